@@ -2,22 +2,37 @@ package main
 
 import (
 	"billing-api/internal/config"
+	"billing-api/internal/domain"
+	billingApiGrpc "billing-api/internal/grpc"
 	billingApiHttp "billing-api/internal/http"
 	"billing-api/internal/infra/db"
 	"billing-api/internal/infra/db/repository"
+	"billing-api/internal/infra/memory"
+	"billing-api/internal/infra/publisher"
 	"billing-api/internal/logger"
+	"billing-api/internal/metrics"
 	"billing-api/internal/service"
+	"billing-api/internal/tracing"
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// billing-api migrate manages the schema and exits, see runMigrate
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -28,6 +43,20 @@ func main() {
 	cfg.LogLevel = logLevel
 
 	appLogger.Info("starting server", slog.String("env", cfg.AppEnv))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		File:         cfg.TracingFile,
+		SampleRatio:  cfg.TracingSampleRatio,
+		Environment:  cfg.AppEnv,
+	})
+	if err != nil {
+		appLogger.Error("Failed to set up tracing", slog.Any("err", err))
+		os.Exit(1)
+	}
+	appLogger.Info("tracing set up", slog.String("exporter", cfg.TracingExporter))
 	appLogger.Info("Try to establsing db connection...")
 	pool, err := db.NewPostgresPool(cfg)
 	if err != nil {
@@ -37,18 +66,156 @@ func main() {
 		appLogger.Info("Successfuly establsing db connection...")
 	}
 
-	defer pool.Close()
+	// the instances starting together wait for the one holding the migration lock, then find nothing left to apply
+	if cfg.MigrateOnStartup {
+		applied, err := db.NewMigrator(pool, db.Migrations).Up(context.Background())
+		if err != nil {
+			appLogger.Error("Failed to migrate the database", slog.Int("applied", len(applied)), slog.Any("err", err))
+			os.Exit(1)
+		}
+		appLogger.Info("database migrated", slog.Int("applied", len(applied)), slog.Int("schema_version", int(db.SchemaVersion())))
+	}
 
-	billingService := service.NewBillingService(pool, repository.NewPostgresRepo(pool))
+	metrics.Registry.MustRegister(db.NewPoolCollector(pool), db.NewTxRetryCollector())
+
+	// transactions failing with a serialization failure, deadlock or lost connection run again
+	db.DefaultTxRetryPolicy = db.ExponentialTxRetry{
+		MaxAttempts: cfg.TxMaxAttempts,
+		BaseBackoff: time.Duration(cfg.TxRetryBackoffMs) * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+
+	tenants, err := service.LoadTenants(cfg.TenantsFile, service.TenantSettings{
+		PagingLimitDefault:  cfg.PagingLimitDefault,
+		PagingLimitMax:      cfg.PagingLimitMax,
+		DelinquencyGapWeeks: cfg.DelinquencyGapWeeks,
+	})
+	if err != nil {
+		appLogger.Error("Failed to load the tenants", slog.Any("err", err))
+		os.Exit(1)
+	}
+	appLogger.Info("serving tenants", slog.Any("tenants", tenants.IDs()))
+
+	eventBus := service.NewEventBus(cfg.EventBusHistorySize)
+	billingService := service.NewBillingService(pool, repository.NewPostgresRepo(pool), eventBus, tenants)
+	collectionService := service.NewCollectionService(
+		repository.NewPostgresCollectionRepo(pool),
+		billingService,
+		service.NewRetryPolicy(cfg.CollectionRetryBackoffDays, cfg.CollectionNonRetryableCodes),
+	)
+	idempotencyService := service.NewIdempotencyService(
+		repository.NewPostgresIdempotencyRepo(pool),
+		time.Duration(cfg.IdempotencyKeyTTL)*time.Second,
+		time.Duration(cfg.IdempotencyLockTimeout)*time.Second,
+	)
+	webhookRepo := repository.NewPostgresWebhookRepo(pool)
+	webhookService := service.NewWebhookService(webhookRepo)
+
+	authService, err := newAuthService(cfg, repository.NewPostgresAPIKeyRepo(pool), tenants)
+	if err != nil {
+		appLogger.Error("Failed to set up authentication", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if !cfg.AuthEnabled {
+		appLogger.Warn("authentication is disabled, every API route is open")
+	}
+	auditService := service.NewAuditService(repository.NewPostgresAuditRepo(pool))
+
+	rateLimiter, rateLimitPool, err := newRateLimiter(cfg)
+	if err != nil {
+		appLogger.Error("Failed to set up rate limiting", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	runnerCtx, stopRunners := context.WithCancel(context.Background())
+	defer stopRunners()
+
+	var loadShedder *service.LoadShedder
+	if cfg.LoadShedEnabled {
+		loadShedder = service.NewLoadShedder(service.PgxPoolStats(pool), service.LoadShedderOptions{
+			InitialLimit:         cfg.LoadShedInitialLimit,
+			MinLimit:             cfg.LoadShedMinLimit,
+			MaxLimit:             cfg.LoadShedMaxLimit,
+			TargetAcquireLatency: time.Duration(cfg.LoadShedTargetAcquireMs) * time.Millisecond,
+			Interval:             time.Duration(cfg.LoadShedAdjustIntervalMs) * time.Millisecond,
+		})
+		loadShedder.Start(runnerCtx)
+	}
+	if cfg.CollectionRunnerEnabled {
+		appLogger.Info("starting direct debit collection runner", slog.Int("interval_seconds", cfg.CollectionRunInterval))
+		service.NewCollectionRunner(collectionService, time.Duration(cfg.CollectionRunInterval)*time.Second).Start(runnerCtx)
+	}
+
+	service.NewIdempotencyPurger(idempotencyService, time.Duration(cfg.IdempotencyPurgeInterval)*time.Second).Start(runnerCtx)
+	if rateLimiter != nil {
+		service.NewRateLimitPurger(rateLimiter, time.Duration(cfg.RateLimitPurgeInterval)*time.Second).Start(runnerCtx)
+	}
+
+	// webhook deliveries are enqueued by the outbox dispatcher, an instance delivering them must relay the outbox too
+	if cfg.WebhookDispatcherEnabled && !cfg.OutboxDispatcherEnabled {
+		appLogger.Error("WEBHOOK_DISPATCHER_ENABLED requires OUTBOX_DISPATCHER_ENABLED, the webhook deliveries are enqueued by the outbox dispatcher")
+		os.Exit(1)
+	}
+
+	if cfg.OutboxDispatcherEnabled {
+		var eventPublisher domain.EventPublisher = publisher.NewLogPublisher()
+		if cfg.OutboxPublishURL != "" {
+			eventPublisher = publisher.NewHTTPPublisher(cfg.OutboxPublishURL, 5*time.Second)
+		}
+		// webhook subscriptions are fed from the outbox as well
+		eventPublisher = publisher.NewMultiPublisher(webhookService, eventPublisher)
+		appLogger.Info("starting outbox dispatcher", slog.Int("poll_interval_seconds", cfg.OutboxPollInterval))
+		service.NewOutboxDispatcher(repository.NewPostgresOutboxRepo(pool), eventPublisher, service.OutboxDispatcherOptions{
+			PollInterval: time.Duration(cfg.OutboxPollInterval) * time.Second,
+			BatchSize:    int32(cfg.OutboxBatchSize),
+			MaxAttempts:  cfg.OutboxMaxAttempts,
+			BaseBackoff:  time.Second,
+			MaxBackoff:   10 * time.Minute,
+		}).Start(runnerCtx)
+	}
+	// the monitor only records the events in the outbox, the instances relaying it may run elsewhere
+	if cfg.DelinquencyMonitorEnabled {
+		service.NewDelinquencyMonitor(billingService, time.Duration(cfg.DelinquencyCheckInterval)*time.Second).Start(runnerCtx)
+	}
+
+	if cfg.WebhookDispatcherEnabled {
+		appLogger.Info("starting webhook dispatcher", slog.Int("poll_interval_seconds", cfg.WebhookPollInterval))
+		service.NewWebhookDispatcher(webhookRepo, publisher.NewWebhookSender(time.Duration(cfg.WebhookTimeout)*time.Second), service.WebhookDispatcherOptions{
+			PollInterval: time.Duration(cfg.WebhookPollInterval) * time.Second,
+			BatchSize:    int32(cfg.WebhookBatchSize),
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			BaseBackoff:  10 * time.Second,
+			MaxBackoff:   time.Hour,
+		}).Start(runnerCtx)
+	}
 
 	addr := ":" + cfg.ServerPort
 
-	router := billingApiHttp.NewRouter(billingService, cfg)
+	readiness := service.NewReadiness(repository.NewPostgresHealthRepo(pool), service.PgxPoolStats(pool), service.ReadinessOptions{
+		SchemaVersion: db.SchemaVersion(),
+		MaxPoolUsage:  cfg.ReadyMaxPoolUsage,
+		Timeout:       2 * time.Second,
+	})
+	router := billingApiHttp.NewRouter(billingApiHttp.RouterDeps{
+		BillingService:     billingService,
+		CollectionService:  collectionService,
+		WebhookService:     webhookService,
+		EventBus:           eventBus,
+		IdempotencyService: idempotencyService,
+		AuthService:        authService,
+		AuditService:       auditService,
+		RateLimiter:        rateLimiter,
+		LoadShedder:        loadShedder,
+		Readiness:          readiness,
+		QueryStats:         db.QueryStatsSnapshot,
+	}, cfg)
 
 	server := &http.Server{
 		Addr:    addr,
 		Handler: router,
 	}
+	// Shutdown waits for the handlers, the SSE streams only return once the bus ends their subscriptions
+	server.RegisterOnShutdown(eventBus.Close)
 
 	go func() {
 		appLogger.Info("billing-api started", slog.String("port", addr))
@@ -58,21 +225,148 @@ func main() {
 		}
 	}()
 
+	// internal services call the same billing service over gRPC, on its own port
+	var grpcServer *grpc.Server
+	if cfg.GRPCEnabled {
+		grpcAddr := ":" + cfg.GRPCPort
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			appLogger.Error("Failed to listen for gRPC", slog.Any("err", err))
+			os.Exit(1)
+		}
+		grpcServer = billingApiGrpc.NewServer(billingService, idempotencyService, authService, cfg)
+		go func() {
+			appLogger.Info("billing-api gRPC started", slog.String("port", grpcAddr))
+			if err := grpcServer.Serve(listener); err != nil {
+				appLogger.Error("gRPC serve error", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
 	<-stop
 
-	appLogger.Info("closing all db connections...")
-	pool.Close()
+	// not ready first, the load balancer stops sending requests while the servers still answer the ones in flight
+	readiness.Drain()
+	appLogger.Info("not ready, waiting before draining...", slog.Int("delay_seconds", cfg.ShutdownDrainDelay))
+	time.Sleep(time.Duration(cfg.ShutdownDrainDelay) * time.Second)
+
+	stopRunners()
+
+	if grpcServer != nil {
+		appLogger.Info("shutting down gRPC server...")
+		stopGRPC(grpcServer, 10*time.Second)
+	}
 
 	appLogger.Info("shutting down billing-api server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		appLogger.Error("List error", slog.Any("err", err))
-		os.Exit(1)
+		// the requests still running are cut when the pool closes
+		appLogger.Error("server did not drain in time", slog.Any("err", err))
+	} else {
+		appLogger.Info("server gracefully stopped")
+	}
+
+	// the requests are drained, nothing uses the pool anymore
+	appLogger.Info("closing all db connections...")
+	pool.Close()
+	if rateLimitPool != nil {
+		rateLimitPool.Close()
+	}
+
+	// the spans of the last requests are still buffered
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		appLogger.Error("Failed to flush the spans", slog.Any("err", err))
+	}
+}
+
+// newAuthService loads the RBAC policy, sets up the JWT verifier when configured and stores the bootstrap admin key
+func newAuthService(cfg *config.Config, repo domain.APIKeyRepository, tenants *service.Tenants) (*service.AuthService, error) {
+	var verifier *service.JWTVerifier
+	if cfg.AuthJWTHMACSecret != "" || cfg.AuthJWKSFile != "" {
+		var err error
+		verifier, err = service.NewJWTVerifier(service.JWTVerifierOptions{
+			HMACSecret: []byte(cfg.AuthJWTHMACSecret),
+			JWKSFile:   cfg.AuthJWKSFile,
+			Issuer:     cfg.AuthJWTIssuer,
+			Audience:   cfg.AuthJWTAudience,
+			Leeway:     30 * time.Second,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := service.LoadPolicy(cfg.RBACPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	authService := service.NewAuthService(repo, verifier, policy, tenants)
+	if cfg.AuthBootstrapAPIKey != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := authService.EnsureAPIKey(ctx, "bootstrap", cfg.AuthBootstrapAPIKey, []string{domain.ScopeAdmin}, []string{service.RoleAdmin}); err != nil {
+			return nil, err
+		}
+	}
+	return authService, nil
+}
+
+/*
+newRateLimiter keeps the token buckets in memory or in Postgres, it returns nil when rate limiting is disabled.
+The Postgres store gets a pool of its own, returned to be closed on shutdown, and the buckets of the instance
+take over while it fails.
+*/
+func newRateLimiter(cfg *config.Config) (*service.RateLimiter, *pgxpool.Pool, error) {
+	if !cfg.RateLimitEnabled {
+		return nil, nil, nil
+	}
+	routes, err := service.ParseRouteRateLimits(cfg.RateLimitRoutes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var repo, fallback domain.RateLimitRepository
+	var pool *pgxpool.Pool
+	switch cfg.RateLimitStore {
+	case "memory":
+		repo = memory.NewRateLimitRepo(memory.NewStore())
+	case "postgres":
+		pool, err = db.NewRateLimitPool(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo = repository.NewPostgresRateLimitRepo(pool, time.Duration(cfg.RateLimitStoreTimeoutMs)*time.Millisecond)
+		fallback = memory.NewRateLimitRepo(memory.NewStore())
+	default:
+		return nil, nil, fmt.Errorf("rate limit: unknown store %q, want memory or postgres", cfg.RateLimitStore)
+	}
+
+	return service.NewRateLimiter(repo, fallback, service.RateLimitRules{
+		PerIP:  domain.RateLimit{Rate: cfg.RateLimitIPRate, Burst: cfg.RateLimitIPBurst},
+		PerKey: domain.RateLimit{Rate: cfg.RateLimitKeyRate, Burst: cfg.RateLimitKeyBurst},
+		Routes: routes,
+	}), pool, nil
+}
+
+// stopGRPC lets the running calls finish, streams still open after the timeout are cut
+func stopGRPC(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.Stop()
 	}
-	appLogger.Info("server gracefully stopped")
 }
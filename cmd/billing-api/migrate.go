@@ -0,0 +1,116 @@
+package main
+
+import (
+	"billing-api/internal/config"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/logger"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: billing-api migrate <command>
+
+commands:
+  up                apply the pending migrations
+  down [steps]      revert the latest applied migrations, 1 by default
+  status            list the migrations and whether they are applied
+  baseline VERSION  record the migrations up to VERSION as applied without running them, and
+                    the checksums of the ones recorded without, for a database created before
+                    the migrator, by docker-entrypoint-initdb.d. Check first that its schema
+                    matches the files, up and down refuse to run until then
+`
+
+// runMigrate runs billing-api migrate against DATABASE_URL and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	appLogger, _ := logger.NewLogger(cfg.AppEnv == "production")
+
+	pool, err := db.NewPostgresPool(cfg)
+	if err != nil {
+		appLogger.Error("Failed to connect to db", slog.Any("err", err))
+		return 1
+	}
+	defer pool.Close()
+
+	// a migration interrupted by a signal is rolled back with its transaction
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	migrator := db.NewMigrator(pool, db.Migrations)
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			appLogger.Error("Failed to migrate the database", slog.Int("applied", len(applied)), slog.Any("err", err))
+			return 1
+		}
+		appLogger.Info("database migrated", slog.Int("applied", len(applied)), slog.Int("schema_version", int(db.SchemaVersion())))
+
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "down: steps must be a positive number, got %q\n", args[1])
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			appLogger.Error("Failed to revert the migrations", slog.Int("reverted", len(reverted)), slog.Any("err", err))
+			return 1
+		}
+		appLogger.Info("migrations reverted", slog.Int("reverted", len(reverted)))
+
+	case command == "status" && len(args) == 1:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			appLogger.Error("Failed to read the migrations", slog.Any("err", err))
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := ""
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+		}
+		w.Flush()
+
+	case command == "baseline" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 32)
+		if err != nil || version < 1 {
+			fmt.Fprintf(os.Stderr, "baseline: VERSION must be a positive number, got %q\n", args[1])
+			return 2
+		}
+		recorded, err := migrator.Baseline(ctx, int32(version))
+		if err != nil {
+			appLogger.Error("Failed to baseline the database", slog.Any("err", err))
+			return 1
+		}
+		appLogger.Info("migrations recorded as applied", slog.Int("recorded", len(recorded)), slog.Int64("version", version))
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var logLevels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func newAdminCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Operate the running server",
+	}
+
+	logLevel := &cobra.Command{
+		Use:       "log-level LEVEL",
+		Short:     "Change the log level of the server at runtime: DEBUG, INFO, WARN or ERROR",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: logLevels,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			level := strings.ToUpper(args[0])
+			if err := a.client.SetLogLevel(cmd.Context(), level); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Log level of %s changed to %s\n", a.baseURL, level)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(logLevel)
+	return cmd
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"billing-api/pkg/billingclient"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Download bank files and loan statements",
+	}
+	cmd.AddCommand(newExportBatchCmd(a), newExportStatementCmd(a))
+	return cmd
+}
+
+// writeExport writes the file to path, or to stdout when path is empty
+func writeExport(cmd *cobra.Command, path string, data []byte) error {
+	if path == "" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d bytes to %s\n", len(data), path)
+	return nil
+}
+
+func newExportBatchCmd(a *app) *cobra.Command {
+	var format, file string
+	cmd := &cobra.Command{
+		Use:     "batch BATCH_ID",
+		Short:   "Download the bank file of a collection batch",
+		Example: `  billingctl export batch 7 --format fixed --file collection-7.txt`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batchID, err := parseID("batch ID", args[0])
+			if err != nil {
+				return err
+			}
+			data, err := a.client.ExportCollectionBatch(cmd.Context(), batchID, format)
+			if err != nil {
+				return err
+			}
+			return writeExport(cmd, file, data)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", billingclient.BankFileFormatCSV, "bank file format: csv or fixed")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file to write, stdout when empty")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(
+		[]string{billingclient.BankFileFormatCSV, billingclient.BankFileFormatFixed}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newExportStatementCmd(a *app) *cobra.Command {
+	var from, to, format, file string
+	cmd := &cobra.Command{
+		Use:     "statement LOAN_ID",
+		Short:   "Download the statement of a loan, by default for the current month",
+		Example: `  billingctl export statement 24 --from 2026-01-01 --to 2026-03-31 --format html --file statement.html`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+
+			var period billingclient.StatementPeriod
+			if period.From, err = parseDateFlag("from", from); err != nil {
+				return err
+			}
+			if period.To, err = parseDateFlag("to", to); err != nil {
+				return err
+			}
+
+			data, err := a.client.DownloadStatement(cmd.Context(), loanID, period, format)
+			if err != nil {
+				return err
+			}
+			return writeExport(cmd, file, data)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "first day of the period as YYYY-MM-DD")
+	cmd.Flags().StringVar(&to, "to", "", "last day of the period as YYYY-MM-DD")
+	cmd.Flags().StringVar(&format, "format", "csv", "statement format: csv, html or json")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file to write, stdout when empty")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"csv", "html", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func parseDateFlag(name, value string) (billingclient.Date, error) {
+	if value == "" {
+		return billingclient.Date{}, nil
+	}
+	d, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return billingclient.Date{}, fmt.Errorf("invalid --%s %q, expected YYYY-MM-DD", name, value)
+	}
+	return billingclient.Date{Time: d}, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"billing-api/pkg/billingclient"
+	"fmt"
+	"iter"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func parseID(name, s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return id, nil
+}
+
+// listFlags are the paging flags shared by the list commands
+type listFlags struct {
+	limit  int
+	cursor string
+	all    bool
+}
+
+func (f *listFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.limit, "limit", 0, "page size, the server default when 0")
+	cmd.Flags().StringVar(&f.cursor, "cursor", "", "cursor of the page to fetch, printed after the previous page")
+	cmd.Flags().BoolVar(&f.all, "all", false, "fetch every page")
+	cmd.MarkFlagsMutuallyExclusive("cursor", "all")
+}
+
+func (f *listFlags) params() billingclient.ListParams {
+	return billingclient.ListParams{Limit: f.limit, Cursor: f.cursor}
+}
+
+// collect drains an iterator of the client, for --all
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// printNextCursor tells how to fetch the next page, on stderr so the output can still be piped
+func printNextCursor(cmd *cobra.Command, next string) {
+	if next != "" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "More results: --cursor %s\n", next)
+	}
+}
+
+func newLoanCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "loan",
+		Short: "Create, show and list loans",
+	}
+	cmd.AddCommand(newLoanCreateCmd(a), newLoanGetCmd(a), newLoanListCmd(a))
+	return cmd
+}
+
+func newLoanCreateCmd(a *app) *cobra.Command {
+	var in billingclient.SubmitLoanInput
+	var startDate string
+
+	cmd := &cobra.Command{
+		Use:     "create",
+		Short:   "Create a loan and its weekly schedule",
+		Example: `  billingctl loan create --principal 5000000 --rate 0.1 --weeks 50 --start-date 2026-01-05`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if startDate == "" {
+				in.StartDate = billingclient.Date{Time: time.Now().UTC().Truncate(24 * time.Hour)}
+			} else {
+				d, err := time.Parse(time.DateOnly, startDate)
+				if err != nil {
+					return fmt.Errorf("invalid start date %q, expected YYYY-MM-DD", startDate)
+				}
+				in.StartDate = billingclient.Date{Time: d}
+			}
+
+			loan, err := a.client.SubmitLoan(cmd.Context(), in)
+			if err != nil {
+				return err
+			}
+			return a.render(cmd, loan, table{
+				header: []string{"LOAN_ID", "WEEKLY_PAYMENT", "TOTAL_PAYABLE"},
+				rows:   [][]string{{formatInt(loan.LoanID), formatInt(loan.WeeklyPaymentAmount), formatInt(loan.TotalPayable)}},
+			})
+		},
+	}
+	cmd.Flags().Int64Var(&in.PrincipalAmount, "principal", 0, "principal amount")
+	cmd.Flags().Float64Var(&in.AnnualInterestRate, "rate", 0, "annual interest rate, 0.1 for 10%")
+	cmd.Flags().IntVar(&in.TotalWeeks, "weeks", 0, "number of weekly installments")
+	cmd.Flags().StringVar(&startDate, "start-date", "", "start date as YYYY-MM-DD (default today)")
+	cmd.Flags().StringVar(&in.BorrowerID, "borrower", "", "subject of the borrower allowed to read the loan")
+	cmd.Flags().StringVar(&in.IdempotencyKey, "idempotency-key", "", "key to retry a create safely, generated when empty")
+	_ = cmd.MarkFlagRequired("principal")
+	_ = cmd.MarkFlagRequired("weeks")
+	return cmd
+}
+
+func newLoanGetCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get LOAN_ID",
+		Short: "Show a loan with its delinquency flag",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+			loan, err := a.client.GetLoan(cmd.Context(), loanID)
+			if err != nil {
+				return err
+			}
+			return a.render(cmd, loan, table{
+				header: []string{"LOAN_ID", "TOTAL_PAYABLE", "WEEKLY_PAYMENT", "TOTAL_WEEKS", "CREATED_AT", "DELINQUENT"},
+				rows: [][]string{{
+					formatInt(loan.LoanID),
+					formatInt(loan.TotalPayable),
+					formatInt(loan.WeeklyPaymentAmount),
+					formatInt(loan.TotalWeeks),
+					formatTime(loan.CreatedAt),
+					strconv.FormatBool(loan.IsDelinquent),
+				}},
+			})
+		},
+	}
+}
+
+func newLoanListCmd(a *app) *cobra.Command {
+	var f listFlags
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List loans, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var loans []billingclient.LoanSummary
+			var value any
+			if f.all {
+				all, err := collect(a.client.Loans(cmd.Context(), f.limit))
+				if err != nil {
+					return err
+				}
+				loans, value = all, all
+			} else {
+				page, err := a.client.ListLoans(cmd.Context(), f.params())
+				if err != nil {
+					return err
+				}
+				loans, value = page.Loans, page
+				defer printNextCursor(cmd, page.NextCursor)
+			}
+
+			t := table{header: []string{"LOAN_ID", "PRINCIPAL", "TOTAL_PAYABLE", "WEEKLY_PAYMENT", "TOTAL_WEEKS", "CREATED_AT"}}
+			for _, l := range loans {
+				t.rows = append(t.rows, []string{
+					formatInt(l.LoanID),
+					formatInt(l.PrincipalAmount),
+					formatInt(l.TotalPayable),
+					formatInt(l.WeeklyPaymentAmount),
+					formatInt(l.TotalWeeks),
+					formatTime(l.CreatedAt),
+				})
+			}
+			return a.render(cmd, value, t)
+		},
+	}
+	f.register(cmd)
+	return cmd
+}
+
+func newOutstandingCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "outstanding LOAN_ID",
+		Short: "Show the amount still to be paid on a loan",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+			outstanding, err := a.client.GetOutstanding(cmd.Context(), loanID)
+			if err != nil {
+				return err
+			}
+			return a.render(cmd, outstanding, table{
+				header: []string{"LOAN_ID", "OUTSTANDING"},
+				rows:   [][]string{{formatInt(outstanding.LoanID), formatInt(outstanding.Outstanding)}},
+			})
+		},
+	}
+}
+
+func newScheduleCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Show the weekly installments of a loan",
+	}
+
+	var f listFlags
+	list := &cobra.Command{
+		Use:   "list LOAN_ID",
+		Short: "List the installments of a loan in due date order",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+
+			var schedules []billingclient.Schedule
+			var value any
+			if f.all {
+				all, err := collect(a.client.Schedules(cmd.Context(), loanID, f.limit))
+				if err != nil {
+					return err
+				}
+				schedules, value = all, all
+			} else {
+				page, err := a.client.ListSchedules(cmd.Context(), loanID, f.params())
+				if err != nil {
+					return err
+				}
+				schedules, value = page.Schedules, page
+				defer printNextCursor(cmd, page.NextCursor)
+			}
+
+			t := table{header: []string{"SEQUENCE", "DUE_DATE", "AMOUNT", "PAID_AMOUNT", "STATUS"}}
+			for _, s := range schedules {
+				t.rows = append(t.rows, []string{
+					formatInt(s.Sequence),
+					s.DueDate.String(),
+					formatInt(s.Amount),
+					formatInt(s.PaidAmount),
+					s.Status,
+				})
+			}
+			return a.render(cmd, value, t)
+		},
+	}
+	f.register(list)
+
+	cmd.AddCommand(list)
+	return cmd
+}
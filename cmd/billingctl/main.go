@@ -0,0 +1,119 @@
+/*
+billingctl is the command-line tool of the billing API for day-to-day operations: creating loans, posting payments,
+reading schedules and balances, changing the log level and exporting files.
+
+It talks to the REST API through pkg/billingclient, so payments are sent with an idempotency key and retried
+safely. The target environment comes from a profile, see `billingctl profile --help`.
+*/
+package main
+
+import (
+	"billing-api/pkg/billingclient"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+// app holds the global flags, the client is built from them once the command line is parsed
+type app struct {
+	profile string
+	baseURL string
+	output  string
+	timeout time.Duration
+
+	client *billingclient.Client
+}
+
+func newRootCmd() *cobra.Command {
+	a := &app{}
+
+	root := &cobra.Command{
+		Use:   "billingctl",
+		Short: "Operate the billing API from the command line",
+		Long: `Operate the billing API from the command line.
+
+The API is selected with --base-url, the BILLINGCTL_BASE_URL variable or a profile (--profile, BILLINGCTL_PROFILE or
+the current profile of the config file), in that order. Without any it is ` + defaultBaseURL + `.`,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return a.init(cmd)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&a.profile, "profile", "p", os.Getenv("BILLINGCTL_PROFILE"), "profile to use")
+	flags.StringVar(&a.baseURL, "base-url", os.Getenv("BILLINGCTL_BASE_URL"), "URL of the billing API, overrides the profile")
+	flags.StringVarP(&a.output, "output", "o", "", "output format: table, json or csv (default table)")
+	flags.DurationVar(&a.timeout, "timeout", 30*time.Second, "timeout of each request")
+	_ = root.RegisterFlagCompletionFunc("profile", completeProfiles)
+	_ = root.RegisterFlagCompletionFunc("output", completeOutputFormats)
+
+	root.AddCommand(
+		newLoanCmd(a),
+		newPaymentCmd(a),
+		newScheduleCmd(a),
+		newOutstandingCmd(a),
+		newAdminCmd(a),
+		newExportCmd(a),
+		newProfileCmd(a),
+	)
+	return root
+}
+
+// init resolves the profile and builds the client, profile commands work without a reachable API
+func (a *app) init(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := a.profile
+	if name == "" {
+		name = cfg.Current
+	}
+	var profile Profile
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("unknown profile %q, see billingctl profile list", name)
+		}
+		profile = p
+	}
+
+	if a.baseURL == "" {
+		a.baseURL = profile.BaseURL
+	}
+	if a.baseURL == "" {
+		a.baseURL = defaultBaseURL
+	}
+	if a.output == "" {
+		a.output = profile.Output
+	}
+	if a.output == "" {
+		a.output = outputTable
+	}
+	if err := validateOutput(a.output); err != nil {
+		return err
+	}
+
+	opts := []billingclient.Option{billingclient.WithHTTPClient(newHTTPClient(a.timeout))}
+	for key, value := range profile.Headers {
+		opts = append(opts, billingclient.WithHeader(key, value))
+	}
+	a.client = billingclient.New(a.baseURL, opts...)
+	return nil
+}
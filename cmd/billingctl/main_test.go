@@ -0,0 +1,172 @@
+package main
+
+import (
+	"billing-api/internal/config"
+	billingApiHttp "billing-api/internal/http"
+	"billing-api/internal/infra/memory"
+	"billing-api/internal/service"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *config.Config) {
+	t.Helper()
+
+	store := memory.NewStore()
+	eventBus := service.NewEventBus(100)
+	billingService := service.NewBillingService(nil, memory.NewBillingRepo(store), eventBus, nil)
+	collectionService := service.NewCollectionService(memory.NewCollectionRepo(store), billingService, service.NewRetryPolicy([]int{3, 7}, nil))
+	webhookService := service.NewWebhookService(memory.NewWebhookRepo(store))
+	idempotencyService := service.NewIdempotencyService(memory.NewIdempotencyRepo(store), time.Hour, time.Minute)
+	cfg := &config.Config{PagingLimitDefault: 10, PagingLimitMax: 100, LogLevel: new(slog.LevelVar)}
+
+	server := httptest.NewServer(billingApiHttp.NewRouter(billingApiHttp.RouterDeps{
+		BillingService:     billingService,
+		CollectionService:  collectionService,
+		WebhookService:     webhookService,
+		EventBus:           eventBus,
+		IdempotencyService: idempotencyService,
+		AuthService:        service.NewAuthService(memory.NewAPIKeyRepo(store), nil, service.DefaultPolicy(), nil),
+		AuditService:       service.NewAuditService(memory.NewAuditRepo(store)),
+	}, cfg))
+	t.Cleanup(server.Close)
+	return server, cfg
+}
+
+// run executes billingctl with a config file of the test and returns stdout and stderr
+func run(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	err := cmd.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestBillingctl(t *testing.T) {
+	server, cfg := newTestServer(t)
+	t.Setenv("BILLINGCTL_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	t.Setenv("BILLINGCTL_PROFILE", "")
+	t.Setenv("BILLINGCTL_BASE_URL", "")
+
+	out, _, err := run(t, "profile", "set", "test", "--base-url", server.URL, "--header", "X-Operator=alice")
+	require.NoError(t, err)
+	assert.Contains(t, out, "test")
+
+	createLoan := func() int64 {
+		out, _, err := run(t, "loan", "create", "--principal", "5000000", "--rate", "0.1", "--weeks", "50", "-o", "json")
+		require.NoError(t, err)
+		var loan struct {
+			LoanID              int64 `json:"loan_id"`
+			WeeklyPaymentAmount int64 `json:"weekly_payment_amount"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &loan))
+		assert.Equal(t, int64(110000), loan.WeeklyPaymentAmount)
+		return loan.LoanID
+	}
+	first := createLoan()
+	second := createLoan()
+
+	t.Run("profiles", func(t *testing.T) {
+		out, _, err := run(t, "profile", "list")
+		require.NoError(t, err)
+		assert.Contains(t, out, "*")
+		assert.Contains(t, out, server.URL)
+
+		_, _, err = run(t, "--profile", "missing", "loan", "get", "1")
+		assert.ErrorContains(t, err, `unknown profile "missing"`)
+	})
+
+	t.Run("loan list pages with the cursor", func(t *testing.T) {
+		out, stderr, err := run(t, "loan", "list", "--limit", "1", "-o", "csv")
+		require.NoError(t, err)
+		records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "LOAN_ID", records[0][0])
+		assert.Equal(t, strconv.FormatInt(second, 10), records[1][0])
+
+		cursor := strings.TrimSpace(strings.TrimPrefix(stderr, "More results: --cursor"))
+		require.NotEmpty(t, cursor)
+		out, _, err = run(t, "loan", "list", "--limit", "1", "--cursor", cursor, "-o", "csv")
+		require.NoError(t, err)
+		assert.Contains(t, out, strconv.FormatInt(first, 10))
+
+		out, _, err = run(t, "loan", "list", "--all", "--limit", "1", "-o", "json")
+		require.NoError(t, err)
+		var loans []struct {
+			LoanID int64 `json:"loan_id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &loans))
+		require.Len(t, loans, 2)
+		assert.Equal(t, []int64{second, first}, []int64{loans[0].LoanID, loans[1].LoanID})
+	})
+
+	t.Run("payment submit defaults to the weekly amount and is retried with its key", func(t *testing.T) {
+		id := strconv.FormatInt(first, 10)
+		out, _, err := run(t, "payment", "submit", id, "--idempotency-key", "cli-test-week-1", "-o", "json")
+		require.NoError(t, err)
+		var payment submittedPayment
+		require.NoError(t, json.Unmarshal([]byte(out), &payment))
+		assert.Equal(t, int64(110000), payment.Amount)
+		assert.False(t, payment.AlreadyProcessed)
+
+		out, _, err = run(t, "payment", "submit", id, "--idempotency-key", "cli-test-week-1", "-o", "json")
+		require.NoError(t, err)
+		var replayed submittedPayment
+		require.NoError(t, json.Unmarshal([]byte(out), &replayed))
+		assert.Equal(t, payment.PaymentID, replayed.PaymentID)
+		assert.True(t, replayed.AlreadyProcessed)
+
+		out, _, err = run(t, "payment", "list", id)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(out, "\n"), out)
+
+		out, _, err = run(t, "outstanding", id, "-o", "csv")
+		require.NoError(t, err)
+		assert.Contains(t, out, id+",5390000")
+	})
+
+	t.Run("API errors are returned", func(t *testing.T) {
+		_, _, err := run(t, "loan", "get", "999999")
+		assert.Error(t, err)
+
+		_, _, err = run(t, "payment", "submit", "999999", "--amount", "1")
+		assert.ErrorContains(t, err, "retry with --idempotency-key")
+
+		_, _, err = run(t, "loan", "get", "abc")
+		assert.ErrorContains(t, err, `invalid loan ID "abc"`)
+	})
+
+	t.Run("schedule and statement", func(t *testing.T) {
+		out, _, err := run(t, "schedule", "list", strconv.FormatInt(second, 10), "--limit", "3")
+		require.NoError(t, err)
+		assert.Equal(t, 4, strings.Count(out, "\n"), out)
+
+		file := filepath.Join(t.TempDir(), "statement.csv")
+		_, stderr, err := run(t, "export", "statement", strconv.FormatInt(first, 10), "--file", file)
+		require.NoError(t, err)
+		assert.Contains(t, stderr, "Wrote")
+	})
+
+	t.Run("admin log-level", func(t *testing.T) {
+		out, _, err := run(t, "admin", "log-level", "debug")
+		require.NoError(t, err)
+		assert.Contains(t, out, "DEBUG")
+		assert.Equal(t, slog.LevelDebug, cfg.LogLevel.Level())
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"billing-api/pkg/billingclient"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func newPaymentCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "payment",
+		Short: "Post and list loan payments",
+	}
+	cmd.AddCommand(newPaymentSubmitCmd(a), newPaymentListCmd(a))
+	return cmd
+}
+
+// submittedPayment is the output of payment submit, with the key to quote when following up on the payment
+type submittedPayment struct {
+	PaymentID        int64  `json:"payment_id"`
+	LoanID           int64  `json:"loan_id"`
+	Amount           int64  `json:"amount"`
+	AlreadyProcessed bool   `json:"already_processed"`
+	IdempotencyKey   string `json:"idempotency_key"`
+}
+
+func newPaymentSubmitCmd(a *app) *cobra.Command {
+	var in billingclient.SubmitPaymentInput
+
+	cmd := &cobra.Command{
+		Use:   "submit LOAN_ID",
+		Short: "Pay the next weekly installment of a loan",
+		Long: `Pay the next weekly installment of a loan.
+
+Without --amount the weekly payment amount of the loan is paid. Pass the --idempotency-key of a previous attempt to
+retry it without risking a second payment.`,
+		Example: `  billingctl payment submit 24
+  billingctl payment submit 24 --amount 110000 --idempotency-key 5f0c6d8e-pay-week-3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+			if in.Amount == 0 {
+				loan, err := a.client.GetLoan(cmd.Context(), loanID)
+				if err != nil {
+					return err
+				}
+				in.Amount = loan.WeeklyPaymentAmount
+			}
+			if in.IdempotencyKey == "" {
+				in.IdempotencyKey = billingclient.NewIdempotencyKey()
+			}
+
+			result, err := a.client.SubmitPayment(cmd.Context(), loanID, in)
+			if err != nil {
+				// the key lets the operator retry a payment whose outcome is unknown
+				return fmt.Errorf("%w\nretry with --idempotency-key %s", err, in.IdempotencyKey)
+			}
+
+			value := submittedPayment{
+				PaymentID:        result.PaymentID,
+				LoanID:           loanID,
+				Amount:           in.Amount,
+				AlreadyProcessed: result.AlreadyProcessed,
+				IdempotencyKey:   in.IdempotencyKey,
+			}
+			return a.render(cmd, value, table{
+				header: []string{"PAYMENT_ID", "LOAN_ID", "AMOUNT", "ALREADY_PROCESSED", "IDEMPOTENCY_KEY"},
+				rows:   [][]string{{formatInt(result.PaymentID), formatInt(loanID), formatInt(in.Amount), strconv.FormatBool(result.AlreadyProcessed), in.IdempotencyKey}},
+			})
+		},
+	}
+	cmd.Flags().Int64Var(&in.Amount, "amount", 0, "amount to pay (default the weekly payment amount)")
+	cmd.Flags().StringVar(&in.IdempotencyKey, "idempotency-key", "", "key to retry a payment safely, generated when empty")
+	return cmd
+}
+
+func newPaymentListCmd(a *app) *cobra.Command {
+	var f listFlags
+	cmd := &cobra.Command{
+		Use:   "list LOAN_ID",
+		Short: "List the payments of a loan, oldest first",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loanID, err := parseID("loan ID", args[0])
+			if err != nil {
+				return err
+			}
+
+			var payments []billingclient.Payment
+			var value any
+			if f.all {
+				all, err := collect(a.client.Payments(cmd.Context(), loanID, f.limit))
+				if err != nil {
+					return err
+				}
+				payments, value = all, all
+			} else {
+				page, err := a.client.ListPayments(cmd.Context(), loanID, f.params())
+				if err != nil {
+					return err
+				}
+				payments, value = page.Payments, page
+				defer printNextCursor(cmd, page.NextCursor)
+			}
+
+			t := table{header: []string{"WEEK", "AMOUNT", "PAID_AT"}}
+			for _, p := range payments {
+				t.rows = append(t.rows, []string{formatInt(p.WeekNumber), formatInt(p.Amount), formatTime(p.PaidAt)})
+			}
+			return a.render(cmd, value, t)
+		},
+	}
+	f.register(cmd)
+	return cmd
+}
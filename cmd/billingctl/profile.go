@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultBaseURL = "http://localhost:8081"
+
+// Profile is one environment the CLI talks to
+type Profile struct {
+	BaseURL string            `json:"base_url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Output  string            `json:"output,omitempty"`
+}
+
+// Config is the profiles file, BILLINGCTL_CONFIG or billingctl/config.json in the user config directory
+type Config struct {
+	Current  string             `json:"current"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("BILLINGCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "billingctl", "config.json"), nil
+}
+
+// loadConfig reads the profiles file, a missing file is an empty config
+func loadConfig() (*Config, error) {
+	cfg := &Config{Profiles: map[string]Profile{}}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	return cfg, nil
+}
+
+func (c *Config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// headers may hold credentials
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func (c *Config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func newProfileCmd(app *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the environments the CLI talks to",
+	}
+	cmd.AddCommand(newProfileListCmd(app), newProfileSetCmd(), newProfileUseCmd(), newProfileDeleteCmd())
+	return cmd
+}
+
+func newProfileListCmd(app *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, the current one is marked with *",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			t := table{header: []string{"CURRENT", "NAME", "BASE_URL", "OUTPUT"}}
+			for _, name := range cfg.profileNames() {
+				p := cfg.Profiles[name]
+				current := ""
+				if name == cfg.Current {
+					current = "*"
+				}
+				t.rows = append(t.rows, []string{current, name, p.BaseURL, p.Output})
+			}
+			return app.render(cmd, cfg.Profiles, t)
+		},
+	}
+}
+
+func newProfileSetCmd() *cobra.Command {
+	var baseURL, output string
+	var headers []string
+
+	cmd := &cobra.Command{
+		Use:   "set NAME",
+		Short: "Create or update a profile",
+		Example: `  billingctl profile set staging --base-url https://billing.staging.internal --header "Authorization=Bearer $TOKEN"
+  billingctl profile set local --base-url http://localhost:8081 --output json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			p := cfg.Profiles[args[0]]
+			if cmd.Flags().Changed("base-url") {
+				p.BaseURL = baseURL
+			}
+			if cmd.Flags().Changed("output") {
+				if err := validateOutput(output); err != nil {
+					return err
+				}
+				p.Output = output
+			}
+			for _, h := range headers {
+				key, value, ok := strings.Cut(h, "=")
+				if !ok || key == "" {
+					return fmt.Errorf("invalid header %q, expected KEY=VALUE", h)
+				}
+				if p.Headers == nil {
+					p.Headers = map[string]string{}
+				}
+				// an empty value removes the header
+				if value == "" {
+					delete(p.Headers, key)
+					continue
+				}
+				p.Headers[key] = value
+			}
+			if p.BaseURL == "" {
+				p.BaseURL = defaultBaseURL
+			}
+
+			cfg.Profiles[args[0]] = p
+			if cfg.Current == "" {
+				cfg.Current = args[0]
+			}
+			if err := cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile %s saved\n", args[0])
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "URL of the billing API")
+	cmd.Flags().StringVar(&output, "output", "", "default output format of the profile: table, json or csv")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "header sent with every request as KEY=VALUE, repeatable, an empty value removes it")
+	_ = cmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
+	return cmd
+}
+
+func newProfileUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "use NAME",
+		Short:             "Make a profile the default",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				return fmt.Errorf("unknown profile %q", args[0])
+			}
+			cfg.Current = args[0]
+			if err := cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Switched to profile %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newProfileDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete NAME",
+		Short:             "Delete a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				return fmt.Errorf("unknown profile %q", args[0])
+			}
+			delete(cfg.Profiles, args[0])
+			if cfg.Current == args[0] {
+				cfg.Current = ""
+			}
+			if err := cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile %s deleted\n", args[0])
+			return nil
+		},
+	}
+}
+
+func completeProfiles(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return cfg.profileNames(), cobra.ShellCompDirectiveNoFileComp
+}
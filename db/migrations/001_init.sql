@@ -1,6 +1,4 @@
-DROP TABLE IF EXISTS public.payments;
-DROP TABLE IF EXISTS public.loans;
-DROP TABLE IF EXISTS public.schedules;
+-- migrate:up
 CREATE TABLE loans (
   id BIGSERIAL PRIMARY KEY,
   principal_amount BIGINT NOT NULL,
@@ -39,4 +37,8 @@ CREATE TABLE schedules (
   updated_at TIMESTAMP NOT NULL DEFAULT now(),
   UNIQUE (loan_id, sequence)
 );
-CREATE INDEX idx_schedules_loan_sequence ON schedules (loan_id, sequence);
\ No newline at end of file
+CREATE INDEX idx_schedules_loan_sequence ON schedules (loan_id, sequence);
+-- migrate:down
+DROP TABLE IF EXISTS schedules;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS loans;
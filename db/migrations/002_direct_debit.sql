@@ -0,0 +1,48 @@
+-- migrate:up
+CREATE TABLE mandates (
+  id BIGSERIAL PRIMARY KEY,
+  loan_id BIGINT NOT NULL REFERENCES loans(id),
+  account_holder TEXT NOT NULL,
+  bank_code VARCHAR(16) NOT NULL,
+  account_number VARCHAR(34) NOT NULL,
+  reference VARCHAR(35) NOT NULL,
+  status TEXT NOT NULL,
+  -- ACTIVE | REVOKED
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  revoked_at TIMESTAMP
+);
+-- only one active mandate per loan, revoked ones are kept as history
+CREATE UNIQUE INDEX uk_mandates_active_loan_id ON mandates (loan_id)
+WHERE status = 'ACTIVE';
+CREATE TABLE collection_batches (
+  id BIGSERIAL PRIMARY KEY,
+  collection_date DATE NOT NULL,
+  status TEXT NOT NULL,
+  -- CREATED | EXPORTED | RECONCILED
+  item_count INT NOT NULL,
+  total_amount BIGINT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE TABLE collection_items (
+  id BIGSERIAL PRIMARY KEY,
+  batch_id BIGINT NOT NULL REFERENCES collection_batches(id),
+  loan_id BIGINT NOT NULL REFERENCES loans(id),
+  mandate_id BIGINT NOT NULL REFERENCES mandates(id),
+  schedule_sequence INT NOT NULL,
+  amount BIGINT NOT NULL,
+  attempt INT NOT NULL,
+  status TEXT NOT NULL,
+  -- SUBMITTED | SUCCEEDED | RETRY_PENDING | RETRIED | FAILED
+  failure_code TEXT,
+  next_attempt_on DATE,
+  payment_id BIGINT REFERENCES payments(id),
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_collection_items_batch_id ON collection_items (batch_id, id);
+CREATE INDEX idx_collection_items_loan_sequence ON collection_items (loan_id, schedule_sequence);
+-- migrate:down
+DROP TABLE IF EXISTS collection_items;
+DROP TABLE IF EXISTS collection_batches;
+DROP TABLE IF EXISTS mandates;
@@ -0,0 +1,22 @@
+-- migrate:up
+CREATE TABLE outbox_events (
+  id BIGSERIAL PRIMARY KEY,
+  aggregate_type TEXT NOT NULL,
+  -- LOAN
+  aggregate_id BIGINT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING',
+  -- PENDING | DISPATCHED | DEAD
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  dispatched_at TIMESTAMP
+);
+-- dispatcher scans pending events in id order, per aggregate to preserve ordering
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id)
+WHERE status = 'PENDING';
+CREATE INDEX idx_outbox_events_aggregate ON outbox_events (aggregate_type, aggregate_id, id);
+-- migrate:down
+DROP TABLE IF EXISTS outbox_events;
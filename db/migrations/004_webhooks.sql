@@ -0,0 +1,46 @@
+-- migrate:up
+CREATE TABLE webhook_subscriptions (
+  id BIGSERIAL PRIMARY KEY,
+  url TEXT NOT NULL,
+  event_types TEXT [] NOT NULL,
+  secret TEXT NOT NULL,
+  status TEXT NOT NULL,
+  -- ACTIVE | DELETED
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at TIMESTAMP
+);
+-- one row per (subscription, outbox event), the unique key makes the fan-out idempotent
+CREATE TABLE webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id),
+  event_id BIGINT NOT NULL REFERENCES outbox_events(id),
+  event_type TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING',
+  -- PENDING | DELIVERED | DEAD
+  attempts INT NOT NULL DEFAULT 0,
+  last_status_code INT,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  delivered_at TIMESTAMP,
+  CONSTRAINT uk_webhook_deliveries_subscription_event UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at)
+WHERE status = 'PENDING';
+CREATE INDEX idx_webhook_deliveries_dead ON webhook_deliveries (id)
+WHERE status = 'DEAD';
+-- delivery log, one row per HTTP attempt
+CREATE TABLE webhook_delivery_attempts (
+  id BIGSERIAL PRIMARY KEY,
+  delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id),
+  attempt INT NOT NULL,
+  status_code INT,
+  error TEXT,
+  duration_ms INT NOT NULL,
+  attempted_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id, id);
+-- migrate:down
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,18 @@
+-- migrate:up
+CREATE TABLE idempotency_keys (
+  key VARCHAR(255) PRIMARY KEY,
+  -- sha256 of method, path and body hash
+  request_hash TEXT NOT NULL,
+  status TEXT NOT NULL,
+  -- IN_PROGRESS | COMPLETED
+  response_status INT,
+  response_content_type TEXT,
+  response_body BYTEA,
+  locked_at TIMESTAMP NOT NULL DEFAULT now(),
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+-- migrate:down
+DROP TABLE IF EXISTS idempotency_keys;
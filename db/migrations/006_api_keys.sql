@@ -0,0 +1,19 @@
+-- migrate:up
+-- the secret part of a key is never stored, only the sha256 of the whole key
+CREATE TABLE api_keys (
+  id BIGSERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  -- public part of the key, used to look it up and shown in listings and logs
+  prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL,
+  scopes TEXT [] NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  -- the key this one replaced, set by a rotation
+  rotated_from BIGINT REFERENCES api_keys(id),
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  CONSTRAINT uk_api_keys_prefix UNIQUE (prefix)
+);
+-- migrate:down
+DROP TABLE IF EXISTS api_keys;
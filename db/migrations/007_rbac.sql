@@ -0,0 +1,20 @@
+-- migrate:up
+-- roles of the RBAC policy granted to an API key, JWTs carry theirs in the roles claim
+ALTER TABLE api_keys
+ADD COLUMN IF NOT EXISTS roles TEXT [] NOT NULL DEFAULT '{}';
+-- subject of the borrower owning the loan, a caller with only the loan:read:own permission sees the loans
+-- whose borrower_id is its own subject
+ALTER TABLE loans
+ADD COLUMN IF NOT EXISTS borrower_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_loans_borrower_id ON loans (borrower_id, id);
+-- keys issued before the roles existed keep what their scopes allowed
+UPDATE api_keys
+SET roles = CASE
+    WHEN 'admin' = ANY(scopes) THEN ARRAY ['admin']
+    ELSE ARRAY ['agent']
+  END
+WHERE roles = '{}';
+-- migrate:down
+DROP INDEX IF EXISTS idx_loans_borrower_id;
+ALTER TABLE loans DROP COLUMN IF EXISTS borrower_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,78 @@
+-- migrate:up
+-- every row belongs to a lending partner, rows created before the tenants existed belong to the default tenant
+ALTER TABLE loans
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE payments
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE schedules
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE collection_batches
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE idempotency_keys
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+-- the tenant of the callers authenticated by the key
+ALTER TABLE api_keys
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+DROP INDEX IF EXISTS idx_loans_borrower_id;
+CREATE INDEX IF NOT EXISTS idx_loans_tenant_id ON loans (tenant_id, id);
+CREATE INDEX IF NOT EXISTS idx_loans_tenant_borrower_id ON loans (tenant_id, borrower_id, id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id, id);
+-- two partners may use the same idempotency keys
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS uk_payments_idempotency_key;
+ALTER TABLE payments
+ADD CONSTRAINT uk_payments_tenant_idempotency_key UNIQUE (tenant_id, idempotency_key);
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys
+ADD PRIMARY KEY (tenant_id, key);
+/*
+ Row-level security, a second line of defence behind the tenant filter of every query.
+ The policies only apply to roles that do not own the tables, or to every role once FORCE ROW LEVEL SECURITY
+ is set. Run the API with DB_ROW_LEVEL_SECURITY=true so each connection sets app.tenant_id before its queries,
+ a connection without it sees no row.
+ */
+ALTER TABLE loans ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE schedules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE collection_batches ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON loans;
+CREATE POLICY tenant_isolation ON loans USING (
+  tenant_id = current_setting('app.tenant_id', true)
+);
+DROP POLICY IF EXISTS tenant_isolation ON payments;
+CREATE POLICY tenant_isolation ON payments USING (
+  tenant_id = current_setting('app.tenant_id', true)
+);
+DROP POLICY IF EXISTS tenant_isolation ON schedules;
+CREATE POLICY tenant_isolation ON schedules USING (
+  tenant_id = current_setting('app.tenant_id', true)
+);
+DROP POLICY IF EXISTS tenant_isolation ON collection_batches;
+CREATE POLICY tenant_isolation ON collection_batches USING (
+  tenant_id = current_setting('app.tenant_id', true)
+);
+-- migrate:down
+DROP POLICY IF EXISTS tenant_isolation ON collection_batches;
+DROP POLICY IF EXISTS tenant_isolation ON schedules;
+DROP POLICY IF EXISTS tenant_isolation ON payments;
+DROP POLICY IF EXISTS tenant_isolation ON loans;
+ALTER TABLE collection_batches DISABLE ROW LEVEL SECURITY;
+ALTER TABLE schedules DISABLE ROW LEVEL SECURITY;
+ALTER TABLE payments DISABLE ROW LEVEL SECURITY;
+ALTER TABLE loans DISABLE ROW LEVEL SECURITY;
+-- fails when two tenants used the same idempotency key
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys
+ADD PRIMARY KEY (key);
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS uk_payments_tenant_idempotency_key;
+ALTER TABLE payments
+ADD CONSTRAINT uk_payments_idempotency_key UNIQUE (idempotency_key);
+DROP INDEX IF EXISTS idx_api_keys_tenant_id;
+DROP INDEX IF EXISTS idx_loans_tenant_borrower_id;
+DROP INDEX IF EXISTS idx_loans_tenant_id;
+CREATE INDEX IF NOT EXISTS idx_loans_borrower_id ON loans (borrower_id, id);
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE collection_batches DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE schedules DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE loans DROP COLUMN IF EXISTS tenant_id;
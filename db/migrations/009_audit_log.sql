@@ -0,0 +1,36 @@
+-- migrate:up
+-- one row per state-changing operation, written in the transaction of the change
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  occurred_at TIMESTAMP NOT NULL,
+  -- subject of the caller, "anonymous" without authentication, "system" for the background jobs
+  actor TEXT NOT NULL,
+  -- loan.create, payment.create, api_key.rotate, ...
+  action TEXT NOT NULL,
+  entity_type TEXT NOT NULL,
+  entity_id TEXT NOT NULL,
+  -- JSON rather than JSONB, the documents are hashed and must be read back byte for byte
+  before_state JSON,
+  after_state JSON,
+  request_id TEXT NOT NULL DEFAULT '',
+  idempotency_key TEXT NOT NULL DEFAULT '',
+  source_ip TEXT NOT NULL DEFAULT '',
+  -- sha256 of the previous entry of the tenant and of this one, see domain.AuditEntry.ComputeHash
+  prev_hash TEXT NOT NULL,
+  hash TEXT NOT NULL
+);
+CREATE INDEX idx_audit_log_tenant_id ON audit_log (tenant_id, id);
+CREATE INDEX idx_audit_log_tenant_entity ON audit_log (tenant_id, entity_type, entity_id, id);
+CREATE INDEX idx_audit_log_tenant_actor ON audit_log (tenant_id, actor, id);
+-- append-only, an entry can not be changed or removed once written
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER trg_audit_log_append_only BEFORE
+UPDATE
+  OR DELETE ON audit_log FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE TRIGGER trg_audit_log_no_truncate BEFORE TRUNCATE ON audit_log FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+-- migrate:down
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
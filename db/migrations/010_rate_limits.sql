@@ -0,0 +1,15 @@
+-- migrate:up
+-- token buckets of the rate limiter shared by every instance, see service.RateLimiter
+-- unlogged, losing the buckets on a crash only resets the limits
+CREATE UNLOGGED TABLE rate_limit_buckets (
+  -- ip:<address>, key:<tenant>/<subject> or route:<method pattern>:<client>
+  bucket_key TEXT PRIMARY KEY,
+  -- tokens left after the last request, refilled on the next one
+  tokens DOUBLE PRECISION NOT NULL,
+  -- whether the last request got a token
+  allowed BOOLEAN NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
+-- migrate:down
+DROP TABLE IF EXISTS rate_limit_buckets;
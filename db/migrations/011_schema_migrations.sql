@@ -0,0 +1,12 @@
+-- migrate:up
+-- the migrations applied to the database, the readiness probe compares the latest one with db.SchemaVersion.
+-- The migrator creates the table before running the first migration and records every migration it runs with the
+-- sha256 of its file, the migrations recorded before it existed have no checksum until it runs
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT PRIMARY KEY,
+  name TEXT NOT NULL,
+  checksum TEXT,
+  applied_at TIMESTAMP NOT NULL DEFAULT now()
+);
+-- migrate:down
+-- the table is kept, the migrator records in it which migrations are applied
@@ -0,0 +1,45 @@
+-- migrate:up
+-- the outbox events and the webhooks belong to a tenant like the loans, rows created before belong to the default tenant
+ALTER TABLE outbox_events
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_subscriptions
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_deliveries
+ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+-- the events recorded since 008 take the tenant of their loan
+UPDATE outbox_events e
+SET tenant_id = l.tenant_id
+FROM loans l
+WHERE e.aggregate_type = 'LOAN'
+  AND l.id = e.aggregate_id
+  AND e.tenant_id <> l.tenant_id;
+-- the deliveries not sent yet of an event of another tenant than the subscription are dropped, they were never meant
+-- for that partner. The ones already sent are kept with their log, under the tenant of the subscription
+DELETE FROM webhook_delivery_attempts a USING webhook_deliveries d,
+  webhook_subscriptions s,
+  outbox_events e
+WHERE a.delivery_id = d.id
+  AND d.subscription_id = s.id
+  AND d.event_id = e.id
+  AND d.status <> 'DELIVERED'
+  AND e.tenant_id <> s.tenant_id;
+DELETE FROM webhook_deliveries d USING webhook_subscriptions s,
+  outbox_events e
+WHERE d.subscription_id = s.id
+  AND d.event_id = e.id
+  AND d.status <> 'DELIVERED'
+  AND e.tenant_id <> s.tenant_id;
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_tenant_id ON webhook_subscriptions (tenant_id, id)
+WHERE status = 'ACTIVE';
+DROP INDEX IF EXISTS idx_webhook_deliveries_dead;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_tenant_dead ON webhook_deliveries (tenant_id, id)
+WHERE status = 'DEAD';
+-- migrate:down
+-- the deliveries dropped by the up migration are not restored
+DROP INDEX IF EXISTS idx_webhook_deliveries_tenant_dead;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_dead ON webhook_deliveries (id)
+WHERE status = 'DEAD';
+DROP INDEX IF EXISTS idx_webhook_subscriptions_tenant_id;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,11 @@
+/*
+Package migrations embeds the SQL migrations of the database in the binary, see db.Migrator.
+Every file is named NNN_name.sql and holds the statements applying it after -- migrate:up and the ones
+reverting it after -- migrate:down, sqlc reads the up part only.
+*/
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,60 @@
+-- name: InsertAPIKey :one
+INSERT INTO api_keys (
+    name,
+    prefix,
+    key_hash,
+    scopes,
+    roles,
+    tenant_id,
+    expires_at,
+    rotated_from,
+    created_at
+  )
+VALUES (
+    @name::text,
+    @prefix::text,
+    @key_hash::text,
+    @scopes::text [],
+    @roles::text [],
+    @tenant_id::text,
+    sqlc.narg('expires_at')::timestamp,
+    sqlc.narg('rotated_from')::bigint,
+    @created_at::timestamp
+  )
+RETURNING *;
+-- name: GetAPIKeyByID :one
+SELECT *
+FROM api_keys
+WHERE id = $1
+  AND tenant_id = $2;
+-- name: GetAPIKeyByPrefix :one
+-- looked up before the caller and its tenant are known
+SELECT *
+FROM api_keys
+WHERE prefix = $1;
+-- name: ListAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE tenant_id = $1
+ORDER BY id;
+-- name: ExpireAPIKey :exec
+-- shortens the life of a rotated key, a key already expiring sooner keeps its expiry
+UPDATE api_keys
+SET expires_at = LEAST(
+    COALESCE(expires_at, @expires_at::timestamp),
+    @expires_at::timestamp
+  )
+WHERE id = @id::bigint
+  AND tenant_id = @tenant_id::text;
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = @revoked_at::timestamp
+WHERE id = @id::bigint
+  AND tenant_id = @tenant_id::text
+  AND revoked_at IS NULL
+RETURNING *;
+-- name: TouchAPIKey :exec
+-- recorded while authenticating, before the tenant is known
+UPDATE api_keys
+SET last_used_at = @last_used_at::timestamp
+WHERE id = @id::bigint;
@@ -0,0 +1,69 @@
+-- name: LockAuditChain :exec
+-- entries of a tenant are chained one after the other, held until the end of the transaction
+SELECT pg_advisory_xact_lock(hashtext('audit_log:' || @tenant_id::text));
+-- name: GetLastAuditHash :one
+SELECT hash
+FROM audit_log
+WHERE tenant_id = $1
+ORDER BY id DESC
+LIMIT 1;
+-- name: InsertAuditEntry :one
+INSERT INTO audit_log (
+    tenant_id,
+    occurred_at,
+    actor,
+    action,
+    entity_type,
+    entity_id,
+    before_state,
+    after_state,
+    request_id,
+    idempotency_key,
+    source_ip,
+    prev_hash,
+    hash
+  )
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING *;
+-- name: ListAuditEntries :many
+SELECT *
+FROM audit_log
+WHERE tenant_id = @tenant_id::text
+  AND (
+    sqlc.narg('cursor_id')::bigint IS NULL
+    OR id < sqlc.narg('cursor_id')::bigint
+  )
+  AND (
+    sqlc.narg('actor')::text IS NULL
+    OR actor = sqlc.narg('actor')::text
+  )
+  AND (
+    sqlc.narg('action')::text IS NULL
+    OR action = sqlc.narg('action')::text
+  )
+  AND (
+    sqlc.narg('entity_type')::text IS NULL
+    OR entity_type = sqlc.narg('entity_type')::text
+  )
+  AND (
+    sqlc.narg('entity_id')::text IS NULL
+    OR entity_id = sqlc.narg('entity_id')::text
+  )
+  AND (
+    sqlc.narg('occurred_from')::timestamp IS NULL
+    OR occurred_at >= sqlc.narg('occurred_from')::timestamp
+  )
+  AND (
+    sqlc.narg('occurred_to')::timestamp IS NULL
+    OR occurred_at < sqlc.narg('occurred_to')::timestamp
+  )
+ORDER BY id DESC
+LIMIT @limit_val::int;
+-- name: ListAuditChain :many
+-- the chain of a tenant in write order, to verify it
+SELECT *
+FROM audit_log
+WHERE tenant_id = @tenant_id::text
+  AND id > @after_id::bigint
+ORDER BY id
+LIMIT @limit_val::int;
@@ -0,0 +1,161 @@
+-- name: InsertMandate :one
+INSERT INTO mandates (
+    loan_id,
+    account_holder,
+    bank_code,
+    account_number,
+    reference,
+    status
+  )
+VALUES ($1, $2, $3, $4, $5, 'ACTIVE')
+RETURNING *;
+-- name: GetActiveMandateByLoanID :one
+SELECT m.*
+FROM mandates m
+  JOIN loans l ON l.id = m.loan_id
+WHERE m.loan_id = $1
+  AND l.tenant_id = $2
+  AND m.status = 'ACTIVE'
+LIMIT 1;
+-- name: RevokeMandate :one
+UPDATE mandates
+SET status = 'REVOKED',
+  revoked_at = now()
+WHERE loan_id = $1
+  AND status = 'ACTIVE'
+  AND loan_id IN (
+    SELECT l.id
+    FROM loans l
+    WHERE l.tenant_id = $2
+  )
+RETURNING *;
+-- name: LockCollectionRun :exec
+-- serialize collection runs across instances for the lifetime of the transaction
+SELECT pg_advisory_xact_lock(hashtext('collection_run'));
+-- name: ListCollectionCandidates :many
+SELECT s.loan_id,
+  s.sequence,
+  s.due_date,
+  (s.amount - s.paid_amount)::BIGINT AS amount,
+  m.id AS mandate_id,
+  (
+    SELECT COUNT(*)
+    FROM collection_items prev
+    WHERE prev.loan_id = s.loan_id
+      AND prev.schedule_sequence = s.sequence
+  )::INT AS previous_attempts
+FROM schedules s
+  JOIN mandates m ON m.loan_id = s.loan_id
+  AND m.status = 'ACTIVE'
+WHERE s.tenant_id = @tenant_id::text
+  AND s.due_date <= @collection_date::date
+  AND s.status <> 'PAID'
+  AND NOT EXISTS (
+    SELECT 1
+    FROM collection_items ci
+    WHERE ci.loan_id = s.loan_id
+      AND ci.schedule_sequence = s.sequence
+      AND (
+        ci.status IN ('SUBMITTED', 'SUCCEEDED', 'FAILED', 'UNPOSTED')
+        OR (
+          ci.status = 'RETRY_PENDING'
+          AND ci.next_attempt_on > @collection_date::date
+        )
+      )
+  )
+ORDER BY s.loan_id,
+  s.sequence;
+-- name: ConsumeDueRetries :exec
+-- only the retries ListCollectionCandidates picked up, a loan without an active mandate keeps its retry for later
+UPDATE collection_items
+SET status = 'RETRIED',
+  updated_at = now()
+WHERE status = 'RETRY_PENDING'
+  AND next_attempt_on <= @collection_date::date
+  AND EXISTS (
+    SELECT 1
+    FROM mandates m
+    WHERE m.loan_id = collection_items.loan_id
+      AND m.status = 'ACTIVE'
+  )
+  AND batch_id IN (
+    SELECT b.id
+    FROM collection_batches b
+    WHERE b.tenant_id = @tenant_id::text
+  );
+-- name: InsertCollectionBatch :one
+INSERT INTO collection_batches (
+    collection_date,
+    status,
+    item_count,
+    total_amount,
+    tenant_id
+  )
+VALUES ($1, 'CREATED', $2, $3, $4)
+RETURNING *;
+-- name: CreateCollectionItems :copyfrom
+INSERT INTO collection_items (
+    batch_id,
+    loan_id,
+    mandate_id,
+    schedule_sequence,
+    amount,
+    attempt,
+    status
+  )
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+-- name: GetCollectionBatchByID :one
+SELECT *
+FROM collection_batches
+WHERE id = $1
+  AND tenant_id = $2;
+-- name: LockCollectionBatchForUpdate :one
+-- serializes the imports of a batch until the end of the transaction
+SELECT *
+FROM collection_batches
+WHERE id = $1
+  AND tenant_id = $2 FOR
+UPDATE;
+-- name: UpdateCollectionBatchStatus :one
+UPDATE collection_batches
+SET status = $1,
+  updated_at = now()
+WHERE id = $2
+  AND tenant_id = $3
+RETURNING *;
+-- name: ListCollectionItemsByBatchID :many
+SELECT ci.id,
+  ci.batch_id,
+  ci.loan_id,
+  ci.mandate_id,
+  ci.schedule_sequence,
+  ci.amount,
+  ci.attempt,
+  ci.status,
+  ci.failure_code,
+  ci.next_attempt_on,
+  ci.payment_id,
+  m.account_holder,
+  m.bank_code,
+  m.account_number,
+  m.reference AS mandate_reference
+FROM collection_items ci
+  JOIN mandates m ON m.id = ci.mandate_id
+  JOIN collection_batches b ON b.id = ci.batch_id
+WHERE ci.batch_id = $1
+  AND b.tenant_id = $2
+ORDER BY ci.id;
+-- name: UpdateCollectionItemResult :one
+UPDATE collection_items ci
+SET status = $1,
+  failure_code = $2,
+  next_attempt_on = $3,
+  payment_id = $4,
+  updated_at = now()
+FROM collection_batches b
+WHERE ci.id = $5
+  AND ci.batch_id = $6
+  AND ci.status = 'SUBMITTED'
+  AND b.id = ci.batch_id
+  AND b.tenant_id = $7
+RETURNING ci.id;
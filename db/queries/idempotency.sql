@@ -0,0 +1,60 @@
+-- name: AcquireIdempotencyKey :one
+-- claims a new key, or takes over one that expired or whose owner stopped before completing it
+INSERT INTO idempotency_keys (
+    tenant_id,
+    key,
+    request_hash,
+    status,
+    locked_at,
+    created_at,
+    expires_at
+  )
+VALUES (
+    @tenant_id::text,
+    @key::text,
+    @request_hash::text,
+    'IN_PROGRESS',
+    @now::timestamp,
+    @now::timestamp,
+    @expires_at::timestamp
+  ) ON CONFLICT (tenant_id, key) DO
+UPDATE
+SET request_hash = EXCLUDED.request_hash,
+  status = 'IN_PROGRESS',
+  response_status = NULL,
+  response_content_type = NULL,
+  response_body = NULL,
+  locked_at = EXCLUDED.locked_at,
+  created_at = EXCLUDED.created_at,
+  completed_at = NULL,
+  expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= @now::timestamp
+  OR (
+    idempotency_keys.status = 'IN_PROGRESS'
+    AND idempotency_keys.locked_at <= @stale_before::timestamp
+  )
+RETURNING *;
+-- name: GetIdempotencyKey :one
+SELECT *
+FROM idempotency_keys
+WHERE tenant_id = $1
+  AND key = $2;
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = 'COMPLETED',
+  response_status = @response_status::int,
+  response_content_type = @response_content_type::text,
+  response_body = @response_body::bytea,
+  completed_at = now()
+WHERE tenant_id = @tenant_id::text
+  AND key = @key::text
+  AND status = 'IN_PROGRESS';
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE tenant_id = $1
+  AND key = $2
+  AND status = 'IN_PROGRESS';
+-- name: DeleteExpiredIdempotencyKeys :execrows
+-- purges the expired keys of every tenant
+DELETE FROM idempotency_keys
+WHERE expires_at <= @now::timestamp;
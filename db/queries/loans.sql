@@ -1,7 +1,8 @@
 -- name: GetLoanByID :one
 SELECT *
 FROM loans
-WHERE id = $1;
+WHERE id = $1
+  AND tenant_id = $2;
 -- name: InsertLoan :one
 INSERT INTO loans (
     principal_amount,
@@ -9,7 +10,30 @@ INSERT INTO loans (
     total_payable_amount,
     weekly_payment_amount,
     total_weeks,
-    start_date
+    start_date,
+    borrower_id,
+    tenant_id
   )
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING *;
\ No newline at end of file
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+-- name: LockLoanForUpdate :one
+-- row lock held until the end of the transaction, serializes concurrent writes on the same loan
+SELECT *
+FROM loans
+WHERE id = $1
+  AND tenant_id = $2 FOR
+UPDATE;
+-- name: ListLoans :many
+SELECT *
+FROM loans
+WHERE tenant_id = @tenant_id::text
+  AND (
+    sqlc.narg('cursor_id')::bigint IS NULL
+    OR id < sqlc.narg('cursor_id')::bigint
+  )
+  AND (
+    sqlc.narg('borrower_id')::text IS NULL
+    OR borrower_id = sqlc.narg('borrower_id')::text
+  )
+ORDER BY id DESC
+LIMIT @limit_val::int;
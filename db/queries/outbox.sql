@@ -0,0 +1,91 @@
+-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (
+    aggregate_type,
+    aggregate_id,
+    event_type,
+    payload,
+    tenant_id
+  )
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
+-- name: TryLockOutboxDispatch :one
+-- only one instance dispatches at a time, which keeps the per aggregate ordering simple
+SELECT pg_try_advisory_xact_lock(hashtext('outbox_dispatch'));
+-- name: ClaimOutboxEvents :many
+-- leases the due events until lease_until by moving their next attempt, they are published outside of the transaction
+-- and picked up again once the lease expired when their dispatcher died in between
+UPDATE outbox_events
+SET next_attempt_at = @lease_until::timestamp
+WHERE id IN (
+    SELECT e.id
+    FROM outbox_events e
+    WHERE e.status = 'PENDING'
+      AND e.next_attempt_at <= @now::timestamp
+      AND NOT EXISTS (
+        SELECT 1
+        FROM outbox_events blocked
+        WHERE blocked.aggregate_type = e.aggregate_type
+          AND blocked.aggregate_id = e.aggregate_id
+          AND blocked.status = 'PENDING'
+          AND blocked.id < e.id
+          AND blocked.next_attempt_at > @now::timestamp
+      )
+    ORDER BY e.id
+    LIMIT @limit_val::int FOR
+    UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+SET status = 'DISPATCHED',
+  attempts = attempts + 1,
+  last_error = NULL,
+  dispatched_at = now()
+WHERE id = $1
+  AND status = 'PENDING';
+-- name: MarkOutboxEventFailed :exec
+-- an event dispatched meanwhile by the dispatcher that took over an expired lease is left alone
+UPDATE outbox_events
+SET status = @status::text,
+  attempts = attempts + 1,
+  last_error = @last_error::text,
+  next_attempt_at = @next_attempt_at::timestamp
+WHERE id = @id::bigint
+  AND status = 'PENDING';
+-- name: ListNewlyDelinquentLoans :many
+-- loans of the tenant that crossed its delinquency threshold and have no LoanBecameDelinquent event since their last payment
+WITH loan_progress AS (
+  SELECT l.id,
+    l.total_weeks,
+    (
+      FLOOR(
+        DATE_PART('epoch', sqlc.arg(evaluated_at)::timestamp - l.created_at) / 604800
+      ) + 1
+    )::INT AS expected_week,
+    COALESCE(MAX(p.week_number), 0)::INT AS last_paid_week,
+    MAX(p.created_at) AS last_paid_at
+  FROM loans l
+    LEFT JOIN payments p ON p.loan_id = l.id
+  WHERE l.tenant_id = sqlc.arg(tenant_id)::text
+    AND l.created_at <= sqlc.arg(evaluated_at)::timestamp
+  GROUP BY l.id
+)
+SELECT lp.id,
+  lp.last_paid_week,
+  lp.expected_week
+FROM loan_progress lp
+WHERE lp.last_paid_week < lp.total_weeks
+  AND lp.expected_week - lp.last_paid_week >= sqlc.arg(gap_weeks)::int
+  AND NOT EXISTS (
+    SELECT 1
+    FROM outbox_events e
+    WHERE e.aggregate_type = 'LOAN'
+      AND e.aggregate_id = lp.id
+      AND e.event_type = 'LoanBecameDelinquent'
+      AND (
+        lp.last_paid_at IS NULL
+        OR e.created_at > lp.last_paid_at
+      )
+  )
+ORDER BY lp.id
+LIMIT sqlc.arg(limit_val)::int;
@@ -6,6 +6,7 @@ SELECT id,
   paid_at
 FROM payments
 WHERE loan_id = @loan_id::bigint
+  AND tenant_id = @tenant_id::text
   AND (
     (
       sqlc.narg('cursor_paid_at')::timestamptz IS NULL
@@ -24,22 +25,50 @@ LIMIT @limit_val::int;
 -- name: GetTotalPaidAmount :one
 SELECT COALESCE(SUM(amount), 0)::BIGINT AS total_paid
 FROM payments
-WHERE loan_id = $1;
+WHERE loan_id = $1
+  AND tenant_id = $2;
 -- name: InsertPayment :one
 INSERT INTO payments (
     loan_id,
     week_number,
     amount,
     idempotency_key,
-    paid_at
+    paid_at,
+    tenant_id
   )
-VALUES ($1, $2, $3, $4, $5)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 -- name: GetLastPaidWeek :one
 SELECT COALESCE(MAX(week_number), 0)::INT AS last_paid_week
 FROM payments
-WHERE loan_id = $1;
+WHERE loan_id = $1
+  AND tenant_id = $2;
 -- name: GetPaidWeeksCount :one
 SELECT COUNT(*)::INT
 FROM payments
-WHERE loan_id = $1;
\ No newline at end of file
+WHERE loan_id = $1
+  AND tenant_id = $2;
+-- name: ListPaymentsByLoanIDInPeriod :many
+SELECT id,
+  loan_id,
+  week_number,
+  amount,
+  paid_at
+FROM payments
+WHERE loan_id = @loan_id::bigint
+  AND tenant_id = @tenant_id::text
+  AND paid_at >= @period_start::timestamp
+  AND paid_at < @period_end::timestamp
+ORDER BY paid_at ASC,
+  id ASC;
+-- name: GetTotalPaidAmountBefore :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT AS total_paid
+FROM payments
+WHERE loan_id = @loan_id::bigint
+  AND tenant_id = @tenant_id::text
+  AND paid_at < @before::timestamp;
+-- name: GetPaymentByIdempotencyKey :one
+SELECT *
+FROM payments
+WHERE tenant_id = @tenant_id::text
+  AND idempotency_key = @idempotency_key::text;
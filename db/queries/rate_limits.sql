@@ -0,0 +1,36 @@
+-- name: TakeRateLimitToken :one
+-- refills the bucket for the time elapsed since its last request, capped at the burst, and takes a token when a whole one is left
+-- sqlc.arg rather than @, sqlc fails to rewrite the repeated @ parameters of this statement
+INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, allowed, updated_at)
+VALUES (
+    sqlc.arg(bucket_key)::text,
+    sqlc.arg(burst)::float8 - 1,
+    true,
+    sqlc.arg(now)::timestamp
+  ) ON CONFLICT (bucket_key) DO
+UPDATE
+SET tokens = CASE
+    WHEN LEAST(
+      sqlc.arg(burst)::float8,
+      b.tokens + GREATEST(date_part('epoch', sqlc.arg(now)::timestamp - b.updated_at), 0) * sqlc.arg(rate)::float8
+    ) >= 1 THEN LEAST(
+      sqlc.arg(burst)::float8,
+      b.tokens + GREATEST(date_part('epoch', sqlc.arg(now)::timestamp - b.updated_at), 0) * sqlc.arg(rate)::float8
+    ) - 1
+    ELSE LEAST(
+      sqlc.arg(burst)::float8,
+      b.tokens + GREATEST(date_part('epoch', sqlc.arg(now)::timestamp - b.updated_at), 0) * sqlc.arg(rate)::float8
+    )
+  END,
+  allowed = LEAST(
+    sqlc.arg(burst)::float8,
+    b.tokens + GREATEST(date_part('epoch', sqlc.arg(now)::timestamp - b.updated_at), 0) * sqlc.arg(rate)::float8
+  ) >= 1,
+  -- the clocks of the instances may disagree, a bucket never goes back in time
+  updated_at = GREATEST(b.updated_at, sqlc.arg(now)::timestamp)
+RETURNING tokens,
+  allowed;
+-- name: DeleteIdleRateLimitBuckets :execrows
+-- buckets idle long enough to be full again are the same as missing ones
+DELETE FROM rate_limit_buckets
+WHERE updated_at <= @idle_before::timestamp;
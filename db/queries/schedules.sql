@@ -4,16 +4,18 @@ INSERT INTO schedules (
     sequence,
     due_date,
     amount,
-    status
+    status,
+    tenant_id
   )
-VALUES ($1, $2, $3, $4, $5);
+VALUES ($1, $2, $3, $4, $5, $6);
 -- name: ListSchedulesByLoanIDWithCursor :many
 SELECT *
 FROM schedules
 WHERE loan_id = $1
-  AND sequence > $2
+  AND tenant_id = $2
+  AND sequence > $3
 ORDER BY sequence
-LIMIT $3;
+LIMIT $4;
 -- name: UpdateSchedulePayment :one
 UPDATE schedules
 SET paid_amount = paid_amount + $1,
@@ -23,11 +25,32 @@ SET paid_amount = paid_amount + $1,
   END,
   updated_at = now()
 WHERE loan_id = $2
-  AND sequence = $3
+  AND tenant_id = $3
+  AND sequence = $4
 RETURNING id;
 -- name: GetScheduleBySequence :one
 SELECT *
 FROM schedules
 WHERE loan_id = $1
-  AND sequence = $2
-LIMIT 1;
\ No newline at end of file
+  AND tenant_id = $2
+  AND sequence = $3
+LIMIT 1;
+-- name: ListUpcomingSchedules :many
+-- the schedules due on or after due_from, with what was paid on them before it
+SELECT s.id,
+  s.loan_id,
+  s.sequence,
+  s.due_date,
+  s.amount,
+  COALESCE(SUM(p.amount), 0)::BIGINT AS paid_amount
+FROM schedules s
+  LEFT JOIN payments p ON p.loan_id = s.loan_id
+  AND p.week_number = s.sequence
+  AND p.paid_at < @due_from::timestamp
+WHERE s.loan_id = @loan_id::bigint
+  AND s.tenant_id = @tenant_id::text
+  AND s.due_date >= @due_from::date
+GROUP BY s.id
+HAVING COALESCE(SUM(p.amount), 0) < s.amount
+ORDER BY s.sequence
+LIMIT @max_rows;
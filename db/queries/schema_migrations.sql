@@ -0,0 +1,30 @@
+-- name: GetSchemaVersion :one
+-- the latest migration applied, 0 when none is recorded
+SELECT COALESCE(MAX(version), 0)::int AS version
+FROM schema_migrations;
+-- name: ListSchemaMigrations :many
+SELECT version,
+  name,
+  checksum,
+  applied_at
+FROM schema_migrations
+ORDER BY version;
+-- name: InsertSchemaMigration :exec
+INSERT INTO schema_migrations (version, name, checksum)
+VALUES (@version, @name, @checksum);
+-- name: DeleteSchemaMigration :exec
+DELETE FROM schema_migrations
+WHERE version = @version;
+-- name: SetSchemaMigrationChecksum :exec
+-- adopts a migration recorded before the migrator existed, for migrate baseline
+UPDATE schema_migrations
+SET checksum = @checksum
+WHERE version = @version
+  AND checksum IS NULL;
+-- name: TryLockSchemaMigrations :one
+-- session lock, held by the migrator across the transactions of the migrations
+SELECT pg_try_advisory_lock(hashtext('schema_migrations'));
+-- name: LockSchemaMigrations :exec
+SELECT pg_advisory_lock(hashtext('schema_migrations'));
+-- name: UnlockSchemaMigrations :exec
+SELECT pg_advisory_unlock(hashtext('schema_migrations'));
@@ -0,0 +1,148 @@
+-- name: InsertWebhookSubscription :one
+INSERT INTO webhook_subscriptions (url, event_types, secret, status, tenant_id)
+VALUES ($1, $2, $3, 'ACTIVE', $4)
+RETURNING *;
+-- name: GetWebhookSubscriptionByID :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'ACTIVE';
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE tenant_id = $1
+  AND status = 'ACTIVE'
+ORDER BY id;
+-- name: DeleteWebhookSubscription :one
+UPDATE webhook_subscriptions
+SET status = 'DELETED',
+  deleted_at = now()
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'ACTIVE'
+RETURNING *;
+-- name: EnqueueWebhookDeliveries :execrows
+-- fan an outbox event out to every active subscription of its tenant listening to its type
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, tenant_id)
+SELECT s.id,
+  @event_id::bigint,
+  @event_type::text,
+  s.tenant_id
+FROM webhook_subscriptions s
+WHERE s.tenant_id = @tenant_id::text
+  AND s.status = 'ACTIVE'
+  AND @event_type::text = ANY(s.event_types) ON CONFLICT (subscription_id, event_id) DO NOTHING;
+-- name: ClaimWebhookDeliveries :many
+-- leases the due deliveries until lease_until like ClaimOutboxEvents, several dispatchers can claim side by side
+WITH claimed AS (
+  UPDATE webhook_deliveries
+  SET next_attempt_at = @lease_until::timestamp
+  WHERE id IN (
+      SELECT d.id
+      FROM webhook_deliveries d
+        JOIN webhook_subscriptions s ON s.id = d.subscription_id
+        AND s.status = 'ACTIVE'
+      WHERE d.status = 'PENDING'
+        AND d.next_attempt_at <= @now::timestamp
+      ORDER BY d.next_attempt_at,
+        d.id
+      LIMIT @limit_val::int FOR
+      UPDATE OF d SKIP LOCKED
+    )
+  RETURNING id,
+    subscription_id,
+    event_id,
+    attempts
+)
+SELECT c.id,
+  c.subscription_id,
+  c.attempts,
+  s.url,
+  s.secret,
+  e.id AS event_id,
+  e.tenant_id,
+  e.aggregate_type,
+  e.aggregate_id,
+  e.event_type,
+  e.payload,
+  e.created_at AS occurred_at
+FROM claimed c
+  JOIN webhook_subscriptions s ON s.id = c.subscription_id
+  JOIN outbox_events e ON e.id = c.event_id
+ORDER BY c.id;
+-- name: InsertWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (
+    delivery_id,
+    attempt,
+    status_code,
+    error,
+    duration_ms
+  )
+VALUES ($1, $2, $3, $4, $5);
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'DELIVERED',
+  attempts = attempts + 1,
+  last_status_code = @status_code::int,
+  last_error = NULL,
+  delivered_at = now()
+WHERE id = @id::bigint
+  AND status = 'PENDING';
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = @status::text,
+  attempts = attempts + 1,
+  last_status_code = sqlc.narg('status_code')::int,
+  last_error = @last_error::text,
+  next_attempt_at = @next_attempt_at::timestamp
+WHERE id = @id::bigint
+  AND status = 'PENDING';
+-- name: GetWebhookDeliveryByID :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1
+  AND tenant_id = $2;
+-- name: ListWebhookDeliveriesBySubscriptionID :many
+-- newest first, optionally filtered by status
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = @subscription_id::bigint
+  AND tenant_id = @tenant_id::text
+  AND (
+    sqlc.narg('status')::text IS NULL
+    OR status = sqlc.narg('status')::text
+  )
+  AND (
+    sqlc.narg('cursor_id')::bigint IS NULL
+    OR id < sqlc.narg('cursor_id')::bigint
+  )
+ORDER BY id DESC
+LIMIT @limit_val::int;
+-- name: ListDeadWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE tenant_id = @tenant_id::text
+  AND status = 'DEAD'
+  AND (
+    sqlc.narg('cursor_id')::bigint IS NULL
+    OR id < sqlc.narg('cursor_id')::bigint
+  )
+ORDER BY id DESC
+LIMIT @limit_val::int;
+-- name: ListWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY id;
+-- name: RedeliverWebhookDelivery :one
+-- a dead delivery gets a fresh retry budget, its attempt log is kept
+UPDATE webhook_deliveries
+SET status = 'PENDING',
+  attempts = 0,
+  last_error = NULL,
+  next_attempt_at = now()
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'DEAD'
+RETURNING *;
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -15,13 +17,96 @@ type Config struct {
 	PagingLimitDefault int
 	PagingLimitMax     int
 	ServerPort         string
+	GRPCEnabled        bool
+	GRPCPort           string
 	MaxConns           int
 	MinConns           int
 	MaxConnIdleTime    int
 	MaxConnLifeTime    int
 	HealthCheckPeriod  int
+	TxMaxAttempts      int
+	TxRetryBackoffMs   int
+	SlowQueryMs        int // queries taking longer are logged as slow_query, 0 logs none
+	MigrateOnStartup   bool
 	AppEnv             string
 	LogLevel           *slog.LevelVar
+
+	// direct debit collection
+	CollectionRunnerEnabled     bool
+	CollectionRunInterval       int
+	CollectionRetryBackoffDays  []int
+	CollectionNonRetryableCodes []string
+
+	// outbox event dispatching
+	OutboxDispatcherEnabled bool
+	OutboxPollInterval      int
+	OutboxBatchSize         int
+	OutboxMaxAttempts       int
+	OutboxPublishURL        string
+
+	DelinquencyMonitorEnabled bool
+	DelinquencyCheckInterval  int
+
+	WebhookDispatcherEnabled bool
+	WebhookPollInterval      int
+	WebhookBatchSize         int
+	WebhookMaxAttempts       int
+	WebhookTimeout           int
+
+	EventBusHistorySize int
+
+	IdempotencyKeyTTL        int
+	IdempotencyLockTimeout   int
+	IdempotencyPurgeInterval int
+
+	// authentication, every API route requires an API key or a JWT when enabled
+	AuthEnabled             bool
+	AuthJWTHMACSecret       string
+	AuthJWKSFile            string
+	AuthJWTIssuer           string
+	AuthJWTAudience         string
+	AuthAPIKeyRotationGrace int
+	AuthBootstrapAPIKey     string
+	RBACPolicyFile          string
+
+	// multi-tenancy, the paging limits and DELINQUENCY_GAP_WEEKS are the defaults of the tenants file
+	TenantsFile         string
+	DelinquencyGapWeeks int
+	RowLevelSecurity    bool
+
+	// the reverse proxies allowed to give the client address in X-Forwarded-For and X-Real-IP, none by default
+	TrustedProxies []netip.Prefix
+
+	// rate limiting, token buckets per client address, per API key and per route, a zero rate disables a bucket
+	RateLimitEnabled        bool
+	RateLimitStore          string // memory for a single instance, postgres to share the limits between instances
+	RateLimitDBMaxConns     int    // connections of the postgres store, a pool apart from the one of the requests
+	RateLimitStoreTimeoutMs int    // a slower postgres store is replaced by the buckets of the instance
+	RateLimitIPRate         float64
+	RateLimitIPBurst        int
+	RateLimitKeyRate        float64
+	RateLimitKeyBurst       int
+	RateLimitRoutes         []string // "METHOD pattern=rate:burst"
+	RateLimitPurgeInterval  int
+
+	// load shedding, a concurrency limit lowered when the wait for a pool connection exceeds the target
+	LoadShedEnabled          bool
+	LoadShedInitialLimit     int
+	LoadShedMinLimit         int
+	LoadShedMaxLimit         int
+	LoadShedTargetAcquireMs  int
+	LoadShedAdjustIntervalMs int
+
+	// tracing, the spans are exported with OTLP over gRPC or written as JSON to stdout or a file
+	TracingExporter     string // none, otlp, stdout or file
+	TracingOTLPEndpoint string
+	TracingOTLPInsecure bool
+	TracingFile         string
+	TracingSampleRatio  float64
+
+	// readiness probe and shutdown
+	ReadyMaxPoolUsage  float64 // share of the pool connections in use from which the instance is not ready, 0 disables it
+	ShutdownDrainDelay int     // seconds between turning not ready and draining the servers
 }
 
 func Load() (*Config, error) {
@@ -34,17 +119,95 @@ func Load() (*Config, error) {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	trustedProxies, err := parsePrefixes(getEnvList("TRUSTED_PROXIES", nil))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+
 	return &Config{
 		DatabaseURL:        getEnv("DATABASE_URL", "postgres://localhost:5432/billing"),
 		PagingLimitDefault: getEnvInt("PAGING_LIMIT_DEFAULT", 10),
 		PagingLimitMax:     getEnvInt("PAGING_LIMIT_MAX", 100),
 		ServerPort:         getEnv("SERVER_PORT", "8081"),
+		GRPCEnabled:        getEnvBool("GRPC_ENABLED", true),
+		GRPCPort:           getEnv("GRPC_PORT", "9091"),
 		MaxConns:           getEnvInt("DB_MAX_CONNS", 20),
 		MinConns:           getEnvInt("DB_MIN_CONNS", 5),
 		MaxConnIdleTime:    getEnvInt("DB_MAX_IDLE_TIME", 300),
 		MaxConnLifeTime:    getEnvInt("DB_MAX_LIFE_TIME", 1800),
 		HealthCheckPeriod:  getEnvInt("DB_HEALTH_CHECK_PERIOD", 60),
+		TxMaxAttempts:      getEnvInt("DB_TX_MAX_ATTEMPTS", 3),
+		TxRetryBackoffMs:   getEnvInt("DB_TX_RETRY_BACKOFF_MS", 20),
+		SlowQueryMs:        getEnvInt("DB_SLOW_QUERY_MS", 200),
+		MigrateOnStartup:   getEnvBool("DB_MIGRATE_ON_STARTUP", false),
 		AppEnv:             strings.ToLower(getEnv("APP_ENV", "development")),
+
+		CollectionRunnerEnabled:     getEnvBool("COLLECTION_RUNNER_ENABLED", false),
+		CollectionRunInterval:       getEnvInt("COLLECTION_RUN_INTERVAL", 3600),
+		CollectionRetryBackoffDays:  getEnvIntList("COLLECTION_RETRY_BACKOFF_DAYS", []int{3, 7}),
+		CollectionNonRetryableCodes: getEnvList("COLLECTION_NON_RETRYABLE_CODES", []string{"AC04", "MD01", "MD07"}),
+
+		OutboxDispatcherEnabled:   getEnvBool("OUTBOX_DISPATCHER_ENABLED", true),
+		OutboxPollInterval:        getEnvInt("OUTBOX_POLL_INTERVAL", 2),
+		OutboxBatchSize:           getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxMaxAttempts:         getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxPublishURL:          getEnv("OUTBOX_PUBLISH_URL", ""),
+		DelinquencyMonitorEnabled: getEnvBool("DELINQUENCY_MONITOR_ENABLED", true),
+		DelinquencyCheckInterval:  getEnvInt("DELINQUENCY_CHECK_INTERVAL", 3600),
+
+		WebhookDispatcherEnabled: getEnvBool("WEBHOOK_DISPATCHER_ENABLED", true),
+		WebhookPollInterval:      getEnvInt("WEBHOOK_POLL_INTERVAL", 5),
+		WebhookBatchSize:         getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		WebhookMaxAttempts:       getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeout:           getEnvInt("WEBHOOK_TIMEOUT", 10),
+
+		EventBusHistorySize: getEnvInt("EVENT_BUS_HISTORY_SIZE", 1000),
+
+		IdempotencyKeyTTL:        getEnvInt("IDEMPOTENCY_KEY_TTL", 86400),
+		IdempotencyLockTimeout:   getEnvInt("IDEMPOTENCY_LOCK_TIMEOUT", 60),
+		IdempotencyPurgeInterval: getEnvInt("IDEMPOTENCY_PURGE_INTERVAL", 3600),
+
+		AuthEnabled:             getEnvBool("AUTH_ENABLED", true),
+		AuthJWTHMACSecret:       getEnv("AUTH_JWT_HMAC_SECRET", ""),
+		AuthJWKSFile:            getEnv("AUTH_JWKS_FILE", ""),
+		AuthJWTIssuer:           getEnv("AUTH_JWT_ISSUER", ""),
+		AuthJWTAudience:         getEnv("AUTH_JWT_AUDIENCE", ""),
+		AuthAPIKeyRotationGrace: getEnvInt("AUTH_API_KEY_ROTATION_GRACE", 86400),
+		AuthBootstrapAPIKey:     getEnv("AUTH_BOOTSTRAP_API_KEY", ""),
+		RBACPolicyFile:          getEnv("RBAC_POLICY_FILE", ""),
+
+		TenantsFile:         getEnv("TENANTS_FILE", ""),
+		DelinquencyGapWeeks: getEnvInt("DELINQUENCY_GAP_WEEKS", 2),
+		RowLevelSecurity:    getEnvBool("DB_ROW_LEVEL_SECURITY", false),
+
+		TrustedProxies: trustedProxies,
+
+		RateLimitEnabled:        getEnvBool("RATE_LIMIT_ENABLED", true),
+		RateLimitStore:          strings.ToLower(getEnv("RATE_LIMIT_STORE", "memory")),
+		RateLimitDBMaxConns:     getEnvInt("RATE_LIMIT_DB_MAX_CONNS", 4),
+		RateLimitStoreTimeoutMs: getEnvInt("RATE_LIMIT_STORE_TIMEOUT_MS", 50),
+		RateLimitIPRate:         getEnvFloat("RATE_LIMIT_IP_RATE", 50),
+		RateLimitIPBurst:        getEnvInt("RATE_LIMIT_IP_BURST", 100),
+		RateLimitKeyRate:        getEnvFloat("RATE_LIMIT_KEY_RATE", 20),
+		RateLimitKeyBurst:       getEnvInt("RATE_LIMIT_KEY_BURST", 40),
+		RateLimitRoutes:         getEnvList("RATE_LIMIT_ROUTES", []string{"POST /loan/{loanID}/payment=5:10"}),
+		RateLimitPurgeInterval:  getEnvInt("RATE_LIMIT_PURGE_INTERVAL", 600),
+
+		LoadShedEnabled:          getEnvBool("LOAD_SHED_ENABLED", true),
+		LoadShedInitialLimit:     getEnvInt("LOAD_SHED_INITIAL_LIMIT", 100),
+		LoadShedMinLimit:         getEnvInt("LOAD_SHED_MIN_LIMIT", 10),
+		LoadShedMaxLimit:         getEnvInt("LOAD_SHED_MAX_LIMIT", 1000),
+		LoadShedTargetAcquireMs:  getEnvInt("LOAD_SHED_TARGET_ACQUIRE_MS", 50),
+		LoadShedAdjustIntervalMs: getEnvInt("LOAD_SHED_ADJUST_INTERVAL_MS", 1000),
+
+		TracingExporter:     strings.ToLower(getEnv("TRACING_EXPORTER", "none")),
+		TracingOTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4317"),
+		TracingOTLPInsecure: getEnvBool("TRACING_OTLP_INSECURE", true),
+		TracingFile:         getEnv("TRACING_FILE", "traces.json"),
+		TracingSampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+
+		ReadyMaxPoolUsage:  getEnvFloat("READY_MAX_POOL_USAGE", 1),
+		ShutdownDrainDelay: getEnvInt("SHUTDOWN_DRAIN_DELAY", 5),
 	}, nil
 }
 
@@ -67,3 +230,75 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return v
 }
+
+func getEnvFloat(key string, fallback float64) float64 {
+	s, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	s, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvList helper to read comma separated values, empty entries are dropped
+func getEnvList(key string, fallback []string) []string {
+	s, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvIntList(key string, fallback []int) []int {
+	if _, ok := os.LookupEnv(key); !ok {
+		return fallback
+	}
+	var values []int
+	for _, v := range getEnvList(key, nil) {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fallback
+		}
+		values = append(values, n)
+	}
+	return values
+}
+
+// parsePrefixes reads CIDRs, an address alone stands for itself
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if addr, err := netip.ParseAddr(v); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", v)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
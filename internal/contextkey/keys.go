@@ -6,4 +6,7 @@ type Key string
 const (
 	IdempotencyKey Key = "idempotency_key"
 	RequestIDKey   Key = "request_id"
+	PrincipalKey   Key = "principal"
+	TenantKey      Key = "tenant"
+	RequestMetaKey Key = "request_meta"
 )
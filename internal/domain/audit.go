@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"billing-api/internal/contextkey"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// audited actions, named <entity type>.<verb>
+const (
+	AuditActionLoanCreate                = "loan.create"
+	AuditActionPaymentCreate             = "payment.create"
+	AuditActionMandateCreate             = "mandate.create"
+	AuditActionMandateRevoke             = "mandate.revoke"
+	AuditActionCollectionBatchCreate     = "collection_batch.create"
+	AuditActionCollectionBatchExport     = "collection_batch.export"
+	AuditActionCollectionBatchReconcile  = "collection_batch.reconcile"
+	AuditActionWebhookSubscriptionCreate = "webhook_subscription.create"
+	AuditActionWebhookSubscriptionDelete = "webhook_subscription.delete"
+	AuditActionWebhookDeliveryRedeliver  = "webhook_delivery.redeliver"
+	AuditActionAPIKeyCreate              = "api_key.create"
+	AuditActionAPIKeyRotate              = "api_key.rotate"
+	AuditActionAPIKeyRevoke              = "api_key.revoke"
+	AuditActionLogLevelChange            = "log_level.change"
+)
+
+const (
+	AuditEntityLoan                = "loan"
+	AuditEntityPayment             = "payment"
+	AuditEntityMandate             = "mandate"
+	AuditEntityCollectionBatch     = "collection_batch"
+	AuditEntityWebhookSubscription = "webhook_subscription"
+	AuditEntityWebhookDelivery     = "webhook_delivery"
+	AuditEntityAPIKey              = "api_key"
+	AuditEntityLogLevel            = "log_level"
+)
+
+// actors of the changes not made by an authenticated caller
+const (
+	AuditActorAnonymous = "anonymous" // a request while authentication is disabled
+	AuditActorSystem    = "system"    // a background job
+)
+
+/*
+AuditEntry records a state-changing operation: who did what to which entity, and the entity before and after.
+
+The entries of a tenant form a hash chain, each one hashing the previous hash together with its own content,
+so an entry changed or removed behind the API breaks the chain from that entry on.
+*/
+type AuditEntry struct {
+	ID             int64
+	TenantID       string
+	OccurredAt     time.Time
+	Actor          string
+	Action         string
+	EntityType     string
+	EntityID       string
+	Before         json.RawMessage // nil when the entity did not exist before
+	After          json.RawMessage // nil when the entity no longer exists
+	RequestID      string
+	IdempotencyKey string
+	SourceIP       string
+	PrevHash       string // empty for the first entry of the tenant
+	Hash           string
+}
+
+// ComputeHash returns the hex sha256 of the entry chained to PrevHash, every field is length-prefixed so none can bleed into the next
+func (e *AuditEntry) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash,
+		e.TenantID,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		string(e.Before),
+		string(e.After),
+		e.RequestID,
+		e.IdempotencyKey,
+		e.SourceIP,
+	} {
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CreateAuditEntryCommand is an entry before it is chained, the repository adds the tenant, PrevHash and Hash
+type CreateAuditEntryCommand struct {
+	OccurredAt     time.Time
+	Actor          string
+	Action         string
+	EntityType     string
+	EntityID       string
+	Before         json.RawMessage
+	After          json.RawMessage
+	RequestID      string
+	IdempotencyKey string
+	SourceIP       string
+}
+
+/*
+NewAuditCommand describes a change made on behalf of the caller of ctx, with the request it came with.
+before and after are snapshots of the entity, marshalled to JSON, nil when it did not exist.
+*/
+func NewAuditCommand(ctx context.Context, action, entityType, entityID string, before, after any) (CreateAuditEntryCommand, error) {
+	meta := RequestMetaFromContext(ctx)
+	cmd := CreateAuditEntryCommand{
+		// Postgres keeps microseconds, the hash must be the same once read back
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:      AuditActorSystem,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  meta.RequestID,
+		SourceIP:   meta.SourceIP,
+	}
+	switch p := PrincipalFromContext(ctx); {
+	case p != nil:
+		cmd.Actor = p.Subject
+	case meta.RequestID != "":
+		cmd.Actor = AuditActorAnonymous
+	}
+	cmd.IdempotencyKey, _ = ctx.Value(contextkey.IdempotencyKey).(string)
+
+	var err error
+	if cmd.Before, err = marshalAuditSnapshot(before); err != nil {
+		return cmd, err
+	}
+	if cmd.After, err = marshalAuditSnapshot(after); err != nil {
+		return cmd, err
+	}
+	return cmd, nil
+}
+
+func marshalAuditSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// ListAuditEntriesQuery filters the entries of the tenant, newest first, nil filters match everything
+type ListAuditEntriesQuery struct {
+	Actor      *string
+	Action     *string
+	EntityType *string
+	EntityID   *string
+	From       *time.Time // inclusive
+	To         *time.Time // exclusive
+	CursorID   *int64
+	Limit      int32
+}
+
+// AuditChainVerification is the outcome of walking the hash chain of a tenant
+type AuditChainVerification struct {
+	Valid      bool
+	Entries    int    // entries checked, up to the first broken one
+	BrokenAtID *int64 // first entry whose hash or link does not match
+	Reason     string
+}
+
+// ContextWithIdempotencyKey records the key a change is made under, when it does not come with the request
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextkey.IdempotencyKey, key)
+}
+
+// RequestMeta describes the request a change came with, recorded by the audit log
+type RequestMeta struct {
+	RequestID string
+	SourceIP  string
+}
+
+func ContextWithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, contextkey.RequestMetaKey, meta)
+}
+
+// RequestMetaFromContext returns the zero RequestMeta outside of a request, in the background jobs
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(contextkey.RequestMetaKey).(RequestMeta)
+	return meta
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"billing-api/internal/contextkey"
+	"context"
+	"slices"
+	"time"
+)
+
+// scopes granted to a caller, ScopeAdmin includes the other two
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+/*
+permissions granted by the roles of the RBAC policy (see service.Policy), every API route requires one of them.
+PermissionLoanReadOwn only grants the loans whose borrower is the caller itself.
+*/
+const (
+	PermissionLoanRead         = "loan:read"
+	PermissionLoanReadOwn      = "loan:read:own"
+	PermissionLoanCreate       = "loan:create"
+	PermissionLoanWriteOff     = "loan:write_off"
+	PermissionPaymentCreate    = "payment:create"
+	PermissionPaymentReverse   = "payment:reverse"
+	PermissionMandateManage    = "mandate:manage"
+	PermissionCollectionManage = "collection:manage"
+	PermissionWebhookManage    = "webhook:manage"
+	PermissionLogLevelChange   = "admin:log_level"
+	PermissionConfigManage     = "admin:config" // API keys and the other runtime configuration
+	PermissionAuditRead        = "audit:read"
+
+	// PermissionAll grants every permission, including the ones added later
+	PermissionAll = "*"
+)
+
+var Permissions = []string{
+	PermissionLoanRead,
+	PermissionLoanReadOwn,
+	PermissionLoanCreate,
+	PermissionLoanWriteOff,
+	PermissionPaymentCreate,
+	PermissionPaymentReverse,
+	PermissionMandateManage,
+	PermissionCollectionManage,
+	PermissionWebhookManage,
+	PermissionLogLevelChange,
+	PermissionConfigManage,
+	PermissionAuditRead,
+}
+
+type PrincipalKind string
+
+const (
+	PrincipalKindAPIKey PrincipalKind = "api_key"
+	PrincipalKindJWT    PrincipalKind = "jwt"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Kind     PrincipalKind
+	Subject  string // "api_key:<prefix>" for an API key, the sub claim of a JWT
+	TenantID string // lending partner the caller acts for, every query of the request is scoped to it
+	Scopes   []string
+	Roles    []string
+	// Permissions are granted by the roles, resolved once authenticated
+	Permissions []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+func (p *Principal) Can(permission string) bool {
+	return slices.Contains(p.Permissions, permission) || slices.Contains(p.Permissions, PermissionAll)
+}
+
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextkey.PrincipalKey, p)
+}
+
+// PrincipalFromContext returns the caller set by the auth middleware, nil when auth is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextkey.PrincipalKey).(*Principal)
+	return p
+}
+
+// APIKey is a long-lived credential of a service, only the hash of the key is stored
+type APIKey struct {
+	ID          int64
+	Name        string
+	Prefix      string // public part of the key, identifies it in listings and logs
+	Hash        string
+	TenantID    string
+	Scopes      []string
+	Roles       []string
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+	RotatedFrom *int64
+	CreatedAt   time.Time
+}
+
+// Active tells whether the key can still authenticate at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
+
+type CreateAPIKeyCommand struct {
+	Name        string
+	Prefix      string
+	Hash        string
+	TenantID    string
+	Scopes      []string
+	Roles       []string
+	ExpiresAt   *time.Time
+	RotatedFrom *int64
+	CreatedAt   time.Time
+}
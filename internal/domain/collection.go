@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	MandateStatusActive  = "ACTIVE"
+	MandateStatusRevoked = "REVOKED"
+
+	CollectionBatchStatusCreated    = "CREATED"
+	CollectionBatchStatusExported   = "EXPORTED"
+	CollectionBatchStatusReconciled = "RECONCILED"
+
+	CollectionItemStatusSubmitted    = "SUBMITTED"
+	CollectionItemStatusSucceeded    = "SUCCEEDED"
+	CollectionItemStatusRetryPending = "RETRY_PENDING"
+	CollectionItemStatusRetried      = "RETRIED"
+	CollectionItemStatusFailed       = "FAILED"
+	CollectionItemStatusUnposted     = "UNPOSTED" // debited by the bank, the payment could not be posted, left for manual review
+)
+
+// Mandate is the borrower's authorization to debit their bank account for a loan
+type Mandate struct {
+	ID            int64
+	LoanID        int64
+	AccountHolder string
+	BankCode      string
+	AccountNumber string
+	Reference     string
+	Status        string
+	CreatedAt     time.Time
+	RevokedAt     *time.Time
+}
+
+type CreateMandateCommand struct {
+	LoanID        int64
+	AccountHolder string
+	BankCode      string
+	AccountNumber string
+	Reference     string
+}
+
+// CollectionCandidate is an unpaid, due schedule that has an active mandate
+type CollectionCandidate struct {
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int
+	DueDate          time.Time
+	Amount           int64
+	PreviousAttempts int
+}
+
+type CollectionBatch struct {
+	ID             int64
+	CollectionDate time.Time
+	Status         string
+	ItemCount      int
+	TotalAmount    int64
+	CreatedAt      time.Time
+}
+
+type CreateCollectionBatchCommand struct {
+	CollectionDate time.Time
+	ItemCount      int32
+	TotalAmount    int64
+}
+
+// CollectionItem is a single debit instruction within a batch,
+// carrying the mandate details needed to build the bank file
+type CollectionItem struct {
+	ID               int64
+	BatchID          int64
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int
+	Amount           int64
+	Attempt          int
+	Status           string
+	FailureCode      string
+	NextAttemptOn    *time.Time
+	PaymentID        *int64
+	AccountHolder    string
+	BankCode         string
+	AccountNumber    string
+	MandateReference string
+}
+
+type CreateCollectionItemCommand struct {
+	BatchID          int64
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int32
+	Amount           int64
+	Attempt          int32
+}
+
+type UpdateCollectionItemResultCommand struct {
+	ItemID        int64
+	BatchID       int64
+	Status        string
+	FailureCode   string
+	NextAttemptOn *time.Time
+	PaymentID     *int64
+}
+
+// CollectionResult is one line of the bank's result file
+type CollectionResult struct {
+	ItemID     int64
+	Succeeded  bool
+	ReasonCode string
+}
@@ -8,7 +8,81 @@ var (
 	ErrInvalidLoanTerms        = errors.New("Invalid loan terms")
 	ErrInvalidPayment          = errors.New("Invalid payment")
 	ErrLoanAlreadyClosed       = errors.New("Loan already fully paid")
+	ErrInstallmentAlreadyPaid  = errors.New("Installment already paid")
+	ErrInstallmentOutOfOrder   = errors.New("An earlier installment is still unpaid")
 	ErrDuplicatePayment        = errors.New("Duplicate payment for current week")
+	ErrConcurrentPayment       = errors.New("Another payment for the same week was processed concurrently")
+	ErrPaymentNotFound         = errors.New("Payment not found")
 	ErrDelinquencyCheck        = errors.New("Failed to compute loan delinquency")
 	ErrScheduleNotFound        = errors.New("Schedule not found")
+	ErrInvalidStatementPeriod  = errors.New("Invalid statement period")
+	ErrMandateNotFound         = errors.New("Mandate not found")
+	ErrMandateAlreadyActive    = errors.New("Loan already has an active mandate")
+	ErrCollectionBatchNotFound = errors.New("Collection batch not found")
+	ErrCollectionBatchClosed   = errors.New("Collection batch already reconciled")
+	ErrCollectionItemNotFound  = errors.New("Collection item not found or already settled")
+	ErrInvalidBankFile         = errors.New("Invalid bank file")
+	ErrInvalidWebhook          = errors.New("Invalid webhook subscription")
+	ErrWebhookNotFound         = errors.New("Webhook subscription not found")
+	ErrWebhookDeliveryNotFound = errors.New("Webhook delivery not found")
+	ErrWebhookDeliveryNotDead  = errors.New("Webhook delivery is not in the dead-letter list")
+	ErrIdempotencyKeyMismatch  = errors.New("Idempotency key already used with a different request")
+	ErrIdempotencyKeyInFlight  = errors.New("A request with the same idempotency key is still in progress")
+	ErrUnauthenticated         = errors.New("Missing or invalid credentials")
+	ErrInsufficientScope       = errors.New("Credentials lack the scope required by the operation")
+	ErrAPIKeyNotFound          = errors.New("API key not found")
+	ErrInvalidAPIKey           = errors.New("Invalid API key")
+	ErrPermissionDenied        = errors.New("The roles of the caller do not grant the operation")
+	ErrInvalidAuditQuery       = errors.New("Invalid audit log query")
+	ErrRateLimited             = errors.New("Too many requests")
+	ErrOverloaded              = errors.New("Server overloaded")
 )
+
+// errorCodes are the stable machine-readable codes of the errors above, they are part of the API contract
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrLoanNotFound, "loan_not_found"},
+	{ErrInvalidStateOutstanding, "invalid_outstanding_state"},
+	{ErrInvalidLoanTerms, "invalid_loan_terms"},
+	{ErrInvalidPayment, "invalid_payment_amount"},
+	{ErrLoanAlreadyClosed, "loan_already_closed"},
+	{ErrInstallmentAlreadyPaid, "installment_already_paid"},
+	{ErrInstallmentOutOfOrder, "installment_out_of_order"},
+	{ErrDuplicatePayment, "duplicate_payment"},
+	{ErrConcurrentPayment, "concurrent_payment"},
+	{ErrDelinquencyCheck, "delinquency_check_failed"},
+	{ErrScheduleNotFound, "schedule_not_found"},
+	{ErrInvalidStatementPeriod, "invalid_statement_period"},
+	{ErrMandateNotFound, "mandate_not_found"},
+	{ErrMandateAlreadyActive, "mandate_already_active"},
+	{ErrCollectionBatchNotFound, "collection_batch_not_found"},
+	{ErrCollectionBatchClosed, "collection_batch_closed"},
+	{ErrCollectionItemNotFound, "collection_item_not_found"},
+	{ErrInvalidBankFile, "invalid_bank_file"},
+	{ErrInvalidWebhook, "invalid_webhook_subscription"},
+	{ErrWebhookNotFound, "webhook_subscription_not_found"},
+	{ErrWebhookDeliveryNotFound, "webhook_delivery_not_found"},
+	{ErrWebhookDeliveryNotDead, "webhook_delivery_not_dead"},
+	{ErrIdempotencyKeyMismatch, "idempotency_key_mismatch"},
+	{ErrIdempotencyKeyInFlight, "idempotency_key_in_flight"},
+	{ErrUnauthenticated, "unauthenticated"},
+	{ErrInsufficientScope, "insufficient_scope"},
+	{ErrAPIKeyNotFound, "api_key_not_found"},
+	{ErrInvalidAPIKey, "invalid_api_key"},
+	{ErrPermissionDenied, "permission_denied"},
+	{ErrInvalidAuditQuery, "invalid_audit_query"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrOverloaded, "overloaded"},
+}
+
+// ErrorCode returns the code of the domain error wrapped in err, ok is false for any other error
+func ErrorCode(err error) (code string, ok bool) {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AggregateTypeLoan = "LOAN"
+
+	EventLoanCreated             = "LoanCreated"
+	EventPaymentReceived         = "PaymentReceived"
+	EventScheduleInstallmentPaid = "ScheduleInstallmentPaid"
+	EventLoanPaidOff             = "LoanPaidOff"
+	EventLoanBecameDelinquent    = "LoanBecameDelinquent"
+
+	OutboxEventStatusPending    = "PENDING"
+	OutboxEventStatusDispatched = "DISPATCHED"
+	OutboxEventStatusDead       = "DEAD"
+)
+
+// OutboxEvent is a domain event persisted in the same transaction as the change that caused it
+type OutboxEvent struct {
+	ID            int64
+	TenantID      string // the tenant of the aggregate, only its webhook subscriptions and streams receive the event
+	AggregateType string
+	AggregateID   int64
+	EventType     string
+	Payload       []byte // JSON document, shape depends on EventType
+	Attempts      int
+	CreatedAt     time.Time
+}
+
+type CreateOutboxEventCommand struct {
+	AggregateType string
+	AggregateID   int64
+	EventType     string
+	Payload       []byte
+}
+
+// ClaimOutboxEventsCommand leases up to Limit events due at Now to one dispatcher until LeaseUntil
+type ClaimOutboxEventsCommand struct {
+	Now        time.Time
+	LeaseUntil time.Time
+	Limit      int32
+}
+
+type MarkOutboxEventFailedCommand struct {
+	ID            int64
+	Status        string
+	LastError     string
+	NextAttemptAt time.Time
+}
+
+// DelinquentLoan is a loan that crossed the delinquency threshold without an event being emitted yet
+type DelinquentLoan struct {
+	LoanID       int64
+	LastPaidWeek int
+	ExpectedWeek int
+}
+
+// EventPublisher delivers outbox events to downstream systems, it must be safe to call more than once per event
+type EventPublisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
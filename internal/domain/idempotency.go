@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+const (
+	IdempotencyStatusInProgress = "IN_PROGRESS"
+	IdempotencyStatusCompleted  = "COMPLETED"
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an idempotency key
+type IdempotencyRecord struct {
+	Key                 string
+	RequestHash         string
+	Status              string
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+	CreatedAt           time.Time
+	ExpiresAt           time.Time
+}
+
+type AcquireIdempotencyKeyCommand struct {
+	Key         string
+	RequestHash string
+	Now         time.Time
+	ExpiresAt   time.Time
+	StaleBefore time.Time // an in-progress key locked before this is considered abandoned
+}
+
+type CompleteIdempotencyKeyCommand struct {
+	Key                 string
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+}
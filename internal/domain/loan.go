@@ -10,6 +10,7 @@ type Loan struct {
 	TotalPayableAmount  int64
 	WeeklyPaymentAmount int64
 	TotalWeeks          int
+	BorrowerID          *string // subject of the borrower, nil for loans created without one
 	CreatedAt           time.Time
 }
 
@@ -20,4 +21,5 @@ type CreateLoanCommand struct {
 	WeeklyPaymentAmount int64
 	TotalWeeks          int32
 	StartDate           time.Time
+	BorrowerID          *string
 }
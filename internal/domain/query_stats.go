@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// QueryStat aggregates the runs of a statement since startup, the percentiles are over its latest runs
+type QueryStat struct {
+	Name   string // the sqlc query name, or the first keyword of the statements sqlc did not generate
+	Count  int64
+	Errors int64
+	Slow   int64 // runs that took longer than the slow query threshold
+	P50    time.Duration
+	P95    time.Duration
+	Max    time.Duration
+}
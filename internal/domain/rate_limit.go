@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// RateLimit is a token bucket: Burst requests at once, refilled at Rate requests per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled is false for the zero value, which leaves the requests unlimited
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+type TakeRateLimitTokenCommand struct {
+	Key   string
+	Limit RateLimit
+	Now   time.Time
+}
+
+// RateLimitBucket is the state of a bucket once a request took, or failed to take, its token
+type RateLimitBucket struct {
+	Allowed bool
+	Tokens  float64 // left for the next requests, below 1 when the bucket is empty
+}
@@ -2,26 +2,155 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
+// AuditWriter appends to the audit log of the tenant of ctx, in the transaction of the repository when there is one
+type AuditWriter interface {
+	InsertAuditEntry(ctx context.Context, arg CreateAuditEntryCommand) error
+}
+
 type BillingRepository interface {
+	AuditWriter
 
 	// transcaction
 	WithTx(ctx context.Context, fn func(repo BillingRepository) error) error
+	WithTxOptions(ctx context.Context, opts TxOptions, fn func(repo BillingRepository) error) error
 
 	// Loan-related actions
 	GetLoanByID(ctx context.Context, id int64) (*Loan, error)
+	LockLoanForUpdate(ctx context.Context, id int64) (*Loan, error)
 	InsertLoan(ctx context.Context, arg CreateLoanCommand) (*Loan, error)
+	ListLoans(ctx context.Context, cursorID *int64, borrowerID *string, limit int32) ([]Loan, error)
 
 	// Payment-related actions
 	GetTotalPaidAmount(ctx context.Context, loanID int64) (int64, error)
 	GetPaidWeeksCount(ctx context.Context, loanID int64) (int32, error)
 	GetLastPaidWeek(ctx context.Context, loanID int64) (int32, error)
 	InsertPayment(ctx context.Context, arg CreatePaymentComand) (*Payment, error)
+	GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*Payment, error)
 	ListPaymentsByLoanID(ctx context.Context, arg ListPaymentsQuery) ([]Payment, error)
+	ListPaymentsByLoanIDInPeriod(ctx context.Context, arg StatementPeriodQuery) ([]Payment, error)
+	GetTotalPaidAmountBefore(ctx context.Context, loanID int64, before time.Time) (int64, error)
 
 	// Schedule-related actions
 	CreateLoanSchedules(ctx context.Context, arg []LoanSchedule) (int64, error)
 	ListSchedulesByLoanID(ctx context.Context, arg ListScheduleQuery) ([]LoanSchedule, error)
 	UpdateSchedulePayment(ctx context.Context, arg UpdateLoanSchedulePaymentCommand) (int64, error)
+	ListUpcomingSchedules(ctx context.Context, arg UpcomingSchedulesQuery) ([]LoanSchedule, error)
+
+	// Event-related actions, written within the same transaction as the change
+	InsertOutboxEvent(ctx context.Context, arg CreateOutboxEventCommand) (int64, error)
+	ListNewlyDelinquentLoans(ctx context.Context, evaluatedAt time.Time, gapWeeks int32, limit int32) ([]DelinquentLoan, error)
+}
+
+type CollectionRepository interface {
+	AuditWriter
+
+	// transaction
+	WithTx(ctx context.Context, fn func(repo CollectionRepository) error) error
+	// Billing returns the billing repository of the same transaction, so an import posts its payments atomically
+	Billing() BillingRepository
+
+	// Mandate-related actions
+	InsertMandate(ctx context.Context, arg CreateMandateCommand) (*Mandate, error)
+	GetActiveMandateByLoanID(ctx context.Context, loanID int64) (*Mandate, error)
+	RevokeMandate(ctx context.Context, loanID int64) (*Mandate, error)
+
+	// Collection run actions
+	LockCollectionRun(ctx context.Context) error
+	ListCollectionCandidates(ctx context.Context, collectionDate time.Time) ([]CollectionCandidate, error)
+	ConsumeDueRetries(ctx context.Context, collectionDate time.Time) error
+	InsertCollectionBatch(ctx context.Context, arg CreateCollectionBatchCommand) (*CollectionBatch, error)
+	CreateCollectionItems(ctx context.Context, arg []CreateCollectionItemCommand) (int64, error)
+
+	// Batch-related actions
+	GetCollectionBatchByID(ctx context.Context, id int64) (*CollectionBatch, error)
+	LockCollectionBatchForUpdate(ctx context.Context, id int64) (*CollectionBatch, error)
+	UpdateCollectionBatchStatus(ctx context.Context, id int64, status string) (*CollectionBatch, error)
+	ListCollectionItemsByBatchID(ctx context.Context, batchID int64) ([]CollectionItem, error)
+	UpdateCollectionItemResult(ctx context.Context, arg UpdateCollectionItemResultCommand) (int64, error)
+}
+
+type OutboxRepository interface {
+
+	// transaction
+	WithTx(ctx context.Context, fn func(repo OutboxRepository) error) error
+
+	TryLockOutboxDispatch(ctx context.Context) (bool, error)
+	ClaimOutboxEvents(ctx context.Context, arg ClaimOutboxEventsCommand) ([]OutboxEvent, error)
+	MarkOutboxEventDispatched(ctx context.Context, id int64) error
+	MarkOutboxEventFailed(ctx context.Context, arg MarkOutboxEventFailedCommand) error
+}
+
+type WebhookRepository interface {
+	AuditWriter
+
+	// transaction
+	WithTx(ctx context.Context, fn func(repo WebhookRepository) error) error
+
+	// subscriptions
+	InsertWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionCommand) (*WebhookSubscription, error)
+	GetWebhookSubscriptionByID(ctx context.Context, id int64) (*WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id int64) (*WebhookSubscription, error)
+
+	// deliveries
+	EnqueueWebhookDeliveries(ctx context.Context, eventID int64, eventType string) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesCommand) ([]DueWebhookDelivery, error)
+	InsertWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptCommand) error
+	MarkWebhookDeliverySucceeded(ctx context.Context, id int64, statusCode int) error
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedCommand) error
+	GetWebhookDeliveryByID(ctx context.Context, id int64) (*WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesQuery) ([]WebhookDelivery, error)
+	ListDeadWebhookDeliveries(ctx context.Context, cursorID *int64, limit int32) ([]WebhookDelivery, error)
+	ListWebhookDeliveryAttempts(ctx context.Context, deliveryID int64) ([]WebhookDeliveryAttempt, error)
+	RedeliverWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error)
+}
+
+type IdempotencyRepository interface {
+	// AcquireIdempotencyKey claims the key, when it is already taken the existing record is returned with acquired = false
+	AcquireIdempotencyKey(ctx context.Context, arg AcquireIdempotencyKeyCommand) (record *IdempotencyRecord, acquired bool, err error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyCommand) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+// RateLimitRepository holds the token buckets of the rate limiter, shared by the instances when stored in Postgres
+type RateLimitRepository interface {
+	// TakeRateLimitToken refills the bucket for the time elapsed since its last request and takes a token when one is left
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenCommand) (*RateLimitBucket, error)
+	DeleteIdleRateLimitBuckets(ctx context.Context, idleBefore time.Time) (int64, error)
+}
+
+type APIKeyRepository interface {
+	AuditWriter
+
+	// transaction
+	WithTx(ctx context.Context, fn func(repo APIKeyRepository) error) error
+
+	InsertAPIKey(ctx context.Context, arg CreateAPIKeyCommand) (*APIKey, error)
+	GetAPIKeyByID(ctx context.Context, id int64) (*APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	// ExpireAPIKey moves the expiry of the key forward to at, unless it already expires sooner
+	ExpireAPIKey(ctx context.Context, id int64, at time.Time) error
+	RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
+
+type AuditRepository interface {
+	AuditWriter
+
+	// ListAuditEntries retrieves the entries of the tenant of ctx, newest first
+	ListAuditEntries(ctx context.Context, arg ListAuditEntriesQuery) ([]AuditEntry, error)
+	// ListAuditChain retrieves the entries of the tenant of ctx after afterID, oldest first
+	ListAuditChain(ctx context.Context, afterID int64, limit int32) ([]AuditEntry, error)
+}
+
+// HealthRepository reports the state of the database to the readiness probe
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	// GetSchemaVersion returns the latest migration applied, 0 when none is recorded
+	GetSchemaVersion(ctx context.Context) (int32, error)
 }
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	StatementEntryPayment    = "PAYMENT"
+	StatementEntryFee        = "FEE"
+	StatementEntryReversal   = "REVERSAL"
+	StatementEntryAdjustment = "ADJUSTMENT"
+)
+
+// Statement is a point in time view of a loan account over a period
+type Statement struct {
+	Loan                 Loan
+	PeriodStart          time.Time
+	PeriodEnd            time.Time
+	OpeningBalance       int64
+	Entries              []StatementEntry
+	ClosingBalance       int64
+	UpcomingInstallments []LoanSchedule
+	GeneratedAt          time.Time
+}
+
+/*
+StatementEntry is a single movement on the loan balance.
+Debit increases the amount owed (fees, reversals), Credit decreases it (payments),
+Balance is the outstanding amount right after the entry is applied.
+*/
+type StatementEntry struct {
+	Type        string
+	Reference   string
+	Date        time.Time
+	Description string
+	Debit       int64
+	Credit      int64
+	Balance     int64
+}
+
+type StatementPeriodQuery struct {
+	LoanID      int64
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+}
+
+// UpcomingSchedulesQuery selects the schedules due on or after DueFrom that were not fully paid before it
+type UpcomingSchedulesQuery struct {
+	LoanID  int64
+	DueFrom time.Time
+	Limit   int32
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"billing-api/internal/contextkey"
+	"context"
+)
+
+// DefaultTenantID owns the rows created before the tenants existed, and every call when auth is disabled
+const DefaultTenantID = "default"
+
+// ContextWithTenant scopes the queries run with ctx to the tenant, used by the background jobs working for every tenant
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextkey.TenantKey, tenantID)
+}
+
+/*
+TenantFromContext returns the tenant every repository scopes its queries to: the one set by ContextWithTenant,
+otherwise the tenant of the authenticated caller, otherwise DefaultTenantID.
+*/
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(contextkey.TenantKey).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	if p := PrincipalFromContext(ctx); p != nil && p.TenantID != "" {
+		return p.TenantID
+	}
+	return DefaultTenantID
+}
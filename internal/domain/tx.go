@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+type TxIsolation string
+
+const (
+	TxIsolationDefault        TxIsolation = "" // the server default, read committed unless configured otherwise
+	TxIsolationReadCommitted  TxIsolation = "read committed"
+	TxIsolationRepeatableRead TxIsolation = "repeatable read"
+	TxIsolationSerializable   TxIsolation = "serializable"
+)
+
+/*
+TxOptions configures a transaction started by WithTxOptions.
+
+Deferrable only has an effect on serializable read-only transactions: they wait for a snapshot
+that can not conflict with other transactions and then never fail with a serialization failure.
+*/
+type TxOptions struct {
+	Isolation  TxIsolation
+	ReadOnly   bool
+	Deferrable bool
+	Retry      TxRetryPolicy // nil uses the default policy of the repository
+}
+
+// SnapshotTxOptions runs the reads of a report against one consistent snapshot
+var SnapshotTxOptions = TxOptions{
+	Isolation: TxIsolationRepeatableRead,
+	ReadOnly:  true,
+}
+
+/*
+TxRetryPolicy decides whether a transaction that failed with a transient error runs again.
+
+It is only consulted for errors the repository knows to be safe to retry (e.g. a serialization
+failure, a deadlock or a connection lost before commit), attempt is the number of attempts made so far.
+*/
+type TxRetryPolicy interface {
+	Backoff(attempt int, err error) (wait time.Duration, retry bool)
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	WebhookSubscriptionStatusActive  = "ACTIVE"
+	WebhookSubscriptionStatusDeleted = "DELETED"
+
+	WebhookDeliveryStatusPending   = "PENDING"
+	WebhookDeliveryStatusDelivered = "DELIVERED"
+	WebhookDeliveryStatusDead      = "DEAD"
+)
+
+// WebhookEventTypes lists the events a partner can subscribe to
+var WebhookEventTypes = []string{
+	EventLoanCreated,
+	EventPaymentReceived,
+	EventScheduleInstallmentPaid,
+	EventLoanPaidOff,
+	EventLoanBecameDelinquent,
+}
+
+// WebhookSubscription is a partner endpoint notified about the listed event types
+type WebhookSubscription struct {
+	ID         int64
+	URL        string
+	EventTypes []string
+	Secret     string // HMAC key for the delivery signature
+	Status     string
+	CreatedAt  time.Time
+}
+
+type CreateWebhookSubscriptionCommand struct {
+	URL        string
+	EventTypes []string
+	Secret     string
+}
+
+// WebhookDelivery tracks one outbox event sent to one subscription
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        int64
+	EventType      string
+	Status         string
+	Attempts       int
+	LastStatusCode *int
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// WebhookDeliveryAttempt is one entry of the delivery log
+type WebhookDeliveryAttempt struct {
+	ID          int64
+	DeliveryID  int64
+	Attempt     int
+	StatusCode  *int
+	Error       string
+	Duration    time.Duration
+	AttemptedAt time.Time
+}
+
+// DueWebhookDelivery is a pending delivery together with its target and the event to send
+type DueWebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	Attempts       int
+	URL            string
+	Secret         string
+	Event          OutboxEvent
+}
+
+// ClaimWebhookDeliveriesCommand leases up to Limit deliveries due at Now to one dispatcher until LeaseUntil
+type ClaimWebhookDeliveriesCommand struct {
+	Now        time.Time
+	LeaseUntil time.Time
+	Limit      int32
+}
+
+type ListWebhookDeliveriesQuery struct {
+	SubscriptionID int64
+	Status         string // optional
+	CursorID       *int64
+	LimitVal       int32
+}
+
+type CreateWebhookDeliveryAttemptCommand struct {
+	DeliveryID int64
+	Attempt    int
+	StatusCode *int
+	Error      string
+	Duration   time.Duration
+}
+
+type MarkWebhookDeliveryFailedCommand struct {
+	ID            int64
+	Status        string
+	StatusCode    *int
+	LastError     string
+	NextAttemptAt time.Time
+}
+
+// WebhookResult is the outcome of one HTTP delivery, StatusCode is 0 when no response was received
+type WebhookResult struct {
+	StatusCode int
+	Err        error
+}
+
+// WebhookSender performs the signed HTTP call of a delivery
+type WebhookSender interface {
+	Send(ctx context.Context, delivery DueWebhookDelivery) WebhookResult
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/service"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	authorizationMetadata = "authorization"
+	apiKeyMetadata        = "x-api-key"
+)
+
+// writeMethods need the write scope, the other billing methods only read
+var writeMethods = map[string]bool{
+	"/billing.v1.BillingService/SubmitLoan":    true,
+	"/billing.v1.BillingService/SubmitPayment": true,
+}
+
+/*
+methodPermissions are the permissions of the RBAC policy a method requires, any of them is enough.
+The read methods also grant a borrower its own loans, BillingService.AuthorizeLoanAccess checks the loan.
+*/
+var methodPermissions = map[string][]string{
+	"/billing.v1.BillingService/SubmitLoan":    {domain.PermissionLoanCreate},
+	"/billing.v1.BillingService/SubmitPayment": {domain.PermissionPaymentCreate},
+}
+
+var readLoanPermissions = []string{domain.PermissionLoanRead, domain.PermissionLoanReadOwn}
+
+/*
+authenticate checks the credentials of a call the same way as the REST API: the authorization metadata
+holds a bearer API key or JWT, or x-api-key an API key. Health checks and reflection need no credentials.
+*/
+func authenticate(ctx context.Context, authService *service.AuthService, method string) (context.Context, error) {
+	if !strings.HasPrefix(method, "/billing.v1.") {
+		return ctx, nil
+	}
+
+	principal, err := authService.Authenticate(ctx, service.Credentials{
+		Authorization: firstMetadata(ctx, authorizationMetadata),
+		APIKey:        firstMetadata(ctx, apiKeyMetadata),
+	})
+	if errors.Is(err, domain.ErrUnauthenticated) {
+		slog.InfoContext(ctx, "authentication_failed", slog.String("grpc_method", method), slog.String("reason", err.Error()))
+		code, _ := domain.ErrorCode(err)
+		return nil, newStatus(ctx, codes.Unauthenticated, code, "Missing or invalid credentials").Err()
+	}
+	if err != nil {
+		return nil, toStatusError(ctx, method, err)
+	}
+	setLoggedPrincipal(ctx, principal)
+	ctx = domain.ContextWithPrincipal(ctx, principal)
+
+	scope := domain.ScopeRead
+	if writeMethods[method] {
+		scope = domain.ScopeWrite
+	}
+	if !principal.HasScope(scope) {
+		slog.WarnContext(ctx, "insufficient_scope", slog.String("grpc_method", method), slog.String("required_scope", scope))
+		code, _ := domain.ErrorCode(domain.ErrInsufficientScope)
+		return nil, newStatus(ctx, codes.PermissionDenied, code, "The "+scope+" scope is required").Err()
+	}
+
+	permissions, ok := methodPermissions[method]
+	if !ok {
+		permissions = readLoanPermissions
+	}
+	if !slices.ContainsFunc(permissions, principal.Can) {
+		service.AuditDenied(ctx, principal, permissions[0], "missing permission")
+		code, _ := domain.ErrorCode(domain.ErrPermissionDenied)
+		return nil, newStatus(ctx, codes.PermissionDenied, code, "The "+permissions[0]+" permission is required").Err()
+	}
+	return ctx, nil
+}
+
+func unaryAuth(authService *service.AuthService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, authService, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(authService *service.AuthService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), authService, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,311 @@
+package grpc
+
+import (
+	"billing-api/internal/config"
+	"billing-api/internal/contextkey"
+	"billing-api/internal/domain"
+	"billing-api/internal/grpc/billingv1"
+	"billing-api/internal/service"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const dateLayout = "2006-01-02"
+
+// bounds of SubmitLoanRequest, the same as the validate tags of the REST request
+const (
+	maxPrincipalAmount = 1_000_000_000_000
+	maxTotalWeeks      = 520
+	maxBorrowerIDLen   = 100
+)
+
+type BillingServer struct {
+	billingv1.UnimplementedBillingServiceServer
+	billingService *service.BillingService
+	config         *config.Config
+}
+
+func NewBillingServer(bs *service.BillingService, cfg *config.Config) *BillingServer {
+	return &BillingServer{
+		billingService: bs,
+		config:         cfg,
+	}
+}
+
+func (s *BillingServer) SubmitLoan(ctx context.Context, req *billingv1.SubmitLoanRequest) (*billingv1.SubmitLoanResponse, error) {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if req.PrincipalAmount < 1 || req.PrincipalAmount > maxPrincipalAmount {
+		violations = append(violations, fieldViolation("principal_amount", "must be between 1 and 1000000000000"))
+	}
+	if req.AnnualInterestRate < 0 || req.AnnualInterestRate > 1 {
+		violations = append(violations, fieldViolation("annual_interest_rate", "must be between 0 and 1"))
+	}
+	if req.TotalWeeks < 1 || req.TotalWeeks > maxTotalWeeks {
+		violations = append(violations, fieldViolation("total_weeks", "must be between 1 and 520"))
+	}
+	startDate, err := time.Parse(dateLayout, req.StartDate)
+	if err != nil {
+		violations = append(violations, fieldViolation("start_date", "must be a date in YYYY-MM-DD format"))
+	}
+	if len([]rune(req.BorrowerId)) > maxBorrowerIDLen {
+		violations = append(violations, fieldViolation("borrower_id", "must be at most 100 characters"))
+	}
+	if len(violations) > 0 {
+		return nil, invalidArgument(ctx, violations...)
+	}
+
+	loan, err := s.billingService.SubmitLoan(ctx, service.SubmitLoanInput{
+		PrincipalAmount:    req.PrincipalAmount,
+		AnnualInterestRate: req.AnnualInterestRate,
+		TotalWeeks:         int(req.TotalWeeks),
+		StartDate:          startDate,
+		BorrowerID:         req.BorrowerId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &billingv1.SubmitLoanResponse{
+		LoanId:              loan.ID,
+		WeeklyPaymentAmount: loan.WeeklyPaymentAmount,
+		TotalPayable:        loan.TotalPayableAmount,
+	}, nil
+}
+
+func (s *BillingServer) GetLoan(ctx context.Context, req *billingv1.GetLoanRequest) (*billingv1.Loan, error) {
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return nil, err
+	}
+	loan, err := s.billingService.GetLoanByID(ctx, req.LoanId)
+	if err != nil {
+		return nil, err
+	}
+
+	isDelinquent, err := s.billingService.IsDelinquent(ctx, loan.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var borrowerID string
+	if loan.BorrowerID != nil {
+		borrowerID = *loan.BorrowerID
+	}
+	return &billingv1.Loan{
+		LoanId:              loan.ID,
+		PrincipalAmount:     loan.PrincipalAmount,
+		TotalPayable:        loan.TotalPayableAmount,
+		WeeklyPaymentAmount: loan.WeeklyPaymentAmount,
+		TotalWeeks:          int32(loan.TotalWeeks),
+		CreatedAt:           timestamppb.New(loan.CreatedAt),
+		IsDelinquent:        isDelinquent,
+		BorrowerId:          borrowerID,
+	}, nil
+}
+
+func (s *BillingServer) GetOutstanding(ctx context.Context, req *billingv1.GetOutstandingRequest) (*billingv1.GetOutstandingResponse, error) {
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return nil, err
+	}
+	outstanding, err := s.billingService.GetOutstanding(ctx, req.LoanId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &billingv1.GetOutstandingResponse{
+		LoanId:      req.LoanId,
+		Outstanding: outstanding,
+	}, nil
+}
+
+func (s *BillingServer) SubmitPayment(ctx context.Context, req *billingv1.SubmitPaymentRequest) (*billingv1.SubmitPaymentResponse, error) {
+	if req.Amount < 1 {
+		return nil, invalidArgument(ctx, fieldViolation("amount", "must be at least 1"))
+	}
+
+	// set by the idempotency interceptor
+	idempotencyKey, _ := ctx.Value(contextkey.IdempotencyKey).(string)
+	if idempotencyKey == "" {
+		return nil, invalidArgument(ctx, fieldViolation(idempotencyKeyMetadata, "metadata is required"))
+	}
+
+	// same overall deadline as the REST handler
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	id, err := s.billingService.SubmitPayment(ctx, service.SubmitPaymentInput{
+		LoanID:         req.LoanId,
+		Amount:         req.Amount,
+		PaidAt:         time.Now(),
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &billingv1.SubmitPaymentResponse{PaymentId: id}, nil
+}
+
+func (s *BillingServer) ListPayments(ctx context.Context, req *billingv1.ListPaymentsRequest) (*billingv1.ListPaymentsResponse, error) {
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return nil, err
+	}
+	cursor, err := decodePageToken[service.PaymentCursor](req.PageToken)
+	if err != nil {
+		return nil, invalidArgument(ctx, fieldViolation("page_token", "invalid page token"))
+	}
+
+	payments, nextCursor, err := s.billingService.ListPayments(ctx, req.LoanId, s.pageSize(ctx, req.PageSize), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	nextPageToken, err := encodePageToken(nextCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &billingv1.ListPaymentsResponse{NextPageToken: nextPageToken}
+	for _, p := range payments {
+		resp.Payments = append(resp.Payments, toPayment(p))
+	}
+	return resp, nil
+}
+
+func (s *BillingServer) ListSchedules(ctx context.Context, req *billingv1.ListSchedulesRequest) (*billingv1.ListSchedulesResponse, error) {
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return nil, err
+	}
+	cursor, err := decodePageToken[service.ScheduleCursor](req.PageToken)
+	if err != nil {
+		return nil, invalidArgument(ctx, fieldViolation("page_token", "invalid page token"))
+	}
+
+	schedules, nextCursor, err := s.billingService.ListSchedules(ctx, req.LoanId, s.pageSize(ctx, req.PageSize), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	nextPageToken, err := encodePageToken(nextCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &billingv1.ListSchedulesResponse{NextPageToken: nextPageToken}
+	for _, sc := range schedules {
+		resp.Schedules = append(resp.Schedules, toSchedule(sc))
+	}
+	return resp, nil
+}
+
+// StreamPayments pages through the payments with the largest page size and sends them one by one
+func (s *BillingServer) StreamPayments(req *billingv1.StreamPaymentsRequest, stream billingv1.BillingService_StreamPaymentsServer) error {
+	ctx := stream.Context()
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return err
+	}
+	if _, err := s.billingService.GetLoanByID(ctx, req.LoanId); err != nil {
+		return err
+	}
+
+	var cursor *service.PaymentCursor
+	for {
+		payments, next, err := s.billingService.ListPayments(ctx, req.LoanId, s.billingService.TenantSettings(ctx).PagingLimitMax, cursor)
+		if err != nil {
+			return err
+		}
+		for _, p := range payments {
+			if err := stream.Send(toPayment(p)); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (s *BillingServer) StreamSchedules(req *billingv1.StreamSchedulesRequest, stream billingv1.BillingService_StreamSchedulesServer) error {
+	ctx := stream.Context()
+	if err := s.billingService.AuthorizeLoanAccess(ctx, req.LoanId); err != nil {
+		return err
+	}
+	if _, err := s.billingService.GetLoanByID(ctx, req.LoanId); err != nil {
+		return err
+	}
+
+	var cursor *service.ScheduleCursor
+	for {
+		schedules, next, err := s.billingService.ListSchedules(ctx, req.LoanId, s.billingService.TenantSettings(ctx).PagingLimitMax, cursor)
+		if err != nil {
+			return err
+		}
+		for _, sc := range schedules {
+			if err := stream.Send(toSchedule(sc)); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// pageSize applies the same defaults and cap of the tenant as the limit query parameter of the REST API
+func (s *BillingServer) pageSize(ctx context.Context, size int32) int {
+	settings := s.billingService.TenantSettings(ctx)
+	if size <= 0 || int(size) > settings.PagingLimitMax {
+		return settings.PagingLimitDefault
+	}
+	return int(size)
+}
+
+func toPayment(p domain.Payment) *billingv1.Payment {
+	return &billingv1.Payment{
+		WeekNumber: int32(p.WeekNumber),
+		Amount:     p.Amount,
+		PaidAt:     timestamppb.New(p.PaidAt),
+	}
+}
+
+func toSchedule(s domain.LoanSchedule) *billingv1.Schedule {
+	return &billingv1.Schedule{
+		Sequence:   int32(s.Sequence),
+		DueDate:    s.DueDate.Format(dateLayout),
+		Amount:     s.Amount,
+		PaidAmount: s.PaidAmount,
+		Status:     s.Status,
+	}
+}
+
+// page tokens are encoded like the next_cursor of the REST API, a token of one API is valid in the other
+func encodePageToken[T any](cursor *T) (string, error) {
+	if cursor == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken[T any](token string) (*T, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor T
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
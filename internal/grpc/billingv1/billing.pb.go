@@ -0,0 +1,1121 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: billing/v1/billing.proto
+
+package billingv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Loan struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	LoanId              int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	PrincipalAmount     int64                  `protobuf:"varint,2,opt,name=principal_amount,json=principalAmount,proto3" json:"principal_amount,omitempty"`
+	TotalPayable        int64                  `protobuf:"varint,3,opt,name=total_payable,json=totalPayable,proto3" json:"total_payable,omitempty"`
+	WeeklyPaymentAmount int64                  `protobuf:"varint,4,opt,name=weekly_payment_amount,json=weeklyPaymentAmount,proto3" json:"weekly_payment_amount,omitempty"`
+	TotalWeeks          int32                  `protobuf:"varint,5,opt,name=total_weeks,json=totalWeeks,proto3" json:"total_weeks,omitempty"`
+	CreatedAt           *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	IsDelinquent        bool                   `protobuf:"varint,7,opt,name=is_delinquent,json=isDelinquent,proto3" json:"is_delinquent,omitempty"`
+	// subject of the borrower allowed to read the loan, empty for a loan created without one
+	BorrowerId    string `protobuf:"bytes,8,opt,name=borrower_id,json=borrowerId,proto3" json:"borrower_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Loan) Reset() {
+	*x = Loan{}
+	mi := &file_billing_v1_billing_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Loan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Loan) ProtoMessage() {}
+
+func (x *Loan) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Loan.ProtoReflect.Descriptor instead.
+func (*Loan) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Loan) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *Loan) GetPrincipalAmount() int64 {
+	if x != nil {
+		return x.PrincipalAmount
+	}
+	return 0
+}
+
+func (x *Loan) GetTotalPayable() int64 {
+	if x != nil {
+		return x.TotalPayable
+	}
+	return 0
+}
+
+func (x *Loan) GetWeeklyPaymentAmount() int64 {
+	if x != nil {
+		return x.WeeklyPaymentAmount
+	}
+	return 0
+}
+
+func (x *Loan) GetTotalWeeks() int32 {
+	if x != nil {
+		return x.TotalWeeks
+	}
+	return 0
+}
+
+func (x *Loan) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Loan) GetIsDelinquent() bool {
+	if x != nil {
+		return x.IsDelinquent
+	}
+	return false
+}
+
+func (x *Loan) GetBorrowerId() string {
+	if x != nil {
+		return x.BorrowerId
+	}
+	return ""
+}
+
+type Schedule struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Sequence   int32                  `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	DueDate    string                 `protobuf:"bytes,2,opt,name=due_date,json=dueDate,proto3" json:"due_date,omitempty"`
+	Amount     int64                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	PaidAmount int64                  `protobuf:"varint,4,opt,name=paid_amount,json=paidAmount,proto3" json:"paid_amount,omitempty"`
+	// PENDING, PARTIAL or PAID
+	Status        string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Schedule) Reset() {
+	*x = Schedule{}
+	mi := &file_billing_v1_billing_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Schedule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Schedule) ProtoMessage() {}
+
+func (x *Schedule) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Schedule.ProtoReflect.Descriptor instead.
+func (*Schedule) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Schedule) GetSequence() int32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Schedule) GetDueDate() string {
+	if x != nil {
+		return x.DueDate
+	}
+	return ""
+}
+
+func (x *Schedule) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Schedule) GetPaidAmount() int64 {
+	if x != nil {
+		return x.PaidAmount
+	}
+	return 0
+}
+
+func (x *Schedule) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type Payment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WeekNumber    int32                  `protobuf:"varint,1,opt,name=week_number,json=weekNumber,proto3" json:"week_number,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	PaidAt        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=paid_at,json=paidAt,proto3" json:"paid_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Payment) Reset() {
+	*x = Payment{}
+	mi := &file_billing_v1_billing_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payment) ProtoMessage() {}
+
+func (x *Payment) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payment.ProtoReflect.Descriptor instead.
+func (*Payment) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Payment) GetWeekNumber() int32 {
+	if x != nil {
+		return x.WeekNumber
+	}
+	return 0
+}
+
+func (x *Payment) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Payment) GetPaidAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.PaidAt
+	}
+	return nil
+}
+
+type SubmitLoanRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	PrincipalAmount int64                  `protobuf:"varint,1,opt,name=principal_amount,json=principalAmount,proto3" json:"principal_amount,omitempty"`
+	// flat annual rate, 0.1 is 10%
+	AnnualInterestRate float64 `protobuf:"fixed64,2,opt,name=annual_interest_rate,json=annualInterestRate,proto3" json:"annual_interest_rate,omitempty"`
+	TotalWeeks         int32   `protobuf:"varint,3,opt,name=total_weeks,json=totalWeeks,proto3" json:"total_weeks,omitempty"`
+	StartDate          string  `protobuf:"bytes,4,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	// optional subject of the borrower, lets the borrower read the loan under loan:read:own
+	BorrowerId    string `protobuf:"bytes,5,opt,name=borrower_id,json=borrowerId,proto3" json:"borrower_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitLoanRequest) Reset() {
+	*x = SubmitLoanRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitLoanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitLoanRequest) ProtoMessage() {}
+
+func (x *SubmitLoanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitLoanRequest.ProtoReflect.Descriptor instead.
+func (*SubmitLoanRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SubmitLoanRequest) GetPrincipalAmount() int64 {
+	if x != nil {
+		return x.PrincipalAmount
+	}
+	return 0
+}
+
+func (x *SubmitLoanRequest) GetAnnualInterestRate() float64 {
+	if x != nil {
+		return x.AnnualInterestRate
+	}
+	return 0
+}
+
+func (x *SubmitLoanRequest) GetTotalWeeks() int32 {
+	if x != nil {
+		return x.TotalWeeks
+	}
+	return 0
+}
+
+func (x *SubmitLoanRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *SubmitLoanRequest) GetBorrowerId() string {
+	if x != nil {
+		return x.BorrowerId
+	}
+	return ""
+}
+
+type SubmitLoanResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	LoanId              int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	WeeklyPaymentAmount int64                  `protobuf:"varint,2,opt,name=weekly_payment_amount,json=weeklyPaymentAmount,proto3" json:"weekly_payment_amount,omitempty"`
+	TotalPayable        int64                  `protobuf:"varint,3,opt,name=total_payable,json=totalPayable,proto3" json:"total_payable,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SubmitLoanResponse) Reset() {
+	*x = SubmitLoanResponse{}
+	mi := &file_billing_v1_billing_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitLoanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitLoanResponse) ProtoMessage() {}
+
+func (x *SubmitLoanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitLoanResponse.ProtoReflect.Descriptor instead.
+func (*SubmitLoanResponse) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SubmitLoanResponse) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *SubmitLoanResponse) GetWeeklyPaymentAmount() int64 {
+	if x != nil {
+		return x.WeeklyPaymentAmount
+	}
+	return 0
+}
+
+func (x *SubmitLoanResponse) GetTotalPayable() int64 {
+	if x != nil {
+		return x.TotalPayable
+	}
+	return 0
+}
+
+type GetLoanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLoanRequest) Reset() {
+	*x = GetLoanRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLoanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLoanRequest) ProtoMessage() {}
+
+func (x *GetLoanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLoanRequest.ProtoReflect.Descriptor instead.
+func (*GetLoanRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetLoanRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+type GetOutstandingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOutstandingRequest) Reset() {
+	*x = GetOutstandingRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOutstandingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOutstandingRequest) ProtoMessage() {}
+
+func (x *GetOutstandingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOutstandingRequest.ProtoReflect.Descriptor instead.
+func (*GetOutstandingRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetOutstandingRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+type GetOutstandingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	Outstanding   int64                  `protobuf:"varint,2,opt,name=outstanding,proto3" json:"outstanding,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOutstandingResponse) Reset() {
+	*x = GetOutstandingResponse{}
+	mi := &file_billing_v1_billing_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOutstandingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOutstandingResponse) ProtoMessage() {}
+
+func (x *GetOutstandingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOutstandingResponse.ProtoReflect.Descriptor instead.
+func (*GetOutstandingResponse) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetOutstandingResponse) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *GetOutstandingResponse) GetOutstanding() int64 {
+	if x != nil {
+		return x.Outstanding
+	}
+	return 0
+}
+
+type SubmitPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitPaymentRequest) Reset() {
+	*x = SubmitPaymentRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitPaymentRequest) ProtoMessage() {}
+
+func (x *SubmitPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitPaymentRequest.ProtoReflect.Descriptor instead.
+func (*SubmitPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SubmitPaymentRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *SubmitPaymentRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type SubmitPaymentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PaymentId     int64                  `protobuf:"varint,1,opt,name=payment_id,json=paymentId,proto3" json:"payment_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitPaymentResponse) Reset() {
+	*x = SubmitPaymentResponse{}
+	mi := &file_billing_v1_billing_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitPaymentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitPaymentResponse) ProtoMessage() {}
+
+func (x *SubmitPaymentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitPaymentResponse.ProtoReflect.Descriptor instead.
+func (*SubmitPaymentResponse) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SubmitPaymentResponse) GetPaymentId() int64 {
+	if x != nil {
+		return x.PaymentId
+	}
+	return 0
+}
+
+type ListPaymentsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	LoanId int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	// defaults to PAGE_DEFAULT_LIMIT, capped at PAGE_MAX_LIMIT
+	PageSize int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page, the same cursor as the REST next_cursor
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPaymentsRequest) Reset() {
+	*x = ListPaymentsRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPaymentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPaymentsRequest) ProtoMessage() {}
+
+func (x *ListPaymentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPaymentsRequest.ProtoReflect.Descriptor instead.
+func (*ListPaymentsRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListPaymentsRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *ListPaymentsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListPaymentsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListPaymentsResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Payments []*Payment             `protobuf:"bytes,1,rep,name=payments,proto3" json:"payments,omitempty"`
+	// empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPaymentsResponse) Reset() {
+	*x = ListPaymentsResponse{}
+	mi := &file_billing_v1_billing_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPaymentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPaymentsResponse) ProtoMessage() {}
+
+func (x *ListPaymentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPaymentsResponse.ProtoReflect.Descriptor instead.
+func (*ListPaymentsResponse) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListPaymentsResponse) GetPayments() []*Payment {
+	if x != nil {
+		return x.Payments
+	}
+	return nil
+}
+
+func (x *ListPaymentsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type ListSchedulesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSchedulesRequest) Reset() {
+	*x = ListSchedulesRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSchedulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSchedulesRequest) ProtoMessage() {}
+
+func (x *ListSchedulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSchedulesRequest.ProtoReflect.Descriptor instead.
+func (*ListSchedulesRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListSchedulesRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+func (x *ListSchedulesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListSchedulesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListSchedulesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schedules     []*Schedule            `protobuf:"bytes,1,rep,name=schedules,proto3" json:"schedules,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSchedulesResponse) Reset() {
+	*x = ListSchedulesResponse{}
+	mi := &file_billing_v1_billing_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSchedulesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSchedulesResponse) ProtoMessage() {}
+
+func (x *ListSchedulesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSchedulesResponse.ProtoReflect.Descriptor instead.
+func (*ListSchedulesResponse) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListSchedulesResponse) GetSchedules() []*Schedule {
+	if x != nil {
+		return x.Schedules
+	}
+	return nil
+}
+
+func (x *ListSchedulesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type StreamPaymentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamPaymentsRequest) Reset() {
+	*x = StreamPaymentsRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamPaymentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamPaymentsRequest) ProtoMessage() {}
+
+func (x *StreamPaymentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamPaymentsRequest.ProtoReflect.Descriptor instead.
+func (*StreamPaymentsRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StreamPaymentsRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+type StreamSchedulesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        int64                  `protobuf:"varint,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamSchedulesRequest) Reset() {
+	*x = StreamSchedulesRequest{}
+	mi := &file_billing_v1_billing_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamSchedulesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamSchedulesRequest) ProtoMessage() {}
+
+func (x *StreamSchedulesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_billing_v1_billing_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamSchedulesRequest.ProtoReflect.Descriptor instead.
+func (*StreamSchedulesRequest) Descriptor() ([]byte, []int) {
+	return file_billing_v1_billing_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *StreamSchedulesRequest) GetLoanId() int64 {
+	if x != nil {
+		return x.LoanId
+	}
+	return 0
+}
+
+var File_billing_v1_billing_proto protoreflect.FileDescriptor
+
+const file_billing_v1_billing_proto_rawDesc = "" +
+	"\n" +
+	"\x18billing/v1/billing.proto\x12\n" +
+	"billing.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc5\x02\n" +
+	"\x04Loan\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x12)\n" +
+	"\x10principal_amount\x18\x02 \x01(\x03R\x0fprincipalAmount\x12#\n" +
+	"\rtotal_payable\x18\x03 \x01(\x03R\ftotalPayable\x122\n" +
+	"\x15weekly_payment_amount\x18\x04 \x01(\x03R\x13weeklyPaymentAmount\x12\x1f\n" +
+	"\vtotal_weeks\x18\x05 \x01(\x05R\n" +
+	"totalWeeks\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12#\n" +
+	"\ris_delinquent\x18\a \x01(\bR\fisDelinquent\x12\x1f\n" +
+	"\vborrower_id\x18\b \x01(\tR\n" +
+	"borrowerId\"\x92\x01\n" +
+	"\bSchedule\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x05R\bsequence\x12\x19\n" +
+	"\bdue_date\x18\x02 \x01(\tR\adueDate\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12\x1f\n" +
+	"\vpaid_amount\x18\x04 \x01(\x03R\n" +
+	"paidAmount\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\"w\n" +
+	"\aPayment\x12\x1f\n" +
+	"\vweek_number\x18\x01 \x01(\x05R\n" +
+	"weekNumber\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x123\n" +
+	"\apaid_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x06paidAt\"\xd1\x01\n" +
+	"\x11SubmitLoanRequest\x12)\n" +
+	"\x10principal_amount\x18\x01 \x01(\x03R\x0fprincipalAmount\x120\n" +
+	"\x14annual_interest_rate\x18\x02 \x01(\x01R\x12annualInterestRate\x12\x1f\n" +
+	"\vtotal_weeks\x18\x03 \x01(\x05R\n" +
+	"totalWeeks\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x04 \x01(\tR\tstartDate\x12\x1f\n" +
+	"\vborrower_id\x18\x05 \x01(\tR\n" +
+	"borrowerId\"\x86\x01\n" +
+	"\x12SubmitLoanResponse\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x122\n" +
+	"\x15weekly_payment_amount\x18\x02 \x01(\x03R\x13weeklyPaymentAmount\x12#\n" +
+	"\rtotal_payable\x18\x03 \x01(\x03R\ftotalPayable\")\n" +
+	"\x0eGetLoanRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\"0\n" +
+	"\x15GetOutstandingRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\"S\n" +
+	"\x16GetOutstandingResponse\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x12 \n" +
+	"\voutstanding\x18\x02 \x01(\x03R\voutstanding\"G\n" +
+	"\x14SubmitPaymentRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\"6\n" +
+	"\x15SubmitPaymentResponse\x12\x1d\n" +
+	"\n" +
+	"payment_id\x18\x01 \x01(\x03R\tpaymentId\"j\n" +
+	"\x13ListPaymentsRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"o\n" +
+	"\x14ListPaymentsResponse\x12/\n" +
+	"\bpayments\x18\x01 \x03(\v2\x13.billing.v1.PaymentR\bpayments\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"k\n" +
+	"\x14ListSchedulesRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"s\n" +
+	"\x15ListSchedulesResponse\x122\n" +
+	"\tschedules\x18\x01 \x03(\v2\x14.billing.v1.ScheduleR\tschedules\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"0\n" +
+	"\x15StreamPaymentsRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId\"1\n" +
+	"\x16StreamSchedulesRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\x03R\x06loanId2\x89\x05\n" +
+	"\x0eBillingService\x12K\n" +
+	"\n" +
+	"SubmitLoan\x12\x1d.billing.v1.SubmitLoanRequest\x1a\x1e.billing.v1.SubmitLoanResponse\x127\n" +
+	"\aGetLoan\x12\x1a.billing.v1.GetLoanRequest\x1a\x10.billing.v1.Loan\x12W\n" +
+	"\x0eGetOutstanding\x12!.billing.v1.GetOutstandingRequest\x1a\".billing.v1.GetOutstandingResponse\x12T\n" +
+	"\rSubmitPayment\x12 .billing.v1.SubmitPaymentRequest\x1a!.billing.v1.SubmitPaymentResponse\x12Q\n" +
+	"\fListPayments\x12\x1f.billing.v1.ListPaymentsRequest\x1a .billing.v1.ListPaymentsResponse\x12T\n" +
+	"\rListSchedules\x12 .billing.v1.ListSchedulesRequest\x1a!.billing.v1.ListSchedulesResponse\x12J\n" +
+	"\x0eStreamPayments\x12!.billing.v1.StreamPaymentsRequest\x1a\x13.billing.v1.Payment0\x01\x12M\n" +
+	"\x0fStreamSchedules\x12\".billing.v1.StreamSchedulesRequest\x1a\x14.billing.v1.Schedule0\x01B/Z-billing-api/internal/grpc/billingv1;billingv1b\x06proto3"
+
+var (
+	file_billing_v1_billing_proto_rawDescOnce sync.Once
+	file_billing_v1_billing_proto_rawDescData []byte
+)
+
+func file_billing_v1_billing_proto_rawDescGZIP() []byte {
+	file_billing_v1_billing_proto_rawDescOnce.Do(func() {
+		file_billing_v1_billing_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_billing_v1_billing_proto_rawDesc), len(file_billing_v1_billing_proto_rawDesc)))
+	})
+	return file_billing_v1_billing_proto_rawDescData
+}
+
+var file_billing_v1_billing_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_billing_v1_billing_proto_goTypes = []any{
+	(*Loan)(nil),                   // 0: billing.v1.Loan
+	(*Schedule)(nil),               // 1: billing.v1.Schedule
+	(*Payment)(nil),                // 2: billing.v1.Payment
+	(*SubmitLoanRequest)(nil),      // 3: billing.v1.SubmitLoanRequest
+	(*SubmitLoanResponse)(nil),     // 4: billing.v1.SubmitLoanResponse
+	(*GetLoanRequest)(nil),         // 5: billing.v1.GetLoanRequest
+	(*GetOutstandingRequest)(nil),  // 6: billing.v1.GetOutstandingRequest
+	(*GetOutstandingResponse)(nil), // 7: billing.v1.GetOutstandingResponse
+	(*SubmitPaymentRequest)(nil),   // 8: billing.v1.SubmitPaymentRequest
+	(*SubmitPaymentResponse)(nil),  // 9: billing.v1.SubmitPaymentResponse
+	(*ListPaymentsRequest)(nil),    // 10: billing.v1.ListPaymentsRequest
+	(*ListPaymentsResponse)(nil),   // 11: billing.v1.ListPaymentsResponse
+	(*ListSchedulesRequest)(nil),   // 12: billing.v1.ListSchedulesRequest
+	(*ListSchedulesResponse)(nil),  // 13: billing.v1.ListSchedulesResponse
+	(*StreamPaymentsRequest)(nil),  // 14: billing.v1.StreamPaymentsRequest
+	(*StreamSchedulesRequest)(nil), // 15: billing.v1.StreamSchedulesRequest
+	(*timestamppb.Timestamp)(nil),  // 16: google.protobuf.Timestamp
+}
+var file_billing_v1_billing_proto_depIdxs = []int32{
+	16, // 0: billing.v1.Loan.created_at:type_name -> google.protobuf.Timestamp
+	16, // 1: billing.v1.Payment.paid_at:type_name -> google.protobuf.Timestamp
+	2,  // 2: billing.v1.ListPaymentsResponse.payments:type_name -> billing.v1.Payment
+	1,  // 3: billing.v1.ListSchedulesResponse.schedules:type_name -> billing.v1.Schedule
+	3,  // 4: billing.v1.BillingService.SubmitLoan:input_type -> billing.v1.SubmitLoanRequest
+	5,  // 5: billing.v1.BillingService.GetLoan:input_type -> billing.v1.GetLoanRequest
+	6,  // 6: billing.v1.BillingService.GetOutstanding:input_type -> billing.v1.GetOutstandingRequest
+	8,  // 7: billing.v1.BillingService.SubmitPayment:input_type -> billing.v1.SubmitPaymentRequest
+	10, // 8: billing.v1.BillingService.ListPayments:input_type -> billing.v1.ListPaymentsRequest
+	12, // 9: billing.v1.BillingService.ListSchedules:input_type -> billing.v1.ListSchedulesRequest
+	14, // 10: billing.v1.BillingService.StreamPayments:input_type -> billing.v1.StreamPaymentsRequest
+	15, // 11: billing.v1.BillingService.StreamSchedules:input_type -> billing.v1.StreamSchedulesRequest
+	4,  // 12: billing.v1.BillingService.SubmitLoan:output_type -> billing.v1.SubmitLoanResponse
+	0,  // 13: billing.v1.BillingService.GetLoan:output_type -> billing.v1.Loan
+	7,  // 14: billing.v1.BillingService.GetOutstanding:output_type -> billing.v1.GetOutstandingResponse
+	9,  // 15: billing.v1.BillingService.SubmitPayment:output_type -> billing.v1.SubmitPaymentResponse
+	11, // 16: billing.v1.BillingService.ListPayments:output_type -> billing.v1.ListPaymentsResponse
+	13, // 17: billing.v1.BillingService.ListSchedules:output_type -> billing.v1.ListSchedulesResponse
+	2,  // 18: billing.v1.BillingService.StreamPayments:output_type -> billing.v1.Payment
+	1,  // 19: billing.v1.BillingService.StreamSchedules:output_type -> billing.v1.Schedule
+	12, // [12:20] is the sub-list for method output_type
+	4,  // [4:12] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_billing_v1_billing_proto_init() }
+func file_billing_v1_billing_proto_init() {
+	if File_billing_v1_billing_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_billing_v1_billing_proto_rawDesc), len(file_billing_v1_billing_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_billing_v1_billing_proto_goTypes,
+		DependencyIndexes: file_billing_v1_billing_proto_depIdxs,
+		MessageInfos:      file_billing_v1_billing_proto_msgTypes,
+	}.Build()
+	File_billing_v1_billing_proto = out.File
+	file_billing_v1_billing_proto_goTypes = nil
+	file_billing_v1_billing_proto_depIdxs = nil
+}
@@ -0,0 +1,414 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: billing/v1/billing.proto
+
+package billingv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BillingService_SubmitLoan_FullMethodName      = "/billing.v1.BillingService/SubmitLoan"
+	BillingService_GetLoan_FullMethodName         = "/billing.v1.BillingService/GetLoan"
+	BillingService_GetOutstanding_FullMethodName  = "/billing.v1.BillingService/GetOutstanding"
+	BillingService_SubmitPayment_FullMethodName   = "/billing.v1.BillingService/SubmitPayment"
+	BillingService_ListPayments_FullMethodName    = "/billing.v1.BillingService/ListPayments"
+	BillingService_ListSchedules_FullMethodName   = "/billing.v1.BillingService/ListSchedules"
+	BillingService_StreamPayments_FullMethodName  = "/billing.v1.BillingService/StreamPayments"
+	BillingService_StreamSchedules_FullMethodName = "/billing.v1.BillingService/StreamSchedules"
+)
+
+// BillingServiceClient is the client API for BillingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// BillingService is the gRPC counterpart of the /loan REST routes, both call the same BillingService of the service layer.
+//
+// Amounts are integers in the smallest currency unit, dates without a time are YYYY-MM-DD strings like in the REST API.
+// Failed calls carry a google.rpc.ErrorInfo detail whose reason is the stable error code of the REST error catalog.
+type BillingServiceClient interface {
+	// SubmitLoan creates a loan and its weekly schedule.
+	// An optional x-idempotency-key metadata value makes retries replay the original response.
+	SubmitLoan(ctx context.Context, in *SubmitLoanRequest, opts ...grpc.CallOption) (*SubmitLoanResponse, error)
+	GetLoan(ctx context.Context, in *GetLoanRequest, opts ...grpc.CallOption) (*Loan, error)
+	GetOutstanding(ctx context.Context, in *GetOutstandingRequest, opts ...grpc.CallOption) (*GetOutstandingResponse, error)
+	// SubmitPayment pays the next weekly installment, the x-idempotency-key metadata value is required.
+	SubmitPayment(ctx context.Context, in *SubmitPaymentRequest, opts ...grpc.CallOption) (*SubmitPaymentResponse, error)
+	ListPayments(ctx context.Context, in *ListPaymentsRequest, opts ...grpc.CallOption) (*ListPaymentsResponse, error)
+	ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error)
+	// StreamPayments sends every payment of the loan, oldest first.
+	StreamPayments(ctx context.Context, in *StreamPaymentsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Payment], error)
+	// StreamSchedules sends the whole repayment schedule of the loan, by sequence.
+	StreamSchedules(ctx context.Context, in *StreamSchedulesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Schedule], error)
+}
+
+type billingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBillingServiceClient(cc grpc.ClientConnInterface) BillingServiceClient {
+	return &billingServiceClient{cc}
+}
+
+func (c *billingServiceClient) SubmitLoan(ctx context.Context, in *SubmitLoanRequest, opts ...grpc.CallOption) (*SubmitLoanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitLoanResponse)
+	err := c.cc.Invoke(ctx, BillingService_SubmitLoan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) GetLoan(ctx context.Context, in *GetLoanRequest, opts ...grpc.CallOption) (*Loan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Loan)
+	err := c.cc.Invoke(ctx, BillingService_GetLoan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) GetOutstanding(ctx context.Context, in *GetOutstandingRequest, opts ...grpc.CallOption) (*GetOutstandingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOutstandingResponse)
+	err := c.cc.Invoke(ctx, BillingService_GetOutstanding_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) SubmitPayment(ctx context.Context, in *SubmitPaymentRequest, opts ...grpc.CallOption) (*SubmitPaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitPaymentResponse)
+	err := c.cc.Invoke(ctx, BillingService_SubmitPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) ListPayments(ctx context.Context, in *ListPaymentsRequest, opts ...grpc.CallOption) (*ListPaymentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPaymentsResponse)
+	err := c.cc.Invoke(ctx, BillingService_ListPayments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSchedulesResponse)
+	err := c.cc.Invoke(ctx, BillingService_ListSchedules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *billingServiceClient) StreamPayments(ctx context.Context, in *StreamPaymentsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Payment], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BillingService_ServiceDesc.Streams[0], BillingService_StreamPayments_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamPaymentsRequest, Payment]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BillingService_StreamPaymentsClient = grpc.ServerStreamingClient[Payment]
+
+func (c *billingServiceClient) StreamSchedules(ctx context.Context, in *StreamSchedulesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Schedule], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BillingService_ServiceDesc.Streams[1], BillingService_StreamSchedules_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamSchedulesRequest, Schedule]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BillingService_StreamSchedulesClient = grpc.ServerStreamingClient[Schedule]
+
+// BillingServiceServer is the server API for BillingService service.
+// All implementations must embed UnimplementedBillingServiceServer
+// for forward compatibility.
+//
+// BillingService is the gRPC counterpart of the /loan REST routes, both call the same BillingService of the service layer.
+//
+// Amounts are integers in the smallest currency unit, dates without a time are YYYY-MM-DD strings like in the REST API.
+// Failed calls carry a google.rpc.ErrorInfo detail whose reason is the stable error code of the REST error catalog.
+type BillingServiceServer interface {
+	// SubmitLoan creates a loan and its weekly schedule.
+	// An optional x-idempotency-key metadata value makes retries replay the original response.
+	SubmitLoan(context.Context, *SubmitLoanRequest) (*SubmitLoanResponse, error)
+	GetLoan(context.Context, *GetLoanRequest) (*Loan, error)
+	GetOutstanding(context.Context, *GetOutstandingRequest) (*GetOutstandingResponse, error)
+	// SubmitPayment pays the next weekly installment, the x-idempotency-key metadata value is required.
+	SubmitPayment(context.Context, *SubmitPaymentRequest) (*SubmitPaymentResponse, error)
+	ListPayments(context.Context, *ListPaymentsRequest) (*ListPaymentsResponse, error)
+	ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error)
+	// StreamPayments sends every payment of the loan, oldest first.
+	StreamPayments(*StreamPaymentsRequest, grpc.ServerStreamingServer[Payment]) error
+	// StreamSchedules sends the whole repayment schedule of the loan, by sequence.
+	StreamSchedules(*StreamSchedulesRequest, grpc.ServerStreamingServer[Schedule]) error
+	mustEmbedUnimplementedBillingServiceServer()
+}
+
+// UnimplementedBillingServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBillingServiceServer struct{}
+
+func (UnimplementedBillingServiceServer) SubmitLoan(context.Context, *SubmitLoanRequest) (*SubmitLoanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitLoan not implemented")
+}
+func (UnimplementedBillingServiceServer) GetLoan(context.Context, *GetLoanRequest) (*Loan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLoan not implemented")
+}
+func (UnimplementedBillingServiceServer) GetOutstanding(context.Context, *GetOutstandingRequest) (*GetOutstandingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOutstanding not implemented")
+}
+func (UnimplementedBillingServiceServer) SubmitPayment(context.Context, *SubmitPaymentRequest) (*SubmitPaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitPayment not implemented")
+}
+func (UnimplementedBillingServiceServer) ListPayments(context.Context, *ListPaymentsRequest) (*ListPaymentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPayments not implemented")
+}
+func (UnimplementedBillingServiceServer) ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchedules not implemented")
+}
+func (UnimplementedBillingServiceServer) StreamPayments(*StreamPaymentsRequest, grpc.ServerStreamingServer[Payment]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPayments not implemented")
+}
+func (UnimplementedBillingServiceServer) StreamSchedules(*StreamSchedulesRequest, grpc.ServerStreamingServer[Schedule]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSchedules not implemented")
+}
+func (UnimplementedBillingServiceServer) mustEmbedUnimplementedBillingServiceServer() {}
+func (UnimplementedBillingServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeBillingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BillingServiceServer will
+// result in compilation errors.
+type UnsafeBillingServiceServer interface {
+	mustEmbedUnimplementedBillingServiceServer()
+}
+
+func RegisterBillingServiceServer(s grpc.ServiceRegistrar, srv BillingServiceServer) {
+	// If the following call pancis, it indicates UnimplementedBillingServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BillingService_ServiceDesc, srv)
+}
+
+func _BillingService_SubmitLoan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitLoanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).SubmitLoan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_SubmitLoan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).SubmitLoan(ctx, req.(*SubmitLoanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_GetLoan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLoanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).GetLoan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_GetLoan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).GetLoan(ctx, req.(*GetLoanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_GetOutstanding_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOutstandingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).GetOutstanding(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_GetOutstanding_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).GetOutstanding(ctx, req.(*GetOutstandingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_SubmitPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitPaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).SubmitPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_SubmitPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).SubmitPayment(ctx, req.(*SubmitPaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_ListPayments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPaymentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).ListPayments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_ListPayments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).ListPayments(ctx, req.(*ListPaymentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_ListSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BillingServiceServer).ListSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BillingService_ListSchedules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BillingServiceServer).ListSchedules(ctx, req.(*ListSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BillingService_StreamPayments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamPaymentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BillingServiceServer).StreamPayments(m, &grpc.GenericServerStream[StreamPaymentsRequest, Payment]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BillingService_StreamPaymentsServer = grpc.ServerStreamingServer[Payment]
+
+func _BillingService_StreamSchedules_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSchedulesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BillingServiceServer).StreamSchedules(m, &grpc.GenericServerStream[StreamSchedulesRequest, Schedule]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BillingService_StreamSchedulesServer = grpc.ServerStreamingServer[Schedule]
+
+// BillingService_ServiceDesc is the grpc.ServiceDesc for BillingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BillingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "billing.v1.BillingService",
+	HandlerType: (*BillingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitLoan",
+			Handler:    _BillingService_SubmitLoan_Handler,
+		},
+		{
+			MethodName: "GetLoan",
+			Handler:    _BillingService_GetLoan_Handler,
+		},
+		{
+			MethodName: "GetOutstanding",
+			Handler:    _BillingService_GetOutstanding_Handler,
+		},
+		{
+			MethodName: "SubmitPayment",
+			Handler:    _BillingService_SubmitPayment_Handler,
+		},
+		{
+			MethodName: "ListPayments",
+			Handler:    _BillingService_ListPayments_Handler,
+		},
+		{
+			MethodName: "ListSchedules",
+			Handler:    _BillingService_ListSchedules_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPayments",
+			Handler:       _BillingService_StreamPayments_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamSchedules",
+			Handler:       _BillingService_StreamSchedules_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "billing/v1/billing.proto",
+}
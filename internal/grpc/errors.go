@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the google.rpc.ErrorInfo details, its reason is the code of the REST error catalog
+const errorDomain = "billing-api"
+
+// domainErrorStatus maps a domain error to its gRPC code, an empty message exposes the error message as is
+type domainErrorStatus struct {
+	err     error
+	code    codes.Code
+	logMsg  string
+	message string
+}
+
+var domainErrorStatuses = []domainErrorStatus{
+	{domain.ErrLoanNotFound, codes.NotFound, "loan_not_found", "Loan not found"},
+	{domain.ErrInvalidLoanTerms, codes.InvalidArgument, "invalid_loan_terms", ""},
+	{domain.ErrInvalidPayment, codes.InvalidArgument, "invalid_payment_amount", "Invalid payment amount"},
+	{domain.ErrLoanAlreadyClosed, codes.FailedPrecondition, "loan_already_closed", "Loan already closed"},
+	{domain.ErrDuplicatePayment, codes.AlreadyExists, "payment_already_processed", "Idempotency key already used by a payment of another loan"},
+	{domain.ErrConcurrentPayment, codes.Aborted, "concurrent_payment", "Another payment for this loan was processed concurrently"},
+	{domain.ErrScheduleNotFound, codes.Internal, "schedule_not_found", "Schedule not found"},
+	{domain.ErrIdempotencyKeyMismatch, codes.FailedPrecondition, "idempotency_key_mismatch", "Idempotency key already used with a different request"},
+	{domain.ErrIdempotencyKeyInFlight, codes.Aborted, "idempotency_key_in_flight", "A request with this idempotency key is still in progress"},
+	{domain.ErrDelinquencyCheck, codes.Internal, "logic_error", "Failed to compute loan deliquency"},
+	{domain.ErrInvalidStateOutstanding, codes.Internal, "invalid_outstanding_state", "Invalid loan payment state"},
+	{domain.ErrPermissionDenied, codes.PermissionDenied, "permission_denied", ""},
+}
+
+/*
+toStatusError turns the error of a call into a status error, the gRPC counterpart of Handler.HandleError.
+
+Status errors built by the server itself are passed through, domain errors get their code and a
+google.rpc.ErrorInfo detail with the stable error code, anything else is an internal error.
+*/
+func toStatusError(ctx context.Context, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "repo-timeout") {
+		logError(ctx, method, "request_timeout", err)
+		return newStatus(ctx, codes.DeadlineExceeded, problem.CodeDatabaseTimeout, "Database timeout").Err()
+	}
+
+	for _, s := range domainErrorStatuses {
+		if !errors.Is(err, s.err) {
+			continue
+		}
+		logError(ctx, method, s.logMsg, err)
+		message := s.message
+		if message == "" {
+			message = err.Error()
+		}
+		code, _ := domain.ErrorCode(s.err)
+		return newStatus(ctx, s.code, code, message).Err()
+	}
+
+	logError(ctx, method, "internal_server_error", err)
+	return newStatus(ctx, codes.Internal, problem.CodeInternalError, "Internal server error").Err()
+}
+
+// newStatus builds a status carrying the error code and the request ID as an ErrorInfo detail
+func newStatus(ctx context.Context, code codes.Code, reason, message string) *status.Status {
+	s := status.New(code, message)
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain}
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		info.Metadata = map[string]string{"request_id": reqID}
+	}
+	if withDetails, err := s.WithDetails(info); err == nil {
+		return withDetails
+	}
+	return s
+}
+
+// invalidArgument reports invalid request fields at once, like the validation_failed problem of the REST API
+func invalidArgument(ctx context.Context, violations ...*errdetails.BadRequest_FieldViolation) error {
+	reason := problem.CodeInvalidRequest
+	if len(violations) > 0 {
+		reason = problem.CodeValidationFailed
+	}
+	s := newStatus(ctx, codes.InvalidArgument, reason, "Request validation failed")
+	if withDetails, err := s.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		s = withDetails
+	}
+	return s.Err()
+}
+
+func fieldViolation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+func logError(ctx context.Context, method, msg string, err error) {
+	slog.ErrorContext(ctx, msg,
+		slog.String("grpc_method", method),
+		slog.Any("err", err),
+	)
+}
@@ -0,0 +1,122 @@
+package grpc
+
+import (
+	"billing-api/internal/contextkey"
+	"billing-api/internal/domain"
+	"billing-api/internal/grpc/billingv1"
+	"billing-api/internal/http/problem"
+	"billing-api/internal/service"
+	"context"
+	"errors"
+	"log/slog"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	idempotencyKeyMetadata      = "x-idempotency-key"
+	idempotencyReplayedMetadata = "idempotent-replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// stored responses are the marshaled response message, or the marshaled google.rpc.Status of a failed call
+	idempotentContentType = "application/grpc+proto"
+)
+
+// idempotentMethods are the calls whose responses are stored for replays, with the type of their response
+var idempotentMethods = map[string]func() proto.Message{
+	billingv1.BillingService_SubmitLoan_FullMethodName:    func() proto.Message { return &billingv1.SubmitLoanResponse{} },
+	billingv1.BillingService_SubmitPayment_FullMethodName: func() proto.Message { return &billingv1.SubmitPaymentResponse{} },
+}
+
+/*
+unaryIdempotency is the gRPC counterpart of the REST idempotency middleware and shares its key store.
+
+The key is read from the x-idempotency-key metadata and put in the context for the handlers. A retry with the same
+key and request gets the stored response with the idempotent-replayed header, a reused key with a different
+request fails with FailedPrecondition and a duplicate arriving while the original still runs with Aborted.
+Server errors are not stored, the key is released so the client can retry.
+*/
+func unaryIdempotency(svc *service.IdempotencyService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		newResponse, ok := idempotentMethods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		key := firstMetadata(ctx, idempotencyKeyMetadata)
+		ctx = context.WithValue(ctx, contextkey.IdempotencyKey, key)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, newStatus(ctx, codes.InvalidArgument, problem.CodeInvalidRequest, "Idempotency key too long").Err()
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, toStatusError(ctx, info.FullMethod, err)
+		}
+
+		record, err := svc.Begin(ctx, key, service.FingerprintRequest("GRPC", info.FullMethod, body))
+		switch {
+		case errors.Is(err, domain.ErrIdempotencyKeyMismatch), errors.Is(err, domain.ErrIdempotencyKeyInFlight):
+			return nil, toStatusError(ctx, info.FullMethod, err)
+		case err != nil:
+			slog.ErrorContext(ctx, "idempotency_begin_failed", slog.Any("err", err))
+			return nil, newStatus(ctx, codes.Internal, problem.CodeInternalError, "Internal server error").Err()
+		case record != nil:
+			_ = grpc.SetHeader(ctx, metadata.Pairs(idempotencyReplayedMetadata, "true"))
+			return replayResponse(record.ResponseStatus, record.ResponseBody, newResponse())
+		}
+
+		resp, err := handler(ctx, req)
+
+		// the outcome must be stored even when the client already hung up
+		storeCtx := context.WithoutCancel(ctx)
+		code := status.Code(err)
+		var storeErr error
+		switch {
+		case isServerError(code):
+			storeErr = svc.Release(storeCtx, key)
+		case err != nil:
+			stored, _ := proto.Marshal(status.Convert(err).Proto())
+			storeErr = svc.Complete(storeCtx, key, int(code), idempotentContentType, stored)
+		default:
+			stored, _ := proto.Marshal(resp.(proto.Message))
+			storeErr = svc.Complete(storeCtx, key, int(codes.OK), idempotentContentType, stored)
+		}
+		if storeErr != nil {
+			slog.ErrorContext(ctx, "idempotency_store_failed", slog.Any("err", storeErr))
+		}
+		return resp, err
+	}
+}
+
+func replayResponse(code int, body []byte, resp proto.Message) (any, error) {
+	if codes.Code(code) != codes.OK {
+		var s spb.Status
+		if err := proto.Unmarshal(body, &s); err != nil {
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+		return nil, status.ErrorProto(&s)
+	}
+	if err := proto.Unmarshal(body, resp); err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+	return resp, nil
+}
+
+// isServerError tells the codes that mean the call may succeed when retried as is, the 5xx of gRPC
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented, codes.Canceled:
+		return true
+	}
+	return false
+}
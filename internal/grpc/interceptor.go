@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const requestIDMetadata = "x-request-id"
+
+// the interceptors mirror the middleware stack of the REST router: request ID, logging, recovery and error mapping
+
+/*
+withRequestID puts the caller's x-request-id, or a new one, where middleware.GetReqID and the logger find it,
+and records it for the audit log together with the address of the peer.
+*/
+func withRequestID(ctx context.Context) context.Context {
+	reqID := firstMetadata(ctx, requestIDMetadata)
+	if reqID == "" {
+		reqID = fmt.Sprintf("grpc-%06d", middleware.NextRequestID())
+	}
+	meta := domain.RequestMeta{RequestID: reqID}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.SourceIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.SourceIP); err == nil {
+			meta.SourceIP = host
+		}
+	}
+	ctx = domain.ContextWithRequestMeta(ctx, meta)
+	return context.WithValue(ctx, middleware.RequestIDKey, reqID)
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type loggedPrincipalKey struct{}
+
+// withLoggedPrincipal makes room for the caller found by the auth interceptor, logRequest runs outside of it
+func withLoggedPrincipal(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggedPrincipalKey{}, new(*domain.Principal))
+}
+
+func setLoggedPrincipal(ctx context.Context, p *domain.Principal) {
+	if slot, ok := ctx.Value(loggedPrincipalKey{}).(**domain.Principal); ok {
+		*slot = p
+	}
+}
+
+func logRequest(ctx context.Context, method string, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String("grpc_method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if slot, ok := ctx.Value(loggedPrincipalKey{}).(**domain.Principal); ok && *slot != nil {
+		attrs = append(attrs, slog.String("principal", (*slot).Subject))
+	}
+	slog.LogAttrs(ctx, slog.LevelInfo, "grpc_request", attrs...)
+}
+
+// recovered turns a panic of a handler into an Internal status instead of crashing the server
+func recovered(ctx context.Context, method string, rec any) error {
+	slog.ErrorContext(ctx, "grpc_panic",
+		slog.String("grpc_method", method),
+		slog.Any("panic", rec),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+func unaryRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+func unaryLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx = withLoggedPrincipal(ctx)
+	resp, err := handler(ctx, req)
+	logRequest(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func unaryRecoverer(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = recovered(ctx, info.FullMethod, rec)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// unaryErrors is the centralized error handling, handlers return domain errors as they are
+func unaryErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, toStatusError(ctx, info.FullMethod, err)
+	}
+	return resp, nil
+}
+
+// serverStream replaces the context of a stream, grpc.ServerStream has no WithContext
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamRequestID(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+func streamLogger(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := withLoggedPrincipal(ss.Context())
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	logRequest(ctx, info.FullMethod, start, err)
+	return err
+}
+
+func streamRecoverer(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = recovered(ss.Context(), info.FullMethod, rec)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func streamErrors(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return toStatusError(ss.Context(), info.FullMethod, handler(srv, ss))
+}
@@ -0,0 +1,43 @@
+/*
+Package grpc serves the billing API over gRPC for internal services, next to the REST API and on its own port.
+
+The calls go to the same BillingService as the REST handlers, share the idempotency key store and report domain
+errors with a gRPC code plus the stable error code of the REST error catalog.
+*/
+package grpc
+
+import (
+	"billing-api/internal/config"
+	"billing-api/internal/grpc/billingv1"
+	"billing-api/internal/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+/*
+NewServer registers the billing service, the standard health service and server reflection for grpcurl.
+The billing calls are authenticated like the REST API when auth is enabled.
+*/
+func NewServer(billingService *service.BillingService, idempotencyService *service.IdempotencyService, authService *service.AuthService, cfg *config.Config) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{unaryRequestID, unaryLogger, unaryRecoverer}
+	stream := []grpc.StreamServerInterceptor{streamRequestID, streamLogger, streamRecoverer}
+	if cfg.AuthEnabled {
+		unary = append(unary, unaryAuth(authService))
+		stream = append(stream, streamAuth(authService))
+	}
+	unary = append(unary, unaryIdempotency(idempotencyService), unaryErrors)
+	stream = append(stream, streamErrors)
+
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	billingv1.RegisterBillingServiceServer(s, NewBillingServer(billingService, cfg))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	return s
+}
@@ -0,0 +1,304 @@
+package grpc
+
+import (
+	"billing-api/internal/config"
+	"billing-api/internal/grpc/billingv1"
+	"billing-api/internal/infra/memory"
+	"billing-api/internal/service"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) billingv1.BillingServiceClient {
+	t.Helper()
+	store := memory.NewStore()
+	return startTestServer(t, store, service.NewAuthService(memory.NewAPIKeyRepo(store), nil, service.DefaultPolicy(), nil), &config.Config{PagingLimitDefault: 10, PagingLimitMax: 20})
+}
+
+func startTestServer(t *testing.T, store *memory.Store, authService *service.AuthService, cfg *config.Config) billingv1.BillingServiceClient {
+	t.Helper()
+
+	tenants := service.DefaultTenants(service.TenantSettings{PagingLimitDefault: cfg.PagingLimitDefault, PagingLimitMax: cfg.PagingLimitMax, DelinquencyGapWeeks: 2})
+	billingService := service.NewBillingService(nil, memory.NewBillingRepo(store), nil, tenants)
+	idempotencyService := service.NewIdempotencyService(memory.NewIdempotencyRepo(store), time.Hour, time.Minute)
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(billingService, idempotencyService, authService, cfg)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return billingv1.NewBillingServiceClient(conn)
+}
+
+func withIdempotencyKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), idempotencyKeyMetadata, key)
+}
+
+// assertStatus checks the gRPC code and the error code carried in the ErrorInfo detail
+func assertStatus(t *testing.T, err error, code codes.Code, reason string) *status.Status {
+	t.Helper()
+	s, ok := status.FromError(err)
+	require.True(t, ok, "not a status error: %v", err)
+	assert.Equal(t, code, s.Code(), s.Message())
+	for _, d := range s.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			assert.Equal(t, reason, info.Reason)
+			assert.Equal(t, errorDomain, info.Domain)
+			assert.NotEmpty(t, info.Metadata["request_id"])
+			return s
+		}
+	}
+	t.Errorf("status has no ErrorInfo detail: %v", s.Details())
+	return s
+}
+
+var testLoan = &billingv1.SubmitLoanRequest{
+	PrincipalAmount:    5_000_000,
+	AnnualInterestRate: 0.1,
+	TotalWeeks:         50,
+	StartDate:          "2026-01-05",
+}
+
+func TestBillingServer_SubmitLoan(t *testing.T) {
+	client := newTestClient(t)
+
+	t.Run("created and replayed with the same idempotency key", func(t *testing.T) {
+		first, err := client.SubmitLoan(withIdempotencyKey("loan-1"), testLoan)
+		require.NoError(t, err)
+		assert.Equal(t, int64(110000), first.WeeklyPaymentAmount)
+		assert.Equal(t, int64(5_500_000), first.TotalPayable)
+
+		var header metadata.MD
+		replay, err := client.SubmitLoan(withIdempotencyKey("loan-1"), testLoan, grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Equal(t, first.LoanId, replay.LoanId)
+		assert.Equal(t, []string{"true"}, header.Get(idempotencyReplayedMetadata))
+	})
+
+	t.Run("key reused with a different request", func(t *testing.T) {
+		other := &billingv1.SubmitLoanRequest{PrincipalAmount: 1000, TotalWeeks: 10, StartDate: "2026-01-05"}
+		_, err := client.SubmitLoan(withIdempotencyKey("loan-1"), other)
+		assertStatus(t, err, codes.FailedPrecondition, "idempotency_key_mismatch")
+	})
+
+	t.Run("every invalid field is reported", func(t *testing.T) {
+		_, err := client.SubmitLoan(context.Background(), &billingv1.SubmitLoanRequest{TotalWeeks: 600, StartDate: "05-01-2026", BorrowerId: strings.Repeat("b", 101)})
+		s := assertStatus(t, err, codes.InvalidArgument, "validation_failed")
+
+		var fields []string
+		for _, d := range s.Details() {
+			if br, ok := d.(*errdetails.BadRequest); ok {
+				for _, v := range br.FieldViolations {
+					fields = append(fields, v.Field)
+				}
+			}
+		}
+		assert.ElementsMatch(t, []string{"principal_amount", "total_weeks", "start_date", "borrower_id"}, fields)
+	})
+
+	t.Run("borrower is recorded on the loan", func(t *testing.T) {
+		req := &billingv1.SubmitLoanRequest{PrincipalAmount: 5_000_000, AnnualInterestRate: 0.1, TotalWeeks: 50, StartDate: "2026-01-05", BorrowerId: "borrower-7"}
+		created, err := client.SubmitLoan(context.Background(), req)
+		require.NoError(t, err)
+
+		loan, err := client.GetLoan(context.Background(), &billingv1.GetLoanRequest{LoanId: created.LoanId})
+		require.NoError(t, err)
+		assert.Equal(t, "borrower-7", loan.BorrowerId)
+
+		loan, err = client.GetLoan(context.Background(), &billingv1.GetLoanRequest{LoanId: 1})
+		require.NoError(t, err)
+		assert.Empty(t, loan.BorrowerId)
+	})
+
+	t.Run("loan terms rejected by the service", func(t *testing.T) {
+		_, err := client.SubmitLoan(context.Background(), &billingv1.SubmitLoanRequest{PrincipalAmount: 1001, TotalWeeks: 10, StartDate: "2026-01-05"})
+		assertStatus(t, err, codes.InvalidArgument, "invalid_loan_terms")
+	})
+}
+
+func TestBillingServer_SubmitPayment(t *testing.T) {
+	client := newTestClient(t)
+	loan, err := client.SubmitLoan(context.Background(), testLoan)
+	require.NoError(t, err)
+
+	t.Run("idempotency key is required", func(t *testing.T) {
+		_, err := client.SubmitPayment(context.Background(), &billingv1.SubmitPaymentRequest{LoanId: loan.LoanId, Amount: loan.WeeklyPaymentAmount})
+		assertStatus(t, err, codes.InvalidArgument, "validation_failed")
+	})
+
+	t.Run("paid once and replayed", func(t *testing.T) {
+		req := &billingv1.SubmitPaymentRequest{LoanId: loan.LoanId, Amount: loan.WeeklyPaymentAmount}
+		first, err := client.SubmitPayment(withIdempotencyKey("pay-1"), req)
+		require.NoError(t, err)
+		replay, err := client.SubmitPayment(withIdempotencyKey("pay-1"), req)
+		require.NoError(t, err)
+		assert.Equal(t, first.PaymentId, replay.PaymentId)
+
+		outstanding, err := client.GetOutstanding(context.Background(), &billingv1.GetOutstandingRequest{LoanId: loan.LoanId})
+		require.NoError(t, err)
+		assert.Equal(t, loan.TotalPayable-loan.WeeklyPaymentAmount, outstanding.Outstanding)
+	})
+
+	t.Run("wrong amount is stored and replayed as the same error", func(t *testing.T) {
+		req := &billingv1.SubmitPaymentRequest{LoanId: loan.LoanId, Amount: 1}
+		_, err := client.SubmitPayment(withIdempotencyKey("pay-2"), req)
+		assertStatus(t, err, codes.InvalidArgument, "invalid_payment_amount")
+		_, err = client.SubmitPayment(withIdempotencyKey("pay-2"), req)
+		assertStatus(t, err, codes.InvalidArgument, "invalid_payment_amount")
+	})
+
+	t.Run("unknown loan", func(t *testing.T) {
+		_, err := client.SubmitPayment(withIdempotencyKey("pay-3"), &billingv1.SubmitPaymentRequest{LoanId: 999, Amount: 110000})
+		assertStatus(t, err, codes.NotFound, "loan_not_found")
+	})
+}
+
+func TestBillingServer_ListAndStream(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	loan, err := client.SubmitLoan(ctx, testLoan)
+	require.NoError(t, err)
+	for _, key := range []string{"pay-1", "pay-2", "pay-3"} {
+		_, err := client.SubmitPayment(withIdempotencyKey(key), &billingv1.SubmitPaymentRequest{LoanId: loan.LoanId, Amount: loan.WeeklyPaymentAmount})
+		require.NoError(t, err)
+	}
+
+	t.Run("loan details", func(t *testing.T) {
+		got, err := client.GetLoan(ctx, &billingv1.GetLoanRequest{LoanId: loan.LoanId})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5_000_000), got.PrincipalAmount)
+		assert.Equal(t, int32(50), got.TotalWeeks)
+
+		_, err = client.GetLoan(ctx, &billingv1.GetLoanRequest{LoanId: 999})
+		assertStatus(t, err, codes.NotFound, "loan_not_found")
+	})
+
+	t.Run("schedules are paged with page tokens", func(t *testing.T) {
+		var sequences []int32
+		token := ""
+		for {
+			page, err := client.ListSchedules(ctx, &billingv1.ListSchedulesRequest{LoanId: loan.LoanId, PageSize: 20, PageToken: token})
+			require.NoError(t, err)
+			for _, s := range page.Schedules {
+				sequences = append(sequences, s.Sequence)
+			}
+			if page.NextPageToken == "" {
+				break
+			}
+			token = page.NextPageToken
+		}
+		assert.Len(t, sequences, 50)
+		assert.Equal(t, int32(50), sequences[49])
+
+		_, err := client.ListSchedules(ctx, &billingv1.ListSchedulesRequest{LoanId: loan.LoanId, PageToken: "%%%"})
+		assertStatus(t, err, codes.InvalidArgument, "validation_failed")
+	})
+
+	t.Run("payments page", func(t *testing.T) {
+		page, err := client.ListPayments(ctx, &billingv1.ListPaymentsRequest{LoanId: loan.LoanId, PageSize: 2})
+		require.NoError(t, err)
+		assert.Len(t, page.Payments, 2)
+		require.NotEmpty(t, page.NextPageToken)
+
+		rest, err := client.ListPayments(ctx, &billingv1.ListPaymentsRequest{LoanId: loan.LoanId, PageSize: 2, PageToken: page.NextPageToken})
+		require.NoError(t, err)
+		require.Len(t, rest.Payments, 1)
+		assert.Equal(t, int32(3), rest.Payments[0].WeekNumber)
+	})
+
+	t.Run("streams send every row", func(t *testing.T) {
+		schedules, err := client.StreamSchedules(ctx, &billingv1.StreamSchedulesRequest{LoanId: loan.LoanId})
+		require.NoError(t, err)
+		var paid, total int
+		for {
+			s, err := schedules.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			total++
+			if s.Status == "PAID" {
+				paid++
+			}
+		}
+		assert.Equal(t, 50, total)
+		assert.Equal(t, 3, paid)
+
+		payments, err := client.StreamPayments(ctx, &billingv1.StreamPaymentsRequest{LoanId: loan.LoanId})
+		require.NoError(t, err)
+		var weeks []int32
+		for {
+			p, err := payments.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			weeks = append(weeks, p.WeekNumber)
+		}
+		assert.Equal(t, []int32{1, 2, 3}, weeks)
+	})
+
+	t.Run("stream of an unknown loan", func(t *testing.T) {
+		stream, err := client.StreamPayments(ctx, &billingv1.StreamPaymentsRequest{LoanId: 999})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assertStatus(t, err, codes.NotFound, "loan_not_found")
+	})
+}
+
+func TestBillingServer_Auth(t *testing.T) {
+	store := memory.NewStore()
+	authService := service.NewAuthService(memory.NewAPIKeyRepo(store), nil, service.DefaultPolicy(), nil)
+	writer := "bk_00000000000000aa_grpc-test-writer-secret-of-32-chars"
+	reader := "bk_00000000000000bb_grpc-test-reader-secret-of-32-chars"
+	require.NoError(t, authService.EnsureAPIKey(context.Background(), "writer", writer, []string{"read", "write"}, []string{service.RoleAgent}))
+	require.NoError(t, authService.EnsureAPIKey(context.Background(), "reader", reader, []string{"read"}, []string{service.RoleAgent}))
+	client := startTestServer(t, store, authService, &config.Config{PagingLimitDefault: 10, PagingLimitMax: 20, AuthEnabled: true})
+
+	t.Run("calls without credentials are rejected", func(t *testing.T) {
+		_, err := client.SubmitLoan(withIdempotencyKey("auth-1"), testLoan)
+		assertStatus(t, err, codes.Unauthenticated, "unauthenticated")
+
+		ctx := metadata.AppendToOutgoingContext(withIdempotencyKey("auth-1"), apiKeyMetadata, "bk_00000000000000aa_wrong-secret-of-at-least-32-chars")
+		_, err = client.SubmitLoan(ctx, testLoan)
+		assertStatus(t, err, codes.Unauthenticated, "unauthenticated")
+	})
+
+	t.Run("the write scope is required to submit", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(withIdempotencyKey("auth-2"), apiKeyMetadata, reader)
+		_, err := client.SubmitLoan(ctx, testLoan)
+		assertStatus(t, err, codes.PermissionDenied, "insufficient_scope")
+	})
+
+	t.Run("the API key is accepted in both metadata", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(withIdempotencyKey("auth-3"), authorizationMetadata, "Bearer "+writer)
+		loan, err := client.SubmitLoan(ctx, testLoan)
+		require.NoError(t, err)
+
+		ctx = metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, reader)
+		got, err := client.GetLoan(ctx, &billingv1.GetLoanRequest{LoanId: loan.GetLoanId()})
+		require.NoError(t, err)
+		assert.Equal(t, loan.GetLoanId(), got.GetLoanId())
+	})
+}
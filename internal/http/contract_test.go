@@ -0,0 +1,722 @@
+package http
+
+import (
+	"billing-api/internal/config"
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/memory"
+	"billing-api/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// contractClient sends requests through the router and checks every response against openapi.json
+type contractClient struct {
+	t         *testing.T
+	router    http.Handler
+	spec      *openAPISpec
+	exercised map[string]bool
+	apiKey    string      // sent as X-API-Key unless the request is anonymous or sets its own credentials
+	header    http.Header // of the last response
+	body      string      // of the last response
+}
+
+type contractRequest struct {
+	method string
+	target string
+	body   string
+	header map[string]string
+	// anonymous requests are sent without the API key of the client
+	anonymous bool
+	// stream requests are cancelled shortly after the response started, for the Server-Sent Event endpoints
+	stream bool
+}
+
+func (c *contractClient) do(req contractRequest, wantStatus int) map[string]any {
+	c.t.Helper()
+
+	var body io.Reader
+	if req.body != "" {
+		body = strings.NewReader(req.body)
+	}
+	r := httptest.NewRequest(req.method, req.target, body)
+	if req.body != "" && strings.HasPrefix(req.body, "{") {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if !req.anonymous && req.header["Authorization"] == "" && req.header["X-API-Key"] == "" {
+		r.Header.Set("X-API-Key", c.apiKey)
+	}
+	for k, v := range req.header {
+		r.Header.Set(k, v)
+	}
+
+	ctx := r.Context()
+	if req.stream {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+	}
+	// a route context created up front is kept by chi, so the matched pattern can be read afterwards
+	rctx := chi.NewRouteContext()
+	r = r.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, r)
+
+	operation := req.method + " " + rctx.RoutePattern()
+	c.exercised[operation] = true
+	c.header = rec.Header()
+	c.body = rec.Body.String()
+
+	require.Equal(c.t, wantStatus, rec.Code, "%s %s: %s", req.method, req.target, rec.Body.String())
+	require.NoError(c.t, c.spec.validateResponse(req.method, rctx.RoutePattern(), rec), "%s %s: %s", req.method, req.target, rec.Body.String())
+
+	var decoded map[string]any
+	if strings.Contains(rec.Header().Get("Content-Type"), "json") {
+		_ = json.Unmarshal(rec.Body.Bytes(), &decoded)
+	}
+	return decoded
+}
+
+func (c *contractClient) get(target string, wantStatus int) map[string]any {
+	c.t.Helper()
+	return c.do(contractRequest{method: http.MethodGet, target: target}, wantStatus)
+}
+
+func (c *contractClient) post(target, body string, wantStatus int) map[string]any {
+	c.t.Helper()
+	return c.do(contractRequest{method: http.MethodPost, target: target, body: body}, wantStatus)
+}
+
+func (c *contractClient) delete(target string, wantStatus int) map[string]any {
+	c.t.Helper()
+	return c.do(contractRequest{method: http.MethodDelete, target: target}, wantStatus)
+}
+
+type failingWebhookSender struct{}
+
+func (failingWebhookSender) Send(ctx context.Context, delivery domain.DueWebhookDelivery) domain.WebhookResult {
+	return domain.WebhookResult{StatusCode: http.StatusInternalServerError, Err: errors.New("partner returned 500")}
+}
+
+func id(v any) int64 {
+	n, _ := v.(float64)
+	return int64(n)
+}
+
+// TestOpenAPIContract walks through every operation of the API against the in-memory repositories
+func TestOpenAPIContract(t *testing.T) {
+	spec, err := loadOpenAPISpec()
+	require.NoError(t, err)
+
+	store := memory.NewStore()
+	eventBus := service.NewEventBus(100)
+	tenants, err := service.NewTenants(service.TenantSettings{PagingLimitDefault: 10, PagingLimitMax: 100, DelinquencyGapWeeks: 2}, map[string]service.TenantSettings{"acme": {PagingLimitDefault: 3, PagingLimitMax: 5}})
+	require.NoError(t, err)
+	billingService := service.NewBillingService(nil, memory.NewBillingRepo(store), eventBus, tenants)
+	collectionService := service.NewCollectionService(memory.NewCollectionRepo(store), billingService, service.NewRetryPolicy([]int{3, 7}, nil))
+	webhookRepo := memory.NewWebhookRepo(store)
+	webhookService := service.NewWebhookService(webhookRepo)
+	idempotencyService := service.NewIdempotencyService(memory.NewIdempotencyRepo(store), time.Hour, time.Minute)
+	cfg := &config.Config{
+		PagingLimitDefault:      10,
+		PagingLimitMax:          100,
+		LogLevel:                new(slog.LevelVar),
+		AuthEnabled:             true,
+		AuthAPIKeyRotationGrace: 3600,
+		SlowQueryMs:             200,
+	}
+	jwtSecret := []byte("contract-test-jwt-secret")
+	jwtVerifier, err := service.NewJWTVerifier(service.JWTVerifierOptions{HMACSecret: jwtSecret})
+	require.NoError(t, err)
+	authService := service.NewAuthService(memory.NewAPIKeyRepo(store), jwtVerifier, service.DefaultPolicy(), tenants)
+	adminKey := "bk_00000000000000aa_contract-test-secret-of-32-characters"
+	require.NoError(t, authService.EnsureAPIKey(context.Background(), "contract test", adminKey, []string{domain.ScopeAdmin}, []string{service.RoleAdmin}))
+	readiness := service.NewReadiness(memory.NewHealthRepo(db.SchemaVersion()), nil, service.ReadinessOptions{SchemaVersion: db.SchemaVersion()})
+	// the memory repositories run no query, the statistics are those of a database
+	queryStats := func() []domain.QueryStat {
+		return []domain.QueryStat{
+			{Name: "ListPaymentsByLoanID", Count: 40, Slow: 2, P50: 3 * time.Millisecond, P95: 250 * time.Millisecond, Max: 410 * time.Millisecond},
+			{Name: "GetLoanByID", Count: 120, Errors: 1, P50: 1500 * time.Microsecond, P95: 4 * time.Millisecond, Max: 9 * time.Millisecond},
+		}
+	}
+	deps := RouterDeps{
+		BillingService:     billingService,
+		CollectionService:  collectionService,
+		WebhookService:     webhookService,
+		EventBus:           eventBus,
+		IdempotencyService: idempotencyService,
+		AuthService:        authService,
+		AuditService:       service.NewAuditService(memory.NewAuditRepo(store)),
+	}
+	served := deps
+	served.Readiness = readiness
+	served.QueryStats = queryStats
+	router := NewRouter(served, cfg)
+
+	c := &contractClient{t: t, router: router, spec: spec, exercised: make(map[string]bool), apiKey: adminKey}
+
+	t.Run("meta", func(t *testing.T) {
+		c.t = t
+		c.get("/health", http.StatusOK)
+		c.get("/openapi.json", http.StatusOK)
+		c.get("/docs", http.StatusOK)
+	})
+
+	t.Run("probes", func(t *testing.T) {
+		c.t = t
+		c.get("/livez", http.StatusOK)
+		ready := c.get("/readyz", http.StatusOK)
+		assert.Equal(t, "ready", ready["status"])
+		assert.Len(t, ready["checks"], 3)
+
+		// routers of their own, the readiness of the others stays untouched
+		probe := func(readiness *service.Readiness) *contractClient {
+			probed := *c
+			probed.t = t
+			probedDeps := deps
+			probedDeps.Readiness = readiness
+			probed.router = NewRouter(probedDeps, cfg)
+			return &probed
+		}
+		failedChecks := func(resp map[string]any) []string {
+			var failed []string
+			for _, check := range resp["checks"].([]any) {
+				if check := check.(map[string]any); check["status"] != "ok" {
+					failed = append(failed, check["name"].(string))
+				}
+			}
+			return failed
+		}
+
+		behind := service.NewReadiness(memory.NewHealthRepo(db.SchemaVersion()-1), nil, service.ReadinessOptions{SchemaVersion: db.SchemaVersion()})
+		notReady := probe(behind).get("/readyz", http.StatusServiceUnavailable)
+		assert.Equal(t, "not_ready", notReady["status"])
+		assert.Equal(t, []string{"schema"}, failedChecks(notReady))
+
+		saturated := service.NewReadiness(memory.NewHealthRepo(db.SchemaVersion()), func() service.PoolStats {
+			return service.PoolStats{AcquiredConns: 10, MaxConns: 10}
+		}, service.ReadinessOptions{SchemaVersion: db.SchemaVersion(), MaxPoolUsage: 1})
+		assert.Equal(t, []string{"pool"}, failedChecks(probe(saturated).get("/readyz", http.StatusServiceUnavailable)))
+
+		// not ready as soon as the shutdown starts, alive until the server stops
+		draining := service.NewReadiness(memory.NewHealthRepo(db.SchemaVersion()), nil, service.ReadinessOptions{SchemaVersion: db.SchemaVersion()})
+		draining.Drain()
+		assert.Equal(t, []string{"shutdown"}, failedChecks(probe(draining).get("/readyz", http.StatusServiceUnavailable)))
+		probe(draining).get("/livez", http.StatusOK)
+	})
+
+	var loanID int64
+	var weeklyAmount int64
+	t.Run("loan", func(t *testing.T) {
+		c.t = t
+		loanBody := `{"principal_amount": 5000000, "annual_interest_rate": 0.1, "total_weeks": 50, "start_date": "2026-01-05"}`
+		created := c.do(contractRequest{method: http.MethodPost, target: "/loan", body: loanBody, header: map[string]string{"X-Idempotency-Key": "loan-1"}}, http.StatusCreated)
+		loanID, weeklyAmount = id(created["loan_id"]), id(created["weekly_payment_amount"])
+		assert.Equal(t, int64(110000), weeklyAmount)
+
+		c.do(contractRequest{method: http.MethodPost, target: "/loan", body: loanBody, header: map[string]string{"X-Idempotency-Key": "loan-1"}}, http.StatusCreated)
+		c.do(contractRequest{method: http.MethodPost, target: "/loan", body: `{"principal_amount": 1000, "total_weeks": 10, "start_date": "2026-01-05"}`, header: map[string]string{"X-Idempotency-Key": "loan-1"}}, http.StatusUnprocessableEntity)
+		invalid := c.post("/loan", `{"principal_amount": 0, "total_weeks": 600, "start_date": "05-01-2026"}`, http.StatusBadRequest)
+		assert.Equal(t, "validation_failed", invalid["code"])
+		assert.Len(t, invalid["errors"], 3)
+
+		c.post("/loan", `{"principal_amount": 1000000, "total_weeks": 10, "start_date": "2026-01-05"}`, http.StatusCreated)
+		loans := c.get("/loan?limit=1", http.StatusOK)
+		require.NotNil(t, loans["next_cursor"])
+		c.get("/loan?cursor="+loans["next_cursor"].(string), http.StatusOK)
+		c.get("/loan?cursor=not-a-cursor", http.StatusBadRequest)
+
+		loan := fmt.Sprintf("/loan/%d", loanID)
+		c.get(loan, http.StatusOK)
+		c.get("/loan/999999", http.StatusNotFound)
+		c.get("/loan/abc", http.StatusBadRequest)
+		c.get(loan+"/outstanding", http.StatusOK)
+		c.get("/loan/999999/outstanding", http.StatusNotFound)
+	})
+
+	loan := fmt.Sprintf("/loan/%d", loanID)
+	t.Run("payment", func(t *testing.T) {
+		c.t = t
+		paymentBody := fmt.Sprintf(`{"amount": %d}`, weeklyAmount)
+		c.do(contractRequest{method: http.MethodPost, target: loan + "/payment", body: paymentBody, header: map[string]string{"X-Idempotency-Key": "pay-1"}}, http.StatusCreated)
+		c.do(contractRequest{method: http.MethodPost, target: loan + "/payment", body: paymentBody, header: map[string]string{"X-Idempotency-Key": "pay-1"}}, http.StatusCreated)
+		c.do(contractRequest{method: http.MethodPost, target: loan + "/payment", body: paymentBody, header: map[string]string{"X-Idempotency-Key": "pay-2"}}, http.StatusCreated)
+		c.do(contractRequest{method: http.MethodPost, target: loan + "/payment", body: `{"amount": 1}`, header: map[string]string{"X-Idempotency-Key": "pay-3"}}, http.StatusBadRequest)
+		c.do(contractRequest{method: http.MethodPost, target: "/loan/999999/payment", body: paymentBody, header: map[string]string{"X-Idempotency-Key": "pay-4"}}, http.StatusNotFound)
+		c.post(loan+"/payment", paymentBody, http.StatusBadRequest)
+
+		page := c.get(loan+"/payment?limit=1", http.StatusOK)
+		require.NotNil(t, page["next_cursor"])
+		c.get(loan+"/payment?cursor="+page["next_cursor"].(string), http.StatusOK)
+		c.get(loan+"/payment?limit=abc", http.StatusBadRequest)
+		c.get(loan+"/payment?cursor=not-a-cursor", http.StatusBadRequest)
+
+		schedules := c.get(loan+"/schedule?limit=5", http.StatusOK)
+		require.NotNil(t, schedules["next_cursor"])
+		c.get(loan+"/schedule?cursor="+schedules["next_cursor"].(string), http.StatusOK)
+		c.get(loan+"/schedule?limit=abc", http.StatusBadRequest)
+	})
+
+	t.Run("statement", func(t *testing.T) {
+		c.t = t
+		period := "from=2026-01-01&to=2026-03-31"
+		c.get(loan+"/statement?"+period, http.StatusOK)
+		c.get(loan+"/statement?format=csv&"+period, http.StatusOK)
+		c.get(loan+"/statement?format=html&"+period, http.StatusOK)
+		c.get(loan+"/statement?format=xml", http.StatusBadRequest)
+		c.get(loan+"/statement?from=2026-03-01&to=2026-01-01", http.StatusBadRequest)
+		c.get("/loan/999999/statement", http.StatusNotFound)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		c.t = t
+		c.do(contractRequest{method: http.MethodGet, target: loan + "/events", stream: true}, http.StatusOK)
+		c.do(contractRequest{method: http.MethodGet, target: loan + "/events", header: map[string]string{"Last-Event-ID": "x"}}, http.StatusBadRequest)
+		c.get("/loan/999999/events", http.StatusNotFound)
+		c.do(contractRequest{method: http.MethodGet, target: "/loan/admin/events", stream: true, header: map[string]string{"Last-Event-ID": "1"}}, http.StatusOK)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		c.t = t
+		c.post("/loan/admin/log-level?level=debug", "", http.StatusOK)
+		c.post("/loan/admin/log-level?level=verbose", "", http.StatusBadRequest)
+	})
+
+	t.Run("collection", func(t *testing.T) {
+		c.t = t
+		mandateBody := `{"account_holder": "Jane Doe", "bank_code": "BCA", "account_number": "1234567890", "reference": "MDT-1"}`
+		c.get(loan+"/mandate", http.StatusNotFound)
+		c.post(loan+"/mandate", mandateBody, http.StatusCreated)
+		c.post(loan+"/mandate", mandateBody, http.StatusConflict)
+		c.post(loan+"/mandate", `{"account_holder": ""}`, http.StatusBadRequest)
+		c.post("/loan/999999/mandate", mandateBody, http.StatusNotFound)
+		c.get(loan+"/mandate", http.StatusOK)
+
+		created := c.post("/collection/run?date=2026-02-02", "", http.StatusCreated)
+		c.post("/collection/run?date=2026-02-02", "", http.StatusOK)
+		c.post("/collection/run?date=yesterday", "", http.StatusBadRequest)
+
+		batch := fmt.Sprintf("/collection/batch/%d", id(created["batch_id"]))
+		detail := c.get(batch, http.StatusOK)
+		c.get("/collection/batch/999999", http.StatusNotFound)
+		c.get(batch+"/export", http.StatusOK)
+		c.get(batch+"/export?format=fixed", http.StatusOK)
+		c.get(batch+"/export?format=xml", http.StatusBadRequest)
+
+		items := detail["items"].([]any)
+		require.NotEmpty(t, items)
+		require.Len(t, items, 2)
+		result := "item_id,status,reason_code\n"
+		for _, item := range items {
+			result += fmt.Sprintf("%d,SUCCESS,\n", id(item.(map[string]any)["item_id"]))
+		}
+		c.post(batch+"/result", "item_id,status\nabc,MAYBE\n", http.StatusBadRequest)
+		// the first file settles one item, the batch stays open for the second
+		partial := c.post(batch+"/result", fmt.Sprintf("%d,SUCCESS\n", id(items[0].(map[string]any)["item_id"])), http.StatusOK)
+		assert.EqualValues(t, 1, partial["pending"])
+		c.post(batch+"/result", result, http.StatusOK)
+		c.post(batch+"/result", result, http.StatusConflict)
+
+		c.delete(loan+"/mandate", http.StatusOK)
+		c.delete(loan+"/mandate", http.StatusNotFound)
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		c.t = t
+		created := c.post("/webhook/subscription", `{"url": "https://partner.example.com/hook", "event_types": ["LoanCreated", "PaymentReceived"]}`, http.StatusCreated)
+		assert.NotEmpty(t, created["secret"])
+		c.post("/webhook/subscription", `{"url": "not a url", "event_types": []}`, http.StatusBadRequest)
+		c.post("/webhook/subscription", `{"url": "https://partner.example.com/hook", "event_types": ["LoanDeleted"]}`, http.StatusBadRequest)
+
+		subscription := fmt.Sprintf("/webhook/subscription/%d", id(created["subscription_id"]))
+		c.get("/webhook/subscription", http.StatusOK)
+		c.get(subscription, http.StatusOK)
+		c.get("/webhook/subscription/999999", http.StatusNotFound)
+
+		for _, event := range store.OutboxEvents() {
+			require.NoError(t, webhookService.Publish(context.Background(), event))
+		}
+		deliveries := c.get(subscription+"/delivery?status=PENDING&limit=1", http.StatusOK)
+		require.NotNil(t, deliveries["next_cursor"])
+		c.get(subscription+"/delivery?cursor="+deliveries["next_cursor"].(string), http.StatusOK)
+		c.get(subscription+"/delivery?status=LOST", http.StatusBadRequest)
+
+		// a single failed attempt moves the delivery to the dead-letter list
+		dispatcher := service.NewWebhookDispatcher(webhookRepo, failingWebhookSender{}, service.WebhookDispatcherOptions{
+			BatchSize:   1,
+			MaxAttempts: 1,
+			BaseBackoff: time.Second,
+			MaxBackoff:  time.Minute,
+		})
+		processed, err := dispatcher.DispatchOnce(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 1, processed)
+
+		dead := c.get("/webhook/dead-letter?limit=10", http.StatusOK)
+		require.Len(t, dead["deliveries"], 1)
+		delivery := fmt.Sprintf("/webhook/delivery/%d", id(dead["deliveries"].([]any)[0].(map[string]any)["delivery_id"]))
+		c.get(delivery, http.StatusOK)
+		c.get("/webhook/delivery/999999", http.StatusNotFound)
+		c.get("/webhook/dead-letter?limit=abc", http.StatusBadRequest)
+
+		c.post(delivery+"/redeliver", "", http.StatusAccepted)
+		c.post(delivery+"/redeliver", "", http.StatusConflict)
+		c.post("/webhook/delivery/999999/redeliver", "", http.StatusNotFound)
+
+		c.delete(subscription, http.StatusOK)
+		c.delete(subscription, http.StatusNotFound)
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		c.t = t
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", anonymous: true}, http.StatusUnauthorized)
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: map[string]string{"X-API-Key": "bk_00000000000000aa_wrong-secret-of-at-least-32-chars"}}, http.StatusUnauthorized)
+
+		created := c.post("/admin/api-key", `{"name": "reporting", "scopes": ["read"], "roles": ["agent"]}`, http.StatusCreated)
+		readKey, _ := created["key"].(string)
+		require.NotEmpty(t, readKey)
+		c.post("/admin/api-key", `{"name": "reporting", "scopes": ["read"], "roles": ["auditor"]}`, http.StatusBadRequest)
+
+		asReader := map[string]string{"X-API-Key": readKey}
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: asReader}, http.StatusOK)
+		c.do(contractRequest{method: http.MethodPost, target: "/loan/admin/log-level?level=info", header: asReader}, http.StatusForbidden)
+		denied := c.do(contractRequest{method: http.MethodGet, target: "/admin/api-key", header: asReader}, http.StatusForbidden)
+		assert.Equal(t, "permission_denied", denied["code"])
+		assert.Equal(t, "admin:config", denied["required_permission"])
+
+		bearer := func(sub string, roles ...string) map[string]string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub":   sub,
+				"scope": "read write",
+				"roles": roles,
+				"exp":   time.Now().Add(time.Hour).Unix(),
+			}).SignedString(jwtSecret)
+			require.NoError(t, err)
+			return map[string]string{"Authorization": "Bearer " + token}
+		}
+		agent := bearer("operator@example.com", "agent")
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: agent}, http.StatusOK)
+		denied = c.do(contractRequest{method: http.MethodPost, target: "/loan/admin/log-level?level=info", header: agent}, http.StatusForbidden)
+		assert.Equal(t, "admin:log_level", denied["required_permission"])
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: bearer("nobody@example.com")}, http.StatusForbidden)
+
+		// a borrower only sees the loans created with its subject as borrower_id
+		owned := c.do(contractRequest{method: http.MethodPost, target: "/loan", body: `{"principal_amount": 1000000, "total_weeks": 10, "start_date": "2026-01-05", "borrower_id": "borrower-1"}`, header: map[string]string{"X-Idempotency-Key": "loan-borrower-1"}}, http.StatusCreated)
+		ownedLoan := fmt.Sprintf("/loan/%d", id(owned["loan_id"]))
+		borrower := bearer("borrower-1", "borrower")
+		loans := c.do(contractRequest{method: http.MethodGet, target: "/loan", header: borrower}, http.StatusOK)
+		require.Len(t, loans["loans"], 1)
+		assert.Equal(t, "borrower-1", loans["loans"].([]any)[0].(map[string]any)["borrower_id"])
+		c.do(contractRequest{method: http.MethodGet, target: ownedLoan, header: borrower}, http.StatusOK)
+		c.do(contractRequest{method: http.MethodGet, target: ownedLoan + "/schedule", header: borrower}, http.StatusOK)
+		c.do(contractRequest{method: http.MethodGet, target: ownedLoan, header: bearer("borrower-2", "borrower")}, http.StatusForbidden)
+		c.do(contractRequest{method: http.MethodGet, target: loan, header: borrower}, http.StatusForbidden)
+		c.do(contractRequest{method: http.MethodPost, target: ownedLoan + "/payment", body: `{"amount": 100000}`, header: borrower}, http.StatusForbidden)
+
+		keys := c.get("/admin/api-key", http.StatusOK)
+		assert.Len(t, keys["api_keys"], 2)
+
+		keyID := fmt.Sprint(id(created["api_key_id"]))
+		rotated := c.post("/admin/api-key/"+keyID+"/rotate?grace_seconds=0", "", http.StatusCreated)
+		assert.Equal(t, created["api_key_id"], rotated["rotated_from"])
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: asReader}, http.StatusUnauthorized)
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: map[string]string{"Authorization": "Bearer " + rotated["key"].(string)}}, http.StatusOK)
+		c.post("/admin/api-key/"+keyID+"/rotate", "", http.StatusNotFound)
+		c.post("/admin/api-key/abc/rotate", "", http.StatusBadRequest)
+
+		rotatedID := fmt.Sprint(id(rotated["api_key_id"]))
+		c.delete("/admin/api-key/"+rotatedID, http.StatusOK)
+		c.delete("/admin/api-key/"+rotatedID, http.StatusNotFound)
+		c.delete("/admin/api-key/abc", http.StatusBadRequest)
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: map[string]string{"X-API-Key": rotated["key"].(string)}}, http.StatusUnauthorized)
+	})
+
+	t.Run("tenants", func(t *testing.T) {
+		c.t = t
+		asTenant := func(tenantID string) map[string]string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub":       "operator@" + tenantID + ".example.com",
+				"scope":     "admin",
+				"roles":     []string{"admin"},
+				"tenant_id": tenantID,
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			}).SignedString(jwtSecret)
+			require.NoError(t, err)
+			return map[string]string{"Authorization": "Bearer " + token}
+		}
+		acme := asTenant("acme")
+		c.do(contractRequest{method: http.MethodGet, target: "/loan", header: asTenant("globex")}, http.StatusUnauthorized)
+
+		// the loans of the default tenant are not found by acme, and the other way around
+		loans := c.do(contractRequest{method: http.MethodGet, target: "/loan", header: acme}, http.StatusOK)
+		assert.Empty(t, loans["loans"])
+		c.do(contractRequest{method: http.MethodGet, target: loan, header: acme}, http.StatusNotFound)
+		c.do(contractRequest{method: http.MethodPost, target: loan + "/payment", body: `{"amount": 110000}`, header: map[string]string{"Authorization": acme["Authorization"], "X-Idempotency-Key": "acme-payment-1"}}, http.StatusNotFound)
+
+		created := c.do(contractRequest{method: http.MethodPost, target: "/loan", body: `{"principal_amount": 1000000, "total_weeks": 10, "start_date": "2026-01-05"}`, header: map[string]string{"Authorization": acme["Authorization"], "X-Idempotency-Key": "loan-1"}}, http.StatusCreated)
+		acmeLoan := fmt.Sprintf("/loan/%d", id(created["loan_id"]))
+		c.do(contractRequest{method: http.MethodGet, target: acmeLoan, header: acme}, http.StatusOK)
+		c.get(acmeLoan, http.StatusNotFound)
+
+		// the events of acme only reach the webhooks and the streams of acme
+		subscription := c.do(contractRequest{method: http.MethodPost, target: "/webhook/subscription", body: `{"url": "https://acme.example.com/hook", "event_types": ["LoanCreated"]}`, header: acme}, http.StatusCreated)
+		acmeSubscription := fmt.Sprintf("/webhook/subscription/%d", id(subscription["subscription_id"]))
+		c.get(acmeSubscription, http.StatusNotFound)
+		defaultSubscription := c.post("/webhook/subscription", `{"url": "https://partner.example.com/hook", "event_types": ["LoanCreated"]}`, http.StatusCreated)
+		for _, event := range store.OutboxEvents() {
+			require.NoError(t, webhookService.Publish(context.Background(), event))
+		}
+		deliveries := c.do(contractRequest{method: http.MethodGet, target: acmeSubscription + "/delivery", header: acme}, http.StatusOK)
+		require.Len(t, deliveries["deliveries"], 1)
+		c.get(acmeSubscription+"/delivery", http.StatusNotFound)
+		c.get(fmt.Sprintf("/webhook/delivery/%d", id(deliveries["deliveries"].([]any)[0].(map[string]any)["delivery_id"])), http.StatusNotFound)
+		deliveries = c.get(fmt.Sprintf("/webhook/subscription/%d/delivery", id(defaultSubscription["subscription_id"])), http.StatusOK)
+		assert.NotEmpty(t, deliveries["deliveries"])
+		c.do(contractRequest{method: http.MethodGet, target: "/loan/admin/events", stream: true, header: map[string]string{"Authorization": acme["Authorization"], "Last-Event-ID": "1"}}, http.StatusOK)
+		assert.Contains(t, c.body, `"tenant_id":"acme"`)
+		assert.NotContains(t, c.body, `"tenant_id":"default"`)
+
+		// paging limits of acme: its default of 3, a limit above its max of 5 is refused
+		schedules := c.do(contractRequest{method: http.MethodGet, target: acmeLoan + "/schedule", header: acme}, http.StatusOK)
+		assert.Len(t, schedules["schedules"], 3)
+		c.do(contractRequest{method: http.MethodGet, target: acmeLoan + "/schedule?limit=50", header: acme}, http.StatusBadRequest)
+		schedules = c.do(contractRequest{method: http.MethodGet, target: acmeLoan + "/schedule?limit=5", header: acme}, http.StatusOK)
+		assert.Len(t, schedules["schedules"], 5)
+
+		// the default tenant issues the keys of the other tenants, not the other way around
+		acmeKey := c.post("/admin/api-key", `{"name": "acme reporting", "scopes": ["read"], "roles": ["agent"], "tenant_id": "acme"}`, http.StatusCreated)
+		assert.Equal(t, "acme", acmeKey["tenant_id"])
+		loans = c.do(contractRequest{method: http.MethodGet, target: "/loan", header: map[string]string{"X-API-Key": acmeKey["key"].(string)}}, http.StatusOK)
+		assert.Len(t, loans["loans"], 1)
+		c.post("/admin/api-key", `{"name": "globex reporting", "scopes": ["read"], "roles": ["agent"], "tenant_id": "globex"}`, http.StatusBadRequest)
+		c.do(contractRequest{method: http.MethodPost, target: "/admin/api-key", body: `{"name": "escape", "scopes": ["read"], "roles": ["agent"], "tenant_id": "default"}`, header: acme}, http.StatusForbidden)
+		keys := c.do(contractRequest{method: http.MethodGet, target: "/admin/api-key", header: acme}, http.StatusOK)
+		assert.Len(t, keys["api_keys"], 1)
+
+		// the admin of acme holds "*" but the routes acting on the whole process belong to the operator tenant
+		denied := c.do(contractRequest{method: http.MethodPost, target: "/loan/admin/log-level?level=debug", header: acme}, http.StatusForbidden)
+		assert.Equal(t, "permission_denied", denied["code"])
+		c.do(contractRequest{method: http.MethodGet, target: "/admin/load-shedding", header: acme}, http.StatusForbidden)
+		c.do(contractRequest{method: http.MethodGet, target: "/admin/query-stats", header: acme}, http.StatusForbidden)
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		c.t = t
+		entries := c.get("/admin/audit-log?action=payment.create", http.StatusOK)["entries"].([]any)
+		// the two payments of the payment test and the two posted by the collection imports, under their own key
+		require.Len(t, entries, 4)
+		assert.Contains(t, entries[0].(map[string]any)["idempotency_key"], "direct-debit-")
+		assert.Contains(t, entries[1].(map[string]any)["idempotency_key"], "direct-debit-")
+		payment := entries[2].(map[string]any)
+		assert.Equal(t, "api_key:00000000000000aa", payment["actor"])
+		assert.Equal(t, "pay-2", payment["idempotency_key"])
+		assert.NotEmpty(t, payment["request_id"])
+		assert.NotEmpty(t, payment["source_ip"])
+		assert.Nil(t, payment["before"])
+		assert.NotNil(t, payment["after"])
+
+		// the mandate was created then revoked, the account number is masked in both states
+		mandate := c.get("/admin/audit-log?entity_type=mandate&action=mandate.revoke", http.StatusOK)["entries"].([]any)[0].(map[string]any)
+		assert.Equal(t, "******7890", mandate["before"].(map[string]any)["account_number"])
+		assert.Equal(t, "REVOKED", mandate["after"].(map[string]any)["status"])
+		c.get("/admin/audit-log?action=log_level.change&actor=api_key:00000000000000aa", http.StatusOK)
+
+		page := c.get("/admin/audit-log?limit=2", http.StatusOK)
+		require.NotNil(t, page["next_cursor"])
+		next := c.get("/admin/audit-log?limit=2&cursor="+page["next_cursor"].(string), http.StatusOK)
+		assert.Less(t, id(next["entries"].([]any)[0].(map[string]any)["audit_entry_id"]), id(page["entries"].([]any)[1].(map[string]any)["audit_entry_id"]))
+		assert.Empty(t, c.get("/admin/audit-log?to=2000-01-01T00:00:00Z", http.StatusOK)["entries"])
+		c.get("/admin/audit-log?from=yesterday", http.StatusBadRequest)
+		invalid := c.get("/admin/audit-log?from=2030-01-01T00:00:00Z&to=2020-01-01T00:00:00Z", http.StatusBadRequest)
+		assert.Equal(t, "invalid_audit_query", invalid["code"])
+
+		verified := c.get("/admin/audit-log/verify", http.StatusOK)
+		assert.Equal(t, true, verified["valid"])
+		assert.Greater(t, id(verified["entries"]), int64(10))
+
+		// acme has a chain of its own
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "operator@acme.example.com", "scope": "read", "roles": []string{"finance"}, "tenant_id": "acme", "exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(jwtSecret)
+		require.NoError(t, err)
+		acme := map[string]string{"Authorization": "Bearer " + token}
+		entries = c.do(contractRequest{method: http.MethodGet, target: "/admin/audit-log", header: acme}, http.StatusOK)["entries"].([]any)
+		for _, e := range entries {
+			assert.Equal(t, "operator@acme.example.com", e.(map[string]any)["actor"])
+		}
+		assert.Empty(t, entries[len(entries)-1].(map[string]any)["prev_hash"])
+		c.do(contractRequest{method: http.MethodGet, target: "/admin/audit-log/verify", header: acme}, http.StatusOK)
+
+		agent, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "operator@example.com", "scope": "read", "roles": []string{"agent"}, "exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(jwtSecret)
+		require.NoError(t, err)
+		denied := c.do(contractRequest{method: http.MethodGet, target: "/admin/audit-log", header: map[string]string{"Authorization": "Bearer " + agent}}, http.StatusForbidden)
+		assert.Equal(t, "audit:read", denied["required_permission"])
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		// a router of its own, its tight route limit would get in the way of the other subtests
+		limiter := service.NewRateLimiter(memory.NewRateLimitRepo(store), nil, service.RateLimitRules{
+			PerIP:  domain.RateLimit{Rate: 100, Burst: 100},
+			PerKey: domain.RateLimit{Rate: 100, Burst: 100},
+			Routes: map[string]domain.RateLimit{"GET /loan/{loanID}": {Rate: 0.01, Burst: 1}},
+		})
+		limited := *c
+		limited.t = t
+		limitedDeps := deps
+		limitedDeps.RateLimiter = limiter
+		limited.router = NewRouter(limitedDeps, cfg)
+		loan := fmt.Sprintf("/loan/%d", loanID)
+
+		limited.get(loan, http.StatusOK)
+		assert.Equal(t, "1", limited.header.Get("RateLimit-Limit"))
+		assert.Equal(t, "0", limited.header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "100", limited.header.Get("RateLimit-Reset"))
+
+		limitedResponse := limited.get(loan, http.StatusTooManyRequests)
+		assert.Equal(t, "rate_limited", limitedResponse["code"])
+		assert.Equal(t, "100", limited.header.Get("Retry-After"))
+
+		// the route limit is per caller and the other routes only count against the caller limit
+		limited.get(loan+"/outstanding", http.StatusOK)
+		assert.Equal(t, "98", limited.header.Get("RateLimit-Remaining"))
+		agent, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "operator@example.com", "scope": "read", "roles": []string{"agent"}, "exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(jwtSecret)
+		require.NoError(t, err)
+		limited.do(contractRequest{method: http.MethodGet, target: loan, header: map[string]string{"Authorization": "Bearer " + agent}}, http.StatusOK)
+	})
+
+	t.Run("load shedding", func(t *testing.T) {
+		c.t = t
+		assert.Equal(t, false, c.get("/admin/load-shedding", http.StatusOK)["enabled"])
+
+		shedder := service.NewLoadShedder(nil, service.LoadShedderOptions{InitialLimit: 4, MinLimit: 1, MaxLimit: 4})
+		shedding := *c
+		shedding.t = t
+		sheddingDeps := deps
+		sheddingDeps.LoadShedder = shedder
+		shedding.router = NewRouter(sheddingDeps, cfg)
+
+		// two requests still running fill the share of the reporting reads, not the one of the others
+		for range 2 {
+			release, ok := shedder.Acquire(service.PriorityCritical)
+			require.True(t, ok)
+			defer release()
+		}
+		overloaded := shedding.get("/loan", http.StatusServiceUnavailable)
+		assert.Equal(t, "overloaded", overloaded["code"])
+		assert.Equal(t, "1", shedding.header.Get("Retry-After"))
+		shedding.get(fmt.Sprintf("/loan/%d", loanID), http.StatusOK)
+
+		state := shedding.get("/admin/load-shedding", http.StatusOK)
+		assert.Equal(t, true, state["enabled"])
+		assert.Equal(t, float64(2), state["in_flight"])
+		assert.Equal(t, map[string]any{"critical": float64(0), "normal": float64(0), "low": float64(1)}, state["shed"])
+	})
+
+	t.Run("query stats", func(t *testing.T) {
+		c.t = t
+		stats := c.get("/admin/query-stats", http.StatusOK)
+		assert.Equal(t, float64(200), stats["slow_threshold_ms"])
+		queries := stats["queries"].([]any)
+		require.Len(t, queries, 2)
+		assert.Equal(t, map[string]any{
+			"name": "ListPaymentsByLoanID", "count": float64(40), "errors": float64(0), "slow": float64(2),
+			"p50_ms": float64(3), "p95_ms": float64(250), "max_ms": float64(410),
+		}, queries[0])
+		assert.Equal(t, 1.5, queries[1].(map[string]any)["p50_ms"])
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		c.t = t
+		c.get("/metrics", http.StatusOK)
+
+		// the paths matching no route share a label, not one series each
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/route", nil))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := rec.Body.String()
+		assert.Contains(t, body, `billing_http_request_duration_seconds_count{method="GET",route="/loan/{loanID}",status="200"}`)
+		assert.Contains(t, body, `billing_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"}`)
+		assert.Contains(t, body, `billing_loans_created_total{tenant="default"}`)
+		assert.Contains(t, body, `billing_payments_posted_total{tenant="default"}`)
+	})
+
+	t.Run("tracing", func(t *testing.T) {
+		c.t = t
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		otel.SetTracerProvider(provider)
+		t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+		// the spans are children of the trace of the caller
+		c.do(contractRequest{method: http.MethodGet, target: fmt.Sprintf("/loan/%d", loanID), header: map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		}}, http.StatusOK)
+
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		for _, span := range recorder.Ended() {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), span.Name())
+			spans[span.Name()] = span
+		}
+		chain := []string{"GET /loan/{loanID}", "Handler.RequireLoanAccess", "Handler.GetLoanByID", "BillingService.GetLoanByID"}
+		for _, name := range chain {
+			require.Contains(t, spans, name)
+		}
+		assert.Equal(t, "00f067aa0ba902b7", spans[chain[0]].Parent().SpanID().String())
+		for i := 1; i < len(chain); i++ {
+			assert.Equal(t, spans[chain[i-1]].SpanContext().SpanID(), spans[chain[i]].Parent().SpanID(), chain[i])
+		}
+
+		// a failed handler marks its span
+		recorder.Reset()
+		c.get("/loan/999999", http.StatusNotFound)
+		for _, span := range recorder.Ended() {
+			if span.Name() == "Handler.GetLoanByID" {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			}
+		}
+	})
+
+	t.Run("every route is documented and exercised", func(t *testing.T) {
+		var routes []string
+		err := chi.Walk(router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			route = strings.ReplaceAll(route, "/*/", "/")
+			if route != "/" {
+				route = strings.TrimSuffix(route, "/")
+			}
+			routes = append(routes, method+" "+route)
+			return nil
+		})
+		require.NoError(t, err)
+		slices.Sort(routes)
+
+		assert.Equal(t, routes, spec.operations(), "routes of NewRouter and operations of openapi.json differ")
+		for _, operation := range spec.operations() {
+			assert.True(t, c.exercised[operation], "%s is not exercised by the contract test", operation)
+		}
+	})
+}
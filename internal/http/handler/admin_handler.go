@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"billing-api/internal/domain"
+	"billing-api/internal/service"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,25 +15,66 @@ func (h *Handler) ChangeLogLevel(level *slog.LevelVar) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		newLevel := r.URL.Query().Get("level")
 
+		var next slog.Level
 		switch strings.ToUpper(newLevel) {
 		case "DEBUG":
-			level.Set(slog.LevelDebug)
+			next = slog.LevelDebug
 		case "INFO":
-			level.Set(slog.LevelInfo)
+			next = slog.LevelInfo
 		case "WARN":
-			level.Set(slog.LevelWarn)
+			next = slog.LevelWarn
 		case "ERROR":
-			level.Set(slog.LevelError)
+			next = slog.LevelError
 		default:
 			return BadRequest(fmt.Sprintf("Invalid level : %s", newLevel), errors.New("Invalid level"))
 		}
 
+		// the level lives outside of the database, it is only changed once the change is audited
+		err := h.auditService.Record(r.Context(), domain.AuditActionLogLevelChange, domain.AuditEntityLogLevel, "root",
+			service.LogLevelAuditSnapshot{Level: level.Level().String()},
+			service.LogLevelAuditSnapshot{Level: next.String()},
+		)
+		if err != nil {
+			return err
+		}
+		level.Set(next)
+
 		slog.Debug("Log level changed", slog.String("new_level", strings.ToUpper(newLevel)))
 		slog.Info("Log level changed", slog.String("new_level", strings.ToUpper(newLevel)))
 
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
 		return json.NewEncoder(w).Encode(map[string]string{
 			"status":  "success",
 			"message": fmt.Sprintf("Log level changed to %s", level),
 		})
 	}
 }
+
+// GetLoadShedding reports the concurrency limit of the load shedder and the requests it shed, shedder is nil when disabled
+func (h *Handler) GetLoadShedding(shedder *service.LoadShedder) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		response := LoadSheddingResponse{Shed: map[string]int64{}}
+		if shedder != nil {
+			response = ToLoadSheddingResponse(shedder.State())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetQueryStats reports the count and durations of every statement run since startup, stats is nil without a database
+func (h *Handler) GetQueryStats(stats func() []domain.QueryStat) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var queries []domain.QueryStat
+		if stats != nil {
+			queries = stats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(w).Encode(ToQueryStatsResponse(h.config.SlowQueryMs, queries))
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"billing-api/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateAPIKey issues a key, the response is the only place the key is ever shown
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	var req CreateAPIKeyRequest
+	if err := decodeRequest(r, &req); err != nil {
+		return err
+	}
+
+	apiKey, key, err := h.authService.CreateAPIKey(r.Context(), service.CreateAPIKeyInput{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Roles:     req.Roles,
+		ExpiresAt: req.ExpiresAt,
+		TenantID:  req.TenantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(ToAPIKeyResponse(apiKey, key))
+}
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	keys, err := h.authService.ListAPIKeys(r.Context())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListAPIKeyResponse(keys))
+}
+
+// RotateAPIKey issues the replacement of a key, `grace_seconds` is how long the old key keeps working
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid API key ID", err)
+	}
+	grace := time.Duration(h.config.AuthAPIKeyRotationGrace) * time.Second
+	if s := r.URL.Query().Get("grace_seconds"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 {
+			return InvalidField("grace_seconds", "must be a positive number of seconds", err)
+		}
+		grace = time.Duration(seconds) * time.Second
+	}
+
+	apiKey, key, err := h.authService.RotateAPIKey(r.Context(), keyID, grace)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(ToAPIKeyResponse(apiKey, key))
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid API key ID", err)
+	}
+
+	apiKey, err := h.authService.RevokeAPIKey(r.Context(), keyID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToAPIKeyResponse(apiKey, ""))
+}
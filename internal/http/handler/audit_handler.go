@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/service"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+/*
+ListAuditLog returns the audit log of the tenant newest first.
+`actor`, `action`, `entity_type` and `entity_id` match exactly, `from` (inclusive) and `to` (exclusive) are RFC 3339 timestamps.
+*/
+func (h *Handler) ListAuditLog(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	var q domain.ListAuditEntriesQuery
+	for param, dst := range map[string]**string{
+		"actor":       &q.Actor,
+		"action":      &q.Action,
+		"entity_type": &q.EntityType,
+		"entity_id":   &q.EntityID,
+	} {
+		if v := query.Get(param); v != "" {
+			*dst = &v
+		}
+	}
+	for param, dst := range map[string]**time.Time{
+		"from": &q.From,
+		"to":   &q.To,
+	} {
+		if v := query.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return InvalidField(param, "must be an RFC 3339 timestamp", err)
+			}
+			*dst = &t
+		}
+	}
+
+	limit, err := h.pageLimit(r)
+	if err != nil {
+		return err
+	}
+	q.Limit = int32(limit)
+
+	cursor, err := DecodeCursor[service.AuditCursor](r)
+	if err != nil {
+		return BadRequest("Invalid audit log cursor", err)
+	}
+
+	entries, nextCursor, err := h.auditService.List(r.Context(), q, cursor)
+	if err != nil {
+		return err
+	}
+
+	encodedNextCursor, err := EncodeCursor(nextCursor)
+	if err != nil {
+		return InternalError("Error encoding next cursor", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListAuditEntryResponse(entries, encodedNextCursor))
+}
+
+// VerifyAuditLog checks the hash chain of the tenant, a broken chain is reported in the body rather than as an error
+func (h *Handler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) error {
+	result, err := h.auditService.VerifyChain(r.Context())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToAuditChainVerificationResponse(result))
+}
@@ -11,6 +11,22 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+/*
+RequireLoanAccess guards the routes of a loan: a caller only allowed to read its own loans gets 403 on the
+loans of other borrowers. An invalid loan ID is left to the handler to report.
+*/
+func (h *Handler) RequireLoanAccess(next http.Handler) http.Handler {
+	return h.MakeHandler(func(w http.ResponseWriter, r *http.Request) error {
+		if loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64); err == nil {
+			if err := h.billingService.AuthorizeLoanAccess(r.Context(), loanID); err != nil {
+				return err
+			}
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	})
+}
+
 func (h *Handler) GetLoanByID(w http.ResponseWriter, r *http.Request) error {
 	loanIDStr := chi.URLParam(r, "loanID")
 	loanID, err := strconv.ParseInt(loanIDStr, 10, 64)
@@ -36,6 +52,7 @@ func (h *Handler) GetLoanByID(w http.ResponseWriter, r *http.Request) error {
 		WeeklyPaymentAmount: loan.WeeklyPaymentAmount,
 		TotalPayable:        loan.TotalPayableAmount,
 		TotalWeeks:          loan.TotalWeeks,
+		BorrowerID:          loan.BorrowerID,
 		CreatedAt:           loan.CreatedAt.Format(time.RFC3339),
 		IsDelinquent:        isDelinquent,
 	}
@@ -45,15 +62,42 @@ func (h *Handler) GetLoanByID(w http.ResponseWriter, r *http.Request) error {
 	return json.NewEncoder(w).Encode(resp)
 }
 
+// ListLoans returns the loans newest first, for the back office
+func (h *Handler) ListLoans(w http.ResponseWriter, r *http.Request) error {
+	limit, err := h.pageLimit(r)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := DecodeCursor[service.LoanCursor](r)
+	if err != nil {
+		return BadRequest("Invalid loan cursor", err)
+	}
+
+	loans, nextCursor, err := h.billingService.ListLoans(r.Context(), limit, cursor)
+	if err != nil {
+		return err
+	}
+
+	encodedNextCursor, err := EncodeCursor(nextCursor)
+	if err != nil {
+		return InternalError("Error encoding next cursor", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListLoanResponse(loans, encodedNextCursor))
+}
+
 func (h *Handler) SubmitLoan(w http.ResponseWriter, r *http.Request) error {
 	var req SubmitLoanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return BadRequest("Invalid request body", err)
+	if err := decodeRequest(r, &req); err != nil {
+		return err
 	}
 
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	startDate, err := time.Parse(dateLayout, req.StartDate)
 	if err != nil {
-		return BadRequest("Invalid start_date", err)
+		return InvalidField("start_date", "Invalid start_date, expected YYYY-MM-DD", err)
 	}
 
 	loan, err := h.billingService.SubmitLoan(r.Context(), service.SubmitLoanInput{
@@ -61,6 +105,7 @@ func (h *Handler) SubmitLoan(w http.ResponseWriter, r *http.Request) error {
 		AnnualInterestRate: req.AnnualInterestRate,
 		TotalWeeks:         req.TotalWeeks,
 		StartDate:          startDate,
+		BorrowerID:         req.BorrowerID,
 	})
 	if err != nil {
 		return err
@@ -108,9 +153,8 @@ func (h *Handler) MakePayment(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	var req SubmitPaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		// http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return BadRequest("Invalid body request", err)
+	if err := decodeRequest(r, &req); err != nil {
+		return err
 	}
 
 	// extract idempotency key
@@ -151,13 +195,9 @@ func (h *Handler) ListPayments(w http.ResponseWriter, r *http.Request) error {
 		return BadRequest("Invalid loan ID", err)
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit, err := strconv.Atoi(limitStr)
+	limit, err := h.pageLimit(r)
 	if err != nil {
-		return BadRequest("Invalid page limit number", err)
-	}
-	if limit <= 0 || limit > h.config.PagingLimitMax {
-		limit = h.config.PagingLimitDefault
+		return err
 	}
 
 	cursor, err := DecodeCursor[service.PaymentCursor](r)
@@ -191,13 +231,9 @@ func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) error {
 		return BadRequest("Invalid loan ID", err)
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit, err := strconv.Atoi(limitStr)
+	limit, err := h.pageLimit(r)
 	if err != nil {
-		return BadRequest("Invalid page limit number", err)
-	}
-	if limit <= 0 || limit > h.config.PagingLimitMax {
-		limit = h.config.PagingLimitDefault
+		return err
 	}
 
 	cursor, err := DecodeCursor[service.ScheduleCursor](r)
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"billing-api/internal/service"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) CreateMandate(w http.ResponseWriter, r *http.Request) error {
+	loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid loan ID", err)
+	}
+
+	var req CreateMandateRequest
+	if err := decodeRequest(r, &req); err != nil {
+		return err
+	}
+
+	mandate, err := h.collectionService.CreateMandate(r.Context(), service.CreateMandateInput{
+		LoanID:        loanID,
+		AccountHolder: req.AccountHolder,
+		BankCode:      req.BankCode,
+		AccountNumber: req.AccountNumber,
+		Reference:     req.Reference,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(ToMandateResponse(mandate))
+}
+
+func (h *Handler) GetMandate(w http.ResponseWriter, r *http.Request) error {
+	loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid loan ID", err)
+	}
+
+	mandate, err := h.collectionService.GetMandate(r.Context(), loanID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToMandateResponse(mandate))
+}
+
+func (h *Handler) RevokeMandate(w http.ResponseWriter, r *http.Request) error {
+	loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid loan ID", err)
+	}
+
+	mandate, err := h.collectionService.RevokeMandate(r.Context(), loanID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToMandateResponse(mandate))
+}
+
+// RunCollection triggers a collection run manually, by default for today
+func (h *Handler) RunCollection(w http.ResponseWriter, r *http.Request) error {
+	collectionDate := time.Now()
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		d, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return BadRequest("Invalid date", err)
+		}
+		collectionDate = d
+	}
+
+	batch, err := h.collectionService.RunCollection(r.Context(), collectionDate)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch == nil {
+		w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(w).Encode(map[string]string{
+			"status":  "success",
+			"message": "nothing to collect",
+		})
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(ToCollectionBatchResponse(batch, nil))
+}
+
+func (h *Handler) GetCollectionBatch(w http.ResponseWriter, r *http.Request) error {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batchID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid batch ID", err)
+	}
+
+	batch, items, err := h.collectionService.GetBatch(r.Context(), batchID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToCollectionBatchResponse(batch, items))
+}
+
+// ExportCollectionBatch downloads the bank file of a batch, `format` is csv (default) or fixed
+func (h *Handler) ExportCollectionBatch(w http.ResponseWriter, r *http.Request) error {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batchID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid batch ID", err)
+	}
+
+	format, err := service.ParseBankFileFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		return BadRequest("Invalid bank file format", err)
+	}
+
+	// render into a buffer first so a failure still reaches HandleError with clean headers
+	var buf bytes.Buffer
+	if err := h.collectionService.ExportBatch(r.Context(), batchID, format, &buf); err != nil {
+		return err
+	}
+
+	contentType, extension := "text/csv", "csv"
+	if format == service.BankFileFormatFixed {
+		contentType, extension = "text/plain", "txt"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="collection-batch-%d.%s"`, batchID, extension))
+	w.WriteHeader(http.StatusOK)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// ImportCollectionResult uploads the bank's result file of a batch as the raw request body
+func (h *Handler) ImportCollectionResult(w http.ResponseWriter, r *http.Request) error {
+	batchID, err := strconv.ParseInt(chi.URLParam(r, "batchID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid batch ID", err)
+	}
+
+	format, err := service.ParseBankFileFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		return BadRequest("Invalid bank file format", err)
+	}
+
+	summary, err := h.collectionService.ImportResults(r.Context(), batchID, format, r.Body)
+	if err != nil {
+		return err
+	}
+
+	resp := CollectionImportResponse{
+		BatchID:        summary.BatchID,
+		Succeeded:      summary.Succeeded,
+		RetryScheduled: summary.RetryScheduled,
+		Failed:         summary.Failed,
+		Skipped:        summary.Skipped,
+		Unposted:       summary.Unposted,
+		Pending:        summary.Pending,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(resp)
+}
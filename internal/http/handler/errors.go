@@ -2,18 +2,20 @@ package handler
 
 import (
 	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
 )
 
 type AppError struct {
-	Code    int    // we defind HTTP Status code here
-	Message string // the message FE sees
-	Err     error  // actual technical issue that captured
+	Code        int                  // we defind HTTP Status code here
+	Message     string               // the message FE sees
+	Err         error                // actual technical issue that captured
+	FieldErrors []problem.FieldError // which request fields are invalid, if any
 }
 
 func (e *AppError) Error() string {
@@ -29,6 +31,26 @@ func BadRequest(msg string, internalError error) error {
 	}
 }
 
+// InvalidField is a bad request caused by a single request field, the field is reported in the problem details
+func InvalidField(field, msg string, internalError error) error {
+	return &AppError{
+		Code:        http.StatusBadRequest,
+		Message:     msg,
+		Err:         internalError,
+		FieldErrors: []problem.FieldError{{Field: field, Message: msg}},
+	}
+}
+
+// ValidationFailed reports every invalid request field at once
+func ValidationFailed(fieldErrors []problem.FieldError) error {
+	return &AppError{
+		Code:        http.StatusBadRequest,
+		Message:     "Request validation failed",
+		Err:         fmt.Errorf("%d invalid request fields", len(fieldErrors)),
+		FieldErrors: fieldErrors,
+	}
+}
+
 // we'll reuse this within handler as default return object for internal error 500
 func InternalError(msg string, internalError error) error {
 	return &AppError{
@@ -38,6 +60,51 @@ func InternalError(msg string, internalError error) error {
 	}
 }
 
+// domainErrorResponse maps a domain error to its HTTP status, an empty detail exposes the error message as is
+type domainErrorResponse struct {
+	err    error
+	status int
+	logMsg string
+	detail string
+}
+
+var domainErrorResponses = []domainErrorResponse{
+	{domain.ErrLoanNotFound, http.StatusNotFound, "loan_not_found", "Loan not found"},
+	{domain.ErrInvalidLoanTerms, http.StatusBadRequest, "invalid_loan_terms", ""},
+	{domain.ErrInvalidPayment, http.StatusBadRequest, "invalid_payment_amount", "Invalid payment amount"},
+	{domain.ErrLoanAlreadyClosed, http.StatusConflict, "loan_already_closed", "Loan already closed"},
+	{domain.ErrScheduleNotFound, http.StatusInternalServerError, "schedule_not_found", "Schedule not found"},
+	{domain.ErrDuplicatePayment, http.StatusConflict, "payment_already_processed", "Idempotency key already used by a payment of another loan"},
+	{domain.ErrConcurrentPayment, http.StatusConflict, "concurrent_payment", "Another payment for this loan was processed concurrently"},
+	{domain.ErrInvalidStatementPeriod, http.StatusBadRequest, "invalid_statement_period", "Invalid statement period"},
+	{domain.ErrMandateNotFound, http.StatusNotFound, "mandate_not_found", "Mandate not found"},
+	{domain.ErrMandateAlreadyActive, http.StatusConflict, "mandate_already_active", "Loan already has an active mandate"},
+	{domain.ErrCollectionBatchNotFound, http.StatusNotFound, "collection_batch_not_found", "Collection batch not found"},
+	{domain.ErrCollectionBatchClosed, http.StatusConflict, "collection_batch_closed", "Collection batch already reconciled"},
+	{domain.ErrCollectionItemNotFound, http.StatusConflict, "collection_item_not_found", "Collection item not found or already settled"},
+	{domain.ErrInvalidBankFile, http.StatusBadRequest, "invalid_bank_file", ""},
+	{domain.ErrInvalidWebhook, http.StatusBadRequest, "invalid_webhook_subscription", ""},
+	{domain.ErrWebhookNotFound, http.StatusNotFound, "webhook_subscription_not_found", "Webhook subscription not found"},
+	{domain.ErrWebhookDeliveryNotFound, http.StatusNotFound, "webhook_delivery_not_found", "Webhook delivery not found"},
+	{domain.ErrWebhookDeliveryNotDead, http.StatusConflict, "webhook_delivery_not_dead", "Only dead deliveries can be redelivered"},
+	{domain.ErrIdempotencyKeyMismatch, http.StatusUnprocessableEntity, "idempotency_key_mismatch", "Idempotency key already used with a different request"},
+	{domain.ErrIdempotencyKeyInFlight, http.StatusConflict, "idempotency_key_in_flight", "A request with this idempotency key is still in progress"},
+	{domain.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Missing or invalid credentials"},
+	{domain.ErrInsufficientScope, http.StatusForbidden, "insufficient_scope", ""},
+	{domain.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found", "API key not found"},
+	{domain.ErrInvalidAPIKey, http.StatusBadRequest, "invalid_api_key", ""},
+	{domain.ErrPermissionDenied, http.StatusForbidden, "permission_denied", ""},
+	{domain.ErrInvalidAuditQuery, http.StatusBadRequest, "invalid_audit_query", ""},
+	{domain.ErrDelinquencyCheck, http.StatusInternalServerError, "logic_error", "Failed to compute loan deliquency"},
+	{domain.ErrInvalidStateOutstanding, http.StatusInternalServerError, "invalid_outstanding_state", "Invalid loan payment state"},
+}
+
+/*
+HandleError writes err as application/problem+json.
+
+Domain errors get their stable code from domain.ErrorCode, errors of the handlers themselves (AppError) are
+invalid_request, validation_failed when fields are reported, or internal_error.
+*/
 func (h *Handler) HandleError(w http.ResponseWriter, r *http.Request, err error) {
 	if err == nil {
 		return
@@ -47,7 +114,16 @@ func (h *Handler) HandleError(w http.ResponseWriter, r *http.Request, err error)
 	var appError *AppError
 	if errors.As(err, &appError) {
 		logError(r, "app_error", err)
-		http.Error(w, appError.Message, appError.Code)
+		code := problem.CodeInvalidRequest
+		switch {
+		case len(appError.FieldErrors) > 0:
+			code = problem.CodeValidationFailed
+		case appError.Code >= http.StatusInternalServerError:
+			code = problem.CodeInternalError
+		}
+		p := problem.New(appError.Code, code, appError.Message)
+		p.Errors = appError.FieldErrors
+		problem.Write(w, r, p)
 		return
 	}
 
@@ -55,39 +131,26 @@ func (h *Handler) HandleError(w http.ResponseWriter, r *http.Request, err error)
 	// Or check if it's a context error
 	if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "repo-timeout") {
 		logError(r, "request_timeout", err)
-		http.Error(w, "Database timeout", http.StatusGatewayTimeout)
+		problem.Error(w, r, http.StatusGatewayTimeout, problem.CodeDatabaseTimeout, "Database timeout")
 		return
 	}
 
-	// handle other errors
-	switch {
-	case errors.Is(err, domain.ErrLoanNotFound):
-		logError(r, "loan_not_found", err)
-		http.Error(w, "Loan not found", http.StatusNotFound)
-	case errors.Is(err, domain.ErrInvalidPayment):
-		logError(r, "invalid_payment_amount", err)
-		http.Error(w, "Invalid payment amount", http.StatusBadRequest)
-	case errors.Is(err, domain.ErrLoanAlreadyClosed):
-		logError(r, "loan_already_closed", err)
-		http.Error(w, "Loan already closed", http.StatusConflict)
-	case errors.Is(err, domain.ErrScheduleNotFound):
-		logError(r, "schedule_not_found", err)
-		http.Error(w, "Schedule not found", http.StatusInternalServerError)
-	case errors.Is(err, domain.ErrDuplicatePayment):
-		logError(r, "payment_already_processed", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status":  "success",
-			"message": "payment already processed",
-		})
-	case errors.Is(err, domain.ErrDelinquencyCheck):
-		logError(r, "logic_error", err)
-		http.Error(w, "Failed to compute loan deliquency", http.StatusInternalServerError)
-	default:
-		logError(r, "internal_server_error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	for _, resp := range domainErrorResponses {
+		if !errors.Is(err, resp.err) {
+			continue
+		}
+		logError(r, resp.logMsg, err)
+		detail := resp.detail
+		if detail == "" {
+			detail = err.Error()
+		}
+		code, _ := domain.ErrorCode(resp.err)
+		problem.Error(w, r, resp.status, code, detail)
+		return
 	}
+
+	logError(r, "internal_server_error", err)
+	problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternalError, "Internal server error")
 }
 
 func logError(r *http.Request, msg string, err error) {
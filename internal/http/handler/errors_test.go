@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+		fields []problem.FieldError
+	}{
+		{
+			name:   "bad request",
+			err:    BadRequest("Invalid loan ID", nil),
+			status: http.StatusBadRequest,
+			code:   problem.CodeInvalidRequest,
+			detail: "Invalid loan ID",
+		},
+		{
+			name:   "invalid field",
+			err:    InvalidField("limit", "must be between 1 and 100", nil),
+			status: http.StatusBadRequest,
+			code:   problem.CodeValidationFailed,
+			detail: "must be between 1 and 100",
+			fields: []problem.FieldError{{Field: "limit", Message: "must be between 1 and 100"}},
+		},
+		{
+			name:   "internal app error",
+			err:    InternalError("Failed to render the statement", errors.New("template")),
+			status: http.StatusInternalServerError,
+			code:   problem.CodeInternalError,
+			detail: "Failed to render the statement",
+		},
+		{
+			name:   "deadline",
+			err:    fmt.Errorf("GetLoan: %w", context.DeadlineExceeded),
+			status: http.StatusGatewayTimeout,
+			code:   problem.CodeDatabaseTimeout,
+			detail: "Database timeout",
+		},
+		{
+			name:   "wrapped domain error",
+			err:    fmt.Errorf("loading loan 1: %w", domain.ErrLoanNotFound),
+			status: http.StatusNotFound,
+			code:   "loan_not_found",
+			detail: "Loan not found",
+		},
+		{
+			name:   "domain error exposing its message",
+			err:    domain.ErrInvalidLoanTerms,
+			status: http.StatusBadRequest,
+			code:   "invalid_loan_terms",
+			detail: domain.ErrInvalidLoanTerms.Error(),
+		},
+		{
+			name:   "duplicate payment",
+			err:    domain.ErrDuplicatePayment,
+			status: http.StatusConflict,
+			code:   "duplicate_payment",
+			detail: "Idempotency key already used by a payment of another loan",
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("connection reset"),
+			status: http.StatusInternalServerError,
+			code:   problem.CodeInternalError,
+			detail: "Internal server error",
+		},
+	}
+
+	h := &Handler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleError(w, httptest.NewRequest(http.MethodGet, "/loan/1", nil), tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+			var got problem.Details
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.status, got.Status)
+			assert.Equal(t, tt.code, got.Code)
+			assert.Equal(t, tt.detail, got.Detail)
+			assert.Equal(t, tt.fields, got.Errors)
+		})
+	}
+
+	t.Run("nil error writes nothing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.HandleError(w, httptest.NewRequest(http.MethodGet, "/loan/1", nil), nil)
+		assert.Empty(t, w.Body.Bytes())
+	})
+}
+
+// every domain error answered by the handlers has a code in the catalog, the problem would otherwise have none
+func TestDomainErrorResponses_HaveCatalogCodes(t *testing.T) {
+	for _, resp := range domainErrorResponses {
+		code, ok := domain.ErrorCode(resp.err)
+		assert.True(t, ok, "no catalog code for %q", resp.err)
+		assert.NotEmpty(t, code)
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/publisher"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// interval of the keep-alive comments, keeps proxies from closing an idle stream
+const eventStreamHeartbeat = 15 * time.Second
+
+// StreamLoanEvents streams the payment, schedule and status changes of one loan as Server-Sent Events
+func (h *Handler) StreamLoanEvents(w http.ResponseWriter, r *http.Request) error {
+	loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid loan ID", err)
+	}
+
+	if _, err := h.billingService.GetLoanByID(r.Context(), loanID); err != nil {
+		return err
+	}
+
+	return h.streamEvents(w, r, func(e domain.OutboxEvent) bool {
+		return e.AggregateType == domain.AggregateTypeLoan && e.AggregateID == loanID
+	})
+}
+
+// StreamAllEvents streams the events of every loan of the tenant, meant for the back-office dashboard
+func (h *Handler) StreamAllEvents(w http.ResponseWriter, r *http.Request) error {
+	return h.streamEvents(w, r, nil)
+}
+
+/*
+streamEvents writes the matching bus events of the caller's tenant until the client disconnects.
+A reconnecting client sends the Last-Event-ID header and receives the buffered events it missed first.
+*/
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, filter func(domain.OutboxEvent) bool) error {
+	var lastEventID int64
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return BadRequest("Invalid Last-Event-ID", err)
+		}
+		lastEventID = id
+	}
+
+	tenantID := domain.TenantFromContext(r.Context())
+	rc := http.NewResponseController(w)
+	sub, replay := h.eventBus.Subscribe(lastEventID, func(e domain.OutboxEvent) bool {
+		return e.TenantID == tenantID && (filter == nil || filter(e))
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// ask EventSource to wait a bit before reconnecting
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, event := range replay {
+		if err := writeServerSentEvent(w, event); err != nil {
+			return nil
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	// once streaming started errors can't be reported anymore, the client simply reconnects
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if err := writeServerSentEvent(w, event); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return nil
+		}
+	}
+}
+
+// writeServerSentEvent writes the event envelope as a single SSE message named after the event type
+func writeServerSentEvent(w http.ResponseWriter, event domain.OutboxEvent) error {
+	data, err := json.Marshal(publisher.NewEnvelope(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.EventType, data)
+	return err
+}
@@ -4,33 +4,66 @@ import (
 	"billing-api/internal/config"
 	"billing-api/internal/contextkey"
 	"billing-api/internal/service"
+	"billing-api/internal/tracing"
 	"context"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
 )
 
 type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
 
 type Handler struct {
-	billingService *service.BillingService
-	config         *config.Config
+	billingService    *service.BillingService
+	collectionService *service.CollectionService
+	webhookService    *service.WebhookService
+	eventBus          *service.EventBus
+	authService       *service.AuthService
+	auditService      *service.AuditService
+	config            *config.Config
 }
 
-func NewHandler(bs *service.BillingService, cfg *config.Config) *Handler {
+func NewHandler(bs *service.BillingService, cs *service.CollectionService, ws *service.WebhookService, bus *service.EventBus, as *service.AuthService, aus *service.AuditService, cfg *config.Config) *Handler {
 	return &Handler{
-		billingService: bs,
-		config:         cfg,
+		billingService:    bs,
+		collectionService: cs,
+		webhookService:    ws,
+		eventBus:          bus,
+		authService:       as,
+		auditService:      aus,
+		config:            cfg,
 	}
 }
 
+// MakeHandler runs fn in a span named after it, the error it returns is recorded on the span before being handled
 func (h *Handler) MakeHandler(fn HandlerFunc) http.HandlerFunc {
+	spanName := handlerName(fn)
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := fn(w, r); err != nil {
+		ctx, span := tracing.Start(r.Context(), spanName)
+		r = r.WithContext(ctx)
+		err := fn(w, r)
+		tracing.End(span, err)
+		if err != nil {
 			// centralized error handling
 			h.HandleError(w, r, err)
 		}
 	}
 }
 
+/*
+handlerName names the span of fn after the method of Handler it comes from, "Handler.GetLoanByID" for h.GetLoanByID
+as well as for the closures returned by h.ChangeLogLevel or h.RequireLoanAccess.
+*/
+func handlerName(fn HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	if _, method, ok := strings.Cut(name, "(*Handler)."); ok {
+		method, _, _ = strings.Cut(method, ".")
+		return "Handler." + strings.TrimSuffix(method, "-fm")
+	}
+	return "handler"
+}
+
 // GetIdempotencyKey safely retrieves the key from context
 func GetIdempotencyKey(ctx context.Context) string {
 	val, ok := ctx.Value(contextkey.IdempotencyKey).(string)
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"billing-api/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+// Livez answers as long as the process serves requests, it does not look at the dependencies
+func (h *Handler) Livez(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte("OK"))
+	return err
+}
+
+// Readyz reports the readiness checks, with 503 when one fails. readiness is nil without a database, always ready
+func (h *Handler) Readyz(readiness *service.Readiness) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		report := service.ReadinessReport{Ready: true}
+		if readiness != nil {
+			report = readiness.Check(r.Context())
+		}
+
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		return json.NewEncoder(w).Encode(ToReadinessResponse(report))
+	}
+}
@@ -1,19 +1,43 @@
 package handler
 
 import (
+	"billing-api/internal/http/problem"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+/*
+Request DTOs declare their rules in the `validate` tag, checked by decodeRequest after decoding:
+
+	required     the value must not be empty (zero number, empty string or empty list)
+	min=N,max=N  bounds of a number, or of the length of a string or list
+	oneof=A B    the string, or every string of a list, must be one of the space separated values
+	date         a calendar date in YYYY-MM-DD format
+	url          an absolute http or https URL
+
+Rules other than required are skipped for an omitted optional field. The tags of the types listed in requestTypes
+are checked when the package loads, a typo in a rule fails at startup and not on a request.
+*/
 type SubmitLoanRequest struct {
-	PrincipalAmount    int64   `json:"principal_amount"`
-	AnnualInterestRate float64 `json:"annual_interest_rate"`
-	TotalWeeks         int     `json:"total_weeks"`
-	StartDate          string  `json:"start_date"` // YYYY-MM-DD
+	PrincipalAmount    int64   `json:"principal_amount" validate:"required,min=1,max=1000000000000"`
+	AnnualInterestRate float64 `json:"annual_interest_rate" validate:"min=0,max=1"`
+	TotalWeeks         int     `json:"total_weeks" validate:"required,min=1,max=520"`
+	StartDate          string  `json:"start_date" validate:"required,date"` // YYYY-MM-DD
+	BorrowerID         string  `json:"borrower_id" validate:"max=100"`      // subject of the borrower, lets the borrower read the loan
 }
 
 type SubmitPaymentRequest struct {
-	Amount int64 `json:"amount"`
+	Amount int64 `json:"amount" validate:"required,min=1"`
 }
 
 // EncodeCursor generic function to encode any struct into a base64 string
@@ -31,3 +55,275 @@ func EncodeCursor[T any](cursor *T) (*string, error) {
 	r := base64.RawURLEncoding.EncodeToString(data)
 	return &r, nil
 }
+
+type CreateMandateRequest struct {
+	AccountHolder string `json:"account_holder" validate:"required,max=140"`
+	BankCode      string `json:"bank_code" validate:"required,max=16"`
+	AccountNumber string `json:"account_number" validate:"required,max=34"`
+	Reference     string `json:"reference" validate:"required,max=35"`
+}
+
+// CreateWebhookSubscriptionRequest event types are checked against domain.WebhookEventTypes by the service
+type CreateWebhookSubscriptionRequest struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"required"`
+	Secret     string   `json:"secret" validate:"min=16,max=256"`
+}
+
+// CreateAPIKeyRequest expires_at is an RFC 3339 timestamp, the key never expires without it
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,oneof=read write admin"`
+	Roles     []string   `json:"roles" validate:"required"` // checked against the RBAC policy by the service
+	ExpiresAt *time.Time `json:"expires_at"`
+	TenantID  string     `json:"tenant_id"` // the tenant of the caller when omitted
+}
+
+const dateLayout = "2006-01-02"
+
+// requestTypes are the DTOs decoded by decodeRequest
+var requestTypes = []any{
+	SubmitLoanRequest{},
+	SubmitPaymentRequest{},
+	CreateMandateRequest{},
+	CreateWebhookSubscriptionRequest{},
+	CreateAPIKeyRequest{},
+}
+
+func init() {
+	for _, dst := range requestTypes {
+		if err := checkRules(reflect.TypeOf(dst)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+/*
+decodeRequest decodes the JSON body into dst and validates it.
+
+Unknown fields are rejected, so a typo in a field name is reported instead of silently ignored.
+All invalid fields are reported at once as a validation_failed problem.
+*/
+func decodeRequest(r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if dec.More() {
+		return BadRequest("Request body must contain a single JSON object", errors.New("trailing data after JSON body"))
+	}
+
+	fieldErrors, err := validateRequest(dst)
+	if err != nil {
+		return InternalError("Invalid request validation rules", err)
+	}
+	if len(fieldErrors) > 0 {
+		return ValidationFailed(fieldErrors)
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		return BadRequest("Request body is required", err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return InvalidField(typeErr.Field, fmt.Sprintf("must be of type %s", jsonTypeName(typeErr.Type)), err)
+	case errors.As(err, &syntaxErr):
+		return BadRequest(fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset), err)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return InvalidField(field, "unknown field", err)
+	}
+	return BadRequest("Invalid request body", err)
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.Kind().String()
+}
+
+// validateRequest checks the `validate` tags of the struct dst points to, in field order
+func validateRequest(dst any) ([]problem.FieldError, error) {
+	v := reflect.Indirect(reflect.ValueOf(dst))
+	t := v.Type()
+	if !slices.ContainsFunc(requestTypes, func(dst any) bool { return reflect.TypeOf(dst) == t }) {
+		// the rules of the types missing from requestTypes are checked on every request
+		if err := checkRules(t); err != nil {
+			return nil, err
+		}
+	}
+
+	var fieldErrors []problem.FieldError
+	for i := range t.NumField() {
+		tag, ok := t.Field(i).Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		if msg := validateField(v.Field(i), strings.Split(tag, ",")); msg != "" {
+			fieldErrors = append(fieldErrors, problem.FieldError{Field: name, Message: msg})
+		}
+	}
+	return fieldErrors, nil
+}
+
+// checkRules reports the unknown rules, the bounds that are not numbers and the rules that do not apply to their field
+func checkRules(t reflect.Type) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		kind := field.Type.Kind()
+		isString := kind == reflect.String
+		isStrings := kind == reflect.Slice && field.Type.Elem().Kind() == reflect.String
+
+		for _, rule := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+			var err error
+			switch name {
+			case "required":
+			case "min", "max":
+				if _, parseErr := strconv.ParseFloat(arg, 64); parseErr != nil {
+					err = fmt.Errorf("bound %q is not a number", arg)
+				} else if !isNumber(kind) && !isString && kind != reflect.Slice {
+					err = fmt.Errorf("does not apply to %s", field.Type)
+				}
+			case "oneof":
+				if len(strings.Fields(arg)) == 0 {
+					err = errors.New("no allowed value")
+				} else if !isString && !isStrings {
+					err = fmt.Errorf("does not apply to %s", field.Type)
+				}
+			case "date", "url":
+				if !isString {
+					err = fmt.Errorf("does not apply to %s", field.Type)
+				}
+			default:
+				err = errors.New("unknown rule")
+			}
+			if err != nil {
+				return fmt.Errorf("%s.%s: validation rule %q: %w", t.Name(), field.Name, rule, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validateField returns the message of the first rule the value breaks, or an empty string, the rules were checked
+// by checkRules
+func validateField(v reflect.Value, rules []string) string {
+	if v.IsZero() {
+		if slices.Contains(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		var msg string
+		switch name {
+		case "required":
+		case "min", "max":
+			msg = validateBound(v, name, arg)
+		case "oneof":
+			msg = validateOneOf(v, strings.Fields(arg))
+		case "date":
+			if _, err := time.Parse(dateLayout, v.String()); err != nil {
+				msg = "must be a date in YYYY-MM-DD format"
+			}
+		case "url":
+			if u, err := url.Parse(v.String()); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				msg = "must be an absolute http or https URL"
+			}
+		}
+		if msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func validateBound(v reflect.Value, rule, arg string) string {
+	bound, _ := strconv.ParseFloat(arg, 64)
+
+	var value float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		value = v.Float()
+	case reflect.String:
+		value, unit = float64(len([]rune(v.String()))), " characters"
+	case reflect.Slice:
+		value, unit = float64(v.Len()), " items"
+	}
+
+	if rule == "min" && value < bound {
+		return fmt.Sprintf("must be at least %s%s", arg, unit)
+	}
+	if rule == "max" && value > bound {
+		return fmt.Sprintf("must be at most %s%s", arg, unit)
+	}
+	return ""
+}
+
+func validateOneOf(v reflect.Value, allowed []string) string {
+	values := []string{v.String()}
+	if v.Kind() == reflect.Slice {
+		values = v.Interface().([]string)
+	}
+	for _, value := range values {
+		if !slices.Contains(allowed, value) {
+			return fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))
+		}
+	}
+	return ""
+}
+
+// pageLimit reads the optional `limit` query param, the default of the tenant when omitted
+func (h *Handler) pageLimit(r *http.Request) (int, error) {
+	settings := h.billingService.TenantSettings(r.Context())
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return settings.PagingLimitDefault, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		return 0, InvalidField("limit", "Invalid page limit number", err)
+	}
+	if limit < 1 || limit > settings.PagingLimitMax {
+		return 0, InvalidField("limit", fmt.Sprintf("must be between 1 and %d", settings.PagingLimitMax), nil)
+	}
+	return limit, nil
+}
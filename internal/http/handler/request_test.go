@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"billing-api/internal/http/problem"
+	"billing-api/internal/service"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validatedRequest struct {
+	Amount   int64    `json:"amount" validate:"required,min=1,max=100"`
+	Rate     float64  `json:"rate" validate:"min=0,max=1"`
+	Name     string   `json:"name" validate:"max=5"`
+	Date     string   `json:"date" validate:"date"`
+	URL      string   `json:"url" validate:"url"`
+	Kind     string   `json:"kind" validate:"oneof=a b"`
+	Scopes   []string `json:"scopes" validate:"min=1,max=2,oneof=read write"`
+	Untagged string   `json:"untagged"`
+}
+
+func TestValidateRequest(t *testing.T) {
+	valid := func() validatedRequest {
+		return validatedRequest{Amount: 10, Rate: 0.1, Name: "héllo", Date: "2026-01-05", URL: "https://partner.example/hook", Kind: "a", Scopes: []string{"read"}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *validatedRequest)
+		field  string
+		msg    string
+	}{
+		{"valid", func(r *validatedRequest) {}, "", ""},
+		{"required", func(r *validatedRequest) { r.Amount = 0 }, "amount", "is required"},
+		{"min of a number", func(r *validatedRequest) { r.Amount = -1 }, "amount", "must be at least 1"},
+		{"max of a number", func(r *validatedRequest) { r.Amount = 101 }, "amount", "must be at most 100"},
+		{"max of a float", func(r *validatedRequest) { r.Rate = 1.5 }, "rate", "must be at most 1"},
+		{"omitted optional field", func(r *validatedRequest) { r.Rate, r.Name, r.Date, r.URL, r.Kind, r.Scopes = 0, "", "", "", "", nil }, "", ""},
+		{"max counts characters, not bytes", func(r *validatedRequest) { r.Name = "héllos" }, "name", "must be at most 5 characters"},
+		{"date", func(r *validatedRequest) { r.Date = "05/01/2026" }, "date", "must be a date in YYYY-MM-DD format"},
+		{"relative url", func(r *validatedRequest) { r.URL = "/hook" }, "url", "must be an absolute http or https URL"},
+		{"url scheme", func(r *validatedRequest) { r.URL = "ftp://partner.example" }, "url", "must be an absolute http or https URL"},
+		{"oneof", func(r *validatedRequest) { r.Kind = "c" }, "kind", "must be one of: a, b"},
+		{"max of a list", func(r *validatedRequest) { r.Scopes = []string{"read", "write", "read"} }, "scopes", "must be at most 2 items"},
+		{"oneof every item of a list", func(r *validatedRequest) { r.Scopes = []string{"read", "admin"} }, "scopes", "must be one of: read, write"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			fieldErrors, err := validateRequest(&req)
+			require.NoError(t, err)
+			if tt.field == "" {
+				assert.Empty(t, fieldErrors)
+				return
+			}
+			assert.Equal(t, []problem.FieldError{{Field: tt.field, Message: tt.msg}}, fieldErrors)
+		})
+	}
+
+	t.Run("every invalid field is reported in field order", func(t *testing.T) {
+		fieldErrors, err := validateRequest(&validatedRequest{Name: "too long", Kind: "c"})
+		require.NoError(t, err)
+		assert.Equal(t, []problem.FieldError{
+			{Field: "amount", Message: "is required"},
+			{Field: "name", Message: "must be at most 5 characters"},
+			{Field: "kind", Message: "must be one of: a, b"},
+		}, fieldErrors)
+	})
+
+	t.Run("bad rule is an error, not a panic", func(t *testing.T) {
+		type badRequest struct {
+			Amount int64 `json:"amount" validate:"requried"`
+		}
+		_, err := validateRequest(&badRequest{Amount: 1})
+		assert.ErrorContains(t, err, `badRequest.Amount: validation rule "requried": unknown rule`)
+	})
+}
+
+func TestCheckRules(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  any
+		err  string
+	}{
+		{"unknown rule", struct {
+			A string `validate:"email"`
+		}{}, `validation rule "email": unknown rule`},
+		{"bound not a number", struct {
+			A int `validate:"min=one"`
+		}{}, `validation rule "min=one": bound "one" is not a number`},
+		{"bound of a bool", struct {
+			A bool `validate:"max=1"`
+		}{}, `validation rule "max=1": does not apply to bool`},
+		{"oneof without values", struct {
+			A string `validate:"oneof="`
+		}{}, `validation rule "oneof=": no allowed value`},
+		{"oneof of a number", struct {
+			A int `validate:"oneof=1 2"`
+		}{}, `validation rule "oneof=1 2": does not apply to int`},
+		{"date of a number", struct {
+			A int64 `validate:"date"`
+		}{}, `validation rule "date": does not apply to int64`},
+		{"url of a list", struct {
+			A []string `validate:"url"`
+		}{}, `validation rule "url": does not apply to []string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, checkRules(reflect.TypeOf(tt.dst)), tt.err)
+		})
+	}
+
+	t.Run("request types", func(t *testing.T) {
+		for _, dst := range append(requestTypes, validatedRequest{}) {
+			assert.NoError(t, checkRules(reflect.TypeOf(dst)))
+		}
+	})
+}
+
+func TestDecodeRequest(t *testing.T) {
+	decode := func(body string) error {
+		var req SubmitPaymentRequest
+		return decodeRequest(httptest.NewRequest(http.MethodPost, "/loan/1/payment", strings.NewReader(body)), &req)
+	}
+	fieldErrors := func(err error) []problem.FieldError {
+		var appErr *AppError
+		require.True(t, errors.As(err, &appErr), "got %v", err)
+		return appErr.FieldErrors
+	}
+
+	assert.NoError(t, decode(`{"amount": 10}`))
+	assert.Equal(t, []problem.FieldError{{Field: "amount", Message: "must be at least 1"}}, fieldErrors(decode(`{"amount": -10}`)))
+	assert.Equal(t, []problem.FieldError{{Field: "amount", Message: "must be of type integer"}}, fieldErrors(decode(`{"amount": "10"}`)))
+	assert.Equal(t, []problem.FieldError{{Field: "amont", Message: "unknown field"}}, fieldErrors(decode(`{"amont": 10}`)))
+	assert.Empty(t, fieldErrors(decode(``)))
+	assert.Empty(t, fieldErrors(decode(`{"amount": 10} {}`)))
+}
+
+func TestPageLimit(t *testing.T) {
+	h := &Handler{billingService: service.NewBillingService(nil, nil, nil, nil)}
+
+	tests := []struct {
+		query string
+		limit int
+		msg   string
+	}{
+		{"", 10, ""},
+		{"?limit=1", 1, ""},
+		{"?limit=100", 100, ""},
+		{"?limit=abc", 0, "Invalid page limit number"},
+		{"?limit=0", 0, "must be between 1 and 100"},
+		{"?limit=-5", 0, "must be between 1 and 100"},
+		{"?limit=100000", 0, "must be between 1 and 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			limit, err := h.pageLimit(httptest.NewRequest(http.MethodGet, "/loan"+tt.query, nil))
+			if tt.msg == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tt.limit, limit)
+				return
+			}
+			var appErr *AppError
+			require.True(t, errors.As(err, &appErr))
+			assert.Equal(t, []problem.FieldError{{Field: "limit", Message: tt.msg}}, appErr.FieldErrors)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"billing-api/internal/domain"
+	"billing-api/internal/service"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
@@ -15,12 +16,28 @@ type SubmitLoanResponse struct {
 }
 
 type DetailLoanResponse struct {
-	LoanID              int64  `json:"loan_id"`
-	TotalPayable        int64  `json:"total_payable"`
-	WeeklyPaymentAmount int64  `json:"weekly_payment_amount"`
-	TotalWeeks          int    `json:"total_weeks"`
-	CreatedAt           string `json:"created_at"`
-	IsDelinquent        bool   `json:"is_delinquent"`
+	LoanID              int64   `json:"loan_id"`
+	TotalPayable        int64   `json:"total_payable"`
+	WeeklyPaymentAmount int64   `json:"weekly_payment_amount"`
+	TotalWeeks          int     `json:"total_weeks"`
+	BorrowerID          *string `json:"borrower_id,omitempty"`
+	CreatedAt           string  `json:"created_at"`
+	IsDelinquent        bool    `json:"is_delinquent"`
+}
+
+type LoanResponse struct {
+	LoanID              int64   `json:"loan_id"`
+	PrincipalAmount     int64   `json:"principal_amount"`
+	TotalPayable        int64   `json:"total_payable"`
+	WeeklyPaymentAmount int64   `json:"weekly_payment_amount"`
+	TotalWeeks          int     `json:"total_weeks"`
+	BorrowerID          *string `json:"borrower_id,omitempty"`
+	CreatedAt           string  `json:"created_at"`
+}
+
+type ListLoanResponse struct {
+	Loans      []LoanResponse `json:"loans"`
+	NextCursor *string        `json:"next_cursor,omitempty"`
 }
 
 type OutstandingResponse struct {
@@ -71,6 +88,25 @@ func ToListPaymentResponse(payments []domain.Payment, nextCursor *string) ListPa
 	}
 }
 
+func ToListLoanResponse(loans []domain.Loan, nextCursor *string) ListLoanResponse {
+	list := make([]LoanResponse, len(loans))
+	for i, l := range loans {
+		list[i] = LoanResponse{
+			LoanID:              l.ID,
+			PrincipalAmount:     l.PrincipalAmount,
+			TotalPayable:        l.TotalPayableAmount,
+			WeeklyPaymentAmount: l.WeeklyPaymentAmount,
+			TotalWeeks:          l.TotalWeeks,
+			BorrowerID:          l.BorrowerID,
+			CreatedAt:           l.CreatedAt.Format(time.RFC3339),
+		}
+	}
+	return ListLoanResponse{
+		Loans:      list,
+		NextCursor: nextCursor,
+	}
+}
+
 // DecodeCursor generic function to decode any struct from a base64 URL query param
 func DecodeCursor[T any](r *http.Request) (*T, error) {
 	encodedCursor := r.URL.Query().Get("cursor")
@@ -108,3 +144,472 @@ func ToListScheduleResponse(schedules []domain.LoanSchedule, nextCursor *string)
 		NextCursor: nextCursor,
 	}
 }
+
+type MandateResponse struct {
+	MandateID     int64   `json:"mandate_id"`
+	LoanID        int64   `json:"loan_id"`
+	AccountHolder string  `json:"account_holder"`
+	BankCode      string  `json:"bank_code"`
+	AccountNumber string  `json:"account_number"`
+	Reference     string  `json:"reference"`
+	Status        string  `json:"status"`
+	CreatedAt     string  `json:"created_at"`
+	RevokedAt     *string `json:"revoked_at,omitempty"`
+}
+
+type CollectionItemResponse struct {
+	ItemID           int64   `json:"item_id"`
+	LoanID           int64   `json:"loan_id"`
+	ScheduleSequence int     `json:"schedule_sequence"`
+	Amount           int64   `json:"amount"`
+	Attempt          int     `json:"attempt"`
+	Status           string  `json:"status"`
+	FailureCode      string  `json:"failure_code,omitempty"`
+	NextAttemptOn    *string `json:"next_attempt_on,omitempty"`
+	PaymentID        *int64  `json:"payment_id,omitempty"`
+}
+
+type CollectionBatchResponse struct {
+	BatchID        int64                    `json:"batch_id"`
+	CollectionDate string                   `json:"collection_date"`
+	Status         string                   `json:"status"`
+	ItemCount      int                      `json:"item_count"`
+	TotalAmount    int64                    `json:"total_amount"`
+	Items          []CollectionItemResponse `json:"items,omitempty"`
+}
+
+type CollectionImportResponse struct {
+	BatchID        int64 `json:"batch_id"`
+	Succeeded      int   `json:"succeeded"`
+	RetryScheduled int   `json:"retry_scheduled"`
+	Failed         int   `json:"failed"`
+	Skipped        int   `json:"skipped"`
+	Unposted       int   `json:"unposted"`
+	Pending        int   `json:"pending"`
+}
+
+func ToMandateResponse(m *domain.Mandate) MandateResponse {
+	resp := MandateResponse{
+		MandateID:     m.ID,
+		LoanID:        m.LoanID,
+		AccountHolder: m.AccountHolder,
+		BankCode:      m.BankCode,
+		AccountNumber: m.AccountNumber,
+		Reference:     m.Reference,
+		Status:        m.Status,
+		CreatedAt:     m.CreatedAt.Format(time.RFC3339),
+	}
+	if m.RevokedAt != nil {
+		revokedAt := m.RevokedAt.Format(time.RFC3339)
+		resp.RevokedAt = &revokedAt
+	}
+	return resp
+}
+
+// ToCollectionBatchResponse maps a batch and (optionally) its items to the API response format
+func ToCollectionBatchResponse(b *domain.CollectionBatch, items []domain.CollectionItem) CollectionBatchResponse {
+	list := make([]CollectionItemResponse, len(items))
+	for i, item := range items {
+		list[i] = CollectionItemResponse{
+			ItemID:           item.ID,
+			LoanID:           item.LoanID,
+			ScheduleSequence: item.ScheduleSequence,
+			Amount:           item.Amount,
+			Attempt:          item.Attempt,
+			Status:           item.Status,
+			FailureCode:      item.FailureCode,
+			PaymentID:        item.PaymentID,
+		}
+		if item.NextAttemptOn != nil {
+			nextAttemptOn := item.NextAttemptOn.Format("2006-01-02")
+			list[i].NextAttemptOn = &nextAttemptOn
+		}
+	}
+	return CollectionBatchResponse{
+		BatchID:        b.ID,
+		CollectionDate: b.CollectionDate.Format("2006-01-02"),
+		Status:         b.Status,
+		ItemCount:      b.ItemCount,
+		TotalAmount:    b.TotalAmount,
+		Items:          list,
+	}
+}
+
+type StatementEntryResponse struct {
+	Type        string `json:"type"`
+	Reference   string `json:"reference"`
+	Date        string `json:"date"`
+	Description string `json:"description"`
+	Debit       int64  `json:"debit"`
+	Credit      int64  `json:"credit"`
+	Balance     int64  `json:"balance"`
+}
+
+type UpcomingInstallmentResponse struct {
+	Sequence int    `json:"sequence"`
+	DueDate  string `json:"due_date"`
+	Amount   int64  `json:"amount"`
+}
+
+type StatementResponse struct {
+	LoanID               int64                         `json:"loan_id"`
+	PeriodStart          string                        `json:"period_start"`
+	PeriodEnd            string                        `json:"period_end"`
+	OpeningBalance       int64                         `json:"opening_balance"`
+	Entries              []StatementEntryResponse      `json:"entries"`
+	ClosingBalance       int64                         `json:"closing_balance"`
+	UpcomingInstallments []UpcomingInstallmentResponse `json:"upcoming_installments"`
+	GeneratedAt          string                        `json:"generated_at"`
+}
+
+// ToStatementResponse maps the domain statement to the API response format
+func ToStatementResponse(s *domain.Statement) StatementResponse {
+	entries := make([]StatementEntryResponse, len(s.Entries))
+	for i, e := range s.Entries {
+		entries[i] = StatementEntryResponse{
+			Type:        e.Type,
+			Reference:   e.Reference,
+			Date:        e.Date.Format(time.RFC3339),
+			Description: e.Description,
+			Debit:       e.Debit,
+			Credit:      e.Credit,
+			Balance:     e.Balance,
+		}
+	}
+
+	upcoming := make([]UpcomingInstallmentResponse, len(s.UpcomingInstallments))
+	for i, u := range s.UpcomingInstallments {
+		upcoming[i] = UpcomingInstallmentResponse{
+			Sequence: u.Sequence,
+			DueDate:  u.DueDate.Format("2006-01-02"),
+			Amount:   u.Amount - u.PaidAmount,
+		}
+	}
+
+	return StatementResponse{
+		LoanID:               s.Loan.ID,
+		PeriodStart:          s.PeriodStart.Format("2006-01-02"),
+		PeriodEnd:            s.PeriodEnd.Format("2006-01-02"),
+		OpeningBalance:       s.OpeningBalance,
+		Entries:              entries,
+		ClosingBalance:       s.ClosingBalance,
+		UpcomingInstallments: upcoming,
+		GeneratedAt:          s.GeneratedAt.Format(time.RFC3339),
+	}
+}
+
+type WebhookSubscriptionResponse struct {
+	SubscriptionID int64    `json:"subscription_id"`
+	URL            string   `json:"url"`
+	EventTypes     []string `json:"event_types"`
+	Secret         string   `json:"secret,omitempty"` // only returned on creation
+	Status         string   `json:"status"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+type ListWebhookSubscriptionResponse struct {
+	Subscriptions []WebhookSubscriptionResponse `json:"subscriptions"`
+}
+
+type WebhookDeliveryAttemptResponse struct {
+	Attempt     int    `json:"attempt"`
+	StatusCode  *int   `json:"status_code,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+	AttemptedAt string `json:"attempted_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	DeliveryID     int64                            `json:"delivery_id"`
+	SubscriptionID int64                            `json:"subscription_id"`
+	EventID        int64                            `json:"event_id"`
+	EventType      string                           `json:"event_type"`
+	Status         string                           `json:"status"`
+	Attempts       int                              `json:"attempts"`
+	LastStatusCode *int                             `json:"last_status_code,omitempty"`
+	LastError      string                           `json:"last_error,omitempty"`
+	NextAttemptAt  *string                          `json:"next_attempt_at,omitempty"`
+	CreatedAt      string                           `json:"created_at"`
+	DeliveredAt    *string                          `json:"delivered_at,omitempty"`
+	AttemptLog     []WebhookDeliveryAttemptResponse `json:"attempt_log,omitempty"`
+}
+
+type ListWebhookDeliveryResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	NextCursor *string                   `json:"next_cursor,omitempty"`
+}
+
+func ToWebhookSubscriptionResponse(s *domain.WebhookSubscription, withSecret bool) WebhookSubscriptionResponse {
+	resp := WebhookSubscriptionResponse{
+		SubscriptionID: s.ID,
+		URL:            s.URL,
+		EventTypes:     s.EventTypes,
+		Status:         s.Status,
+		CreatedAt:      s.CreatedAt.Format(time.RFC3339),
+	}
+	if withSecret {
+		resp.Secret = s.Secret
+	}
+	return resp
+}
+
+func ToListWebhookSubscriptionResponse(subscriptions []domain.WebhookSubscription) ListWebhookSubscriptionResponse {
+	list := make([]WebhookSubscriptionResponse, len(subscriptions))
+	for i := range subscriptions {
+		list[i] = ToWebhookSubscriptionResponse(&subscriptions[i], false)
+	}
+	return ListWebhookSubscriptionResponse{Subscriptions: list}
+}
+
+func ToWebhookDeliveryResponse(d *domain.WebhookDelivery, attempts []domain.WebhookDeliveryAttempt) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		DeliveryID:     d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.Status == domain.WebhookDeliveryStatusPending {
+		nextAttemptAt := d.NextAttemptAt.Format(time.RFC3339)
+		resp.NextAttemptAt = &nextAttemptAt
+	}
+	if d.DeliveredAt != nil {
+		deliveredAt := d.DeliveredAt.Format(time.RFC3339)
+		resp.DeliveredAt = &deliveredAt
+	}
+	for _, a := range attempts {
+		resp.AttemptLog = append(resp.AttemptLog, WebhookDeliveryAttemptResponse{
+			Attempt:     a.Attempt,
+			StatusCode:  a.StatusCode,
+			Error:       a.Error,
+			DurationMs:  a.Duration.Milliseconds(),
+			AttemptedAt: a.AttemptedAt.Format(time.RFC3339),
+		})
+	}
+	return resp
+}
+
+func ToListWebhookDeliveryResponse(deliveries []domain.WebhookDelivery, nextCursor *string) ListWebhookDeliveryResponse {
+	list := make([]WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		list[i] = ToWebhookDeliveryResponse(&deliveries[i], nil)
+	}
+	return ListWebhookDeliveryResponse{
+		Deliveries: list,
+		NextCursor: nextCursor,
+	}
+}
+
+type APIKeyResponse struct {
+	APIKeyID    int64    `json:"api_key_id"`
+	Name        string   `json:"name"`
+	Prefix      string   `json:"prefix"`
+	TenantID    string   `json:"tenant_id"`
+	Key         string   `json:"key,omitempty"` // only returned on creation and rotation
+	Scopes      []string `json:"scopes"`
+	Roles       []string `json:"roles"`
+	ExpiresAt   *string  `json:"expires_at,omitempty"`
+	LastUsedAt  *string  `json:"last_used_at,omitempty"`
+	RevokedAt   *string  `json:"revoked_at,omitempty"`
+	RotatedFrom *int64   `json:"rotated_from,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+type ListAPIKeyResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+func ToAPIKeyResponse(k *domain.APIKey, key string) APIKeyResponse {
+	return APIKeyResponse{
+		APIKeyID:    k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		TenantID:    k.TenantID,
+		Key:         key,
+		Scopes:      k.Scopes,
+		Roles:       k.Roles,
+		ExpiresAt:   formatOptionalTime(k.ExpiresAt),
+		LastUsedAt:  formatOptionalTime(k.LastUsedAt),
+		RevokedAt:   formatOptionalTime(k.RevokedAt),
+		RotatedFrom: k.RotatedFrom,
+		CreatedAt:   k.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func ToListAPIKeyResponse(keys []domain.APIKey) ListAPIKeyResponse {
+	list := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		list[i] = ToAPIKeyResponse(&keys[i], "")
+	}
+	return ListAPIKeyResponse{APIKeys: list}
+}
+
+// AuditEntryResponse carries every hashed field, so a client can verify the chain on its own
+type AuditEntryResponse struct {
+	AuditEntryID   int64           `json:"audit_entry_id"`
+	OccurredAt     string          `json:"occurred_at"`
+	Actor          string          `json:"actor"`
+	Action         string          `json:"action"`
+	EntityType     string          `json:"entity_type"`
+	EntityID       string          `json:"entity_id"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	RequestID      string          `json:"request_id,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	SourceIP       string          `json:"source_ip,omitempty"`
+	PrevHash       string          `json:"prev_hash"`
+	Hash           string          `json:"hash"`
+}
+
+type ListAuditEntryResponse struct {
+	Entries    []AuditEntryResponse `json:"entries"`
+	NextCursor *string              `json:"next_cursor,omitempty"`
+}
+
+type AuditChainVerificationResponse struct {
+	Valid      bool   `json:"valid"`
+	Entries    int    `json:"entries"`
+	BrokenAtID *int64 `json:"broken_at_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+func ToAuditEntryResponse(e *domain.AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		AuditEntryID:   e.ID,
+		OccurredAt:     e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Actor:          e.Actor,
+		Action:         e.Action,
+		EntityType:     e.EntityType,
+		EntityID:       e.EntityID,
+		Before:         e.Before,
+		After:          e.After,
+		RequestID:      e.RequestID,
+		IdempotencyKey: e.IdempotencyKey,
+		SourceIP:       e.SourceIP,
+		PrevHash:       e.PrevHash,
+		Hash:           e.Hash,
+	}
+}
+
+func ToListAuditEntryResponse(entries []domain.AuditEntry, nextCursor *string) ListAuditEntryResponse {
+	list := make([]AuditEntryResponse, len(entries))
+	for i := range entries {
+		list[i] = ToAuditEntryResponse(&entries[i])
+	}
+	return ListAuditEntryResponse{
+		Entries:    list,
+		NextCursor: nextCursor,
+	}
+}
+
+func ToAuditChainVerificationResponse(v *domain.AuditChainVerification) AuditChainVerificationResponse {
+	return AuditChainVerificationResponse{
+		Valid:      v.Valid,
+		Entries:    v.Entries,
+		BrokenAtID: v.BrokenAtID,
+		Reason:     v.Reason,
+	}
+}
+
+type LoadSheddingResponse struct {
+	Enabled                bool             `json:"enabled"`
+	Limit                  int              `json:"limit"`
+	MinLimit               int              `json:"min_limit"`
+	MaxLimit               int              `json:"max_limit"`
+	InFlight               int              `json:"in_flight"`
+	AcquireLatencyMs       float64          `json:"acquire_latency_ms"`
+	TargetAcquireLatencyMs float64          `json:"target_acquire_latency_ms"`
+	PoolAcquiredConns      int32            `json:"pool_acquired_conns"`
+	PoolMaxConns           int32            `json:"pool_max_conns"`
+	Admitted               int64            `json:"admitted"`
+	Shed                   map[string]int64 `json:"shed"`
+}
+
+func ToLoadSheddingResponse(s service.LoadShedderState) LoadSheddingResponse {
+	shed := make(map[string]int64, len(s.Shed))
+	for priority, count := range s.Shed {
+		shed[string(priority)] = count
+	}
+	return LoadSheddingResponse{
+		Enabled:                true,
+		Limit:                  s.Limit,
+		MinLimit:               s.MinLimit,
+		MaxLimit:               s.MaxLimit,
+		InFlight:               s.InFlight,
+		AcquireLatencyMs:       float64(s.AcquireLatency.Microseconds()) / 1000,
+		TargetAcquireLatencyMs: float64(s.TargetAcquireLatency.Microseconds()) / 1000,
+		PoolAcquiredConns:      s.PoolAcquiredConns,
+		PoolMaxConns:           s.PoolMaxConns,
+		Admitted:               s.Admitted,
+		Shed:                   shed,
+	}
+}
+
+type ReadinessCheckResponse struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // ok or failed
+	Detail string `json:"detail,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                   `json:"status"` // ready or not_ready
+	Checks []ReadinessCheckResponse `json:"checks"`
+}
+
+func ToReadinessResponse(report service.ReadinessReport) ReadinessResponse {
+	resp := ReadinessResponse{Status: "ready", Checks: make([]ReadinessCheckResponse, 0, len(report.Checks))}
+	if !report.Ready {
+		resp.Status = "not_ready"
+	}
+	for _, check := range report.Checks {
+		status := "ok"
+		if !check.OK {
+			status = "failed"
+		}
+		resp.Checks = append(resp.Checks, ReadinessCheckResponse{Name: check.Name, Status: status, Detail: check.Detail})
+	}
+	return resp
+}
+
+type QueryStatResponse struct {
+	Name   string  `json:"name"`
+	Count  int64   `json:"count"`
+	Errors int64   `json:"errors"`
+	Slow   int64   `json:"slow"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+type QueryStatsResponse struct {
+	SlowThresholdMs int                 `json:"slow_threshold_ms"`
+	Queries         []QueryStatResponse `json:"queries"`
+}
+
+func ToQueryStatsResponse(slowThresholdMs int, stats []domain.QueryStat) QueryStatsResponse {
+	resp := QueryStatsResponse{SlowThresholdMs: slowThresholdMs, Queries: make([]QueryStatResponse, 0, len(stats))}
+	for _, s := range stats {
+		resp.Queries = append(resp.Queries, QueryStatResponse{
+			Name:   s.Name,
+			Count:  s.Count,
+			Errors: s.Errors,
+			Slow:   s.Slow,
+			P50Ms:  float64(s.P50.Microseconds()) / 1000,
+			P95Ms:  float64(s.P95.Microseconds()) / 1000,
+			MaxMs:  float64(s.Max.Microseconds()) / 1000,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed templates/statement.html
+var templateFS embed.FS
+
+var statementTemplate = template.Must(
+	template.New("statement.html").
+		Funcs(template.FuncMap{"amount": formatAmount}).
+		ParseFS(templateFS, "templates/statement.html"),
+)
+
+/*
+GetStatement renders the loan statement for `from` to `to` (YYYY-MM-DD, both inclusive).
+When omitted the period defaults to the current month up to today, `format` is json (default), csv or html.
+*/
+func (h *Handler) GetStatement(w http.ResponseWriter, r *http.Request) error {
+	loanID, err := strconv.ParseInt(chi.URLParam(r, "loanID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid loan ID", err)
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if s := query.Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			return BadRequest("Invalid to date", err)
+		}
+	}
+	from := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if s := query.Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			return BadRequest("Invalid from date", err)
+		}
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "html" {
+		return BadRequest(fmt.Sprintf("Invalid format : %s", format), errors.New("Invalid statement format"))
+	}
+
+	statement, err := h.billingService.GetStatement(r.Context(), loanID, from, to)
+	if err != nil {
+		return err
+	}
+	resp := ToStatementResponse(statement)
+
+	// render into a buffer first so a failure still reaches HandleError with clean headers
+	var buf bytes.Buffer
+	switch format {
+	case "csv":
+		if err := writeStatementCSV(&buf, resp); err != nil {
+			return InternalError("Error rendering statement", err)
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%d-%s-%s.csv"`, loanID, resp.PeriodStart, resp.PeriodEnd))
+	case "html":
+		if err := statementTemplate.Execute(&buf, resp); err != nil {
+			return InternalError("Error rendering statement", err)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	default:
+		if err := json.NewEncoder(&buf).Encode(resp); err != nil {
+			return InternalError("Error rendering statement", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// writeStatementCSV writes the ledger followed by the upcoming installments as one flat table
+func writeStatementCSV(buf *bytes.Buffer, s StatementResponse) error {
+	cw := csv.NewWriter(buf)
+	rows := [][]string{
+		{"date", "type", "reference", "description", "debit", "credit", "balance"},
+		{s.PeriodStart, "OPENING_BALANCE", "", "Opening balance", "", "", strconv.FormatInt(s.OpeningBalance, 10)},
+	}
+	for _, e := range s.Entries {
+		rows = append(rows, []string{
+			e.Date,
+			e.Type,
+			e.Reference,
+			e.Description,
+			strconv.FormatInt(e.Debit, 10),
+			strconv.FormatInt(e.Credit, 10),
+			strconv.FormatInt(e.Balance, 10),
+		})
+	}
+	rows = append(rows, []string{s.PeriodEnd, "CLOSING_BALANCE", "", "Closing balance", "", "", strconv.FormatInt(s.ClosingBalance, 10)})
+	for _, u := range s.UpcomingInstallments {
+		rows = append(rows, []string{
+			u.DueDate,
+			"UPCOMING_DUE",
+			fmt.Sprintf("W%d", u.Sequence),
+			fmt.Sprintf("Installment for week %d", u.Sequence),
+			strconv.FormatInt(u.Amount, 10),
+			"",
+			"",
+		})
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// formatAmount renders an amount with thousands separators, eg 5500000 -> 5,500,000
+func formatAmount(n int64) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return sign + s
+}
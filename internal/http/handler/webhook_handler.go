@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) CreateWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	var req CreateWebhookSubscriptionRequest
+	if err := decodeRequest(r, &req); err != nil {
+		return err
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(r.Context(), service.CreateWebhookSubscriptionInput{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(ToWebhookSubscriptionResponse(subscription, true))
+}
+
+func (h *Handler) ListWebhookSubscriptions(w http.ResponseWriter, r *http.Request) error {
+	subscriptions, err := h.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListWebhookSubscriptionResponse(subscriptions))
+}
+
+func (h *Handler) GetWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	subscriptionID, err := strconv.ParseInt(chi.URLParam(r, "subscriptionID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid subscription ID", err)
+	}
+
+	subscription, err := h.webhookService.GetSubscription(r.Context(), subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToWebhookSubscriptionResponse(subscription, false))
+}
+
+func (h *Handler) DeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) error {
+	subscriptionID, err := strconv.ParseInt(chi.URLParam(r, "subscriptionID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid subscription ID", err)
+	}
+
+	subscription, err := h.webhookService.DeleteSubscription(r.Context(), subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToWebhookSubscriptionResponse(subscription, false))
+}
+
+// ListWebhookDeliveries returns the delivery log of a subscription, `status` optionally filters PENDING, DELIVERED or DEAD
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	subscriptionID, err := strconv.ParseInt(chi.URLParam(r, "subscriptionID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid subscription ID", err)
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains([]string{
+		domain.WebhookDeliveryStatusPending,
+		domain.WebhookDeliveryStatusDelivered,
+		domain.WebhookDeliveryStatusDead,
+	}, status) {
+		return BadRequest(fmt.Sprintf("Invalid delivery status : %s", status), errors.New("Invalid webhook delivery status"))
+	}
+
+	limit, err := h.pageLimit(r)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := DecodeCursor[service.WebhookDeliveryCursor](r)
+	if err != nil {
+		return BadRequest("Invalid delivery cursor", err)
+	}
+
+	deliveries, nextCursor, err := h.webhookService.ListDeliveries(r.Context(), subscriptionID, status, limit, cursor)
+	if err != nil {
+		return err
+	}
+
+	encodedNextCursor, err := EncodeCursor(nextCursor)
+	if err != nil {
+		return InternalError("Error encoding next cursor", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListWebhookDeliveryResponse(deliveries, encodedNextCursor))
+}
+
+// GetWebhookDelivery returns a delivery together with every attempt made
+func (h *Handler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) error {
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid delivery ID", err)
+	}
+
+	delivery, attempts, err := h.webhookService.GetDelivery(r.Context(), deliveryID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToWebhookDeliveryResponse(delivery, attempts))
+}
+
+func (h *Handler) ListWebhookDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	limit, err := h.pageLimit(r)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := DecodeCursor[service.WebhookDeliveryCursor](r)
+	if err != nil {
+		return BadRequest("Invalid delivery cursor", err)
+	}
+
+	deliveries, nextCursor, err := h.webhookService.ListDeadLetters(r.Context(), limit, cursor)
+	if err != nil {
+		return err
+	}
+
+	encodedNextCursor, err := EncodeCursor(nextCursor)
+	if err != nil {
+		return InternalError("Error encoding next cursor", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ToListWebhookDeliveryResponse(deliveries, encodedNextCursor))
+}
+
+// RedeliverWebhook moves a dead delivery back to the queue, it is sent on the next dispatcher tick
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		return BadRequest("Invalid delivery ID", err)
+	}
+
+	delivery, err := h.webhookService.Redeliver(r.Context(), deliveryID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(ToWebhookDeliveryResponse(delivery, nil))
+}
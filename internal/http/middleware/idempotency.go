@@ -1,16 +1,124 @@
 package middleware
 
 import (
+	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
+	"billing-api/internal/service"
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 
 	billingApiContextKey "billing-api/internal/contextkey"
 )
 
-func IdempotencyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := r.Header.Get("X-Idempotency-Key")
-		ctx := context.WithValue(r.Context(), billingApiContextKey.IdempotencyKey, key)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+const (
+	IdempotencyKeyHeader      = "X-Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// request bodies are read in full to fingerprint them
+	maxIdempotentBodySize = 1 << 20
+)
+
+/*
+NewIdempotencyMiddleware replays the stored response when a request is retried with the same X-Idempotency-Key.
+
+The key is also put in the context for the handlers. Requests without a key are passed through unchanged.
+A reused key with a different body gets 422, a duplicate arriving while the original still runs gets 409.
+Server errors are not stored, the key is released so the client can retry.
+*/
+func NewIdempotencyMiddleware(svc *service.IdempotencyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			ctx := context.WithValue(r.Context(), billingApiContextKey.IdempotencyKey, key)
+			r = r.WithContext(ctx)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Idempotency key too long")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, err := svc.Begin(ctx, key, service.FingerprintRequest(r.Method, r.URL.Path, body))
+			switch {
+			case errors.Is(err, domain.ErrIdempotencyKeyMismatch):
+				code, _ := domain.ErrorCode(err)
+				problem.Error(w, r, http.StatusUnprocessableEntity, code, "Idempotency key already used with a different request")
+				return
+			case errors.Is(err, domain.ErrIdempotencyKeyInFlight):
+				code, _ := domain.ErrorCode(err)
+				problem.Error(w, r, http.StatusConflict, code, "A request with this idempotency key is still in progress")
+				return
+			case err != nil:
+				slog.ErrorContext(ctx, "idempotency_begin_failed", slog.Any("err", err))
+				problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternalError, "Internal server error")
+				return
+			case record != nil:
+				replayResponse(w, record)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// the outcome must be stored even when the client already hung up
+			storeCtx := context.WithoutCancel(ctx)
+			if rec.status >= http.StatusInternalServerError {
+				err = svc.Release(storeCtx, key)
+			} else {
+				err = svc.Complete(storeCtx, key, rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes())
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "idempotency_store_failed", slog.Any("err", err))
+			}
+		})
+	}
+}
+
+func replayResponse(w http.ResponseWriter, record *domain.IdempotencyRecord) {
+	if record.ResponseContentType != "" {
+		w.Header().Set("Content-Type", record.ResponseContentType)
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(record.ResponseStatus)
+	_, _ = w.Write(record.ResponseBody)
+}
+
+// responseRecorder writes through to the client while keeping a copy of the status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
 }
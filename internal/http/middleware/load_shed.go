@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
+	"billing-api/internal/service"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+NewLoadShedMiddleware rejects the requests the load shedder has no room for with 503 and Retry-After, before they
+wait on the database. The priority of a route is looked up by "METHOD pattern", the routes not listed are normal.
+It must run once the route is matched, and before the auth middleware whose key lookups also use the pool.
+*/
+func NewLoadShedMiddleware(shedder *service.LoadShedder, priorities map[string]service.RequestPriority) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			priority, ok := priorities[r.Method+" "+chi.RouteContext(r.Context()).RoutePattern()]
+			if !ok {
+				priority = service.PriorityNormal
+			}
+
+			release, admitted := shedder.Acquire(priority)
+			if !admitted {
+				code, _ := domain.ErrorCode(domain.ErrOverloaded)
+				w.Header().Set("Retry-After", "1")
+				problem.Error(w, r, http.StatusServiceUnavailable, code, "The server is overloaded, retry later")
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"billing-api/internal/domain"
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
@@ -8,21 +10,39 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+type accessLogKey struct{}
+
+// accessLog collects what the inner middleware learn about a request for the access log line
+type accessLog struct {
+	principal *domain.Principal
+}
+
+// setLoggedPrincipal records the caller in the access log of the request, if LoggerMiddleware runs
+func setLoggedPrincipal(ctx context.Context, p *domain.Principal) {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLog); ok {
+		entry.principal = p
+	}
+}
+
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		entry := &accessLog{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
 
 		// capture the status code
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 		next.ServeHTTP(ww, r)
 
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelInfo, "http_request",
+		attrs := []slog.Attr{
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 			slog.Int("status", ww.Status()),
 			slog.Duration("latency", time.Since(start)),
-		)
+		}
+		if entry.principal != nil {
+			attrs = append(attrs, slog.String("principal", entry.principal.Subject))
+		}
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "http_request", attrs...)
 	})
 }
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/http/problem"
+	"billing-api/internal/service"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+/*
+NewRateLimitMiddleware throttles the callers with the token buckets of the limiter. It must run once the route
+is matched, and after the auth middleware to limit each caller.
+
+Every limited response carries the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of the most
+restrictive bucket, a rejected request gets 429 with Retry-After. The limiter falls back to the buckets of the
+instance when its store fails, a request is only let through when no bucket can be read at all.
+*/
+func NewRateLimitMiddleware(limiter *service.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			decision, err := limiter.Allow(ctx, service.RateLimitRequest{
+				Route:     r.Method + " " + chi.RouteContext(ctx).RoutePattern(),
+				SourceIP:  domain.RequestMetaFromContext(ctx).SourceIP,
+				Principal: domain.PrincipalFromContext(ctx),
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "rate_limit_check_failed", slog.Any("err", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if decision.Limit > 0 {
+				w.Header().Set(RateLimitLimitHeader, strconv.Itoa(decision.Limit))
+				w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+				w.Header().Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(decision.Reset)))
+			}
+			if !decision.Allowed {
+				slog.InfoContext(ctx, "rate_limited", slog.Duration("retry_after", decision.RetryAfter))
+				code, _ := domain.ErrorCode(domain.ErrRateLimited)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+				problem.Error(w, r, http.StatusTooManyRequests, code, "Too many requests, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds rounds up, a client waiting the rounded down delay would be rejected again
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+/*
+NewRealIPMiddleware sets RemoteAddr to the address of the client, for the audit log and the per address rate limit.
+
+X-Forwarded-For and X-Real-IP are only read when the request comes from one of the trusted proxies, any client can
+send them otherwise. X-Forwarded-For is read from the right: the proxies append the address they received the request
+from, so the first address that is not a trusted proxy is the client, the entries on its left are set by the client.
+*/
+func NewRealIPMiddleware(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(trustedProxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, ok := clientAddr(r, trusted); ok {
+				r.RemoteAddr = addr.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientAddr returns the address of the client given by the trusted proxy the request comes from, false otherwise
+func clientAddr(r *http.Request, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !trusted(peer.Unmap()) {
+		return netip.Addr{}, false
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := peer
+		for _, hop := range slices.Backward(hops) {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+			if err != nil {
+				// a malformed entry was not written by a trusted proxy, the last one read is the closest to the client
+				break
+			}
+			client = addr.Unmap()
+			if !trusted(client) {
+				break
+			}
+		}
+		return client, true
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
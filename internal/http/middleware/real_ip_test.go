@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRealIPMiddleware(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string]string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:51000", nil, "203.0.113.7:51000"},
+		{"forwarded header of an untrusted client is ignored", "203.0.113.7:51000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7:51000"},
+		{"real ip header of an untrusted client is ignored", "203.0.113.7:51000", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.7:51000"},
+		{"client behind a trusted proxy", "10.1.2.3:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"entries set by the client are skipped", "10.1.2.3:443", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:443", map[string]string{"X-Forwarded-For": "198.51.100.1, 192.168.1.1, 10.9.9.9"}, "198.51.100.1"},
+		{"malformed entry stops the walk", "10.1.2.3:443", map[string]string{"X-Forwarded-For": "198.51.100.1, garbage, 10.9.9.9"}, "10.9.9.9"},
+		{"real ip of a trusted proxy", "192.168.1.1:443", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without header", "10.1.2.3:443", nil, "10.1.2.3:443"},
+		{"ipv4 mapped ipv6 proxy", "[::ffff:10.1.2.3]:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := NewRealIPMiddleware(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/loan", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"billing-api/internal/domain"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+/*
+RequestMetaMiddleware records the request ID and the address of the client for the audit log.
+It must run after middleware.RequestID and NewRealIPMiddleware, the address is the one it settled on.
+*/
+func RequestMetaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		ctx := domain.ContextWithRequestMeta(r.Context(), domain.RequestMeta{
+			RequestID: middleware.GetReqID(r.Context()),
+			SourceIP:  ip,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
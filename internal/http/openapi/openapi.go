@@ -0,0 +1,36 @@
+/*
+Package openapi serves the OpenAPI 3.1 document of the API and a browsable reference page rendered from it.
+
+openapi.json is maintained by hand next to the handlers, the contract tests of the http package fail when a
+route is missing from it or a response does not match it.
+*/
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// Spec returns the raw OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+func ServeSpec(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// ServeDocs renders the reference in the browser, the page is self-contained so it works without internet access
+func ServeDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(docsPage)
+}
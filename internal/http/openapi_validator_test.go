@@ -0,0 +1,260 @@
+package http
+
+import (
+	"billing-api/internal/http/openapi"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+openAPISpec checks responses against openapi.json.
+
+Only the part of JSON Schema the document uses is implemented: $ref, type (a name or a list of names), const,
+enum, required, properties, additionalProperties, items, minimum, maximum, minLength, maxLength, minItems and
+the date, date-time and uri formats.
+*/
+type openAPISpec struct {
+	doc map[string]any
+}
+
+func loadOpenAPISpec() (*openAPISpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(openapi.Spec()))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &openAPISpec{doc: doc}, nil
+}
+
+// operations lists every documented operation as "METHOD /path"
+func (s *openAPISpec) operations() []string {
+	var list []string
+	for path, item := range s.doc["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			list = append(list, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(list)
+	return list
+}
+
+func (s *openAPISpec) operation(method, path string) (map[string]any, bool) {
+	item, ok := s.doc["paths"].(map[string]any)[path].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	op, ok := item[strings.ToLower(method)].(map[string]any)
+	return op, ok
+}
+
+// resolve follows a local $ref like #/components/schemas/Problem
+func (s *openAPISpec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur any = s.doc
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			cur = cur.(map[string]any)[key]
+		}
+		node = cur.(map[string]any)
+	}
+}
+
+/*
+validateResponse checks the status, content type and body of a response of the operation.
+Client errors must be listed explicitly, the default response only covers server errors.
+*/
+func (s *openAPISpec) validateResponse(method, path string, rec *httptest.ResponseRecorder) error {
+	op, ok := s.operation(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	responses := op["responses"].(map[string]any)
+	raw, ok := responses[strconv.Itoa(rec.Code)].(map[string]any)
+	if !ok && rec.Code >= 500 {
+		raw, ok = responses["default"].(map[string]any)
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", rec.Code)
+	}
+	response := s.resolve(raw)
+
+	content, _ := response["content"].(map[string]any)
+	if len(content) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", rec.Header().Get("Content-Type"), err)
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		return fmt.Errorf("content type %s is not documented for status %d", mediaType, rec.Code)
+	}
+	schema, _ := media["schema"].(map[string]any)
+	if schema == nil {
+		return nil
+	}
+
+	var body any
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		dec := json.NewDecoder(bytes.NewReader(rec.Body.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
+	} else {
+		body = rec.Body.String()
+	}
+	return s.validate(schema, body, "$")
+}
+
+func (s *openAPISpec) validate(schema map[string]any, value any, at string) error {
+	schema = s.resolve(schema)
+
+	if c, ok := schema["const"]; ok && fmt.Sprint(c) != fmt.Sprint(value) {
+		return fmt.Errorf("%s: %v is not %v", at, value, c)
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+	}
+
+	if t, ok := schema["type"]; ok {
+		types := []any{t}
+		if list, ok := t.([]any); ok {
+			types = list
+		}
+		if !slices.ContainsFunc(types, func(t any) bool { return hasJSONType(value, t.(string)) }) {
+			return fmt.Errorf("%s: %v is not of type %v", at, value, t)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return s.validateObject(schema, v, at)
+	case []any:
+		if n, ok := schemaInt(schema, "minItems"); ok && len(v) < n {
+			return fmt.Errorf("%s: fewer than %d items", at, n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := s.validate(items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if min, ok := schemaFloat(schema, "minimum"); ok && f < min {
+			return fmt.Errorf("%s: %v is below the minimum %v", at, v, min)
+		}
+		if max, ok := schemaFloat(schema, "maximum"); ok && f > max {
+			return fmt.Errorf("%s: %v is above the maximum %v", at, v, max)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n, ok := schemaInt(schema, "minLength"); ok && length < n {
+			return fmt.Errorf("%s: shorter than %d", at, n)
+		}
+		if n, ok := schemaInt(schema, "maxLength"); ok && length > n {
+			return fmt.Errorf("%s: longer than %d", at, n)
+		}
+		if format, ok := schema["format"].(string); ok {
+			if err := checkFormat(format, v); err != nil {
+				return fmt.Errorf("%s: %q is not a valid %s: %w", at, v, format, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *openAPISpec) validateObject(schema map[string]any, v map[string]any, at string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at, name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, value := range v {
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			if schema["additionalProperties"] == false {
+				return fmt.Errorf("%s: unexpected property %s", at, name)
+			}
+			continue
+		}
+		if err := s.validate(property, value, at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasJSONType(value any, t string) bool {
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		_, err := v.Int64()
+		return t == "integer" && err == nil
+	}
+	return false
+}
+
+func checkFormat(format, v string) error {
+	switch format {
+	case "date":
+		_, err := time.Parse("2006-01-02", v)
+		return err
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err
+	case "uri":
+		_, err := url.ParseRequestURI(v)
+		return err
+	}
+	return nil
+}
+
+func schemaInt(schema map[string]any, key string) (int, bool) {
+	n, ok := schema[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+func schemaFloat(schema map[string]any, key string) (float64, bool) {
+	n, ok := schema[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
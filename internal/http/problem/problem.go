@@ -0,0 +1,73 @@
+/*
+Package problem writes error responses as RFC 7807 problem details (application/problem+json).
+
+Every response carries a stable machine-readable code, clients match on the code and never on the
+human readable title or detail, those may change at any time.
+*/
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const ContentType = "application/problem+json"
+
+// typePrefix turns a code into the problem type URI, the codes are listed in the error catalog of the README
+const typePrefix = "urn:billing-api:error:"
+
+// codes of errors raised by the HTTP layer itself, the domain errors have their own code (see domain.ErrorCode)
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeDatabaseTimeout  = "database_timeout"
+	CodeInternalError    = "internal_error"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Details struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// RequiredPermission is the permission missing from the roles of the caller, on permission_denied errors
+	RequiredPermission string `json:"required_permission,omitempty"`
+}
+
+func New(status int, code, detail string) *Details {
+	return &Details{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write sends the problem, filling in the request path and the request ID set by middleware.RequestID
+func Write(w http.ResponseWriter, r *http.Request, p *Details) {
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Error is a shortcut for a problem without field errors, the counterpart of http.Error
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
@@ -0,0 +1,55 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	handler := middleware.RequestID(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p := New(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+		p.Errors = []FieldError{{Field: "amount", Message: "must be at least 1"}}
+		Write(rw, r, p)
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/loan/1/payment?dry=1", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	var got Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "urn:billing-api:error:validation_failed", got.Type)
+	assert.Equal(t, "Bad Request", got.Title)
+	assert.Equal(t, http.StatusBadRequest, got.Status)
+	assert.Equal(t, "Request validation failed", got.Detail)
+	assert.Equal(t, "/loan/1/payment", got.Instance)
+	assert.Equal(t, CodeValidationFailed, got.Code)
+	assert.NotEmpty(t, got.RequestID)
+	assert.Equal(t, []FieldError{{Field: "amount", Message: "must be at least 1"}}, got.Errors)
+}
+
+func TestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	Error(w, httptest.NewRequest(http.MethodGet, "/loan/1", nil), http.StatusNotFound, "loan_not_found", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the optional members are left out rather than sent empty
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, map[string]any{
+		"type":     "urn:billing-api:error:loan_not_found",
+		"title":    "Not Found",
+		"status":   float64(http.StatusNotFound),
+		"instance": "/loan/1",
+		"code":     "loan_not_found",
+	}, got)
+}
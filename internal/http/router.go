@@ -2,48 +2,172 @@ package http
 
 import (
 	"billing-api/internal/config"
+	"billing-api/internal/domain"
+	"billing-api/internal/metrics"
 	"billing-api/internal/service"
 	"net/http"
 
 	"billing-api/internal/http/handler"
 	billingApiMiddleware "billing-api/internal/http/middleware"
+	"billing-api/internal/http/openapi"
+	"billing-api/internal/http/problem"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewRouter(billingService *service.BillingService, cfg *config.Config) http.Handler {
+// RouterDeps are the services behind the routes, the optional ones are left nil to disable them
+type RouterDeps struct {
+	BillingService     *service.BillingService
+	CollectionService  *service.CollectionService
+	WebhookService     *service.WebhookService
+	EventBus           *service.EventBus
+	IdempotencyService *service.IdempotencyService
+	AuthService        *service.AuthService
+	AuditService       *service.AuditService
+
+	RateLimiter *service.RateLimiter      // optional, the callers are not rate limited without it
+	LoadShedder *service.LoadShedder      // optional, no request is shed without it
+	Readiness   *service.Readiness        // optional, /readyz always answers ready without it
+	QueryStats  func() []domain.QueryStat // optional, /admin/query-stats is empty without a database
+}
+
+func NewRouter(deps RouterDeps, cfg *config.Config) http.Handler {
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
 	// r.Use(billingApiMiddleware.RequestIDMiddleware)
 	r.Use(middleware.RequestID)
+	r.Use(billingApiMiddleware.TracingMiddleware)
 	r.Use(billingApiMiddleware.LoggerMiddleware)
-	r.Use(middleware.RealIP)
+	r.Use(billingApiMiddleware.MetricsMiddleware)
+	r.Use(billingApiMiddleware.NewRealIPMiddleware(cfg.TrustedProxies))
+	r.Use(billingApiMiddleware.RequestMetaMiddleware)
 
-	r.Get("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeNotFound, "No route for "+r.URL.Path)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
 	})
 
-	r.Route("/loan", func(r chi.Router) {
-		h := handler.NewHandler(billingService, cfg)
+	h := handler.NewHandler(deps.BillingService, deps.CollectionService, deps.WebhookService, deps.EventBus, deps.AuthService, deps.AuditService, cfg)
+
+	// the probes need no credentials, /health is the liveness probe of the first deployments
+	r.Get("/health", h.MakeHandler(h.Livez))
+	r.Get("/livez", h.MakeHandler(h.Livez))
+	r.Get("/readyz", h.MakeHandler(h.Readyz(deps.Readiness)))
+
+	// Prometheus scrapes it, like /health it needs no credentials
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	// API reference, keep openapi.json in sync with the routes below
+	r.Get("/openapi.json", openapi.ServeSpec)
+	r.Get("/docs", openapi.ServeDocs)
+
+	// the payment writes are shed last and the reporting reads first, the routes not listed are normal
+	routePriorities := map[string]service.RequestPriority{
+		"POST /loan/{loanID}/payment":            service.PriorityCritical,
+		"GET /loan":                              service.PriorityLow,
+		"GET /loan/{loanID}/payment":             service.PriorityLow,
+		"GET /loan/{loanID}/schedule":            service.PriorityLow,
+		"GET /loan/{loanID}/statement":           service.PriorityLow,
+		"GET /collection/batch/{batchID}/export": service.PriorityLow,
+		"GET /webhook/dead-letter":               service.PriorityLow,
+		"GET /admin/audit-log":                   service.PriorityLow,
+		"GET /admin/audit-log/verify":            service.PriorityLow,
+		// the streams stay open for long, and the state of the shedder and the query statistics must be readable under load
+		"GET /loan/{loanID}/events": service.PriorityExempt,
+		"GET /loan/admin/events":    service.PriorityExempt,
+		"GET /admin/load-shedding":  service.PriorityExempt,
+		"GET /admin/query-stats":    service.PriorityExempt,
+	}
+
+	/*
+		every API route below needs credentials, GET requests the read scope and the others the write scope,
+		and each route a permission granted by the roles of the caller. The callers are then rate limited,
+		unless RateLimiter is nil. Before all that, the load shedder turns requests away when the database is
+		slow, unless LoadShedder is nil.
+		The middlewares are inlined on the routes rather than used by the sub-routers, so they run once the
+		route is matched, the 401, 403, 429 and 503 responses carry the route pattern like the others and the
+		route limits and priorities know the route.
+	*/
+	protected := func(r chi.Router) chi.Router {
+		if deps.LoadShedder != nil {
+			r = r.With(billingApiMiddleware.NewLoadShedMiddleware(deps.LoadShedder, routePriorities))
+		}
+		if cfg.AuthEnabled {
+			r = r.With(billingApiMiddleware.NewAuthMiddleware(deps.AuthService), billingApiMiddleware.RequireMethodScope)
+		}
+		if deps.RateLimiter != nil {
+			r = r.With(billingApiMiddleware.NewRateLimitMiddleware(deps.RateLimiter))
+		}
+		return r
+	}
 
-		r.Post("/", h.MakeHandler(h.SubmitLoan))
-		r.Get("/{loanID}", h.MakeHandler(h.GetLoanByID))
-		r.Get("/{loanID}/outstanding", h.MakeHandler(h.GetOutstanding))
-		r.Get("/{loanID}/payment", h.MakeHandler(h.ListPayments))
-		r.Get("/{loanID}/schedule", h.MakeHandler(h.ListSchedules))
+	// the permission each route requires, see service.Policy for the roles granting them
+	can := billingApiMiddleware.RequirePermission
+	operator := billingApiMiddleware.RequireOperator
+	readLoan := can(domain.PermissionLoanRead, domain.PermissionLoanReadOwn)
+	idempotent := billingApiMiddleware.NewIdempotencyMiddleware(deps.IdempotencyService)
+
+	r.Route("/loan", func(r chi.Router) {
+		r = protected(r)
+		r.With(readLoan).Get("/", h.MakeHandler(h.ListLoans))
+		r.With(can(domain.PermissionLoanCreate), idempotent).Post("/", h.MakeHandler(h.SubmitLoan))
 
 		r.Group(func(r chi.Router) {
-			r.Use(billingApiMiddleware.IdempotencyMiddleware)
-			r.Post("/{loanID}/payment", h.MakeHandler(h.MakePayment))
+			r.Use(readLoan, h.RequireLoanAccess)
+			r.Get("/{loanID}", h.MakeHandler(h.GetLoanByID))
+			r.Get("/{loanID}/outstanding", h.MakeHandler(h.GetOutstanding))
+			r.Get("/{loanID}/payment", h.MakeHandler(h.ListPayments))
+			r.Get("/{loanID}/schedule", h.MakeHandler(h.ListSchedules))
+			r.Get("/{loanID}/statement", h.MakeHandler(h.GetStatement))
+			r.Get("/{loanID}/events", h.MakeHandler(h.StreamLoanEvents))
+			r.Get("/{loanID}/mandate", h.MakeHandler(h.GetMandate))
 		})
+		r.With(can(domain.PermissionPaymentCreate), idempotent).Post("/{loanID}/payment", h.MakeHandler(h.MakePayment))
+		r.With(can(domain.PermissionMandateManage)).Post("/{loanID}/mandate", h.MakeHandler(h.CreateMandate))
+		r.With(can(domain.PermissionMandateManage)).Delete("/{loanID}/mandate", h.MakeHandler(h.RevokeMandate))
+
+		r.With(operator, can(domain.PermissionLogLevelChange)).Post("/admin/log-level", h.MakeHandler(h.ChangeLogLevel(cfg.LogLevel)))
+		r.With(can(domain.PermissionLoanRead)).Get("/admin/events", h.MakeHandler(h.StreamAllEvents))
+	})
+
+	r.Route("/collection", func(r chi.Router) {
+		r = protected(r).With(can(domain.PermissionCollectionManage))
+		r.Post("/run", h.MakeHandler(h.RunCollection))
+		r.Get("/batch/{batchID}", h.MakeHandler(h.GetCollectionBatch))
+		r.Get("/batch/{batchID}/export", h.MakeHandler(h.ExportCollectionBatch))
+		r.Post("/batch/{batchID}/result", h.MakeHandler(h.ImportCollectionResult))
+	})
+
+	r.Route("/webhook", func(r chi.Router) {
+		r = protected(r).With(can(domain.PermissionWebhookManage))
+		r.Post("/subscription", h.MakeHandler(h.CreateWebhookSubscription))
+		r.Get("/subscription", h.MakeHandler(h.ListWebhookSubscriptions))
+		r.Get("/subscription/{subscriptionID}", h.MakeHandler(h.GetWebhookSubscription))
+		r.Delete("/subscription/{subscriptionID}", h.MakeHandler(h.DeleteWebhookSubscription))
+		r.Get("/subscription/{subscriptionID}/delivery", h.MakeHandler(h.ListWebhookDeliveries))
+		r.Get("/delivery/{deliveryID}", h.MakeHandler(h.GetWebhookDelivery))
+		r.Get("/dead-letter", h.MakeHandler(h.ListWebhookDeadLetters))
+		r.Post("/delivery/{deliveryID}/redeliver", h.MakeHandler(h.RedeliverWebhook))
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r = protected(r)
 		r.Group(func(r chi.Router) {
-			// later we can put specific auth middleware here
-			r.Post("/admin/log-level", h.MakeHandler(h.ChangeLogLevel(cfg.LogLevel)))
+			r.Use(can(domain.PermissionConfigManage))
+			r.Post("/api-key", h.MakeHandler(h.CreateAPIKey))
+			r.Get("/api-key", h.MakeHandler(h.ListAPIKeys))
+			r.Post("/api-key/{keyID}/rotate", h.MakeHandler(h.RotateAPIKey))
+			r.Delete("/api-key/{keyID}", h.MakeHandler(h.RevokeAPIKey))
+			r.With(operator).Get("/load-shedding", h.MakeHandler(h.GetLoadShedding(deps.LoadShedder)))
+			r.With(operator).Get("/query-stats", h.MakeHandler(h.GetQueryStats(deps.QueryStats)))
 		})
+		r.With(can(domain.PermissionAuditRead)).Get("/audit-log", h.MakeHandler(h.ListAuditLog))
+		r.With(can(domain.PermissionAuditRead)).Get("/audit-log/verify", h.MakeHandler(h.VerifyAuditLog))
 	})
 
 	return r
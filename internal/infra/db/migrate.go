@@ -0,0 +1,388 @@
+package db
+
+import (
+	"billing-api/internal/infra/db/sqlc"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	migrateUpMarker   = "-- migrate:up"
+	migrateDownMarker = "-- migrate:down"
+)
+
+// Migration is one file of db/migrations, NNN_name.sql
+type Migration struct {
+	Version  int32
+	Name     string // the file name without .sql, 001_init
+	Up       string
+	Down     string
+	Checksum string // hex sha256 of the file, a migration must not change once applied
+}
+
+// LoadMigrations reads the NNN_name.sql files of fsys, in version order
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		migration, err := parseMigration(file, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+	return migrations, nil
+}
+
+// parseMigration splits a file into the statements after -- migrate:up and the ones after -- migrate:down
+func parseMigration(file, content string) (Migration, error) {
+	name := strings.TrimSuffix(file, ".sql")
+	prefix, _, ok := strings.Cut(name, "_")
+	version, err := strconv.ParseInt(prefix, 10, 32)
+	if !ok || err != nil || version <= 0 {
+		return Migration{}, errors.New("the file must be named NNN_name.sql")
+	}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	for line := range strings.Lines(content) {
+		switch strings.TrimSpace(line) {
+		case migrateUpMarker:
+			if section != nil {
+				return Migration{}, fmt.Errorf("%s must come first", migrateUpMarker)
+			}
+			section = &up
+			continue
+		case migrateDownMarker:
+			if section != &up {
+				return Migration{}, fmt.Errorf("%s must follow %s", migrateDownMarker, migrateUpMarker)
+			}
+			section = &down
+			continue
+		}
+		if section == nil {
+			if strings.TrimSpace(line) != "" {
+				return Migration{}, fmt.Errorf("statements before %s", migrateUpMarker)
+			}
+			continue
+		}
+		section.WriteString(line)
+	}
+	if strings.TrimSpace(up.String()) == "" {
+		return Migration{}, fmt.Errorf("no statement after %s", migrateUpMarker)
+	}
+	if section != &down {
+		return Migration{}, fmt.Errorf("no %s section, a migration that can not be reverted says so in a comment there", migrateDownMarker)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	return Migration{
+		Version:  int32(version),
+		Name:     name,
+		Up:       up.String(),
+		Down:     down.String(),
+		Checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// states of a migration in MigrationStatus
+const (
+	MigrationApplied    = "applied"
+	MigrationPending    = "pending"
+	MigrationChanged    = "changed"    // applied, its file changed since
+	MigrationUnverified = "unverified" // applied without checksum, before the migrator, until migrate baseline
+	MigrationUnknown    = "unknown"    // applied by a newer release, this one has no file for it
+)
+
+type MigrationStatus struct {
+	Version   int32
+	Name      string
+	State     string
+	AppliedAt time.Time // zero when pending
+}
+
+// createSchemaMigrations is 011_schema_migrations.sql, run before any migration so the first ones are recorded too.
+// The checksum column is added to the tables created by 011 before it was there
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT PRIMARY KEY,
+  name TEXT NOT NULL,
+  checksum TEXT,
+  applied_at TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE schema_migrations
+ADD COLUMN IF NOT EXISTS checksum TEXT;`
+
+/*
+Migrator applies the migrations to the database and records them in schema_migrations with their checksum.
+
+Every migration runs in a transaction with its record, a failing one leaves the database at the previous version.
+A run holds a session advisory lock from start to end, so when several instances start at once one migrates
+and the others wait for it, then find nothing left to do.
+*/
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool, migrations []Migration) *Migrator {
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+	}
+}
+
+// Up applies the pending migrations in version order, it refuses to run when an applied migration changed
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *pgx.Conn, records map[int32]sqlc.SchemaMigration) error {
+		if err := m.verify(records); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := records[migration.Version]; ok {
+				continue
+			}
+			err := m.run(ctx, conn, migration, migration.Up, func(queries *sqlc.Queries) error {
+				return queries.InsertSchemaMigration(ctx, sqlc.InsertSchemaMigrationParams{
+					Version:  migration.Version,
+					Name:     migration.Name,
+					Checksum: pgtype.Text{String: migration.Checksum, Valid: true},
+				})
+			})
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "migration applied", slog.String("migration", migration.Name))
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, the latest first
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *pgx.Conn, records map[int32]sqlc.SchemaMigration) error {
+		if err := m.verify(records); err != nil {
+			return err
+		}
+		versions := slices.Sorted(maps.Keys(records))
+		slices.Reverse(versions)
+
+		for _, version := range versions[:min(steps, len(versions))] {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %s has no file, it was applied by a newer release", records[version].Name)
+			}
+			err := m.run(ctx, conn, migration, migration.Down, func(queries *sqlc.Queries) error {
+				return queries.DeleteSchemaMigration(ctx, migration.Version)
+			})
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "migration reverted", slog.String("migration", migration.Name))
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+/*
+Baseline records the migrations up to version as applied without running them, for a database created before
+the migrator existed. The migrations up to version already recorded without checksum take the one of their file.
+Either way the files are trusted to match the database, which the operator checks before running it.
+*/
+func (m *Migrator) Baseline(ctx context.Context, version int32) ([]Migration, error) {
+	var recorded []Migration
+	err := m.locked(ctx, func(conn *pgx.Conn, records map[int32]sqlc.SchemaMigration) error {
+		queries := sqlc.New(conn)
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				continue
+			}
+			checksum := pgtype.Text{String: migration.Checksum, Valid: true}
+			record, ok := records[migration.Version]
+			var err error
+			switch {
+			case !ok:
+				err = queries.InsertSchemaMigration(ctx, sqlc.InsertSchemaMigrationParams{
+					Version:  migration.Version,
+					Name:     migration.Name,
+					Checksum: checksum,
+				})
+			case !record.Checksum.Valid:
+				err = queries.SetSchemaMigrationChecksum(ctx, sqlc.SetSchemaMigrationChecksumParams{
+					Checksum: checksum,
+					Version:  migration.Version,
+				})
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			recorded = append(recorded, migration)
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// Status lists the migrations of the files and of the database, in version order
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.locked(ctx, func(_ *pgx.Conn, records map[int32]sqlc.SchemaMigration) error {
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name, State: MigrationPending}
+			if record, ok := records[migration.Version]; ok {
+				switch {
+				case !record.Checksum.Valid:
+					status.State = MigrationUnverified
+				case record.Checksum.String != migration.Checksum:
+					status.State = MigrationChanged
+				default:
+					status.State = MigrationApplied
+				}
+				status.AppliedAt = record.AppliedAt.Time
+			}
+			statuses = append(statuses, status)
+		}
+		for version, record := range records {
+			if _, ok := m.find(version); !ok {
+				statuses = append(statuses, MigrationStatus{Version: version, Name: record.Name, State: MigrationUnknown, AppliedAt: record.AppliedAt.Time})
+			}
+		}
+		slices.SortFunc(statuses, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) find(version int32) (Migration, bool) {
+	i := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+	if i < 0 {
+		return Migration{}, false
+	}
+	return m.migrations[i], true
+}
+
+/*
+verify fails when the file of an applied migration changed since, the database no longer matches it.
+A migration recorded before the migrator existed has no checksum, nothing tells whether its file changed since,
+so it is not adopted silently: migrate baseline records the checksums once the schema was checked against the files.
+*/
+func (m *Migrator) verify(records map[int32]sqlc.SchemaMigration) error {
+	for _, version := range slices.Sorted(maps.Keys(records)) {
+		migration, ok := m.find(version)
+		if !ok {
+			continue
+		}
+		record := records[version]
+		if !record.Checksum.Valid {
+			return fmt.Errorf("migration %s was recorded without checksum, check the schema matches the files and run migrate baseline %d", migration.Name, m.unverifiedUpTo(records))
+		}
+		if record.Checksum.String != migration.Checksum {
+			return fmt.Errorf("migration %s changed after it was applied, add a new migration instead", migration.Name)
+		}
+	}
+	return nil
+}
+
+// unverifiedUpTo is the latest version recorded without checksum, the one to give to migrate baseline
+func (m *Migrator) unverifiedUpTo(records map[int32]sqlc.SchemaMigration) int32 {
+	var latest int32
+	for version, record := range records {
+		if !record.Checksum.Valid {
+			latest = max(latest, version)
+		}
+	}
+	return latest
+}
+
+// run executes the statements of a migration and updates its record in the same transaction
+func (m *Migrator) run(ctx context.Context, conn *pgx.Conn, migration Migration, statements string, record func(queries *sqlc.Queries) error) error {
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, statements); err != nil {
+			return err
+		}
+		return record(sqlc.New(tx))
+	})
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", migration.Name, err)
+	}
+	return nil
+}
+
+// locked runs fn on a connection holding the migration lock, with the migrations recorded in the database
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgx.Conn, records map[int32]sqlc.SchemaMigration) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	queries := sqlc.New(conn)
+
+	locked, err := queries.TryLockSchemaMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		slog.InfoContext(ctx, "another instance is migrating, waiting for it")
+		if err := queries.LockSchemaMigrations(ctx); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		// the lock belongs to the session, a connection that could not release it must not go back to the pool
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := queries.UnlockSchemaMigrations(unlockCtx); err != nil {
+			_ = conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createSchemaMigrations); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	rows, err := queries.ListSchemaMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	records := make(map[int32]sqlc.SchemaMigration, len(rows))
+	for _, row := range rows {
+		records[row.Version] = row
+	}
+	return fn(conn.Conn(), records)
+}
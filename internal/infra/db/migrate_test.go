@@ -0,0 +1,231 @@
+package db
+
+import (
+	"billing-api/internal/infra/db/sqlc"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		up      string
+		down    string
+		err     string
+	}{
+		{
+			name:    "up and down",
+			file:    "004_fees.sql",
+			content: "-- migrate:up\nCREATE TABLE fees (id INT);\n-- migrate:down\nDROP TABLE fees;\n",
+			up:      "CREATE TABLE fees (id INT);\n",
+			down:    "DROP TABLE fees;\n",
+		},
+		{
+			name:    "blank lines before the up marker",
+			file:    "004_fees.sql",
+			content: "\n  \n-- migrate:up\nSELECT 1;\n-- migrate:down\n-- can not be reverted\n",
+			up:      "SELECT 1;\n",
+			down:    "-- can not be reverted\n",
+		},
+		{
+			name:    "no up marker",
+			file:    "004_fees.sql",
+			content: "CREATE TABLE fees (id INT);\n",
+			err:     "statements before -- migrate:up",
+		},
+		{
+			name:    "statements before the up marker",
+			file:    "004_fees.sql",
+			content: "SELECT 1;\n-- migrate:up\nSELECT 2;\n-- migrate:down\n",
+			err:     "statements before -- migrate:up",
+		},
+		{
+			name:    "down before up",
+			file:    "004_fees.sql",
+			content: "-- migrate:down\nDROP TABLE fees;\n-- migrate:up\nCREATE TABLE fees (id INT);\n",
+			err:     "-- migrate:down must follow -- migrate:up",
+		},
+		{
+			name:    "two up markers",
+			file:    "004_fees.sql",
+			content: "-- migrate:up\nSELECT 1;\n-- migrate:up\nSELECT 2;\n-- migrate:down\n",
+			err:     "-- migrate:up must come first",
+		},
+		{
+			name:    "two down markers",
+			file:    "004_fees.sql",
+			content: "-- migrate:up\nSELECT 1;\n-- migrate:down\n-- migrate:down\n",
+			err:     "-- migrate:down must follow -- migrate:up",
+		},
+		{
+			name:    "empty up section",
+			file:    "004_fees.sql",
+			content: "-- migrate:up\n\n-- migrate:down\nDROP TABLE fees;\n",
+			err:     "no statement after -- migrate:up",
+		},
+		{
+			name:    "no down section",
+			file:    "004_fees.sql",
+			content: "-- migrate:up\nCREATE TABLE fees (id INT);\n",
+			err:     "no -- migrate:down section",
+		},
+		{
+			name: "no underscore in the name",
+			file: "004.sql",
+			err:  "the file must be named NNN_name.sql",
+		},
+		{
+			name: "version is not a number",
+			file: "fees_004.sql",
+			err:  "the file must be named NNN_name.sql",
+		},
+		{
+			name: "version zero",
+			file: "000_fees.sql",
+			err:  "the file must be named NNN_name.sql",
+		},
+		{
+			name: "version out of range",
+			file: "99999999999_fees.sql",
+			err:  "the file must be named NNN_name.sql",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration, err := parseMigration(tt.file, tt.content)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int32(4), migration.Version)
+			assert.Equal(t, "004_fees", migration.Name)
+			assert.Equal(t, tt.up, migration.Up)
+			assert.Equal(t, tt.down, migration.Down)
+			assert.Len(t, migration.Checksum, 64)
+		})
+	}
+
+	t.Run("checksum covers the whole file", func(t *testing.T) {
+		a, err := parseMigration("004_fees.sql", "-- migrate:up\nSELECT 1;\n-- migrate:down\n")
+		require.NoError(t, err)
+		b, err := parseMigration("004_fees.sql", "-- migrate:up\nSELECT 1;\n-- migrate:down\n-- can not be reverted\n")
+		require.NoError(t, err)
+		assert.NotEqual(t, a.Checksum, b.Checksum)
+	})
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(sql string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("-- migrate:up\n" + sql + "\n-- migrate:down\n")}
+	}
+
+	t.Run("sorted by version", func(t *testing.T) {
+		migrations, err := LoadMigrations(fstest.MapFS{
+			"10_fees.sql":      file("SELECT 10;"),
+			"002_mandates.sql": file("SELECT 2;"),
+			"001_init.sql":     file("SELECT 1;"),
+			"README.md":        {Data: []byte("not a migration")},
+		})
+		require.NoError(t, err)
+		var names []string
+		for _, migration := range migrations {
+			names = append(names, migration.Name)
+		}
+		assert.Equal(t, []string{"001_init", "002_mandates", "10_fees"}, names)
+	})
+
+	t.Run("same version twice", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{
+			"001_init.sql":   file("SELECT 1;"),
+			"002_fees.sql":   file("SELECT 2;"),
+			"2_mandates.sql": file("SELECT 2;"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "have the same version")
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{
+			"001_init.sql": file("SELECT 1;"),
+			"002_fees.sql": {Data: []byte("SELECT 2;\n")},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "migration 002_fees.sql")
+	})
+
+	t.Run("db/migrations", func(t *testing.T) {
+		migrations, err := LoadMigrations(os.DirFS("../../../db/migrations"))
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		assert.Equal(t, int32(1), migrations[0].Version)
+	})
+}
+
+func TestMigrator_Verify(t *testing.T) {
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"001_init.sql":     {Data: []byte("-- migrate:up\nSELECT 1;\n-- migrate:down\n")},
+		"002_mandates.sql": {Data: []byte("-- migrate:up\nSELECT 2;\n-- migrate:down\n")},
+		"003_fees.sql":     {Data: []byte("-- migrate:up\nSELECT 3;\n-- migrate:down\n")},
+	})
+	require.NoError(t, err)
+	m := &Migrator{migrations: migrations}
+
+	record := func(migration Migration, checksum pgtype.Text) sqlc.SchemaMigration {
+		return sqlc.SchemaMigration{Version: migration.Version, Name: migration.Name, Checksum: checksum}
+	}
+	verified := func(migration Migration) sqlc.SchemaMigration {
+		return record(migration, pgtype.Text{String: migration.Checksum, Valid: true})
+	}
+
+	tests := []struct {
+		name    string
+		records []sqlc.SchemaMigration
+		err     string
+	}{
+		{"nothing applied", nil, ""},
+		{"applied with their checksum", []sqlc.SchemaMigration{verified(migrations[0]), verified(migrations[1])}, ""},
+		{
+			name:    "applied by a newer release",
+			records: []sqlc.SchemaMigration{verified(migrations[0]), {Version: 4, Name: "004_next", Checksum: pgtype.Text{String: "abc", Valid: true}}},
+		},
+		{
+			name:    "file changed since",
+			records: []sqlc.SchemaMigration{verified(migrations[0]), record(migrations[1], pgtype.Text{String: "abc", Valid: true})},
+			err:     "migration 002_mandates changed after it was applied",
+		},
+		{
+			name:    "recorded without checksum",
+			records: []sqlc.SchemaMigration{record(migrations[0], pgtype.Text{}), record(migrations[1], pgtype.Text{}), verified(migrations[2])},
+			err:     "migration 001_init was recorded without checksum, check the schema matches the files and run migrate baseline 2",
+		},
+		{
+			name:    "the lowest version is reported first",
+			records: []sqlc.SchemaMigration{verified(migrations[0]), record(migrations[1], pgtype.Text{String: "abc", Valid: true}), record(migrations[2], pgtype.Text{})},
+			err:     "migration 002_mandates changed after it was applied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make(map[int32]sqlc.SchemaMigration, len(tt.records))
+			for _, r := range tt.records {
+				records[r.Version] = r
+			}
+			err := m.verify(records)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -2,10 +2,13 @@ package db
 
 import (
 	"billing-api/internal/config"
+	"billing-api/internal/domain"
 	"context"
 	"log"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -39,5 +42,35 @@ func NewPostgresPool(config *config.Config) (*pgxpool.Pool, error) {
 	log.Printf("set HealthCheckPeriod : %d seconds\n", config.HealthCheckPeriod)
 	cfg.HealthCheckPeriod = time.Duration(config.HealthCheckPeriod) * time.Second
 
+	// the row-level security policies of 008_tenants.sql read the tenant from app.tenant_id,
+	// it is set on every acquire as a connection serves a different tenant each time
+	if config.RowLevelSecurity {
+		log.Println("set app.tenant_id on every acquired connection for row-level security")
+		cfg.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+			_, err := conn.Exec(ctx, "SELECT set_config('app.tenant_id', $1, false)", domain.TenantFromContext(ctx))
+			return err == nil, err
+		}
+	}
+
+	// every query and COPY gets a span, see SpanTracer, and every query is timed, see QueryStatsTracer
+	log.Printf("set slow query threshold : %d ms\n", config.SlowQueryMs)
+	cfg.ConnConfig.Tracer = multitracer.New(
+		SpanTracer{},
+		QueryStatsTracer{SlowThreshold: time.Duration(config.SlowQueryMs) * time.Millisecond},
+	)
+
 	return pgxpool.NewWithConfig(ctx, cfg)
 }
+
+/*
+NewRateLimitPool opens the small pool of the Postgres rate limit store. The buckets are read on every request,
+a pool of their own keeps them readable when the requests exhaust the main pool, which is when limiting matters.
+The buckets are not scoped to a tenant, the row-level security setup is left out.
+*/
+func NewRateLimitPool(config *config.Config) (*pgxpool.Pool, error) {
+	rateLimit := *config
+	rateLimit.MaxConns = config.RateLimitDBMaxConns
+	rateLimit.MinConns = min(config.MinConns, config.RateLimitDBMaxConns)
+	rateLimit.RowLevelSecurity = false
+	return NewPostgresPool(&rateLimit)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"billing-api/internal/domain"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// queryStatsSamples is the number of latest runs of a statement its percentiles are computed over
+const queryStatsSamples = 1000
+
+type queryStat struct {
+	count   int64
+	errors  int64
+	slow    int64
+	max     time.Duration
+	samples []time.Duration // ring buffer of the latest durations
+	next    int
+}
+
+func (s *queryStat) add(d time.Duration, failed, slow bool) {
+	s.count++
+	if failed {
+		s.errors++
+	}
+	if slow {
+		s.slow++
+	}
+	s.max = max(s.max, d)
+	if len(s.samples) < queryStatsSamples {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % queryStatsSamples
+}
+
+// queryStats aggregates the statements of every pool since startup, like txRetryCounters
+var queryStats = struct {
+	mu     sync.Mutex
+	byName map[string]*queryStat
+}{byName: make(map[string]*queryStat)}
+
+func recordQuery(name string, d time.Duration, failed, slow bool) {
+	queryStats.mu.Lock()
+	defer queryStats.mu.Unlock()
+	stat, ok := queryStats.byName[name]
+	if !ok {
+		stat = &queryStat{}
+		queryStats.byName[name] = stat
+	}
+	stat.add(d, failed, slow)
+}
+
+// QueryStatsSnapshot returns the statistics of every statement run since startup, the slowest p95 first
+func QueryStatsSnapshot() []domain.QueryStat {
+	queryStats.mu.Lock()
+	defer queryStats.mu.Unlock()
+
+	snapshot := make([]domain.QueryStat, 0, len(queryStats.byName))
+	for name, stat := range queryStats.byName {
+		sorted := slices.Clone(stat.samples)
+		slices.Sort(sorted)
+		snapshot = append(snapshot, domain.QueryStat{
+			Name:   name,
+			Count:  stat.count,
+			Errors: stat.errors,
+			Slow:   stat.slow,
+			P50:    percentile(sorted, 0.5),
+			P95:    percentile(sorted, 0.95),
+			Max:    stat.max,
+		})
+	}
+	slices.SortFunc(snapshot, func(a, b domain.QueryStat) int {
+		return cmp.Or(cmp.Compare(b.P95, a.P95), cmp.Compare(a.Name, b.Name))
+	})
+	return snapshot
+}
+
+// percentile of sorted durations, by the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[max(0, int(math.Ceil(p*float64(len(sorted))))-1)]
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	name string
+	args []any // redacted only when the query turns out slow
+	at   time.Time
+}
+
+/*
+QueryStatsTracer times every query of the pool for QueryStatsSnapshot, and logs the ones taking longer than
+SlowThreshold as slow_query with the sqlc query name and the request ID of the context.
+The arguments are logged by type only, their values may carry personal data.
+*/
+type QueryStatsTracer struct {
+	SlowThreshold time.Duration // 0 logs no query
+}
+
+func (t QueryStatsTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{
+		name: statementName(data.SQL),
+		args: data.Args,
+		at:   time.Now(),
+	})
+}
+
+func (t QueryStatsTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	slow := t.SlowThreshold > 0 && elapsed >= t.SlowThreshold
+	recordQuery(start.name, elapsed, data.Err != nil, slow)
+
+	if slow {
+		attrs := []slog.Attr{
+			slog.String("query", start.name),
+			slog.Duration("duration", elapsed),
+			slog.Duration("threshold", t.SlowThreshold),
+			slog.Any("args", redactArgs(start.args)),
+		}
+		if data.Err != nil {
+			attrs = append(attrs, slog.Any("err", data.Err))
+		}
+		slog.LogAttrs(ctx, slog.LevelWarn, "slow_query", attrs...)
+	}
+}
+
+// redactArgs replaces the arguments of a query with their types, "$1=int64"
+func redactArgs(args []any) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = fmt.Sprintf("$%d=%T", i+1, arg)
+	}
+	return redacted
+}
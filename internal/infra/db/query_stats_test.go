@@ -0,0 +1,131 @@
+package db
+
+import (
+	"billing-api/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"-- name: GetLoanByID :one\nSELECT * FROM loans WHERE id = $1", "GetLoanByID"},
+		{"\n  -- name: ListPaymentsByLoanID :many\nSELECT 1", "ListPaymentsByLoanID"},
+		{"-- name: TryLockOutboxDispatch :one\n-- only one instance dispatches at a time\nSELECT 1", "TryLockOutboxDispatch"},
+		{"begin isolation level serializable", "BEGIN"},
+		{"commit", "COMMIT"},
+		{"SELECT set_config('app.tenant_id', $1, false)", "SELECT"},
+		{"-- a comment that is not a name\nSELECT 1", "--"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, statementName(tt.sql), tt.sql)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []any{int64(42), "4111111111111111", nil, []byte("secret"), time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)}
+	assert.Equal(t, []string{"$1=int64", "$2=string", "$3=<nil>", "$4=[]uint8", "$5=time.Time"}, redactArgs(args))
+	assert.Empty(t, redactArgs(nil))
+}
+
+func TestPercentile(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		sorted := make([]time.Duration, len(values))
+		for i, v := range values {
+			sorted[i] = time.Duration(v) * time.Millisecond
+		}
+		return sorted
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"no sample", nil, 0.95, 0},
+		{"single sample", ms(7), 0.5, 7 * time.Millisecond},
+		{"median of an even count is the lower one", ms(1, 2, 3, 4), 0.5, 2 * time.Millisecond},
+		{"median of an odd count", ms(1, 2, 3, 4, 5), 0.5, 3 * time.Millisecond},
+		{"p95 of 20 samples is the 19th", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20), 0.95, 19 * time.Millisecond},
+		{"p95 of few samples is the max", ms(1, 2, 3), 0.95, 3 * time.Millisecond},
+		{"p0 is the min", ms(1, 2, 3), 0, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestQueryStat_Add(t *testing.T) {
+	var stat queryStat
+	for i := 1; i <= queryStatsSamples+500; i++ {
+		stat.add(time.Duration(i)*time.Millisecond, i%100 == 0, i%10 == 0)
+	}
+	stat.add(time.Millisecond, false, false)
+
+	assert.Equal(t, int64(queryStatsSamples+501), stat.count)
+	assert.Equal(t, int64(15), stat.errors)
+	assert.Equal(t, int64(150), stat.slow)
+	assert.Equal(t, time.Duration(queryStatsSamples+500)*time.Millisecond, stat.max)
+
+	// the buffer keeps the latest runs only, the oldest are overwritten in turn
+	require.Len(t, stat.samples, queryStatsSamples)
+	sorted := slices.Clone(stat.samples)
+	slices.Sort(sorted)
+	assert.Equal(t, time.Millisecond, sorted[0])
+	assert.Equal(t, 502*time.Millisecond, sorted[1], "the runs 1 to 501 were overwritten")
+	assert.Equal(t, 501, stat.next)
+}
+
+func TestQueryStatsTracer(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	ctx := context.Background()
+	sql := "-- name: TestQueryStatsTracerLookup :one\nSELECT * FROM mandates WHERE account_number = $1 AND holder = $2"
+	args := []any{"1234567890", "Jane Doe"}
+	run := func(tracer QueryStatsTracer, err error) {
+		ctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: sql, Args: args})
+		time.Sleep(time.Millisecond)
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: err})
+	}
+
+	run(QueryStatsTracer{}, nil)
+	assert.Empty(t, logs.String(), "no query is logged without a threshold")
+
+	run(QueryStatsTracer{SlowThreshold: time.Nanosecond}, errors.New("canceling statement due to statement timeout"))
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "slow_query", record["msg"])
+	assert.Equal(t, "TestQueryStatsTracerLookup", record["query"])
+	assert.Equal(t, []any{"$1=string", "$2=string"}, record["args"])
+	assert.NotEmpty(t, record["err"])
+	for _, arg := range args {
+		assert.NotContains(t, logs.String(), arg, "argument values never reach the logs")
+	}
+
+	snapshot := QueryStatsSnapshot()
+	i := slices.IndexFunc(snapshot, func(s domain.QueryStat) bool { return s.Name == "TestQueryStatsTracerLookup" })
+	require.GreaterOrEqual(t, i, 0)
+	stat := snapshot[i]
+	assert.Equal(t, int64(2), stat.Count)
+	assert.Equal(t, int64(1), stat.Errors)
+	assert.Equal(t, int64(1), stat.Slow)
+	assert.GreaterOrEqual(t, stat.Max, time.Millisecond)
+}
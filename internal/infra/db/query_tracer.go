@@ -0,0 +1,71 @@
+package db
+
+import (
+	"billing-api/internal/tracing"
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryName reads the name sqlc puts at the top of every query, "-- name: GetLoanByID :one"
+func queryName(sql string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(sql), "\n")
+	name, ok := strings.CutPrefix(line, "-- name: ")
+	if !ok {
+		return ""
+	}
+	name, _, _ = strings.Cut(name, " ")
+	return name
+}
+
+// statementName is the sqlc name of the query, or its first keyword for the statements of the driver,
+// of set_config and the BEGIN and COMMIT of the transactions
+func statementName(sql string) string {
+	if name := queryName(sql); name != "" {
+		return name
+	}
+	keyword, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	return strings.ToUpper(keyword)
+}
+
+/*
+SpanTracer starts a client span for every query and COPY of the pool, named after the sqlc query.
+The statement is recorded without its arguments, they may carry personal data.
+*/
+type SpanTracer struct{}
+
+func (SpanTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, _ = tracing.Start(ctx, "db "+statementName(data.SQL), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.operation.name", queryName(data.SQL)),
+		attribute.String("db.query.text", data.SQL),
+	))
+	return ctx
+}
+
+func (SpanTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	tracing.End(span, data.Err)
+}
+
+func (SpanTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	ctx, _ = tracing.Start(ctx, "db COPY "+data.TableName.Sanitize(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.collection.name", data.TableName.Sanitize()),
+	))
+	return ctx
+}
+
+func (SpanTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	}
+	tracing.End(span, data.Err)
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresAPIKeyRepo manages the keys of the tenant of the context, a key is created for the tenant of its command
+type PostgresAPIKeyRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresAPIKeyRepo(pool *pgxpool.Pool) *PostgresAPIKeyRepo {
+	return &PostgresAPIKeyRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+func (r *PostgresAPIKeyRepo) WithTx(ctx context.Context, fn func(repo domain.APIKeyRepository) error) error {
+	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+		return fn(&PostgresAPIKeyRepo{
+			queries: r.queries.WithTx(tx),
+			pool:    r.pool,
+		})
+	})
+}
+
+func (r *PostgresAPIKeyRepo) InsertAPIKey(ctx context.Context, arg domain.CreateAPIKeyCommand) (*domain.APIKey, error) {
+	return runWithTimeout(ctx, "InsertAPIKey", 1, func(ctx context.Context) (*domain.APIKey, error) {
+		k, err := r.queries.InsertAPIKey(ctx, *MapCreateAPIKeyCommand(&arg))
+		if err != nil {
+			return nil, err
+		}
+		return MapAPIKey(k), nil
+	})
+}
+
+func (r *PostgresAPIKeyRepo) GetAPIKeyByID(ctx context.Context, id int64) (*domain.APIKey, error) {
+	return runWithTimeout(ctx, "GetAPIKeyByID", 1, func(ctx context.Context) (*domain.APIKey, error) {
+		k, err := r.queries.GetAPIKeyByID(ctx, sqlc.GetAPIKeyByIDParams{ID: id, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrAPIKeyNotFound
+			}
+			return nil, err
+		}
+		return MapAPIKey(k), nil
+	})
+}
+
+// GetAPIKeyByPrefix finds the key presented by a caller whatever its tenant, revoked and expired keys included
+func (r *PostgresAPIKeyRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	return runWithTimeout(ctx, "GetAPIKeyByPrefix", 1, func(ctx context.Context) (*domain.APIKey, error) {
+		k, err := r.queries.GetAPIKeyByPrefix(ctx, prefix)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrAPIKeyNotFound
+			}
+			return nil, err
+		}
+		return MapAPIKey(k), nil
+	})
+}
+
+func (r *PostgresAPIKeyRepo) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return runWithTimeout(ctx, "ListAPIKeys", 1, func(ctx context.Context) ([]domain.APIKey, error) {
+		rows, err := r.queries.ListAPIKeys(ctx, domain.TenantFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]domain.APIKey, 0, len(rows))
+		for _, row := range rows {
+			keys = append(keys, *MapAPIKey(row))
+		}
+		return keys, nil
+	})
+}
+
+func (r *PostgresAPIKeyRepo) ExpireAPIKey(ctx context.Context, id int64, at time.Time) error {
+	_, err := runWithTimeout(ctx, "ExpireAPIKey", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.ExpireAPIKey(ctx, sqlc.ExpireAPIKeyParams{
+			ID:        id,
+			TenantID:  domain.TenantFromContext(ctx),
+			ExpiresAt: pgtype.Timestamp{Time: at, Valid: true},
+		})
+	})
+	return err
+}
+
+// RevokeAPIKey disables a key at once, revoking it twice is reported as not found
+func (r *PostgresAPIKeyRepo) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*domain.APIKey, error) {
+	return runWithTimeout(ctx, "RevokeAPIKey", 1, func(ctx context.Context) (*domain.APIKey, error) {
+		k, err := r.queries.RevokeAPIKey(ctx, sqlc.RevokeAPIKeyParams{
+			ID:        id,
+			TenantID:  domain.TenantFromContext(ctx),
+			RevokedAt: pgtype.Timestamp{Time: at, Valid: true},
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrAPIKeyNotFound
+			}
+			return nil, err
+		}
+		return MapAPIKey(k), nil
+	})
+}
+
+func (r *PostgresAPIKeyRepo) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	_, err := runWithTimeout(ctx, "TouchAPIKey", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.TouchAPIKey(ctx, sqlc.TouchAPIKeyParams{
+			ID:         id,
+			LastUsedAt: pgtype.Timestamp{Time: at, Valid: true},
+		})
+	})
+	return err
+}
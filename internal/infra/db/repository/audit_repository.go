@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+PostgresAuditRepo reads the audit log of the tenant of the context.
+
+The other repositories write their entries in their own transactions, this one only writes the changes made
+outside of the database, like the log level.
+*/
+type PostgresAuditRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresAuditRepo(pool *pgxpool.Pool) *PostgresAuditRepo {
+	return &PostgresAuditRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+// InsertAuditEntry appends the entry in a transaction of its own, the chain lock needs one
+func (r *PostgresAuditRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+		return insertAuditEntry(ctx, r.queries.WithTx(tx), arg)
+	})
+}
+
+func (r *PostgresAuditRepo) ListAuditEntries(ctx context.Context, arg domain.ListAuditEntriesQuery) ([]domain.AuditEntry, error) {
+	return runWithTimeout(ctx, "ListAuditEntries", int(arg.Limit), func(ctx context.Context) ([]domain.AuditEntry, error) {
+		params := sqlc.ListAuditEntriesParams{
+			TenantID:   domain.TenantFromContext(ctx),
+			Actor:      textParam(arg.Actor),
+			Action:     textParam(arg.Action),
+			EntityType: textParam(arg.EntityType),
+			EntityID:   textParam(arg.EntityID),
+			LimitVal:   arg.Limit,
+		}
+		if arg.CursorID != nil {
+			params.CursorID = pgtype.Int8{Int64: *arg.CursorID, Valid: true}
+		}
+		if arg.From != nil {
+			params.OccurredFrom = pgtype.Timestamp{Time: arg.From.UTC(), Valid: true}
+		}
+		if arg.To != nil {
+			params.OccurredTo = pgtype.Timestamp{Time: arg.To.UTC(), Valid: true}
+		}
+		rows, err := r.queries.ListAuditEntries(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]domain.AuditEntry, 0, len(rows))
+		for _, row := range rows {
+			entries = append(entries, MapAuditEntry(row))
+		}
+		return entries, nil
+	})
+}
+
+func (r *PostgresAuditRepo) ListAuditChain(ctx context.Context, afterID int64, limit int32) ([]domain.AuditEntry, error) {
+	return runWithTimeout(ctx, "ListAuditChain", int(limit), func(ctx context.Context) ([]domain.AuditEntry, error) {
+		rows, err := r.queries.ListAuditChain(ctx, sqlc.ListAuditChainParams{
+			TenantID: domain.TenantFromContext(ctx),
+			AfterID:  afterID,
+			LimitVal: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]domain.AuditEntry, 0, len(rows))
+		for _, row := range rows {
+			entries = append(entries, MapAuditEntry(row))
+		}
+		return entries, nil
+	})
+}
+
+/*
+insertAuditEntry chains the entry to the last one of the tenant and appends it, queries must be bound to a transaction.
+
+The chain lock is held until the end of the transaction, the repositories write the entry last to keep it short.
+*/
+func insertAuditEntry(ctx context.Context, queries *sqlc.Queries, arg domain.CreateAuditEntryCommand) error {
+	_, err := runWithTimeout(ctx, "InsertAuditEntry", 1, func(ctx context.Context) (struct{}, error) {
+		tenantID := domain.TenantFromContext(ctx)
+		if err := queries.LockAuditChain(ctx, tenantID); err != nil {
+			return struct{}{}, err
+		}
+		prevHash, err := queries.GetLastAuditHash(ctx, tenantID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return struct{}{}, err
+		}
+
+		entry := newAuditEntry(tenantID, prevHash, arg)
+		_, err = queries.InsertAuditEntry(ctx, *MapAuditEntryParams(&entry))
+		return struct{}{}, err
+	})
+	return err
+}
+
+// newAuditEntry is the entry of arg following prevHash, hashed
+func newAuditEntry(tenantID, prevHash string, arg domain.CreateAuditEntryCommand) domain.AuditEntry {
+	entry := domain.AuditEntry{
+		TenantID:       tenantID,
+		OccurredAt:     arg.OccurredAt,
+		Actor:          arg.Actor,
+		Action:         arg.Action,
+		EntityType:     arg.EntityType,
+		EntityID:       arg.EntityID,
+		Before:         arg.Before,
+		After:          arg.After,
+		RequestID:      arg.RequestID,
+		IdempotencyKey: arg.IdempotencyKey,
+		SourceIP:       arg.SourceIP,
+		PrevHash:       prevHash,
+	}
+	entry.Hash = entry.ComputeHash()
+	return entry
+}
+
+func (r *PostgresRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	return insertAuditEntry(ctx, r.queries, arg)
+}
+
+func (r *PostgresCollectionRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	return insertAuditEntry(ctx, r.queries, arg)
+}
+
+func (r *PostgresWebhookRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	return insertAuditEntry(ctx, r.queries, arg)
+}
+
+func (r *PostgresAPIKeyRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	return insertAuditEntry(ctx, r.queries, arg)
+}
@@ -4,6 +4,8 @@ import (
 	"billing-api/internal/domain"
 	"billing-api/internal/infra/db"
 	"billing-api/internal/infra/db/sqlc"
+	"billing-api/internal/metrics"
+	"billing-api/internal/tracing"
 	"context"
 	"database/sql"
 	"errors"
@@ -17,6 +19,10 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+/*
+PostgresRepo scopes every query to the tenant of the context, see domain.TenantFromContext.
+A loan of another tenant is not found.
+*/
 type PostgresRepo struct {
 	pool    *pgxpool.Pool
 	queries *sqlc.Queries
@@ -47,13 +53,20 @@ func getContextWithTimeout(ctx context.Context, label string, rowCount int) (con
 	return context.WithTimeoutCause(ctx, timeout, cause)
 }
 
-func runWithTimeout[T any](ctx context.Context, label string, rowCount int, fn func(context.Context) (T, error)) (T, error) {
+// runWithTimeout runs a repository call in a span of its own, the pgx queries it makes are its children
+func runWithTimeout[T any](ctx context.Context, label string, rowCount int, fn func(context.Context) (T, error)) (t T, err error) {
+	ctx, span := tracing.Start(ctx, "repo "+label)
+	defer func() { tracing.End(span, err) }()
+
 	childCtx, cancel := getContextWithTimeout(ctx, label, rowCount)
 	defer cancel()
 
-	t, err := fn(childCtx)
+	start := time.Now()
+	t, err = fn(childCtx)
+	metrics.RepoCallDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
+			metrics.RepoTimeouts.WithLabelValues(label).Inc()
 			// return the custom cause as the error itself
 			var zero T
 			return zero, context.Cause(childCtx)
@@ -64,7 +77,12 @@ func runWithTimeout[T any](ctx context.Context, label string, rowCount int, fn f
 }
 
 func (r *PostgresRepo) WithTx(ctx context.Context, fn func(repo domain.BillingRepository) error) error {
-	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+	return r.WithTxOptions(ctx, domain.TxOptions{}, fn)
+}
+
+// WithTxOptions runs fn in a transaction with the given isolation level, access mode and retry policy
+func (r *PostgresRepo) WithTxOptions(ctx context.Context, opts domain.TxOptions, fn func(repo domain.BillingRepository) error) error {
+	return db.WithTxOptions(ctx, r.pool, opts, func(tx pgx.Tx) error {
 		// Create a new repository that uses the transaction instead of the pool
 		txRepo := &PostgresRepo{
 			queries: r.queries.WithTx(tx),
@@ -79,7 +97,7 @@ func (r *PostgresRepo) WithTx(ctx context.Context, fn func(repo domain.BillingRe
 func (r *PostgresRepo) GetLoanByID(ctx context.Context, id int64) (*domain.Loan, error) {
 
 	return runWithTimeout(ctx, "GetLoanByID", 1, func(ctx context.Context) (*domain.Loan, error) {
-		l, err := r.queries.GetLoanByID(ctx, id)
+		l, err := r.queries.GetLoanByID(ctx, sqlc.GetLoanByIDParams{ID: id, TenantID: domain.TenantFromContext(ctx)})
 		if err != nil {
 			var zero *domain.Loan
 			return zero, err
@@ -89,11 +107,49 @@ func (r *PostgresRepo) GetLoanByID(ctx context.Context, id int64) (*domain.Loan,
 
 }
 
+// ListLoans retrieves the loans newest first, only those of the borrower when borrowerID is set
+func (r *PostgresRepo) ListLoans(ctx context.Context, cursorID *int64, borrowerID *string, limit int32) ([]domain.Loan, error) {
+	return runWithTimeout(ctx, "ListLoans", int(limit), func(ctx context.Context) ([]domain.Loan, error) {
+		params := sqlc.ListLoansParams{TenantID: domain.TenantFromContext(ctx), LimitVal: limit}
+		if cursorID != nil {
+			params.CursorID = pgtype.Int8{Int64: *cursorID, Valid: true}
+		}
+		if borrowerID != nil {
+			params.BorrowerID = pgtype.Text{String: *borrowerID, Valid: true}
+		}
+		rows, err := r.queries.ListLoans(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		loans := make([]domain.Loan, 0, len(rows))
+		for _, l := range rows {
+			loans = append(loans, *MapLoan(l))
+		}
+		return loans, nil
+	})
+}
+
+// LockLoanForUpdate retrieves a loan and locks its row until the transaction ends
+func (r *PostgresRepo) LockLoanForUpdate(ctx context.Context, id int64) (*domain.Loan, error) {
+	return runWithTimeout(ctx, "LockLoanForUpdate", 1, func(ctx context.Context) (*domain.Loan, error) {
+		l, err := r.queries.LockLoanForUpdate(ctx, sqlc.LockLoanForUpdateParams{ID: id, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrLoanNotFound
+			}
+			return nil, err
+		}
+		return MapLoan(l), nil
+	})
+}
+
 // InsertLoan creates a new loan record
 func (r *PostgresRepo) InsertLoan(ctx context.Context, arg domain.CreateLoanCommand) (*domain.Loan, error) {
 	// set proper timeout for this process
 	return runWithTimeout(ctx, "InsertLoan", 1, func(ctx context.Context) (*domain.Loan, error) {
-		l, err := r.queries.InsertLoan(ctx, *MapCreateLoanCommand(&arg))
+		params := MapCreateLoanCommand(&arg)
+		params.TenantID = domain.TenantFromContext(ctx)
+		l, err := r.queries.InsertLoan(ctx, *params)
 		if err != nil {
 			var zero *domain.Loan
 			return zero, err
@@ -105,27 +161,32 @@ func (r *PostgresRepo) InsertLoan(ctx context.Context, arg domain.CreateLoanComm
 // PAYMENT RELATED
 // GetTotalPaidAmount calculates the sum of all payments for a loan
 func (r *PostgresRepo) GetTotalPaidAmount(ctx context.Context, loanID int64) (int64, error) {
-	return r.queries.GetTotalPaidAmount(ctx, loanID)
+	return r.queries.GetTotalPaidAmount(ctx, sqlc.GetTotalPaidAmountParams{LoanID: loanID, TenantID: domain.TenantFromContext(ctx)})
 }
 
 // GetPaidWeeksCount counts how many weekly payments have been made
 func (r *PostgresRepo) GetPaidWeeksCount(ctx context.Context, loanID int64) (int32, error) {
-	return r.queries.GetPaidWeeksCount(ctx, loanID)
+	return r.queries.GetPaidWeeksCount(ctx, sqlc.GetPaidWeeksCountParams{LoanID: loanID, TenantID: domain.TenantFromContext(ctx)})
 }
 
 // GetLastPaidWeek finds the highest week_number recorded in payments
 func (r *PostgresRepo) GetLastPaidWeek(ctx context.Context, loanID int64) (int32, error) {
-	return r.queries.GetLastPaidWeek(ctx, loanID)
+	return r.queries.GetLastPaidWeek(ctx, sqlc.GetLastPaidWeekParams{LoanID: loanID, TenantID: domain.TenantFromContext(ctx)})
 }
 
 // InsertPayment records a new payment for a specific week
 func (r *PostgresRepo) InsertPayment(ctx context.Context, arg domain.CreatePaymentComand) (*domain.Payment, error) {
 	return runWithTimeout(ctx, "InsertPayment", 1, func(ctx context.Context) (*domain.Payment, error) {
-		p, err := r.queries.InsertPayment(ctx, *MapCreatePaymentComand(&arg))
+		params := MapCreatePaymentComand(&arg)
+		params.TenantID = domain.TenantFromContext(ctx)
+		p, err := r.queries.InsertPayment(ctx, *params)
 		if err != nil {
 			var zero *domain.Payment
 			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
-				// This is a "Duplicate" error.
+				// the same idempotency key is a "Duplicate", the same week means another payment won the race
+				if pgErr.ConstraintName == "uk_payments_loan_id_and_week_number" {
+					return zero, domain.ErrConcurrentPayment
+				}
 				return zero, domain.ErrDuplicatePayment
 			}
 			return zero, err
@@ -134,11 +195,29 @@ func (r *PostgresRepo) InsertPayment(ctx context.Context, arg domain.CreatePayme
 	})
 }
 
+// GetPaymentByIdempotencyKey retrieves the payment posted with the key, idempotency keys are unique per tenant
+func (r *PostgresRepo) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*domain.Payment, error) {
+	return runWithTimeout(ctx, "GetPaymentByIdempotencyKey", 1, func(ctx context.Context) (*domain.Payment, error) {
+		p, err := r.queries.GetPaymentByIdempotencyKey(ctx, sqlc.GetPaymentByIdempotencyKeyParams{
+			TenantID:       domain.TenantFromContext(ctx),
+			IdempotencyKey: idempotencyKey,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrPaymentNotFound
+			}
+			return nil, err
+		}
+		return MapPayment(p), nil
+	})
+}
+
 // ListPaymentsByLoanID handles paginated retrieval of payments
 func (r *PostgresRepo) ListPaymentsByLoanID(ctx context.Context, arg domain.ListPaymentsQuery) ([]domain.Payment, error) {
 	return runWithTimeout(ctx, "List payments based on loanID", 1, func(ctx context.Context) ([]domain.Payment, error) {
 		params := sqlc.ListPaymentsByLoanIDParams{
 			LoanID:   arg.LoanID,
+			TenantID: domain.TenantFromContext(ctx),
 			LimitVal: int32(arg.LimitVal),
 		}
 		// ensure cursor not null or by default use nil as value for paidAt and id
@@ -167,6 +246,37 @@ func (r *PostgresRepo) ListPaymentsByLoanID(ctx context.Context, arg domain.List
 	})
 }
 
+// ListPaymentsByLoanIDInPeriod retrieves every payment made within [PeriodStart, PeriodEnd)
+func (r *PostgresRepo) ListPaymentsByLoanIDInPeriod(ctx context.Context, arg domain.StatementPeriodQuery) ([]domain.Payment, error) {
+	return runWithTimeout(ctx, "List payments based on loanID and period", 10, func(ctx context.Context) ([]domain.Payment, error) {
+		paymentRows, err := r.queries.ListPaymentsByLoanIDInPeriod(ctx, sqlc.ListPaymentsByLoanIDInPeriodParams{
+			LoanID:      arg.LoanID,
+			TenantID:    domain.TenantFromContext(ctx),
+			PeriodStart: pgtype.Timestamp{Time: arg.PeriodStart, Valid: true},
+			PeriodEnd:   pgtype.Timestamp{Time: arg.PeriodEnd, Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		payments := make([]domain.Payment, 0, len(paymentRows))
+		for _, r := range paymentRows {
+			payments = append(payments, MapListPaymentsByLoanIDInPeriodRow(r))
+		}
+		return payments, nil
+	})
+}
+
+// GetTotalPaidAmountBefore calculates the sum of payments made strictly before the given time
+func (r *PostgresRepo) GetTotalPaidAmountBefore(ctx context.Context, loanID int64, before time.Time) (int64, error) {
+	return runWithTimeout(ctx, "GetTotalPaidAmountBefore", 1, func(ctx context.Context) (int64, error) {
+		return r.queries.GetTotalPaidAmountBefore(ctx, sqlc.GetTotalPaidAmountBeforeParams{
+			LoanID:   loanID,
+			TenantID: domain.TenantFromContext(ctx),
+			Before:   pgtype.Timestamp{Time: before, Valid: true},
+		})
+	})
+}
+
 // SCHEDULE RELATED
 // CreateLoanSchedule record schedule during loan creation
 func (r *PostgresRepo) CreateLoanSchedules(ctx context.Context, arg []domain.LoanSchedule) (int64, error) {
@@ -179,6 +289,7 @@ func (r *PostgresRepo) CreateLoanSchedules(ctx context.Context, arg []domain.Loa
 		// 	return 0, err
 		// }
 		params := make([]sqlc.CreateLoanSchedulesParams, len(arg))
+		tenantID := domain.TenantFromContext(ctx)
 		for i, s := range arg {
 			params[i] = sqlc.CreateLoanSchedulesParams{
 				LoanID:   s.LoanID,
@@ -186,6 +297,7 @@ func (r *PostgresRepo) CreateLoanSchedules(ctx context.Context, arg []domain.Loa
 				DueDate:  pgtype.Date{Time: s.DueDate, Valid: true},
 				Amount:   s.Amount,
 				Status:   "PENDING",
+				TenantID: tenantID,
 			}
 		}
 		return r.queries.CreateLoanSchedules(ctx, params)
@@ -197,6 +309,7 @@ func (r *PostgresRepo) ListSchedulesByLoanID(ctx context.Context, arg domain.Lis
 	return runWithTimeout(ctx, "List schedule based on loan ID", 10, func(ctx context.Context) ([]domain.LoanSchedule, error) {
 		schedules, err := r.queries.ListSchedulesByLoanIDWithCursor(ctx, sqlc.ListSchedulesByLoanIDWithCursorParams{
 			LoanID:   arg.LoanID,
+			TenantID: domain.TenantFromContext(ctx),
 			Limit:    arg.Limit,
 			Sequence: arg.CursorSequence,
 		})
@@ -216,6 +329,7 @@ func (r *PostgresRepo) UpdateSchedulePayment(ctx context.Context, arg domain.Upd
 	return runWithTimeout(ctx, "Update schedule payment", 1, func(ctx context.Context) (int64, error) {
 		id, err := r.queries.UpdateSchedulePayment(ctx, sqlc.UpdateSchedulePaymentParams{
 			LoanID:     arg.LoanID,
+			TenantID:   domain.TenantFromContext(ctx),
 			Sequence:   arg.Sequence,
 			PaidAmount: arg.PaidAmount,
 		})
@@ -230,6 +344,60 @@ func (r *PostgresRepo) UpdateSchedulePayment(ctx context.Context, arg domain.Upd
 	})
 }
 
+// ListUpcomingSchedules retrieves the schedules due from a date in sequence order, paid as they were on that date
+func (r *PostgresRepo) ListUpcomingSchedules(ctx context.Context, arg domain.UpcomingSchedulesQuery) ([]domain.LoanSchedule, error) {
+	return runWithTimeout(ctx, "ListUpcomingSchedules", int(arg.Limit), func(ctx context.Context) ([]domain.LoanSchedule, error) {
+		schedules, err := r.queries.ListUpcomingSchedules(ctx, sqlc.ListUpcomingSchedulesParams{
+			DueFrom:  pgtype.Timestamp{Time: arg.DueFrom, Valid: true},
+			LoanID:   arg.LoanID,
+			TenantID: domain.TenantFromContext(ctx),
+			MaxRows:  arg.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		loanSchedules := make([]domain.LoanSchedule, 0, len(schedules))
+		for _, s := range schedules {
+			loanSchedules = append(loanSchedules, MapUpcomingSchedule(s))
+		}
+		return loanSchedules, nil
+	})
+}
+
+// EVENT RELATED
+// InsertOutboxEvent stores a domain event, callers run it inside WithTx so the event commits with the change
+func (r *PostgresRepo) InsertOutboxEvent(ctx context.Context, arg domain.CreateOutboxEventCommand) (int64, error) {
+	return runWithTimeout(ctx, "InsertOutboxEvent", 1, func(ctx context.Context) (int64, error) {
+		return r.queries.InsertOutboxEvent(ctx, sqlc.InsertOutboxEventParams{
+			AggregateType: arg.AggregateType,
+			AggregateID:   arg.AggregateID,
+			EventType:     arg.EventType,
+			Payload:       arg.Payload,
+			TenantID:      domain.TenantFromContext(ctx),
+		})
+	})
+}
+
+// ListNewlyDelinquentLoans finds loans whose unpaid gap reached gapWeeks since their last LoanBecameDelinquent event
+func (r *PostgresRepo) ListNewlyDelinquentLoans(ctx context.Context, evaluatedAt time.Time, gapWeeks int32, limit int32) ([]domain.DelinquentLoan, error) {
+	return runWithTimeout(ctx, "List newly delinquent loans", int(limit), func(ctx context.Context) ([]domain.DelinquentLoan, error) {
+		rows, err := r.queries.ListNewlyDelinquentLoans(ctx, sqlc.ListNewlyDelinquentLoansParams{
+			TenantID:    domain.TenantFromContext(ctx),
+			EvaluatedAt: pgtype.Timestamp{Time: evaluatedAt, Valid: true},
+			GapWeeks:    gapWeeks,
+			LimitVal:    limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		loans := make([]domain.DelinquentLoan, 0, len(rows))
+		for _, row := range rows {
+			loans = append(loans, MapDelinquentLoan(row))
+		}
+		return loans, nil
+	})
+}
+
 // timeout simulator
 func simulateContextTimeout[T any](ctx context.Context) (T, error) {
 	var zero T
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresCollectionRepo scopes the mandates by the tenant of their loan and the batches by their own
+type PostgresCollectionRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresCollectionRepo(pool *pgxpool.Pool) *PostgresCollectionRepo {
+	return &PostgresCollectionRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+func (r *PostgresCollectionRepo) WithTx(ctx context.Context, fn func(repo domain.CollectionRepository) error) error {
+	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+		txRepo := &PostgresCollectionRepo{
+			queries: r.queries.WithTx(tx),
+			pool:    r.pool,
+		}
+		return fn(txRepo)
+	})
+}
+
+// Billing returns a billing repository on the transaction of r, or on the pool outside of WithTx
+func (r *PostgresCollectionRepo) Billing() domain.BillingRepository {
+	return &PostgresRepo{
+		pool:    r.pool,
+		queries: r.queries,
+	}
+}
+
+// MANDATE RELATED
+// InsertMandate registers a new active mandate for a loan
+func (r *PostgresCollectionRepo) InsertMandate(ctx context.Context, arg domain.CreateMandateCommand) (*domain.Mandate, error) {
+	return runWithTimeout(ctx, "InsertMandate", 1, func(ctx context.Context) (*domain.Mandate, error) {
+		m, err := r.queries.InsertMandate(ctx, *MapCreateMandateCommand(&arg))
+		if err != nil {
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+				return nil, domain.ErrMandateAlreadyActive
+			}
+			return nil, err
+		}
+		return MapMandate(m), nil
+	})
+}
+
+// GetActiveMandateByLoanID retrieves the currently active mandate of a loan
+func (r *PostgresCollectionRepo) GetActiveMandateByLoanID(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	return runWithTimeout(ctx, "GetActiveMandateByLoanID", 1, func(ctx context.Context) (*domain.Mandate, error) {
+		m, err := r.queries.GetActiveMandateByLoanID(ctx, sqlc.GetActiveMandateByLoanIDParams{LoanID: loanID, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrMandateNotFound
+			}
+			return nil, err
+		}
+		return MapMandate(m), nil
+	})
+}
+
+// RevokeMandate deactivates the active mandate of a loan
+func (r *PostgresCollectionRepo) RevokeMandate(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	return runWithTimeout(ctx, "RevokeMandate", 1, func(ctx context.Context) (*domain.Mandate, error) {
+		m, err := r.queries.RevokeMandate(ctx, sqlc.RevokeMandateParams{LoanID: loanID, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrMandateNotFound
+			}
+			return nil, err
+		}
+		return MapMandate(m), nil
+	})
+}
+
+// COLLECTION RUN RELATED
+// LockCollectionRun takes a transaction scoped advisory lock so only one instance builds a batch at a time
+func (r *PostgresCollectionRepo) LockCollectionRun(ctx context.Context) error {
+	_, err := runWithTimeout(ctx, "LockCollectionRun", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.LockCollectionRun(ctx)
+	})
+	return err
+}
+
+// ListCollectionCandidates finds due and unpaid schedules that can be debited on the collection date
+func (r *PostgresCollectionRepo) ListCollectionCandidates(ctx context.Context, collectionDate time.Time) ([]domain.CollectionCandidate, error) {
+	return runWithTimeout(ctx, "List collection candidates", 10, func(ctx context.Context) ([]domain.CollectionCandidate, error) {
+		rows, err := r.queries.ListCollectionCandidates(ctx, sqlc.ListCollectionCandidatesParams{
+			TenantID:       domain.TenantFromContext(ctx),
+			CollectionDate: pgtype.Date{Time: collectionDate, Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates := make([]domain.CollectionCandidate, 0, len(rows))
+		for _, row := range rows {
+			candidates = append(candidates, MapCollectionCandidate(row))
+		}
+		return candidates, nil
+	})
+}
+
+// ConsumeDueRetries marks retries that are picked up by the current run so they are not collected twice
+func (r *PostgresCollectionRepo) ConsumeDueRetries(ctx context.Context, collectionDate time.Time) error {
+	_, err := runWithTimeout(ctx, "ConsumeDueRetries", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.ConsumeDueRetries(ctx, sqlc.ConsumeDueRetriesParams{
+			CollectionDate: pgtype.Date{Time: collectionDate, Valid: true},
+			TenantID:       domain.TenantFromContext(ctx),
+		})
+	})
+	return err
+}
+
+// InsertCollectionBatch creates the batch header
+func (r *PostgresCollectionRepo) InsertCollectionBatch(ctx context.Context, arg domain.CreateCollectionBatchCommand) (*domain.CollectionBatch, error) {
+	return runWithTimeout(ctx, "InsertCollectionBatch", 1, func(ctx context.Context) (*domain.CollectionBatch, error) {
+		b, err := r.queries.InsertCollectionBatch(ctx, sqlc.InsertCollectionBatchParams{
+			CollectionDate: pgtype.Date{Time: arg.CollectionDate, Valid: true},
+			ItemCount:      arg.ItemCount,
+			TotalAmount:    arg.TotalAmount,
+			TenantID:       domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return MapCollectionBatch(b), nil
+	})
+}
+
+// CreateCollectionItems batch inserts the debit instructions of a batch
+func (r *PostgresCollectionRepo) CreateCollectionItems(ctx context.Context, arg []domain.CreateCollectionItemCommand) (int64, error) {
+	return runWithTimeout(ctx, "Batch insert collection items", len(arg), func(ctx context.Context) (int64, error) {
+		params := make([]sqlc.CreateCollectionItemsParams, len(arg))
+		for i, item := range arg {
+			params[i] = sqlc.CreateCollectionItemsParams{
+				BatchID:          item.BatchID,
+				LoanID:           item.LoanID,
+				MandateID:        item.MandateID,
+				ScheduleSequence: item.ScheduleSequence,
+				Amount:           item.Amount,
+				Attempt:          item.Attempt,
+				Status:           domain.CollectionItemStatusSubmitted,
+			}
+		}
+		return r.queries.CreateCollectionItems(ctx, params)
+	})
+}
+
+// BATCH RELATED
+// GetCollectionBatchByID retrieves a batch header
+func (r *PostgresCollectionRepo) GetCollectionBatchByID(ctx context.Context, id int64) (*domain.CollectionBatch, error) {
+	return runWithTimeout(ctx, "GetCollectionBatchByID", 1, func(ctx context.Context) (*domain.CollectionBatch, error) {
+		b, err := r.queries.GetCollectionBatchByID(ctx, sqlc.GetCollectionBatchByIDParams{ID: id, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrCollectionBatchNotFound
+			}
+			return nil, err
+		}
+		return MapCollectionBatch(b), nil
+	})
+}
+
+// LockCollectionBatchForUpdate retrieves a batch header and locks its row until the transaction ends
+func (r *PostgresCollectionRepo) LockCollectionBatchForUpdate(ctx context.Context, id int64) (*domain.CollectionBatch, error) {
+	return runWithTimeout(ctx, "LockCollectionBatchForUpdate", 1, func(ctx context.Context) (*domain.CollectionBatch, error) {
+		b, err := r.queries.LockCollectionBatchForUpdate(ctx, sqlc.LockCollectionBatchForUpdateParams{ID: id, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrCollectionBatchNotFound
+			}
+			return nil, err
+		}
+		return MapCollectionBatch(b), nil
+	})
+}
+
+// UpdateCollectionBatchStatus moves a batch through CREATED -> EXPORTED -> RECONCILED
+func (r *PostgresCollectionRepo) UpdateCollectionBatchStatus(ctx context.Context, id int64, status string) (*domain.CollectionBatch, error) {
+	return runWithTimeout(ctx, "UpdateCollectionBatchStatus", 1, func(ctx context.Context) (*domain.CollectionBatch, error) {
+		b, err := r.queries.UpdateCollectionBatchStatus(ctx, sqlc.UpdateCollectionBatchStatusParams{
+			Status:   status,
+			ID:       id,
+			TenantID: domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrCollectionBatchNotFound
+			}
+			return nil, err
+		}
+		return MapCollectionBatch(b), nil
+	})
+}
+
+// ListCollectionItemsByBatchID retrieves every item of a batch together with its mandate details
+func (r *PostgresCollectionRepo) ListCollectionItemsByBatchID(ctx context.Context, batchID int64) ([]domain.CollectionItem, error) {
+	return runWithTimeout(ctx, "List collection items based on batch ID", 10, func(ctx context.Context) ([]domain.CollectionItem, error) {
+		rows, err := r.queries.ListCollectionItemsByBatchID(ctx, sqlc.ListCollectionItemsByBatchIDParams{BatchID: batchID, TenantID: domain.TenantFromContext(ctx)})
+		if err != nil {
+			return nil, err
+		}
+		items := make([]domain.CollectionItem, 0, len(rows))
+		for _, row := range rows {
+			items = append(items, MapCollectionItem(row))
+		}
+		return items, nil
+	})
+}
+
+// UpdateCollectionItemResult records the bank outcome of a submitted item
+func (r *PostgresCollectionRepo) UpdateCollectionItemResult(ctx context.Context, arg domain.UpdateCollectionItemResultCommand) (int64, error) {
+	return runWithTimeout(ctx, "UpdateCollectionItemResult", 1, func(ctx context.Context) (int64, error) {
+		params := MapUpdateCollectionItemResultCommand(&arg)
+		params.TenantID = domain.TenantFromContext(ctx)
+		id, err := r.queries.UpdateCollectionItemResult(ctx, *params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, domain.ErrCollectionItemNotFound
+			}
+			return 0, err
+		}
+		return id, nil
+	})
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresHealthRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresHealthRepo(pool *pgxpool.Pool) *PostgresHealthRepo {
+	return &PostgresHealthRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+// Ping acquires a connection and runs an empty statement on it
+func (r *PostgresHealthRepo) Ping(ctx context.Context) error {
+	_, err := runWithTimeout(ctx, "Ping", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.pool.Ping(ctx)
+	})
+	return err
+}
+
+func (r *PostgresHealthRepo) GetSchemaVersion(ctx context.Context) (int32, error) {
+	return runWithTimeout(ctx, "GetSchemaVersion", 1, func(ctx context.Context) (int32, error) {
+		return r.queries.GetSchemaVersion(ctx)
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresIdempotencyRepo scopes the keys to the tenant of the context, two tenants may use the same key
+type PostgresIdempotencyRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresIdempotencyRepo(pool *pgxpool.Pool) *PostgresIdempotencyRepo {
+	return &PostgresIdempotencyRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+// AcquireIdempotencyKey claims a key, or returns the record currently holding it
+func (r *PostgresIdempotencyRepo) AcquireIdempotencyKey(ctx context.Context, arg domain.AcquireIdempotencyKeyCommand) (*domain.IdempotencyRecord, bool, error) {
+	type result struct {
+		record   *domain.IdempotencyRecord
+		acquired bool
+	}
+	res, err := runWithTimeout(ctx, "AcquireIdempotencyKey", 2, func(ctx context.Context) (result, error) {
+		k, err := r.queries.AcquireIdempotencyKey(ctx, sqlc.AcquireIdempotencyKeyParams{
+			TenantID:    domain.TenantFromContext(ctx),
+			Key:         arg.Key,
+			RequestHash: arg.RequestHash,
+			Now:         pgtype.Timestamp{Time: arg.Now, Valid: true},
+			ExpiresAt:   pgtype.Timestamp{Time: arg.ExpiresAt, Valid: true},
+			StaleBefore: pgtype.Timestamp{Time: arg.StaleBefore, Valid: true},
+		})
+		if err == nil {
+			return result{record: MapIdempotencyRecord(k), acquired: true}, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return result{}, err
+		}
+
+		// the key is held by another request, still running or completed
+		k, err = r.queries.GetIdempotencyKey(ctx, sqlc.GetIdempotencyKeyParams{TenantID: domain.TenantFromContext(ctx), Key: arg.Key})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// released in the meantime, the client can simply retry
+				return result{}, domain.ErrIdempotencyKeyInFlight
+			}
+			return result{}, err
+		}
+		return result{record: MapIdempotencyRecord(k)}, nil
+	})
+	return res.record, res.acquired, err
+}
+
+// CompleteIdempotencyKey stores the response to replay for the key
+func (r *PostgresIdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, arg domain.CompleteIdempotencyKeyCommand) error {
+	_, err := runWithTimeout(ctx, "CompleteIdempotencyKey", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.CompleteIdempotencyKey(ctx, sqlc.CompleteIdempotencyKeyParams{
+			TenantID:            domain.TenantFromContext(ctx),
+			Key:                 arg.Key,
+			ResponseStatus:      int32(arg.ResponseStatus),
+			ResponseContentType: arg.ResponseContentType,
+			ResponseBody:        arg.ResponseBody,
+		})
+	})
+	return err
+}
+
+// ReleaseIdempotencyKey frees an in-progress key so the request can be retried
+func (r *PostgresIdempotencyRepo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := runWithTimeout(ctx, "ReleaseIdempotencyKey", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.ReleaseIdempotencyKey(ctx, sqlc.ReleaseIdempotencyKeyParams{TenantID: domain.TenantFromContext(ctx), Key: key})
+	})
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys purges the keys past their TTL, of every tenant
+func (r *PostgresIdempotencyRepo) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	return runWithTimeout(ctx, "DeleteExpiredIdempotencyKeys", 5, func(ctx context.Context) (int64, error) {
+		return r.queries.DeleteExpiredIdempotencyKeys(ctx, pgtype.Timestamp{Time: now, Valid: true})
+	})
+}
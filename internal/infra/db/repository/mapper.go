@@ -3,6 +3,7 @@ package repository
 import (
 	"billing-api/internal/domain"
 	"billing-api/internal/infra/db/sqlc"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -14,10 +15,18 @@ func MapLoan(l sqlc.Loan) *domain.Loan {
 		TotalPayableAmount:  l.TotalPayableAmount,
 		WeeklyPaymentAmount: l.WeeklyPaymentAmount,
 		TotalWeeks:          int(l.TotalWeeks),
+		BorrowerID:          optionalText(l.BorrowerID),
 		CreatedAt:           l.CreatedAt.Time,
 	}
 }
 
+func optionalText(t pgtype.Text) *string {
+	if !t.Valid {
+		return nil
+	}
+	return &t.String
+}
+
 func MapCreateLoanCommand(clc *domain.CreateLoanCommand) *sqlc.InsertLoanParams {
 	return &sqlc.InsertLoanParams{
 		PrincipalAmount:     clc.PrincipalAmount,
@@ -29,9 +38,17 @@ func MapCreateLoanCommand(clc *domain.CreateLoanCommand) *sqlc.InsertLoanParams
 			Time:  clc.StartDate,
 			Valid: true,
 		},
+		BorrowerID: textParam(clc.BorrowerID),
 	}
 }
 
+func textParam(s *string) pgtype.Text {
+	if s == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *s, Valid: true}
+}
+
 func MapPayment(p sqlc.Payment) *domain.Payment {
 	return &domain.Payment{
 		ID:         p.ID,
@@ -51,6 +68,16 @@ func MapListPaymentsByLoanIDRow(p sqlc.ListPaymentsByLoanIDRow) domain.Payment {
 	}
 }
 
+func MapListPaymentsByLoanIDInPeriodRow(p sqlc.ListPaymentsByLoanIDInPeriodRow) domain.Payment {
+	return domain.Payment{
+		ID:         p.ID,
+		LoanID:     p.LoanID,
+		WeekNumber: int(p.WeekNumber),
+		Amount:     p.Amount,
+		PaidAt:     p.PaidAt.Time,
+	}
+}
+
 func MapCreatePaymentComand(cpc *domain.CreatePaymentComand) *sqlc.InsertPaymentParams {
 	return &sqlc.InsertPaymentParams{
 		LoanID:         cpc.LoanID,
@@ -75,3 +102,339 @@ func MapSchedule(s sqlc.Schedule) domain.LoanSchedule {
 		Status:     s.Status,
 	}
 }
+
+// MapUpcomingSchedule derives the status from the amount paid as of the statement date
+func MapUpcomingSchedule(s sqlc.ListUpcomingSchedulesRow) domain.LoanSchedule {
+	status := "PENDING"
+	if s.PaidAmount > 0 {
+		status = "PARTIAL"
+	}
+	return domain.LoanSchedule{
+		ID:         s.ID,
+		LoanID:     s.LoanID,
+		Sequence:   int(s.Sequence),
+		DueDate:    s.DueDate.Time,
+		Amount:     s.Amount,
+		PaidAmount: s.PaidAmount,
+		Status:     status,
+	}
+}
+
+func MapMandate(m sqlc.Mandate) *domain.Mandate {
+	mandate := &domain.Mandate{
+		ID:            m.ID,
+		LoanID:        m.LoanID,
+		AccountHolder: m.AccountHolder,
+		BankCode:      m.BankCode,
+		AccountNumber: m.AccountNumber,
+		Reference:     m.Reference,
+		Status:        m.Status,
+		CreatedAt:     m.CreatedAt.Time,
+	}
+	if m.RevokedAt.Valid {
+		revokedAt := m.RevokedAt.Time
+		mandate.RevokedAt = &revokedAt
+	}
+	return mandate
+}
+
+func MapCreateMandateCommand(cmc *domain.CreateMandateCommand) *sqlc.InsertMandateParams {
+	return &sqlc.InsertMandateParams{
+		LoanID:        cmc.LoanID,
+		AccountHolder: cmc.AccountHolder,
+		BankCode:      cmc.BankCode,
+		AccountNumber: cmc.AccountNumber,
+		Reference:     cmc.Reference,
+	}
+}
+
+func MapCollectionCandidate(c sqlc.ListCollectionCandidatesRow) domain.CollectionCandidate {
+	return domain.CollectionCandidate{
+		LoanID:           c.LoanID,
+		MandateID:        c.MandateID,
+		ScheduleSequence: int(c.Sequence),
+		DueDate:          c.DueDate.Time,
+		Amount:           c.Amount,
+		PreviousAttempts: int(c.PreviousAttempts),
+	}
+}
+
+func MapCollectionBatch(b sqlc.CollectionBatch) *domain.CollectionBatch {
+	return &domain.CollectionBatch{
+		ID:             b.ID,
+		CollectionDate: b.CollectionDate.Time,
+		Status:         b.Status,
+		ItemCount:      int(b.ItemCount),
+		TotalAmount:    b.TotalAmount,
+		CreatedAt:      b.CreatedAt.Time,
+	}
+}
+
+func MapCollectionItem(i sqlc.ListCollectionItemsByBatchIDRow) domain.CollectionItem {
+	item := domain.CollectionItem{
+		ID:               i.ID,
+		BatchID:          i.BatchID,
+		LoanID:           i.LoanID,
+		MandateID:        i.MandateID,
+		ScheduleSequence: int(i.ScheduleSequence),
+		Amount:           i.Amount,
+		Attempt:          int(i.Attempt),
+		Status:           i.Status,
+		FailureCode:      i.FailureCode.String,
+		AccountHolder:    i.AccountHolder,
+		BankCode:         i.BankCode,
+		AccountNumber:    i.AccountNumber,
+		MandateReference: i.MandateReference,
+	}
+	if i.NextAttemptOn.Valid {
+		nextAttemptOn := i.NextAttemptOn.Time
+		item.NextAttemptOn = &nextAttemptOn
+	}
+	if i.PaymentID.Valid {
+		paymentID := i.PaymentID.Int64
+		item.PaymentID = &paymentID
+	}
+	return item
+}
+
+func MapUpdateCollectionItemResultCommand(cmd *domain.UpdateCollectionItemResultCommand) *sqlc.UpdateCollectionItemResultParams {
+	params := &sqlc.UpdateCollectionItemResultParams{
+		ID:      cmd.ItemID,
+		BatchID: cmd.BatchID,
+		Status:  cmd.Status,
+		FailureCode: pgtype.Text{
+			String: cmd.FailureCode,
+			Valid:  cmd.FailureCode != "",
+		},
+	}
+	if cmd.NextAttemptOn != nil {
+		params.NextAttemptOn = pgtype.Date{Time: *cmd.NextAttemptOn, Valid: true}
+	}
+	if cmd.PaymentID != nil {
+		params.PaymentID = pgtype.Int8{Int64: *cmd.PaymentID, Valid: true}
+	}
+	return params
+}
+
+func MapOutboxEvent(e sqlc.OutboxEvent) domain.OutboxEvent {
+	return domain.OutboxEvent{
+		ID:            e.ID,
+		TenantID:      e.TenantID,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		EventType:     e.EventType,
+		Payload:       e.Payload,
+		Attempts:      int(e.Attempts),
+		CreatedAt:     e.CreatedAt.Time,
+	}
+}
+
+func MapDelinquentLoan(d sqlc.ListNewlyDelinquentLoansRow) domain.DelinquentLoan {
+	return domain.DelinquentLoan{
+		LoanID:       d.ID,
+		LastPaidWeek: int(d.LastPaidWeek),
+		ExpectedWeek: int(d.ExpectedWeek),
+	}
+}
+
+func MapWebhookSubscription(s sqlc.WebhookSubscription) *domain.WebhookSubscription {
+	return &domain.WebhookSubscription{
+		ID:         s.ID,
+		URL:        s.Url,
+		EventTypes: s.EventTypes,
+		Secret:     s.Secret,
+		Status:     s.Status,
+		CreatedAt:  s.CreatedAt.Time,
+	}
+}
+
+func MapWebhookDelivery(d sqlc.WebhookDelivery) domain.WebhookDelivery {
+	delivery := domain.WebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       int(d.Attempts),
+		LastError:      d.LastError.String,
+		NextAttemptAt:  d.NextAttemptAt.Time,
+		CreatedAt:      d.CreatedAt.Time,
+	}
+	if d.LastStatusCode.Valid {
+		statusCode := int(d.LastStatusCode.Int32)
+		delivery.LastStatusCode = &statusCode
+	}
+	if d.DeliveredAt.Valid {
+		deliveredAt := d.DeliveredAt.Time
+		delivery.DeliveredAt = &deliveredAt
+	}
+	return delivery
+}
+
+func MapWebhookDeliveries(rows []sqlc.WebhookDelivery) []domain.WebhookDelivery {
+	deliveries := make([]domain.WebhookDelivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, MapWebhookDelivery(row))
+	}
+	return deliveries
+}
+
+func MapDueWebhookDelivery(d sqlc.ClaimWebhookDeliveriesRow) domain.DueWebhookDelivery {
+	return domain.DueWebhookDelivery{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		Attempts:       int(d.Attempts),
+		URL:            d.Url,
+		Secret:         d.Secret,
+		Event: domain.OutboxEvent{
+			ID:            d.EventID,
+			TenantID:      d.TenantID,
+			AggregateType: d.AggregateType,
+			AggregateID:   d.AggregateID,
+			EventType:     d.EventType,
+			Payload:       d.Payload,
+			CreatedAt:     d.OccurredAt.Time,
+		},
+	}
+}
+
+func MapWebhookDeliveryAttempt(a sqlc.WebhookDeliveryAttempt) domain.WebhookDeliveryAttempt {
+	attempt := domain.WebhookDeliveryAttempt{
+		ID:          a.ID,
+		DeliveryID:  a.DeliveryID,
+		Attempt:     int(a.Attempt),
+		Error:       a.Error.String,
+		Duration:    time.Duration(a.DurationMs) * time.Millisecond,
+		AttemptedAt: a.AttemptedAt.Time,
+	}
+	if a.StatusCode.Valid {
+		statusCode := int(a.StatusCode.Int32)
+		attempt.StatusCode = &statusCode
+	}
+	return attempt
+}
+
+func MapCreateWebhookDeliveryAttemptCommand(cmd *domain.CreateWebhookDeliveryAttemptCommand) *sqlc.InsertWebhookDeliveryAttemptParams {
+	params := &sqlc.InsertWebhookDeliveryAttemptParams{
+		DeliveryID: cmd.DeliveryID,
+		Attempt:    int32(cmd.Attempt),
+		Error:      pgtype.Text{String: cmd.Error, Valid: cmd.Error != ""},
+		DurationMs: int32(cmd.Duration.Milliseconds()),
+	}
+	if cmd.StatusCode != nil {
+		params.StatusCode = pgtype.Int4{Int32: int32(*cmd.StatusCode), Valid: true}
+	}
+	return params
+}
+
+func MapMarkWebhookDeliveryFailedCommand(cmd *domain.MarkWebhookDeliveryFailedCommand) *sqlc.MarkWebhookDeliveryFailedParams {
+	params := &sqlc.MarkWebhookDeliveryFailedParams{
+		ID:            cmd.ID,
+		Status:        cmd.Status,
+		LastError:     cmd.LastError,
+		NextAttemptAt: pgtype.Timestamp{Time: cmd.NextAttemptAt, Valid: true},
+	}
+	if cmd.StatusCode != nil {
+		params.StatusCode = pgtype.Int4{Int32: int32(*cmd.StatusCode), Valid: true}
+	}
+	return params
+}
+
+func MapIdempotencyRecord(k sqlc.IdempotencyKey) *domain.IdempotencyRecord {
+	return &domain.IdempotencyRecord{
+		Key:                 k.Key,
+		RequestHash:         k.RequestHash,
+		Status:              k.Status,
+		ResponseStatus:      int(k.ResponseStatus.Int32),
+		ResponseContentType: k.ResponseContentType.String,
+		ResponseBody:        k.ResponseBody,
+		CreatedAt:           k.CreatedAt.Time,
+		ExpiresAt:           k.ExpiresAt.Time,
+	}
+}
+
+func MapAPIKey(k sqlc.ApiKey) *domain.APIKey {
+	key := &domain.APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Hash:      k.KeyHash,
+		TenantID:  k.TenantID,
+		Scopes:    k.Scopes,
+		Roles:     k.Roles,
+		CreatedAt: k.CreatedAt.Time,
+	}
+	if k.ExpiresAt.Valid {
+		expiresAt := k.ExpiresAt.Time
+		key.ExpiresAt = &expiresAt
+	}
+	if k.LastUsedAt.Valid {
+		lastUsedAt := k.LastUsedAt.Time
+		key.LastUsedAt = &lastUsedAt
+	}
+	if k.RevokedAt.Valid {
+		revokedAt := k.RevokedAt.Time
+		key.RevokedAt = &revokedAt
+	}
+	if k.RotatedFrom.Valid {
+		rotatedFrom := k.RotatedFrom.Int64
+		key.RotatedFrom = &rotatedFrom
+	}
+	return key
+}
+
+func MapCreateAPIKeyCommand(cmd *domain.CreateAPIKeyCommand) *sqlc.InsertAPIKeyParams {
+	params := &sqlc.InsertAPIKeyParams{
+		Name:      cmd.Name,
+		Prefix:    cmd.Prefix,
+		KeyHash:   cmd.Hash,
+		TenantID:  cmd.TenantID,
+		Scopes:    cmd.Scopes,
+		Roles:     cmd.Roles,
+		CreatedAt: pgtype.Timestamp{Time: cmd.CreatedAt, Valid: true},
+	}
+	if cmd.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamp{Time: *cmd.ExpiresAt, Valid: true}
+	}
+	if cmd.RotatedFrom != nil {
+		params.RotatedFrom = pgtype.Int8{Int64: *cmd.RotatedFrom, Valid: true}
+	}
+	return params
+}
+
+func MapAuditEntry(a sqlc.AuditLog) domain.AuditEntry {
+	return domain.AuditEntry{
+		ID:             a.ID,
+		TenantID:       a.TenantID,
+		OccurredAt:     a.OccurredAt.Time,
+		Actor:          a.Actor,
+		Action:         a.Action,
+		EntityType:     a.EntityType,
+		EntityID:       a.EntityID,
+		Before:         a.BeforeState,
+		After:          a.AfterState,
+		RequestID:      a.RequestID,
+		IdempotencyKey: a.IdempotencyKey,
+		SourceIP:       a.SourceIp,
+		PrevHash:       a.PrevHash,
+		Hash:           a.Hash,
+	}
+}
+
+func MapAuditEntryParams(a *domain.AuditEntry) *sqlc.InsertAuditEntryParams {
+	return &sqlc.InsertAuditEntryParams{
+		TenantID:       a.TenantID,
+		OccurredAt:     pgtype.Timestamp{Time: a.OccurredAt, Valid: true},
+		Actor:          a.Actor,
+		Action:         a.Action,
+		EntityType:     a.EntityType,
+		EntityID:       a.EntityID,
+		BeforeState:    a.Before,
+		AfterState:     a.After,
+		RequestID:      a.RequestID,
+		IdempotencyKey: a.IdempotencyKey,
+		SourceIp:       a.SourceIP,
+		PrevHash:       a.PrevHash,
+		Hash:           a.Hash,
+	}
+}
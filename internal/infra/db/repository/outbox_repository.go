@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/sqlc"
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresOutboxRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresOutboxRepo(pool *pgxpool.Pool) *PostgresOutboxRepo {
+	return &PostgresOutboxRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+func (r *PostgresOutboxRepo) WithTx(ctx context.Context, fn func(repo domain.OutboxRepository) error) error {
+	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+		txRepo := &PostgresOutboxRepo{
+			queries: r.queries.WithTx(tx),
+			pool:    r.pool,
+		}
+		return fn(txRepo)
+	})
+}
+
+// TryLockOutboxDispatch returns false when another instance is already dispatching
+func (r *PostgresOutboxRepo) TryLockOutboxDispatch(ctx context.Context) (bool, error) {
+	return runWithTimeout(ctx, "TryLockOutboxDispatch", 1, func(ctx context.Context) (bool, error) {
+		return r.queries.TryLockOutboxDispatch(ctx)
+	})
+}
+
+// ClaimOutboxEvents leases the due pending events in id order, skipping aggregates blocked by an earlier retry
+func (r *PostgresOutboxRepo) ClaimOutboxEvents(ctx context.Context, arg domain.ClaimOutboxEventsCommand) ([]domain.OutboxEvent, error) {
+	return runWithTimeout(ctx, "ClaimOutboxEvents", int(arg.Limit), func(ctx context.Context) ([]domain.OutboxEvent, error) {
+		rows, err := r.queries.ClaimOutboxEvents(ctx, sqlc.ClaimOutboxEventsParams{
+			LeaseUntil: pgtype.Timestamp{Time: arg.LeaseUntil, Valid: true},
+			Now:        pgtype.Timestamp{Time: arg.Now, Valid: true},
+			LimitVal:   arg.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events := make([]domain.OutboxEvent, 0, len(rows))
+		for _, row := range rows {
+			events = append(events, MapOutboxEvent(row))
+		}
+		// UPDATE ... RETURNING does not keep the order of the subquery
+		slices.SortFunc(events, func(a, b domain.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+		return events, nil
+	})
+}
+
+// MarkOutboxEventDispatched records a successful delivery
+func (r *PostgresOutboxRepo) MarkOutboxEventDispatched(ctx context.Context, id int64) error {
+	_, err := runWithTimeout(ctx, "MarkOutboxEventDispatched", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.MarkOutboxEventDispatched(ctx, id)
+	})
+	return err
+}
+
+// MarkOutboxEventFailed records a failed delivery and when it should be attempted again
+func (r *PostgresOutboxRepo) MarkOutboxEventFailed(ctx context.Context, arg domain.MarkOutboxEventFailedCommand) error {
+	_, err := runWithTimeout(ctx, "MarkOutboxEventFailed", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.MarkOutboxEventFailed(ctx, sqlc.MarkOutboxEventFailedParams{
+			ID:            arg.ID,
+			Status:        arg.Status,
+			LastError:     arg.LastError,
+			NextAttemptAt: pgtype.Timestamp{Time: arg.NextAttemptAt, Valid: true},
+		})
+	})
+	return err
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+PostgresRateLimitRepo shares the token buckets between the instances, each request is a single upsert.
+A token taken on every request must stay cheap, it gives up after takeTimeout instead of holding the request.
+*/
+type PostgresRateLimitRepo struct {
+	pool        *pgxpool.Pool
+	queries     *sqlc.Queries
+	takeTimeout time.Duration
+}
+
+func NewPostgresRateLimitRepo(pool *pgxpool.Pool, takeTimeout time.Duration) *PostgresRateLimitRepo {
+	return &PostgresRateLimitRepo{
+		pool:        pool,
+		queries:     sqlc.New(pool),
+		takeTimeout: takeTimeout,
+	}
+}
+
+// TakeRateLimitToken refills the bucket and takes a token in the same statement, concurrent requests queue on the row
+func (r *PostgresRateLimitRepo) TakeRateLimitToken(ctx context.Context, arg domain.TakeRateLimitTokenCommand) (*domain.RateLimitBucket, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, r.takeTimeout, fmt.Errorf("repo-timeout : TakeRateLimitToken limit was (%v)", r.takeTimeout))
+	defer cancel()
+	return runWithTimeout(ctx, "TakeRateLimitToken", 1, func(ctx context.Context) (*domain.RateLimitBucket, error) {
+		row, err := r.queries.TakeRateLimitToken(ctx, sqlc.TakeRateLimitTokenParams{
+			BucketKey: arg.Key,
+			Burst:     float64(arg.Limit.Burst),
+			Now:       pgtype.Timestamp{Time: arg.Now, Valid: true},
+			Rate:      arg.Limit.Rate,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &domain.RateLimitBucket{Allowed: row.Allowed, Tokens: row.Tokens}, nil
+	})
+}
+
+// DeleteIdleRateLimitBuckets purges the buckets without a request since idleBefore
+func (r *PostgresRateLimitRepo) DeleteIdleRateLimitBuckets(ctx context.Context, idleBefore time.Time) (int64, error) {
+	return runWithTimeout(ctx, "DeleteIdleRateLimitBuckets", 5, func(ctx context.Context) (int64, error) {
+		return r.queries.DeleteIdleRateLimitBuckets(ctx, pgtype.Timestamp{Time: idleBefore, Valid: true})
+	})
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/sqlc"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresWebhookRepo struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func NewPostgresWebhookRepo(pool *pgxpool.Pool) *PostgresWebhookRepo {
+	return &PostgresWebhookRepo{
+		pool:    pool,
+		queries: sqlc.New(pool),
+	}
+}
+
+func (r *PostgresWebhookRepo) WithTx(ctx context.Context, fn func(repo domain.WebhookRepository) error) error {
+	return db.WithTx(ctx, r.pool, func(tx pgx.Tx) error {
+		txRepo := &PostgresWebhookRepo{
+			queries: r.queries.WithTx(tx),
+			pool:    r.pool,
+		}
+		return fn(txRepo)
+	})
+}
+
+// SUBSCRIPTION RELATED
+// InsertWebhookSubscription registers a new active subscription
+func (r *PostgresWebhookRepo) InsertWebhookSubscription(ctx context.Context, arg domain.CreateWebhookSubscriptionCommand) (*domain.WebhookSubscription, error) {
+	return runWithTimeout(ctx, "InsertWebhookSubscription", 1, func(ctx context.Context) (*domain.WebhookSubscription, error) {
+		s, err := r.queries.InsertWebhookSubscription(ctx, sqlc.InsertWebhookSubscriptionParams{
+			Url:        arg.URL,
+			EventTypes: arg.EventTypes,
+			Secret:     arg.Secret,
+			TenantID:   domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return MapWebhookSubscription(s), nil
+	})
+}
+
+// GetWebhookSubscriptionByID retrieves an active subscription
+func (r *PostgresWebhookRepo) GetWebhookSubscriptionByID(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	return runWithTimeout(ctx, "GetWebhookSubscriptionByID", 1, func(ctx context.Context) (*domain.WebhookSubscription, error) {
+		s, err := r.queries.GetWebhookSubscriptionByID(ctx, sqlc.GetWebhookSubscriptionByIDParams{
+			ID:       id,
+			TenantID: domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrWebhookNotFound
+			}
+			return nil, err
+		}
+		return MapWebhookSubscription(s), nil
+	})
+}
+
+// ListWebhookSubscriptions retrieves the active subscriptions of the tenant
+func (r *PostgresWebhookRepo) ListWebhookSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return runWithTimeout(ctx, "ListWebhookSubscriptions", 1, func(ctx context.Context) ([]domain.WebhookSubscription, error) {
+		rows, err := r.queries.ListWebhookSubscriptions(ctx, domain.TenantFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		subscriptions := make([]domain.WebhookSubscription, 0, len(rows))
+		for _, row := range rows {
+			subscriptions = append(subscriptions, *MapWebhookSubscription(row))
+		}
+		return subscriptions, nil
+	})
+}
+
+// DeleteWebhookSubscription soft deletes a subscription, its delivery log is kept
+func (r *PostgresWebhookRepo) DeleteWebhookSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	return runWithTimeout(ctx, "DeleteWebhookSubscription", 1, func(ctx context.Context) (*domain.WebhookSubscription, error) {
+		s, err := r.queries.DeleteWebhookSubscription(ctx, sqlc.DeleteWebhookSubscriptionParams{
+			ID:       id,
+			TenantID: domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrWebhookNotFound
+			}
+			return nil, err
+		}
+		return MapWebhookSubscription(s), nil
+	})
+}
+
+// DELIVERY RELATED
+// EnqueueWebhookDeliveries creates a delivery per matching subscription of the tenant, events already fanned out are skipped
+func (r *PostgresWebhookRepo) EnqueueWebhookDeliveries(ctx context.Context, eventID int64, eventType string) (int64, error) {
+	return runWithTimeout(ctx, "EnqueueWebhookDeliveries", 1, func(ctx context.Context) (int64, error) {
+		return r.queries.EnqueueWebhookDeliveries(ctx, sqlc.EnqueueWebhookDeliveriesParams{
+			EventID:   eventID,
+			EventType: eventType,
+			TenantID:  domain.TenantFromContext(ctx),
+		})
+	})
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries whose next attempt is due
+func (r *PostgresWebhookRepo) ClaimWebhookDeliveries(ctx context.Context, arg domain.ClaimWebhookDeliveriesCommand) ([]domain.DueWebhookDelivery, error) {
+	return runWithTimeout(ctx, "ClaimWebhookDeliveries", int(arg.Limit), func(ctx context.Context) ([]domain.DueWebhookDelivery, error) {
+		rows, err := r.queries.ClaimWebhookDeliveries(ctx, sqlc.ClaimWebhookDeliveriesParams{
+			LeaseUntil: pgtype.Timestamp{Time: arg.LeaseUntil, Valid: true},
+			Now:        pgtype.Timestamp{Time: arg.Now, Valid: true},
+			LimitVal:   arg.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		deliveries := make([]domain.DueWebhookDelivery, 0, len(rows))
+		for _, row := range rows {
+			deliveries = append(deliveries, MapDueWebhookDelivery(row))
+		}
+		return deliveries, nil
+	})
+}
+
+// InsertWebhookDeliveryAttempt appends an entry to the delivery log
+func (r *PostgresWebhookRepo) InsertWebhookDeliveryAttempt(ctx context.Context, arg domain.CreateWebhookDeliveryAttemptCommand) error {
+	_, err := runWithTimeout(ctx, "InsertWebhookDeliveryAttempt", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.InsertWebhookDeliveryAttempt(ctx, *MapCreateWebhookDeliveryAttemptCommand(&arg))
+	})
+	return err
+}
+
+// MarkWebhookDeliverySucceeded records a delivery acknowledged by the partner
+func (r *PostgresWebhookRepo) MarkWebhookDeliverySucceeded(ctx context.Context, id int64, statusCode int) error {
+	_, err := runWithTimeout(ctx, "MarkWebhookDeliverySucceeded", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.MarkWebhookDeliverySucceeded(ctx, sqlc.MarkWebhookDeliverySucceededParams{
+			ID:         id,
+			StatusCode: int32(statusCode),
+		})
+	})
+	return err
+}
+
+// MarkWebhookDeliveryFailed records a failed attempt and when it should be attempted again
+func (r *PostgresWebhookRepo) MarkWebhookDeliveryFailed(ctx context.Context, arg domain.MarkWebhookDeliveryFailedCommand) error {
+	_, err := runWithTimeout(ctx, "MarkWebhookDeliveryFailed", 1, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, r.queries.MarkWebhookDeliveryFailed(ctx, *MapMarkWebhookDeliveryFailedCommand(&arg))
+	})
+	return err
+}
+
+// GetWebhookDeliveryByID retrieves a single delivery
+func (r *PostgresWebhookRepo) GetWebhookDeliveryByID(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	return runWithTimeout(ctx, "GetWebhookDeliveryByID", 1, func(ctx context.Context) (*domain.WebhookDelivery, error) {
+		d, err := r.queries.GetWebhookDeliveryByID(ctx, sqlc.GetWebhookDeliveryByIDParams{
+			ID:       id,
+			TenantID: domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrWebhookDeliveryNotFound
+			}
+			return nil, err
+		}
+		delivery := MapWebhookDelivery(d)
+		return &delivery, nil
+	})
+}
+
+// ListWebhookDeliveries retrieves the deliveries of a subscription, newest first
+func (r *PostgresWebhookRepo) ListWebhookDeliveries(ctx context.Context, arg domain.ListWebhookDeliveriesQuery) ([]domain.WebhookDelivery, error) {
+	return runWithTimeout(ctx, "List webhook deliveries based on subscriptionID", int(arg.LimitVal), func(ctx context.Context) ([]domain.WebhookDelivery, error) {
+		params := sqlc.ListWebhookDeliveriesBySubscriptionIDParams{
+			SubscriptionID: arg.SubscriptionID,
+			TenantID:       domain.TenantFromContext(ctx),
+			Status:         pgtype.Text{String: arg.Status, Valid: arg.Status != ""},
+			LimitVal:       arg.LimitVal,
+		}
+		if arg.CursorID != nil {
+			params.CursorID = pgtype.Int8{Int64: *arg.CursorID, Valid: true}
+		}
+		rows, err := r.queries.ListWebhookDeliveriesBySubscriptionID(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return MapWebhookDeliveries(rows), nil
+	})
+}
+
+// ListDeadWebhookDeliveries retrieves the dead-letter list, newest first
+func (r *PostgresWebhookRepo) ListDeadWebhookDeliveries(ctx context.Context, cursorID *int64, limit int32) ([]domain.WebhookDelivery, error) {
+	return runWithTimeout(ctx, "List dead webhook deliveries", int(limit), func(ctx context.Context) ([]domain.WebhookDelivery, error) {
+		params := sqlc.ListDeadWebhookDeliveriesParams{TenantID: domain.TenantFromContext(ctx), LimitVal: limit}
+		if cursorID != nil {
+			params.CursorID = pgtype.Int8{Int64: *cursorID, Valid: true}
+		}
+		rows, err := r.queries.ListDeadWebhookDeliveries(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return MapWebhookDeliveries(rows), nil
+	})
+}
+
+// ListWebhookDeliveryAttempts retrieves the delivery log of a delivery in attempt order
+func (r *PostgresWebhookRepo) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID int64) ([]domain.WebhookDeliveryAttempt, error) {
+	return runWithTimeout(ctx, "ListWebhookDeliveryAttempts", 1, func(ctx context.Context) ([]domain.WebhookDeliveryAttempt, error) {
+		rows, err := r.queries.ListWebhookDeliveryAttempts(ctx, deliveryID)
+		if err != nil {
+			return nil, err
+		}
+		attempts := make([]domain.WebhookDeliveryAttempt, 0, len(rows))
+		for _, row := range rows {
+			attempts = append(attempts, MapWebhookDeliveryAttempt(row))
+		}
+		return attempts, nil
+	})
+}
+
+// RedeliverWebhookDelivery moves a dead delivery back to pending
+func (r *PostgresWebhookRepo) RedeliverWebhookDelivery(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	return runWithTimeout(ctx, "RedeliverWebhookDelivery", 1, func(ctx context.Context) (*domain.WebhookDelivery, error) {
+		d, err := r.queries.RedeliverWebhookDelivery(ctx, sqlc.RedeliverWebhookDeliveryParams{
+			ID:       id,
+			TenantID: domain.TenantFromContext(ctx),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, domain.ErrWebhookDeliveryNotDead
+			}
+			return nil, err
+		}
+		delivery := MapWebhookDelivery(d)
+		return &delivery, nil
+	})
+}
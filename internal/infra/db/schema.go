@@ -0,0 +1,22 @@
+package db
+
+import "billing-api/db/migrations"
+
+// Migrations are the files of db/migrations embedded in the binary, in version order
+var Migrations = mustLoadMigrations()
+
+func mustLoadMigrations() []Migration {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		panic(err)
+	}
+	if len(loaded) == 0 {
+		panic("db/migrations has no migration")
+	}
+	return loaded
+}
+
+// SchemaVersion is the latest migration of db/migrations, an instance is not ready until the database has it applied
+func SchemaVersion() int32 {
+	return Migrations[len(Migrations)-1].Version
+}
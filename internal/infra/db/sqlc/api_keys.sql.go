@@ -0,0 +1,253 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_keys.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const expireAPIKey = `-- name: ExpireAPIKey :exec
+UPDATE api_keys
+SET expires_at = LEAST(
+    COALESCE(expires_at, $1::timestamp),
+    $1::timestamp
+  )
+WHERE id = $2::bigint
+  AND tenant_id = $3::text
+`
+
+type ExpireAPIKeyParams struct {
+	ExpiresAt pgtype.Timestamp
+	ID        int64
+	TenantID  string
+}
+
+// shortens the life of a rotated key, a key already expiring sooner keeps its expiry
+func (q *Queries) ExpireAPIKey(ctx context.Context, arg ExpireAPIKeyParams) error {
+	_, err := q.db.Exec(ctx, expireAPIKey, arg.ExpiresAt, arg.ID, arg.TenantID)
+	return err
+}
+
+const getAPIKeyByID = `-- name: GetAPIKeyByID :one
+SELECT id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_at, roles, tenant_id
+FROM api_keys
+WHERE id = $1
+  AND tenant_id = $2
+`
+
+type GetAPIKeyByIDParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) GetAPIKeyByID(ctx context.Context, arg GetAPIKeyByIDParams) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, getAPIKeyByID, arg.ID, arg.TenantID)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.RotatedFrom,
+		&i.CreatedAt,
+		&i.Roles,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const getAPIKeyByPrefix = `-- name: GetAPIKeyByPrefix :one
+SELECT id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_at, roles, tenant_id
+FROM api_keys
+WHERE prefix = $1
+`
+
+// looked up before the caller and its tenant are known
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, getAPIKeyByPrefix, prefix)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.RotatedFrom,
+		&i.CreatedAt,
+		&i.Roles,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const insertAPIKey = `-- name: InsertAPIKey :one
+INSERT INTO api_keys (
+    name,
+    prefix,
+    key_hash,
+    scopes,
+    roles,
+    tenant_id,
+    expires_at,
+    rotated_from,
+    created_at
+  )
+VALUES (
+    $1::text,
+    $2::text,
+    $3::text,
+    $4::text [],
+    $5::text [],
+    $6::text,
+    $7::timestamp,
+    $8::bigint,
+    $9::timestamp
+  )
+RETURNING id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_at, roles, tenant_id
+`
+
+type InsertAPIKeyParams struct {
+	Name        string
+	Prefix      string
+	KeyHash     string
+	Scopes      []string
+	Roles       []string
+	TenantID    string
+	ExpiresAt   pgtype.Timestamp
+	RotatedFrom pgtype.Int8
+	CreatedAt   pgtype.Timestamp
+}
+
+func (q *Queries) InsertAPIKey(ctx context.Context, arg InsertAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, insertAPIKey,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.Scopes,
+		arg.Roles,
+		arg.TenantID,
+		arg.ExpiresAt,
+		arg.RotatedFrom,
+		arg.CreatedAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.RotatedFrom,
+		&i.CreatedAt,
+		&i.Roles,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_at, roles, tenant_id
+FROM api_keys
+WHERE tenant_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListAPIKeys(ctx context.Context, tenantID string) ([]ApiKey, error) {
+	rows, err := q.db.Query(ctx, listAPIKeys, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.RotatedFrom,
+			&i.CreatedAt,
+			&i.Roles,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = $1::timestamp
+WHERE id = $2::bigint
+  AND tenant_id = $3::text
+  AND revoked_at IS NULL
+RETURNING id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_at, roles, tenant_id
+`
+
+type RevokeAPIKeyParams struct {
+	RevokedAt pgtype.Timestamp
+	ID        int64
+	TenantID  string
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRow(ctx, revokeAPIKey, arg.RevokedAt, arg.ID, arg.TenantID)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.RotatedFrom,
+		&i.CreatedAt,
+		&i.Roles,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $1::timestamp
+WHERE id = $2::bigint
+`
+
+type TouchAPIKeyParams struct {
+	LastUsedAt pgtype.Timestamp
+	ID         int64
+}
+
+// recorded while authenticating, before the tenant is known
+func (q *Queries) TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error {
+	_, err := q.db.Exec(ctx, touchAPIKey, arg.LastUsedAt, arg.ID)
+	return err
+}
@@ -0,0 +1,253 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_log.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getLastAuditHash = `-- name: GetLastAuditHash :one
+SELECT hash
+FROM audit_log
+WHERE tenant_id = $1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastAuditHash(ctx context.Context, tenantID string) (string, error) {
+	row := q.db.QueryRow(ctx, getLastAuditHash, tenantID)
+	var hash string
+	err := row.Scan(&hash)
+	return hash, err
+}
+
+const insertAuditEntry = `-- name: InsertAuditEntry :one
+INSERT INTO audit_log (
+    tenant_id,
+    occurred_at,
+    actor,
+    action,
+    entity_type,
+    entity_id,
+    before_state,
+    after_state,
+    request_id,
+    idempotency_key,
+    source_ip,
+    prev_hash,
+    hash
+  )
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING id, tenant_id, occurred_at, actor, action, entity_type, entity_id, before_state, after_state, request_id, idempotency_key, source_ip, prev_hash, hash
+`
+
+type InsertAuditEntryParams struct {
+	TenantID       string
+	OccurredAt     pgtype.Timestamp
+	Actor          string
+	Action         string
+	EntityType     string
+	EntityID       string
+	BeforeState    []byte
+	AfterState     []byte
+	RequestID      string
+	IdempotencyKey string
+	SourceIp       string
+	PrevHash       string
+	Hash           string
+}
+
+func (q *Queries) InsertAuditEntry(ctx context.Context, arg InsertAuditEntryParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, insertAuditEntry,
+		arg.TenantID,
+		arg.OccurredAt,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.BeforeState,
+		arg.AfterState,
+		arg.RequestID,
+		arg.IdempotencyKey,
+		arg.SourceIp,
+		arg.PrevHash,
+		arg.Hash,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.OccurredAt,
+		&i.Actor,
+		&i.Action,
+		&i.EntityType,
+		&i.EntityID,
+		&i.BeforeState,
+		&i.AfterState,
+		&i.RequestID,
+		&i.IdempotencyKey,
+		&i.SourceIp,
+		&i.PrevHash,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const listAuditChain = `-- name: ListAuditChain :many
+SELECT id, tenant_id, occurred_at, actor, action, entity_type, entity_id, before_state, after_state, request_id, idempotency_key, source_ip, prev_hash, hash
+FROM audit_log
+WHERE tenant_id = $1::text
+  AND id > $2::bigint
+ORDER BY id
+LIMIT $3::int
+`
+
+type ListAuditChainParams struct {
+	TenantID string
+	AfterID  int64
+	LimitVal int32
+}
+
+// the chain of a tenant in write order, to verify it
+func (q *Queries) ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditChain, arg.TenantID, arg.AfterID, arg.LimitVal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.OccurredAt,
+			&i.Actor,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.BeforeState,
+			&i.AfterState,
+			&i.RequestID,
+			&i.IdempotencyKey,
+			&i.SourceIp,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEntries = `-- name: ListAuditEntries :many
+SELECT id, tenant_id, occurred_at, actor, action, entity_type, entity_id, before_state, after_state, request_id, idempotency_key, source_ip, prev_hash, hash
+FROM audit_log
+WHERE tenant_id = $1::text
+  AND (
+    $2::bigint IS NULL
+    OR id < $2::bigint
+  )
+  AND (
+    $3::text IS NULL
+    OR actor = $3::text
+  )
+  AND (
+    $4::text IS NULL
+    OR action = $4::text
+  )
+  AND (
+    $5::text IS NULL
+    OR entity_type = $5::text
+  )
+  AND (
+    $6::text IS NULL
+    OR entity_id = $6::text
+  )
+  AND (
+    $7::timestamp IS NULL
+    OR occurred_at >= $7::timestamp
+  )
+  AND (
+    $8::timestamp IS NULL
+    OR occurred_at < $8::timestamp
+  )
+ORDER BY id DESC
+LIMIT $9::int
+`
+
+type ListAuditEntriesParams struct {
+	TenantID     string
+	CursorID     pgtype.Int8
+	Actor        pgtype.Text
+	Action       pgtype.Text
+	EntityType   pgtype.Text
+	EntityID     pgtype.Text
+	OccurredFrom pgtype.Timestamp
+	OccurredTo   pgtype.Timestamp
+	LimitVal     int32
+}
+
+func (q *Queries) ListAuditEntries(ctx context.Context, arg ListAuditEntriesParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditEntries,
+		arg.TenantID,
+		arg.CursorID,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.OccurredFrom,
+		arg.OccurredTo,
+		arg.LimitVal,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.OccurredAt,
+			&i.Actor,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.BeforeState,
+			&i.AfterState,
+			&i.RequestID,
+			&i.IdempotencyKey,
+			&i.SourceIp,
+			&i.PrevHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAuditChain = `-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_log:' || $1::text))
+`
+
+// entries of a tenant are chained one after the other, held until the end of the transaction
+func (q *Queries) LockAuditChain(ctx context.Context, tenantID string) error {
+	_, err := q.db.Exec(ctx, lockAuditChain, tenantID)
+	return err
+}
@@ -0,0 +1,503 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: collections.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeDueRetries = `-- name: ConsumeDueRetries :exec
+UPDATE collection_items
+SET status = 'RETRIED',
+  updated_at = now()
+WHERE status = 'RETRY_PENDING'
+  AND next_attempt_on <= $1::date
+  AND EXISTS (
+    SELECT 1
+    FROM mandates m
+    WHERE m.loan_id = collection_items.loan_id
+      AND m.status = 'ACTIVE'
+  )
+  AND batch_id IN (
+    SELECT b.id
+    FROM collection_batches b
+    WHERE b.tenant_id = $2::text
+  )
+`
+
+type ConsumeDueRetriesParams struct {
+	CollectionDate pgtype.Date
+	TenantID       string
+}
+
+// only the retries ListCollectionCandidates picked up, a loan without an active mandate keeps its retry for later
+func (q *Queries) ConsumeDueRetries(ctx context.Context, arg ConsumeDueRetriesParams) error {
+	_, err := q.db.Exec(ctx, consumeDueRetries, arg.CollectionDate, arg.TenantID)
+	return err
+}
+
+type CreateCollectionItemsParams struct {
+	BatchID          int64
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int32
+	Amount           int64
+	Attempt          int32
+	Status           string
+}
+
+const getActiveMandateByLoanID = `-- name: GetActiveMandateByLoanID :one
+SELECT m.id, m.loan_id, m.account_holder, m.bank_code, m.account_number, m.reference, m.status, m.created_at, m.revoked_at
+FROM mandates m
+  JOIN loans l ON l.id = m.loan_id
+WHERE m.loan_id = $1
+  AND l.tenant_id = $2
+  AND m.status = 'ACTIVE'
+LIMIT 1
+`
+
+type GetActiveMandateByLoanIDParams struct {
+	LoanID   int64
+	TenantID string
+}
+
+func (q *Queries) GetActiveMandateByLoanID(ctx context.Context, arg GetActiveMandateByLoanIDParams) (Mandate, error) {
+	row := q.db.QueryRow(ctx, getActiveMandateByLoanID, arg.LoanID, arg.TenantID)
+	var i Mandate
+	err := row.Scan(
+		&i.ID,
+		&i.LoanID,
+		&i.AccountHolder,
+		&i.BankCode,
+		&i.AccountNumber,
+		&i.Reference,
+		&i.Status,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getCollectionBatchByID = `-- name: GetCollectionBatchByID :one
+SELECT id, collection_date, status, item_count, total_amount, created_at, updated_at, tenant_id
+FROM collection_batches
+WHERE id = $1
+  AND tenant_id = $2
+`
+
+type GetCollectionBatchByIDParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) GetCollectionBatchByID(ctx context.Context, arg GetCollectionBatchByIDParams) (CollectionBatch, error) {
+	row := q.db.QueryRow(ctx, getCollectionBatchByID, arg.ID, arg.TenantID)
+	var i CollectionBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CollectionDate,
+		&i.Status,
+		&i.ItemCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const insertCollectionBatch = `-- name: InsertCollectionBatch :one
+INSERT INTO collection_batches (
+    collection_date,
+    status,
+    item_count,
+    total_amount,
+    tenant_id
+  )
+VALUES ($1, 'CREATED', $2, $3, $4)
+RETURNING id, collection_date, status, item_count, total_amount, created_at, updated_at, tenant_id
+`
+
+type InsertCollectionBatchParams struct {
+	CollectionDate pgtype.Date
+	ItemCount      int32
+	TotalAmount    int64
+	TenantID       string
+}
+
+func (q *Queries) InsertCollectionBatch(ctx context.Context, arg InsertCollectionBatchParams) (CollectionBatch, error) {
+	row := q.db.QueryRow(ctx, insertCollectionBatch,
+		arg.CollectionDate,
+		arg.ItemCount,
+		arg.TotalAmount,
+		arg.TenantID,
+	)
+	var i CollectionBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CollectionDate,
+		&i.Status,
+		&i.ItemCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const insertMandate = `-- name: InsertMandate :one
+INSERT INTO mandates (
+    loan_id,
+    account_holder,
+    bank_code,
+    account_number,
+    reference,
+    status
+  )
+VALUES ($1, $2, $3, $4, $5, 'ACTIVE')
+RETURNING id, loan_id, account_holder, bank_code, account_number, reference, status, created_at, revoked_at
+`
+
+type InsertMandateParams struct {
+	LoanID        int64
+	AccountHolder string
+	BankCode      string
+	AccountNumber string
+	Reference     string
+}
+
+func (q *Queries) InsertMandate(ctx context.Context, arg InsertMandateParams) (Mandate, error) {
+	row := q.db.QueryRow(ctx, insertMandate,
+		arg.LoanID,
+		arg.AccountHolder,
+		arg.BankCode,
+		arg.AccountNumber,
+		arg.Reference,
+	)
+	var i Mandate
+	err := row.Scan(
+		&i.ID,
+		&i.LoanID,
+		&i.AccountHolder,
+		&i.BankCode,
+		&i.AccountNumber,
+		&i.Reference,
+		&i.Status,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const listCollectionCandidates = `-- name: ListCollectionCandidates :many
+SELECT s.loan_id,
+  s.sequence,
+  s.due_date,
+  (s.amount - s.paid_amount)::BIGINT AS amount,
+  m.id AS mandate_id,
+  (
+    SELECT COUNT(*)
+    FROM collection_items prev
+    WHERE prev.loan_id = s.loan_id
+      AND prev.schedule_sequence = s.sequence
+  )::INT AS previous_attempts
+FROM schedules s
+  JOIN mandates m ON m.loan_id = s.loan_id
+  AND m.status = 'ACTIVE'
+WHERE s.tenant_id = $1::text
+  AND s.due_date <= $2::date
+  AND s.status <> 'PAID'
+  AND NOT EXISTS (
+    SELECT 1
+    FROM collection_items ci
+    WHERE ci.loan_id = s.loan_id
+      AND ci.schedule_sequence = s.sequence
+      AND (
+        ci.status IN ('SUBMITTED', 'SUCCEEDED', 'FAILED', 'UNPOSTED')
+        OR (
+          ci.status = 'RETRY_PENDING'
+          AND ci.next_attempt_on > $2::date
+        )
+      )
+  )
+ORDER BY s.loan_id,
+  s.sequence
+`
+
+type ListCollectionCandidatesParams struct {
+	TenantID       string
+	CollectionDate pgtype.Date
+}
+
+type ListCollectionCandidatesRow struct {
+	LoanID           int64
+	Sequence         int32
+	DueDate          pgtype.Date
+	Amount           int64
+	MandateID        int64
+	PreviousAttempts int32
+}
+
+func (q *Queries) ListCollectionCandidates(ctx context.Context, arg ListCollectionCandidatesParams) ([]ListCollectionCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, listCollectionCandidates, arg.TenantID, arg.CollectionDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCollectionCandidatesRow
+	for rows.Next() {
+		var i ListCollectionCandidatesRow
+		if err := rows.Scan(
+			&i.LoanID,
+			&i.Sequence,
+			&i.DueDate,
+			&i.Amount,
+			&i.MandateID,
+			&i.PreviousAttempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCollectionItemsByBatchID = `-- name: ListCollectionItemsByBatchID :many
+SELECT ci.id,
+  ci.batch_id,
+  ci.loan_id,
+  ci.mandate_id,
+  ci.schedule_sequence,
+  ci.amount,
+  ci.attempt,
+  ci.status,
+  ci.failure_code,
+  ci.next_attempt_on,
+  ci.payment_id,
+  m.account_holder,
+  m.bank_code,
+  m.account_number,
+  m.reference AS mandate_reference
+FROM collection_items ci
+  JOIN mandates m ON m.id = ci.mandate_id
+  JOIN collection_batches b ON b.id = ci.batch_id
+WHERE ci.batch_id = $1
+  AND b.tenant_id = $2
+ORDER BY ci.id
+`
+
+type ListCollectionItemsByBatchIDParams struct {
+	BatchID  int64
+	TenantID string
+}
+
+type ListCollectionItemsByBatchIDRow struct {
+	ID               int64
+	BatchID          int64
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int32
+	Amount           int64
+	Attempt          int32
+	Status           string
+	FailureCode      pgtype.Text
+	NextAttemptOn    pgtype.Date
+	PaymentID        pgtype.Int8
+	AccountHolder    string
+	BankCode         string
+	AccountNumber    string
+	MandateReference string
+}
+
+func (q *Queries) ListCollectionItemsByBatchID(ctx context.Context, arg ListCollectionItemsByBatchIDParams) ([]ListCollectionItemsByBatchIDRow, error) {
+	rows, err := q.db.Query(ctx, listCollectionItemsByBatchID, arg.BatchID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCollectionItemsByBatchIDRow
+	for rows.Next() {
+		var i ListCollectionItemsByBatchIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.BatchID,
+			&i.LoanID,
+			&i.MandateID,
+			&i.ScheduleSequence,
+			&i.Amount,
+			&i.Attempt,
+			&i.Status,
+			&i.FailureCode,
+			&i.NextAttemptOn,
+			&i.PaymentID,
+			&i.AccountHolder,
+			&i.BankCode,
+			&i.AccountNumber,
+			&i.MandateReference,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockCollectionBatchForUpdate = `-- name: LockCollectionBatchForUpdate :one
+SELECT id, collection_date, status, item_count, total_amount, created_at, updated_at, tenant_id
+FROM collection_batches
+WHERE id = $1
+  AND tenant_id = $2 FOR
+UPDATE
+`
+
+type LockCollectionBatchForUpdateParams struct {
+	ID       int64
+	TenantID string
+}
+
+// serializes the imports of a batch until the end of the transaction
+func (q *Queries) LockCollectionBatchForUpdate(ctx context.Context, arg LockCollectionBatchForUpdateParams) (CollectionBatch, error) {
+	row := q.db.QueryRow(ctx, lockCollectionBatchForUpdate, arg.ID, arg.TenantID)
+	var i CollectionBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CollectionDate,
+		&i.Status,
+		&i.ItemCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const lockCollectionRun = `-- name: LockCollectionRun :exec
+SELECT pg_advisory_xact_lock(hashtext('collection_run'))
+`
+
+// serialize collection runs across instances for the lifetime of the transaction
+func (q *Queries) LockCollectionRun(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockCollectionRun)
+	return err
+}
+
+const revokeMandate = `-- name: RevokeMandate :one
+UPDATE mandates
+SET status = 'REVOKED',
+  revoked_at = now()
+WHERE loan_id = $1
+  AND status = 'ACTIVE'
+  AND loan_id IN (
+    SELECT l.id
+    FROM loans l
+    WHERE l.tenant_id = $2
+  )
+RETURNING id, loan_id, account_holder, bank_code, account_number, reference, status, created_at, revoked_at
+`
+
+type RevokeMandateParams struct {
+	LoanID   int64
+	TenantID string
+}
+
+func (q *Queries) RevokeMandate(ctx context.Context, arg RevokeMandateParams) (Mandate, error) {
+	row := q.db.QueryRow(ctx, revokeMandate, arg.LoanID, arg.TenantID)
+	var i Mandate
+	err := row.Scan(
+		&i.ID,
+		&i.LoanID,
+		&i.AccountHolder,
+		&i.BankCode,
+		&i.AccountNumber,
+		&i.Reference,
+		&i.Status,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const updateCollectionBatchStatus = `-- name: UpdateCollectionBatchStatus :one
+UPDATE collection_batches
+SET status = $1,
+  updated_at = now()
+WHERE id = $2
+  AND tenant_id = $3
+RETURNING id, collection_date, status, item_count, total_amount, created_at, updated_at, tenant_id
+`
+
+type UpdateCollectionBatchStatusParams struct {
+	Status   string
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) UpdateCollectionBatchStatus(ctx context.Context, arg UpdateCollectionBatchStatusParams) (CollectionBatch, error) {
+	row := q.db.QueryRow(ctx, updateCollectionBatchStatus, arg.Status, arg.ID, arg.TenantID)
+	var i CollectionBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CollectionDate,
+		&i.Status,
+		&i.ItemCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const updateCollectionItemResult = `-- name: UpdateCollectionItemResult :one
+UPDATE collection_items ci
+SET status = $1,
+  failure_code = $2,
+  next_attempt_on = $3,
+  payment_id = $4,
+  updated_at = now()
+FROM collection_batches b
+WHERE ci.id = $5
+  AND ci.batch_id = $6
+  AND ci.status = 'SUBMITTED'
+  AND b.id = ci.batch_id
+  AND b.tenant_id = $7
+RETURNING ci.id
+`
+
+type UpdateCollectionItemResultParams struct {
+	Status        string
+	FailureCode   pgtype.Text
+	NextAttemptOn pgtype.Date
+	PaymentID     pgtype.Int8
+	ID            int64
+	BatchID       int64
+	TenantID      string
+}
+
+func (q *Queries) UpdateCollectionItemResult(ctx context.Context, arg UpdateCollectionItemResultParams) (int64, error) {
+	row := q.db.QueryRow(ctx, updateCollectionItemResult,
+		arg.Status,
+		arg.FailureCode,
+		arg.NextAttemptOn,
+		arg.PaymentID,
+		arg.ID,
+		arg.BatchID,
+		arg.TenantID,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
@@ -9,6 +9,44 @@ import (
 	"context"
 )
 
+// iteratorForCreateCollectionItems implements pgx.CopyFromSource.
+type iteratorForCreateCollectionItems struct {
+	rows                 []CreateCollectionItemsParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCreateCollectionItems) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCreateCollectionItems) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].BatchID,
+		r.rows[0].LoanID,
+		r.rows[0].MandateID,
+		r.rows[0].ScheduleSequence,
+		r.rows[0].Amount,
+		r.rows[0].Attempt,
+		r.rows[0].Status,
+	}, nil
+}
+
+func (r iteratorForCreateCollectionItems) Err() error {
+	return nil
+}
+
+func (q *Queries) CreateCollectionItems(ctx context.Context, arg []CreateCollectionItemsParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"collection_items"}, []string{"batch_id", "loan_id", "mandate_id", "schedule_sequence", "amount", "attempt", "status"}, &iteratorForCreateCollectionItems{rows: arg})
+}
+
 // iteratorForCreateLoanSchedules implements pgx.CopyFromSource.
 type iteratorForCreateLoanSchedules struct {
 	rows                 []CreateLoanSchedulesParams
@@ -34,6 +72,7 @@ func (r iteratorForCreateLoanSchedules) Values() ([]interface{}, error) {
 		r.rows[0].DueDate,
 		r.rows[0].Amount,
 		r.rows[0].Status,
+		r.rows[0].TenantID,
 	}, nil
 }
 
@@ -42,5 +81,5 @@ func (r iteratorForCreateLoanSchedules) Err() error {
 }
 
 func (q *Queries) CreateLoanSchedules(ctx context.Context, arg []CreateLoanSchedulesParams) (int64, error) {
-	return q.db.CopyFrom(ctx, []string{"schedules"}, []string{"loan_id", "sequence", "due_date", "amount", "status"}, &iteratorForCreateLoanSchedules{rows: arg})
+	return q.db.CopyFrom(ctx, []string{"schedules"}, []string{"loan_id", "sequence", "due_date", "amount", "status", "tenant_id"}, &iteratorForCreateLoanSchedules{rows: arg})
 }
@@ -0,0 +1,178 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: idempotency.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acquireIdempotencyKey = `-- name: AcquireIdempotencyKey :one
+INSERT INTO idempotency_keys (
+    tenant_id,
+    key,
+    request_hash,
+    status,
+    locked_at,
+    created_at,
+    expires_at
+  )
+VALUES (
+    $1::text,
+    $2::text,
+    $3::text,
+    'IN_PROGRESS',
+    $4::timestamp,
+    $4::timestamp,
+    $5::timestamp
+  ) ON CONFLICT (tenant_id, key) DO
+UPDATE
+SET request_hash = EXCLUDED.request_hash,
+  status = 'IN_PROGRESS',
+  response_status = NULL,
+  response_content_type = NULL,
+  response_body = NULL,
+  locked_at = EXCLUDED.locked_at,
+  created_at = EXCLUDED.created_at,
+  completed_at = NULL,
+  expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= $4::timestamp
+  OR (
+    idempotency_keys.status = 'IN_PROGRESS'
+    AND idempotency_keys.locked_at <= $6::timestamp
+  )
+RETURNING key, request_hash, status, response_status, response_content_type, response_body, locked_at, created_at, completed_at, expires_at, tenant_id
+`
+
+type AcquireIdempotencyKeyParams struct {
+	TenantID    string
+	Key         string
+	RequestHash string
+	Now         pgtype.Timestamp
+	ExpiresAt   pgtype.Timestamp
+	StaleBefore pgtype.Timestamp
+}
+
+// claims a new key, or takes over one that expired or whose owner stopped before completing it
+func (q *Queries) AcquireIdempotencyKey(ctx context.Context, arg AcquireIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, acquireIdempotencyKey,
+		arg.TenantID,
+		arg.Key,
+		arg.RequestHash,
+		arg.Now,
+		arg.ExpiresAt,
+		arg.StaleBefore,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.RequestHash,
+		&i.Status,
+		&i.ResponseStatus,
+		&i.ResponseContentType,
+		&i.ResponseBody,
+		&i.LockedAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = 'COMPLETED',
+  response_status = $1::int,
+  response_content_type = $2::text,
+  response_body = $3::bytea,
+  completed_at = now()
+WHERE tenant_id = $4::text
+  AND key = $5::text
+  AND status = 'IN_PROGRESS'
+`
+
+type CompleteIdempotencyKeyParams struct {
+	ResponseStatus      int32
+	ResponseContentType string
+	ResponseBody        []byte
+	TenantID            string
+	Key                 string
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, completeIdempotencyKey,
+		arg.ResponseStatus,
+		arg.ResponseContentType,
+		arg.ResponseBody,
+		arg.TenantID,
+		arg.Key,
+	)
+	return err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= $1::timestamp
+`
+
+// purges the expired keys of every tenant
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context, now pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredIdempotencyKeys, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT key, request_hash, status, response_status, response_content_type, response_body, locked_at, created_at, completed_at, expires_at, tenant_id
+FROM idempotency_keys
+WHERE tenant_id = $1
+  AND key = $2
+`
+
+type GetIdempotencyKeyParams struct {
+	TenantID string
+	Key      string
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, arg.TenantID, arg.Key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.RequestHash,
+		&i.Status,
+		&i.ResponseStatus,
+		&i.ResponseContentType,
+		&i.ResponseBody,
+		&i.LockedAt,
+		&i.CreatedAt,
+		&i.CompletedAt,
+		&i.ExpiresAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const releaseIdempotencyKey = `-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE tenant_id = $1
+  AND key = $2
+  AND status = 'IN_PROGRESS'
+`
+
+type ReleaseIdempotencyKeyParams struct {
+	TenantID string
+	Key      string
+}
+
+func (q *Queries) ReleaseIdempotencyKey(ctx context.Context, arg ReleaseIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, releaseIdempotencyKey, arg.TenantID, arg.Key)
+	return err
+}
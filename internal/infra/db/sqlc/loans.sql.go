@@ -12,13 +12,19 @@ import (
 )
 
 const getLoanByID = `-- name: GetLoanByID :one
-SELECT id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at
+SELECT id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at, borrower_id, tenant_id
 FROM loans
 WHERE id = $1
+  AND tenant_id = $2
 `
 
-func (q *Queries) GetLoanByID(ctx context.Context, id int64) (Loan, error) {
-	row := q.db.QueryRow(ctx, getLoanByID, id)
+type GetLoanByIDParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) GetLoanByID(ctx context.Context, arg GetLoanByIDParams) (Loan, error) {
+	row := q.db.QueryRow(ctx, getLoanByID, arg.ID, arg.TenantID)
 	var i Loan
 	err := row.Scan(
 		&i.ID,
@@ -29,6 +35,8 @@ func (q *Queries) GetLoanByID(ctx context.Context, id int64) (Loan, error) {
 		&i.TotalWeeks,
 		&i.StartDate,
 		&i.CreatedAt,
+		&i.BorrowerID,
+		&i.TenantID,
 	)
 	return i, err
 }
@@ -40,10 +48,12 @@ INSERT INTO loans (
     total_payable_amount,
     weekly_payment_amount,
     total_weeks,
-    start_date
+    start_date,
+    borrower_id,
+    tenant_id
   )
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at, borrower_id, tenant_id
 `
 
 type InsertLoanParams struct {
@@ -53,6 +63,8 @@ type InsertLoanParams struct {
 	WeeklyPaymentAmount int64
 	TotalWeeks          int32
 	StartDate           pgtype.Date
+	BorrowerID          pgtype.Text
+	TenantID            string
 }
 
 func (q *Queries) InsertLoan(ctx context.Context, arg InsertLoanParams) (Loan, error) {
@@ -63,7 +75,100 @@ func (q *Queries) InsertLoan(ctx context.Context, arg InsertLoanParams) (Loan, e
 		arg.WeeklyPaymentAmount,
 		arg.TotalWeeks,
 		arg.StartDate,
+		arg.BorrowerID,
+		arg.TenantID,
+	)
+	var i Loan
+	err := row.Scan(
+		&i.ID,
+		&i.PrincipalAmount,
+		&i.TotalInterestAmount,
+		&i.TotalPayableAmount,
+		&i.WeeklyPaymentAmount,
+		&i.TotalWeeks,
+		&i.StartDate,
+		&i.CreatedAt,
+		&i.BorrowerID,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const listLoans = `-- name: ListLoans :many
+SELECT id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at, borrower_id, tenant_id
+FROM loans
+WHERE tenant_id = $1::text
+  AND (
+    $2::bigint IS NULL
+    OR id < $2::bigint
+  )
+  AND (
+    $3::text IS NULL
+    OR borrower_id = $3::text
+  )
+ORDER BY id DESC
+LIMIT $4::int
+`
+
+type ListLoansParams struct {
+	TenantID   string
+	CursorID   pgtype.Int8
+	BorrowerID pgtype.Text
+	LimitVal   int32
+}
+
+func (q *Queries) ListLoans(ctx context.Context, arg ListLoansParams) ([]Loan, error) {
+	rows, err := q.db.Query(ctx, listLoans,
+		arg.TenantID,
+		arg.CursorID,
+		arg.BorrowerID,
+		arg.LimitVal,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Loan
+	for rows.Next() {
+		var i Loan
+		if err := rows.Scan(
+			&i.ID,
+			&i.PrincipalAmount,
+			&i.TotalInterestAmount,
+			&i.TotalPayableAmount,
+			&i.WeeklyPaymentAmount,
+			&i.TotalWeeks,
+			&i.StartDate,
+			&i.CreatedAt,
+			&i.BorrowerID,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockLoanForUpdate = `-- name: LockLoanForUpdate :one
+SELECT id, principal_amount, total_interest_amount, total_payable_amount, weekly_payment_amount, total_weeks, start_date, created_at, borrower_id, tenant_id
+FROM loans
+WHERE id = $1
+  AND tenant_id = $2 FOR
+UPDATE
+`
+
+type LockLoanForUpdateParams struct {
+	ID       int64
+	TenantID string
+}
+
+// row lock held until the end of the transaction, serializes concurrent writes on the same loan
+func (q *Queries) LockLoanForUpdate(ctx context.Context, arg LockLoanForUpdateParams) (Loan, error) {
+	row := q.db.QueryRow(ctx, lockLoanForUpdate, arg.ID, arg.TenantID)
 	var i Loan
 	err := row.Scan(
 		&i.ID,
@@ -74,6 +179,8 @@ func (q *Queries) InsertLoan(ctx context.Context, arg InsertLoanParams) (Loan, e
 		&i.TotalWeeks,
 		&i.StartDate,
 		&i.CreatedAt,
+		&i.BorrowerID,
+		&i.TenantID,
 	)
 	return i, err
 }
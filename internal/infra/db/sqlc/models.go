@@ -8,6 +8,79 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID          int64
+	Name        string
+	Prefix      string
+	KeyHash     string
+	Scopes      []string
+	ExpiresAt   pgtype.Timestamp
+	LastUsedAt  pgtype.Timestamp
+	RevokedAt   pgtype.Timestamp
+	RotatedFrom pgtype.Int8
+	CreatedAt   pgtype.Timestamp
+	Roles       []string
+	TenantID    string
+}
+
+type AuditLog struct {
+	ID             int64
+	TenantID       string
+	OccurredAt     pgtype.Timestamp
+	Actor          string
+	Action         string
+	EntityType     string
+	EntityID       string
+	BeforeState    []byte
+	AfterState     []byte
+	RequestID      string
+	IdempotencyKey string
+	SourceIp       string
+	PrevHash       string
+	Hash           string
+}
+
+type CollectionBatch struct {
+	ID             int64
+	CollectionDate pgtype.Date
+	Status         string
+	ItemCount      int32
+	TotalAmount    int64
+	CreatedAt      pgtype.Timestamp
+	UpdatedAt      pgtype.Timestamp
+	TenantID       string
+}
+
+type CollectionItem struct {
+	ID               int64
+	BatchID          int64
+	LoanID           int64
+	MandateID        int64
+	ScheduleSequence int32
+	Amount           int64
+	Attempt          int32
+	Status           string
+	FailureCode      pgtype.Text
+	NextAttemptOn    pgtype.Date
+	PaymentID        pgtype.Int8
+	CreatedAt        pgtype.Timestamp
+	UpdatedAt        pgtype.Timestamp
+}
+
+type IdempotencyKey struct {
+	Key                 string
+	RequestHash         string
+	Status              string
+	ResponseStatus      pgtype.Int4
+	ResponseContentType pgtype.Text
+	ResponseBody        []byte
+	LockedAt            pgtype.Timestamp
+	CreatedAt           pgtype.Timestamp
+	CompletedAt         pgtype.Timestamp
+	ExpiresAt           pgtype.Timestamp
+	TenantID            string
+}
+
 type Loan struct {
 	ID                  int64
 	PrincipalAmount     int64
@@ -17,6 +90,35 @@ type Loan struct {
 	TotalWeeks          int32
 	StartDate           pgtype.Date
 	CreatedAt           pgtype.Timestamp
+	BorrowerID          pgtype.Text
+	TenantID            string
+}
+
+type Mandate struct {
+	ID            int64
+	LoanID        int64
+	AccountHolder string
+	BankCode      string
+	AccountNumber string
+	Reference     string
+	Status        string
+	CreatedAt     pgtype.Timestamp
+	RevokedAt     pgtype.Timestamp
+}
+
+type OutboxEvent struct {
+	ID            int64
+	AggregateType string
+	AggregateID   int64
+	EventType     string
+	Payload       []byte
+	Status        string
+	Attempts      int32
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamp
+	CreatedAt     pgtype.Timestamp
+	DispatchedAt  pgtype.Timestamp
+	TenantID      string
 }
 
 type Payment struct {
@@ -27,6 +129,14 @@ type Payment struct {
 	IdempotencyKey string
 	PaidAt         pgtype.Timestamp
 	CreatedAt      pgtype.Timestamp
+	TenantID       string
+}
+
+type RateLimitBucket struct {
+	BucketKey string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt pgtype.Timestamp
 }
 
 type Schedule struct {
@@ -39,4 +149,48 @@ type Schedule struct {
 	Status     string
 	CreatedAt  pgtype.Timestamp
 	UpdatedAt  pgtype.Timestamp
+	TenantID   string
+}
+
+type SchemaMigration struct {
+	Version   int32
+	Name      string
+	Checksum  pgtype.Text
+	AppliedAt pgtype.Timestamp
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        int64
+	EventType      string
+	Status         string
+	Attempts       int32
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	NextAttemptAt  pgtype.Timestamp
+	CreatedAt      pgtype.Timestamp
+	DeliveredAt    pgtype.Timestamp
+	TenantID       string
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          int64
+	DeliveryID  int64
+	Attempt     int32
+	StatusCode  pgtype.Int4
+	Error       pgtype.Text
+	DurationMs  int32
+	AttemptedAt pgtype.Timestamp
+}
+
+type WebhookSubscription struct {
+	ID         int64
+	Url        string
+	EventTypes []string
+	Secret     string
+	Status     string
+	CreatedAt  pgtype.Timestamp
+	DeletedAt  pgtype.Timestamp
+	TenantID   string
 }
@@ -0,0 +1,242 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbox.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimOutboxEvents = `-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET next_attempt_at = $1::timestamp
+WHERE id IN (
+    SELECT e.id
+    FROM outbox_events e
+    WHERE e.status = 'PENDING'
+      AND e.next_attempt_at <= $2::timestamp
+      AND NOT EXISTS (
+        SELECT 1
+        FROM outbox_events blocked
+        WHERE blocked.aggregate_type = e.aggregate_type
+          AND blocked.aggregate_id = e.aggregate_id
+          AND blocked.status = 'PENDING'
+          AND blocked.id < e.id
+          AND blocked.next_attempt_at > $2::timestamp
+      )
+    ORDER BY e.id
+    LIMIT $3::int FOR
+    UPDATE SKIP LOCKED
+  )
+RETURNING id, aggregate_type, aggregate_id, event_type, payload, status, attempts, last_error, next_attempt_at, created_at, dispatched_at, tenant_id
+`
+
+type ClaimOutboxEventsParams struct {
+	LeaseUntil pgtype.Timestamp
+	Now        pgtype.Timestamp
+	LimitVal   int32
+}
+
+// leases the due events until lease_until by moving their next attempt, they are published outside of the transaction
+// and picked up again once the lease expired when their dispatcher died in between
+func (q *Queries) ClaimOutboxEvents(ctx context.Context, arg ClaimOutboxEventsParams) ([]OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, claimOutboxEvents, arg.LeaseUntil, arg.Now, arg.LimitVal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OutboxEvent
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.DispatchedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertOutboxEvent = `-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (
+    aggregate_type,
+    aggregate_id,
+    event_type,
+    payload,
+    tenant_id
+  )
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id
+`
+
+type InsertOutboxEventParams struct {
+	AggregateType string
+	AggregateID   int64
+	EventType     string
+	Payload       []byte
+	TenantID      string
+}
+
+func (q *Queries) InsertOutboxEvent(ctx context.Context, arg InsertOutboxEventParams) (int64, error) {
+	row := q.db.QueryRow(ctx, insertOutboxEvent,
+		arg.AggregateType,
+		arg.AggregateID,
+		arg.EventType,
+		arg.Payload,
+		arg.TenantID,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const listNewlyDelinquentLoans = `-- name: ListNewlyDelinquentLoans :many
+WITH loan_progress AS (
+  SELECT l.id,
+    l.total_weeks,
+    (
+      FLOOR(
+        DATE_PART('epoch', $3::timestamp - l.created_at) / 604800
+      ) + 1
+    )::INT AS expected_week,
+    COALESCE(MAX(p.week_number), 0)::INT AS last_paid_week,
+    MAX(p.created_at) AS last_paid_at
+  FROM loans l
+    LEFT JOIN payments p ON p.loan_id = l.id
+  WHERE l.tenant_id = $4::text
+    AND l.created_at <= $3::timestamp
+  GROUP BY l.id
+)
+SELECT lp.id,
+  lp.last_paid_week,
+  lp.expected_week
+FROM loan_progress lp
+WHERE lp.last_paid_week < lp.total_weeks
+  AND lp.expected_week - lp.last_paid_week >= $1::int
+  AND NOT EXISTS (
+    SELECT 1
+    FROM outbox_events e
+    WHERE e.aggregate_type = 'LOAN'
+      AND e.aggregate_id = lp.id
+      AND e.event_type = 'LoanBecameDelinquent'
+      AND (
+        lp.last_paid_at IS NULL
+        OR e.created_at > lp.last_paid_at
+      )
+  )
+ORDER BY lp.id
+LIMIT $2::int
+`
+
+type ListNewlyDelinquentLoansParams struct {
+	GapWeeks    int32
+	LimitVal    int32
+	EvaluatedAt pgtype.Timestamp
+	TenantID    string
+}
+
+type ListNewlyDelinquentLoansRow struct {
+	ID           int64
+	LastPaidWeek int32
+	ExpectedWeek int32
+}
+
+// loans of the tenant that crossed its delinquency threshold and have no LoanBecameDelinquent event since their last payment
+func (q *Queries) ListNewlyDelinquentLoans(ctx context.Context, arg ListNewlyDelinquentLoansParams) ([]ListNewlyDelinquentLoansRow, error) {
+	rows, err := q.db.Query(ctx, listNewlyDelinquentLoans,
+		arg.GapWeeks,
+		arg.LimitVal,
+		arg.EvaluatedAt,
+		arg.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNewlyDelinquentLoansRow
+	for rows.Next() {
+		var i ListNewlyDelinquentLoansRow
+		if err := rows.Scan(&i.ID, &i.LastPaidWeek, &i.ExpectedWeek); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOutboxEventDispatched = `-- name: MarkOutboxEventDispatched :exec
+UPDATE outbox_events
+SET status = 'DISPATCHED',
+  attempts = attempts + 1,
+  last_error = NULL,
+  dispatched_at = now()
+WHERE id = $1
+  AND status = 'PENDING'
+`
+
+func (q *Queries) MarkOutboxEventDispatched(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, markOutboxEventDispatched, id)
+	return err
+}
+
+const markOutboxEventFailed = `-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET status = $1::text,
+  attempts = attempts + 1,
+  last_error = $2::text,
+  next_attempt_at = $3::timestamp
+WHERE id = $4::bigint
+  AND status = 'PENDING'
+`
+
+type MarkOutboxEventFailedParams struct {
+	Status        string
+	LastError     string
+	NextAttemptAt pgtype.Timestamp
+	ID            int64
+}
+
+// an event dispatched meanwhile by the dispatcher that took over an expired lease is left alone
+func (q *Queries) MarkOutboxEventFailed(ctx context.Context, arg MarkOutboxEventFailedParams) error {
+	_, err := q.db.Exec(ctx, markOutboxEventFailed,
+		arg.Status,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.ID,
+	)
+	return err
+}
+
+const tryLockOutboxDispatch = `-- name: TryLockOutboxDispatch :one
+SELECT pg_try_advisory_xact_lock(hashtext('outbox_dispatch'))
+`
+
+// only one instance dispatches at a time, which keeps the per aggregate ordering simple
+func (q *Queries) TryLockOutboxDispatch(ctx context.Context) (bool, error) {
+	row := q.db.QueryRow(ctx, tryLockOutboxDispatch)
+	var pg_try_advisory_xact_lock bool
+	err := row.Scan(&pg_try_advisory_xact_lock)
+	return pg_try_advisory_xact_lock, err
+}
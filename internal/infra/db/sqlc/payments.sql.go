@@ -15,10 +15,16 @@ const getLastPaidWeek = `-- name: GetLastPaidWeek :one
 SELECT COALESCE(MAX(week_number), 0)::INT AS last_paid_week
 FROM payments
 WHERE loan_id = $1
+  AND tenant_id = $2
 `
 
-func (q *Queries) GetLastPaidWeek(ctx context.Context, loanID int64) (int32, error) {
-	row := q.db.QueryRow(ctx, getLastPaidWeek, loanID)
+type GetLastPaidWeekParams struct {
+	LoanID   int64
+	TenantID string
+}
+
+func (q *Queries) GetLastPaidWeek(ctx context.Context, arg GetLastPaidWeekParams) (int32, error) {
+	row := q.db.QueryRow(ctx, getLastPaidWeek, arg.LoanID, arg.TenantID)
 	var last_paid_week int32
 	err := row.Scan(&last_paid_week)
 	return last_paid_week, err
@@ -28,23 +34,84 @@ const getPaidWeeksCount = `-- name: GetPaidWeeksCount :one
 SELECT COUNT(*)::INT
 FROM payments
 WHERE loan_id = $1
+  AND tenant_id = $2
 `
 
-func (q *Queries) GetPaidWeeksCount(ctx context.Context, loanID int64) (int32, error) {
-	row := q.db.QueryRow(ctx, getPaidWeeksCount, loanID)
+type GetPaidWeeksCountParams struct {
+	LoanID   int64
+	TenantID string
+}
+
+func (q *Queries) GetPaidWeeksCount(ctx context.Context, arg GetPaidWeeksCountParams) (int32, error) {
+	row := q.db.QueryRow(ctx, getPaidWeeksCount, arg.LoanID, arg.TenantID)
 	var column_1 int32
 	err := row.Scan(&column_1)
 	return column_1, err
 }
 
+const getPaymentByIdempotencyKey = `-- name: GetPaymentByIdempotencyKey :one
+SELECT id, loan_id, week_number, amount, idempotency_key, paid_at, created_at, tenant_id
+FROM payments
+WHERE tenant_id = $1::text
+  AND idempotency_key = $2::text
+`
+
+type GetPaymentByIdempotencyKeyParams struct {
+	TenantID       string
+	IdempotencyKey string
+}
+
+func (q *Queries) GetPaymentByIdempotencyKey(ctx context.Context, arg GetPaymentByIdempotencyKeyParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPaymentByIdempotencyKey, arg.TenantID, arg.IdempotencyKey)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.LoanID,
+		&i.WeekNumber,
+		&i.Amount,
+		&i.IdempotencyKey,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
 const getTotalPaidAmount = `-- name: GetTotalPaidAmount :one
 SELECT COALESCE(SUM(amount), 0)::BIGINT AS total_paid
 FROM payments
 WHERE loan_id = $1
+  AND tenant_id = $2
+`
+
+type GetTotalPaidAmountParams struct {
+	LoanID   int64
+	TenantID string
+}
+
+func (q *Queries) GetTotalPaidAmount(ctx context.Context, arg GetTotalPaidAmountParams) (int64, error) {
+	row := q.db.QueryRow(ctx, getTotalPaidAmount, arg.LoanID, arg.TenantID)
+	var total_paid int64
+	err := row.Scan(&total_paid)
+	return total_paid, err
+}
+
+const getTotalPaidAmountBefore = `-- name: GetTotalPaidAmountBefore :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT AS total_paid
+FROM payments
+WHERE loan_id = $1::bigint
+  AND tenant_id = $2::text
+  AND paid_at < $3::timestamp
 `
 
-func (q *Queries) GetTotalPaidAmount(ctx context.Context, loanID int64) (int64, error) {
-	row := q.db.QueryRow(ctx, getTotalPaidAmount, loanID)
+type GetTotalPaidAmountBeforeParams struct {
+	LoanID   int64
+	TenantID string
+	Before   pgtype.Timestamp
+}
+
+func (q *Queries) GetTotalPaidAmountBefore(ctx context.Context, arg GetTotalPaidAmountBeforeParams) (int64, error) {
+	row := q.db.QueryRow(ctx, getTotalPaidAmountBefore, arg.LoanID, arg.TenantID, arg.Before)
 	var total_paid int64
 	err := row.Scan(&total_paid)
 	return total_paid, err
@@ -56,10 +123,11 @@ INSERT INTO payments (
     week_number,
     amount,
     idempotency_key,
-    paid_at
+    paid_at,
+    tenant_id
   )
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, loan_id, week_number, amount, idempotency_key, paid_at, created_at
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, loan_id, week_number, amount, idempotency_key, paid_at, created_at, tenant_id
 `
 
 type InsertPaymentParams struct {
@@ -68,6 +136,7 @@ type InsertPaymentParams struct {
 	Amount         int64
 	IdempotencyKey string
 	PaidAt         pgtype.Timestamp
+	TenantID       string
 }
 
 func (q *Queries) InsertPayment(ctx context.Context, arg InsertPaymentParams) (Payment, error) {
@@ -77,6 +146,7 @@ func (q *Queries) InsertPayment(ctx context.Context, arg InsertPaymentParams) (P
 		arg.Amount,
 		arg.IdempotencyKey,
 		arg.PaidAt,
+		arg.TenantID,
 	)
 	var i Payment
 	err := row.Scan(
@@ -87,6 +157,7 @@ func (q *Queries) InsertPayment(ctx context.Context, arg InsertPaymentParams) (P
 		&i.IdempotencyKey,
 		&i.PaidAt,
 		&i.CreatedAt,
+		&i.TenantID,
 	)
 	return i, err
 }
@@ -99,25 +170,27 @@ SELECT id,
   paid_at
 FROM payments
 WHERE loan_id = $1::bigint
+  AND tenant_id = $2::text
   AND (
     (
-      $2::timestamptz IS NULL
-      AND $3::bigint IS NULL
+      $3::timestamptz IS NULL
+      AND $4::bigint IS NULL
     )
     OR (
       (paid_at, id) > (
-        $2::timestamptz,
-        $3::bigint
+        $3::timestamptz,
+        $4::bigint
       )
     )
   )
 ORDER BY paid_at ASC,
   id ASC
-LIMIT $4::int
+LIMIT $5::int
 `
 
 type ListPaymentsByLoanIDParams struct {
 	LoanID       int64
+	TenantID     string
 	CursorPaidAt pgtype.Timestamptz
 	CursorID     pgtype.Int8
 	LimitVal     int32
@@ -134,6 +207,7 @@ type ListPaymentsByLoanIDRow struct {
 func (q *Queries) ListPaymentsByLoanID(ctx context.Context, arg ListPaymentsByLoanIDParams) ([]ListPaymentsByLoanIDRow, error) {
 	rows, err := q.db.Query(ctx, listPaymentsByLoanID,
 		arg.LoanID,
+		arg.TenantID,
 		arg.CursorPaidAt,
 		arg.CursorID,
 		arg.LimitVal,
@@ -161,3 +235,64 @@ func (q *Queries) ListPaymentsByLoanID(ctx context.Context, arg ListPaymentsByLo
 	}
 	return items, nil
 }
+
+const listPaymentsByLoanIDInPeriod = `-- name: ListPaymentsByLoanIDInPeriod :many
+SELECT id,
+  loan_id,
+  week_number,
+  amount,
+  paid_at
+FROM payments
+WHERE loan_id = $1::bigint
+  AND tenant_id = $2::text
+  AND paid_at >= $3::timestamp
+  AND paid_at < $4::timestamp
+ORDER BY paid_at ASC,
+  id ASC
+`
+
+type ListPaymentsByLoanIDInPeriodParams struct {
+	LoanID      int64
+	TenantID    string
+	PeriodStart pgtype.Timestamp
+	PeriodEnd   pgtype.Timestamp
+}
+
+type ListPaymentsByLoanIDInPeriodRow struct {
+	ID         int64
+	LoanID     int64
+	WeekNumber int32
+	Amount     int64
+	PaidAt     pgtype.Timestamp
+}
+
+func (q *Queries) ListPaymentsByLoanIDInPeriod(ctx context.Context, arg ListPaymentsByLoanIDInPeriodParams) ([]ListPaymentsByLoanIDInPeriodRow, error) {
+	rows, err := q.db.Query(ctx, listPaymentsByLoanIDInPeriod,
+		arg.LoanID,
+		arg.TenantID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPaymentsByLoanIDInPeriodRow
+	for rows.Next() {
+		var i ListPaymentsByLoanIDInPeriodRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LoanID,
+			&i.WeekNumber,
+			&i.Amount,
+			&i.PaidAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,84 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: rate_limits.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteIdleRateLimitBuckets = `-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at <= $1::timestamp
+`
+
+// buckets idle long enough to be full again are the same as missing ones
+func (q *Queries) DeleteIdleRateLimitBuckets(ctx context.Context, idleBefore pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteIdleRateLimitBuckets, idleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, allowed, updated_at)
+VALUES (
+    $1::text,
+    $2::float8 - 1,
+    true,
+    $3::timestamp
+  ) ON CONFLICT (bucket_key) DO
+UPDATE
+SET tokens = CASE
+    WHEN LEAST(
+      $2::float8,
+      b.tokens + GREATEST(date_part('epoch', $3::timestamp - b.updated_at), 0) * $4::float8
+    ) >= 1 THEN LEAST(
+      $2::float8,
+      b.tokens + GREATEST(date_part('epoch', $3::timestamp - b.updated_at), 0) * $4::float8
+    ) - 1
+    ELSE LEAST(
+      $2::float8,
+      b.tokens + GREATEST(date_part('epoch', $3::timestamp - b.updated_at), 0) * $4::float8
+    )
+  END,
+  allowed = LEAST(
+    $2::float8,
+    b.tokens + GREATEST(date_part('epoch', $3::timestamp - b.updated_at), 0) * $4::float8
+  ) >= 1,
+  -- the clocks of the instances may disagree, a bucket never goes back in time
+  updated_at = GREATEST(b.updated_at, $3::timestamp)
+RETURNING tokens,
+  allowed
+`
+
+type TakeRateLimitTokenParams struct {
+	BucketKey string
+	Burst     float64
+	Now       pgtype.Timestamp
+	Rate      float64
+}
+
+type TakeRateLimitTokenRow struct {
+	Tokens  float64
+	Allowed bool
+}
+
+// refills the bucket for the time elapsed since its last request, capped at the burst, and takes a token when a whole one is left
+// sqlc.arg rather than @, sqlc fails to rewrite the repeated @ parameters of this statement
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRow(ctx, takeRateLimitToken,
+		arg.BucketKey,
+		arg.Burst,
+		arg.Now,
+		arg.Rate,
+	)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
+}
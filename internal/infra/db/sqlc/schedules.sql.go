@@ -17,23 +17,26 @@ type CreateLoanSchedulesParams struct {
 	DueDate  pgtype.Date
 	Amount   int64
 	Status   string
+	TenantID string
 }
 
 const getScheduleBySequence = `-- name: GetScheduleBySequence :one
-SELECT id, loan_id, sequence, due_date, amount, paid_amount, status, created_at, updated_at
+SELECT id, loan_id, sequence, due_date, amount, paid_amount, status, created_at, updated_at, tenant_id
 FROM schedules
 WHERE loan_id = $1
-  AND sequence = $2
+  AND tenant_id = $2
+  AND sequence = $3
 LIMIT 1
 `
 
 type GetScheduleBySequenceParams struct {
 	LoanID   int64
+	TenantID string
 	Sequence int32
 }
 
 func (q *Queries) GetScheduleBySequence(ctx context.Context, arg GetScheduleBySequenceParams) (Schedule, error) {
-	row := q.db.QueryRow(ctx, getScheduleBySequence, arg.LoanID, arg.Sequence)
+	row := q.db.QueryRow(ctx, getScheduleBySequence, arg.LoanID, arg.TenantID, arg.Sequence)
 	var i Schedule
 	err := row.Scan(
 		&i.ID,
@@ -45,27 +48,35 @@ func (q *Queries) GetScheduleBySequence(ctx context.Context, arg GetScheduleBySe
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TenantID,
 	)
 	return i, err
 }
 
 const listSchedulesByLoanIDWithCursor = `-- name: ListSchedulesByLoanIDWithCursor :many
-SELECT id, loan_id, sequence, due_date, amount, paid_amount, status, created_at, updated_at
+SELECT id, loan_id, sequence, due_date, amount, paid_amount, status, created_at, updated_at, tenant_id
 FROM schedules
 WHERE loan_id = $1
-  AND sequence > $2
+  AND tenant_id = $2
+  AND sequence > $3
 ORDER BY sequence
-LIMIT $3
+LIMIT $4
 `
 
 type ListSchedulesByLoanIDWithCursorParams struct {
 	LoanID   int64
+	TenantID string
 	Sequence int32
 	Limit    int32
 }
 
 func (q *Queries) ListSchedulesByLoanIDWithCursor(ctx context.Context, arg ListSchedulesByLoanIDWithCursorParams) ([]Schedule, error) {
-	rows, err := q.db.Query(ctx, listSchedulesByLoanIDWithCursor, arg.LoanID, arg.Sequence, arg.Limit)
+	rows, err := q.db.Query(ctx, listSchedulesByLoanIDWithCursor,
+		arg.LoanID,
+		arg.TenantID,
+		arg.Sequence,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +94,76 @@ func (q *Queries) ListSchedulesByLoanIDWithCursor(ctx context.Context, arg ListS
 			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUpcomingSchedules = `-- name: ListUpcomingSchedules :many
+SELECT s.id,
+  s.loan_id,
+  s.sequence,
+  s.due_date,
+  s.amount,
+  COALESCE(SUM(p.amount), 0)::BIGINT AS paid_amount
+FROM schedules s
+  LEFT JOIN payments p ON p.loan_id = s.loan_id
+  AND p.week_number = s.sequence
+  AND p.paid_at < $1::timestamp
+WHERE s.loan_id = $2::bigint
+  AND s.tenant_id = $3::text
+  AND s.due_date >= $1::date
+GROUP BY s.id
+HAVING COALESCE(SUM(p.amount), 0) < s.amount
+ORDER BY s.sequence
+LIMIT $4
+`
+
+type ListUpcomingSchedulesParams struct {
+	DueFrom  pgtype.Timestamp
+	LoanID   int64
+	TenantID string
+	MaxRows  int32
+}
+
+type ListUpcomingSchedulesRow struct {
+	ID         int64
+	LoanID     int64
+	Sequence   int32
+	DueDate    pgtype.Date
+	Amount     int64
+	PaidAmount int64
+}
+
+// the schedules due on or after due_from, with what was paid on them before it
+func (q *Queries) ListUpcomingSchedules(ctx context.Context, arg ListUpcomingSchedulesParams) ([]ListUpcomingSchedulesRow, error) {
+	rows, err := q.db.Query(ctx, listUpcomingSchedules,
+		arg.DueFrom,
+		arg.LoanID,
+		arg.TenantID,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUpcomingSchedulesRow
+	for rows.Next() {
+		var i ListUpcomingSchedulesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LoanID,
+			&i.Sequence,
+			&i.DueDate,
+			&i.Amount,
+			&i.PaidAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -103,18 +184,25 @@ SET paid_amount = paid_amount + $1,
   END,
   updated_at = now()
 WHERE loan_id = $2
-  AND sequence = $3
+  AND tenant_id = $3
+  AND sequence = $4
 RETURNING id
 `
 
 type UpdateSchedulePaymentParams struct {
 	PaidAmount int64
 	LoanID     int64
+	TenantID   string
 	Sequence   int32
 }
 
 func (q *Queries) UpdateSchedulePayment(ctx context.Context, arg UpdateSchedulePaymentParams) (int64, error) {
-	row := q.db.QueryRow(ctx, updateSchedulePayment, arg.PaidAmount, arg.LoanID, arg.Sequence)
+	row := q.db.QueryRow(ctx, updateSchedulePayment,
+		arg.PaidAmount,
+		arg.LoanID,
+		arg.TenantID,
+		arg.Sequence,
+	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
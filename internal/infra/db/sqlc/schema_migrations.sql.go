@@ -0,0 +1,133 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: schema_migrations.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteSchemaMigration = `-- name: DeleteSchemaMigration :exec
+DELETE FROM schema_migrations
+WHERE version = $1
+`
+
+func (q *Queries) DeleteSchemaMigration(ctx context.Context, version int32) error {
+	_, err := q.db.Exec(ctx, deleteSchemaMigration, version)
+	return err
+}
+
+const getSchemaVersion = `-- name: GetSchemaVersion :one
+SELECT COALESCE(MAX(version), 0)::int AS version
+FROM schema_migrations
+`
+
+// the latest migration applied, 0 when none is recorded
+func (q *Queries) GetSchemaVersion(ctx context.Context) (int32, error) {
+	row := q.db.QueryRow(ctx, getSchemaVersion)
+	var version int32
+	err := row.Scan(&version)
+	return version, err
+}
+
+const insertSchemaMigration = `-- name: InsertSchemaMigration :exec
+INSERT INTO schema_migrations (version, name, checksum)
+VALUES ($1, $2, $3)
+`
+
+type InsertSchemaMigrationParams struct {
+	Version  int32
+	Name     string
+	Checksum pgtype.Text
+}
+
+func (q *Queries) InsertSchemaMigration(ctx context.Context, arg InsertSchemaMigrationParams) error {
+	_, err := q.db.Exec(ctx, insertSchemaMigration, arg.Version, arg.Name, arg.Checksum)
+	return err
+}
+
+const listSchemaMigrations = `-- name: ListSchemaMigrations :many
+SELECT version,
+  name,
+  checksum,
+  applied_at
+FROM schema_migrations
+ORDER BY version
+`
+
+func (q *Queries) ListSchemaMigrations(ctx context.Context) ([]SchemaMigration, error) {
+	rows, err := q.db.Query(ctx, listSchemaMigrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SchemaMigration
+	for rows.Next() {
+		var i SchemaMigration
+		if err := rows.Scan(
+			&i.Version,
+			&i.Name,
+			&i.Checksum,
+			&i.AppliedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockSchemaMigrations = `-- name: LockSchemaMigrations :exec
+SELECT pg_advisory_lock(hashtext('schema_migrations'))
+`
+
+func (q *Queries) LockSchemaMigrations(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockSchemaMigrations)
+	return err
+}
+
+const setSchemaMigrationChecksum = `-- name: SetSchemaMigrationChecksum :exec
+UPDATE schema_migrations
+SET checksum = $1
+WHERE version = $2
+  AND checksum IS NULL
+`
+
+type SetSchemaMigrationChecksumParams struct {
+	Checksum pgtype.Text
+	Version  int32
+}
+
+// adopts a migration recorded before the migrator existed, for migrate baseline
+func (q *Queries) SetSchemaMigrationChecksum(ctx context.Context, arg SetSchemaMigrationChecksumParams) error {
+	_, err := q.db.Exec(ctx, setSchemaMigrationChecksum, arg.Checksum, arg.Version)
+	return err
+}
+
+const tryLockSchemaMigrations = `-- name: TryLockSchemaMigrations :one
+SELECT pg_try_advisory_lock(hashtext('schema_migrations'))
+`
+
+// session lock, held by the migrator across the transactions of the migrations
+func (q *Queries) TryLockSchemaMigrations(ctx context.Context) (bool, error) {
+	row := q.db.QueryRow(ctx, tryLockSchemaMigrations)
+	var pg_try_advisory_lock bool
+	err := row.Scan(&pg_try_advisory_lock)
+	return pg_try_advisory_lock, err
+}
+
+const unlockSchemaMigrations = `-- name: UnlockSchemaMigrations :exec
+SELECT pg_advisory_unlock(hashtext('schema_migrations'))
+`
+
+func (q *Queries) UnlockSchemaMigrations(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, unlockSchemaMigrations)
+	return err
+}
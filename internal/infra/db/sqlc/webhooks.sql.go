@@ -0,0 +1,567 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package sqlc
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+  UPDATE webhook_deliveries
+  SET next_attempt_at = $1::timestamp
+  WHERE id IN (
+      SELECT d.id
+      FROM webhook_deliveries d
+        JOIN webhook_subscriptions s ON s.id = d.subscription_id
+        AND s.status = 'ACTIVE'
+      WHERE d.status = 'PENDING'
+        AND d.next_attempt_at <= $2::timestamp
+      ORDER BY d.next_attempt_at,
+        d.id
+      LIMIT $3::int FOR
+      UPDATE OF d SKIP LOCKED
+    )
+  RETURNING id,
+    subscription_id,
+    event_id,
+    attempts
+)
+SELECT c.id,
+  c.subscription_id,
+  c.attempts,
+  s.url,
+  s.secret,
+  e.id AS event_id,
+  e.tenant_id,
+  e.aggregate_type,
+  e.aggregate_id,
+  e.event_type,
+  e.payload,
+  e.created_at AS occurred_at
+FROM claimed c
+  JOIN webhook_subscriptions s ON s.id = c.subscription_id
+  JOIN outbox_events e ON e.id = c.event_id
+ORDER BY c.id
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil pgtype.Timestamp
+	Now        pgtype.Timestamp
+	LimitVal   int32
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID             int64
+	SubscriptionID int64
+	Attempts       int32
+	Url            string
+	Secret         string
+	EventID        int64
+	TenantID       string
+	AggregateType  string
+	AggregateID    int64
+	EventType      string
+	Payload        []byte
+	OccurredAt     pgtype.Timestamp
+}
+
+// leases the due deliveries until lease_until like ClaimOutboxEvents, several dispatchers can claim side by side
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.LimitVal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+			&i.EventID,
+			&i.TenantID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.OccurredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :one
+UPDATE webhook_subscriptions
+SET status = 'DELETED',
+  deleted_at = now()
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'ACTIVE'
+RETURNING id, url, event_types, secret, status, created_at, deleted_at, tenant_id
+`
+
+type DeleteWebhookSubscriptionParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, deleteWebhookSubscription, arg.ID, arg.TenantID)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.EventTypes,
+		&i.Secret,
+		&i.Status,
+		&i.CreatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, tenant_id)
+SELECT s.id,
+  $1::bigint,
+  $2::text,
+  s.tenant_id
+FROM webhook_subscriptions s
+WHERE s.tenant_id = $3::text
+  AND s.status = 'ACTIVE'
+  AND $2::text = ANY(s.event_types) ON CONFLICT (subscription_id, event_id) DO NOTHING
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	EventID   int64
+	EventType string
+	TenantID  string
+}
+
+// fan an outbox event out to every active subscription of its tenant listening to its type
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueWebhookDeliveries, arg.EventID, arg.EventType, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getWebhookDeliveryByID = `-- name: GetWebhookDeliveryByID :one
+SELECT id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, created_at, delivered_at, tenant_id
+FROM webhook_deliveries
+WHERE id = $1
+  AND tenant_id = $2
+`
+
+type GetWebhookDeliveryByIDParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) GetWebhookDeliveryByID(ctx context.Context, arg GetWebhookDeliveryByIDParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDeliveryByID, arg.ID, arg.TenantID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.SubscriptionID,
+		&i.EventID,
+		&i.EventType,
+		&i.Status,
+		&i.Attempts,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const getWebhookSubscriptionByID = `-- name: GetWebhookSubscriptionByID :one
+SELECT id, url, event_types, secret, status, created_at, deleted_at, tenant_id
+FROM webhook_subscriptions
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'ACTIVE'
+`
+
+type GetWebhookSubscriptionByIDParams struct {
+	ID       int64
+	TenantID string
+}
+
+func (q *Queries) GetWebhookSubscriptionByID(ctx context.Context, arg GetWebhookSubscriptionByIDParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, getWebhookSubscriptionByID, arg.ID, arg.TenantID)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.EventTypes,
+		&i.Secret,
+		&i.Status,
+		&i.CreatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const insertWebhookDeliveryAttempt = `-- name: InsertWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (
+    delivery_id,
+    attempt,
+    status_code,
+    error,
+    duration_ms
+  )
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertWebhookDeliveryAttemptParams struct {
+	DeliveryID int64
+	Attempt    int32
+	StatusCode pgtype.Int4
+	Error      pgtype.Text
+	DurationMs int32
+}
+
+func (q *Queries) InsertWebhookDeliveryAttempt(ctx context.Context, arg InsertWebhookDeliveryAttemptParams) error {
+	_, err := q.db.Exec(ctx, insertWebhookDeliveryAttempt,
+		arg.DeliveryID,
+		arg.Attempt,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const insertWebhookSubscription = `-- name: InsertWebhookSubscription :one
+INSERT INTO webhook_subscriptions (url, event_types, secret, status, tenant_id)
+VALUES ($1, $2, $3, 'ACTIVE', $4)
+RETURNING id, url, event_types, secret, status, created_at, deleted_at, tenant_id
+`
+
+type InsertWebhookSubscriptionParams struct {
+	Url        string
+	EventTypes []string
+	Secret     string
+	TenantID   string
+}
+
+func (q *Queries) InsertWebhookSubscription(ctx context.Context, arg InsertWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, insertWebhookSubscription,
+		arg.Url,
+		arg.EventTypes,
+		arg.Secret,
+		arg.TenantID,
+	)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.EventTypes,
+		&i.Secret,
+		&i.Status,
+		&i.CreatedAt,
+		&i.DeletedAt,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const listDeadWebhookDeliveries = `-- name: ListDeadWebhookDeliveries :many
+SELECT id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, created_at, delivered_at, tenant_id
+FROM webhook_deliveries
+WHERE tenant_id = $1::text
+  AND status = 'DEAD'
+  AND (
+    $2::bigint IS NULL
+    OR id < $2::bigint
+  )
+ORDER BY id DESC
+LIMIT $3::int
+`
+
+type ListDeadWebhookDeliveriesParams struct {
+	TenantID string
+	CursorID pgtype.Int8
+	LimitVal int32
+}
+
+func (q *Queries) ListDeadWebhookDeliveries(ctx context.Context, arg ListDeadWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listDeadWebhookDeliveries, arg.TenantID, arg.CursorID, arg.LimitVal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Attempts,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveriesBySubscriptionID = `-- name: ListWebhookDeliveriesBySubscriptionID :many
+SELECT id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, created_at, delivered_at, tenant_id
+FROM webhook_deliveries
+WHERE subscription_id = $1::bigint
+  AND tenant_id = $2::text
+  AND (
+    $3::text IS NULL
+    OR status = $3::text
+  )
+  AND (
+    $4::bigint IS NULL
+    OR id < $4::bigint
+  )
+ORDER BY id DESC
+LIMIT $5::int
+`
+
+type ListWebhookDeliveriesBySubscriptionIDParams struct {
+	SubscriptionID int64
+	TenantID       string
+	Status         pgtype.Text
+	CursorID       pgtype.Int8
+	LimitVal       int32
+}
+
+// newest first, optionally filtered by status
+func (q *Queries) ListWebhookDeliveriesBySubscriptionID(ctx context.Context, arg ListWebhookDeliveriesBySubscriptionIDParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveriesBySubscriptionID,
+		arg.SubscriptionID,
+		arg.TenantID,
+		arg.Status,
+		arg.CursorID,
+		arg.LimitVal,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Status,
+			&i.Attempts,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+			&i.DeliveredAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveryAttempts = `-- name: ListWebhookDeliveryAttempts :many
+SELECT id, delivery_id, attempt, status_code, error, duration_ms, attempted_at
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID int64) ([]WebhookDeliveryAttempt, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveryAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.Attempt,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+			&i.AttemptedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, url, event_types, secret, status, created_at, deleted_at, tenant_id
+FROM webhook_subscriptions
+WHERE tenant_id = $1
+  AND status = 'ACTIVE'
+ORDER BY id
+`
+
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context, tenantID string) ([]WebhookSubscription, error) {
+	rows, err := q.db.Query(ctx, listWebhookSubscriptions, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookSubscription
+	for rows.Next() {
+		var i WebhookSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.EventTypes,
+			&i.Secret,
+			&i.Status,
+			&i.CreatedAt,
+			&i.DeletedAt,
+			&i.TenantID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDeliveryFailed = `-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $1::text,
+  attempts = attempts + 1,
+  last_status_code = $2::int,
+  last_error = $3::text,
+  next_attempt_at = $4::timestamp
+WHERE id = $5::bigint
+  AND status = 'PENDING'
+`
+
+type MarkWebhookDeliveryFailedParams struct {
+	Status        string
+	StatusCode    pgtype.Int4
+	LastError     string
+	NextAttemptAt pgtype.Timestamp
+	ID            int64
+}
+
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDeliveryFailed,
+		arg.Status,
+		arg.StatusCode,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.ID,
+	)
+	return err
+}
+
+const markWebhookDeliverySucceeded = `-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'DELIVERED',
+  attempts = attempts + 1,
+  last_status_code = $1::int,
+  last_error = NULL,
+  delivered_at = now()
+WHERE id = $2::bigint
+  AND status = 'PENDING'
+`
+
+type MarkWebhookDeliverySucceededParams struct {
+	StatusCode int32
+	ID         int64
+}
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDeliverySucceeded, arg.StatusCode, arg.ID)
+	return err
+}
+
+const redeliverWebhookDelivery = `-- name: RedeliverWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'PENDING',
+  attempts = 0,
+  last_error = NULL,
+  next_attempt_at = now()
+WHERE id = $1
+  AND tenant_id = $2
+  AND status = 'DEAD'
+RETURNING id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, created_at, delivered_at, tenant_id
+`
+
+type RedeliverWebhookDeliveryParams struct {
+	ID       int64
+	TenantID string
+}
+
+// a dead delivery gets a fresh retry budget, its attempt log is kept
+func (q *Queries) RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, redeliverWebhookDelivery, arg.ID, arg.TenantID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.SubscriptionID,
+		&i.EventID,
+		&i.EventType,
+		&i.Status,
+		&i.Attempts,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+		&i.DeliveredAt,
+		&i.TenantID,
+	)
+	return i, err
+}
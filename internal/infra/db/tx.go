@@ -1,18 +1,118 @@
 package db
 
 import (
+	"billing-api/internal/domain"
+	"billing-api/internal/tracing"
 	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultTxRetryPolicy is used by transactions without their own policy, it is meant to be set once at startup
+var DefaultTxRetryPolicy domain.TxRetryPolicy = ExponentialTxRetry{
+	MaxAttempts: 3,
+	BaseBackoff: 20 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
 /*
 withTx reusable wrapper function to support atomic transactional
+
+It runs with the server default options, see WithTxOptions.
 */
 func WithTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	return WithTxOptions(ctx, pool, domain.TxOptions{}, fn)
+}
+
+/*
+WithTxOptions runs fn in a transaction with the given isolation level and access mode.
+
+A transaction that fails with a transient error is rolled back and fn runs again in a new transaction
+as long as the retry policy allows it, so fn must not keep side effects outside the transaction between attempts.
+Transient errors are a serialization failure (40001), a deadlock (40P01) and a lost connection, the latter
+only when it is certain the commit did not reach the server.
+*/
+func WithTxOptions(ctx context.Context, pool *pgxpool.Pool, opts domain.TxOptions, fn func(tx pgx.Tx) error) error {
+	return withTxOptions(ctx, pool, opts, fn)
+}
+
+// txBeginner is the part of pgxpool.Pool the transactions use, the tests begin fake transactions
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+func withTxOptions(ctx context.Context, db txBeginner, opts domain.TxOptions, fn func(tx pgx.Tx) error) (err error) {
+	policy := opts.Retry
+	if policy == nil {
+		policy = DefaultTxRetryPolicy
+	}
+	txOptions := toPgxTxOptions(opts)
+
+	// every attempt is in the same span, the retries are events of it
+	ctx, span := tracing.Start(ctx, "db transaction")
+	defer func() { tracing.End(span, err) }()
+
+	for attempt := 1; ; attempt++ {
+		err = runTx(ctx, db, txOptions, fn)
+		if err == nil {
+			return nil
+		}
+
+		reason, transient := transientTxErrorReason(err)
+		if !transient || ctx.Err() != nil {
+			return err
+		}
+		backoff, retry := policy.Backoff(attempt, err)
+		if !retry {
+			txRetryCounters.exhausted.Add(1)
+			return err
+		}
+
+		txRetryCounters.add(reason)
+		span.AddEvent("tx_retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("reason", reason),
+		))
+		slog.WarnContext(ctx, "tx_retry",
+			slog.Int("attempt", attempt),
+			slog.String("reason", reason),
+			slog.Duration("backoff", backoff),
+			slog.Any("err", err),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
 
-	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+// commitError marks a failure of the COMMIT itself, after which the outcome of the transaction is unknown
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string { return e.err.Error() }
+func (e *commitError) Unwrap() error { return e.err }
+
+// connectionLostError marks a failure of fn on a connection the driver closed, the transaction died with it
+type connectionLostError struct {
+	err error
+}
+
+func (e *connectionLostError) Error() string { return e.err.Error() }
+func (e *connectionLostError) Unwrap() error { return e.err }
+
+func runTx(ctx context.Context, db txBeginner, opts pgx.TxOptions, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -29,13 +129,95 @@ func WithTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) e
 
 	err = fn(tx)
 	if err != nil {
+		// pgx closes the connection on a network failure, an io.EOF or net.OpError of fn's own is not one
+		lost := connClosed(tx)
 		_ = tx.Rollback(ctx)
+		if lost {
+			return &connectionLostError{err: err}
+		}
 		return err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return err
+	if err = tx.Commit(ctx); err != nil {
+		return &commitError{err: err}
 	}
-
 	return nil
 }
+
+func connClosed(tx pgx.Tx) bool {
+	conn := tx.Conn()
+	return conn != nil && conn.IsClosed()
+}
+
+func toPgxTxOptions(opts domain.TxOptions) pgx.TxOptions {
+	txOptions := pgx.TxOptions{IsoLevel: pgx.TxIsoLevel(opts.Isolation)}
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+	if opts.Deferrable {
+		txOptions.DeferrableMode = pgx.Deferrable
+	}
+	return txOptions
+}
+
+/*
+transientTxErrorReason reports whether the transaction failed in a way that succeeds when simply run again.
+
+Postgres rolls back a transaction aborted by a serialization failure or a deadlock, even at commit, so those are
+always safe. A lost connection is safe before commit, during the commit only when nothing was sent to the server.
+Only the driver tells the connection is lost, by closing it or with an error it knows is safe to retry.
+*/
+func transientTxErrorReason(err error) (string, bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "", false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001":
+			return "serialization_failure", true
+		case "40P01":
+			return "deadlock", true
+		}
+		return "", false
+	}
+
+	var commitErr *commitError
+	if errors.As(err, &commitErr) {
+		return "connection", pgconn.SafeToRetry(err)
+	}
+	var lostErr *connectionLostError
+	if errors.As(err, &lostErr) {
+		return "connection", true
+	}
+	return "connection", pgconn.SafeToRetry(err)
+}
+
+// ExponentialTxRetry retries up to MaxAttempts attempts in total, waiting BaseBackoff doubled per attempt plus jitter
+type ExponentialTxRetry struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func (p ExponentialTxRetry) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	backoff := p.BaseBackoff << (attempt - 1)
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, p.MaxBackoff)
+	}
+	if backoff > 0 {
+		backoff += rand.N(backoff)
+	}
+	return backoff, true
+}
+
+// NoTxRetry returns transient errors to the caller right away
+type NoTxRetry struct{}
+
+func (NoTxRetry) Backoff(attempt int, err error) (time.Duration, bool) {
+	return 0, false
+}
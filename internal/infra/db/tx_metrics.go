@@ -0,0 +1,70 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TxRetryStats counts transaction retries since startup, by the transient error that caused them
+type TxRetryStats struct {
+	SerializationFailures int64 `json:"serialization_failures"`
+	Deadlocks             int64 `json:"deadlocks"`
+	ConnectionErrors      int64 `json:"connection_errors"`
+	Exhausted             int64 `json:"exhausted"` // transient failures returned because the policy gave up
+}
+
+type txRetryCounter struct {
+	serializationFailures atomic.Int64
+	deadlocks             atomic.Int64
+	connectionErrors      atomic.Int64
+	exhausted             atomic.Int64
+}
+
+var txRetryCounters txRetryCounter
+
+func (c *txRetryCounter) add(reason string) {
+	switch reason {
+	case "serialization_failure":
+		c.serializationFailures.Add(1)
+	case "deadlock":
+		c.deadlocks.Add(1)
+	case "connection":
+		c.connectionErrors.Add(1)
+	}
+}
+
+// TxRetryStatsSnapshot returns the retry counters of all transactions run through WithTx
+func TxRetryStatsSnapshot() TxRetryStats {
+	return TxRetryStats{
+		SerializationFailures: txRetryCounters.serializationFailures.Load(),
+		Deadlocks:             txRetryCounters.deadlocks.Load(),
+		ConnectionErrors:      txRetryCounters.connectionErrors.Load(),
+		Exhausted:             txRetryCounters.exhausted.Load(),
+	}
+}
+
+var (
+	txRetriesDesc   = prometheus.NewDesc("billing_db_tx_retries_total", "Transactions run again after a transient failure, by reason.", []string{"reason"}, nil)
+	txExhaustedDesc = prometheus.NewDesc("billing_db_tx_retries_exhausted_total", "Transient failures returned because the retry policy gave up.", nil, nil)
+)
+
+// txRetryCollector exposes TxRetryStatsSnapshot
+type txRetryCollector struct{}
+
+func NewTxRetryCollector() prometheus.Collector {
+	return txRetryCollector{}
+}
+
+func (txRetryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- txRetriesDesc
+	ch <- txExhaustedDesc
+}
+
+func (txRetryCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := TxRetryStatsSnapshot()
+	ch <- prometheus.MustNewConstMetric(txRetriesDesc, prometheus.CounterValue, float64(stats.SerializationFailures), "serialization_failure")
+	ch <- prometheus.MustNewConstMetric(txRetriesDesc, prometheus.CounterValue, float64(stats.Deadlocks), "deadlock")
+	ch <- prometheus.MustNewConstMetric(txRetriesDesc, prometheus.CounterValue, float64(stats.ConnectionErrors), "connection")
+	ch <- prometheus.MustNewConstMetric(txExhaustedDesc, prometheus.CounterValue, float64(stats.Exhausted))
+}
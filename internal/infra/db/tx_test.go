@@ -0,0 +1,285 @@
+package db
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx is a transaction that commits with commitErr, the other pgx.Tx methods are not used by runTx
+type fakeTx struct {
+	pgx.Tx
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = tx.commitErr == nil
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func (tx *fakeTx) Conn() *pgx.Conn {
+	return nil
+}
+
+// fakeBeginner begins fake transactions, the i-th one commits with commitErrs[i] when given
+type fakeBeginner struct {
+	begins     int
+	beginErr   error
+	commitErrs []error
+	txs        []*fakeTx
+}
+
+func (b *fakeBeginner) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	b.begins++
+	if b.beginErr != nil {
+		return nil, b.beginErr
+	}
+	tx := &fakeTx{}
+	if i := len(b.txs); i < len(b.commitErrs) {
+		tx.commitErr = b.commitErrs[i]
+	}
+	b.txs = append(b.txs, tx)
+	return tx, nil
+}
+
+// safeToRetryError is how pgconn reports a failure that happened before anything was sent to the server
+type safeToRetryError struct {
+	safe bool
+}
+
+func (e safeToRetryError) Error() string     { return "write failed" }
+func (e safeToRetryError) SafeToRetry() bool { return e.safe }
+
+func pgError(code string) error {
+	return &pgconn.PgError{Code: code, Message: "tx failed"}
+}
+
+// noWaitRetry retries up to MaxAttempts attempts without waiting
+type noWaitRetry struct {
+	MaxAttempts int
+}
+
+func (p noWaitRetry) Backoff(attempt int, err error) (time.Duration, bool) {
+	return 0, attempt < p.MaxAttempts
+}
+
+func TestTransientTxErrorReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		reason    string
+		transient bool
+	}{
+		{"serialization failure", pgError("40001"), "serialization_failure", true},
+		{"deadlock", fmt.Errorf("InsertPayment: %w", pgError("40P01")), "deadlock", true},
+		{"unique violation", pgError("23505"), "", false},
+		{"serialization failure at commit", &commitError{err: pgError("40001")}, "serialization_failure", true},
+		{"commit not sent", &commitError{err: safeToRetryError{safe: true}}, "connection", true},
+		{"commit sent, outcome unknown", &commitError{err: safeToRetryError{safe: false}}, "connection", false},
+		{"connection closed by the driver", &connectionLostError{err: io.ErrUnexpectedEOF}, "connection", true},
+		{"driver error before sending", safeToRetryError{safe: true}, "connection", true},
+		{"canceled", &connectionLostError{err: context.Canceled}, "", false},
+		{"deadline", fmt.Errorf("GetLoan: %w", context.DeadlineExceeded), "", false},
+		// the errors of fn's own I/O, eg a call to a partner, do not mean the database connection is lost
+		{"io.EOF of fn", fmt.Errorf("reading the bank file: %w", io.EOF), "connection", false},
+		{"net.OpError of fn", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, "connection", false},
+		{"connection reset of fn", fmt.Errorf("webhook: %w", syscall.ECONNRESET), "connection", false},
+		{"domain error", domain.ErrLoanNotFound, "connection", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, transient := transientTxErrorReason(tt.err)
+			assert.Equal(t, tt.transient, transient)
+			if tt.transient {
+				assert.Equal(t, tt.reason, reason)
+			}
+		})
+	}
+}
+
+func TestWithTxOptions(t *testing.T) {
+	ctx := context.Background()
+	retry := domain.TxOptions{Retry: noWaitRetry{MaxAttempts: 3}}
+
+	t.Run("commits on the first attempt", func(t *testing.T) {
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, runs)
+		require.Len(t, db.txs, 1)
+		assert.True(t, db.txs[0].committed)
+	})
+
+	t.Run("runs fn again after a serialization failure", func(t *testing.T) {
+		before := TxRetryStatsSnapshot()
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			if runs == 1 {
+				return pgError("40001")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, runs)
+		require.Len(t, db.txs, 2)
+		assert.True(t, db.txs[0].rolledBack)
+		assert.True(t, db.txs[1].committed)
+		assert.Equal(t, before.SerializationFailures+1, TxRetryStatsSnapshot().SerializationFailures)
+	})
+
+	t.Run("runs fn again after a deadlock at commit", func(t *testing.T) {
+		before := TxRetryStatsSnapshot()
+		db := &fakeBeginner{commitErrs: []error{pgError("40P01")}}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, runs)
+		assert.Equal(t, before.Deadlocks+1, TxRetryStatsSnapshot().Deadlocks)
+	})
+
+	t.Run("commit that may have reached the server is not retried", func(t *testing.T) {
+		commitErr := safeToRetryError{safe: false}
+		db := &fakeBeginner{commitErrs: []error{commitErr}}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return nil
+		})
+		assert.ErrorIs(t, err, commitErr)
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("commit that was not sent is retried", func(t *testing.T) {
+		before := TxRetryStatsSnapshot()
+		db := &fakeBeginner{commitErrs: []error{safeToRetryError{safe: true}}}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, runs)
+		assert.Equal(t, before.ConnectionErrors+1, TxRetryStatsSnapshot().ConnectionErrors)
+	})
+
+	t.Run("error of fn's own I/O is returned as is", func(t *testing.T) {
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return io.EOF
+		})
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, 1, runs)
+		assert.True(t, db.txs[0].rolledBack)
+	})
+
+	t.Run("begin failure before sending is retried", func(t *testing.T) {
+		db := &fakeBeginner{beginErr: safeToRetryError{safe: true}}
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, db.beginErr)
+		assert.Equal(t, 3, db.begins)
+	})
+
+	t.Run("policy exhaustion returns the last error", func(t *testing.T) {
+		before := TxRetryStatsSnapshot()
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			return pgError("40001")
+		})
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(err, &pgErr))
+		assert.Equal(t, "40001", pgErr.Code)
+		assert.Equal(t, 3, runs)
+
+		after := TxRetryStatsSnapshot()
+		assert.Equal(t, before.SerializationFailures+2, after.SerializationFailures)
+		assert.Equal(t, before.Exhausted+1, after.Exhausted)
+	})
+
+	t.Run("no retry policy", func(t *testing.T) {
+		before := TxRetryStatsSnapshot()
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, domain.TxOptions{Retry: NoTxRetry{}}, func(tx pgx.Tx) error {
+			runs++
+			return pgError("40P01")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, runs)
+		assert.Equal(t, before.Exhausted+1, TxRetryStatsSnapshot().Exhausted)
+	})
+
+	t.Run("canceled context stops the retries", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		db := &fakeBeginner{}
+		runs := 0
+		err := withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+			runs++
+			cancel()
+			return pgError("40001")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("panic in fn rolls back", func(t *testing.T) {
+		db := &fakeBeginner{}
+		assert.Panics(t, func() {
+			_ = withTxOptions(ctx, db, retry, func(tx pgx.Tx) error {
+				panic("boom")
+			})
+		})
+		assert.True(t, db.txs[0].rolledBack)
+	})
+}
+
+func TestExponentialTxRetry(t *testing.T) {
+	policy := ExponentialTxRetry{MaxAttempts: 4, BaseBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	// the backoff doubles per attempt up to MaxBackoff, the jitter adds less than the backoff itself
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond} {
+		backoff, retry := policy.Backoff(attempt, nil)
+		assert.True(t, retry, "attempt %d", attempt)
+		assert.GreaterOrEqual(t, backoff, base, "attempt %d", attempt)
+		assert.Less(t, backoff, 2*base, "attempt %d", attempt)
+	}
+
+	_, retry := policy.Backoff(4, nil)
+	assert.False(t, retry, "MaxAttempts attempts in total")
+
+	backoff, retry := ExponentialTxRetry{MaxAttempts: 2}.Backoff(1, nil)
+	assert.True(t, retry)
+	assert.Zero(t, backoff)
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"slices"
+	"time"
+)
+
+type APIKeyRepo struct {
+	store *Store
+	tx    *txState
+}
+
+func NewAPIKeyRepo(store *Store) *APIKeyRepo {
+	return &APIKeyRepo{store: store}
+}
+
+func (r *APIKeyRepo) WithTx(ctx context.Context, fn func(repo domain.APIKeyRepository) error) error {
+	return r.store.withTx(func(tx *txState) error {
+		return fn(&APIKeyRepo{store: r.store, tx: tx})
+	})
+}
+
+// apiKeyIndex must be called with the store lock held, -1 when there is no such key
+func (r *APIKeyRepo) apiKeyIndex(match func(k domain.APIKey) bool) int {
+	return slices.IndexFunc(r.store.apiKeys, match)
+}
+
+// updateAPIKey applies fn to the key at index i and registers the undo, must be called with the store lock held
+func (r *APIKeyRepo) updateAPIKey(i int, fn func(k *domain.APIKey)) domain.APIKey {
+	previous := r.store.apiKeys[i]
+	fn(&r.store.apiKeys[i])
+	r.tx.onRollback(func() {
+		if j := r.apiKeyIndex(func(k domain.APIKey) bool { return k.ID == previous.ID }); j >= 0 {
+			r.store.apiKeys[j] = previous
+		}
+	})
+	return r.store.apiKeys[i]
+}
+
+func (r *APIKeyRepo) InsertAPIKey(ctx context.Context, arg domain.CreateAPIKeyCommand) (*domain.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := domain.APIKey{
+		ID:          r.store.nextID(),
+		Name:        arg.Name,
+		Prefix:      arg.Prefix,
+		Hash:        arg.Hash,
+		Scopes:      slices.Clone(arg.Scopes),
+		Roles:       slices.Clone(arg.Roles),
+		TenantID:    arg.TenantID,
+		ExpiresAt:   arg.ExpiresAt,
+		RotatedFrom: arg.RotatedFrom,
+		CreatedAt:   arg.CreatedAt,
+	}
+	r.store.apiKeys = append(r.store.apiKeys, key)
+	r.tx.onRollback(func() {
+		r.store.apiKeys = slices.DeleteFunc(r.store.apiKeys, func(k domain.APIKey) bool { return k.ID == key.ID })
+	})
+	return &key, nil
+}
+
+func (r *APIKeyRepo) GetAPIKeyByID(ctx context.Context, id int64) (*domain.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.apiKeyIndex(func(k domain.APIKey) bool { return k.ID == id && k.TenantID == domain.TenantFromContext(ctx) })
+	if i < 0 {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	key := r.store.apiKeys[i]
+	return &key, nil
+}
+
+// GetAPIKeyByPrefix looks in every tenant, the tenant of the caller is only known once its key is found
+func (r *APIKeyRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.apiKeyIndex(func(k domain.APIKey) bool { return k.Prefix == prefix })
+	if i < 0 {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	key := r.store.apiKeys[i]
+	return &key, nil
+}
+
+func (r *APIKeyRepo) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	var list []domain.APIKey
+	for _, k := range r.store.apiKeys {
+		if k.TenantID == tenantID {
+			list = append(list, k)
+		}
+	}
+	return list, nil
+}
+
+func (r *APIKeyRepo) ExpireAPIKey(ctx context.Context, id int64, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.apiKeyIndex(func(k domain.APIKey) bool { return k.ID == id && k.TenantID == domain.TenantFromContext(ctx) })
+	if i < 0 {
+		return nil
+	}
+	r.updateAPIKey(i, func(k *domain.APIKey) {
+		if k.ExpiresAt == nil || k.ExpiresAt.After(at) {
+			k.ExpiresAt = &at
+		}
+	})
+	return nil
+}
+
+func (r *APIKeyRepo) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (*domain.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.apiKeyIndex(func(k domain.APIKey) bool {
+		return k.ID == id && k.TenantID == domain.TenantFromContext(ctx) && k.RevokedAt == nil
+	})
+	if i < 0 {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	key := r.updateAPIKey(i, func(k *domain.APIKey) { k.RevokedAt = &at })
+	return &key, nil
+}
+
+func (r *APIKeyRepo) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if i := r.apiKeyIndex(func(k domain.APIKey) bool { return k.ID == id }); i >= 0 {
+		r.updateAPIKey(i, func(k *domain.APIKey) { k.LastUsedAt = &at })
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"slices"
+)
+
+type AuditRepo struct {
+	store *Store
+}
+
+func NewAuditRepo(store *Store) *AuditRepo {
+	return &AuditRepo{store: store}
+}
+
+func (r *AuditRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.appendAuditEntry(ctx, nil, arg)
+	return nil
+}
+
+func (r *AuditRepo) ListAuditEntries(ctx context.Context, arg domain.ListAuditEntriesQuery) ([]domain.AuditEntry, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	entries := []domain.AuditEntry{}
+	for _, e := range slices.Backward(r.store.auditLog) {
+		if len(entries) == int(arg.Limit) {
+			break
+		}
+		if e.TenantID != tenantID ||
+			(arg.CursorID != nil && e.ID >= *arg.CursorID) ||
+			(arg.Actor != nil && e.Actor != *arg.Actor) ||
+			(arg.Action != nil && e.Action != *arg.Action) ||
+			(arg.EntityType != nil && e.EntityType != *arg.EntityType) ||
+			(arg.EntityID != nil && e.EntityID != *arg.EntityID) ||
+			(arg.From != nil && e.OccurredAt.Before(*arg.From)) ||
+			(arg.To != nil && !e.OccurredAt.Before(*arg.To)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (r *AuditRepo) ListAuditChain(ctx context.Context, afterID int64, limit int32) ([]domain.AuditEntry, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	entries := []domain.AuditEntry{}
+	for _, e := range r.store.auditLog {
+		if len(entries) == int(limit) {
+			break
+		}
+		if e.TenantID == tenantID && e.ID > afterID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+/*
+appendAuditEntry chains the entry to the last one of the tenant of ctx, must be called with the store lock held.
+The store lock serializes the writers like the chain lock of the Postgres repositories.
+*/
+func (s *Store) appendAuditEntry(ctx context.Context, tx *txState, arg domain.CreateAuditEntryCommand) {
+	entry := domain.AuditEntry{
+		ID:             s.nextID(),
+		TenantID:       domain.TenantFromContext(ctx),
+		OccurredAt:     arg.OccurredAt,
+		Actor:          arg.Actor,
+		Action:         arg.Action,
+		EntityType:     arg.EntityType,
+		EntityID:       arg.EntityID,
+		Before:         arg.Before,
+		After:          arg.After,
+		RequestID:      arg.RequestID,
+		IdempotencyKey: arg.IdempotencyKey,
+		SourceIP:       arg.SourceIP,
+	}
+	for _, e := range slices.Backward(s.auditLog) {
+		if e.TenantID == entry.TenantID {
+			entry.PrevHash = e.Hash
+			break
+		}
+	}
+	entry.Hash = entry.ComputeHash()
+	s.auditLog = append(s.auditLog, entry)
+	tx.onRollback(func() {
+		s.auditLog = slices.DeleteFunc(s.auditLog, func(e domain.AuditEntry) bool { return e.ID == entry.ID })
+	})
+}
+
+func (r *BillingRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.appendAuditEntry(ctx, r.tx, arg)
+	return nil
+}
+
+func (r *CollectionRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.appendAuditEntry(ctx, r.tx, arg)
+	return nil
+}
+
+func (r *WebhookRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.appendAuditEntry(ctx, r.tx, arg)
+	return nil
+}
+
+func (r *APIKeyRepo) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.appendAuditEntry(ctx, r.tx, arg)
+	return nil
+}
@@ -0,0 +1,412 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type BillingRepo struct {
+	store *Store
+	tx    *txState // nil outside of WithTx
+}
+
+func NewBillingRepo(store *Store) *BillingRepo {
+	return &BillingRepo{store: store}
+}
+
+func (r *BillingRepo) WithTx(ctx context.Context, fn func(repo domain.BillingRepository) error) error {
+	return r.WithTxOptions(ctx, domain.TxOptions{}, fn)
+}
+
+// WithTxOptions ignores the options, there are no transient errors to retry and reads are never isolated
+func (r *BillingRepo) WithTxOptions(ctx context.Context, opts domain.TxOptions, fn func(repo domain.BillingRepository) error) error {
+	return r.store.withTx(func(tx *txState) error {
+		return fn(&BillingRepo{store: r.store, tx: tx})
+	})
+}
+
+// LOAN RELATED
+func (r *BillingRepo) GetLoanByID(ctx context.Context, id int64) (*domain.Loan, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	loan, ok := r.store.loans[id]
+	if !ok || !r.store.ownsLoan(ctx, id) {
+		return nil, domain.ErrLoanNotFound
+	}
+	return &loan, nil
+}
+
+func (r *BillingRepo) ListLoans(ctx context.Context, cursorID *int64, borrowerID *string, limit int32) ([]domain.Loan, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	ids := make([]int64, 0, len(r.store.loans))
+	for id, loan := range r.store.loans {
+		if !r.store.ownsLoan(ctx, id) {
+			continue
+		}
+		if (cursorID == nil || id < *cursorID) && (borrowerID == nil || loan.BorrowerID != nil && *loan.BorrowerID == *borrowerID) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+
+	list := make([]domain.Loan, 0, limit)
+	for _, id := range ids[:min(len(ids), int(limit))] {
+		list = append(list, r.store.loans[id])
+	}
+	return list, nil
+}
+
+// LockLoanForUpdate blocks until no other transaction holds the loan, like SELECT ... FOR UPDATE
+func (r *BillingRepo) LockLoanForUpdate(ctx context.Context, id int64) (*domain.Loan, error) {
+	r.store.mu.Lock()
+	ok := r.store.ownsLoan(ctx, id)
+	lock := r.store.loanLocks[id]
+	r.store.mu.Unlock()
+	if !ok {
+		return nil, domain.ErrLoanNotFound
+	}
+
+	r.tx.lock(lock)
+	return r.GetLoanByID(ctx, id)
+}
+
+func (r *BillingRepo) InsertLoan(ctx context.Context, arg domain.CreateLoanCommand) (*domain.Loan, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	loan := domain.Loan{
+		ID:                  r.store.nextID(),
+		PrincipalAmount:     arg.PrincipalAmount,
+		TotalPayableAmount:  arg.TotalPayableAmount,
+		WeeklyPaymentAmount: arg.WeeklyPaymentAmount,
+		TotalWeeks:          int(arg.TotalWeeks),
+		BorrowerID:          arg.BorrowerID,
+		CreatedAt:           time.Now(),
+	}
+	r.store.loans[loan.ID] = loan
+	r.store.loanTenants[loan.ID] = domain.TenantFromContext(ctx)
+	r.store.startDate[loan.ID] = arg.StartDate
+	r.store.loanLocks[loan.ID] = &sync.Mutex{}
+	r.tx.onRollback(func() {
+		delete(r.store.loans, loan.ID)
+		delete(r.store.loanTenants, loan.ID)
+		delete(r.store.startDate, loan.ID)
+		delete(r.store.loanLocks, loan.ID)
+	})
+	return &loan, nil
+}
+
+// PAYMENT RELATED
+func (r *BillingRepo) paymentsOf(ctx context.Context, loanID int64) []storedPayment {
+	tenantID := domain.TenantFromContext(ctx)
+	var payments []storedPayment
+	for _, p := range r.store.payments {
+		if p.LoanID == loanID && p.TenantID == tenantID {
+			payments = append(payments, p)
+		}
+	}
+	return payments
+}
+
+func (r *BillingRepo) GetTotalPaidAmount(ctx context.Context, loanID int64) (int64, error) {
+	return r.GetTotalPaidAmountBefore(ctx, loanID, time.Time{})
+}
+
+func (r *BillingRepo) GetPaidWeeksCount(ctx context.Context, loanID int64) (int32, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return int32(len(r.paymentsOf(ctx, loanID))), nil
+}
+
+func (r *BillingRepo) GetLastPaidWeek(ctx context.Context, loanID int64) (int32, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var last int
+	for _, p := range r.paymentsOf(ctx, loanID) {
+		last = max(last, p.WeekNumber)
+	}
+	return int32(last), nil
+}
+
+// InsertPayment enforces the same unique constraints as the payments table, idempotency keys are unique per tenant
+func (r *BillingRepo) InsertPayment(ctx context.Context, arg domain.CreatePaymentComand) (*domain.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	for _, p := range r.store.payments {
+		if p.IdempotencyKey == arg.IdempotencyKey && p.TenantID == tenantID {
+			return nil, domain.ErrDuplicatePayment
+		}
+		if p.LoanID == arg.LoanID && p.WeekNumber == int(arg.WeekNumber) {
+			return nil, domain.ErrConcurrentPayment
+		}
+	}
+
+	payment := storedPayment{
+		Payment: domain.Payment{
+			ID:         r.store.nextID(),
+			LoanID:     arg.LoanID,
+			WeekNumber: int(arg.WeekNumber),
+			Amount:     arg.Amount,
+			PaidAt:     arg.PaidAt,
+		},
+		TenantID:       tenantID,
+		IdempotencyKey: arg.IdempotencyKey,
+		CreatedAt:      time.Now(),
+	}
+	r.store.payments = append(r.store.payments, payment)
+	r.tx.onRollback(func() {
+		r.store.payments = slices.DeleteFunc(r.store.payments, func(p storedPayment) bool { return p.ID == payment.ID })
+	})
+	return &payment.Payment, nil
+}
+
+func (r *BillingRepo) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*domain.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	i := slices.IndexFunc(r.store.payments, func(p storedPayment) bool {
+		return p.IdempotencyKey == idempotencyKey && p.TenantID == tenantID
+	})
+	if i < 0 {
+		return nil, domain.ErrPaymentNotFound
+	}
+	payment := r.store.payments[i].Payment
+	return &payment, nil
+}
+
+func (r *BillingRepo) ListPaymentsByLoanID(ctx context.Context, arg domain.ListPaymentsQuery) ([]domain.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	payments := r.sortedPayments(ctx, arg.LoanID)
+	list := make([]domain.Payment, 0, arg.LimitVal)
+	for _, p := range payments {
+		if !arg.CursorPaidAt.IsZero() && arg.CursorID != nil {
+			if p.PaidAt.Before(arg.CursorPaidAt) || (p.PaidAt.Equal(arg.CursorPaidAt) && p.ID <= *arg.CursorID) {
+				continue
+			}
+		}
+		if len(list) == int(arg.LimitVal) {
+			break
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func (r *BillingRepo) ListPaymentsByLoanIDInPeriod(ctx context.Context, arg domain.StatementPeriodQuery) ([]domain.Payment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var list []domain.Payment
+	for _, p := range r.sortedPayments(ctx, arg.LoanID) {
+		if !p.PaidAt.Before(arg.PeriodStart) && p.PaidAt.Before(arg.PeriodEnd) {
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
+
+// GetTotalPaidAmountBefore sums the payments made before the given time, a zero time sums all of them
+func (r *BillingRepo) GetTotalPaidAmountBefore(ctx context.Context, loanID int64, before time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var total int64
+	for _, p := range r.paymentsOf(ctx, loanID) {
+		if before.IsZero() || p.PaidAt.Before(before) {
+			total += p.Amount
+		}
+	}
+	return total, nil
+}
+
+// sortedPayments returns the payments of a loan ordered by paid_at and id, must be called with the store lock held
+func (r *BillingRepo) sortedPayments(ctx context.Context, loanID int64) []domain.Payment {
+	stored := r.paymentsOf(ctx, loanID)
+	payments := make([]domain.Payment, len(stored))
+	for i, p := range stored {
+		payments[i] = p.Payment
+	}
+	slices.SortFunc(payments, func(a, b domain.Payment) int {
+		if c := a.PaidAt.Compare(b.PaidAt); c != 0 {
+			return c
+		}
+		return int(a.ID - b.ID)
+	})
+	return payments
+}
+
+// SCHEDULE RELATED
+func (r *BillingRepo) CreateLoanSchedules(ctx context.Context, arg []domain.LoanSchedule) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, s := range arg {
+		s.ID = r.store.nextID()
+		s.Sequence = i + 1
+		s.Status = "PENDING"
+		r.store.schedules[s.LoanID] = append(r.store.schedules[s.LoanID], s)
+	}
+	if len(arg) > 0 {
+		loanID := arg[0].LoanID
+		r.tx.onRollback(func() { delete(r.store.schedules, loanID) })
+	}
+	return int64(len(arg)), nil
+}
+
+func (r *BillingRepo) ListSchedulesByLoanID(ctx context.Context, arg domain.ListScheduleQuery) ([]domain.LoanSchedule, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	list := make([]domain.LoanSchedule, 0, arg.Limit)
+	if !r.store.ownsLoan(ctx, arg.LoanID) {
+		return list, nil
+	}
+	for _, s := range r.store.schedules[arg.LoanID] {
+		if s.Sequence <= int(arg.CursorSequence) {
+			continue
+		}
+		if len(list) == int(arg.Limit) {
+			break
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (r *BillingRepo) UpdateSchedulePayment(ctx context.Context, arg domain.UpdateLoanSchedulePaymentCommand) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if !r.store.ownsLoan(ctx, arg.LoanID) {
+		return 0, domain.ErrScheduleNotFound
+	}
+	schedules := r.store.schedules[arg.LoanID]
+	for i := range schedules {
+		s := &schedules[i]
+		if s.Sequence != int(arg.Sequence) {
+			continue
+		}
+		previous := *s
+		s.PaidAmount += arg.PaidAmount
+		s.Status = "PARTIAL"
+		if s.PaidAmount >= s.Amount {
+			s.Status = "PAID"
+		}
+		r.tx.onRollback(func() { *s = previous })
+		return s.ID, nil
+	}
+	return 0, domain.ErrScheduleNotFound
+}
+
+func (r *BillingRepo) ListUpcomingSchedules(ctx context.Context, arg domain.UpcomingSchedulesQuery) ([]domain.LoanSchedule, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var list []domain.LoanSchedule
+	if !r.store.ownsLoan(ctx, arg.LoanID) {
+		return list, nil
+	}
+	paid := make(map[int]int64)
+	for _, p := range r.paymentsOf(ctx, arg.LoanID) {
+		if p.PaidAt.Before(arg.DueFrom) {
+			paid[p.WeekNumber] += p.Amount
+		}
+	}
+	for _, s := range r.store.schedules[arg.LoanID] {
+		if s.DueDate.Before(arg.DueFrom) || paid[s.Sequence] >= s.Amount {
+			continue
+		}
+		if len(list) == int(arg.Limit) {
+			break
+		}
+		s.PaidAmount, s.Status = paid[s.Sequence], "PENDING"
+		if s.PaidAmount > 0 {
+			s.Status = "PARTIAL"
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// EVENT RELATED
+func (r *BillingRepo) InsertOutboxEvent(ctx context.Context, arg domain.CreateOutboxEventCommand) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	event := domain.OutboxEvent{
+		ID:            r.store.nextID(),
+		TenantID:      domain.TenantFromContext(ctx),
+		AggregateType: arg.AggregateType,
+		AggregateID:   arg.AggregateID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		CreatedAt:     time.Now(),
+	}
+	r.store.outbox = append(r.store.outbox, event)
+	r.tx.onRollback(func() {
+		r.store.outbox = slices.DeleteFunc(r.store.outbox, func(e domain.OutboxEvent) bool { return e.ID == event.ID })
+	})
+	return event.ID, nil
+}
+
+// ListNewlyDelinquentLoans mirrors the SQL: gapWeeks or more weeks behind and not announced since the last payment
+func (r *BillingRepo) ListNewlyDelinquentLoans(ctx context.Context, evaluatedAt time.Time, gapWeeks int32, limit int32) ([]domain.DelinquentLoan, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	ids := make([]int64, 0, len(r.store.loans))
+	for id := range r.store.loans {
+		if r.store.ownsLoan(ctx, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	var list []domain.DelinquentLoan
+	for _, id := range ids {
+		loan := r.store.loans[id]
+		if loan.CreatedAt.After(evaluatedAt) {
+			continue
+		}
+
+		var lastPaidWeek int
+		var lastPaidAt time.Time
+		for _, p := range r.paymentsOf(ctx, id) {
+			lastPaidWeek = max(lastPaidWeek, p.WeekNumber)
+			if p.CreatedAt.After(lastPaidAt) {
+				lastPaidAt = p.CreatedAt
+			}
+		}
+		expectedWeek := int(evaluatedAt.Sub(loan.CreatedAt)/(7*24*time.Hour)) + 1
+		if lastPaidWeek >= loan.TotalWeeks || expectedWeek-lastPaidWeek < int(gapWeeks) {
+			continue
+		}
+
+		announced := slices.ContainsFunc(r.store.outbox, func(e domain.OutboxEvent) bool {
+			return e.AggregateType == domain.AggregateTypeLoan && e.AggregateID == id &&
+				e.EventType == domain.EventLoanBecameDelinquent && e.CreatedAt.After(lastPaidAt)
+		})
+		if announced {
+			continue
+		}
+
+		list = append(list, domain.DelinquentLoan{LoanID: id, LastPaidWeek: lastPaidWeek, ExpectedWeek: expectedWeek})
+		if len(list) == int(limit) {
+			break
+		}
+	}
+	return list, nil
+}
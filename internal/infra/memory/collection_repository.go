@@ -0,0 +1,320 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type CollectionRepo struct {
+	store *Store
+	tx    *txState
+}
+
+func NewCollectionRepo(store *Store) *CollectionRepo {
+	return &CollectionRepo{store: store}
+}
+
+func (r *CollectionRepo) WithTx(ctx context.Context, fn func(repo domain.CollectionRepository) error) error {
+	return r.store.withTx(func(tx *txState) error {
+		return fn(&CollectionRepo{store: r.store, tx: tx})
+	})
+}
+
+// Billing returns a billing repository sharing the transaction of r
+func (r *CollectionRepo) Billing() domain.BillingRepository {
+	return &BillingRepo{store: r.store, tx: r.tx}
+}
+
+// MANDATE RELATED
+func (r *CollectionRepo) InsertMandate(ctx context.Context, arg domain.CreateMandateCommand) (*domain.Mandate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// uk_mandates_active_loan_id, one active mandate per loan
+	if r.activeMandateIndex(arg.LoanID) >= 0 {
+		return nil, domain.ErrMandateAlreadyActive
+	}
+	mandate := domain.Mandate{
+		ID:            r.store.nextID(),
+		LoanID:        arg.LoanID,
+		AccountHolder: arg.AccountHolder,
+		BankCode:      arg.BankCode,
+		AccountNumber: arg.AccountNumber,
+		Reference:     arg.Reference,
+		Status:        domain.MandateStatusActive,
+		CreatedAt:     time.Now(),
+	}
+	r.store.mandates = append(r.store.mandates, mandate)
+	r.tx.onRollback(func() {
+		r.store.mandates = slices.DeleteFunc(r.store.mandates, func(m domain.Mandate) bool { return m.ID == mandate.ID })
+	})
+	return &mandate, nil
+}
+
+// activeMandateIndex must be called with the store lock held, -1 when the loan has no active mandate
+func (r *CollectionRepo) activeMandateIndex(loanID int64) int {
+	return slices.IndexFunc(r.store.mandates, func(m domain.Mandate) bool {
+		return m.LoanID == loanID && m.Status == domain.MandateStatusActive
+	})
+}
+
+func (r *CollectionRepo) GetActiveMandateByLoanID(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.activeMandateIndex(loanID)
+	if i < 0 || !r.store.ownsLoan(ctx, loanID) {
+		return nil, domain.ErrMandateNotFound
+	}
+	mandate := r.store.mandates[i]
+	return &mandate, nil
+}
+
+func (r *CollectionRepo) RevokeMandate(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.activeMandateIndex(loanID)
+	if i < 0 || !r.store.ownsLoan(ctx, loanID) {
+		return nil, domain.ErrMandateNotFound
+	}
+	previous := r.store.mandates[i]
+	now := time.Now()
+	r.store.mandates[i].Status = domain.MandateStatusRevoked
+	r.store.mandates[i].RevokedAt = &now
+	r.tx.onRollback(func() { r.restoreMandate(previous) })
+
+	mandate := r.store.mandates[i]
+	return &mandate, nil
+}
+
+func (r *CollectionRepo) restoreMandate(m domain.Mandate) {
+	if i := slices.IndexFunc(r.store.mandates, func(x domain.Mandate) bool { return x.ID == m.ID }); i >= 0 {
+		r.store.mandates[i] = m
+	}
+}
+
+// COLLECTION RUN RELATED
+func (r *CollectionRepo) LockCollectionRun(ctx context.Context) error {
+	r.tx.lock(r.store.advisoryLock("collection_run"))
+	return nil
+}
+
+// ListCollectionCandidates mirrors the SQL: due, unpaid schedules with an active mandate that are not being collected
+func (r *CollectionRepo) ListCollectionCandidates(ctx context.Context, collectionDate time.Time) ([]domain.CollectionCandidate, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	loanIDs := make([]int64, 0, len(r.store.schedules))
+	for id := range r.store.schedules {
+		if r.store.ownsLoan(ctx, id) {
+			loanIDs = append(loanIDs, id)
+		}
+	}
+	slices.Sort(loanIDs)
+
+	var list []domain.CollectionCandidate
+	for _, loanID := range loanIDs {
+		i := r.activeMandateIndex(loanID)
+		if i < 0 {
+			continue
+		}
+		mandate := r.store.mandates[i]
+
+		for _, s := range r.store.schedules[loanID] {
+			if s.DueDate.After(collectionDate) || s.Status == "PAID" {
+				continue
+			}
+
+			var previousAttempts int
+			inProgress := false
+			for _, item := range r.store.collectionItems {
+				if item.LoanID != loanID || item.ScheduleSequence != s.Sequence {
+					continue
+				}
+				previousAttempts++
+				switch item.Status {
+				case domain.CollectionItemStatusSubmitted, domain.CollectionItemStatusSucceeded, domain.CollectionItemStatusFailed, domain.CollectionItemStatusUnposted:
+					inProgress = true
+				case domain.CollectionItemStatusRetryPending:
+					inProgress = inProgress || item.NextAttemptOn.After(collectionDate)
+				}
+			}
+			if inProgress {
+				continue
+			}
+
+			list = append(list, domain.CollectionCandidate{
+				LoanID:           loanID,
+				MandateID:        mandate.ID,
+				ScheduleSequence: s.Sequence,
+				DueDate:          s.DueDate,
+				Amount:           s.Amount - s.PaidAmount,
+				PreviousAttempts: previousAttempts,
+			})
+		}
+	}
+	return list, nil
+}
+
+func (r *CollectionRepo) ConsumeDueRetries(ctx context.Context, collectionDate time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i := range r.store.collectionItems {
+		item := &r.store.collectionItems[i]
+		if item.Status != domain.CollectionItemStatusRetryPending || item.NextAttemptOn.After(collectionDate) || !r.store.ownsCollectionBatch(ctx, item.BatchID) {
+			continue
+		}
+		// like the SQL, a loan without an active mandate keeps its retry for later
+		if r.activeMandateIndex(item.LoanID) < 0 {
+			continue
+		}
+		previous := *item
+		item.Status = domain.CollectionItemStatusRetried
+		item.UpdatedAt = time.Now()
+		r.tx.onRollback(func() { r.restoreCollectionItem(previous) })
+	}
+	return nil
+}
+
+func (r *CollectionRepo) InsertCollectionBatch(ctx context.Context, arg domain.CreateCollectionBatchCommand) (*domain.CollectionBatch, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	batch := domain.CollectionBatch{
+		ID:             r.store.nextID(),
+		CollectionDate: arg.CollectionDate,
+		Status:         domain.CollectionBatchStatusCreated,
+		ItemCount:      int(arg.ItemCount),
+		TotalAmount:    arg.TotalAmount,
+		CreatedAt:      time.Now(),
+	}
+	r.store.collectionBatches[batch.ID] = batch
+	r.store.batchTenants[batch.ID] = domain.TenantFromContext(ctx)
+	r.tx.onRollback(func() {
+		delete(r.store.collectionBatches, batch.ID)
+		delete(r.store.batchTenants, batch.ID)
+	})
+	return &batch, nil
+}
+
+func (r *CollectionRepo) CreateCollectionItems(ctx context.Context, arg []domain.CreateCollectionItemCommand) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	ids := make([]int64, len(arg))
+	for i, cmd := range arg {
+		ids[i] = r.store.nextID()
+		r.store.collectionItems = append(r.store.collectionItems, storedCollectionItem{
+			CollectionItem: domain.CollectionItem{
+				ID:               ids[i],
+				BatchID:          cmd.BatchID,
+				LoanID:           cmd.LoanID,
+				MandateID:        cmd.MandateID,
+				ScheduleSequence: int(cmd.ScheduleSequence),
+				Amount:           cmd.Amount,
+				Attempt:          int(cmd.Attempt),
+				Status:           domain.CollectionItemStatusSubmitted,
+			},
+			UpdatedAt: time.Now(),
+		})
+	}
+	r.tx.onRollback(func() {
+		r.store.collectionItems = slices.DeleteFunc(r.store.collectionItems, func(item storedCollectionItem) bool {
+			return slices.Contains(ids, item.ID)
+		})
+	})
+	return int64(len(arg)), nil
+}
+
+// BATCH RELATED
+func (r *CollectionRepo) GetCollectionBatchByID(ctx context.Context, id int64) (*domain.CollectionBatch, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	batch, ok := r.store.collectionBatches[id]
+	if !ok || !r.store.ownsCollectionBatch(ctx, id) {
+		return nil, domain.ErrCollectionBatchNotFound
+	}
+	return &batch, nil
+}
+
+// LockCollectionBatchForUpdate holds the batch until the end of the transaction, like the row lock of the SQL
+func (r *CollectionRepo) LockCollectionBatchForUpdate(ctx context.Context, id int64) (*domain.CollectionBatch, error) {
+	r.tx.lock(r.store.advisoryLock(fmt.Sprintf("collection_batch:%d", id)))
+	return r.GetCollectionBatchByID(ctx, id)
+}
+
+func (r *CollectionRepo) UpdateCollectionBatchStatus(ctx context.Context, id int64, status string) (*domain.CollectionBatch, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	batch, ok := r.store.collectionBatches[id]
+	if !ok || !r.store.ownsCollectionBatch(ctx, id) {
+		return nil, domain.ErrCollectionBatchNotFound
+	}
+	previous := batch
+	batch.Status = status
+	r.store.collectionBatches[id] = batch
+	r.tx.onRollback(func() { r.store.collectionBatches[id] = previous })
+	return &batch, nil
+}
+
+// ListCollectionItemsByBatchID returns the items joined with the mandate details, ordered by id
+func (r *CollectionRepo) ListCollectionItemsByBatchID(ctx context.Context, batchID int64) ([]domain.CollectionItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var list []domain.CollectionItem
+	if !r.store.ownsCollectionBatch(ctx, batchID) {
+		return list, nil
+	}
+	for _, stored := range r.store.collectionItems {
+		if stored.BatchID != batchID {
+			continue
+		}
+		item := stored.CollectionItem
+		if i := slices.IndexFunc(r.store.mandates, func(m domain.Mandate) bool { return m.ID == item.MandateID }); i >= 0 {
+			m := r.store.mandates[i]
+			item.AccountHolder = m.AccountHolder
+			item.BankCode = m.BankCode
+			item.AccountNumber = m.AccountNumber
+			item.MandateReference = m.Reference
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// UpdateCollectionItemResult only settles submitted items of the batch, like the SQL
+func (r *CollectionRepo) UpdateCollectionItemResult(ctx context.Context, arg domain.UpdateCollectionItemResultCommand) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := slices.IndexFunc(r.store.collectionItems, func(item storedCollectionItem) bool {
+		return item.ID == arg.ItemID && item.BatchID == arg.BatchID && item.Status == domain.CollectionItemStatusSubmitted
+	})
+	if i < 0 || !r.store.ownsCollectionBatch(ctx, arg.BatchID) {
+		return 0, domain.ErrCollectionItemNotFound
+	}
+
+	item := &r.store.collectionItems[i]
+	previous := *item
+	item.Status = arg.Status
+	item.FailureCode = arg.FailureCode
+	item.NextAttemptOn = arg.NextAttemptOn
+	item.PaymentID = arg.PaymentID
+	item.UpdatedAt = time.Now()
+	r.tx.onRollback(func() { r.restoreCollectionItem(previous) })
+	return item.ID, nil
+}
+
+func (r *CollectionRepo) restoreCollectionItem(item storedCollectionItem) {
+	if i := slices.IndexFunc(r.store.collectionItems, func(x storedCollectionItem) bool { return x.ID == item.ID }); i >= 0 {
+		r.store.collectionItems[i] = item
+	}
+}
@@ -0,0 +1,20 @@
+package memory
+
+import "context"
+
+// HealthRepo is always reachable, with the schema version it is given
+type HealthRepo struct {
+	schemaVersion int32
+}
+
+func NewHealthRepo(schemaVersion int32) *HealthRepo {
+	return &HealthRepo{schemaVersion: schemaVersion}
+}
+
+func (r *HealthRepo) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *HealthRepo) GetSchemaVersion(ctx context.Context) (int32, error) {
+	return r.schemaVersion, nil
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"time"
+)
+
+type IdempotencyRepo struct {
+	store *Store
+}
+
+func NewIdempotencyRepo(store *Store) *IdempotencyRepo {
+	return &IdempotencyRepo{store: store}
+}
+
+// AcquireIdempotencyKey claims a new key, or takes over one that expired or whose owner stopped before completing it
+func (r *IdempotencyRepo) AcquireIdempotencyKey(ctx context.Context, arg domain.AcquireIdempotencyKeyCommand) (*domain.IdempotencyRecord, bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	id := idempotencyKeyID{TenantID: domain.TenantFromContext(ctx), Key: arg.Key}
+	existing, ok := r.store.idempotencyKeys[id]
+	if ok {
+		expired := !existing.ExpiresAt.After(arg.Now)
+		stale := existing.Status == domain.IdempotencyStatusInProgress && !existing.LockedAt.After(arg.StaleBefore)
+		if !expired && !stale {
+			record := existing.IdempotencyRecord
+			return &record, false, nil
+		}
+	}
+
+	stored := &storedIdempotencyKey{
+		IdempotencyRecord: domain.IdempotencyRecord{
+			Key:         arg.Key,
+			RequestHash: arg.RequestHash,
+			Status:      domain.IdempotencyStatusInProgress,
+			CreatedAt:   arg.Now,
+			ExpiresAt:   arg.ExpiresAt,
+		},
+		LockedAt: arg.Now,
+	}
+	r.store.idempotencyKeys[id] = stored
+	record := stored.IdempotencyRecord
+	return &record, true, nil
+}
+
+func (r *IdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, arg domain.CompleteIdempotencyKeyCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.idempotencyKeys[idempotencyKeyID{TenantID: domain.TenantFromContext(ctx), Key: arg.Key}]
+	if !ok || stored.Status != domain.IdempotencyStatusInProgress {
+		return nil
+	}
+	stored.Status = domain.IdempotencyStatusCompleted
+	stored.ResponseStatus = arg.ResponseStatus
+	stored.ResponseContentType = arg.ResponseContentType
+	stored.ResponseBody = arg.ResponseBody
+	return nil
+}
+
+// ReleaseIdempotencyKey frees an in-progress key so the request can be retried
+func (r *IdempotencyRepo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	id := idempotencyKeyID{TenantID: domain.TenantFromContext(ctx), Key: key}
+	if stored, ok := r.store.idempotencyKeys[id]; ok && stored.Status == domain.IdempotencyStatusInProgress {
+		delete(r.store.idempotencyKeys, id)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys purges the keys of every tenant
+func (r *IdempotencyRepo) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for key, stored := range r.store.idempotencyKeys {
+		if !stored.ExpiresAt.After(now) {
+			delete(r.store.idempotencyKeys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
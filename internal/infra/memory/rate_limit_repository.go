@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"time"
+)
+
+// rateLimitBucket is a row of rate_limit_buckets
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// RateLimitRepo keeps the token buckets of a single instance
+type RateLimitRepo struct {
+	store *Store
+}
+
+func NewRateLimitRepo(store *Store) *RateLimitRepo {
+	return &RateLimitRepo{store: store}
+}
+
+// TakeRateLimitToken refills the bucket for the time elapsed since its last request and takes a token when a whole one is left
+func (r *RateLimitRepo) TakeRateLimitToken(ctx context.Context, arg domain.TakeRateLimitTokenCommand) (*domain.RateLimitBucket, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	bucket, ok := r.store.rateLimitBuckets[arg.Key]
+	if !ok {
+		bucket = &rateLimitBucket{tokens: float64(arg.Limit.Burst), updatedAt: arg.Now}
+		r.store.rateLimitBuckets[arg.Key] = bucket
+	}
+	if elapsed := arg.Now.Sub(bucket.updatedAt); elapsed > 0 {
+		bucket.tokens = min(float64(arg.Limit.Burst), bucket.tokens+elapsed.Seconds()*arg.Limit.Rate)
+		bucket.updatedAt = arg.Now
+	}
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return &domain.RateLimitBucket{Allowed: allowed, Tokens: bucket.tokens}, nil
+}
+
+func (r *RateLimitRepo) DeleteIdleRateLimitBuckets(ctx context.Context, idleBefore time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for key, bucket := range r.store.rateLimitBuckets {
+		if !bucket.updatedAt.After(idleBefore) {
+			delete(r.store.rateLimitBuckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,204 @@
+/*
+Package memory holds in-memory implementations of the repositories.
+
+They follow the semantics of the Postgres repositories closely enough to run the services in tests
+without a database: unique constraints are enforced, writes of a failed transaction are rolled back,
+and row and advisory locks block concurrent transactions until the holder ends.
+Reads are not isolated from other running transactions.
+
+All repositories created from the same Store share its tables, like repositories sharing a pool.
+*/
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type storedPayment struct {
+	domain.Payment
+	TenantID       string
+	IdempotencyKey string
+	CreatedAt      time.Time
+}
+
+type storedCollectionItem struct {
+	domain.CollectionItem
+	UpdatedAt time.Time
+}
+
+// storedWebhookSubscription belongs to a tenant, its deliveries too
+type storedWebhookSubscription struct {
+	domain.WebhookSubscription
+	TenantID  string
+	DeletedAt *time.Time
+}
+
+// idempotencyKeyID is the primary key of idempotency_keys, keys are unique per tenant
+type idempotencyKeyID struct {
+	TenantID string
+	Key      string
+}
+
+type storedIdempotencyKey struct {
+	domain.IdempotencyRecord
+	LockedAt time.Time
+}
+
+type Store struct {
+	mu     sync.Mutex
+	lastID int64
+
+	// billing, the payments and schedules of a loan belong to its tenant
+	loans       map[int64]domain.Loan
+	loanTenants map[int64]string
+	startDate   map[int64]time.Time
+	payments    []storedPayment
+	schedules   map[int64][]domain.LoanSchedule // by loan id, ordered by sequence
+	outbox      []domain.OutboxEvent
+
+	// direct debit collection
+	mandates          []domain.Mandate
+	collectionBatches map[int64]domain.CollectionBatch
+	batchTenants      map[int64]string
+	collectionItems   []storedCollectionItem
+
+	// webhooks
+	webhookSubscriptions []storedWebhookSubscription
+	webhookDeliveries    []domain.WebhookDelivery
+	webhookAttempts      []domain.WebhookDeliveryAttempt
+
+	idempotencyKeys map[idempotencyKeyID]*storedIdempotencyKey
+
+	rateLimitBuckets map[string]*rateLimitBucket
+
+	apiKeys []domain.APIKey
+
+	// append-only, in write order, chained per tenant
+	auditLog []domain.AuditEntry
+
+	// row locks by loan id and advisory locks by name, held until the end of the transaction
+	loanLocks     map[int64]*sync.Mutex
+	advisoryLocks map[string]*sync.Mutex
+}
+
+func NewStore() *Store {
+	return &Store{
+		loans:             make(map[int64]domain.Loan),
+		loanTenants:       make(map[int64]string),
+		startDate:         make(map[int64]time.Time),
+		schedules:         make(map[int64][]domain.LoanSchedule),
+		collectionBatches: make(map[int64]domain.CollectionBatch),
+		batchTenants:      make(map[int64]string),
+		idempotencyKeys:   make(map[idempotencyKeyID]*storedIdempotencyKey),
+		rateLimitBuckets:  make(map[string]*rateLimitBucket),
+		loanLocks:         make(map[int64]*sync.Mutex),
+		advisoryLocks:     make(map[string]*sync.Mutex),
+	}
+}
+
+// ownsLoan reports whether the loan exists for the tenant of ctx, must be called with the store lock held
+func (s *Store) ownsLoan(ctx context.Context, loanID int64) bool {
+	tenantID, ok := s.loanTenants[loanID]
+	return ok && tenantID == domain.TenantFromContext(ctx)
+}
+
+// ownsCollectionBatch is ownsLoan for collection batches
+func (s *Store) ownsCollectionBatch(ctx context.Context, batchID int64) bool {
+	tenantID, ok := s.batchTenants[batchID]
+	return ok && tenantID == domain.TenantFromContext(ctx)
+}
+
+func (s *Store) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// txState tracks what a transaction has to undo on rollback and which locks it holds
+type txState struct {
+	undo   []func()
+	locked []*sync.Mutex
+}
+
+// withTx runs fn in a transaction, its writes are undone when fn fails or panics and its locks released at the end
+func (s *Store) withTx(fn func(tx *txState) error) (err error) {
+	tx := &txState{}
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.rollback(tx)
+			s.release(tx)
+			panic(rec)
+		}
+		if err != nil {
+			s.rollback(tx)
+		}
+		s.release(tx)
+	}()
+	return fn(tx)
+}
+
+func (s *Store) rollback(tx *txState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+func (s *Store) release(tx *txState) {
+	for _, l := range tx.locked {
+		l.Unlock()
+	}
+}
+
+// onRollback registers how to revert a write, must be called with the store lock held
+func (tx *txState) onRollback(fn func()) {
+	if tx != nil {
+		tx.undo = append(tx.undo, fn)
+	}
+}
+
+// lock takes l for the rest of the transaction, outside of a transaction there is nothing to hold it for
+func (tx *txState) lock(l *sync.Mutex) {
+	if tx == nil || slices.Contains(tx.locked, l) {
+		return
+	}
+	l.Lock()
+	tx.locked = append(tx.locked, l)
+}
+
+// tryLock is lock without waiting, like pg_try_advisory_xact_lock
+func (tx *txState) tryLock(l *sync.Mutex) bool {
+	if tx == nil {
+		return true
+	}
+	if slices.Contains(tx.locked, l) {
+		return true
+	}
+	if !l.TryLock() {
+		return false
+	}
+	tx.locked = append(tx.locked, l)
+	return true
+}
+
+func (s *Store) advisoryLock(name string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.advisoryLocks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		s.advisoryLocks[name] = l
+	}
+	return l
+}
+
+// OutboxEvents returns the committed outbox events, for assertions in tests
+func (s *Store) OutboxEvents() []domain.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.outbox)
+}
@@ -0,0 +1,350 @@
+package memory
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"slices"
+	"time"
+)
+
+type WebhookRepo struct {
+	store *Store
+	tx    *txState
+}
+
+func NewWebhookRepo(store *Store) *WebhookRepo {
+	return &WebhookRepo{store: store}
+}
+
+func (r *WebhookRepo) WithTx(ctx context.Context, fn func(repo domain.WebhookRepository) error) error {
+	return r.store.withTx(func(tx *txState) error {
+		return fn(&WebhookRepo{store: r.store, tx: tx})
+	})
+}
+
+// SUBSCRIPTION RELATED
+func (r *WebhookRepo) InsertWebhookSubscription(ctx context.Context, arg domain.CreateWebhookSubscriptionCommand) (*domain.WebhookSubscription, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	subscription := storedWebhookSubscription{
+		WebhookSubscription: domain.WebhookSubscription{
+			ID:         r.store.nextID(),
+			URL:        arg.URL,
+			EventTypes: slices.Clone(arg.EventTypes),
+			Secret:     arg.Secret,
+			Status:     domain.WebhookSubscriptionStatusActive,
+			CreatedAt:  time.Now(),
+		},
+		TenantID: domain.TenantFromContext(ctx),
+	}
+	r.store.webhookSubscriptions = append(r.store.webhookSubscriptions, subscription)
+	r.tx.onRollback(func() {
+		r.store.webhookSubscriptions = slices.DeleteFunc(r.store.webhookSubscriptions, func(s storedWebhookSubscription) bool {
+			return s.ID == subscription.ID
+		})
+	})
+	result := subscription.WebhookSubscription
+	return &result, nil
+}
+
+// activeSubscriptionIndex must be called with the store lock held, -1 when there is no such active subscription
+func (r *WebhookRepo) activeSubscriptionIndex(id int64) int {
+	return slices.IndexFunc(r.store.webhookSubscriptions, func(s storedWebhookSubscription) bool {
+		return s.ID == id && s.Status == domain.WebhookSubscriptionStatusActive
+	})
+}
+
+// tenantSubscriptionIndex is activeSubscriptionIndex limited to the subscriptions of the tenant of ctx
+func (r *WebhookRepo) tenantSubscriptionIndex(ctx context.Context, id int64) int {
+	i := r.activeSubscriptionIndex(id)
+	if i < 0 || r.store.webhookSubscriptions[i].TenantID != domain.TenantFromContext(ctx) {
+		return -1
+	}
+	return i
+}
+
+// ofTenant reports whether the delivery belongs to the tenant of ctx, deliveries have the tenant of their subscription.
+// Must be called with the store lock held.
+func (r *WebhookRepo) ofTenant(ctx context.Context, d domain.WebhookDelivery) bool {
+	tenantID := domain.TenantFromContext(ctx)
+	return slices.ContainsFunc(r.store.webhookSubscriptions, func(s storedWebhookSubscription) bool {
+		return s.ID == d.SubscriptionID && s.TenantID == tenantID
+	})
+}
+
+func (r *WebhookRepo) GetWebhookSubscriptionByID(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.tenantSubscriptionIndex(ctx, id)
+	if i < 0 {
+		return nil, domain.ErrWebhookNotFound
+	}
+	subscription := r.store.webhookSubscriptions[i].WebhookSubscription
+	return &subscription, nil
+}
+
+func (r *WebhookRepo) ListWebhookSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	var list []domain.WebhookSubscription
+	for _, s := range r.store.webhookSubscriptions {
+		if s.Status == domain.WebhookSubscriptionStatusActive && s.TenantID == tenantID {
+			list = append(list, s.WebhookSubscription)
+		}
+	}
+	return list, nil
+}
+
+func (r *WebhookRepo) DeleteWebhookSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := r.tenantSubscriptionIndex(ctx, id)
+	if i < 0 {
+		return nil, domain.ErrWebhookNotFound
+	}
+	previous := r.store.webhookSubscriptions[i]
+	now := time.Now()
+	r.store.webhookSubscriptions[i].Status = domain.WebhookSubscriptionStatusDeleted
+	r.store.webhookSubscriptions[i].DeletedAt = &now
+	r.tx.onRollback(func() {
+		if j := slices.IndexFunc(r.store.webhookSubscriptions, func(s storedWebhookSubscription) bool { return s.ID == id }); j >= 0 {
+			r.store.webhookSubscriptions[j] = previous
+		}
+	})
+	subscription := r.store.webhookSubscriptions[i].WebhookSubscription
+	return &subscription, nil
+}
+
+// DELIVERY RELATED
+// EnqueueWebhookDeliveries fans an event out to every active subscription of the tenant listening to its type, once per subscription
+func (r *WebhookRepo) EnqueueWebhookDeliveries(ctx context.Context, eventID int64, eventType string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var ids []int64
+	now := time.Now()
+	tenantID := domain.TenantFromContext(ctx)
+	for _, s := range r.store.webhookSubscriptions {
+		if s.Status != domain.WebhookSubscriptionStatusActive || s.TenantID != tenantID || !slices.Contains(s.EventTypes, eventType) {
+			continue
+		}
+		exists := slices.ContainsFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool {
+			return d.SubscriptionID == s.ID && d.EventID == eventID
+		})
+		if exists {
+			continue
+		}
+		delivery := domain.WebhookDelivery{
+			ID:             r.store.nextID(),
+			SubscriptionID: s.ID,
+			EventID:        eventID,
+			EventType:      eventType,
+			Status:         domain.WebhookDeliveryStatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		r.store.webhookDeliveries = append(r.store.webhookDeliveries, delivery)
+		ids = append(ids, delivery.ID)
+	}
+	r.tx.onRollback(func() {
+		r.store.webhookDeliveries = slices.DeleteFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool {
+			return slices.Contains(ids, d.ID)
+		})
+	})
+	return int64(len(ids)), nil
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries of active subscriptions with their event, oldest due first
+func (r *WebhookRepo) ClaimWebhookDeliveries(ctx context.Context, arg domain.ClaimWebhookDeliveriesCommand) ([]domain.DueWebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now, limit := arg.Now, arg.Limit
+	due := slices.Clone(r.store.webhookDeliveries)
+	slices.SortStableFunc(due, func(a, b domain.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+
+	var list []domain.DueWebhookDelivery
+	for _, d := range due {
+		if len(list) == int(limit) {
+			break
+		}
+		if d.Status != domain.WebhookDeliveryStatusPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		i := r.activeSubscriptionIndex(d.SubscriptionID)
+		j := slices.IndexFunc(r.store.outbox, func(e domain.OutboxEvent) bool { return e.ID == d.EventID })
+		if i < 0 || j < 0 {
+			continue
+		}
+		subscription := r.store.webhookSubscriptions[i]
+		r.updateDelivery(d.ID, func(d *domain.WebhookDelivery) { d.NextAttemptAt = arg.LeaseUntil })
+		list = append(list, domain.DueWebhookDelivery{
+			ID:             d.ID,
+			SubscriptionID: d.SubscriptionID,
+			Attempts:       d.Attempts,
+			URL:            subscription.URL,
+			Secret:         subscription.Secret,
+			Event:          r.store.outbox[j],
+		})
+	}
+	return list, nil
+}
+
+func (r *WebhookRepo) InsertWebhookDeliveryAttempt(ctx context.Context, arg domain.CreateWebhookDeliveryAttemptCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	attempt := domain.WebhookDeliveryAttempt{
+		ID:          r.store.nextID(),
+		DeliveryID:  arg.DeliveryID,
+		Attempt:     arg.Attempt,
+		StatusCode:  arg.StatusCode,
+		Error:       arg.Error,
+		Duration:    arg.Duration.Truncate(time.Millisecond),
+		AttemptedAt: time.Now(),
+	}
+	r.store.webhookAttempts = append(r.store.webhookAttempts, attempt)
+	r.tx.onRollback(func() {
+		r.store.webhookAttempts = slices.DeleteFunc(r.store.webhookAttempts, func(a domain.WebhookDeliveryAttempt) bool {
+			return a.ID == attempt.ID
+		})
+	})
+	return nil
+}
+
+// updateDelivery applies fn to the delivery and registers the undo, must be called with the store lock held
+func (r *WebhookRepo) updateDelivery(id int64, fn func(d *domain.WebhookDelivery)) (*domain.WebhookDelivery, bool) {
+	i := slices.IndexFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool { return d.ID == id })
+	if i < 0 {
+		return nil, false
+	}
+	previous := r.store.webhookDeliveries[i]
+	fn(&r.store.webhookDeliveries[i])
+	r.tx.onRollback(func() {
+		if j := slices.IndexFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool { return d.ID == id }); j >= 0 {
+			r.store.webhookDeliveries[j] = previous
+		}
+	})
+	delivery := r.store.webhookDeliveries[i]
+	return &delivery, true
+}
+
+func (r *WebhookRepo) MarkWebhookDeliverySucceeded(ctx context.Context, id int64, statusCode int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	r.updateDelivery(id, func(d *domain.WebhookDelivery) {
+		if d.Status != domain.WebhookDeliveryStatusPending {
+			return
+		}
+		d.Status = domain.WebhookDeliveryStatusDelivered
+		d.Attempts++
+		d.LastStatusCode = &statusCode
+		d.LastError = ""
+		d.DeliveredAt = &now
+	})
+	return nil
+}
+
+func (r *WebhookRepo) MarkWebhookDeliveryFailed(ctx context.Context, arg domain.MarkWebhookDeliveryFailedCommand) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.updateDelivery(arg.ID, func(d *domain.WebhookDelivery) {
+		if d.Status != domain.WebhookDeliveryStatusPending {
+			return
+		}
+		d.Status = arg.Status
+		d.Attempts++
+		d.LastStatusCode = arg.StatusCode
+		d.LastError = arg.LastError
+		d.NextAttemptAt = arg.NextAttemptAt
+	})
+	return nil
+}
+
+func (r *WebhookRepo) GetWebhookDeliveryByID(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := slices.IndexFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool { return d.ID == id && r.ofTenant(ctx, d) })
+	if i < 0 {
+		return nil, domain.ErrWebhookDeliveryNotFound
+	}
+	delivery := r.store.webhookDeliveries[i]
+	return &delivery, nil
+}
+
+// newestDeliveries returns the deliveries matching keep, newest first and after the cursor, must be called with the store lock held
+func (r *WebhookRepo) newestDeliveries(cursorID *int64, limit int32, keep func(d domain.WebhookDelivery) bool) []domain.WebhookDelivery {
+	list := make([]domain.WebhookDelivery, 0, limit)
+	for _, d := range slices.Backward(r.store.webhookDeliveries) {
+		if len(list) == int(limit) {
+			break
+		}
+		if (cursorID != nil && d.ID >= *cursorID) || !keep(d) {
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+func (r *WebhookRepo) ListWebhookDeliveries(ctx context.Context, arg domain.ListWebhookDeliveriesQuery) ([]domain.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.newestDeliveries(arg.CursorID, arg.LimitVal, func(d domain.WebhookDelivery) bool {
+		return d.SubscriptionID == arg.SubscriptionID && r.ofTenant(ctx, d) && (arg.Status == "" || d.Status == arg.Status)
+	}), nil
+}
+
+func (r *WebhookRepo) ListDeadWebhookDeliveries(ctx context.Context, cursorID *int64, limit int32) ([]domain.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.newestDeliveries(cursorID, limit, func(d domain.WebhookDelivery) bool {
+		return d.Status == domain.WebhookDeliveryStatusDead && r.ofTenant(ctx, d)
+	}), nil
+}
+
+func (r *WebhookRepo) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID int64) ([]domain.WebhookDeliveryAttempt, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var list []domain.WebhookDeliveryAttempt
+	for _, a := range r.store.webhookAttempts {
+		if a.DeliveryID == deliveryID {
+			list = append(list, a)
+		}
+	}
+	return list, nil
+}
+
+// RedeliverWebhookDelivery gives a dead delivery a fresh retry budget, its attempt log is kept
+func (r *WebhookRepo) RedeliverWebhookDelivery(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	i := slices.IndexFunc(r.store.webhookDeliveries, func(d domain.WebhookDelivery) bool {
+		return d.ID == id && d.Status == domain.WebhookDeliveryStatusDead && r.ofTenant(ctx, d)
+	})
+	if i < 0 {
+		return nil, domain.ErrWebhookDeliveryNotDead
+	}
+	delivery, _ := r.updateDelivery(id, func(d *domain.WebhookDelivery) {
+		d.Status = domain.WebhookDeliveryStatusPending
+		d.Attempts = 0
+		d.LastError = ""
+		d.NextAttemptAt = time.Now()
+	})
+	return delivery, nil
+}
@@ -0,0 +1,25 @@
+package publisher
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"log/slog"
+)
+
+// LogPublisher writes events to the application log, used when no downstream endpoint is configured
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	slog.InfoContext(ctx, "domain_event",
+		slog.Int64("event_id", event.ID),
+		slog.String("event_type", event.EventType),
+		slog.String("aggregate_type", event.AggregateType),
+		slog.Int64("aggregate_id", event.AggregateID),
+		slog.String("payload", string(event.Payload)),
+	)
+	return nil
+}
@@ -0,0 +1,27 @@
+package publisher
+
+import (
+	"billing-api/internal/domain"
+	"context"
+)
+
+/*
+MultiPublisher hands every event to several publishers in order and fails on the first error.
+The dispatcher then retries the whole event, so every publisher must tolerate duplicates.
+*/
+type MultiPublisher struct {
+	publishers []domain.EventPublisher
+}
+
+func NewMultiPublisher(publishers ...domain.EventPublisher) *MultiPublisher {
+	return &MultiPublisher{publishers: publishers}
+}
+
+func (p *MultiPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
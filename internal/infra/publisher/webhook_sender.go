@@ -0,0 +1,84 @@
+package publisher
+
+import (
+	"billing-api/internal/domain"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+/*
+SignWebhook computes the delivery signature, `v1=` followed by the hex encoded
+HMAC-SHA256 of "{timestamp}.{body}" keyed with the subscription secret.
+
+Receivers recompute it from the raw body and the timestamp header, and should reject
+timestamps too far in the past to protect against replays.
+*/
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSender posts the event envelope to the subscription URL, any non 2xx response is a failure
+type WebhookSender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	return &WebhookSender{
+		client: &http.Client{Timeout: timeout},
+		now:    time.Now,
+	}
+}
+
+func (s *WebhookSender) Send(ctx context.Context, delivery domain.DueWebhookDelivery) domain.WebhookResult {
+	body, err := json.Marshal(NewEnvelope(delivery.Event))
+	if err != nil {
+		return domain.WebhookResult{Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return domain.WebhookResult{Err: err}
+	}
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(delivery.Event.ID, 10))
+	req.Header.Set("X-Event-Type", delivery.Event.EventType)
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return domain.WebhookResult{Err: err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return domain.WebhookResult{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode),
+		}
+	}
+	return domain.WebhookResult{StatusCode: resp.StatusCode}
+}
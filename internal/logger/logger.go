@@ -2,11 +2,13 @@ package logger
 
 import (
 	"billing-api/internal/contextkey"
+	"billing-api/internal/domain"
 	"context"
 	"log/slog"
 	"os"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LogContextHandler struct {
@@ -17,6 +19,13 @@ func (l *LogContextHandler) Handle(ctx context.Context, r slog.Record) error {
 	if reqID := middleware.GetReqID(ctx); reqID != "" {
 		r.AddAttrs(slog.String(string(contextkey.RequestIDKey), reqID))
 	}
+	if p := domain.PrincipalFromContext(ctx); p != nil {
+		r.AddAttrs(slog.String(string(contextkey.PrincipalKey), p.Subject))
+	}
+	// the span of the record, to find the trace of a log line
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
 	return l.Handler.Handle(ctx, r)
 }
 
@@ -0,0 +1,91 @@
+/*
+Package metrics holds the Prometheus metrics of the API, served on /metrics in the text format.
+
+The metrics are package variables registered on Registry, like the transaction retry counters of the db
+package. Collectors reading a live dependency, the pool statistics for instance, are registered by main.
+*/
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "billing"
+
+// Registry holds every metric served on /metrics, with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration is labelled with the route pattern rather than the path, to keep the series bounded
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests by method, route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// RepoCallDuration and RepoTimeouts are labelled with the operation given to runWithTimeout
+	RepoCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_call_duration_seconds",
+		Help:      "Duration of the repository calls by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"operation"})
+	RepoTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repo_timeouts_total",
+		Help:      "Repository calls that exceeded their deadline, by operation.",
+	}, []string{"operation"})
+
+	LoansCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_created_total",
+		Help:      "Loans created, by tenant.",
+	}, []string{"tenant"})
+	PaymentsPosted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_posted_total",
+		Help:      "Payments posted, by tenant.",
+	}, []string{"tenant"})
+	DuplicatePayments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicate_payments_total",
+		Help:      "Payments rejected because their idempotency key was already processed, by tenant.",
+	}, []string{"tenant"})
+	DelinquentLoans = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_became_delinquent_total",
+		Help:      "Loans detected as delinquent by the delinquency monitor, by tenant.",
+	}, []string{"tenant"})
+
+	// RateLimitStoreFallbacks counts the buckets taken from the memory of the instance as the shared store failed
+	RateLimitStoreFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_store_fallbacks_total",
+		Help:      "Rate limit buckets taken from the instance memory because the shared store failed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		RepoCallDuration,
+		RepoTimeouts,
+		LoansCreated,
+		PaymentsPosted,
+		DuplicatePayments,
+		DelinquentLoans,
+		RateLimitStoreFallbacks,
+	)
+}
+
+// Handler serves the metrics of Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -3,6 +3,7 @@ package mocks
 import (
 	"billing-api/internal/domain"
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -24,12 +25,34 @@ func (m *MockBillingRepository) WithTx(ctx context.Context, fn func(repo domain.
 	return nil
 }
 
+// WithTxOptions works like WithTx, the options are passed to the expectation so tests can assert them
+func (m *MockBillingRepository) WithTxOptions(ctx context.Context, opts domain.TxOptions, fn func(repo domain.BillingRepository) error) error {
+	args := m.Called(ctx, opts, fn)
+
+	if args.Get(0) != nil {
+		return args.Error(0)
+	}
+	return nil
+}
+
 // GetLoanByID mocks the retrieval of a single loan.
 func (m *MockBillingRepository) GetLoanByID(ctx context.Context, id int64) (*domain.Loan, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*domain.Loan), args.Error(1)
 }
 
+// ListLoans mocks the paginated retrieval of loans.
+func (m *MockBillingRepository) ListLoans(ctx context.Context, cursorID *int64, borrowerID *string, limit int32) ([]domain.Loan, error) {
+	args := m.Called(ctx, cursorID, borrowerID, limit)
+	return args.Get(0).([]domain.Loan), args.Error(1)
+}
+
+// LockLoanForUpdate mocks the retrieval of a single loan with a row lock.
+func (m *MockBillingRepository) LockLoanForUpdate(ctx context.Context, id int64) (*domain.Loan, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.Loan), args.Error(1)
+}
+
 // GetTotalPaidAmount mocks the calculation of total amount paid for a loan.
 func (m *MockBillingRepository) GetTotalPaidAmount(ctx context.Context, loanID int64) (int64, error) {
 	args := m.Called(ctx, loanID)
@@ -60,6 +83,15 @@ func (m *MockBillingRepository) InsertPayment(ctx context.Context, arg domain.Cr
 	return args.Get(0).(*domain.Payment), args.Error(1)
 }
 
+// GetPaymentByIdempotencyKey mocks the lookup of a payment by its idempotency key.
+func (m *MockBillingRepository) GetPaymentByIdempotencyKey(ctx context.Context, idempotencyKey string) (*domain.Payment, error) {
+	args := m.Called(ctx, idempotencyKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Payment), args.Error(1)
+}
+
 // ListPaymentsByLoanID mocks the paginated retrieval of payments.
 func (m *MockBillingRepository) ListPaymentsByLoanID(ctx context.Context, arg domain.ListPaymentsQuery) ([]domain.Payment, error) {
 	args := m.Called(ctx, arg)
@@ -70,6 +102,21 @@ func (m *MockBillingRepository) ListPaymentsByLoanID(ctx context.Context, arg do
 	return args.Get(0).([]domain.Payment), args.Error(1)
 }
 
+// ListPaymentsByLoanIDInPeriod mocks the retrieval of payments within a statement period.
+func (m *MockBillingRepository) ListPaymentsByLoanIDInPeriod(ctx context.Context, arg domain.StatementPeriodQuery) ([]domain.Payment, error) {
+	args := m.Called(ctx, arg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Payment), args.Error(1)
+}
+
+// GetTotalPaidAmountBefore mocks the sum of payments made before a point in time.
+func (m *MockBillingRepository) GetTotalPaidAmountBefore(ctx context.Context, loanID int64, before time.Time) (int64, error) {
+	args := m.Called(ctx, loanID, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // CreateLoanSchedule mocks the creation of schedules
 func (m *MockBillingRepository) CreateLoanSchedules(ctx context.Context, arg []domain.LoanSchedule) (int64, error) {
 	args := m.Called(ctx, arg)
@@ -91,3 +138,32 @@ func (m *MockBillingRepository) UpdateSchedulePayment(ctx context.Context, arg d
 	args := m.Called(ctx, arg)
 	return args.Get(0).(int64), args.Error(1)
 }
+
+// ListUpcomingSchedules mocks the retrieval of the schedules due after a statement period
+func (m *MockBillingRepository) ListUpcomingSchedules(ctx context.Context, arg domain.UpcomingSchedulesQuery) ([]domain.LoanSchedule, error) {
+	args := m.Called(ctx, arg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.LoanSchedule), args.Error(1)
+}
+
+// InsertOutboxEvent mocks storing a domain event in the outbox
+func (m *MockBillingRepository) InsertOutboxEvent(ctx context.Context, arg domain.CreateOutboxEventCommand) (int64, error) {
+	args := m.Called(ctx, arg)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// ListNewlyDelinquentLoans mocks the detection of loans that crossed the delinquency threshold
+func (m *MockBillingRepository) ListNewlyDelinquentLoans(ctx context.Context, evaluatedAt time.Time, gapWeeks int32, limit int32) ([]domain.DelinquentLoan, error) {
+	args := m.Called(ctx, evaluatedAt, gapWeeks, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.DelinquentLoan), args.Error(1)
+}
+
+func (m *MockBillingRepository) InsertAuditEntry(ctx context.Context, arg domain.CreateAuditEntryCommand) error {
+	args := m.Called(ctx, arg)
+	return args.Error(0)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// audit snapshots, the JSON shape of the entities in the before and after states of the audit log
+
+type LoanAuditSnapshot struct {
+	ID                  int64   `json:"id"`
+	PrincipalAmount     int64   `json:"principal_amount"`
+	TotalPayableAmount  int64   `json:"total_payable_amount"`
+	WeeklyPaymentAmount int64   `json:"weekly_payment_amount"`
+	TotalWeeks          int     `json:"total_weeks"`
+	BorrowerID          *string `json:"borrower_id,omitempty"`
+}
+
+type PaymentAuditSnapshot struct {
+	ID         int64     `json:"id"`
+	LoanID     int64     `json:"loan_id"`
+	WeekNumber int       `json:"week_number"`
+	Amount     int64     `json:"amount"`
+	PaidAt     time.Time `json:"paid_at"`
+}
+
+// MandateAuditSnapshot only keeps the last digits of the account number
+type MandateAuditSnapshot struct {
+	ID            int64  `json:"id"`
+	LoanID        int64  `json:"loan_id"`
+	AccountHolder string `json:"account_holder"`
+	BankCode      string `json:"bank_code"`
+	AccountNumber string `json:"account_number"`
+	Reference     string `json:"reference"`
+	Status        string `json:"status"`
+}
+
+type CollectionBatchAuditSnapshot struct {
+	ID             int64  `json:"id"`
+	CollectionDate string `json:"collection_date"`
+	Status         string `json:"status"`
+	ItemCount      int    `json:"item_count"`
+	TotalAmount    int64  `json:"total_amount"`
+}
+
+// WebhookSubscriptionAuditSnapshot leaves out the signing secret
+type WebhookSubscriptionAuditSnapshot struct {
+	ID         int64    `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Status     string   `json:"status"`
+}
+
+type WebhookDeliveryAuditSnapshot struct {
+	ID             int64  `json:"id"`
+	SubscriptionID int64  `json:"subscription_id"`
+	EventID        int64  `json:"event_id"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+}
+
+// APIKeyAuditSnapshot leaves out the hash of the secret
+type APIKeyAuditSnapshot struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	TenantID    string     `json:"tenant_id"`
+	Scopes      []string   `json:"scopes"`
+	Roles       []string   `json:"roles"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RotatedFrom *int64     `json:"rotated_from,omitempty"`
+}
+
+type LogLevelAuditSnapshot struct {
+	Level string `json:"level"`
+}
+
+func loanAuditSnapshot(l *domain.Loan) LoanAuditSnapshot {
+	return LoanAuditSnapshot{
+		ID:                  l.ID,
+		PrincipalAmount:     l.PrincipalAmount,
+		TotalPayableAmount:  l.TotalPayableAmount,
+		WeeklyPaymentAmount: l.WeeklyPaymentAmount,
+		TotalWeeks:          l.TotalWeeks,
+		BorrowerID:          l.BorrowerID,
+	}
+}
+
+func paymentAuditSnapshot(p *domain.Payment) PaymentAuditSnapshot {
+	return PaymentAuditSnapshot{
+		ID:         p.ID,
+		LoanID:     p.LoanID,
+		WeekNumber: p.WeekNumber,
+		Amount:     p.Amount,
+		PaidAt:     p.PaidAt,
+	}
+}
+
+func mandateAuditSnapshot(m *domain.Mandate) MandateAuditSnapshot {
+	masked := m.AccountNumber
+	if n := len(masked); n > 4 {
+		masked = strings.Repeat("*", n-4) + masked[n-4:]
+	}
+	return MandateAuditSnapshot{
+		ID:            m.ID,
+		LoanID:        m.LoanID,
+		AccountHolder: m.AccountHolder,
+		BankCode:      m.BankCode,
+		AccountNumber: masked,
+		Reference:     m.Reference,
+		Status:        m.Status,
+	}
+}
+
+func collectionBatchAuditSnapshot(b *domain.CollectionBatch) CollectionBatchAuditSnapshot {
+	return CollectionBatchAuditSnapshot{
+		ID:             b.ID,
+		CollectionDate: b.CollectionDate.Format(time.DateOnly),
+		Status:         b.Status,
+		ItemCount:      b.ItemCount,
+		TotalAmount:    b.TotalAmount,
+	}
+}
+
+func webhookSubscriptionAuditSnapshot(s *domain.WebhookSubscription) WebhookSubscriptionAuditSnapshot {
+	return WebhookSubscriptionAuditSnapshot{
+		ID:         s.ID,
+		URL:        s.URL,
+		EventTypes: s.EventTypes,
+		Status:     s.Status,
+	}
+}
+
+func webhookDeliveryAuditSnapshot(d *domain.WebhookDelivery) WebhookDeliveryAuditSnapshot {
+	return WebhookDeliveryAuditSnapshot{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+	}
+}
+
+func apiKeyAuditSnapshot(k *domain.APIKey) APIKeyAuditSnapshot {
+	return APIKeyAuditSnapshot{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		TenantID:    k.TenantID,
+		Scopes:      k.Scopes,
+		Roles:       k.Roles,
+		ExpiresAt:   k.ExpiresAt,
+		RevokedAt:   k.RevokedAt,
+		RotatedFrom: k.RotatedFrom,
+	}
+}
+
+/*
+recordAudit appends the change to the audit log through w, the (transactional) repository of the change,
+so the entry is committed or rolled back together with it. A nil before or after snapshot is stored as null.
+*/
+func recordAudit(ctx context.Context, w domain.AuditWriter, action, entityType string, entityID int64, before, after any) error {
+	cmd, err := domain.NewAuditCommand(ctx, action, entityType, strconv.FormatInt(entityID, 10), before, after)
+	if err != nil {
+		return fmt.Errorf("audit %s: %w", action, err)
+	}
+	return w.InsertAuditEntry(ctx, cmd)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+)
+
+// auditChainPageSize is how many entries VerifyChain reads at once
+const auditChainPageSize = 500
+
+type AuditCursor struct {
+	ID int64
+}
+
+type AuditService struct {
+	repo domain.AuditRepository
+}
+
+func NewAuditService(repo domain.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+/*
+Record appends a change made outside of the database, like the log level, to the audit log.
+The changes of the repositories are audited in their own transactions.
+*/
+func (s *AuditService) Record(ctx context.Context, action, entityType, entityID string, before, after any) error {
+	cmd, err := domain.NewAuditCommand(ctx, action, entityType, entityID, before, after)
+	if err != nil {
+		return fmt.Errorf("audit %s: %w", action, err)
+	}
+	return s.repo.InsertAuditEntry(ctx, cmd)
+}
+
+// List returns the entries of the tenant newest first, the filters of query narrow them down
+func (s *AuditService) List(ctx context.Context, query domain.ListAuditEntriesQuery, cursor *AuditCursor) ([]domain.AuditEntry, *AuditCursor, error) {
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, nil, fmt.Errorf("%w: from must be before to", domain.ErrInvalidAuditQuery)
+	}
+	if cursor != nil {
+		query.CursorID = &cursor.ID
+	}
+
+	entries, err := s.repo.ListAuditEntries(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) < int(query.Limit) || len(entries) == 0 {
+		return entries, nil, nil
+	}
+	return entries, &AuditCursor{ID: entries[len(entries)-1].ID}, nil
+}
+
+/*
+VerifyChain walks the hash chain of the tenant from its first entry and stops at the first broken link:
+an entry whose hash does not match its content, or that does not point to the hash of the entry before it.
+*/
+func (s *AuditService) VerifyChain(ctx context.Context) (*domain.AuditChainVerification, error) {
+	result := &domain.AuditChainVerification{Valid: true}
+	var afterID int64
+	prevHash := ""
+	for {
+		entries, err := s.repo.ListAuditChain(ctx, afterID, auditChainPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			reason := ""
+			switch {
+			case e.PrevHash != prevHash:
+				reason = "prev_hash does not match the hash of the previous entry"
+			case e.ComputeHash() != e.Hash:
+				reason = "hash does not match the content of the entry"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAtID = &e.ID
+				result.Reason = reason
+				return result, nil
+			}
+			result.Entries++
+			prevHash = e.Hash
+			afterID = e.ID
+		}
+		if len(entries) < auditChainPageSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/memory"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tamperedAuditRepo rewrites the chain on its way out, like an UPDATE or DELETE made behind the API
+type tamperedAuditRepo struct {
+	domain.AuditRepository
+	tamper func(entries []domain.AuditEntry) []domain.AuditEntry
+}
+
+func (r tamperedAuditRepo) ListAuditChain(ctx context.Context, afterID int64, limit int32) ([]domain.AuditEntry, error) {
+	entries, err := r.AuditRepository.ListAuditChain(ctx, afterID, limit)
+	return r.tamper(entries), err
+}
+
+func TestAuditService_VerifyChain(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	repo := memory.NewAuditRepo(store)
+	svc := NewAuditService(repo)
+
+	// the mandates of a loan are audited in the transaction of the change, a failed one leaves no entry
+	collection := NewCollectionService(memory.NewCollectionRepo(store), NewBillingService(nil, memory.NewBillingRepo(store), nil, nil), NewRetryPolicy(nil, nil))
+	loan, err := collection.billingService.SubmitLoan(ctx, SubmitLoanInput{PrincipalAmount: 1000, TotalWeeks: 10, StartDate: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	mandate := CreateMandateInput{LoanID: loan.ID, AccountHolder: "Jane Doe", BankCode: "BCA", AccountNumber: "1234567890", Reference: "MDT-1"}
+	_, err = collection.CreateMandate(ctx, mandate)
+	require.NoError(t, err)
+	_, err = collection.CreateMandate(ctx, mandate)
+	require.ErrorIs(t, err, domain.ErrMandateAlreadyActive)
+	require.NoError(t, svc.Record(ctx, domain.AuditActionLogLevelChange, domain.AuditEntityLogLevel, "root", LogLevelAuditSnapshot{Level: "INFO"}, LogLevelAuditSnapshot{Level: "DEBUG"}))
+
+	entries, next, err := svc.List(ctx, domain.ListAuditEntriesQuery{Limit: 10}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{domain.AuditActionLogLevelChange, domain.AuditActionMandateCreate, domain.AuditActionLoanCreate},
+		[]string{entries[0].Action, entries[1].Action, entries[2].Action})
+	assert.Equal(t, domain.AuditActorSystem, entries[0].Actor)
+	assert.Equal(t, entries[1].Hash, entries[0].PrevHash)
+	assert.Empty(t, entries[2].PrevHash)
+
+	result, err := svc.VerifyChain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.AuditChainVerification{Valid: true, Entries: 3}, result)
+
+	// another tenant starts a chain of its own
+	other := domain.ContextWithTenant(ctx, "acme")
+	require.NoError(t, svc.Record(other, domain.AuditActionLogLevelChange, domain.AuditEntityLogLevel, "root", nil, LogLevelAuditSnapshot{Level: "WARN"}))
+	entries, _, err = svc.List(other, domain.ListAuditEntriesQuery{Limit: 10}, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Empty(t, entries[0].PrevHash)
+
+	tests := []struct {
+		name   string
+		tamper func(entries []domain.AuditEntry) []domain.AuditEntry
+		reason string
+	}{
+		{"changed content", func(entries []domain.AuditEntry) []domain.AuditEntry {
+			entries[1].After = json.RawMessage(`{"status":"REVOKED"}`)
+			return entries
+		}, "hash does not match the content of the entry"},
+		{"removed entry", func(entries []domain.AuditEntry) []domain.AuditEntry {
+			return entries[1:]
+		}, "prev_hash does not match the hash of the previous entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewAuditService(tamperedAuditRepo{repo, tt.tamper}).VerifyChain(ctx)
+			require.NoError(t, err)
+			assert.False(t, result.Valid)
+			assert.Equal(t, tt.reason, result.Reason)
+			require.NotNil(t, result.BrokenAtID)
+		})
+	}
+
+	_, _, err = svc.List(ctx, domain.ListAuditEntriesQuery{Limit: 10, From: &entries[0].OccurredAt, To: &entries[0].OccurredAt}, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidAuditQuery)
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyScheme       = "bk_"
+	apiKeyPrefixLength = 16 // hex characters
+	apiKeyMinSecret    = 32
+
+	// last_used_at is written at most once per interval per key, not on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+// Credentials are the values a caller authenticates with, as sent in the headers or the gRPC metadata
+type Credentials struct {
+	Authorization string // "Bearer <token>", the token is a JWT or an API key
+	APIKey        string // X-API-Key
+}
+
+/*
+AuthService authenticates the callers of the REST and gRPC APIs and manages the API keys.
+
+An API key looks like bk_<prefix>_<secret>: the prefix is stored in clear to find the key, only the
+sha256 of the whole key is stored. A rotation issues a new key with the same name and scopes and lets
+the old one live on for a grace period so the callers can be switched over.
+Bearer tokens that are not API keys are verified as JWTs, when a verifier is configured.
+The roles of the caller, stored with the key or carried by the JWT, are resolved to permissions by the policy.
+Every caller acts for a tenant, the one of its key or the tenant_id claim of its JWT, which must be served by the deployment.
+*/
+type AuthService struct {
+	repo    domain.APIKeyRepository
+	jwt     *JWTVerifier
+	policy  *Policy
+	tenants *Tenants
+	now     func() time.Time
+}
+
+// NewAuthService jwt may be nil, bearer JWTs are then rejected, and tenants nil to serve the default tenant only
+func NewAuthService(repo domain.APIKeyRepository, jwt *JWTVerifier, policy *Policy, tenants *Tenants) *AuthService {
+	if tenants == nil {
+		tenants = DefaultTenants(defaultTenantSettings)
+	}
+	return &AuthService{
+		repo:    repo,
+		jwt:     jwt,
+		policy:  policy,
+		tenants: tenants,
+		now:     time.Now,
+	}
+}
+
+// Authenticate returns the caller presenting the credentials, or an error wrapping domain.ErrUnauthenticated
+func (s *AuthService) Authenticate(ctx context.Context, creds Credentials) (*domain.Principal, error) {
+	principal, err := s.authenticate(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	if principal.TenantID == "" {
+		principal.TenantID = domain.DefaultTenantID
+	}
+	if !s.tenants.Has(principal.TenantID) {
+		return nil, fmt.Errorf("%w: unknown tenant %q", domain.ErrUnauthenticated, principal.TenantID)
+	}
+	principal.Permissions = s.policy.Permissions(principal.Roles)
+	return principal, nil
+}
+
+func (s *AuthService) authenticate(ctx context.Context, creds Credentials) (*domain.Principal, error) {
+	if creds.APIKey != "" {
+		return s.authenticateAPIKey(ctx, creds.APIKey)
+	}
+	if creds.Authorization == "" {
+		return nil, fmt.Errorf("%w: no credentials", domain.ErrUnauthenticated)
+	}
+
+	scheme, token, _ := strings.Cut(creds.Authorization, " ")
+	token = strings.TrimSpace(token)
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: unsupported authorization scheme", domain.ErrUnauthenticated)
+	}
+	if strings.HasPrefix(token, apiKeyScheme) {
+		return s.authenticateAPIKey(ctx, token)
+	}
+	if s.jwt == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", domain.ErrUnauthenticated)
+	}
+	principal, err := s.jwt.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrUnauthenticated, err)
+	}
+	return principal, nil
+}
+
+func (s *AuthService) authenticateAPIKey(ctx context.Context, key string) (*domain.Principal, error) {
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed API key", domain.ErrUnauthenticated)
+	}
+	stored, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown API key %s", domain.ErrUnauthenticated, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(stored.Hash)) != 1 {
+		return nil, fmt.Errorf("%w: wrong secret for API key %s", domain.ErrUnauthenticated, prefix)
+	}
+	now := s.now()
+	if !stored.Active(now) {
+		return nil, fmt.Errorf("%w: API key %s is revoked or expired", domain.ErrUnauthenticated, prefix)
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= apiKeyTouchInterval {
+		// the request goes on when the usage can not be recorded
+		if err := s.repo.TouchAPIKey(context.WithoutCancel(ctx), stored.ID, now); err != nil {
+			slog.WarnContext(ctx, "api_key_touch_failed", slog.String("prefix", prefix), slog.Any("err", err))
+		}
+	}
+
+	return &domain.Principal{
+		Kind:     domain.PrincipalKindAPIKey,
+		Subject:  "api_key:" + prefix,
+		TenantID: stored.TenantID,
+		Scopes:   stored.Scopes,
+		Roles:    stored.Roles,
+	}, nil
+}
+
+/*
+CreateAPIKey issues a new key. The key itself is only returned here, the caller must hand it over
+to its owner as it can not be retrieved later.
+The key belongs to the tenant of the caller, only the callers of the default tenant issue keys for the other tenants.
+*/
+func (s *AuthService) CreateAPIKey(ctx context.Context, input CreateAPIKeyInput) (*domain.APIKey, string, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, "", fmt.Errorf("%w: name is required", domain.ErrInvalidAPIKey)
+	}
+	if len(input.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", domain.ErrInvalidAPIKey)
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(domain.Scopes, scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", domain.ErrInvalidAPIKey, scope)
+		}
+	}
+	if len(input.Roles) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one role is required", domain.ErrInvalidAPIKey)
+	}
+	for _, role := range input.Roles {
+		if !s.policy.HasRole(role) {
+			return nil, "", fmt.Errorf("%w: unknown role %q", domain.ErrInvalidAPIKey, role)
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(s.now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", domain.ErrInvalidAPIKey)
+	}
+	callerTenant := domain.TenantFromContext(ctx)
+	tenantID := cmp.Or(input.TenantID, callerTenant)
+	if tenantID != callerTenant && callerTenant != domain.DefaultTenantID {
+		return nil, "", fmt.Errorf("%w: keys of tenant %q can only be issued from the tenant itself", domain.ErrPermissionDenied, tenantID)
+	}
+	if !s.tenants.Has(tenantID) {
+		return nil, "", fmt.Errorf("%w: unknown tenant %q", domain.ErrInvalidAPIKey, tenantID)
+	}
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	var stored *domain.APIKey
+	err = s.repo.WithTx(ctx, func(repo domain.APIKeyRepository) error {
+		stored, err = repo.InsertAPIKey(ctx, domain.CreateAPIKeyCommand{
+			Name:      input.Name,
+			Prefix:    prefix,
+			Hash:      hashAPIKey(key),
+			Scopes:    slices.Compact(slices.Sorted(slices.Values(input.Scopes))),
+			Roles:     slices.Compact(slices.Sorted(slices.Values(input.Roles))),
+			TenantID:  tenantID,
+			ExpiresAt: input.ExpiresAt,
+			CreatedAt: s.now(),
+		})
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, repo, domain.AuditActionAPIKeyCreate, domain.AuditEntityAPIKey, stored.ID, nil, apiKeyAuditSnapshot(stored))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return stored, key, nil
+}
+
+/*
+RotateAPIKey replaces an active key by a new one with the same name, scopes, roles and expiry.
+The old key keeps working for the grace period, a zero grace period disables it at once.
+*/
+func (s *AuthService) RotateAPIKey(ctx context.Context, id int64, gracePeriod time.Duration) (*domain.APIKey, string, error) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rotated *domain.APIKey
+	err = s.repo.WithTx(ctx, func(repo domain.APIKeyRepository) error {
+		now := s.now()
+		old, err := repo.GetAPIKeyByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !old.Active(now) {
+			return fmt.Errorf("%w: API key %d is revoked or expired", domain.ErrAPIKeyNotFound, id)
+		}
+
+		rotated, err = repo.InsertAPIKey(ctx, domain.CreateAPIKeyCommand{
+			Name:        old.Name,
+			Prefix:      prefix,
+			Hash:        hashAPIKey(key),
+			Scopes:      old.Scopes,
+			Roles:       old.Roles,
+			TenantID:    old.TenantID,
+			ExpiresAt:   old.ExpiresAt,
+			RotatedFrom: &old.ID,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := repo.ExpireAPIKey(ctx, old.ID, now.Add(gracePeriod)); err != nil {
+			return err
+		}
+		// the entry of the old key, its after state is the key replacing it
+		return recordAudit(ctx, repo, domain.AuditActionAPIKeyRotate, domain.AuditEntityAPIKey, old.ID, apiKeyAuditSnapshot(old), apiKeyAuditSnapshot(rotated))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return rotated, key, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey disables a key immediately
+func (s *AuthService) RevokeAPIKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	var revoked *domain.APIKey
+	err := s.repo.WithTx(ctx, func(repo domain.APIKeyRepository) error {
+		before, err := repo.GetAPIKeyByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		revoked, err = repo.RevokeAPIKey(ctx, id, s.now())
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, repo, domain.AuditActionAPIKeyRevoke, domain.AuditEntityAPIKey, id, apiKeyAuditSnapshot(before), apiKeyAuditSnapshot(revoked))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+/*
+EnsureAPIKey stores a key chosen by the operator unless it already exists, it lets a fresh deployment
+bootstrap its first admin key from the configuration. The key belongs to the default tenant and should be rotated once in use.
+*/
+func (s *AuthService) EnsureAPIKey(ctx context.Context, name, key string, scopes, roles []string) error {
+	prefix, ok := parseAPIKey(key)
+	if !ok {
+		return fmt.Errorf("%w: expected %s<%d hex characters>_<secret of at least %d characters>", domain.ErrInvalidAPIKey, apiKeyScheme, apiKeyPrefixLength, apiKeyMinSecret)
+	}
+	_, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if !errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return err
+	}
+	_, err = s.repo.InsertAPIKey(ctx, domain.CreateAPIKeyCommand{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashAPIKey(key),
+		Scopes:    scopes,
+		Roles:     roles,
+		TenantID:  domain.DefaultTenantID,
+		CreatedAt: s.now(),
+	})
+	return err
+}
+
+func generateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, apiKeyPrefixLength/2+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b[:apiKeyPrefixLength/2])
+	return apiKeyScheme + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[apiKeyPrefixLength/2:]), prefix, nil
+}
+
+// parseAPIKey returns the prefix of a well-formed key
+func parseAPIKey(key string) (string, bool) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyScheme), "_")
+	if !ok || !strings.HasPrefix(key, apiKeyScheme) || len(prefix) != apiKeyPrefixLength || len(secret) < apiKeyMinSecret {
+		return "", false
+	}
+	if _, err := hex.DecodeString(prefix); err != nil {
+		return "", false
+	}
+	return prefix, true
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/memory"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthService(jwtVerifier *JWTVerifier) (*AuthService, *time.Time) {
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	s := NewAuthService(memory.NewAPIKeyRepo(memory.NewStore()), jwtVerifier, DefaultPolicy(), nil)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestAuthService_APIKeyLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestAuthService(nil)
+
+	created, key, err := s.CreateAPIKey(ctx, CreateAPIKeyInput{Name: "reporting", Scopes: []string{domain.ScopeRead, domain.ScopeRead}, Roles: []string{RoleAgent}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{domain.ScopeRead}, created.Scopes)
+	assert.NotEqual(t, key, created.Hash)
+
+	principal, err := s.Authenticate(ctx, Credentials{APIKey: key})
+	require.NoError(t, err)
+	assert.Equal(t, "api_key:"+created.Prefix, principal.Subject)
+	assert.True(t, principal.HasScope(domain.ScopeRead))
+	assert.False(t, principal.HasScope(domain.ScopeWrite))
+	assert.True(t, principal.Can(domain.PermissionLoanCreate))
+	assert.False(t, principal.Can(domain.PermissionConfigManage))
+
+	_, err = s.Authenticate(ctx, Credentials{Authorization: "Bearer " + key})
+	require.NoError(t, err)
+
+	t.Run("last use is recorded", func(t *testing.T) {
+		keys, err := s.ListAPIKeys(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, keys[0].LastUsedAt)
+		assert.Equal(t, *now, *keys[0].LastUsedAt)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		for _, creds := range []Credentials{
+			{},
+			{APIKey: key[:len(key)-1] + "x"},
+			{APIKey: "bk_short"},
+			{Authorization: "Basic " + key},
+			{Authorization: "Bearer some.jwt.token"},
+		} {
+			_, err := s.Authenticate(ctx, creds)
+			assert.ErrorIs(t, err, domain.ErrUnauthenticated, creds)
+		}
+	})
+
+	t.Run("invalid keys are not created", func(t *testing.T) {
+		past := now.Add(-time.Hour)
+		for _, input := range []CreateAPIKeyInput{
+			{Scopes: []string{domain.ScopeRead}, Roles: []string{RoleAgent}},
+			{Name: "none", Roles: []string{RoleAgent}},
+			{Name: "unknown", Scopes: []string{"delete"}, Roles: []string{RoleAgent}},
+			{Name: "no role", Scopes: []string{domain.ScopeRead}},
+			{Name: "unknown role", Scopes: []string{domain.ScopeRead}, Roles: []string{"auditor"}},
+			{Name: "expired", Scopes: []string{domain.ScopeRead}, Roles: []string{RoleAgent}, ExpiresAt: &past},
+		} {
+			_, _, err := s.CreateAPIKey(ctx, input)
+			assert.ErrorIs(t, err, domain.ErrInvalidAPIKey, input)
+		}
+	})
+
+	t.Run("the old key works during the grace period of a rotation", func(t *testing.T) {
+		rotated, newKey, err := s.RotateAPIKey(ctx, created.ID, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, *rotated.RotatedFrom)
+		assert.Equal(t, created.Name, rotated.Name)
+		assert.Equal(t, created.Roles, rotated.Roles)
+
+		_, err = s.Authenticate(ctx, Credentials{APIKey: key})
+		require.NoError(t, err)
+		_, err = s.Authenticate(ctx, Credentials{APIKey: newKey})
+		require.NoError(t, err)
+
+		*now = now.Add(time.Hour)
+		_, err = s.Authenticate(ctx, Credentials{APIKey: key})
+		assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+		_, _, err = s.RotateAPIKey(ctx, created.ID, 0)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+
+		revoked, err := s.RevokeAPIKey(ctx, rotated.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+		_, err = s.Authenticate(ctx, Credentials{APIKey: newKey})
+		assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+		_, err = s.RevokeAPIKey(ctx, rotated.ID)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+	})
+
+	t.Run("the bootstrap key is stored once", func(t *testing.T) {
+		bootstrap := "bk_0123456789abcdef_bootstrap-secret-of-32-characters"
+		require.NoError(t, s.EnsureAPIKey(ctx, "bootstrap", bootstrap, []string{domain.ScopeAdmin}, []string{RoleAdmin}))
+		require.NoError(t, s.EnsureAPIKey(ctx, "bootstrap", bootstrap, []string{domain.ScopeAdmin}, []string{RoleAdmin}))
+		principal, err := s.Authenticate(ctx, Credentials{APIKey: bootstrap})
+		require.NoError(t, err)
+		assert.True(t, principal.HasScope(domain.ScopeWrite))
+
+		assert.ErrorIs(t, s.EnsureAPIKey(ctx, "bootstrap", "secret", nil, nil), domain.ErrInvalidAPIKey)
+	})
+}
+
+func TestAuthService_JWT(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("test-secret")
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	verifier, err := NewJWTVerifier(JWTVerifierOptions{HMACSecret: secret, Issuer: "https://idp.example.com", Audience: "billing-api"})
+	require.NoError(t, err)
+	s, _ := newTestAuthService(verifier)
+	valid := jwt.MapClaims{
+		"sub":   "alice",
+		"scope": "read write",
+		"roles": []string{RoleBorrower},
+		"iss":   "https://idp.example.com",
+		"aud":   "billing-api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+
+	principal, err := s.Authenticate(ctx, Credentials{Authorization: sign(valid)})
+	require.NoError(t, err)
+	assert.Equal(t, domain.PrincipalKindJWT, principal.Kind)
+	assert.Equal(t, "alice", principal.Subject)
+	assert.Equal(t, []string{domain.ScopeRead, domain.ScopeWrite}, principal.Scopes)
+	assert.Equal(t, []string{domain.PermissionLoanReadOwn}, principal.Permissions)
+
+	for name, change := range map[string]jwt.MapClaims{
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+		"no expiry":      {"exp": nil},
+		"other issuer":   {"iss": "https://other.example.com"},
+		"other audience": {"aud": "other"},
+		"no subject":     {"sub": nil},
+	} {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		for k, v := range change {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		_, err := s.Authenticate(ctx, Credentials{Authorization: sign(claims)})
+		assert.ErrorIs(t, err, domain.ErrUnauthenticated, name)
+	}
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	writeJWKS := func(path string, keys map[string]*rsa.PrivateKey) {
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, key := range keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		data, err := json.Marshal(set)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+	sign := func(kid string, key *rsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(path, map[string]*rsa.PrivateKey{"k1": first})
+
+	verifier, err := NewJWTVerifier(JWTVerifierOptions{JWKSFile: path})
+	require.NoError(t, err)
+	principal, err := verifier.Verify(sign("k1", first))
+	require.NoError(t, err)
+	assert.Equal(t, "svc", principal.Subject)
+
+	_, err = verifier.Verify(sign("k2", second))
+	assert.ErrorContains(t, err, `unknown key id "k2"`)
+	_, err = verifier.Verify(sign("k1", second))
+	assert.Error(t, err)
+
+	// the key rotated by the provider is picked up once the file changed
+	writeJWKS(path, map[string]*rsa.PrivateKey{"k1": first, "k2": second})
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, err = verifier.Verify(sign("k2", second))
+	require.NoError(t, err)
+
+	_, err = NewJWTVerifier(JWTVerifierOptions{})
+	assert.Error(t, err)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{RoleAdmin, RoleAgent, RoleBorrower, RoleFinance}, policy.Roles())
+	assert.Equal(t, []string{domain.PermissionLoanCreate, domain.PermissionLoanRead, domain.PermissionLoanReadOwn, domain.PermissionMandateManage, domain.PermissionPaymentCreate},
+		policy.Permissions([]string{RoleAgent, RoleBorrower, "unknown"}))
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"roles": {"auditor": ["loan:read"]}}`), 0o600))
+	policy, err = LoadPolicy(path)
+	require.NoError(t, err)
+	assert.True(t, policy.HasRole("auditor"))
+	assert.False(t, policy.HasRole(RoleAdmin))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"roles": {"auditor": ["loan:raed"]}}`), 0o600))
+	_, err = LoadPolicy(path)
+	assert.ErrorContains(t, err, `unknown permission "loan:raed"`)
+	require.NoError(t, os.WriteFile(path, []byte(`{"roles": {}}`), 0o600))
+	_, err = LoadPolicy(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/metrics"
+	"billing-api/internal/tracing"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// event payloads, the JSON shape is the public contract for downstream consumers
+
+type LoanCreatedPayload struct {
+	LoanID              int64  `json:"loan_id"`
+	PrincipalAmount     int64  `json:"principal_amount"`
+	TotalPayableAmount  int64  `json:"total_payable_amount"`
+	WeeklyPaymentAmount int64  `json:"weekly_payment_amount"`
+	TotalWeeks          int    `json:"total_weeks"`
+	StartDate           string `json:"start_date"`
+}
+
+type PaymentReceivedPayload struct {
+	LoanID     int64     `json:"loan_id"`
+	PaymentID  int64     `json:"payment_id"`
+	WeekNumber int       `json:"week_number"`
+	Amount     int64     `json:"amount"`
+	PaidAt     time.Time `json:"paid_at"`
+}
+
+type ScheduleInstallmentPaidPayload struct {
+	LoanID   int64 `json:"loan_id"`
+	Sequence int   `json:"sequence"`
+	Amount   int64 `json:"amount"`
+}
+
+type LoanPaidOffPayload struct {
+	LoanID    int64     `json:"loan_id"`
+	TotalPaid int64     `json:"total_paid"`
+	PaidOffAt time.Time `json:"paid_off_at"`
+}
+
+type LoanBecameDelinquentPayload struct {
+	LoanID       int64     `json:"loan_id"`
+	LastPaidWeek int       `json:"last_paid_week"`
+	ExpectedWeek int       `json:"expected_week"`
+	DetectedAt   time.Time `json:"detected_at"`
+}
+
+/*
+loanEvents collects the loan events recorded within a transaction.
+
+Every event is stored in the outbox through the given (transactional) repository, so it is committed or rolled back
+together with the change that caused it. The collected events are only announced on the event bus after the commit.
+*/
+type loanEvents []domain.OutboxEvent
+
+func (e *loanEvents) record(ctx context.Context, repo domain.BillingRepository, loanID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	cmd := domain.CreateOutboxEventCommand{
+		AggregateType: domain.AggregateTypeLoan,
+		AggregateID:   loanID,
+		EventType:     eventType,
+		Payload:       data,
+	}
+	id, err := repo.InsertOutboxEvent(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	*e = append(*e, domain.OutboxEvent{
+		ID:            id,
+		TenantID:      domain.TenantFromContext(ctx),
+		AggregateType: cmd.AggregateType,
+		AggregateID:   cmd.AggregateID,
+		EventType:     cmd.EventType,
+		Payload:       cmd.Payload,
+		CreatedAt:     time.Now(),
+	})
+	return nil
+}
+
+// max loans flagged per detection round, the next round picks up the rest
+const delinquencyDetectionBatchSize = 500
+
+/*
+DetectDelinquentLoans emits a LoanBecameDelinquent event for every loan that crossed the delinquency threshold.
+
+Only the loans of the tenant ctx is scoped to are checked, against its own delinquency gap.
+Delinquency stays a derived state (see ADR-002), the outbox itself is used to remember whether the transition
+was already announced: a loan is only flagged again after a new payment was made since its last event.
+*/
+func (s *BillingService) DetectDelinquentLoans(ctx context.Context, now time.Time) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.DetectDelinquentLoans")
+	defer func() { tracing.End(span, err) }()
+	var events loanEvents
+	gapWeeks := int32(s.TenantSettings(ctx).DelinquencyGapWeeks)
+	err = s.repo.WithTx(ctx, func(repo domain.BillingRepository) error {
+		events = nil
+		loans, err := repo.ListNewlyDelinquentLoans(ctx, now, gapWeeks, delinquencyDetectionBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, l := range loans {
+			err := events.record(ctx, repo, l.LoanID, domain.EventLoanBecameDelinquent, LoanBecameDelinquentPayload{
+				LoanID:       l.LoanID,
+				LastPaidWeek: l.LastPaidWeek,
+				ExpectedWeek: l.ExpectedWeek,
+				DetectedAt:   now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	metrics.DelinquentLoans.WithLabelValues(domain.TenantFromContext(ctx)).Add(float64(len(events)))
+	s.bus.Publish(events...)
+	return len(events), nil
+}
@@ -7,6 +7,7 @@ type SubmitLoanInput struct {
 	AnnualInterestRate float64 // e.g. 0.10
 	TotalWeeks         int
 	StartDate          time.Time
+	BorrowerID         string // optional, subject of the borrower allowed to read the loan
 }
 
 type SubmitPaymentInput struct {
@@ -14,4 +15,37 @@ type SubmitPaymentInput struct {
 	Amount         int64
 	PaidAt         time.Time
 	IdempotencyKey string // Sent from Frontend Header
+	Sequence       int32  // the installment a collected debit was made for, zero pays the next unpaid one
+}
+
+type CreateMandateInput struct {
+	LoanID        int64
+	AccountHolder string
+	BankCode      string
+	AccountNumber string
+	Reference     string
+}
+
+type CollectionImportSummary struct {
+	BatchID        int64
+	Succeeded      int
+	RetryScheduled int
+	Failed         int
+	Skipped        int
+	Unposted       int // debited by the bank but the payment could not be posted, see CollectionItemStatusUnposted
+	Pending        int // submitted items the file has no result for, the batch stays open until a later file settles them
+}
+
+type CreateWebhookSubscriptionInput struct {
+	URL        string
+	EventTypes []string
+	Secret     string // optional, generated when empty
+}
+
+type CreateAPIKeyInput struct {
+	Name      string
+	Scopes    []string
+	Roles     []string   // roles of the RBAC policy
+	ExpiresAt *time.Time // optional, the key never expires when nil
+	TenantID  string     // optional, the tenant of the caller when empty
 }
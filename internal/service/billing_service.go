@@ -2,31 +2,53 @@ package service
 
 import (
 	"billing-api/internal/domain"
+	"billing-api/internal/metrics"
+	"billing-api/internal/tracing"
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type BillingService struct {
-	pool *pgxpool.Pool
-	repo domain.BillingRepository
+	pool    *pgxpool.Pool
+	repo    domain.BillingRepository
+	bus     *EventBus
+	tenants *Tenants
 }
 
-// constructor
-func NewBillingService(pool *pgxpool.Pool, repo domain.BillingRepository) *BillingService {
+// constructor, bus may be nil when nothing listens to in-process events and tenants nil to serve the default tenant only
+func NewBillingService(pool *pgxpool.Pool, repo domain.BillingRepository, bus *EventBus, tenants *Tenants) *BillingService {
+	if tenants == nil {
+		tenants = DefaultTenants(defaultTenantSettings)
+	}
 	return &BillingService{
-		pool: pool,
-		repo: repo,
+		pool:    pool,
+		repo:    repo,
+		bus:     bus,
+		tenants: tenants,
 	}
 }
 
+// Tenants returns the tenants served by the deployment
+func (s *BillingService) Tenants() *Tenants {
+	return s.tenants
+}
+
+// TenantSettings returns the settings of the tenant ctx is scoped to
+func (s *BillingService) TenantSettings(ctx context.Context) TenantSettings {
+	return s.tenants.Settings(domain.TenantFromContext(ctx))
+}
+
 /*
 GetLoan get loan detail based on id
 */
-func (s *BillingService) GetLoanByID(ctx context.Context, loanID int64) (*domain.Loan, error) {
+func (s *BillingService) GetLoanByID(ctx context.Context, loanID int64) (_ *domain.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.GetLoanByID")
+	defer func() { tracing.End(span, err) }()
 
 	// load loan
 	loan, err := s.repo.GetLoanByID(ctx, loanID)
@@ -37,6 +59,61 @@ func (s *BillingService) GetLoanByID(ctx context.Context, loanID int64) (*domain
 	return loan, nil
 }
 
+/*
+AuthorizeLoanAccess checks the caller may read the loan. Callers allowed to read every loan and internal
+calls without a principal pass without a query, a caller only allowed to read its own loans must be
+the borrower of the loan.
+*/
+func (s *BillingService) AuthorizeLoanAccess(ctx context.Context, loanID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.AuthorizeLoanAccess")
+	defer func() { tracing.End(span, err) }()
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil || principal.Can(domain.PermissionLoanRead) {
+		return nil
+	}
+	if !principal.Can(domain.PermissionLoanReadOwn) {
+		AuditDenied(ctx, principal, domain.PermissionLoanRead, "missing permission")
+		return fmt.Errorf("%w: the %s permission is required", domain.ErrPermissionDenied, domain.PermissionLoanRead)
+	}
+
+	loan, err := s.repo.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return domain.ErrLoanNotFound
+	}
+	if loan.BorrowerID == nil || *loan.BorrowerID != principal.Subject {
+		AuditDenied(ctx, principal, domain.PermissionLoanReadOwn, fmt.Sprintf("loan %d belongs to another borrower", loanID))
+		return fmt.Errorf("%w: the loan belongs to another borrower", domain.ErrPermissionDenied)
+	}
+	return nil
+}
+
+/*
+ListLoans returns the loans newest first, a caller only allowed to read its own loans gets those only
+*/
+func (s *BillingService) ListLoans(ctx context.Context, limit int, cursor *LoanCursor) (_ []domain.Loan, _ *LoanCursor, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.ListLoans")
+	defer func() { tracing.End(span, err) }()
+	var cursorID *int64
+	if cursor != nil {
+		cursorID = &cursor.ID
+	}
+	var borrowerID *string
+	if principal := domain.PrincipalFromContext(ctx); principal != nil && !principal.Can(domain.PermissionLoanRead) {
+		borrowerID = &principal.Subject
+	}
+
+	loans, err := s.repo.ListLoans(ctx, cursorID, borrowerID, int32(limit))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var nextCursor *LoanCursor
+	if len(loans) == limit {
+		nextCursor = &LoanCursor{ID: loans[len(loans)-1].ID}
+	}
+	return loans, nextCursor, nil
+}
+
 /*
 SubmitLoan creates a new loan and save all necessary billing data
 
@@ -46,12 +123,16 @@ The assumptions (as allowed by the problem statement):
 - Weekly payment is calculated as total_payable / total_weeks.
 - The total payable amount must be evenly divisible by total_weeks; otherwise, loan creation fails.
 */
-func (s *BillingService) SubmitLoan(ctx context.Context, input SubmitLoanInput) (*domain.Loan, error) {
+func (s *BillingService) SubmitLoan(ctx context.Context, input SubmitLoanInput) (_ *domain.Loan, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.SubmitLoan")
+	defer func() { tracing.End(span, err) }()
 
 	var domainLoan *domain.Loan
+	var events loanEvents
 
-	err := s.repo.WithTx(ctx, func(repo domain.BillingRepository) error {
-		if input.PrincipalAmount <= 0 || input.TotalWeeks <= 0 {
+	err = s.repo.WithTx(ctx, func(repo domain.BillingRepository) error {
+		events = nil
+		if input.PrincipalAmount <= 0 || input.TotalWeeks <= 0 || input.AnnualInterestRate < 0 {
 			return domain.ErrInvalidLoanTerms
 		}
 
@@ -60,11 +141,16 @@ func (s *BillingService) SubmitLoan(ctx context.Context, input SubmitLoanInput)
 		totalPayable := input.PrincipalAmount + totalInterest
 
 		if totalPayable%int64(input.TotalWeeks) != 0 {
-			return errors.New("weekly payment is not evenly divisible")
+			return fmt.Errorf("%w: weekly payment is not evenly divisible", domain.ErrInvalidLoanTerms)
 		}
 
 		weeklyPayment := totalPayable / int64(input.TotalWeeks)
 
+		var borrowerID *string
+		if input.BorrowerID != "" {
+			borrowerID = &input.BorrowerID
+		}
+
 		loan, err := repo.InsertLoan(ctx, domain.CreateLoanCommand{
 			PrincipalAmount:     input.PrincipalAmount,
 			TotalInterestAmount: totalInterest,
@@ -72,6 +158,7 @@ func (s *BillingService) SubmitLoan(ctx context.Context, input SubmitLoanInput)
 			WeeklyPaymentAmount: weeklyPayment,
 			TotalWeeks:          int32(input.TotalWeeks),
 			StartDate:           input.StartDate,
+			BorrowerID:          borrowerID,
 		})
 		if err != nil {
 			return err
@@ -97,17 +184,41 @@ func (s *BillingService) SubmitLoan(ctx context.Context, input SubmitLoanInput)
 		if err != nil {
 			return err
 		}
+
+		err = events.record(ctx, repo, loan.ID, domain.EventLoanCreated, LoanCreatedPayload{
+			LoanID:              loan.ID,
+			PrincipalAmount:     loan.PrincipalAmount,
+			TotalPayableAmount:  loan.TotalPayableAmount,
+			WeeklyPaymentAmount: loan.WeeklyPaymentAmount,
+			TotalWeeks:          loan.TotalWeeks,
+			StartDate:           input.StartDate.Format("2006-01-02"),
+		})
+		if err != nil {
+			return err
+		}
+
+		err = recordAudit(ctx, repo, domain.AuditActionLoanCreate, domain.AuditEntityLoan, loan.ID, nil, loanAuditSnapshot(loan))
+		if err != nil {
+			return err
+		}
 		domainLoan = loan
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return domainLoan, err
+	metrics.LoansCreated.WithLabelValues(domain.TenantFromContext(ctx)).Inc()
+	s.bus.Publish(events...)
+	return domainLoan, nil
 }
 
 /*
 GetOutstanding get total amount that user still need to pay
 */
-func (s *BillingService) GetOutstanding(ctx context.Context, loanID int64) (int64, error) {
+func (s *BillingService) GetOutstanding(ctx context.Context, loanID int64) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.GetOutstanding")
+	defer func() { tracing.End(span, err) }()
 	loan, err := s.repo.GetLoanByID(ctx, loanID)
 	if err != nil {
 		return 0, domain.ErrLoanNotFound
@@ -137,64 +248,161 @@ When a payment is submitted (as interpreted within the problem statement):
 - Payment applies to the next unpaid week
 - A week cannot be paid twice
 - Operation must be atomic (transaction)
+- Concurrent payments of the same loan are serialized by a row lock on the loan
 */
-func (s *BillingService) SubmitPayment(ctx context.Context, input SubmitPaymentInput) (int64, error) {
+func (s *BillingService) SubmitPayment(ctx context.Context, input SubmitPaymentInput) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.SubmitPayment")
+	defer func() { tracing.End(span, err) }()
 
 	var paymentID int64
-	err := s.repo.WithTx(ctx, func(repo domain.BillingRepository) error {
-		// load loan
-		loan, err := repo.GetLoanByID(ctx, input.LoanID)
-		if err != nil {
-			return domain.ErrLoanNotFound
-		}
+	var events loanEvents
+	err = s.repo.WithTx(ctx, func(repo domain.BillingRepository) error {
+		events = nil
+		id, err := s.postPayment(ctx, repo, input, &events)
+		paymentID = id
+		return err
+	})
+	if errors.Is(err, domain.ErrDuplicatePayment) {
+		metrics.DuplicatePayments.WithLabelValues(domain.TenantFromContext(ctx)).Inc()
+		return s.replayPayment(ctx, input, err)
+	}
+	if err != nil {
+		return 0, err
+	}
 
-		// check for outstanding
-		totalPaid, err := repo.GetTotalPaidAmount(ctx, input.LoanID)
-		if err != nil {
-			return err
-		}
-		if totalPaid > loan.TotalPayableAmount {
-			return domain.ErrLoanAlreadyClosed
-		}
-		if input.Amount != loan.WeeklyPaymentAmount {
-			return domain.ErrInvalidPayment
-		}
+	metrics.PaymentsPosted.WithLabelValues(domain.TenantFromContext(ctx)).Inc()
+	s.bus.Publish(events...)
+	return paymentID, nil
+}
 
-		// determine next unpaid week
-		paidWeeks, err := repo.GetPaidWeeksCount(ctx, input.LoanID)
-		if err != nil {
-			return err
-		}
-		nextWeek := paidWeeks + 1
-		if nextWeek > int32(loan.TotalWeeks) {
-			return domain.ErrLoanAlreadyClosed
-		}
+/*
+replayPayment answers the retry of a payment already posted with the same idempotency key with the original payment,
+once the idempotency record expired the retry only reaches the unique key of the payments table.
+A key reused for another loan is not a retry, it keeps failing with dupErr.
+*/
+func (s *BillingService) replayPayment(ctx context.Context, input SubmitPaymentInput, dupErr error) (int64, error) {
+	existing, err := s.repo.GetPaymentByIdempotencyKey(ctx, input.IdempotencyKey)
+	if errors.Is(err, domain.ErrPaymentNotFound) {
+		return 0, dupErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if existing.LoanID != input.LoanID {
+		return 0, dupErr
+	}
 
-		payment, err := repo.InsertPayment(ctx, domain.CreatePaymentComand{
-			LoanID:         input.LoanID,
-			WeekNumber:     nextWeek,
-			Amount:         input.Amount,
-			IdempotencyKey: input.IdempotencyKey,
-			PaidAt:         input.PaidAt,
-		})
-		if err != nil {
-			return err
-		}
+	slog.InfoContext(ctx, "payment_already_processed", slog.Int64("loan_id", input.LoanID), slog.Int64("payment_id", existing.ID))
+	return existing.ID, nil
+}
 
-		// Update the specific schedule record
-		_, err = repo.UpdateSchedulePayment(ctx, domain.UpdateLoanSchedulePaymentCommand{
-			LoanID:     input.LoanID,
-			Sequence:   int32(nextWeek),
-			PaidAmount: input.Amount,
-		})
-		if err != nil {
-			return err
-		}
+// postPayment runs the checks and writes of a payment in the transaction of repo, its events are added to events
+// and must be published once the transaction commits
+func (s *BillingService) postPayment(ctx context.Context, repo domain.BillingRepository, input SubmitPaymentInput, events *loanEvents) (int64, error) {
+	// lock the loan first, concurrent payments of the same loan wait here so each one sees the previous week paid
+	loan, err := repo.LockLoanForUpdate(ctx, input.LoanID)
+	if err != nil {
+		return 0, err
+	}
+
+	// check for outstanding
+	totalPaid, err := repo.GetTotalPaidAmount(ctx, input.LoanID)
+	if err != nil {
+		return 0, err
+	}
+	if totalPaid > loan.TotalPayableAmount {
+		return 0, domain.ErrLoanAlreadyClosed
+	}
+	if input.Amount != loan.WeeklyPaymentAmount {
+		return 0, domain.ErrInvalidPayment
+	}
+
+	// determine next unpaid week
+	paidWeeks, err := repo.GetPaidWeeksCount(ctx, input.LoanID)
+	if err != nil {
+		return 0, err
+	}
+	nextWeek := paidWeeks + 1
+	if nextWeek > int32(loan.TotalWeeks) {
+		return 0, domain.ErrLoanAlreadyClosed
+	}
+	// a collected debit pays the installment it was made for, the weeks are still paid in order
+	switch {
+	case input.Sequence == 0 || input.Sequence == nextWeek:
+	case input.Sequence < nextWeek:
+		return 0, domain.ErrInstallmentAlreadyPaid
+	default:
+		return 0, domain.ErrInstallmentOutOfOrder
+	}
+
+	payment, err := repo.InsertPayment(ctx, domain.CreatePaymentComand{
+		LoanID:         input.LoanID,
+		WeekNumber:     nextWeek,
+		Amount:         input.Amount,
+		IdempotencyKey: input.IdempotencyKey,
+		PaidAt:         input.PaidAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Update the specific schedule record
+	_, err = repo.UpdateSchedulePayment(ctx, domain.UpdateLoanSchedulePaymentCommand{
+		LoanID:     input.LoanID,
+		Sequence:   int32(nextWeek),
+		PaidAmount: input.Amount,
+	})
+	if err != nil {
+		return 0, err
+	}
 
-		paymentID = payment.ID
+	if err := s.recordPaymentEvents(ctx, repo, events, loan, payment, totalPaid+input.Amount); err != nil {
+		return 0, err
+	}
+
+	// payments posted by the collection import carry their key in the input rather than the request
+	auditCtx := ctx
+	if input.IdempotencyKey != "" {
+		auditCtx = domain.ContextWithIdempotencyKey(ctx, input.IdempotencyKey)
+	}
+	err = recordAudit(auditCtx, repo, domain.AuditActionPaymentCreate, domain.AuditEntityPayment, payment.ID, nil, paymentAuditSnapshot(payment))
+	if err != nil {
+		return 0, err
+	}
+
+	return payment.ID, nil
+}
+
+// recordPaymentEvents stores the events caused by a payment within the payment transaction
+func (s *BillingService) recordPaymentEvents(ctx context.Context, repo domain.BillingRepository, events *loanEvents, loan *domain.Loan, payment *domain.Payment, totalPaid int64) error {
+	err := events.record(ctx, repo, loan.ID, domain.EventPaymentReceived, PaymentReceivedPayload{
+		LoanID:     loan.ID,
+		PaymentID:  payment.ID,
+		WeekNumber: payment.WeekNumber,
+		Amount:     payment.Amount,
+		PaidAt:     payment.PaidAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = events.record(ctx, repo, loan.ID, domain.EventScheduleInstallmentPaid, ScheduleInstallmentPaidPayload{
+		LoanID:   loan.ID,
+		Sequence: payment.WeekNumber,
+		Amount:   payment.Amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	if payment.WeekNumber < loan.TotalWeeks {
 		return nil
+	}
+	return events.record(ctx, repo, loan.ID, domain.EventLoanPaidOff, LoanPaidOffPayload{
+		LoanID:    loan.ID,
+		TotalPaid: totalPaid,
+		PaidOffAt: payment.PaidAt,
 	})
-	return paymentID, err
 }
 
 /*
@@ -210,7 +418,9 @@ There is a gap of 2 or more weeks between:
 - the latest paid week
 - and the current expected week
 */
-func (s *BillingService) IsDelinquent(ctx context.Context, loanID int64, now time.Time) (bool, error) {
+func (s *BillingService) IsDelinquent(ctx context.Context, loanID int64, now time.Time) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.IsDelinquent")
+	defer func() { tracing.End(span, err) }()
 	// load loan
 	loan, err := s.repo.GetLoanByID(ctx, loanID)
 	if err != nil {
@@ -233,13 +443,15 @@ func (s *BillingService) IsDelinquent(ctx context.Context, loanID int64, now tim
 	}
 
 	gap := expectedWeek - int(lastPaidWeek)
-	return gap >= 2, nil
+	return gap >= s.TenantSettings(ctx).DelinquencyGapWeeks, nil
 }
 
 /*
 ListPayments return all payment records based on loan id
 */
-func (s *BillingService) ListPayments(ctx context.Context, loanID int64, limit int, cursor *PaymentCursor) ([]domain.Payment, *PaymentCursor, error) {
+func (s *BillingService) ListPayments(ctx context.Context, loanID int64, limit int, cursor *PaymentCursor) (_ []domain.Payment, _ *PaymentCursor, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.ListPayments")
+	defer func() { tracing.End(span, err) }()
 
 	query := domain.ListPaymentsQuery{
 		LoanID:   loanID,
@@ -271,7 +483,9 @@ func (s *BillingService) ListPayments(ctx context.Context, loanID int64, limit i
 /*
 ListSchedules return all schedule records based on loan id
 */
-func (s *BillingService) ListSchedules(ctx context.Context, loanID int64, limit int, cursor *ScheduleCursor) ([]domain.LoanSchedule, *ScheduleCursor, error) {
+func (s *BillingService) ListSchedules(ctx context.Context, loanID int64, limit int, cursor *ScheduleCursor) (_ []domain.LoanSchedule, _ *ScheduleCursor, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.ListSchedules")
+	defer func() { tracing.End(span, err) }()
 
 	params := domain.ListScheduleQuery{
 		LoanID:         loanID,
@@ -300,3 +514,88 @@ func (s *BillingService) ListSchedules(ctx context.Context, loanID int64, limit
 
 	return schedules, nextCursor, nil
 }
+
+// number of unpaid installments listed at the bottom of a statement
+const statementUpcomingInstallments = 5
+
+/*
+GetStatement assembles the account statement of a loan for the period [from, to], both dates inclusive.
+
+The opening balance is the outstanding amount at the start of `from`, every balance movement within the
+period is listed with the running balance, and the next unpaid installments are attached so the borrower
+knows what is due next. All reads run in one read-only snapshot transaction. Fees, reversals and adjustments share the same entry format, but the billing engine
+does not record them yet, so for now the entries are built from payments only.
+*/
+func (s *BillingService) GetStatement(ctx context.Context, loanID int64, from, to time.Time) (_ *domain.Statement, err error) {
+	ctx, span := tracing.Start(ctx, "BillingService.GetStatement")
+	defer func() { tracing.End(span, err) }()
+	periodStart := truncateToDate(from)
+	periodEnd := truncateToDate(to).AddDate(0, 0, 1)
+	if !periodStart.Before(periodEnd) {
+		return nil, domain.ErrInvalidStatementPeriod
+	}
+
+	// read everything from one snapshot, so a payment committed in between can not unbalance the statement
+	var loan *domain.Loan
+	var paidBefore int64
+	var payments []domain.Payment
+	var upcoming []domain.LoanSchedule
+	err = s.repo.WithTxOptions(ctx, domain.SnapshotTxOptions, func(repo domain.BillingRepository) error {
+		var err error
+		loan, err = repo.GetLoanByID(ctx, loanID)
+		if err != nil {
+			return domain.ErrLoanNotFound
+		}
+
+		paidBefore, err = repo.GetTotalPaidAmountBefore(ctx, loanID, periodStart)
+		if err != nil {
+			return err
+		}
+
+		payments, err = repo.ListPaymentsByLoanIDInPeriod(ctx, domain.StatementPeriodQuery{
+			LoanID:      loanID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the installments due after the period, as they stood at its end
+		upcoming, err = repo.ListUpcomingSchedules(ctx, domain.UpcomingSchedulesQuery{
+			LoanID:  loanID,
+			DueFrom: periodEnd,
+			Limit:   statementUpcomingInstallments,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	openingBalance := loan.TotalPayableAmount - paidBefore
+	balance := openingBalance
+	entries := make([]domain.StatementEntry, 0, len(payments))
+	for _, p := range payments {
+		balance -= p.Amount
+		entries = append(entries, domain.StatementEntry{
+			Type:        domain.StatementEntryPayment,
+			Reference:   fmt.Sprintf("PAY-%d", p.ID),
+			Date:        p.PaidAt,
+			Description: fmt.Sprintf("Payment for week %d", p.WeekNumber),
+			Credit:      p.Amount,
+			Balance:     balance,
+		})
+	}
+
+	return &domain.Statement{
+		Loan:                 *loan,
+		PeriodStart:          periodStart,
+		PeriodEnd:            truncateToDate(to),
+		OpeningBalance:       openingBalance,
+		Entries:              entries,
+		ClosingBalance:       balance,
+		UpcomingInstallments: upcoming,
+		GeneratedAt:          time.Now(),
+	}, nil
+}
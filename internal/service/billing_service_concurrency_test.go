@@ -0,0 +1,123 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/db"
+	"billing-api/internal/infra/db/repository"
+	"billing-api/internal/infra/memory"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDatabaseURLEnv names a disposable database for the tests that need Postgres, they are skipped without it
+const testDatabaseURLEnv = "BILLING_TEST_DATABASE_URL"
+
+// latentBillingRepo adds a delay between reading the paid weeks and writing the payment, like a round trip to the database
+type latentBillingRepo struct {
+	domain.BillingRepository
+}
+
+func (r latentBillingRepo) WithTx(ctx context.Context, fn func(repo domain.BillingRepository) error) error {
+	return r.BillingRepository.WithTx(ctx, func(repo domain.BillingRepository) error {
+		return fn(latentBillingRepo{repo})
+	})
+}
+
+func (r latentBillingRepo) GetPaidWeeksCount(ctx context.Context, loanID int64) (int32, error) {
+	count, err := r.BillingRepository.GetPaidWeeksCount(ctx, loanID)
+	time.Sleep(time.Millisecond)
+	return count, err
+}
+
+/*
+TestBillingService_SubmitPayment_Concurrent runs on the memory repositories, whose LockLoanForUpdate is a mutex.
+It proves the service takes the lock before reading the paid weeks, not that SELECT ... FOR UPDATE and the
+transaction retries serialize the payments in Postgres, TestBillingService_SubmitPayment_ConcurrentPostgres does.
+*/
+func TestBillingService_SubmitPayment_Concurrent(t *testing.T) {
+	repo := memory.NewBillingRepo(memory.NewStore())
+	testConcurrentPayments(t, NewBillingService(nil, latentBillingRepo{repo}, nil, nil), repo)
+}
+
+// TestBillingService_SubmitPayment_ConcurrentPostgres migrates the database of BILLING_TEST_DATABASE_URL and
+// posts the concurrent payments through the Postgres repositories
+func TestBillingService_SubmitPayment_ConcurrentPostgres(t *testing.T) {
+	databaseURL := os.Getenv(testDatabaseURLEnv)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	_, err = db.NewMigrator(pool, db.Migrations).Up(ctx)
+	require.NoError(t, err)
+
+	repo := repository.NewPostgresRepo(pool)
+	testConcurrentPayments(t, NewBillingService(pool, latentBillingRepo{repo}, nil, nil), repo)
+}
+
+// testConcurrentPayments pays a new loan from parallel goroutines, every payment must get its own week
+func testConcurrentPayments(t *testing.T, svc *BillingService, repo domain.BillingRepository) {
+	t.Helper()
+	ctx := context.Background()
+
+	loan, err := svc.SubmitLoan(ctx, SubmitLoanInput{
+		PrincipalAmount:    5000000,
+		AnnualInterestRate: 0.10,
+		TotalWeeks:         50,
+		StartDate:          time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	const payers = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, payers)
+	for i := range payers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.SubmitPayment(ctx, SubmitPaymentInput{
+				LoanID:         loan.ID,
+				Amount:         loan.WeeklyPaymentAmount,
+				PaidAt:         time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC),
+				IdempotencyKey: fmt.Sprintf("key-%d-%d", loan.ID, i),
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	payments, err := repo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: loan.ID, LimitVal: 100})
+	require.NoError(t, err)
+	weeks := make([]int, len(payments))
+	for i, p := range payments {
+		weeks[i] = p.WeekNumber
+	}
+	slices.Sort(weeks)
+	expected := make([]int, payers)
+	for i := range expected {
+		expected[i] = i + 1
+	}
+	assert.Equal(t, expected, weeks, "every payment must get its own week")
+
+	outstanding, err := svc.GetOutstanding(ctx, loan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, loan.TotalPayableAmount-payers*loan.WeeklyPaymentAmount, outstanding)
+}
@@ -2,13 +2,17 @@ package service
 
 import (
 	"billing-api/internal/domain"
+	"billing-api/internal/infra/memory"
 	"billing-api/internal/mocks"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsDelinquent_Mock(t *testing.T) {
@@ -16,7 +20,7 @@ func TestIsDelinquent_Mock(t *testing.T) {
 	mockRepo := new(mocks.MockBillingRepository)
 
 	// provide nil since we are in mock mode
-	svc := NewBillingService(nil, mockRepo)
+	svc := NewBillingService(nil, mockRepo, nil, nil)
 	ctx := context.Background()
 	now := time.Now()
 
@@ -26,14 +30,14 @@ func TestIsDelinquent_Mock(t *testing.T) {
 		// mock GetLoanByID
 		// setup loan 4 weeks ago
 		fourWeeksAgoDate := now.AddDate(0, 0, -28)
-		mockRepo.On("GetLoanByID", ctx, loanID).Return(&domain.Loan{
+		mockRepo.On("GetLoanByID", mock.Anything, loanID).Return(&domain.Loan{
 			ID:        loanID,
 			CreatedAt: fourWeeksAgoDate,
 		}, nil).Once()
 
 		// mock GetLastPaidWeek, paid only 1 week
 		// expected week 4 - paid 1 = 3 means delinquent
-		mockRepo.On("GetLastPaidWeek", ctx, loanID).Return(int32(1), nil).Once()
+		mockRepo.On("GetLastPaidWeek", mock.Anything, loanID).Return(int32(1), nil).Once()
 
 		// execute
 		isDelinquent, err := svc.IsDelinquent(ctx, loanID, now)
@@ -47,13 +51,13 @@ func TestIsDelinquent_Mock(t *testing.T) {
 		loanID := int64(2)
 		twoWeeksAgo := now.AddDate(0, 0, -14)
 
-		mockRepo.On("GetLoanByID", ctx, loanID).Return(&domain.Loan{
+		mockRepo.On("GetLoanByID", mock.Anything, loanID).Return(&domain.Loan{
 			ID:        loanID,
 			CreatedAt: twoWeeksAgo,
 		}, nil).Once()
 
 		// expected week 2 - paid 2 = 0 not delinquent
-		mockRepo.On("GetLastPaidWeek", ctx, loanID).Return(int32(2), nil).Once()
+		mockRepo.On("GetLastPaidWeek", mock.Anything, loanID).Return(int32(2), nil).Once()
 
 		isDelinquent, err := svc.IsDelinquent(ctx, loanID, now)
 
@@ -69,17 +73,17 @@ func TestGetOutstanding_Unit(t *testing.T) {
 	mockRepo := new(mocks.MockBillingRepository)
 
 	// provide nil since we are in mock mode
-	svc := NewBillingService(nil, mockRepo)
+	svc := NewBillingService(nil, mockRepo, nil, nil)
 	ctx := context.Background()
 
 	loanID := int64(1)
 
 	// simulate a loan with 5,000,000 total and 1,000,000 already paid
-	mockRepo.On("GetLoanByID", ctx, loanID).Return(&domain.Loan{
+	mockRepo.On("GetLoanByID", mock.Anything, loanID).Return(&domain.Loan{
 		ID:                 loanID,
 		TotalPayableAmount: 5000000,
 	}, nil)
-	mockRepo.On("GetTotalPaidAmount", ctx, loanID).Return(int64(1000000), nil)
+	mockRepo.On("GetTotalPaidAmount", mock.Anything, loanID).Return(int64(1000000), nil)
 
 	outstanding, err := svc.GetOutstanding(ctx, loanID)
 
@@ -91,7 +95,7 @@ func TestGetOutstanding_Unit(t *testing.T) {
 func TestSubmitPayment_Mock(t *testing.T) {
 	mockRepo := new(mocks.MockBillingRepository)
 	// We still need a pool to satisfy the struct, but we won't call the real DB
-	svc := NewBillingService(nil, mockRepo)
+	svc := NewBillingService(nil, mockRepo, nil, nil)
 	ctx := context.Background()
 
 	t.Run("successful payment", func(t *testing.T) {
@@ -108,8 +112,8 @@ func TestSubmitPayment_Mock(t *testing.T) {
 				// assert.NoError(t, err)
 			}).Return(nil)
 
-		// mock GetLoanByID (to check if loan exists and get weekly amount)
-		mockRepo.On("GetLoanByID", mock.Anything, input.LoanID).Return(&domain.Loan{
+		// mock LockLoanForUpdate (to check if loan exists, get weekly amount and serialize payments)
+		mockRepo.On("LockLoanForUpdate", mock.Anything, input.LoanID).Return(&domain.Loan{
 			ID:                  1,
 			WeeklyPaymentAmount: 110000,
 			TotalPayableAmount:  5500000,
@@ -134,9 +138,24 @@ func TestSubmitPayment_Mock(t *testing.T) {
 			PaidAt:     input.PaidAt,
 		}
 		mockRepo.On("InsertPayment", mock.Anything, expectedInsert).Return(&domain.Payment{
-			ID: 999,
+			ID:         999,
+			LoanID:     input.LoanID,
+			WeekNumber: 1,
+			Amount:     input.Amount,
 		}, nil).Once()
 
+		// payment events are recorded within the same transaction, not yet paid off (week 1 of 5)
+		for _, eventType := range []string{domain.EventPaymentReceived, domain.EventScheduleInstallmentPaid} {
+			mockRepo.On("InsertOutboxEvent", mock.Anything, mock.MatchedBy(func(cmd domain.CreateOutboxEventCommand) bool {
+				return cmd.EventType == eventType && cmd.AggregateID == input.LoanID
+			})).Return(int64(1), nil).Once()
+		}
+
+		// and so is the audit entry, a payment made outside of a request is made by the system
+		mockRepo.On("InsertAuditEntry", mock.Anything, mock.MatchedBy(func(cmd domain.CreateAuditEntryCommand) bool {
+			return cmd.Action == domain.AuditActionPaymentCreate && cmd.EntityID == "999" && cmd.Actor == domain.AuditActorSystem && cmd.Before == nil
+		})).Return(nil).Once()
+
 		id, err := svc.SubmitPayment(ctx, input)
 
 		assert.NoError(t, err)
@@ -160,7 +179,7 @@ func TestSubmitPayment_Mock(t *testing.T) {
 
 		mockRepo.On("GetTotalPaidAmount", mock.Anything, input.LoanID).Return(int64(0), nil).Once()
 
-		mockRepo.On("GetLoanByID", mock.Anything, input.LoanID).Return(&domain.Loan{
+		mockRepo.On("LockLoanForUpdate", mock.Anything, input.LoanID).Return(&domain.Loan{
 			ID:                  1,
 			WeeklyPaymentAmount: 110000, // The required amount
 		}, nil).Once()
@@ -174,5 +193,186 @@ func TestSubmitPayment_Mock(t *testing.T) {
 
 		// This confirms InsertPayment was never called
 		mockRepo.AssertExpectations(t)
+		mockRepo.ExpectedCalls = nil
+	})
+
+	t.Run("retry of a processed payment returns the original payment", func(t *testing.T) {
+		input := SubmitPaymentInput{LoanID: 1, Amount: 110000, IdempotencyKey: "key-1"}
+
+		// the idempotency record expired, the retry hits the unique key of the payments table
+		mockRepo.On("WithTx", mock.Anything, mock.Anything).Return(domain.ErrDuplicatePayment).Once()
+		mockRepo.On("GetPaymentByIdempotencyKey", mock.Anything, "key-1").Return(&domain.Payment{ID: 999, LoanID: 1}, nil).Once()
+
+		id, err := svc.SubmitPayment(ctx, input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(999), id)
+		mockRepo.AssertExpectations(t)
+		mockRepo.ExpectedCalls = nil
+	})
+
+	t.Run("key reused for another loan stays a duplicate", func(t *testing.T) {
+		input := SubmitPaymentInput{LoanID: 2, Amount: 110000, IdempotencyKey: "key-1"}
+
+		mockRepo.On("WithTx", mock.Anything, mock.Anything).Return(domain.ErrDuplicatePayment).Once()
+		mockRepo.On("GetPaymentByIdempotencyKey", mock.Anything, "key-1").Return(&domain.Payment{ID: 999, LoanID: 1}, nil).Once()
+
+		id, err := svc.SubmitPayment(ctx, input)
+
+		assert.ErrorIs(t, err, domain.ErrDuplicatePayment)
+		assert.Equal(t, int64(0), id)
+		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestGetStatement_Mock(t *testing.T) {
+	mockRepo := new(mocks.MockBillingRepository)
+	svc := NewBillingService(nil, mockRepo, nil, nil)
+	ctx := context.Background()
+
+	loanID := int64(1)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	t.Run("running balance starts from the opening balance", func(t *testing.T) {
+		// the reads share one read-only snapshot
+		mockRepo.On("WithTxOptions", mock.Anything, domain.SnapshotTxOptions, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fn := args.Get(2).(func(domain.BillingRepository) error)
+				_ = fn(mockRepo)
+			}).Return(nil).Once()
+		mockRepo.On("GetLoanByID", mock.Anything, loanID).Return(&domain.Loan{
+			ID:                 loanID,
+			TotalPayableAmount: 550000,
+		}, nil).Once()
+		mockRepo.On("GetTotalPaidAmountBefore", mock.Anything, loanID, from).Return(int64(110000), nil).Once()
+		mockRepo.On("ListPaymentsByLoanIDInPeriod", mock.Anything, domain.StatementPeriodQuery{
+			LoanID:      loanID,
+			PeriodStart: from,
+			PeriodEnd:   to.AddDate(0, 0, 1),
+		}).Return([]domain.Payment{
+			{ID: 10, WeekNumber: 2, Amount: 110000, PaidAt: from.AddDate(0, 0, 3)},
+			{ID: 11, WeekNumber: 3, Amount: 110000, PaidAt: from.AddDate(0, 0, 10)},
+		}, nil).Once()
+		mockRepo.On("ListUpcomingSchedules", mock.Anything, domain.UpcomingSchedulesQuery{
+			LoanID:  loanID,
+			DueFrom: to.AddDate(0, 0, 1),
+			Limit:   statementUpcomingInstallments,
+		}).Return([]domain.LoanSchedule{
+			{Sequence: 4, Amount: 110000},
+			{Sequence: 5, Amount: 110000},
+		}, nil).Once()
+
+		statement, err := svc.GetStatement(ctx, loanID, from, to)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(440000), statement.OpeningBalance)
+		assert.Len(t, statement.Entries, 2)
+		assert.Equal(t, int64(330000), statement.Entries[0].Balance)
+		assert.Equal(t, int64(220000), statement.ClosingBalance)
+		assert.Len(t, statement.UpcomingInstallments, 2)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("fails when period ends before it starts", func(t *testing.T) {
+		_, err := svc.GetStatement(ctx, loanID, to, from)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidStatementPeriod)
+	})
+}
+
+func TestGetStatement_UpcomingAsOfPeriodEnd(t *testing.T) {
+	ctx := context.Background()
+	svc := NewBillingService(nil, memory.NewBillingRepo(memory.NewStore()), nil, nil)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	loan, err := svc.SubmitLoan(ctx, SubmitLoanInput{
+		PrincipalAmount:    5000000,
+		AnnualInterestRate: 0.10,
+		TotalWeeks:         50,
+		StartDate:          start,
+	})
+	require.NoError(t, err)
+
+	// weeks 1 and 2 are paid after the end of the statement period, week 2 is due after it
+	periodEnd := start.AddDate(0, 0, 10)
+	for _, key := range []string{"week-1", "week-2"} {
+		_, err = svc.SubmitPayment(ctx, SubmitPaymentInput{
+			LoanID:         loan.ID,
+			Amount:         loan.WeeklyPaymentAmount,
+			PaidAt:         time.Now(),
+			IdempotencyKey: key,
+		})
+		require.NoError(t, err)
+	}
+
+	statement, err := svc.GetStatement(ctx, loan.ID, start, periodEnd)
+	require.NoError(t, err)
+	require.NotEmpty(t, statement.UpcomingInstallments)
+	for _, u := range statement.UpcomingInstallments {
+		assert.True(t, u.DueDate.After(periodEnd), "week %d is due on %s", u.Sequence, u.DueDate)
+	}
+	assert.Equal(t, 2, statement.UpcomingInstallments[0].Sequence)
+	assert.Equal(t, int64(0), statement.UpcomingInstallments[0].PaidAmount)
+}
+
+func TestAuthorizeLoanAccess_Mock(t *testing.T) {
+	mockRepo := new(mocks.MockBillingRepository)
+	svc := NewBillingService(nil, mockRepo, nil, nil)
+	borrower := "borrower-1"
+	mockRepo.On("GetLoanByID", mock.Anything, int64(1)).Return(&domain.Loan{ID: 1, BorrowerID: &borrower}, nil)
+	mockRepo.On("GetLoanByID", mock.Anything, int64(2)).Return(&domain.Loan{ID: 2}, nil)
+	as := func(subject string, permissions ...string) context.Context {
+		return domain.ContextWithPrincipal(context.Background(), &domain.Principal{Subject: subject, Permissions: permissions})
+	}
+
+	assert.NoError(t, svc.AuthorizeLoanAccess(context.Background(), 2))
+	assert.NoError(t, svc.AuthorizeLoanAccess(as("agent", domain.PermissionLoanRead), 2))
+	assert.NoError(t, svc.AuthorizeLoanAccess(as(borrower, domain.PermissionLoanReadOwn), 1))
+	assert.ErrorIs(t, svc.AuthorizeLoanAccess(as(borrower, domain.PermissionLoanReadOwn), 2), domain.ErrPermissionDenied)
+	assert.ErrorIs(t, svc.AuthorizeLoanAccess(as("borrower-2", domain.PermissionLoanReadOwn), 1), domain.ErrPermissionDenied)
+	assert.ErrorIs(t, svc.AuthorizeLoanAccess(as(borrower), 1), domain.ErrPermissionDenied)
+}
+
+func TestIsDelinquent_TenantGap(t *testing.T) {
+	mockRepo := new(mocks.MockBillingRepository)
+	tenants, err := NewTenants(defaultTenantSettings, map[string]TenantSettings{"acme": {DelinquencyGapWeeks: 4}})
+	assert.NoError(t, err)
+	svc := NewBillingService(nil, mockRepo, nil, tenants)
+	now := time.Now()
+
+	// expected week 4 - paid 1 = 3, delinquent for the default gap of 2 but not for the gap of 4 of acme
+	mockRepo.On("GetLoanByID", mock.Anything, int64(1)).Return(&domain.Loan{ID: 1, CreatedAt: now.AddDate(0, 0, -21)}, nil)
+	mockRepo.On("GetLastPaidWeek", mock.Anything, int64(1)).Return(int32(1), nil)
+
+	isDelinquent, err := svc.IsDelinquent(context.Background(), 1, now)
+	assert.NoError(t, err)
+	assert.True(t, isDelinquent)
+
+	isDelinquent, err = svc.IsDelinquent(domain.ContextWithTenant(context.Background(), "acme"), 1, now)
+	assert.NoError(t, err)
+	assert.False(t, isDelinquent)
+}
+
+func TestLoadTenants(t *testing.T) {
+	defaults := TenantSettings{PagingLimitDefault: 10, PagingLimitMax: 100, DelinquencyGapWeeks: 2}
+	tenants, err := LoadTenants("", defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{domain.DefaultTenantID}, tenants.IDs())
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"tenants": {"acme": {"paging_limit_max": 50}, "globex": {"delinquency_gap_weeks": 3}}}`), 0o600))
+	tenants, err = LoadTenants(path, defaults)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"acme", domain.DefaultTenantID, "globex"}, tenants.IDs())
+	assert.Equal(t, TenantSettings{PagingLimitDefault: 10, PagingLimitMax: 50, DelinquencyGapWeeks: 2}, tenants.Settings("acme"))
+	assert.Equal(t, 3, tenants.Settings("globex").DelinquencyGapWeeks)
+	assert.Equal(t, defaults, tenants.Settings("initech"))
+	assert.False(t, tenants.Has("initech"))
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"tenants": {"Acme Corp": {}}}`), 0o600))
+	_, err = LoadTenants(path, defaults)
+	assert.ErrorContains(t, err, `invalid tenant id "Acme Corp"`)
+	assert.NoError(t, os.WriteFile(path, []byte(`{"tenants": {"acme": {"paging_limit_max": 5}}}`), 0o600))
+	_, err = LoadTenants(path, defaults)
+	assert.Error(t, err)
+}
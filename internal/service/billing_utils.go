@@ -15,3 +15,10 @@ func weekSince(start, now time.Time) int {
 
 	return weeks + 1
 }
+
+/*
+truncateToDate internal helper method to drop the time part, keeping the calendar date as UTC midnight
+*/
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
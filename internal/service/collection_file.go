@@ -0,0 +1,237 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type BankFileFormat string
+
+const (
+	BankFileFormatCSV   BankFileFormat = "csv"
+	BankFileFormatFixed BankFileFormat = "fixed"
+)
+
+const bankFileDateLayout = "20060102"
+
+// ParseBankFileFormat falls back to CSV when no format is given
+func ParseBankFileFormat(s string) (BankFileFormat, error) {
+	switch BankFileFormat(strings.ToLower(s)) {
+	case "", BankFileFormatCSV:
+		return BankFileFormatCSV, nil
+	case BankFileFormatFixed:
+		return BankFileFormatFixed, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported format %q", domain.ErrInvalidBankFile, s)
+	}
+}
+
+var collectionCSVHeader = []string{
+	"item_id",
+	"loan_id",
+	"mandate_reference",
+	"bank_code",
+	"account_number",
+	"account_holder",
+	"amount",
+	"collection_date",
+}
+
+/*
+WriteCollectionFile renders a batch as a bank debit instruction file.
+
+The fixed-width layout uses one header record, one detail record per item and one trailer record:
+
+	H | batch id (10) | collection date YYYYMMDD (8) | item count (6) | total amount (15)
+	D | item id (12) | bank code (16) | account number (34) | account holder (35) | amount (15) | mandate reference (35)
+	T | item count (6) | total amount (15)
+
+numeric fields are zero padded on the left, text fields are space padded on the right and truncated when too long
+*/
+func WriteCollectionFile(w io.Writer, format BankFileFormat, batch *domain.CollectionBatch, items []domain.CollectionItem) error {
+	switch format {
+	case BankFileFormatCSV:
+		return writeCollectionCSV(w, batch, items)
+	case BankFileFormatFixed:
+		return writeCollectionFixed(w, batch, items)
+	default:
+		return fmt.Errorf("%w: unsupported format %q", domain.ErrInvalidBankFile, format)
+	}
+}
+
+func writeCollectionCSV(w io.Writer, batch *domain.CollectionBatch, items []domain.CollectionItem) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(collectionCSVHeader); err != nil {
+		return err
+	}
+	collectionDate := batch.CollectionDate.Format("2006-01-02")
+	for _, item := range items {
+		record := []string{
+			strconv.FormatInt(item.ID, 10),
+			strconv.FormatInt(item.LoanID, 10),
+			item.MandateReference,
+			item.BankCode,
+			item.AccountNumber,
+			item.AccountHolder,
+			strconv.FormatInt(item.Amount, 10),
+			collectionDate,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCollectionFixed(w io.Writer, batch *domain.CollectionBatch, items []domain.CollectionItem) error {
+	bw := bufio.NewWriter(w)
+
+	var total int64
+	for _, item := range items {
+		total += item.Amount
+	}
+
+	fmt.Fprintf(bw, "H%s%s%s%s\n",
+		padNumber(batch.ID, 10),
+		batch.CollectionDate.Format(bankFileDateLayout),
+		padNumber(int64(len(items)), 6),
+		padNumber(total, 15),
+	)
+	for _, item := range items {
+		fmt.Fprintf(bw, "D%s%s%s%s%s%s\n",
+			padNumber(item.ID, 12),
+			padText(item.BankCode, 16),
+			padText(item.AccountNumber, 34),
+			padText(item.AccountHolder, 35),
+			padNumber(item.Amount, 15),
+			padText(item.MandateReference, 35),
+		)
+	}
+	fmt.Fprintf(bw, "T%s%s\n", padNumber(int64(len(items)), 6), padNumber(total, 15))
+
+	return bw.Flush()
+}
+
+/*
+ParseCollectionResultFile reads the bank's result file for a batch.
+
+CSV rows are `item_id,status,reason_code` where status is SUCCESS or FAILED, a header row is optional.
+Fixed-width detail records are `D | item id (12) | status S/F (1) | reason code (4)`, header and trailer records are skipped.
+*/
+func ParseCollectionResultFile(r io.Reader, format BankFileFormat) ([]domain.CollectionResult, error) {
+	switch format {
+	case BankFileFormatCSV:
+		return parseCollectionResultCSV(r)
+	case BankFileFormatFixed:
+		return parseCollectionResultFixed(r)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", domain.ErrInvalidBankFile, format)
+	}
+}
+
+func parseCollectionResultCSV(r io.Reader) ([]domain.CollectionResult, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var results []domain.CollectionResult
+	line := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidBankFile, err)
+		}
+		line++
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "item_id") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%w: line %d has %d fields", domain.ErrInvalidBankFile, line, len(record))
+		}
+
+		itemID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d invalid item id", domain.ErrInvalidBankFile, line)
+		}
+
+		var succeeded bool
+		switch strings.ToUpper(strings.TrimSpace(record[1])) {
+		case "SUCCESS", "S":
+			succeeded = true
+		case "FAILED", "F":
+			succeeded = false
+		default:
+			return nil, fmt.Errorf("%w: line %d invalid status %q", domain.ErrInvalidBankFile, line, record[1])
+		}
+
+		result := domain.CollectionResult{ItemID: itemID, Succeeded: succeeded}
+		if len(record) > 2 {
+			result.ReasonCode = strings.ToUpper(strings.TrimSpace(record[2]))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func parseCollectionResultFixed(r io.Reader) ([]domain.CollectionResult, error) {
+	scanner := bufio.NewScanner(r)
+
+	var results []domain.CollectionResult
+	line := 0
+	for scanner.Scan() {
+		line++
+		record := strings.TrimRight(scanner.Text(), "\r")
+		if record == "" || record[0] == 'H' || record[0] == 'T' {
+			continue
+		}
+		if record[0] != 'D' || len(record) < 14 {
+			return nil, fmt.Errorf("%w: line %d is not a valid detail record", domain.ErrInvalidBankFile, line)
+		}
+
+		itemID, err := strconv.ParseInt(record[1:13], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d invalid item id", domain.ErrInvalidBankFile, line)
+		}
+
+		var succeeded bool
+		switch record[13] {
+		case 'S':
+			succeeded = true
+		case 'F':
+			succeeded = false
+		default:
+			return nil, fmt.Errorf("%w: line %d invalid status %q", domain.ErrInvalidBankFile, line, record[13])
+		}
+
+		result := domain.CollectionResult{ItemID: itemID, Succeeded: succeeded}
+		if len(record) > 14 {
+			end := min(len(record), 18)
+			result.ReasonCode = strings.TrimSpace(record[14:end])
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidBankFile, err)
+	}
+	return results, nil
+}
+
+func padNumber(n int64, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+func padText(s string, width int) string {
+	if len(s) > width {
+		return s[:width]
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
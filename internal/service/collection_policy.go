@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+RetryPolicy decides whether a failed direct debit is presented to the bank again.
+
+BackoffDays holds the wait (in days) before each retry, so a policy of [3, 5] means
+the first failure is retried 3 days after the collection date, the second failure 5 days after,
+and the third failure is final. Reason codes listed in NonRetryableCodes (eg closed account,
+revoked authorization) fail immediately regardless of the remaining attempts.
+*/
+type RetryPolicy struct {
+	BackoffDays       []int
+	NonRetryableCodes map[string]bool
+}
+
+func NewRetryPolicy(backoffDays []int, nonRetryableCodes []string) RetryPolicy {
+	codes := make(map[string]bool, len(nonRetryableCodes))
+	for _, c := range nonRetryableCodes {
+		codes[strings.ToUpper(strings.TrimSpace(c))] = true
+	}
+	return RetryPolicy{
+		BackoffDays:       backoffDays,
+		NonRetryableCodes: codes,
+	}
+}
+
+// MaxAttempts is the total number of times a single schedule can be presented to the bank
+func (p RetryPolicy) MaxAttempts() int {
+	return len(p.BackoffDays) + 1
+}
+
+/*
+NextAttempt returns the date the schedule should be collected again after the given attempt failed,
+or false when no more retries are allowed
+*/
+func (p RetryPolicy) NextAttempt(attempt int, reasonCode string, collectionDate time.Time) (time.Time, bool) {
+	if p.NonRetryableCodes[strings.ToUpper(reasonCode)] {
+		return time.Time{}, false
+	}
+	if attempt < 1 || attempt > len(p.BackoffDays) {
+		return time.Time{}, false
+	}
+	return collectionDate.AddDate(0, 0, p.BackoffDays[attempt-1]), true
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"log/slog"
+	"time"
+)
+
+/*
+CollectionRunner periodically triggers RunCollection for the current date.
+
+Runs are idempotent per date, so the interval only controls how soon newly due schedules
+or matured retries are picked up, it does not need to line up with the bank cut-off time.
+*/
+type CollectionRunner struct {
+	service  *CollectionService
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewCollectionRunner(service *CollectionService, interval time.Duration) *CollectionRunner {
+	return &CollectionRunner{
+		service:  service,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the collection loop in the background until ctx is cancelled
+func (r *CollectionRunner) Start(ctx context.Context) {
+	go runEvery(ctx, r.interval, r.runOnce)
+}
+
+// runOnce builds one batch per tenant, a tenant failing does not hold back the others
+func (r *CollectionRunner) runOnce(ctx context.Context) {
+	now := r.now()
+	_ = r.service.billingService.Tenants().forEach(ctx, func(ctx context.Context) error {
+		if _, err := r.service.RunCollection(ctx, now); err != nil {
+			slog.ErrorContext(ctx, "collection_run_failed", slog.String("tenant_id", domain.TenantFromContext(ctx)), slog.Any("err", err))
+			return err
+		}
+		return nil
+	})
+}
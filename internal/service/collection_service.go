@@ -0,0 +1,418 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/metrics"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type CollectionService struct {
+	repo           domain.CollectionRepository
+	billingService *BillingService
+	policy         RetryPolicy
+}
+
+// constructor
+func NewCollectionService(repo domain.CollectionRepository, billingService *BillingService, policy RetryPolicy) *CollectionService {
+	return &CollectionService{
+		repo:           repo,
+		billingService: billingService,
+		policy:         policy,
+	}
+}
+
+/*
+CreateMandate registers the borrower's direct debit authorization for a loan.
+A loan can only have one active mandate, the previous one must be revoked first.
+*/
+func (s *CollectionService) CreateMandate(ctx context.Context, input CreateMandateInput) (*domain.Mandate, error) {
+	if _, err := s.billingService.GetLoanByID(ctx, input.LoanID); err != nil {
+		return nil, err
+	}
+
+	var mandate *domain.Mandate
+	err := s.repo.WithTx(ctx, func(repo domain.CollectionRepository) error {
+		m, err := repo.InsertMandate(ctx, domain.CreateMandateCommand{
+			LoanID:        input.LoanID,
+			AccountHolder: input.AccountHolder,
+			BankCode:      input.BankCode,
+			AccountNumber: input.AccountNumber,
+			Reference:     input.Reference,
+		})
+		if err != nil {
+			return err
+		}
+		mandate = m
+		return recordAudit(ctx, repo, domain.AuditActionMandateCreate, domain.AuditEntityMandate, m.ID, nil, mandateAuditSnapshot(m))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mandate, nil
+}
+
+/*
+GetMandate get the active mandate of a loan
+*/
+func (s *CollectionService) GetMandate(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	return s.repo.GetActiveMandateByLoanID(ctx, loanID)
+}
+
+/*
+RevokeMandate stops future collections for a loan, items already submitted to the bank are not affected
+*/
+func (s *CollectionService) RevokeMandate(ctx context.Context, loanID int64) (*domain.Mandate, error) {
+	var mandate *domain.Mandate
+	err := s.repo.WithTx(ctx, func(repo domain.CollectionRepository) error {
+		before, err := repo.GetActiveMandateByLoanID(ctx, loanID)
+		if err != nil {
+			return err
+		}
+		m, err := repo.RevokeMandate(ctx, loanID)
+		if err != nil {
+			return err
+		}
+		mandate = m
+		return recordAudit(ctx, repo, domain.AuditActionMandateRevoke, domain.AuditEntityMandate, m.ID, mandateAuditSnapshot(before), mandateAuditSnapshot(m))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mandate, nil
+}
+
+/*
+RunCollection builds the collection batch for the given date.
+
+Every schedule that is due on or before the collection date, not yet paid and backed by an active mandate
+becomes an item of the batch, including failed items whose retry date has been reached.
+Schedules already submitted to the bank, collected, or exhausted their retries are skipped,
+so running it more than once for the same date is safe. Returns nil when there is nothing to collect.
+*/
+func (s *CollectionService) RunCollection(ctx context.Context, collectionDate time.Time) (*domain.CollectionBatch, error) {
+	collectionDate = truncateToDate(collectionDate)
+
+	var batch *domain.CollectionBatch
+	err := s.repo.WithTx(ctx, func(repo domain.CollectionRepository) error {
+		// other instances wait here until this run is committed
+		if err := repo.LockCollectionRun(ctx); err != nil {
+			return err
+		}
+
+		candidates, err := repo.ListCollectionCandidates(ctx, collectionDate)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+
+		var total int64
+		for _, c := range candidates {
+			total += c.Amount
+		}
+
+		b, err := repo.InsertCollectionBatch(ctx, domain.CreateCollectionBatchCommand{
+			CollectionDate: collectionDate,
+			ItemCount:      int32(len(candidates)),
+			TotalAmount:    total,
+		})
+		if err != nil {
+			return err
+		}
+
+		items := make([]domain.CreateCollectionItemCommand, len(candidates))
+		for i, c := range candidates {
+			items[i] = domain.CreateCollectionItemCommand{
+				BatchID:          b.ID,
+				LoanID:           c.LoanID,
+				MandateID:        c.MandateID,
+				ScheduleSequence: int32(c.ScheduleSequence),
+				Amount:           c.Amount,
+				Attempt:          int32(c.PreviousAttempts + 1),
+			}
+		}
+		if _, err := repo.CreateCollectionItems(ctx, items); err != nil {
+			return err
+		}
+
+		// retries picked up by this batch should not be collected again
+		if err := repo.ConsumeDueRetries(ctx, collectionDate); err != nil {
+			return err
+		}
+
+		err = recordAudit(ctx, repo, domain.AuditActionCollectionBatchCreate, domain.AuditEntityCollectionBatch, b.ID, nil, collectionBatchAuditSnapshot(b))
+		if err != nil {
+			return err
+		}
+
+		batch = b
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if batch != nil {
+		slog.InfoContext(ctx, "collection_batch_created",
+			slog.Int64("batch_id", batch.ID),
+			slog.String("collection_date", collectionDate.Format("2006-01-02")),
+			slog.Int("item_count", batch.ItemCount),
+			slog.Int64("total_amount", batch.TotalAmount),
+		)
+	}
+	return batch, nil
+}
+
+/*
+GetBatch get a batch header together with its items
+*/
+func (s *CollectionService) GetBatch(ctx context.Context, batchID int64) (*domain.CollectionBatch, []domain.CollectionItem, error) {
+	batch, err := s.repo.GetCollectionBatchByID(ctx, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.repo.ListCollectionItemsByBatchID(ctx, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, items, nil
+}
+
+/*
+ExportBatch writes the bank file of a batch and marks it as exported.
+Exporting the same batch again produces the same file, so a lost upload can simply be repeated.
+*/
+func (s *CollectionService) ExportBatch(ctx context.Context, batchID int64, format BankFileFormat, w io.Writer) error {
+	batch, items, err := s.GetBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+
+	if batch.Status == domain.CollectionBatchStatusCreated {
+		err := s.repo.WithTx(ctx, func(repo domain.CollectionRepository) error {
+			return updateBatchStatus(ctx, repo, batch, domain.CollectionBatchStatusExported, domain.AuditActionCollectionBatchExport)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return WriteCollectionFile(w, format, batch, items)
+}
+
+/*
+ImportResults reconciles the bank's result file of a batch.
+
+Successful debits are posted through the payment flow of BillingService using an idempotency key derived from the item,
+so importing the same file twice never posts a payment twice. A debit whose payment can not be posted, the loan was
+paid off by hand since the export for instance, is left for manual review without failing the file. A debit pays the
+installment it was made for, never the next unpaid one. Failed debits are scheduled for another
+attempt according to the retry policy, or marked as failed once the policy gives up.
+The file is settled in one transaction, a failure leaves the batch as it was. The bank may send the results of a batch
+over several files, the batch is reconciled once none of its items is left submitted.
+*/
+func (s *CollectionService) ImportResults(ctx context.Context, batchID int64, format BankFileFormat, r io.Reader) (*CollectionImportSummary, error) {
+	results, err := ParseCollectionResultFile(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary *CollectionImportSummary
+	var events loanEvents
+	var posted int
+	err = s.repo.WithTx(ctx, func(repo domain.CollectionRepository) error {
+		summary, events, posted = &CollectionImportSummary{BatchID: batchID}, nil, 0
+
+		// concurrent imports of the batch wait here, each one sees the items settled by the previous one
+		batch, err := repo.LockCollectionBatchForUpdate(ctx, batchID)
+		if err != nil {
+			return err
+		}
+		if batch.Status == domain.CollectionBatchStatusReconciled {
+			return domain.ErrCollectionBatchClosed
+		}
+
+		items, err := repo.ListCollectionItemsByBatchID(ctx, batchID)
+		if err != nil {
+			return err
+		}
+		itemsByID := make(map[int64]domain.CollectionItem, len(items))
+		for _, item := range items {
+			itemsByID[item.ID] = item
+		}
+
+		// validate the whole file before posting anything
+		for _, result := range results {
+			if _, ok := itemsByID[result.ItemID]; !ok {
+				return fmt.Errorf("%w: item %d does not belong to batch %d", domain.ErrInvalidBankFile, result.ItemID, batchID)
+			}
+		}
+
+		// the items are numbered in schedule order, the installments of a loan are posted in week order whatever the file's
+		slices.SortStableFunc(results, func(a, b domain.CollectionResult) int { return cmp.Compare(a.ItemID, b.ItemID) })
+		settled := make(map[int64]bool, len(results))
+		for _, result := range results {
+			item := itemsByID[result.ItemID]
+			if item.Status != domain.CollectionItemStatusSubmitted || settled[item.ID] {
+				summary.Skipped++
+				continue
+			}
+			settled[item.ID] = true
+
+			if result.Succeeded {
+				status, newPayment, err := s.settleSucceededItem(ctx, repo, item, &events)
+				if err != nil {
+					return err
+				}
+				if newPayment {
+					posted++
+				}
+				if status == domain.CollectionItemStatusUnposted {
+					summary.Unposted++
+				} else {
+					summary.Succeeded++
+				}
+				continue
+			}
+
+			retried, err := s.settleFailedItem(ctx, repo, batch, item, result.ReasonCode)
+			if err != nil {
+				return err
+			}
+			if retried {
+				summary.RetryScheduled++
+			} else {
+				summary.Failed++
+			}
+		}
+
+		for _, item := range items {
+			if item.Status == domain.CollectionItemStatusSubmitted && !settled[item.ID] {
+				summary.Pending++
+			}
+		}
+		if summary.Pending > 0 {
+			return nil
+		}
+		return updateBatchStatus(ctx, repo, batch, domain.CollectionBatchStatusReconciled, domain.AuditActionCollectionBatchReconcile)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if posted > 0 {
+		metrics.PaymentsPosted.WithLabelValues(domain.TenantFromContext(ctx)).Add(float64(posted))
+	}
+	s.billingService.bus.Publish(events...)
+
+	msg := "collection_batch_reconciled"
+	if summary.Pending > 0 {
+		msg = "collection_batch_partially_settled"
+	}
+	slog.InfoContext(ctx, msg,
+		slog.Int64("batch_id", batchID),
+		slog.Int("succeeded", summary.Succeeded),
+		slog.Int("retry_scheduled", summary.RetryScheduled),
+		slog.Int("failed", summary.Failed),
+		slog.Int("skipped", summary.Skipped),
+		slog.Int("unposted", summary.Unposted),
+		slog.Int("pending", summary.Pending),
+	)
+	return summary, nil
+}
+
+// updateBatchStatus moves the batch to status and audits it as action in the transaction of repo, before is the batch as last read
+func updateBatchStatus(ctx context.Context, repo domain.CollectionRepository, before *domain.CollectionBatch, status, action string) error {
+	after, err := repo.UpdateCollectionBatchStatus(ctx, before.ID, status)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, repo, action, domain.AuditEntityCollectionBatch, after.ID, collectionBatchAuditSnapshot(before), collectionBatchAuditSnapshot(after))
+}
+
+// unpostableErrors are the payment errors that leave a collected item for manual review rather than failing the import
+var unpostableErrors = []error{
+	domain.ErrLoanNotFound,
+	domain.ErrLoanAlreadyClosed,
+	domain.ErrInvalidPayment,
+	domain.ErrInstallmentAlreadyPaid,
+	domain.ErrInstallmentOutOfOrder,
+}
+
+/*
+settleSucceededItem posts the payment of the item unless its key already has one, newPayment tells which.
+The checks of the payment flow fail before it writes anything, so when one of unpostableErrors stops it the item is
+marked unposted in the same transaction and the import goes on.
+*/
+func (s *CollectionService) settleSucceededItem(ctx context.Context, repo domain.CollectionRepository, item domain.CollectionItem, events *loanEvents) (status string, newPayment bool, err error) {
+	cmd := domain.UpdateCollectionItemResultCommand{
+		ItemID:  item.ID,
+		BatchID: item.BatchID,
+		Status:  domain.CollectionItemStatusSucceeded,
+	}
+
+	billingRepo := repo.Billing()
+	key := collectionIdempotencyKey(item.ID)
+	existing, err := billingRepo.GetPaymentByIdempotencyKey(ctx, key)
+	switch {
+	case err == nil:
+		// posted before the item was settled, by an import of an earlier release or a payment sent with the same key
+		slog.WarnContext(ctx, "collection_item_already_posted", slog.Int64("item_id", item.ID), slog.Int64("payment_id", existing.ID))
+		cmd.PaymentID = &existing.ID
+	case errors.Is(err, domain.ErrPaymentNotFound):
+		paymentID, err := s.billingService.postPayment(ctx, billingRepo, SubmitPaymentInput{
+			LoanID:         item.LoanID,
+			Amount:         item.Amount,
+			PaidAt:         time.Now(),
+			IdempotencyKey: key,
+			Sequence:       int32(item.ScheduleSequence),
+		}, events)
+		switch {
+		case err == nil:
+			cmd.PaymentID = &paymentID
+			newPayment = true
+		case slices.ContainsFunc(unpostableErrors, func(target error) bool { return errors.Is(err, target) }):
+			code, _ := domain.ErrorCode(err)
+			slog.WarnContext(ctx, "collection_item_unposted", slog.Int64("item_id", item.ID), slog.Int64("loan_id", item.LoanID), slog.String("reason", code))
+			cmd.Status = domain.CollectionItemStatusUnposted
+			cmd.FailureCode = code
+		default:
+			return "", false, fmt.Errorf("posting collection item %d: %w", item.ID, err)
+		}
+	default:
+		return "", false, err
+	}
+
+	_, err = repo.UpdateCollectionItemResult(ctx, cmd)
+	return cmd.Status, newPayment, err
+}
+
+func (s *CollectionService) settleFailedItem(ctx context.Context, repo domain.CollectionRepository, batch *domain.CollectionBatch, item domain.CollectionItem, reasonCode string) (bool, error) {
+	cmd := domain.UpdateCollectionItemResultCommand{
+		ItemID:      item.ID,
+		BatchID:     item.BatchID,
+		Status:      domain.CollectionItemStatusFailed,
+		FailureCode: reasonCode,
+	}
+
+	nextAttemptOn, retry := s.policy.NextAttempt(item.Attempt, reasonCode, batch.CollectionDate)
+	if retry {
+		cmd.Status = domain.CollectionItemStatusRetryPending
+		cmd.NextAttemptOn = &nextAttemptOn
+	}
+
+	_, err := repo.UpdateCollectionItemResult(ctx, cmd)
+	return retry, err
+}
+
+func collectionIdempotencyKey(itemID int64) string {
+	return fmt.Sprintf("direct-debit-%d", itemID)
+}
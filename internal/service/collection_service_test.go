@@ -0,0 +1,306 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/memory"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_NextAttempt(t *testing.T) {
+	policy := NewRetryPolicy([]int{3, 7}, []string{"ac04"})
+	collectionDate := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("first failure is retried after the first backoff", func(t *testing.T) {
+		next, ok := policy.NextAttempt(1, "AM04", collectionDate)
+		assert.True(t, ok)
+		assert.Equal(t, collectionDate.AddDate(0, 0, 3), next)
+	})
+
+	t.Run("last allowed attempt is final", func(t *testing.T) {
+		_, ok := policy.NextAttempt(policy.MaxAttempts(), "AM04", collectionDate)
+		assert.False(t, ok)
+	})
+
+	t.Run("non retryable reason code fails immediately", func(t *testing.T) {
+		_, ok := policy.NextAttempt(1, "AC04", collectionDate)
+		assert.False(t, ok)
+	})
+}
+
+func TestCollectionFile_Fixed(t *testing.T) {
+	batch := &domain.CollectionBatch{
+		ID:             12,
+		CollectionDate: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+	items := []domain.CollectionItem{
+		{ID: 101, Amount: 110000, BankCode: "BCA", AccountNumber: "1234567890", AccountHolder: "Jane Doe", MandateReference: "MDT-1"},
+		{ID: 102, Amount: 220000, BankCode: "BNI", AccountNumber: "0987654321", AccountHolder: "John Doe", MandateReference: "MDT-2"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteCollectionFile(&buf, BankFileFormatFixed, batch, items)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "H000000001220260302000002000000000330000", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "D000000000101BCA             "))
+	assert.Len(t, lines[1], 1+12+16+34+35+15+35)
+	assert.Equal(t, "T000002000000000330000", lines[3])
+}
+
+func TestCollectionResultFile_Parse(t *testing.T) {
+	t.Run("csv with header", func(t *testing.T) {
+		file := "item_id,status,reason_code\n101,SUCCESS,\n102,FAILED,am04\n"
+		results, err := ParseCollectionResultFile(strings.NewReader(file), BankFileFormatCSV)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.CollectionResult{
+			{ItemID: 101, Succeeded: true},
+			{ItemID: 102, Succeeded: false, ReasonCode: "AM04"},
+		}, results)
+	})
+
+	t.Run("fixed width skips header and trailer", func(t *testing.T) {
+		file := "H0000000012\nD000000000101S\nD000000000102FAM04\nT000002\n"
+		results, err := ParseCollectionResultFile(strings.NewReader(file), BankFileFormatFixed)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.CollectionResult{
+			{ItemID: 101, Succeeded: true},
+			{ItemID: 102, Succeeded: false, ReasonCode: "AM04"},
+		}, results)
+	})
+
+	t.Run("invalid status is rejected", func(t *testing.T) {
+		_, err := ParseCollectionResultFile(strings.NewReader("101,MAYBE\n"), BankFileFormatCSV)
+		assert.ErrorIs(t, err, domain.ErrInvalidBankFile)
+	})
+}
+
+// newCollectionFixture runs a collection over one mandated loan per account number, the loans start a week before collectionDate
+func newCollectionFixture(t *testing.T, collectionDate time.Time, accounts ...string) (*CollectionService, *memory.BillingRepo, *domain.CollectionBatch, []domain.CollectionItem) {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	billingRepo := memory.NewBillingRepo(store)
+	billingService := NewBillingService(nil, billingRepo, nil, nil)
+	svc := NewCollectionService(memory.NewCollectionRepo(store), billingService, NewRetryPolicy([]int{3}, nil))
+
+	for _, account := range accounts {
+		loan, err := billingService.SubmitLoan(ctx, SubmitLoanInput{
+			PrincipalAmount:    5000000,
+			AnnualInterestRate: 0.10,
+			TotalWeeks:         50,
+			StartDate:          collectionDate.AddDate(0, 0, -7),
+		})
+		require.NoError(t, err)
+		_, err = svc.CreateMandate(ctx, CreateMandateInput{LoanID: loan.ID, AccountHolder: "Jane Doe", BankCode: "BCA", AccountNumber: account, Reference: "MDT-" + account})
+		require.NoError(t, err)
+	}
+
+	batch, err := svc.RunCollection(ctx, collectionDate)
+	require.NoError(t, err)
+	require.NotNil(t, batch)
+	_, items, err := svc.GetBatch(ctx, batch.ID)
+	require.NoError(t, err)
+	require.Len(t, items, len(accounts))
+	return svc, billingRepo, batch, items
+}
+
+func TestCollectionService_ImportResults(t *testing.T) {
+	ctx := context.Background()
+	collectionDate := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("a partial file leaves the batch open", func(t *testing.T) {
+		svc, _, batch, items := newCollectionFixture(t, collectionDate, "111", "222")
+
+		summary, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,SUCCESS\n", items[0].ID)))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Succeeded)
+		assert.Equal(t, 1, summary.Pending)
+		got, _, err := svc.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, domain.CollectionBatchStatusReconciled, got.Status)
+
+		summary, err = svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,SUCCESS\n%d,FAILED,AM04\n", items[0].ID, items[1].ID)))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Skipped)
+		assert.Equal(t, 1, summary.RetryScheduled)
+		assert.Equal(t, 0, summary.Pending)
+		got, _, err = svc.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CollectionBatchStatusReconciled, got.Status)
+	})
+
+	t.Run("an item already posted records the existing payment", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111")
+		paymentID, err := svc.billingService.SubmitPayment(ctx, SubmitPaymentInput{
+			LoanID:         items[0].LoanID,
+			Amount:         items[0].Amount,
+			PaidAt:         collectionDate,
+			IdempotencyKey: collectionIdempotencyKey(items[0].ID),
+		})
+		require.NoError(t, err)
+
+		summary, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,SUCCESS\n", items[0].ID)))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Succeeded)
+
+		_, items, err = svc.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		require.NotNil(t, items[0].PaymentID)
+		assert.Equal(t, paymentID, *items[0].PaymentID)
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[0].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		assert.Len(t, payments, 1)
+	})
+
+	t.Run("a debit that can not be posted is left for manual review", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111", "222")
+		// the first loan is paid off by hand between the export and the import
+		loan, err := svc.billingService.GetLoanByID(ctx, items[0].LoanID)
+		require.NoError(t, err)
+		for week := 1; week <= loan.TotalWeeks; week++ {
+			_, err := svc.billingService.SubmitPayment(ctx, SubmitPaymentInput{
+				LoanID:         loan.ID,
+				Amount:         loan.WeeklyPaymentAmount,
+				PaidAt:         collectionDate,
+				IdempotencyKey: fmt.Sprintf("manual-%d", week),
+			})
+			require.NoError(t, err)
+		}
+
+		file := fmt.Sprintf("%d,SUCCESS\n%d,SUCCESS\n", items[0].ID, items[1].ID)
+		summary, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(file))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Unposted)
+		assert.Equal(t, 1, summary.Succeeded)
+
+		got, items, err := svc.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CollectionBatchStatusReconciled, got.Status)
+		assert.Equal(t, domain.CollectionItemStatusUnposted, items[0].Status)
+		assert.Equal(t, "loan_already_closed", items[0].FailureCode)
+		assert.Nil(t, items[0].PaymentID)
+		assert.Equal(t, domain.CollectionItemStatusSucceeded, items[1].Status)
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[1].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		assert.Len(t, payments, 1)
+	})
+
+	t.Run("a debit for an installment paid since is not moved to the next one", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111")
+		_, err := svc.billingService.SubmitPayment(ctx, SubmitPaymentInput{
+			LoanID:         items[0].LoanID,
+			Amount:         items[0].Amount,
+			PaidAt:         collectionDate,
+			IdempotencyKey: "manual-1",
+		})
+		require.NoError(t, err)
+
+		summary, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,SUCCESS\n", items[0].ID)))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Unposted)
+
+		_, items, err = svc.GetBatch(ctx, batch.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "installment_already_paid", items[0].FailureCode)
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[0].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		assert.Len(t, payments, 1)
+	})
+
+	t.Run("a debit after an unpaid installment is left for manual review", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111")
+		_, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,FAILED,AM04\n", items[0].ID)))
+		require.NoError(t, err)
+
+		// the retry of week 1 and week 2 are collected together, the bank only debits week 2
+		next, err := svc.RunCollection(ctx, collectionDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		require.NotNil(t, next)
+		_, items, err = svc.GetBatch(ctx, next.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, []int{1, 2}, []int{items[0].ScheduleSequence, items[1].ScheduleSequence})
+
+		file := fmt.Sprintf("%d,SUCCESS\n%d,FAILED,AM04\n", items[1].ID, items[0].ID)
+		summary, err := svc.ImportResults(ctx, next.ID, BankFileFormatCSV, strings.NewReader(file))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Unposted)
+		assert.Equal(t, 1, summary.Failed)
+
+		_, items, err = svc.GetBatch(ctx, next.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CollectionItemStatusUnposted, items[1].Status)
+		assert.Equal(t, "installment_out_of_order", items[1].FailureCode)
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[1].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		assert.Empty(t, payments)
+	})
+
+	t.Run("the installments of a loan are posted in week order whatever the file's", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111")
+		_, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,FAILED,AM04\n", items[0].ID)))
+		require.NoError(t, err)
+		next, err := svc.RunCollection(ctx, collectionDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		_, items, err = svc.GetBatch(ctx, next.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+
+		file := fmt.Sprintf("%d,SUCCESS\n%d,SUCCESS\n", items[1].ID, items[0].ID)
+		summary, err := svc.ImportResults(ctx, next.ID, BankFileFormatCSV, strings.NewReader(file))
+		require.NoError(t, err)
+		assert.Equal(t, 2, summary.Succeeded)
+
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[0].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		require.Len(t, payments, 2)
+		assert.ElementsMatch(t, []int{1, 2}, []int{payments[0].WeekNumber, payments[1].WeekNumber})
+	})
+
+	t.Run("an unknown item rejects the whole file", func(t *testing.T) {
+		svc, billingRepo, batch, items := newCollectionFixture(t, collectionDate, "111")
+
+		_, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(fmt.Sprintf("%d,SUCCESS\n999999,SUCCESS\n", items[0].ID)))
+		assert.ErrorIs(t, err, domain.ErrInvalidBankFile)
+		payments, err := billingRepo.ListPaymentsByLoanID(ctx, domain.ListPaymentsQuery{LoanID: items[0].LoanID, LimitVal: 10})
+		require.NoError(t, err)
+		assert.Empty(t, payments)
+	})
+}
+
+func TestCollectionService_RunCollection_KeepsRetriesWithoutMandate(t *testing.T) {
+	ctx := context.Background()
+	collectionDate := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	svc, _, batch, items := newCollectionFixture(t, collectionDate, "111", "222")
+
+	file := fmt.Sprintf("%d,FAILED,AM04\n%d,FAILED,AM04\n", items[0].ID, items[1].ID)
+	_, err := svc.ImportResults(ctx, batch.ID, BankFileFormatCSV, strings.NewReader(file))
+	require.NoError(t, err)
+	_, err = svc.RevokeMandate(ctx, items[0].LoanID)
+	require.NoError(t, err)
+
+	retryBatch, err := svc.RunCollection(ctx, collectionDate.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.NotNil(t, retryBatch)
+	_, retried, err := svc.GetBatch(ctx, retryBatch.ID)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	assert.Equal(t, items[1].LoanID, retried[0].LoanID)
+
+	_, items, err = svc.GetBatch(ctx, batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CollectionItemStatusRetryPending, items[0].Status)
+	assert.Equal(t, domain.CollectionItemStatusRetried, items[1].Status)
+}
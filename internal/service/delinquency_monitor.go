@@ -0,0 +1,44 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DelinquencyMonitor periodically announces loans that became delinquent through the outbox
+type DelinquencyMonitor struct {
+	service  *BillingService
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewDelinquencyMonitor(service *BillingService, interval time.Duration) *DelinquencyMonitor {
+	return &DelinquencyMonitor{
+		service:  service,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the detection loop in the background until ctx is cancelled
+func (m *DelinquencyMonitor) Start(ctx context.Context) {
+	go runEvery(ctx, m.interval, m.runOnce)
+}
+
+// runOnce checks every tenant in turn, with the delinquency gap of each
+func (m *DelinquencyMonitor) runOnce(ctx context.Context) {
+	now := m.now()
+	_ = m.service.Tenants().forEach(ctx, func(ctx context.Context) error {
+		flagged, err := m.service.DetectDelinquentLoans(ctx, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "delinquency_detection_failed", slog.String("tenant_id", domain.TenantFromContext(ctx)), slog.Any("err", err))
+			return err
+		}
+		if flagged > 0 {
+			slog.InfoContext(ctx, "delinquent_loans_detected", slog.String("tenant_id", domain.TenantFromContext(ctx)), slog.Int("count", flagged))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"sync"
+)
+
+// capacity of a subscriber channel, a subscriber falling further behind is disconnected
+const eventBusSubscriberBuffer = 256
+
+/*
+EventBus fans committed loan events out to in-process subscribers, eg the SSE streams.
+
+It keeps the last events in a ring buffer so a reconnecting client can resume from its last seen event id.
+The bus only sees the events committed by this instance, it complements the outbox and is not a replacement:
+events are dropped for subscribers that cannot keep up, and the buffer is lost on restart.
+A nil *EventBus is valid and discards everything.
+*/
+type EventBus struct {
+	mu          sync.Mutex
+	history     []domain.OutboxEvent
+	next        int // ring buffer write position
+	full        bool
+	subscribers map[*EventSubscription]struct{}
+	closed      bool // set by Close, new subscriptions end at once
+}
+
+// EventSubscription receives the events matching its filter until it is closed
+type EventSubscription struct {
+	bus    *EventBus
+	filter func(domain.OutboxEvent) bool
+	ch     chan domain.OutboxEvent
+	closed bool
+}
+
+func NewEventBus(historySize int) *EventBus {
+	return &EventBus{
+		history:     make([]domain.OutboxEvent, max(historySize, 1)),
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish hands the events to every matching subscriber without blocking
+func (b *EventBus) Publish(events ...domain.OutboxEvent) {
+	if b == nil || len(events) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		b.history[b.next] = event
+		b.next = (b.next + 1) % len(b.history)
+		if b.next == 0 {
+			b.full = true
+		}
+
+		for sub := range b.subscribers {
+			if sub.filter != nil && !sub.filter(event) {
+				continue
+			}
+			select {
+			case sub.ch <- event:
+			default:
+				// the client reconnects with its Last-Event-ID and catches up from the history
+				b.closeLocked(sub)
+			}
+		}
+	}
+}
+
+/*
+Subscribe registers a subscriber for the events matching filter (nil matches all).
+Buffered events with an id greater than afterID are returned for replay, pass 0 to only receive new events.
+*/
+func (b *EventBus) Subscribe(afterID int64, filter func(domain.OutboxEvent) bool) (*EventSubscription, []domain.OutboxEvent) {
+	sub := &EventSubscription{
+		bus:    b,
+		filter: filter,
+		ch:     make(chan domain.OutboxEvent, eventBusSubscriberBuffer),
+	}
+	if b == nil {
+		return sub, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		b.closeLocked(sub)
+		return sub, nil
+	}
+	var replay []domain.OutboxEvent
+	if afterID > 0 {
+		for _, event := range b.historyLocked() {
+			if event.ID > afterID && (filter == nil || filter(event)) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub, replay
+}
+
+/*
+Close ends every subscription and the ones made after it, on shutdown.
+An SSE stream only returns when its subscription ends, http.Server.Shutdown would otherwise wait for the clients
+to disconnect until its timeout.
+*/
+func (b *EventBus) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.closeLocked(sub)
+	}
+}
+
+// historyLocked returns the buffered events from oldest to newest
+func (b *EventBus) historyLocked() []domain.OutboxEvent {
+	if !b.full {
+		return b.history[:b.next]
+	}
+	return append(append([]domain.OutboxEvent{}, b.history[b.next:]...), b.history[:b.next]...)
+}
+
+func (b *EventBus) closeLocked(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+// Events is closed when the subscription ends, either by Close or because the subscriber fell behind
+func (s *EventSubscription) Events() <-chan domain.OutboxEvent {
+	return s.ch
+}
+
+func (s *EventSubscription) Close() {
+	if s.bus == nil {
+		return
+	}
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.closeLocked(s)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	loanEvent := func(id, loanID int64) domain.OutboxEvent {
+		return domain.OutboxEvent{ID: id, AggregateType: domain.AggregateTypeLoan, AggregateID: loanID}
+	}
+	forLoan := func(loanID int64) func(domain.OutboxEvent) bool {
+		return func(e domain.OutboxEvent) bool { return e.AggregateID == loanID }
+	}
+
+	t.Run("subscriber only receives matching events", func(t *testing.T) {
+		bus := NewEventBus(10)
+		sub, replay := bus.Subscribe(0, forLoan(1))
+		defer sub.Close()
+		assert.Empty(t, replay)
+
+		bus.Publish(loanEvent(1, 1), loanEvent(2, 2), loanEvent(3, 1))
+		assert.Equal(t, int64(1), (<-sub.Events()).ID)
+		assert.Equal(t, int64(3), (<-sub.Events()).ID)
+		assert.Len(t, sub.Events(), 0)
+	})
+
+	t.Run("resume replays buffered events after the last event id", func(t *testing.T) {
+		bus := NewEventBus(3)
+		bus.Publish(loanEvent(1, 1), loanEvent(2, 1), loanEvent(3, 1), loanEvent(4, 1))
+
+		sub, replay := bus.Subscribe(2, nil)
+		defer sub.Close()
+		assert.Equal(t, []domain.OutboxEvent{loanEvent(3, 1), loanEvent(4, 1)}, replay)
+	})
+
+	t.Run("subscriber falling behind is disconnected", func(t *testing.T) {
+		bus := NewEventBus(10)
+		sub, _ := bus.Subscribe(0, nil)
+		for i := 0; i <= eventBusSubscriberBuffer; i++ {
+			bus.Publish(loanEvent(int64(i+1), 1))
+		}
+
+		received := 0
+		for range sub.Events() {
+			received++
+		}
+		assert.Equal(t, eventBusSubscriberBuffer, received)
+	})
+
+	t.Run("close ends current and later subscriptions", func(t *testing.T) {
+		bus := NewEventBus(10)
+		bus.Publish(loanEvent(1, 1))
+		sub, _ := bus.Subscribe(0, nil)
+
+		bus.Close()
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+
+		later, replay := bus.Subscribe(0, nil)
+		_, ok = <-later.Events()
+		assert.False(t, ok)
+		assert.Empty(t, replay)
+		later.Close()
+		bus.Publish(loanEvent(2, 1))
+	})
+
+	t.Run("nil bus discards events", func(t *testing.T) {
+		var bus *EventBus
+		bus.Publish(loanEvent(1, 1))
+		sub, replay := bus.Subscribe(0, nil)
+		sub.Close()
+		bus.Close()
+		assert.Empty(t, replay)
+	})
+}
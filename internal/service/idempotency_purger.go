@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// IdempotencyPurger periodically deletes the expired idempotency keys
+type IdempotencyPurger struct {
+	service  *IdempotencyService
+	interval time.Duration
+}
+
+func NewIdempotencyPurger(service *IdempotencyService, interval time.Duration) *IdempotencyPurger {
+	return &IdempotencyPurger{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Start runs the purge loop in the background until ctx is cancelled
+func (p *IdempotencyPurger) Start(ctx context.Context) {
+	go runEvery(ctx, p.interval, p.runOnce)
+}
+
+func (p *IdempotencyPurger) runOnce(ctx context.Context) {
+	deleted, err := p.service.PurgeExpired(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "idempotency_purge_failed", slog.Any("err", err))
+		return
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "idempotency_keys_purged", slog.Int64("count", deleted))
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+/*
+IdempotencyService makes retried writes safe by remembering the response of every idempotency key.
+
+A key is claimed before the request runs and completed with the response afterwards, so a retry replays
+the exact same response. A key reused for a different request is rejected, as is a duplicate arriving while
+the original is still running. Keys expire after the TTL, and a key whose request never completed
+(eg the instance crashed) can be claimed again after the lock timeout.
+*/
+type IdempotencyService struct {
+	repo        domain.IdempotencyRepository
+	ttl         time.Duration
+	lockTimeout time.Duration
+	now         func() time.Time
+}
+
+func NewIdempotencyService(repo domain.IdempotencyRepository, ttl, lockTimeout time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo:        repo,
+		ttl:         ttl,
+		lockTimeout: lockTimeout,
+		now:         time.Now,
+	}
+}
+
+// FingerprintRequest identifies a request by its method, path and body, so a reused key can be told apart from a retry
+func FingerprintRequest(method, path string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	h := sha256.New()
+	h.Write([]byte(method + "\n" + path + "\n"))
+	h.Write(bodyHash[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/*
+Begin claims the key for a request. It returns nil when the caller owns the key and must run the request,
+or the completed record whose response has to be replayed instead.
+*/
+func (s *IdempotencyService) Begin(ctx context.Context, key, requestHash string) (*domain.IdempotencyRecord, error) {
+	now := s.now()
+	record, acquired, err := s.repo.AcquireIdempotencyKey(ctx, domain.AcquireIdempotencyKeyCommand{
+		Key:         key,
+		RequestHash: requestHash,
+		Now:         now,
+		ExpiresAt:   now.Add(s.ttl),
+		StaleBefore: now.Add(-s.lockTimeout),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if acquired {
+		return nil, nil
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, domain.ErrIdempotencyKeyMismatch
+	}
+	if record.Status != domain.IdempotencyStatusCompleted {
+		return nil, domain.ErrIdempotencyKeyInFlight
+	}
+	return record, nil
+}
+
+// Complete stores the response of a claimed key for later replays
+func (s *IdempotencyService) Complete(ctx context.Context, key string, status int, contentType string, body []byte) error {
+	return s.repo.CompleteIdempotencyKey(ctx, domain.CompleteIdempotencyKeyCommand{
+		Key:                 key,
+		ResponseStatus:      status,
+		ResponseContentType: contentType,
+		ResponseBody:        body,
+	})
+}
+
+// Release frees a claimed key without storing a response, used when the request failed in a retryable way
+func (s *IdempotencyService) Release(ctx context.Context, key string) error {
+	return s.repo.ReleaseIdempotencyKey(ctx, key)
+}
+
+// PurgeExpired deletes the keys past their TTL
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpiredIdempotencyKeys(ctx, s.now())
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIdempotencyRepo keeps the keys in memory with the same takeover rules as the SQL
+type fakeIdempotencyRepo struct {
+	records map[string]*domain.IdempotencyRecord
+	locked  map[string]time.Time
+}
+
+func newFakeIdempotencyRepo() *fakeIdempotencyRepo {
+	return &fakeIdempotencyRepo{
+		records: make(map[string]*domain.IdempotencyRecord),
+		locked:  make(map[string]time.Time),
+	}
+}
+
+func (f *fakeIdempotencyRepo) AcquireIdempotencyKey(ctx context.Context, arg domain.AcquireIdempotencyKeyCommand) (*domain.IdempotencyRecord, bool, error) {
+	existing, ok := f.records[arg.Key]
+	stale := ok && existing.Status == domain.IdempotencyStatusInProgress && !f.locked[arg.Key].After(arg.StaleBefore)
+	if ok && existing.ExpiresAt.After(arg.Now) && !stale {
+		return existing, false, nil
+	}
+	record := &domain.IdempotencyRecord{
+		Key:         arg.Key,
+		RequestHash: arg.RequestHash,
+		Status:      domain.IdempotencyStatusInProgress,
+		CreatedAt:   arg.Now,
+		ExpiresAt:   arg.ExpiresAt,
+	}
+	f.records[arg.Key] = record
+	f.locked[arg.Key] = arg.Now
+	return record, true, nil
+}
+
+func (f *fakeIdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, arg domain.CompleteIdempotencyKeyCommand) error {
+	record := f.records[arg.Key]
+	record.Status = domain.IdempotencyStatusCompleted
+	record.ResponseStatus = arg.ResponseStatus
+	record.ResponseContentType = arg.ResponseContentType
+	record.ResponseBody = arg.ResponseBody
+	return nil
+}
+
+func (f *fakeIdempotencyRepo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	delete(f.records, key)
+	return nil
+}
+
+func (f *fakeIdempotencyRepo) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	hash := FingerprintRequest("POST", "/loan/1/payment", []byte(`{"amount":110000}`))
+
+	newService := func() *IdempotencyService {
+		svc := NewIdempotencyService(newFakeIdempotencyRepo(), 24*time.Hour, time.Minute)
+		svc.now = func() time.Time { return now }
+		return svc
+	}
+
+	t.Run("completed key replays the stored response", func(t *testing.T) {
+		svc := newService()
+		record, err := svc.Begin(ctx, "key-1", hash)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+		assert.NoError(t, svc.Complete(ctx, "key-1", 201, "application/json", []byte(`{"payment_id":9}`)))
+
+		record, err = svc.Begin(ctx, "key-1", hash)
+		assert.NoError(t, err)
+		assert.Equal(t, 201, record.ResponseStatus)
+		assert.Equal(t, `{"payment_id":9}`, string(record.ResponseBody))
+	})
+
+	t.Run("same key with a different body is rejected", func(t *testing.T) {
+		svc := newService()
+		_, _ = svc.Begin(ctx, "key-1", hash)
+
+		other := FingerprintRequest("POST", "/loan/1/payment", []byte(`{"amount":1}`))
+		_, err := svc.Begin(ctx, "key-1", other)
+		assert.ErrorIs(t, err, domain.ErrIdempotencyKeyMismatch)
+	})
+
+	t.Run("duplicate of a running request is rejected until the lock times out", func(t *testing.T) {
+		svc := newService()
+		_, _ = svc.Begin(ctx, "key-1", hash)
+
+		_, err := svc.Begin(ctx, "key-1", hash)
+		assert.ErrorIs(t, err, domain.ErrIdempotencyKeyInFlight)
+
+		now = now.Add(2 * time.Minute)
+		record, err := svc.Begin(ctx, "key-1", hash)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("released key can be used again", func(t *testing.T) {
+		svc := newService()
+		_, _ = svc.Begin(ctx, "key-1", hash)
+		assert.NoError(t, svc.Release(ctx, "key-1"))
+
+		record, err := svc.Begin(ctx, "key-1", hash)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTVerifierOptions struct {
+	HMACSecret []byte // verifies HS256/384/512 tokens
+	JWKSFile   string // public keys of the identity provider, verifies RS*, PS*, ES* and EdDSA tokens
+	Issuer     string // checked against the iss claim when set
+	Audience   string // checked against the aud claim when set
+	Leeway     time.Duration
+}
+
+/*
+JWTVerifier validates bearer tokens issued by an identity provider.
+
+Tokens must be signed with the HMAC secret or one of the keys of the JWKS file, picked by the kid header,
+and carry an exp claim. The JWKS file is read again when a token names an unknown kid and the file changed,
+so keys rotated by the provider are picked up without a restart.
+*/
+type JWTVerifier struct {
+	opts    JWTVerifierOptions
+	methods []string
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	modified time.Time
+}
+
+// jwtClaims holds the scopes as the space separated scope claim of OAuth 2.0, the roles of the RBAC policy and the tenant of the caller
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope    string   `json:"scope"`
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenant_id"` // optional, the default tenant when missing
+}
+
+func NewJWTVerifier(opts JWTVerifierOptions) (*JWTVerifier, error) {
+	if len(opts.HMACSecret) == 0 && opts.JWKSFile == "" {
+		return nil, errors.New("jwt: an HMAC secret or a JWKS file is required")
+	}
+
+	v := &JWTVerifier{opts: opts}
+	if len(opts.HMACSecret) > 0 {
+		v.methods = append(v.methods, "HS256", "HS384", "HS512")
+	}
+	if opts.JWKSFile != "" {
+		v.methods = append(v.methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+		if err := v.loadKeys(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Verify checks the token and returns its subject, tenant, scopes and roles
+func (v *JWTVerifier) Verify(token string) (*domain.Principal, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.opts.Leeway),
+	}
+	if v.opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.opts.Issuer))
+	}
+	if v.opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(v.opts.Audience))
+	}
+
+	var claims jwtClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, v.key, parserOpts...); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("jwt: sub claim is missing")
+	}
+	return &domain.Principal{
+		Kind:     domain.PrincipalKindJWT,
+		Subject:  claims.Subject,
+		TenantID: claims.TenantID,
+		Scopes:   strings.Fields(claims.Scope),
+		Roles:    claims.Roles,
+	}, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	if strings.HasPrefix(token.Method.Alg(), "HS") {
+		return v.opts.HMACSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	if err := v.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("jwt: unknown key id %q", kid)
+}
+
+// lookup finds the key by kid, a token without kid is accepted when the JWKS holds a single key
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *JWTVerifier) reloadIfChanged() error {
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("jwt: %w", err)
+	}
+	v.mu.RLock()
+	changed := !info.ModTime().Equal(v.modified)
+	v.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return v.loadKeys()
+}
+
+func (v *JWTVerifier) loadKeys() error {
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("jwt: %w", err)
+	}
+	data, err := os.ReadFile(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("jwt: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("jwt: %s: %w", v.opts.JWKSFile, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.modified = info.ModTime()
+	return nil
+}
+
+// jwk is a public key of a JSON Web Key Set (RFC 7517), private members are ignored
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS reads the RSA, EC and Ed25519 signing keys of a key set by kid, keys meant for encryption are skipped
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
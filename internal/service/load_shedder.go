@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RequestPriority tells which requests the load shedder rejects first
+type RequestPriority string
+
+const (
+	// PriorityCritical is for the payment writes, they may fill the whole concurrency limit
+	PriorityCritical RequestPriority = "critical"
+	PriorityNormal   RequestPriority = "normal"
+	// PriorityLow is for the reporting reads, the first to be shed
+	PriorityLow RequestPriority = "low"
+	// PriorityExempt requests are neither counted nor shed, for the event streams and the shedder state itself
+	PriorityExempt RequestPriority = "exempt"
+)
+
+// priorityShares is the share of the concurrency limit a priority may fill, the rest is kept for the higher ones
+var priorityShares = map[RequestPriority]float64{
+	PriorityCritical: 1,
+	PriorityNormal:   0.8,
+	PriorityLow:      0.5,
+}
+
+// PoolStats is the part of pgxpool.Stat the load shedder watches, the acquisitions are counted since the pool started
+type PoolStats struct {
+	AcquireCount    int64
+	AcquireDuration time.Duration // of the successful acquisitions only
+	// CanceledAcquireCount are the acquisitions given up, by a request timing out on the pool for instance
+	CanceledAcquireCount int64
+	// EmptyAcquireCount are the successful acquisitions that waited for a connection to be released or opened
+	EmptyAcquireCount int64
+	AcquiredConns     int32
+	MaxConns          int32
+}
+
+// PgxPoolStats reads the statistics of the pool for the load shedder
+func PgxPoolStats(pool *pgxpool.Pool) func() PoolStats {
+	return func() PoolStats {
+		stat := pool.Stat()
+		return PoolStats{
+			AcquireCount:         stat.AcquireCount(),
+			AcquireDuration:      stat.AcquireDuration(),
+			CanceledAcquireCount: stat.CanceledAcquireCount(),
+			EmptyAcquireCount:    stat.EmptyAcquireCount(),
+			AcquiredConns:        stat.AcquiredConns(),
+			MaxConns:             stat.MaxConns(),
+		}
+	}
+}
+
+type LoadShedderOptions struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// TargetAcquireLatency is the average wait for a pool connection above which the limit is lowered
+	TargetAcquireLatency time.Duration
+	// Interval between two adjustments of the limit
+	Interval time.Duration
+}
+
+// LoadShedderState is the state exposed on the admin endpoint
+type LoadShedderState struct {
+	Limit                int
+	MinLimit             int
+	MaxLimit             int
+	InFlight             int
+	AcquireLatency       time.Duration // average over the last interval
+	TargetAcquireLatency time.Duration
+	PoolAcquiredConns    int32
+	PoolMaxConns         int32
+	Admitted             int64
+	Shed                 map[RequestPriority]int64
+}
+
+/*
+LoadShedder caps the requests running at once, so that a slow database makes the API reject work early
+instead of queueing it on the pool until the repository timeouts fire.
+
+The limit adapts, additive increase and multiplicative decrease: it is lowered when the average wait for a pool
+connection exceeds the target or an acquisition was given up, and raised while the target is met, no acquisition
+found the pool empty and the requests use most of the limit. The given up acquisitions are not in the average,
+pgxpool only times the successful ones, so the average alone looks healthy while requests time out on the pool.
+Low priority requests may only fill part of the limit, so the reporting reads are shed before the payment writes.
+*/
+type LoadShedder struct {
+	opts      LoadShedderOptions
+	poolStats func() PoolStats // nil without a database, the limit then stays where it is
+
+	mu             sync.Mutex
+	limit          float64
+	inFlight       int
+	peakInFlight   int // since the last adjustment
+	lastPool       PoolStats
+	acquireLatency time.Duration
+	admitted       int64
+	shed           map[RequestPriority]int64
+}
+
+func NewLoadShedder(poolStats func() PoolStats, opts LoadShedderOptions) *LoadShedder {
+	s := &LoadShedder{
+		opts:      opts,
+		poolStats: poolStats,
+		limit:     float64(min(max(opts.InitialLimit, opts.MinLimit), opts.MaxLimit)),
+		shed:      make(map[RequestPriority]int64),
+	}
+	if poolStats != nil {
+		s.lastPool = poolStats()
+	}
+	return s
+}
+
+// Start adjusts the limit in the background until ctx is cancelled
+func (s *LoadShedder) Start(ctx context.Context) {
+	if s.poolStats == nil {
+		return
+	}
+	go runEvery(ctx, s.opts.Interval, s.adjust)
+}
+
+// Acquire admits a request of the given priority, release must be called once it is done. ok is false when it is shed
+func (s *LoadShedder) Acquire(priority RequestPriority) (release func(), ok bool) {
+	if priority == PriorityExempt {
+		return func() {}, true
+	}
+	share, known := priorityShares[priority]
+	if !known {
+		share = priorityShares[PriorityNormal]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if float64(s.inFlight) >= math.Max(1, math.Floor(s.limit*share)) {
+		s.shed[priority]++
+		return nil, false
+	}
+	s.inFlight++
+	s.peakInFlight = max(s.peakInFlight, s.inFlight)
+	s.admitted++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.inFlight--
+		})
+	}, true
+}
+
+// adjust moves the limit after the pool acquisitions of the last interval
+func (s *LoadShedder) adjust(ctx context.Context) {
+	stats := s.poolStats()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.acquireLatency = 0
+	if acquired := stats.AcquireCount - s.lastPool.AcquireCount; acquired > 0 {
+		s.acquireLatency = (stats.AcquireDuration - s.lastPool.AcquireDuration) / time.Duration(acquired)
+	}
+	canceled := stats.CanceledAcquireCount - s.lastPool.CanceledAcquireCount
+	empty := stats.EmptyAcquireCount - s.lastPool.EmptyAcquireCount
+	s.lastPool = stats
+
+	previous := s.limit
+	switch {
+	case s.acquireLatency > s.opts.TargetAcquireLatency || canceled > 0:
+		s.limit = math.Max(float64(s.opts.MinLimit), s.limit*0.75)
+	case empty > 0:
+		// the pool ran out of connections, more requests at once would only wait on it
+	case float64(s.peakInFlight) >= s.limit*0.9:
+		s.limit = math.Min(float64(s.opts.MaxLimit), s.limit+math.Max(1, s.limit*0.1))
+	}
+	s.peakInFlight = s.inFlight
+
+	if int(s.limit) < int(previous) {
+		slog.WarnContext(ctx, "load_shedder_limit_lowered",
+			slog.Int("limit", int(s.limit)),
+			slog.Duration("acquire_latency", s.acquireLatency),
+			slog.Int64("canceled_acquires", canceled),
+			slog.Int("in_flight", s.inFlight),
+		)
+	}
+}
+
+func (s *LoadShedder) State() LoadShedderState {
+	var pool PoolStats
+	if s.poolStats != nil {
+		pool = s.poolStats()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shed := make(map[RequestPriority]int64, len(priorityShares))
+	for priority := range priorityShares {
+		shed[priority] = s.shed[priority]
+	}
+	return LoadShedderState{
+		Limit:                int(s.limit),
+		MinLimit:             s.opts.MinLimit,
+		MaxLimit:             s.opts.MaxLimit,
+		InFlight:             s.inFlight,
+		AcquireLatency:       s.acquireLatency,
+		TargetAcquireLatency: s.opts.TargetAcquireLatency,
+		PoolAcquiredConns:    pool.AcquiredConns,
+		PoolMaxConns:         pool.MaxConns,
+		Admitted:             s.admitted,
+		Shed:                 shed,
+	}
+}
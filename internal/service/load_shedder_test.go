@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShedder_Acquire(t *testing.T) {
+	shedder := NewLoadShedder(nil, LoadShedderOptions{InitialLimit: 10, MinLimit: 1, MaxLimit: 10})
+
+	var releases []func()
+	for range 5 {
+		release, ok := shedder.Acquire(PriorityLow)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok := shedder.Acquire(PriorityLow)
+	assert.False(t, ok, "the reporting reads may only fill half of the limit")
+
+	for range 3 {
+		release, ok := shedder.Acquire(PriorityNormal)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok = shedder.Acquire(PriorityNormal)
+	assert.False(t, ok)
+
+	for range 2 {
+		release, ok := shedder.Acquire(PriorityCritical)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok = shedder.Acquire(PriorityCritical)
+	assert.False(t, ok)
+
+	_, ok = shedder.Acquire(PriorityExempt)
+	assert.True(t, ok)
+
+	releases[0]()
+	releases[0]() // a release is only counted once
+	state := shedder.State()
+	assert.Equal(t, 9, state.InFlight)
+	assert.Equal(t, int64(10), state.Admitted)
+	assert.Equal(t, map[RequestPriority]int64{PriorityCritical: 1, PriorityNormal: 1, PriorityLow: 1}, state.Shed)
+}
+
+func TestLoadShedder_Adjust(t *testing.T) {
+	ctx := context.Background()
+	var pool PoolStats
+	shedder := NewLoadShedder(func() PoolStats { return pool }, LoadShedderOptions{
+		InitialLimit:         13,
+		MinLimit:             10,
+		MaxLimit:             13,
+		TargetAcquireLatency: 50 * time.Millisecond,
+	})
+
+	t.Run("a slow pool lowers the limit down to the minimum", func(t *testing.T) {
+		pool = PoolStats{AcquireCount: 10, AcquireDuration: time.Second}
+		shedder.adjust(ctx)
+		assert.Equal(t, 10, shedder.State().Limit)
+		assert.Equal(t, 100*time.Millisecond, shedder.State().AcquireLatency)
+
+		for range 2 {
+			pool.AcquireCount += 10
+			pool.AcquireDuration += time.Second
+			shedder.adjust(ctx)
+		}
+		assert.Equal(t, 10, shedder.State().Limit)
+	})
+
+	t.Run("an unused limit is not raised", func(t *testing.T) {
+		shedder.adjust(ctx)
+		assert.Equal(t, 10, shedder.State().Limit)
+		assert.Zero(t, shedder.State().AcquireLatency)
+	})
+
+	t.Run("a fast pool raises a limit in use up to the maximum", func(t *testing.T) {
+		for range 10 {
+			release, ok := shedder.Acquire(PriorityCritical)
+			require.True(t, ok)
+			defer release()
+		}
+		pool.AcquireCount += 10
+		pool.AcquireDuration += 10 * time.Millisecond
+		shedder.adjust(ctx)
+		assert.Equal(t, 11, shedder.State().Limit)
+
+		for range 5 {
+			shedder.adjust(ctx)
+		}
+		assert.Equal(t, 12, shedder.State().Limit, "the limit stops growing once 90% of it is no longer used")
+
+		for range 2 {
+			release, ok := shedder.Acquire(PriorityCritical)
+			require.True(t, ok)
+			defer release()
+		}
+		for range 5 {
+			shedder.adjust(ctx)
+		}
+		assert.Equal(t, 13, shedder.State().Limit)
+	})
+
+	t.Run("given up acquisitions lower the limit while the successful ones are fast", func(t *testing.T) {
+		pool.AcquireCount += 10
+		pool.AcquireDuration += 10 * time.Millisecond
+		pool.CanceledAcquireCount += 3
+		shedder.adjust(ctx)
+		assert.Equal(t, 10, shedder.State().Limit)
+		assert.Equal(t, time.Millisecond, shedder.State().AcquireLatency)
+	})
+
+	t.Run("a limit in use is not raised while acquisitions find the pool empty", func(t *testing.T) {
+		for range 10 {
+			release, ok := shedder.Acquire(PriorityCritical)
+			require.True(t, ok)
+			defer release()
+		}
+		pool.AcquireCount += 10
+		pool.AcquireDuration += 10 * time.Millisecond
+		pool.EmptyAcquireCount += 2
+		shedder.adjust(ctx)
+		assert.Equal(t, 10, shedder.State().Limit)
+
+		shedder.adjust(ctx)
+		assert.Equal(t, 11, shedder.State().Limit, "raised again once no acquisition waited")
+	})
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type OutboxDispatcherOptions struct {
+	PollInterval time.Duration
+	BatchSize    int32
+	Lease        time.Duration // how long a claimed batch belongs to the dispatcher before another one may take it over, a minute by default
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+/*
+OutboxDispatcher relays outbox events to the configured publisher.
+
+Delivery is at-least-once: an event is only marked as dispatched after the publisher accepted it,
+so a crash between the two re-sends the event and consumers must deduplicate on the event id.
+Events of the same loan are delivered in the order they were recorded, a failing event holds back
+the following events of that loan until it succeeds or is given up (marked DEAD) after MaxAttempts.
+*/
+type OutboxDispatcher struct {
+	repo      domain.OutboxRepository
+	publisher domain.EventPublisher
+	opts      OutboxDispatcherOptions
+	now       func() time.Time
+}
+
+func NewOutboxDispatcher(repo domain.OutboxRepository, publisher domain.EventPublisher, opts OutboxDispatcherOptions) *OutboxDispatcher {
+	if opts.Lease <= 0 {
+		opts.Lease = defaultDispatchLease
+	}
+	return &OutboxDispatcher{
+		repo:      repo,
+		publisher: publisher,
+		opts:      opts,
+		now:       time.Now,
+	}
+}
+
+// Start runs the dispatch loop in the background until ctx is cancelled
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	go runEvery(ctx, d.opts.PollInterval, func(ctx context.Context) {
+		drain(ctx, d.opts.BatchSize, "outbox_dispatch_failed", d.DispatchOnce)
+	})
+}
+
+/*
+DispatchOnce publishes one batch of due events and returns how many were processed.
+
+The batch is claimed in a short transaction guarded by an advisory lock, which leases its events to this dispatcher.
+The events are published outside of any transaction, so a retried transaction never publishes twice, and each one
+is marked on its own once the publisher answered. What is left when the lease runs out goes back to the next claim.
+*/
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	now := d.now()
+	leaseUntil := now.Add(d.opts.Lease)
+
+	var events []domain.OutboxEvent
+	err := d.repo.WithTx(ctx, func(repo domain.OutboxRepository) error {
+		events = nil
+		locked, err := repo.TryLockOutboxDispatch(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		events, err = repo.ClaimOutboxEvents(ctx, domain.ClaimOutboxEventsCommand{
+			Now:        now,
+			LeaseUntil: leaseUntil,
+			Limit:      d.opts.BatchSize,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// once an event of an aggregate fails, its later events must wait to keep the order
+	var processed int
+	blocked := make(map[string]bool)
+	for _, event := range events {
+		key := fmt.Sprintf("%s:%d", event.AggregateType, event.AggregateID)
+		if blocked[key] {
+			continue
+		}
+		if d.now().After(leaseUntil) {
+			break
+		}
+		processed++
+
+		publishErr := d.publisher.Publish(ctx, event)
+		if publishErr == nil {
+			if err := d.repo.MarkOutboxEventDispatched(ctx, event.ID); err != nil {
+				return processed, err
+			}
+			continue
+		}
+
+		cmd := d.failure(event, publishErr, d.now())
+		if cmd.Status == domain.OutboxEventStatusPending {
+			blocked[key] = true
+		}
+		slog.WarnContext(ctx, "outbox_publish_failed",
+			slog.Int64("event_id", event.ID),
+			slog.String("event_type", event.EventType),
+			slog.Int("attempt", event.Attempts+1),
+			slog.String("status", cmd.Status),
+			slog.Any("err", publishErr),
+		)
+		if err := d.repo.MarkOutboxEventFailed(ctx, cmd); err != nil {
+			return processed, err
+		}
+	}
+	return processed, nil
+}
+
+// failure decides between another attempt with exponential backoff and giving up on the event
+func (d *OutboxDispatcher) failure(event domain.OutboxEvent, publishErr error, now time.Time) domain.MarkOutboxEventFailedCommand {
+	attempts := event.Attempts + 1
+	cmd := domain.MarkOutboxEventFailedCommand{
+		ID:            event.ID,
+		Status:        domain.OutboxEventStatusPending,
+		LastError:     publishErr.Error(),
+		NextAttemptAt: now.Add(exponentialBackoff(attempts, d.opts.BaseBackoff, d.opts.MaxBackoff)),
+	}
+	if attempts >= d.opts.MaxAttempts {
+		cmd.Status = domain.OutboxEventStatusDead
+		cmd.NextAttemptAt = now
+	}
+	return cmd
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOutboxRepo keeps the events in memory and records what the dispatcher marked
+type fakeOutboxRepo struct {
+	events     []domain.OutboxEvent
+	dispatched []int64
+	failed     []domain.MarkOutboxEventFailedCommand
+	txRuns     int // how many times WithTx runs its closure, like a transaction retried after a serialization failure
+}
+
+func (f *fakeOutboxRepo) WithTx(ctx context.Context, fn func(domain.OutboxRepository) error) error {
+	for range max(f.txRuns, 1) - 1 {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return fn(f)
+}
+
+func (f *fakeOutboxRepo) TryLockOutboxDispatch(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeOutboxRepo) ClaimOutboxEvents(ctx context.Context, arg domain.ClaimOutboxEventsCommand) ([]domain.OutboxEvent, error) {
+	return f.events, nil
+}
+
+func (f *fakeOutboxRepo) MarkOutboxEventDispatched(ctx context.Context, id int64) error {
+	f.dispatched = append(f.dispatched, id)
+	return nil
+}
+
+func (f *fakeOutboxRepo) MarkOutboxEventFailed(ctx context.Context, cmd domain.MarkOutboxEventFailedCommand) error {
+	f.failed = append(f.failed, cmd)
+	return nil
+}
+
+type fakePublisher struct {
+	failIDs   map[int64]bool
+	published []int64
+	onPublish func()
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.published = append(p.published, event.ID)
+	if p.onPublish != nil {
+		p.onPublish()
+	}
+	if p.failIDs[event.ID] {
+		return errors.New("endpoint unavailable")
+	}
+	return nil
+}
+
+func TestOutboxDispatcher_DispatchOnce(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	opts := OutboxDispatcherOptions{BatchSize: 10, MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Minute}
+
+	t.Run("failing event holds back later events of the same loan", func(t *testing.T) {
+		repo := &fakeOutboxRepo{events: []domain.OutboxEvent{
+			{ID: 1, AggregateType: domain.AggregateTypeLoan, AggregateID: 7},
+			{ID: 2, AggregateType: domain.AggregateTypeLoan, AggregateID: 7},
+			{ID: 3, AggregateType: domain.AggregateTypeLoan, AggregateID: 8},
+		}}
+		d := NewOutboxDispatcher(repo, &fakePublisher{failIDs: map[int64]bool{1: true}}, opts)
+		d.now = func() time.Time { return now }
+
+		processed, err := d.DispatchOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, processed)
+		assert.Equal(t, []int64{3}, repo.dispatched)
+		assert.Len(t, repo.failed, 1)
+		assert.Equal(t, domain.OutboxEventStatusPending, repo.failed[0].Status)
+		assert.Equal(t, now.Add(time.Second), repo.failed[0].NextAttemptAt)
+	})
+
+	t.Run("a retried claim transaction publishes once", func(t *testing.T) {
+		repo := &fakeOutboxRepo{txRuns: 2, events: []domain.OutboxEvent{
+			{ID: 1, AggregateType: domain.AggregateTypeLoan, AggregateID: 7},
+			{ID: 2, AggregateType: domain.AggregateTypeLoan, AggregateID: 8},
+		}}
+		publisher := &fakePublisher{}
+		d := NewOutboxDispatcher(repo, publisher, opts)
+		d.now = func() time.Time { return now }
+
+		processed, err := d.DispatchOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, processed)
+		assert.Equal(t, []int64{1, 2}, publisher.published)
+		assert.Equal(t, []int64{1, 2}, repo.dispatched)
+	})
+
+	t.Run("stops publishing once the lease ran out", func(t *testing.T) {
+		repo := &fakeOutboxRepo{events: []domain.OutboxEvent{
+			{ID: 1, AggregateType: domain.AggregateTypeLoan, AggregateID: 7},
+			{ID: 2, AggregateType: domain.AggregateTypeLoan, AggregateID: 8},
+		}}
+		clock := now
+		// the first publish takes longer than the lease
+		publisher := &fakePublisher{onPublish: func() { clock = clock.Add(2 * time.Minute) }}
+		leased := opts
+		leased.Lease = time.Minute
+		d := NewOutboxDispatcher(repo, publisher, leased)
+		d.now = func() time.Time { return clock }
+
+		processed, err := d.DispatchOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, []int64{1}, publisher.published)
+	})
+
+	t.Run("event is marked dead after max attempts", func(t *testing.T) {
+		repo := &fakeOutboxRepo{events: []domain.OutboxEvent{
+			{ID: 1, AggregateType: domain.AggregateTypeLoan, AggregateID: 7, Attempts: 2},
+		}}
+		d := NewOutboxDispatcher(repo, &fakePublisher{failIDs: map[int64]bool{1: true}}, opts)
+		d.now = func() time.Time { return now }
+
+		_, err := d.DispatchOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, repo.failed, 1)
+		assert.Equal(t, domain.OutboxEventStatusDead, repo.failed[0].Status)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, exponentialBackoff(1, time.Second, time.Minute))
+	assert.Equal(t, 8*time.Second, exponentialBackoff(4, time.Second, time.Minute))
+	assert.Equal(t, time.Minute, exponentialBackoff(20, time.Second, time.Minute))
+}
@@ -2,6 +2,10 @@ package service
 
 import "time"
 
+type LoanCursor struct {
+	ID int64
+}
+
 type PaymentCursor struct {
 	PaidAt time.Time
 	ID     int64
@@ -10,3 +14,7 @@ type PaymentCursor struct {
 type ScheduleCursor struct {
 	Sequence int32
 }
+
+type WebhookDeliveryCursor struct {
+	ID int64
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+)
+
+// roles of the default policy
+const (
+	RoleBorrower = "borrower"
+	RoleAgent    = "agent"
+	RoleFinance  = "finance"
+	RoleAdmin    = "admin"
+)
+
+/*
+Policy maps the roles of the callers to the permissions they grant.
+
+The default policy lets borrowers read their own loans, agents originate loans and post payments, finance
+additionally reverse payments, write off loans, run the collections and read the audit log, and admins do everything.
+A deployment replaces it with its own roles through a policy file.
+*/
+type Policy struct {
+	roles map[string][]string
+}
+
+// policyFile is the JSON policy file: {"roles": {"<role>": ["<permission>", ...]}}
+type policyFile struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{roles: map[string][]string{
+		RoleBorrower: {domain.PermissionLoanReadOwn},
+		RoleAgent: {
+			domain.PermissionLoanRead,
+			domain.PermissionLoanCreate,
+			domain.PermissionPaymentCreate,
+			domain.PermissionMandateManage,
+		},
+		RoleFinance: {
+			domain.PermissionLoanRead,
+			domain.PermissionPaymentCreate,
+			domain.PermissionPaymentReverse,
+			domain.PermissionLoanWriteOff,
+			domain.PermissionMandateManage,
+			domain.PermissionCollectionManage,
+			domain.PermissionAuditRead,
+		},
+		RoleAdmin: {domain.PermissionAll},
+	}}
+}
+
+// NewPolicy checks every permission is known, a typo would otherwise silently grant nothing
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	if len(roles) == 0 {
+		return nil, errors.New("rbac: the policy has no role")
+	}
+	for role, permissions := range roles {
+		for _, permission := range permissions {
+			if permission != domain.PermissionAll && !slices.Contains(domain.Permissions, permission) {
+				return nil, fmt.Errorf("rbac: role %q: unknown permission %q", role, permission)
+			}
+		}
+	}
+	return &Policy{roles: roles}, nil
+}
+
+// LoadPolicy reads a policy file, the default policy is used when path is empty
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("rbac: %s: %w", path, err)
+	}
+	return NewPolicy(file.Roles)
+}
+
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+func (p *Policy) Roles() []string {
+	return slices.Sorted(maps.Keys(p.roles))
+}
+
+// Permissions returns the permissions granted by the roles, unknown roles grant nothing
+func (p *Policy) Permissions(roles []string) []string {
+	var permissions []string
+	for _, role := range roles {
+		permissions = append(permissions, p.roles[role]...)
+	}
+	return slices.Compact(slices.Sorted(slices.Values(permissions)))
+}
+
+/*
+AuditDenied logs an authorization denial. The REST middlewares, the gRPC interceptors and the services log
+every denial through it, so the denials can be audited from the logs by the authorization_denied message.
+*/
+func AuditDenied(ctx context.Context, principal *domain.Principal, permission, reason string) {
+	attrs := []any{slog.String("permission", permission), slog.String("reason", reason)}
+	if principal != nil {
+		attrs = append(attrs, slog.String("subject", principal.Subject), slog.Any("roles", principal.Roles))
+	}
+	slog.WarnContext(ctx, "authorization_denied", attrs...)
+}
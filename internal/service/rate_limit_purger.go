@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RateLimitPurger periodically deletes the idle token buckets
+type RateLimitPurger struct {
+	limiter  *RateLimiter
+	interval time.Duration
+}
+
+func NewRateLimitPurger(limiter *RateLimiter, interval time.Duration) *RateLimitPurger {
+	return &RateLimitPurger{
+		limiter:  limiter,
+		interval: interval,
+	}
+}
+
+// Start runs the purge loop in the background until ctx is cancelled
+func (p *RateLimitPurger) Start(ctx context.Context) {
+	go runEvery(ctx, p.interval, p.runOnce)
+}
+
+func (p *RateLimitPurger) runOnce(ctx context.Context) {
+	deleted, err := p.limiter.PurgeIdle(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "rate_limit_purge_failed", slog.Any("err", err))
+		return
+	}
+	if deleted > 0 {
+		slog.DebugContext(ctx, "rate_limit_buckets_purged", slog.Int64("count", deleted))
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/metrics"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitRules are the token buckets every request takes a token from, a zero limit disables its bucket
+type RateLimitRules struct {
+	// PerIP limits each client address, authenticated or not
+	PerIP domain.RateLimit
+	// PerKey limits each caller, an API key or the subject of a JWT, across all the routes
+	PerKey domain.RateLimit
+	// Routes limit each caller, or each address without credentials, on a route, by "METHOD pattern"
+	Routes map[string]domain.RateLimit
+}
+
+// RateLimitRequest identifies the buckets of a request
+type RateLimitRequest struct {
+	Route     string // "METHOD pattern" of the matched route, eg "POST /loan/{loanID}/payment"
+	SourceIP  string
+	Principal *domain.Principal // nil without authentication
+}
+
+// RateLimitDecision reports the most restrictive bucket of the request, Limit is 0 when no bucket applies
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a token is back, when the request is not allowed
+}
+
+/*
+RateLimiter throttles the callers with token buckets per address, per caller and per route.
+
+A request takes a token from each of its buckets, the most specific first, and is rejected by the first empty one.
+The buckets live in the repository, in memory for a single instance or in Postgres to share them between instances.
+A bucket the repository fails to read is taken from the fallback, the buckets of the instance, so the callers stay
+limited while a shared store is unavailable.
+*/
+type RateLimiter struct {
+	repo     domain.RateLimitRepository
+	fallback domain.RateLimitRepository // nil returns the errors of repo
+	rules    RateLimitRules
+	now      func() time.Time
+}
+
+func NewRateLimiter(repo, fallback domain.RateLimitRepository, rules RateLimitRules) *RateLimiter {
+	return &RateLimiter{
+		repo:     repo,
+		fallback: fallback,
+		rules:    rules,
+		now:      time.Now,
+	}
+}
+
+// ParseRouteRateLimits reads the route limits, given as "METHOD pattern=rate:burst" with the rate in requests per second
+func ParseRouteRateLimits(specs []string) (map[string]domain.RateLimit, error) {
+	routes := make(map[string]domain.RateLimit, len(specs))
+	for _, spec := range specs {
+		route, limit, ok := strings.Cut(spec, "=")
+		rate, burst, ok2 := strings.Cut(limit, ":")
+		method, pattern, ok3 := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !ok2 || !ok3 || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("rate limit: invalid route limit %q, want \"METHOD pattern=rate:burst\"", spec)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("rate limit: route %q: invalid rate %q", route, rate)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b <= 0 {
+			return nil, fmt.Errorf("rate limit: route %q: invalid burst %q", route, burst)
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(pattern)] = domain.RateLimit{Rate: r, Burst: b}
+	}
+	return routes, nil
+}
+
+// Allow takes a token from every bucket of the request, up to the first empty one
+func (l *RateLimiter) Allow(ctx context.Context, req RateLimitRequest) (RateLimitDecision, error) {
+	now := l.now()
+	decision := RateLimitDecision{Allowed: true}
+	for _, b := range l.buckets(req) {
+		bucket, err := l.takeToken(ctx, domain.TakeRateLimitTokenCommand{Key: b.key, Limit: b.limit, Now: now})
+		if err != nil {
+			return RateLimitDecision{}, err
+		}
+
+		remaining := int(math.Floor(bucket.Tokens))
+		reset := secondsToDuration((float64(b.limit.Burst) - bucket.Tokens) / b.limit.Rate)
+		if !bucket.Allowed {
+			return RateLimitDecision{
+				Limit:      b.limit.Burst,
+				Remaining:  0,
+				Reset:      reset,
+				RetryAfter: secondsToDuration((1 - bucket.Tokens) / b.limit.Rate),
+			}, nil
+		}
+		if decision.Limit == 0 || remaining < decision.Remaining || (remaining == decision.Remaining && reset > decision.Reset) {
+			decision = RateLimitDecision{Allowed: true, Limit: b.limit.Burst, Remaining: remaining, Reset: reset}
+		}
+	}
+	return decision, nil
+}
+
+// takeToken takes the token from the repository, or from the fallback when the repository fails
+func (l *RateLimiter) takeToken(ctx context.Context, cmd domain.TakeRateLimitTokenCommand) (*domain.RateLimitBucket, error) {
+	bucket, err := l.repo.TakeRateLimitToken(ctx, cmd)
+	if err == nil || l.fallback == nil {
+		return bucket, err
+	}
+	slog.WarnContext(ctx, "rate_limit_store_failed", slog.Any("err", err))
+	metrics.RateLimitStoreFallbacks.Inc()
+	return l.fallback.TakeRateLimitToken(ctx, cmd)
+}
+
+type rateLimitBucket struct {
+	key   string
+	limit domain.RateLimit
+}
+
+// buckets lists the enabled buckets of the request, the most specific first so a rejection wastes fewer tokens
+func (l *RateLimiter) buckets(req RateLimitRequest) []rateLimitBucket {
+	client := "ip:" + req.SourceIP
+	if req.Principal != nil {
+		client = "key:" + req.Principal.TenantID + "/" + req.Principal.Subject
+	}
+
+	var buckets []rateLimitBucket
+	if limit := l.rules.Routes[req.Route]; limit.Enabled() {
+		buckets = append(buckets, rateLimitBucket{key: "route:" + req.Route + ":" + client, limit: limit})
+	}
+	if req.Principal != nil && l.rules.PerKey.Enabled() {
+		buckets = append(buckets, rateLimitBucket{key: client, limit: l.rules.PerKey})
+	}
+	if l.rules.PerIP.Enabled() {
+		buckets = append(buckets, rateLimitBucket{key: "ip:" + req.SourceIP, limit: l.rules.PerIP})
+	}
+	return buckets
+}
+
+// PurgeIdle deletes the buckets idle long enough to be full again, they are the same as missing ones
+func (l *RateLimiter) PurgeIdle(ctx context.Context) (int64, error) {
+	var refill time.Duration
+	for _, limit := range append([]domain.RateLimit{l.rules.PerIP, l.rules.PerKey}, slices.Collect(maps.Values(l.rules.Routes))...) {
+		if limit.Enabled() {
+			refill = max(refill, secondsToDuration(float64(limit.Burst)/limit.Rate))
+		}
+	}
+	idleBefore := l.now().Add(-refill)
+	if l.fallback != nil {
+		if _, err := l.fallback.DeleteIdleRateLimitBuckets(ctx, idleBefore); err != nil {
+			return 0, err
+		}
+	}
+	return l.repo.DeleteIdleRateLimitBuckets(ctx, idleBefore)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
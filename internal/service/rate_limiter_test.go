@@ -0,0 +1,126 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"billing-api/internal/infra/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(memory.NewRateLimitRepo(memory.NewStore()), nil, RateLimitRules{
+		PerIP:  domain.RateLimit{Rate: 10, Burst: 10},
+		PerKey: domain.RateLimit{Rate: 0.1, Burst: 3},
+		Routes: map[string]domain.RateLimit{"POST /loan/{loanID}/payment": {Rate: 0.5, Burst: 1}},
+	})
+	limiter.now = func() time.Time { return now }
+	partner := &domain.Principal{Subject: "api_key:partner", TenantID: "default"}
+	payment := RateLimitRequest{Route: "POST /loan/{loanID}/payment", SourceIP: "10.0.0.1", Principal: partner}
+
+	t.Run("the route bucket rejects the second payment until it is refilled", func(t *testing.T) {
+		decision, err := limiter.Allow(ctx, payment)
+		require.NoError(t, err)
+		assert.Equal(t, RateLimitDecision{Allowed: true, Limit: 1, Remaining: 0, Reset: 2 * time.Second}, decision)
+
+		decision, err = limiter.Allow(ctx, payment)
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 2*time.Second, decision.RetryAfter)
+
+		now = now.Add(time.Second)
+		decision, err = limiter.Allow(ctx, payment)
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, time.Second, decision.RetryAfter)
+
+		now = now.Add(time.Second)
+		decision, err = limiter.Allow(ctx, payment)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("the key bucket spans the routes", func(t *testing.T) {
+		read := RateLimitRequest{Route: "GET /loan/{loanID}", SourceIP: "10.0.0.1", Principal: partner}
+		// two of the three tokens were taken by the allowed payments
+		decision, err := limiter.Allow(ctx, read)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+
+		decision, err = limiter.Allow(ctx, read)
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+
+		// another caller behind the same address is not affected
+		other := RateLimitRequest{Route: "GET /loan/{loanID}", SourceIP: "10.0.0.1", Principal: &domain.Principal{Subject: "api_key:other", TenantID: "default"}}
+		decision, err = limiter.Allow(ctx, other)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("idle buckets are purged once full again", func(t *testing.T) {
+		deleted, err := limiter.PurgeIdle(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+
+		now = now.Add(30 * time.Second)
+		deleted, err = limiter.PurgeIdle(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), deleted)
+	})
+}
+
+// failingRateLimitRepo is a shared store that cannot be reached
+type failingRateLimitRepo struct {
+	domain.RateLimitRepository
+}
+
+func (failingRateLimitRepo) TakeRateLimitToken(ctx context.Context, arg domain.TakeRateLimitTokenCommand) (*domain.RateLimitBucket, error) {
+	return nil, errors.New("repo-timeout : TakeRateLimitToken limit was (50ms)")
+}
+
+func TestRateLimiter_Allow_StoreFailure(t *testing.T) {
+	ctx := context.Background()
+	rules := RateLimitRules{PerIP: domain.RateLimit{Rate: 1, Burst: 2}}
+	req := RateLimitRequest{Route: "GET /loan", SourceIP: "10.0.0.1"}
+
+	t.Run("the buckets of the instance take over", func(t *testing.T) {
+		limiter := NewRateLimiter(failingRateLimitRepo{}, memory.NewRateLimitRepo(memory.NewStore()), rules)
+		for range 2 {
+			decision, err := limiter.Allow(ctx, req)
+			require.NoError(t, err)
+			assert.True(t, decision.Allowed)
+		}
+		decision, err := limiter.Allow(ctx, req)
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+	})
+
+	t.Run("without fallback the error is returned", func(t *testing.T) {
+		limiter := NewRateLimiter(failingRateLimitRepo{}, nil, rules)
+		_, err := limiter.Allow(ctx, req)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseRouteRateLimits(t *testing.T) {
+	routes, err := ParseRouteRateLimits([]string{"post /loan/{loanID}/payment=0.5:2", " GET /loan=10:20"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]domain.RateLimit{
+		"POST /loan/{loanID}/payment": {Rate: 0.5, Burst: 2},
+		"GET /loan":                   {Rate: 10, Burst: 20},
+	}, routes)
+
+	for _, spec := range []string{"POST /loan", "/loan=1:1", "POST /loan=fast:1", "POST /loan=1:0", "POST /loan=-1:1"} {
+		_, err := ParseRouteRateLimits([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
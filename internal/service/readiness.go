@@ -0,0 +1,111 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type ReadinessOptions struct {
+	// SchemaVersion is the migration the code needs, a database behind it is not ready
+	SchemaVersion int32
+	// MaxPoolUsage is the share of the pool connections in use from which the instance is not ready, 0 disables the check
+	MaxPoolUsage float64
+	// Timeout of the database checks
+	Timeout time.Duration
+}
+
+// ReadinessCheck is the outcome of one check, Detail tells why it failed
+type ReadinessCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+type ReadinessReport struct {
+	Ready  bool
+	Checks []ReadinessCheck
+}
+
+/*
+Readiness tells whether the instance should receive traffic: it is not shutting down, the database answers,
+has the migrations the code needs and has connections left in the pool.
+*/
+type Readiness struct {
+	repo      domain.HealthRepository
+	poolStats func() PoolStats // nil without a database, the pool is then not checked
+	opts      ReadinessOptions
+	draining  atomic.Bool
+}
+
+func NewReadiness(repo domain.HealthRepository, poolStats func() PoolStats, opts ReadinessOptions) *Readiness {
+	return &Readiness{
+		repo:      repo,
+		poolStats: poolStats,
+		opts:      opts,
+	}
+}
+
+// Drain makes the instance not ready for good, so the load balancer stops sending it requests before it shuts down
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Check runs every check, the instance is ready when they all pass
+func (r *Readiness) Check(ctx context.Context) ReadinessReport {
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	checks := []ReadinessCheck{{Name: "shutdown", OK: !r.draining.Load()}}
+	if !checks[0].OK {
+		checks[0].Detail = "the instance is shutting down"
+	}
+
+	database := ReadinessCheck{Name: "database", OK: true}
+	if err := r.repo.Ping(ctx); err != nil {
+		database = ReadinessCheck{Name: "database", Detail: err.Error()}
+	}
+	checks = append(checks, database)
+
+	// an unreachable database has no schema to check
+	schema := ReadinessCheck{Name: "schema", Detail: "the database is unreachable"}
+	if database.OK {
+		schema = r.checkSchema(ctx)
+	}
+	checks = append(checks, schema)
+
+	if r.poolStats != nil && r.opts.MaxPoolUsage > 0 {
+		checks = append(checks, r.checkPool())
+	}
+
+	report := ReadinessReport{Ready: true, Checks: checks}
+	for _, check := range checks {
+		report.Ready = report.Ready && check.OK
+	}
+	return report
+}
+
+// checkSchema passes when the database has at least the migrations of the code, a newer schema serves the old code during a rollout
+func (r *Readiness) checkSchema(ctx context.Context) ReadinessCheck {
+	version, err := r.repo.GetSchemaVersion(ctx)
+	if err != nil {
+		return ReadinessCheck{Name: "schema", Detail: err.Error()}
+	}
+	if version < r.opts.SchemaVersion {
+		return ReadinessCheck{Name: "schema", Detail: fmt.Sprintf("migration %d is applied, %d is required", version, r.opts.SchemaVersion)}
+	}
+	return ReadinessCheck{Name: "schema", OK: true}
+}
+
+func (r *Readiness) checkPool() ReadinessCheck {
+	stats := r.poolStats()
+	if stats.MaxConns > 0 && float64(stats.AcquiredConns)/float64(stats.MaxConns) >= r.opts.MaxPoolUsage {
+		return ReadinessCheck{Name: "pool", Detail: fmt.Sprintf("%d of %d connections in use", stats.AcquiredConns, stats.MaxConns)}
+	}
+	return ReadinessCheck{Name: "pool", OK: true}
+}
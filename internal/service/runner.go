@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+/*
+runEvery calls fn right away and then on every tick until ctx is cancelled.
+It blocks, so callers start it in their own goroutine.
+*/
+func runEvery(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fn(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+// defaultDispatchLease is how long the dispatchers keep a claimed batch unless configured otherwise
+const defaultDispatchLease = time.Minute
+
+/*
+drain keeps calling dispatchOnce while full batches come back, so a backlog does not wait for the next tick.
+A failure is logged as failureMsg and ends the run, the next tick tries again.
+*/
+func drain(ctx context.Context, batchSize int32, failureMsg string, dispatchOnce func(context.Context) (int, error)) {
+	for ctx.Err() == nil {
+		processed, err := dispatchOnce(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, failureMsg, slog.Any("err", err))
+			return
+		}
+		if processed < int(batchSize) {
+			return
+		}
+	}
+}
+
+/*
+exponentialBackoff returns base * 2^(attempt-1), capped at max
+*/
+func exponentialBackoff(attempt int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return min(backoff, max)
+}
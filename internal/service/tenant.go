@@ -0,0 +1,115 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// TenantSettings is the configuration of a lending partner, a field left out of the tenants file takes the deployment default
+type TenantSettings struct {
+	PagingLimitDefault int `json:"paging_limit_default"`
+	PagingLimitMax     int `json:"paging_limit_max"`
+	// DelinquencyGapWeeks is the number of weeks between the expected and the last paid week making a loan delinquent
+	DelinquencyGapWeeks int `json:"delinquency_gap_weeks"`
+}
+
+// defaultTenantSettings are used by the services created without tenants, in tests and single tenant tools
+var defaultTenantSettings = TenantSettings{PagingLimitDefault: 10, PagingLimitMax: 100, DelinquencyGapWeeks: 2}
+
+/*
+Tenants lists the lending partners served by the deployment and their settings.
+
+The default tenant always exists, it owns the data created before the tenants and serves the deployments
+of a single partner. A caller whose tenant is not listed is not authenticated, so no data is ever created
+for a tenant the background jobs do not know about.
+*/
+type Tenants struct {
+	settings map[string]TenantSettings
+}
+
+// tenantsFile is the JSON tenants file: {"tenants": {"<tenant id>": {"paging_limit_max": 50, ...}}}
+type tenantsFile struct {
+	Tenants map[string]TenantSettings `json:"tenants"`
+}
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// DefaultTenants serves the default tenant only
+func DefaultTenants(defaults TenantSettings) *Tenants {
+	return &Tenants{settings: map[string]TenantSettings{domain.DefaultTenantID: defaults}}
+}
+
+// NewTenants adds the tenants to the default one, their missing settings are taken from defaults
+func NewTenants(defaults TenantSettings, tenants map[string]TenantSettings) (*Tenants, error) {
+	t := DefaultTenants(defaults)
+	for id, settings := range tenants {
+		if !tenantIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("tenants: invalid tenant id %q, expected lower case letters, digits, _ and -", id)
+		}
+		if settings.PagingLimitDefault == 0 {
+			settings.PagingLimitDefault = defaults.PagingLimitDefault
+		}
+		if settings.PagingLimitMax == 0 {
+			settings.PagingLimitMax = defaults.PagingLimitMax
+		}
+		if settings.DelinquencyGapWeeks == 0 {
+			settings.DelinquencyGapWeeks = defaults.DelinquencyGapWeeks
+		}
+		if settings.PagingLimitDefault < 0 || settings.PagingLimitDefault > settings.PagingLimitMax || settings.DelinquencyGapWeeks < 1 {
+			return nil, fmt.Errorf("tenants: tenant %q: invalid settings %+v", id, settings)
+		}
+		t.settings[id] = settings
+	}
+	return t, nil
+}
+
+// LoadTenants reads a tenants file, only the default tenant is served when path is empty
+func LoadTenants(path string, defaults TenantSettings) (*Tenants, error) {
+	if path == "" {
+		return DefaultTenants(defaults), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tenants: %w", err)
+	}
+	var file tenantsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("tenants: %s: %w", path, err)
+	}
+	return NewTenants(defaults, file.Tenants)
+}
+
+func (t *Tenants) Has(tenantID string) bool {
+	_, ok := t.settings[tenantID]
+	return ok
+}
+
+func (t *Tenants) IDs() []string {
+	return slices.Sorted(maps.Keys(t.settings))
+}
+
+// Settings returns the settings of the tenant, those of the default tenant when it is unknown
+func (t *Tenants) Settings(tenantID string) TenantSettings {
+	if settings, ok := t.settings[tenantID]; ok {
+		return settings
+	}
+	return t.settings[domain.DefaultTenantID]
+}
+
+// forEach runs fn once per tenant with ctx scoped to it, a failing tenant does not stop the others
+func (t *Tenants) forEach(ctx context.Context, fn func(ctx context.Context) error) error {
+	var errs []error
+	for _, id := range t.IDs() {
+		if err := fn(domain.ContextWithTenant(ctx, id)); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
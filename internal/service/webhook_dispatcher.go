@@ -0,0 +1,145 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type WebhookDispatcherOptions struct {
+	PollInterval time.Duration
+	BatchSize    int32
+	Lease        time.Duration // how long a claimed batch belongs to the dispatcher before another one may take it over, a minute by default
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+/*
+WebhookDispatcher sends the pending webhook deliveries to the partner endpoints.
+
+Every attempt is written to the delivery log. A failed delivery is retried with exponential backoff,
+after MaxAttempts it lands in the dead-letter list until it is redelivered manually.
+Unlike the outbox, deliveries are not ordered per loan, receivers should rely on the event id and occurred_at.
+*/
+type WebhookDispatcher struct {
+	repo   domain.WebhookRepository
+	sender domain.WebhookSender
+	opts   WebhookDispatcherOptions
+	now    func() time.Time
+}
+
+func NewWebhookDispatcher(repo domain.WebhookRepository, sender domain.WebhookSender, opts WebhookDispatcherOptions) *WebhookDispatcher {
+	if opts.Lease <= 0 {
+		opts.Lease = defaultDispatchLease
+	}
+	return &WebhookDispatcher{
+		repo:   repo,
+		sender: sender,
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// Start runs the delivery loop in the background until ctx is cancelled
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	go runEvery(ctx, d.opts.PollInterval, func(ctx context.Context) {
+		drain(ctx, d.opts.BatchSize, "webhook_dispatch_failed", d.DispatchOnce)
+	})
+}
+
+/*
+DispatchOnce sends one batch of due deliveries and returns how many were attempted.
+
+The batch is claimed in a short transaction that leases its deliveries to this dispatcher, so several instances
+can deliver side by side. The requests are sent outside of any transaction and each attempt is recorded in its own.
+What is left when the lease runs out goes back to the next claim.
+*/
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	now := d.now()
+	leaseUntil := now.Add(d.opts.Lease)
+
+	var deliveries []domain.DueWebhookDelivery
+	err := d.repo.WithTx(ctx, func(repo domain.WebhookRepository) error {
+		var err error
+		deliveries, err = repo.ClaimWebhookDeliveries(ctx, domain.ClaimWebhookDeliveriesCommand{
+			Now:        now,
+			LeaseUntil: leaseUntil,
+			Limit:      d.opts.BatchSize,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var processed int
+	for _, delivery := range deliveries {
+		if d.now().After(leaseUntil) {
+			break
+		}
+		if err := d.deliver(ctx, delivery); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery domain.DueWebhookDelivery) error {
+	start := d.now()
+	result := d.sender.Send(ctx, delivery)
+
+	attempt := domain.CreateWebhookDeliveryAttemptCommand{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts + 1,
+		Duration:   d.now().Sub(start),
+	}
+	if result.StatusCode != 0 {
+		attempt.StatusCode = &result.StatusCode
+	}
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+	}
+
+	var cmd domain.MarkWebhookDeliveryFailedCommand
+	if result.Err != nil {
+		cmd = d.failure(delivery, attempt, d.now())
+		slog.WarnContext(ctx, "webhook_delivery_failed",
+			slog.Int64("delivery_id", delivery.ID),
+			slog.Int64("subscription_id", delivery.SubscriptionID),
+			slog.String("event_type", delivery.Event.EventType),
+			slog.Int("attempt", attempt.Attempt),
+			slog.String("status", cmd.Status),
+			slog.Any("err", result.Err),
+		)
+	}
+
+	// the attempt and its outcome are recorded together, a retried transaction does not send again
+	return d.repo.WithTx(ctx, func(repo domain.WebhookRepository) error {
+		if err := repo.InsertWebhookDeliveryAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		if result.Err == nil {
+			return repo.MarkWebhookDeliverySucceeded(ctx, delivery.ID, result.StatusCode)
+		}
+		return repo.MarkWebhookDeliveryFailed(ctx, cmd)
+	})
+}
+
+// failure decides between another attempt with exponential backoff and moving the delivery to the dead-letter list
+func (d *WebhookDispatcher) failure(delivery domain.DueWebhookDelivery, attempt domain.CreateWebhookDeliveryAttemptCommand, now time.Time) domain.MarkWebhookDeliveryFailedCommand {
+	cmd := domain.MarkWebhookDeliveryFailedCommand{
+		ID:            delivery.ID,
+		Status:        domain.WebhookDeliveryStatusPending,
+		StatusCode:    attempt.StatusCode,
+		LastError:     attempt.Error,
+		NextAttemptAt: now.Add(exponentialBackoff(attempt.Attempt, d.opts.BaseBackoff, d.opts.MaxBackoff)),
+	}
+	if attempt.Attempt >= d.opts.MaxAttempts {
+		cmd.Status = domain.WebhookDeliveryStatusDead
+		cmd.NextAttemptAt = now
+	}
+	return cmd
+}
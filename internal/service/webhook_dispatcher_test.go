@@ -0,0 +1,120 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWebhookRepo implements the dispatch part of the repository, the other methods are not used here
+type fakeWebhookRepo struct {
+	domain.WebhookRepository
+	due       []domain.DueWebhookDelivery
+	attempts  []domain.CreateWebhookDeliveryAttemptCommand
+	succeeded []int64
+	failed    []domain.MarkWebhookDeliveryFailedCommand
+}
+
+func (f *fakeWebhookRepo) WithTx(ctx context.Context, fn func(domain.WebhookRepository) error) error {
+	return fn(f)
+}
+
+func (f *fakeWebhookRepo) ClaimWebhookDeliveries(ctx context.Context, arg domain.ClaimWebhookDeliveriesCommand) ([]domain.DueWebhookDelivery, error) {
+	return f.due, nil
+}
+
+func (f *fakeWebhookRepo) InsertWebhookDeliveryAttempt(ctx context.Context, arg domain.CreateWebhookDeliveryAttemptCommand) error {
+	f.attempts = append(f.attempts, arg)
+	return nil
+}
+
+func (f *fakeWebhookRepo) MarkWebhookDeliverySucceeded(ctx context.Context, id int64, statusCode int) error {
+	f.succeeded = append(f.succeeded, id)
+	return nil
+}
+
+func (f *fakeWebhookRepo) MarkWebhookDeliveryFailed(ctx context.Context, arg domain.MarkWebhookDeliveryFailedCommand) error {
+	f.failed = append(f.failed, arg)
+	return nil
+}
+
+type fakeWebhookSender struct {
+	results map[int64]domain.WebhookResult
+	sent    []int64
+}
+
+func (s *fakeWebhookSender) Send(ctx context.Context, delivery domain.DueWebhookDelivery) domain.WebhookResult {
+	s.sent = append(s.sent, delivery.ID)
+	if result, ok := s.results[delivery.ID]; ok {
+		return result
+	}
+	return domain.WebhookResult{StatusCode: 200}
+}
+
+func TestWebhookDispatcher_DispatchOnce(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	opts := WebhookDispatcherOptions{BatchSize: 10, MaxAttempts: 3, BaseBackoff: 10 * time.Second, MaxBackoff: time.Hour}
+
+	repo := &fakeWebhookRepo{due: []domain.DueWebhookDelivery{
+		{ID: 1},
+		{ID: 2, Attempts: 0},
+		{ID: 3, Attempts: 2},
+	}}
+	sender := &fakeWebhookSender{results: map[int64]domain.WebhookResult{
+		2: {StatusCode: 500, Err: errors.New("webhook endpoint responded with status 500")},
+		3: {Err: errors.New("connection refused")},
+	}}
+	d := NewWebhookDispatcher(repo, sender, opts)
+	d.now = func() time.Time { return now }
+
+	processed, err := d.DispatchOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, processed)
+
+	t.Run("every attempt is logged", func(t *testing.T) {
+		assert.Len(t, repo.attempts, 3)
+		assert.Equal(t, 500, *repo.attempts[1].StatusCode)
+		assert.Nil(t, repo.attempts[2].StatusCode)
+		assert.Equal(t, 3, repo.attempts[2].Attempt)
+	})
+
+	t.Run("2xx marks the delivery as delivered", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, repo.succeeded)
+	})
+
+	t.Run("failure is retried with backoff until max attempts", func(t *testing.T) {
+		assert.Len(t, repo.failed, 2)
+		assert.Equal(t, domain.WebhookDeliveryStatusPending, repo.failed[0].Status)
+		assert.Equal(t, now.Add(10*time.Second), repo.failed[0].NextAttemptAt)
+		assert.Equal(t, domain.WebhookDeliveryStatusDead, repo.failed[1].Status)
+	})
+}
+
+// retryingWebhookRepo runs every transaction twice, like one retried after a serialization failure, the second run is kept
+type retryingWebhookRepo struct {
+	*fakeWebhookRepo
+}
+
+func (r retryingWebhookRepo) WithTx(ctx context.Context, fn func(domain.WebhookRepository) error) error {
+	scratch := *r.fakeWebhookRepo
+	if err := fn(&scratch); err != nil {
+		return err
+	}
+	return fn(r.fakeWebhookRepo)
+}
+
+func TestWebhookDispatcher_DispatchOnce_RetriedTransaction(t *testing.T) {
+	repo := retryingWebhookRepo{&fakeWebhookRepo{due: []domain.DueWebhookDelivery{{ID: 1}, {ID: 2}}}}
+	sender := &fakeWebhookSender{}
+	d := NewWebhookDispatcher(repo, sender, WebhookDispatcherOptions{BatchSize: 10, MaxAttempts: 3, Lease: time.Minute})
+
+	processed, err := d.DispatchOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.Equal(t, []int64{1, 2}, sender.sent)
+	assert.Equal(t, []int64{1, 2}, repo.succeeded)
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"billing-api/internal/domain"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+)
+
+type WebhookService struct {
+	repo domain.WebhookRepository
+}
+
+// constructor
+func NewWebhookService(repo domain.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repo: repo,
+	}
+}
+
+/*
+CreateSubscription registers a partner endpoint for the given event types.
+When no secret is provided one is generated, it is only returned here so the caller must store it.
+*/
+func (s *WebhookService) CreateSubscription(ctx context.Context, input CreateWebhookSubscriptionInput) (*domain.WebhookSubscription, error) {
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", domain.ErrInvalidWebhook)
+	}
+	if len(input.EventTypes) == 0 {
+		return nil, fmt.Errorf("%w: at least one event type is required", domain.ErrInvalidWebhook)
+	}
+	for _, eventType := range input.EventTypes {
+		if !slices.Contains(domain.WebhookEventTypes, eventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", domain.ErrInvalidWebhook, eventType)
+		}
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	var sub *domain.WebhookSubscription
+	err = s.repo.WithTx(ctx, func(repo domain.WebhookRepository) error {
+		created, err := repo.InsertWebhookSubscription(ctx, domain.CreateWebhookSubscriptionCommand{
+			URL:        input.URL,
+			EventTypes: slices.Compact(slices.Sorted(slices.Values(input.EventTypes))),
+			Secret:     secret,
+		})
+		if err != nil {
+			return err
+		}
+		sub = created
+		return recordAudit(ctx, repo, domain.AuditActionWebhookSubscriptionCreate, domain.AuditEntityWebhookSubscription, created.ID, nil, webhookSubscriptionAuditSnapshot(created))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	return s.repo.GetWebhookSubscriptionByID(ctx, id)
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return s.repo.ListWebhookSubscriptions(ctx)
+}
+
+/*
+DeleteSubscription stops notifying the endpoint, pending deliveries are no longer sent but the delivery log is kept
+*/
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	var sub *domain.WebhookSubscription
+	err := s.repo.WithTx(ctx, func(repo domain.WebhookRepository) error {
+		deleted, err := repo.DeleteWebhookSubscription(ctx, id)
+		if err != nil {
+			return err
+		}
+		sub = deleted
+		return recordAudit(ctx, repo, domain.AuditActionWebhookSubscriptionDelete, domain.AuditEntityWebhookSubscription, deleted.ID, webhookSubscriptionAuditSnapshot(deleted), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+/*
+ListDeliveries returns the deliveries of a subscription newest first, optionally filtered by status
+*/
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID int64, status string, limit int, cursor *WebhookDeliveryCursor) ([]domain.WebhookDelivery, *WebhookDeliveryCursor, error) {
+	if _, err := s.repo.GetWebhookSubscriptionByID(ctx, subscriptionID); err != nil {
+		return nil, nil, err
+	}
+
+	query := domain.ListWebhookDeliveriesQuery{
+		SubscriptionID: subscriptionID,
+		Status:         status,
+		LimitVal:       int32(limit),
+	}
+	if cursor != nil {
+		query.CursorID = &cursor.ID
+	}
+
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return deliveries, nextWebhookDeliveryCursor(deliveries, limit), nil
+}
+
+/*
+GetDelivery returns a delivery together with its attempt log
+*/
+func (s *WebhookService) GetDelivery(ctx context.Context, id int64) (*domain.WebhookDelivery, []domain.WebhookDeliveryAttempt, error) {
+	delivery, err := s.repo.GetWebhookDeliveryByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	attempts, err := s.repo.ListWebhookDeliveryAttempts(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return delivery, attempts, nil
+}
+
+/*
+ListDeadLetters returns the deliveries that exhausted their attempts, newest first
+*/
+func (s *WebhookService) ListDeadLetters(ctx context.Context, limit int, cursor *WebhookDeliveryCursor) ([]domain.WebhookDelivery, *WebhookDeliveryCursor, error) {
+	var cursorID *int64
+	if cursor != nil {
+		cursorID = &cursor.ID
+	}
+
+	deliveries, err := s.repo.ListDeadWebhookDeliveries(ctx, cursorID, int32(limit))
+	if err != nil {
+		return nil, nil, err
+	}
+	return deliveries, nextWebhookDeliveryCursor(deliveries, limit), nil
+}
+
+/*
+Redeliver puts a dead delivery back in the queue with a fresh retry budget
+*/
+func (s *WebhookService) Redeliver(ctx context.Context, id int64) (*domain.WebhookDelivery, error) {
+	var delivery *domain.WebhookDelivery
+	err := s.repo.WithTx(ctx, func(repo domain.WebhookRepository) error {
+		before, err := repo.GetWebhookDeliveryByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		after, err := repo.RedeliverWebhookDelivery(ctx, id)
+		if err != nil {
+			return err
+		}
+		delivery = after
+		return recordAudit(ctx, repo, domain.AuditActionWebhookDeliveryRedeliver, domain.AuditEntityWebhookDelivery, after.ID, webhookDeliveryAuditSnapshot(before), webhookDeliveryAuditSnapshot(after))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "webhook_redelivery_requested",
+		slog.Int64("delivery_id", delivery.ID),
+		slog.Int64("subscription_id", delivery.SubscriptionID),
+	)
+	return delivery, nil
+}
+
+/*
+Publish fans an outbox event out to the matching subscriptions, which makes the service an EventPublisher
+for the outbox dispatcher. Only the subscriptions of the tenant of the event receive it, the dispatcher runs outside
+of any request so the tenant comes from the event. Re-publishing the same event does not create duplicate deliveries.
+*/
+func (s *WebhookService) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	ctx = domain.ContextWithTenant(ctx, event.TenantID)
+	_, err := s.repo.EnqueueWebhookDeliveries(ctx, event.ID, event.EventType)
+	return err
+}
+
+func nextWebhookDeliveryCursor(deliveries []domain.WebhookDelivery, limit int) *WebhookDeliveryCursor {
+	if len(deliveries) < limit || len(deliveries) == 0 {
+		return nil
+	}
+	return &WebhookDeliveryCursor{ID: deliveries[len(deliveries)-1].ID}
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,334 @@
+/*
+Package billingclient is the Go client of the billing API.
+
+Every endpoint has a typed method. Submitting a loan or a payment sends an X-Idempotency-Key, generated when the
+caller doesn't provide one, so those calls are retried safely: a retry that reaches the server after the original
+succeeded gets the stored response instead of creating a second loan or payment. Reads are retried as well, other
+writes are never retried.
+
+Failed calls return an *APIError carrying the stable error code of the API, match on the code and never on the
+message:
+
+	loan, err := client.GetLoan(ctx, 42)
+	if billingclient.IsCode(err, billingclient.CodeLoanNotFound) {
+		...
+	}
+
+Payments, schedules and webhook deliveries can be walked page by page with the List methods, or item by item with
+the iterators that follow next_cursor:
+
+	for payment, err := range client.Payments(ctx, 42, 100) {
+		...
+	}
+*/
+package billingclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "X-Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client, which has a 30s timeout. Event streams never time out on their own.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithHeader adds a header to every request, eg an Authorization header
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.header.Set(key, value) }
+}
+
+// WithAPIKey authenticates every request with an API key issued by the admin API
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithMaxRetries sets how many times a retryable call is retried, 0 disables retries
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// WithBackoff sets the bounds of the exponential backoff between retries
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New creates a client of the API served at baseURL, eg http://localhost:8081
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		header:     make(http.Header),
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewIdempotencyKey returns a random key, callers that retry across restarts should store it with the request
+func NewIdempotencyKey() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	header      http.Header
+	// only requests sent with a key may be retried after the server possibly processed them
+	idempotencyKey string
+}
+
+func jsonRequest(method, path string, body any) (*request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &request{method: method, path: path, body: data, contentType: "application/json"}, nil
+}
+
+// retryable tells whether sending the request again can't apply it twice
+func (r *request) retryable() bool {
+	return r.method == http.MethodGet || r.idempotencyKey != ""
+}
+
+/*
+do sends the request, retrying transport errors, 429 and 5xx responses of retryable requests with exponential
+backoff and full jitter. A Retry-After header sent by the server is honored up to the maximum backoff.
+A non 2xx response is returned as an *APIError, the caller must close the body of a successful response.
+*/
+func (c *Client) do(ctx context.Context, hc *http.Client, req *request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, hc, req)
+
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !req.retryable() || attempt >= c.maxRetries {
+				return nil, err
+			}
+		case resp.StatusCode < 300:
+			return resp, nil
+		default:
+			apiErr := readAPIError(resp)
+			if !apiErr.retryable(req) || attempt >= c.maxRetries {
+				return nil, apiErr
+			}
+			retryAfter = apiErr.RetryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.backoff(attempt, retryAfter)):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, hc *http.Client, req *request) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		r.Header[k] = v
+	}
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	if req.idempotencyKey != "" {
+		r.Header.Set(IdempotencyKeyHeader, req.idempotencyKey)
+	}
+	return hc.Do(r)
+}
+
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, c.maxBackoff)
+	}
+	d := min(c.minBackoff<<attempt, c.maxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return mathrand.N(d) + 1
+}
+
+// doJSON sends the request and decodes the JSON response into out, when not nil
+func (c *Client) doJSON(ctx context.Context, req *request, out any) error {
+	resp, err := c.do(ctx, c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return decodeJSON(resp, req, out)
+}
+
+func decodeJSON(resp *http.Response, req *request, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("billingclient: decoding %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// doRaw sends the request and returns the whole response body, for file downloads
+func (c *Client) doRaw(ctx context.Context, req *request) ([]byte, error) {
+	resp, err := c.do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Health checks the /health endpoint, a nil error means the server is up
+func (c *Client) Health(ctx context.Context) error {
+	_, err := c.doRaw(ctx, &request{method: http.MethodGet, path: "/health"})
+	return err
+}
+
+// SetLogLevel changes the log level of the server at runtime: DEBUG, INFO, WARN or ERROR
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	return c.doJSON(ctx, &request{
+		method: http.MethodPost,
+		path:   "/loan/admin/log-level",
+		query:  url.Values{"level": {level}},
+	}, nil)
+}
+
+// ListParams selects a page, a zero Limit uses the server default and an empty Cursor the first page
+type ListParams struct {
+	Limit  int
+	Cursor string
+}
+
+func (p ListParams) query() url.Values {
+	q := url.Values{}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+/*
+paginate yields the items of every page, following the next cursor until the last page.
+An error is yielded once and ends the iteration, as does the caller breaking out of the loop.
+*/
+func paginate[T any](limit int, fetch func(ListParams) ([]T, string, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		params := ListParams{Limit: limit}
+		for {
+			items, next, err := fetch(params)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			params.Cursor = next
+		}
+	}
+}
+
+// Date is a calendar date, sent and received as YYYY-MM-DD
+type Date struct {
+	time.Time
+}
+
+const dateLayout = "2006-01-02"
+
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return errors.New("billingclient: invalid date " + strconv.Quote(s))
+	}
+	d.Time = t
+	return nil
+}
@@ -0,0 +1,370 @@
+package billingclient_test
+
+import (
+	"billing-api/internal/config"
+	billingApiHttp "billing-api/internal/http"
+	"billing-api/internal/infra/memory"
+	"billing-api/internal/service"
+	"billing-api/pkg/billingclient"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer serves NewRouter on the in-memory repositories, wrap lets a test put a failing proxy in front
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+
+	store := memory.NewStore()
+	eventBus := service.NewEventBus(100)
+	billingService := service.NewBillingService(nil, memory.NewBillingRepo(store), eventBus, nil)
+	collectionService := service.NewCollectionService(memory.NewCollectionRepo(store), billingService, service.NewRetryPolicy([]int{3, 7}, nil))
+	webhookService := service.NewWebhookService(memory.NewWebhookRepo(store))
+	idempotencyService := service.NewIdempotencyService(memory.NewIdempotencyRepo(store), time.Hour, time.Minute)
+	cfg := &config.Config{PagingLimitDefault: 10, PagingLimitMax: 100, LogLevel: new(slog.LevelVar)}
+
+	var handler http.Handler = billingApiHttp.NewRouter(billingApiHttp.RouterDeps{
+		BillingService:     billingService,
+		CollectionService:  collectionService,
+		WebhookService:     webhookService,
+		EventBus:           eventBus,
+		IdempotencyService: idempotencyService,
+		AuthService:        service.NewAuthService(memory.NewAPIKeyRepo(store), nil, service.DefaultPolicy(), nil),
+		AuditService:       service.NewAuditService(memory.NewAuditRepo(store)),
+	}, cfg)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newClient(server *httptest.Server) *billingclient.Client {
+	return billingclient.New(server.URL, billingclient.WithBackoff(time.Millisecond, 5*time.Millisecond))
+}
+
+var testLoan = billingclient.SubmitLoanInput{
+	PrincipalAmount:    5_000_000,
+	AnnualInterestRate: 0.1,
+	TotalWeeks:         50,
+	StartDate:          billingclient.NewDate(2026, time.January, 5),
+}
+
+func TestClient_Loans(t *testing.T) {
+	client := newClient(newTestServer(t, nil))
+	ctx := context.Background()
+
+	loan, err := client.SubmitLoan(ctx, testLoan)
+	require.NoError(t, err)
+	assert.Equal(t, int64(110000), loan.WeeklyPaymentAmount)
+	assert.Equal(t, int64(5_500_000), loan.TotalPayable)
+
+	for range 3 {
+		_, err := client.SubmitPayment(ctx, loan.LoanID, billingclient.SubmitPaymentInput{Amount: loan.WeeklyPaymentAmount})
+		require.NoError(t, err)
+	}
+
+	t.Run("loan details", func(t *testing.T) {
+		got, err := client.GetLoan(ctx, loan.LoanID)
+		require.NoError(t, err)
+		assert.Equal(t, 50, got.TotalWeeks)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		outstanding, err := client.GetOutstanding(ctx, loan.LoanID)
+		require.NoError(t, err)
+		assert.Equal(t, loan.TotalPayable-3*loan.WeeklyPaymentAmount, outstanding.Outstanding)
+	})
+
+	t.Run("iterators follow next_cursor", func(t *testing.T) {
+		other, err := client.SubmitLoan(ctx, testLoan)
+		require.NoError(t, err)
+		var loanIDs []int64
+		for l, err := range client.Loans(ctx, 1) {
+			require.NoError(t, err)
+			loanIDs = append(loanIDs, l.LoanID)
+		}
+		assert.Equal(t, []int64{other.LoanID, loan.LoanID}, loanIDs)
+
+		var weeks []int
+		for p, err := range client.Payments(ctx, loan.LoanID, 2) {
+			require.NoError(t, err)
+			weeks = append(weeks, p.WeekNumber)
+		}
+		assert.Equal(t, []int{1, 2, 3}, weeks)
+
+		var sequences []int
+		for s, err := range client.Schedules(ctx, loan.LoanID, 7) {
+			require.NoError(t, err)
+			sequences = append(sequences, s.Sequence)
+		}
+		require.Len(t, sequences, 50)
+		assert.Equal(t, 50, sequences[49])
+
+		page, err := client.ListSchedules(ctx, loan.LoanID, billingclient.ListParams{Limit: 5})
+		require.NoError(t, err)
+		assert.Len(t, page.Schedules, 5)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Equal(t, billingclient.NewDate(2026, time.January, 12), page.Schedules[0].DueDate)
+	})
+
+	t.Run("statement", func(t *testing.T) {
+		// the default period is the current month, which holds the payments made above
+		var period billingclient.StatementPeriod
+		statement, err := client.GetStatement(ctx, loan.LoanID, period)
+		require.NoError(t, err)
+		assert.Equal(t, 1, statement.PeriodStart.Day())
+		assert.Len(t, statement.Entries, 3)
+
+		csv, err := client.DownloadStatement(ctx, loan.LoanID, period, "csv")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(csv), "date,type,reference"))
+	})
+}
+
+func TestClient_Errors(t *testing.T) {
+	client := newClient(newTestServer(t, nil))
+	ctx := context.Background()
+
+	t.Run("problem details are decoded", func(t *testing.T) {
+		_, err := client.GetLoan(ctx, 999)
+		var apiErr *billingclient.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, billingclient.CodeLoanNotFound, apiErr.Code)
+		assert.NotEmpty(t, apiErr.RequestID)
+	})
+
+	t.Run("every invalid field is reported", func(t *testing.T) {
+		_, err := client.SubmitLoan(ctx, billingclient.SubmitLoanInput{TotalWeeks: 600, StartDate: billingclient.NewDate(2026, time.January, 5)})
+		var apiErr *billingclient.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, billingclient.CodeValidationFailed, apiErr.Code)
+		var fields []string
+		for _, f := range apiErr.FieldErrors {
+			fields = append(fields, f.Field)
+		}
+		assert.ElementsMatch(t, []string{"principal_amount", "total_weeks"}, fields)
+	})
+
+	t.Run("idempotency key reused with a different request", func(t *testing.T) {
+		in := testLoan
+		in.IdempotencyKey = "loan-1"
+		first, err := client.SubmitLoan(ctx, in)
+		require.NoError(t, err)
+		replay, err := client.SubmitLoan(ctx, in)
+		require.NoError(t, err)
+		assert.Equal(t, first.LoanID, replay.LoanID)
+
+		in.PrincipalAmount = 1000
+		_, err = client.SubmitLoan(ctx, in)
+		assert.True(t, billingclient.IsCode(err, billingclient.CodeIdempotencyKeyMismatch), err)
+	})
+
+	t.Run("response that isn't a problem", func(t *testing.T) {
+		proxy := newTestServer(t, func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "upstream is down", http.StatusBadGateway)
+			})
+		})
+		err := billingclient.New(proxy.URL, billingclient.WithMaxRetries(0)).Health(ctx)
+		var apiErr *billingclient.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Equal(t, "Bad Gateway", apiErr.Title)
+	})
+}
+
+// flakyProxy lets the request through but fails the first failures responses of the path with a 502, as when
+// the response is lost between a gateway and the client after the API did the work
+type flakyProxy struct {
+	mu       sync.Mutex
+	path     string
+	failures int
+	keys     []string
+	attempts int
+}
+
+func (p *flakyProxy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.Lock()
+		p.attempts++
+		p.keys = append(p.keys, r.Header.Get(billingclient.IdempotencyKeyHeader))
+		fail := p.attempts <= p.failures
+		p.mu.Unlock()
+
+		if !fail {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(httptest.NewRecorder(), r)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+}
+
+func TestClient_Retries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("a lost payment response is retried with the same key and paid once", func(t *testing.T) {
+		proxy := &flakyProxy{path: "/loan/1/payment", failures: 2}
+		client := newClient(newTestServer(t, proxy.wrap))
+		loan, err := client.SubmitLoan(ctx, testLoan)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), loan.LoanID)
+
+		_, err = client.SubmitPayment(ctx, loan.LoanID, billingclient.SubmitPaymentInput{Amount: loan.WeeklyPaymentAmount})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, proxy.attempts)
+		require.NotEmpty(t, proxy.keys[0])
+		assert.Equal(t, []string{proxy.keys[0], proxy.keys[0], proxy.keys[0]}, proxy.keys)
+
+		outstanding, err := client.GetOutstanding(ctx, loan.LoanID)
+		require.NoError(t, err)
+		assert.Equal(t, loan.TotalPayable-loan.WeeklyPaymentAmount, outstanding.Outstanding)
+	})
+
+	t.Run("reads are retried", func(t *testing.T) {
+		proxy := &flakyProxy{path: "/health", failures: 2}
+		client := newClient(newTestServer(t, proxy.wrap))
+		require.NoError(t, client.Health(ctx))
+		assert.Equal(t, 3, proxy.attempts)
+	})
+
+	t.Run("writes without an idempotency key are not retried", func(t *testing.T) {
+		proxy := &flakyProxy{path: "/collection/run", failures: 1}
+		client := newClient(newTestServer(t, proxy.wrap))
+		_, err := client.RunCollection(ctx, billingclient.NewDate(2026, time.February, 2))
+		var apiErr *billingclient.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.Equal(t, 1, proxy.attempts)
+	})
+
+	t.Run("an iterator stops at the first error", func(t *testing.T) {
+		proxy := &flakyProxy{path: "/loan/1/schedule", failures: 10}
+		client := billingclient.New(newTestServer(t, proxy.wrap).URL, billingclient.WithMaxRetries(0))
+		var calls int
+		for _, err := range client.Schedules(ctx, 1, 10) {
+			calls++
+			assert.Error(t, err)
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries give up after the maximum", func(t *testing.T) {
+		proxy := &flakyProxy{path: "/health", failures: 10}
+		client := billingclient.New(newTestServer(t, proxy.wrap).URL, billingclient.WithMaxRetries(2), billingclient.WithBackoff(time.Millisecond, time.Millisecond))
+		require.Error(t, client.Health(ctx))
+		assert.Equal(t, 3, proxy.attempts)
+	})
+}
+
+func TestClient_CollectionAndWebhooks(t *testing.T) {
+	client := newClient(newTestServer(t, nil))
+	ctx := context.Background()
+
+	loan, err := client.SubmitLoan(ctx, testLoan)
+	require.NoError(t, err)
+
+	t.Run("collection run", func(t *testing.T) {
+		_, err := client.CreateMandate(ctx, loan.LoanID, billingclient.CreateMandateInput{
+			AccountHolder: "Jane Doe", BankCode: "BCA", AccountNumber: "1234567890", Reference: "MDT-1",
+		})
+		require.NoError(t, err)
+
+		batch, err := client.RunCollection(ctx, billingclient.NewDate(2026, time.February, 2))
+		require.NoError(t, err)
+		require.NotNil(t, batch)
+		nothing, err := client.RunCollection(ctx, billingclient.NewDate(2026, time.February, 2))
+		require.NoError(t, err)
+		assert.Nil(t, nothing)
+
+		detail, err := client.GetCollectionBatch(ctx, batch.BatchID)
+		require.NoError(t, err)
+		require.NotEmpty(t, detail.Items)
+
+		file, err := client.ExportCollectionBatch(ctx, batch.BatchID, billingclient.BankFileFormatCSV)
+		require.NoError(t, err)
+		assert.NotEmpty(t, file)
+
+		result := fmt.Sprintf("item_id,status,reason_code\n%d,SUCCESS,\n", detail.Items[0].ItemID)
+		summary, err := client.ImportCollectionResult(ctx, batch.BatchID, billingclient.BankFileFormatCSV, strings.NewReader(result))
+		require.NoError(t, err)
+		assert.Equal(t, 1, summary.Succeeded)
+
+		revoked, err := client.RevokeMandate(ctx, loan.LoanID)
+		require.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+	})
+
+	t.Run("webhook subscriptions", func(t *testing.T) {
+		created, err := client.CreateWebhookSubscription(ctx, billingclient.CreateWebhookSubscriptionInput{
+			URL: "https://partner.example.com/hook", EventTypes: []string{"PaymentReceived"},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.Secret)
+
+		list, err := client.ListWebhookSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		for _, err := range client.WebhookDeliveries(ctx, created.SubscriptionID, billingclient.WebhookDeliveryStatusPending, 5) {
+			require.NoError(t, err)
+		}
+
+		deleted, err := client.DeleteWebhookSubscription(ctx, created.SubscriptionID)
+		require.NoError(t, err)
+		assert.NotEqual(t, created.Status, deleted.Status)
+
+		_, err = client.RedeliverWebhook(ctx, 999)
+		assert.True(t, billingclient.IsCode(err, billingclient.CodeWebhookDeliveryNotFound), err)
+	})
+}
+
+func TestClient_StreamLoanEvents(t *testing.T) {
+	client := newClient(newTestServer(t, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	loan, err := client.SubmitLoan(ctx, testLoan)
+	require.NoError(t, err)
+
+	// the events of the loan creation are replayed from the start of the bus
+	stream, err := client.StreamLoanEvents(ctx, loan.LoanID, 0)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	_, err = client.SubmitPayment(ctx, loan.LoanID, billingclient.SubmitPaymentInput{Amount: loan.WeeklyPaymentAmount})
+	require.NoError(t, err)
+
+	for {
+		event, err := stream.Next()
+		require.NoError(t, err)
+		assert.Equal(t, loan.LoanID, event.AggregateID)
+		assert.Equal(t, event.EventID, stream.LastEventID())
+		if event.EventType == "PaymentReceived" {
+			return
+		}
+	}
+}
+
+func TestClient_StreamUnknownLoan(t *testing.T) {
+	client := newClient(newTestServer(t, nil))
+	_, err := client.StreamLoanEvents(context.Background(), 999, 0)
+	assert.True(t, billingclient.IsCode(err, billingclient.CodeLoanNotFound), err)
+}
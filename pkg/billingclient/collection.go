@@ -0,0 +1,116 @@
+package billingclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// bank file formats of the export and of the result import
+const (
+	BankFileFormatCSV   = "csv"
+	BankFileFormatFixed = "fixed"
+)
+
+type CollectionItem struct {
+	ItemID           int64  `json:"item_id"`
+	LoanID           int64  `json:"loan_id"`
+	ScheduleSequence int    `json:"schedule_sequence"`
+	Amount           int64  `json:"amount"`
+	Attempt          int    `json:"attempt"`
+	Status           string `json:"status"`
+	FailureCode      string `json:"failure_code"`
+	NextAttemptOn    *Date  `json:"next_attempt_on"`
+	PaymentID        *int64 `json:"payment_id"`
+}
+
+type CollectionBatch struct {
+	BatchID        int64            `json:"batch_id"`
+	CollectionDate Date             `json:"collection_date"`
+	Status         string           `json:"status"`
+	ItemCount      int              `json:"item_count"`
+	TotalAmount    int64            `json:"total_amount"`
+	Items          []CollectionItem `json:"items"`
+}
+
+type CollectionImportResult struct {
+	BatchID        int64 `json:"batch_id"`
+	Succeeded      int   `json:"succeeded"`
+	RetryScheduled int   `json:"retry_scheduled"`
+	Failed         int   `json:"failed"`
+	Skipped        int   `json:"skipped"`
+	Unposted       int   `json:"unposted"`
+	Pending        int   `json:"pending"`
+}
+
+func batchPath(batchID int64, suffix string) string {
+	return fmt.Sprintf("/collection/batch/%d%s", batchID, suffix)
+}
+
+func formatQuery(format string) url.Values {
+	if format == "" {
+		return nil
+	}
+	return url.Values{"format": {format}}
+}
+
+// RunCollection creates the collection batch of the date, a zero date collects today.
+// The batch is nil when nothing is due.
+func (c *Client) RunCollection(ctx context.Context, date Date) (*CollectionBatch, error) {
+	req := &request{method: http.MethodPost, path: "/collection/run"}
+	if !date.IsZero() {
+		req.query = url.Values{"date": {date.String()}}
+	}
+
+	resp, err := c.do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 200 carries a status message instead of a batch
+	if resp.StatusCode != http.StatusCreated {
+		return nil, nil
+	}
+	var out CollectionBatch
+	if err := decodeJSON(resp, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCollectionBatch returns the batch with its items
+func (c *Client) GetCollectionBatch(ctx context.Context, batchID int64) (*CollectionBatch, error) {
+	var out CollectionBatch
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: batchPath(batchID, "")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportCollectionBatch downloads the bank file of the batch, format is BankFileFormatCSV (default) or BankFileFormatFixed
+func (c *Client) ExportCollectionBatch(ctx context.Context, batchID int64, format string) ([]byte, error) {
+	return c.doRaw(ctx, &request{method: http.MethodGet, path: batchPath(batchID, "/export"), query: formatQuery(format)})
+}
+
+// ImportCollectionResult uploads the result file returned by the bank, it is never retried
+func (c *Client) ImportCollectionResult(ctx context.Context, batchID int64, format string, file io.Reader) (*CollectionImportResult, error) {
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "text/csv"
+	if format == BankFileFormatFixed {
+		contentType = "text/plain"
+	}
+	req := &request{method: http.MethodPost, path: batchPath(batchID, "/result"), query: formatQuery(format), body: body, contentType: contentType}
+
+	var out CollectionImportResult
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,128 @@
+package billingclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// codes of the error catalog of the API, see the README of the server
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeDatabaseTimeout  = "database_timeout"
+	CodeInternalError    = "internal_error"
+
+	CodeLoanNotFound                = "loan_not_found"
+	CodeInvalidOutstandingState     = "invalid_outstanding_state"
+	CodeInvalidLoanTerms            = "invalid_loan_terms"
+	CodeInvalidPaymentAmount        = "invalid_payment_amount"
+	CodeLoanAlreadyClosed           = "loan_already_closed"
+	CodeDuplicatePayment            = "duplicate_payment"
+	CodeConcurrentPayment           = "concurrent_payment"
+	CodeDelinquencyCheckFailed      = "delinquency_check_failed"
+	CodeScheduleNotFound            = "schedule_not_found"
+	CodeInvalidStatementPeriod      = "invalid_statement_period"
+	CodeMandateNotFound             = "mandate_not_found"
+	CodeMandateAlreadyActive        = "mandate_already_active"
+	CodeCollectionBatchNotFound     = "collection_batch_not_found"
+	CodeCollectionBatchClosed       = "collection_batch_closed"
+	CodeCollectionItemNotFound      = "collection_item_not_found"
+	CodeInvalidBankFile             = "invalid_bank_file"
+	CodeInvalidWebhookSubscription  = "invalid_webhook_subscription"
+	CodeWebhookSubscriptionNotFound = "webhook_subscription_not_found"
+	CodeWebhookDeliveryNotFound     = "webhook_delivery_not_found"
+	CodeWebhookDeliveryNotDead      = "webhook_delivery_not_dead"
+	CodeIdempotencyKeyMismatch      = "idempotency_key_mismatch"
+	CodeIdempotencyKeyInFlight      = "idempotency_key_in_flight"
+	CodeUnauthenticated             = "unauthenticated"
+	CodeInsufficientScope           = "insufficient_scope"
+	CodePermissionDenied            = "permission_denied"
+	CodeAPIKeyNotFound              = "api_key_not_found"
+	CodeInvalidAPIKey               = "invalid_api_key"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError is a non 2xx response, decoded from the problem details (RFC 7807) sent by the API
+type APIError struct {
+	StatusCode  int          `json:"status"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Detail      string       `json:"detail"`
+	Instance    string       `json:"instance"`
+	Code        string       `json:"code"`
+	RequestID   string       `json:"request_id"`
+	FieldErrors []FieldError `json:"errors"`
+	// RetryAfter is the delay asked by a Retry-After header, zero when absent
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("billing api: %d %s", e.StatusCode, e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, f := range e.FieldErrors {
+		msg += fmt.Sprintf(" [%s: %s]", f.Field, f.Message)
+	}
+	if e.RequestID != "" {
+		msg += " (request_id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// retryable tells the responses that may succeed when the same request is sent again
+func (e *APIError) retryable(req *request) bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.retryable()
+	case http.StatusConflict:
+		// the original request is still running, the retry gets its stored response once it's done
+		return e.Code == CodeIdempotencyKeyInFlight && req.idempotencyKey != ""
+	}
+	return false
+}
+
+// IsCode tells whether err is an *APIError with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// maximum size of an error body that is read, a misbehaving proxy may send a whole HTML page
+const maxErrorBodySize = 64 << 10
+
+// readAPIError decodes the error response and closes its body, responses that aren't problem details
+// (eg from a proxy in front of the API) keep the status and get the status text as title
+func readAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	apiErr := &APIError{}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/problem+json" || mediaType == "application/json" {
+		_ = json.Unmarshal(body, apiErr)
+	}
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-Id")
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
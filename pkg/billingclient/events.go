@@ -0,0 +1,103 @@
+package billingclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is the envelope of a payment, schedule or loan status change, the payload depends on the event type
+type Event struct {
+	EventID       int64           `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+/*
+EventStream reads the Server-Sent Events of the API.
+
+The stream isn't reconnected automatically: when Next fails, open a new stream with LastEventID to receive the
+events missed in between.
+*/
+type EventStream struct {
+	body        io.ReadCloser
+	reader      *bufio.Reader
+	lastEventID int64
+}
+
+// StreamLoanEvents streams the events of one loan, starting after lastEventID (0 for only new events)
+func (c *Client) StreamLoanEvents(ctx context.Context, loanID, lastEventID int64) (*EventStream, error) {
+	return c.streamEvents(ctx, loanPath(loanID, "/events"), lastEventID)
+}
+
+// StreamAllEvents streams the events of every loan
+func (c *Client) StreamAllEvents(ctx context.Context, lastEventID int64) (*EventStream, error) {
+	return c.streamEvents(ctx, "/loan/admin/events", lastEventID)
+}
+
+func (c *Client) streamEvents(ctx context.Context, path string, lastEventID int64) (*EventStream, error) {
+	req := &request{method: http.MethodGet, path: path, header: http.Header{"Accept": {"text/event-stream"}}}
+	if lastEventID > 0 {
+		req.header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+
+	// the stream lasts until the context ends, the timeout of the client would cut it
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	resp, err := c.do(ctx, &streamClient, req)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: resp.Body, reader: bufio.NewReader(resp.Body), lastEventID: lastEventID}, nil
+}
+
+// Next blocks until the next event, it returns io.EOF when the server ends the stream
+func (s *EventStream) Next() (*Event, error) {
+	var data strings.Builder
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch {
+		case line == "":
+			// a blank line ends the message, retry hints and keep-alive comments carry no data
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return nil, fmt.Errorf("billingclient: decoding event: %w", err)
+			}
+			s.lastEventID = event.EventID
+			return &event, nil
+		case field == "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+}
+
+// LastEventID is the ID of the last event received, to resume the stream after a disconnect
+func (s *EventStream) LastEventID() int64 {
+	return s.lastEventID
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,319 @@
+package billingclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type SubmitLoanInput struct {
+	PrincipalAmount    int64   `json:"principal_amount"`
+	AnnualInterestRate float64 `json:"annual_interest_rate"`
+	TotalWeeks         int     `json:"total_weeks"`
+	StartDate          Date    `json:"start_date"`
+	// BorrowerID is the subject of the borrower allowed to read the loan
+	BorrowerID string `json:"borrower_id,omitempty"`
+	// IdempotencyKey is generated when empty
+	IdempotencyKey string `json:"-"`
+}
+
+type SubmitLoanResult struct {
+	LoanID              int64 `json:"loan_id"`
+	WeeklyPaymentAmount int64 `json:"weekly_payment_amount"`
+	TotalPayable        int64 `json:"total_payable"`
+}
+
+type Loan struct {
+	LoanID              int64     `json:"loan_id"`
+	TotalPayable        int64     `json:"total_payable"`
+	WeeklyPaymentAmount int64     `json:"weekly_payment_amount"`
+	TotalWeeks          int       `json:"total_weeks"`
+	CreatedAt           time.Time `json:"created_at"`
+	IsDelinquent        bool      `json:"is_delinquent"`
+	BorrowerID          string    `json:"borrower_id"`
+}
+
+// LoanSummary is a loan of the loan list, without the delinquency flag computed for a single loan
+type LoanSummary struct {
+	LoanID              int64     `json:"loan_id"`
+	PrincipalAmount     int64     `json:"principal_amount"`
+	TotalPayable        int64     `json:"total_payable"`
+	WeeklyPaymentAmount int64     `json:"weekly_payment_amount"`
+	TotalWeeks          int       `json:"total_weeks"`
+	CreatedAt           time.Time `json:"created_at"`
+	BorrowerID          string    `json:"borrower_id"`
+}
+
+type LoanPage struct {
+	Loans      []LoanSummary `json:"loans"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+type Outstanding struct {
+	LoanID      int64 `json:"loan_id"`
+	Outstanding int64 `json:"outstanding"`
+}
+
+type SubmitPaymentInput struct {
+	Amount int64 `json:"amount"`
+	// IdempotencyKey is generated when empty, the API requires one for every payment
+	IdempotencyKey string `json:"-"`
+}
+
+type SubmitPaymentResult struct {
+	PaymentID int64 `json:"payment_id"`
+	// AlreadyProcessed is set when the API replayed the response of an earlier request with the same idempotency key,
+	// PaymentID is then the payment of that request
+	AlreadyProcessed bool `json:"-"`
+}
+
+type Payment struct {
+	WeekNumber int       `json:"week_number"`
+	Amount     int64     `json:"amount"`
+	PaidAt     time.Time `json:"paid_at"`
+}
+
+type PaymentPage struct {
+	Payments   []Payment `json:"payments"`
+	NextCursor string    `json:"next_cursor"`
+}
+
+type Schedule struct {
+	Sequence   int    `json:"sequence"`
+	DueDate    Date   `json:"due_date"`
+	Amount     int64  `json:"amount"`
+	PaidAmount int64  `json:"paid_amount"`
+	Status     string `json:"status"`
+}
+
+type SchedulePage struct {
+	Schedules  []Schedule `json:"schedules"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+func loanPath(loanID int64, suffix string) string {
+	return fmt.Sprintf("/loan/%d%s", loanID, suffix)
+}
+
+// SubmitLoan creates a loan, a retry with the same idempotency key returns the loan created by the first call
+func (c *Client) SubmitLoan(ctx context.Context, in SubmitLoanInput) (*SubmitLoanResult, error) {
+	req, err := jsonRequest(http.MethodPost, "/loan", in)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotencyKey = in.IdempotencyKey
+	if req.idempotencyKey == "" {
+		req.idempotencyKey = NewIdempotencyKey()
+	}
+
+	var out SubmitLoanResult
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetLoan(ctx context.Context, loanID int64) (*Loan, error) {
+	var out Loan
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListLoans(ctx context.Context, params ListParams) (*LoanPage, error) {
+	var out LoanPage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/loan", query: params.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Loans iterates over every loan, newest first
+func (c *Client) Loans(ctx context.Context, pageSize int) iter.Seq2[LoanSummary, error] {
+	return paginate(pageSize, func(params ListParams) ([]LoanSummary, string, error) {
+		page, err := c.ListLoans(ctx, params)
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Loans, page.NextCursor, nil
+	})
+}
+
+func (c *Client) GetOutstanding(ctx context.Context, loanID int64) (*Outstanding, error) {
+	var out Outstanding
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/outstanding")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitPayment pays the installment of the current week, a retry with the same idempotency key never pays twice
+func (c *Client) SubmitPayment(ctx context.Context, loanID int64, in SubmitPaymentInput) (*SubmitPaymentResult, error) {
+	req, err := jsonRequest(http.MethodPost, loanPath(loanID, "/payment"), in)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotencyKey = in.IdempotencyKey
+	if req.idempotencyKey == "" {
+		req.idempotencyKey = NewIdempotencyKey()
+	}
+
+	resp, err := c.do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out SubmitPaymentResult
+	if err := decodeJSON(resp, req, &out); err != nil {
+		return nil, err
+	}
+	out.AlreadyProcessed = resp.Header.Get(IdempotencyReplayedHeader) == "true"
+	return &out, nil
+}
+
+func (c *Client) ListPayments(ctx context.Context, loanID int64, params ListParams) (*PaymentPage, error) {
+	var out PaymentPage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/payment"), query: params.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Payments iterates over every payment of the loan, fetching pages of pageSize (0 for the server default)
+func (c *Client) Payments(ctx context.Context, loanID int64, pageSize int) iter.Seq2[Payment, error] {
+	return paginate(pageSize, func(params ListParams) ([]Payment, string, error) {
+		page, err := c.ListPayments(ctx, loanID, params)
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Payments, page.NextCursor, nil
+	})
+}
+
+func (c *Client) ListSchedules(ctx context.Context, loanID int64, params ListParams) (*SchedulePage, error) {
+	var out SchedulePage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/schedule"), query: params.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Schedules iterates over every installment of the loan in due date order
+func (c *Client) Schedules(ctx context.Context, loanID int64, pageSize int) iter.Seq2[Schedule, error] {
+	return paginate(pageSize, func(params ListParams) ([]Schedule, string, error) {
+		page, err := c.ListSchedules(ctx, loanID, params)
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Schedules, page.NextCursor, nil
+	})
+}
+
+type StatementEntry struct {
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Debit       int64     `json:"debit"`
+	Credit      int64     `json:"credit"`
+	Balance     int64     `json:"balance"`
+}
+
+type UpcomingInstallment struct {
+	Sequence int   `json:"sequence"`
+	DueDate  Date  `json:"due_date"`
+	Amount   int64 `json:"amount"`
+}
+
+type Statement struct {
+	LoanID               int64                 `json:"loan_id"`
+	PeriodStart          Date                  `json:"period_start"`
+	PeriodEnd            Date                  `json:"period_end"`
+	OpeningBalance       int64                 `json:"opening_balance"`
+	Entries              []StatementEntry      `json:"entries"`
+	ClosingBalance       int64                 `json:"closing_balance"`
+	UpcomingInstallments []UpcomingInstallment `json:"upcoming_installments"`
+	GeneratedAt          time.Time             `json:"generated_at"`
+}
+
+// StatementPeriod bounds a statement, both inclusive, a zero date uses the server default (the current month)
+type StatementPeriod struct {
+	From Date
+	To   Date
+}
+
+func (p StatementPeriod) query(format string) url.Values {
+	q := url.Values{}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.String())
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.String())
+	}
+	if format != "" {
+		q.Set("format", format)
+	}
+	return q
+}
+
+func (c *Client) GetStatement(ctx context.Context, loanID int64, period StatementPeriod) (*Statement, error) {
+	var out Statement
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/statement"), query: period.query("")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadStatement returns the statement rendered by the server, format is csv or html
+func (c *Client) DownloadStatement(ctx context.Context, loanID int64, period StatementPeriod, format string) ([]byte, error) {
+	return c.doRaw(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/statement"), query: period.query(strings.ToLower(format))})
+}
+
+type CreateMandateInput struct {
+	AccountHolder string `json:"account_holder"`
+	BankCode      string `json:"bank_code"`
+	AccountNumber string `json:"account_number"`
+	Reference     string `json:"reference"`
+}
+
+type Mandate struct {
+	MandateID     int64      `json:"mandate_id"`
+	LoanID        int64      `json:"loan_id"`
+	AccountHolder string     `json:"account_holder"`
+	BankCode      string     `json:"bank_code"`
+	AccountNumber string     `json:"account_number"`
+	Reference     string     `json:"reference"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+}
+
+func (c *Client) CreateMandate(ctx context.Context, loanID int64, in CreateMandateInput) (*Mandate, error) {
+	req, err := jsonRequest(http.MethodPost, loanPath(loanID, "/mandate"), in)
+	if err != nil {
+		return nil, err
+	}
+	return c.mandate(ctx, req)
+}
+
+func (c *Client) GetMandate(ctx context.Context, loanID int64) (*Mandate, error) {
+	return c.mandate(ctx, &request{method: http.MethodGet, path: loanPath(loanID, "/mandate")})
+}
+
+func (c *Client) RevokeMandate(ctx context.Context, loanID int64) (*Mandate, error) {
+	return c.mandate(ctx, &request{method: http.MethodDelete, path: loanPath(loanID, "/mandate")})
+}
+
+func (c *Client) mandate(ctx context.Context, req *request) (*Mandate, error) {
+	var out Mandate
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,169 @@
+package billingclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"time"
+)
+
+// statuses of a webhook delivery
+const (
+	WebhookDeliveryStatusPending   = "PENDING"
+	WebhookDeliveryStatusDelivered = "DELIVERED"
+	WebhookDeliveryStatusDead      = "DEAD"
+)
+
+type CreateWebhookSubscriptionInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret signs the deliveries, the server generates one when empty
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookSubscription struct {
+	SubscriptionID int64     `json:"subscription_id"`
+	URL            string    `json:"url"`
+	EventTypes     []string  `json:"event_types"`
+	Secret         string    `json:"secret"` // only returned on creation
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  *int      `json:"status_code"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+type WebhookDelivery struct {
+	DeliveryID     int64                    `json:"delivery_id"`
+	SubscriptionID int64                    `json:"subscription_id"`
+	EventID        int64                    `json:"event_id"`
+	EventType      string                   `json:"event_type"`
+	Status         string                   `json:"status"`
+	Attempts       int                      `json:"attempts"`
+	LastStatusCode *int                     `json:"last_status_code"`
+	LastError      string                   `json:"last_error"`
+	NextAttemptAt  *time.Time               `json:"next_attempt_at"`
+	CreatedAt      time.Time                `json:"created_at"`
+	DeliveredAt    *time.Time               `json:"delivered_at"`
+	AttemptLog     []WebhookDeliveryAttempt `json:"attempt_log"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// ListWebhookDeliveriesParams selects a page of the delivery log, Status optionally filters on one status
+type ListWebhookDeliveriesParams struct {
+	ListParams
+	Status string
+}
+
+func subscriptionPath(subscriptionID int64, suffix string) string {
+	return fmt.Sprintf("/webhook/subscription/%d%s", subscriptionID, suffix)
+}
+
+func deliveryPath(deliveryID int64, suffix string) string {
+	return fmt.Sprintf("/webhook/delivery/%d%s", deliveryID, suffix)
+}
+
+// CreateWebhookSubscription subscribes the URL, the returned subscription is the only one carrying the secret
+func (c *Client) CreateWebhookSubscription(ctx context.Context, in CreateWebhookSubscriptionInput) (*WebhookSubscription, error) {
+	req, err := jsonRequest(http.MethodPost, "/webhook/subscription", in)
+	if err != nil {
+		return nil, err
+	}
+	return c.subscription(ctx, req)
+}
+
+func (c *Client) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	var out struct {
+		Subscriptions []WebhookSubscription `json:"subscriptions"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: "/webhook/subscription"}, &out); err != nil {
+		return nil, err
+	}
+	return out.Subscriptions, nil
+}
+
+func (c *Client) GetWebhookSubscription(ctx context.Context, subscriptionID int64) (*WebhookSubscription, error) {
+	return c.subscription(ctx, &request{method: http.MethodGet, path: subscriptionPath(subscriptionID, "")})
+}
+
+func (c *Client) DeleteWebhookSubscription(ctx context.Context, subscriptionID int64) (*WebhookSubscription, error) {
+	return c.subscription(ctx, &request{method: http.MethodDelete, path: subscriptionPath(subscriptionID, "")})
+}
+
+func (c *Client) subscription(ctx context.Context, req *request) (*WebhookSubscription, error) {
+	var out WebhookSubscription
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, params ListWebhookDeliveriesParams) (*WebhookDeliveryPage, error) {
+	query := params.query()
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	return c.deliveryPage(ctx, &request{method: http.MethodGet, path: subscriptionPath(subscriptionID, "/delivery"), query: query})
+}
+
+// WebhookDeliveries iterates over the delivery log of a subscription, newest first
+func (c *Client) WebhookDeliveries(ctx context.Context, subscriptionID int64, status string, pageSize int) iter.Seq2[WebhookDelivery, error] {
+	return paginate(pageSize, func(params ListParams) ([]WebhookDelivery, string, error) {
+		page, err := c.ListWebhookDeliveries(ctx, subscriptionID, ListWebhookDeliveriesParams{ListParams: params, Status: status})
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Deliveries, page.NextCursor, nil
+	})
+}
+
+// GetWebhookDelivery returns the delivery with every attempt made
+func (c *Client) GetWebhookDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: deliveryPath(deliveryID, "")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ListWebhookDeadLetters(ctx context.Context, params ListParams) (*WebhookDeliveryPage, error) {
+	return c.deliveryPage(ctx, &request{method: http.MethodGet, path: "/webhook/dead-letter", query: params.query()})
+}
+
+// WebhookDeadLetters iterates over the deliveries that gave up, of every subscription
+func (c *Client) WebhookDeadLetters(ctx context.Context, pageSize int) iter.Seq2[WebhookDelivery, error] {
+	return paginate(pageSize, func(params ListParams) ([]WebhookDelivery, string, error) {
+		page, err := c.ListWebhookDeadLetters(ctx, params)
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Deliveries, page.NextCursor, nil
+	})
+}
+
+// RedeliverWebhook moves a dead delivery back to the queue
+func (c *Client) RedeliverWebhook(ctx context.Context, deliveryID int64) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	if err := c.doJSON(ctx, &request{method: http.MethodPost, path: deliveryPath(deliveryID, "/redeliver")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) deliveryPage(ctx context.Context, req *request) (*WebhookDeliveryPage, error) {
+	var out WebhookDeliveryPage
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}